@@ -0,0 +1,148 @@
+package client
+
+// api.go — Typed calls: state, bids and catalogue imports.
+//
+// State reads /api/v1/state, the versioned shape meant for integrations.
+// PlaceBid posts to /bid like the web UI does and reports the X-Bid-* headers.
+// AddItems posts a whole catalogue to /items/batch, which the coordinator
+// applies all or nothing. AdminJSON is there for the admin endpoints that
+// have no typed call.
+
+import (
+	"auction_node/node"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// State returns the auction state as one node sees it.
+func (c *Client) State(ctx context.Context) (*node.StateV1, error) {
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/api/v1/state", idempotent: true})
+	if err != nil {
+		return nil, err
+	}
+	var state node.StateV1
+	if err := json.Unmarshal(resp.body, &state); err != nil {
+		return nil, protocolError(resp.node, "decode state", err)
+	}
+	return &state, nil
+}
+
+// Bid is one bid. Bidder is a display name; the cluster tells bidders apart
+// by the bidder_id cookie in the client's jar.
+type Bid struct {
+	Bidder         string
+	Amount         int
+	ItemID         string // the lot bid on; empty for whatever is up
+	IdempotencyKey string // generated when empty
+}
+
+// BidResult is a committed bid.
+type BidResult struct {
+	Node        string // node the bid was sent to
+	Outcome     string // X-Bid-Outcome, "committed"
+	Message     string
+	TxnID       string
+	Coordinator string
+	Term        int
+}
+
+// PlaceBid submits a bid. A rejection is an *APIError whose Outcome is the
+// X-Bid-Outcome code ("outbid", "item_changed", ...).
+func (c *Client) PlaceBid(ctx context.Context, bid Bid) (*BidResult, error) {
+	if bid.IdempotencyKey == "" {
+		bid.IdempotencyKey = newIdempotencyKey()
+	}
+	form := url.Values{"amount": {strconv.Itoa(bid.Amount)}}
+	if bid.Bidder != "" {
+		form.Set("bidder", bid.Bidder)
+	}
+	if bid.ItemID != "" {
+		form.Set("itemId", bid.ItemID)
+	}
+	resp, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/bid",
+		body:        []byte(form.Encode()),
+		contentType: "application/x-www-form-urlencoded",
+		header:      http.Header{"Idempotency-Key": {bid.IdempotencyKey}},
+		idempotent:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	term, _ := strconv.Atoi(resp.header.Get("X-Bid-Term"))
+	return &BidResult{
+		Node:        resp.node,
+		Outcome:     resp.header.Get("X-Bid-Outcome"),
+		Message:     string(resp.body),
+		TxnID:       resp.header.Get("X-Bid-Txn"),
+		Coordinator: resp.header.Get("X-Bid-Coordinator"),
+		Term:        term,
+	}, nil
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// BatchResult is the answer to AddItems.
+type BatchResult struct {
+	Accepted bool                  `json:"accepted"`
+	Message  string                `json:"message"`
+	IDs      []string              `json:"ids,omitempty"`
+	Errors   []node.BatchItemError `json:"errors,omitempty"`
+}
+
+// AddItems queues a catalogue in one all-or-nothing step. A rejected batch
+// is an *APIError with status 400; the per-item reasons are in the
+// returned BatchResult, which is non-nil whenever the node answered with
+// one.
+func (c *Client) AddItems(ctx context.Context, items []node.TemplateItem) (*BatchResult, error) {
+	body, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/items/batch",
+		body:        body,
+		contentType: "application/json",
+		admin:       true,
+	})
+	if err != nil {
+		var apiErr *APIError
+		var result BatchResult
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest &&
+			json.Unmarshal([]byte(apiErr.Message), &result) == nil {
+			apiErr.Message = result.Message
+			return &result, err
+		}
+		return nil, err
+	}
+	var result BatchResult
+	if err := json.Unmarshal(resp.body, &result); err != nil {
+		return nil, protocolError(resp.node, "decode batch reply", err)
+	}
+	return &result, nil
+}
+
+// AdminJSON GETs an admin endpoint such as "/alerts" or "/admin/inflight"
+// and decodes its JSON into out.
+func (c *Client) AdminJSON(ctx context.Context, path string, out any) error {
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: path, admin: true, idempotent: true})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(resp.body, out); err != nil {
+		return protocolError(resp.node, "decode "+path, err)
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package client
+
+// client.go — Go client for the auction cluster's HTTP API.
+//
+// A Client is configured with every node's base URL and talks to whichever
+// one answers, starting with the last node that did. It fails over to the
+// next node when one cannot be reached, and for reads and bids also when a
+// node answers 503: no leader yet, leader unreachable or not ready, in every
+// case with nothing changed. Bids also carry an Idempotency-Key, so a bid
+// resent after a lost answer is collapsed by the coordinator. Admin writes
+// such as AddItems only move on when the connection was never made, since a
+// lost answer may hide an applied change.
+//
+// Security options mirror the node's flags. CAFile is the bundle the nodes'
+// certificates must chain to (--tls-ca on the nodes; default: system
+// roots), and CertFile/KeyFile present a client certificate for nodes run
+// with --require-client-cert. Token is --admin-token, sent as a Bearer
+// header on admin calls and replaceable at any time with SetToken, so a
+// long-running program can pick up a rotated token without being rebuilt.
+// The cookie jar holds the bidder_id cookie a node issues on the first bid.
+// Cookies are per host, so the client copies every cookie a node sets to
+// the other configured nodes: a bidder keeps one identity however often it
+// fails over. Redirects between configured nodes keep the token; a redirect
+// anywhere else drops it.
+//
+// With --admin-port, operator endpoints live on a separate listener; list
+// those in AdminNodes. Without it admin calls use Nodes.
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultTimeout = 10 * time.Second
+	maxRedirects   = 10
+	maxBodyBytes   = 8 << 20
+)
+
+// Options configures a Client. Only Nodes is required.
+type Options struct {
+	Nodes      []string // public base URLs, e.g. "https://10.0.0.5:8001"
+	AdminNodes []string // --admin-port listeners, if the nodes have them
+	CAFile     string   // PEM bundle node certificates must chain to
+	CertFile   string   // client certificate for mutual TLS (needs KeyFile)
+	KeyFile    string
+	Token      string         // --admin-token of the nodes
+	Jar        http.CookieJar // nil creates an in-memory jar
+	Timeout    time.Duration  // per request; DefaultTimeout if 0
+}
+
+// nodeSet is an ordered list of nodes with the index of the last one that
+// answered.
+type nodeSet struct {
+	urls []*url.URL
+	next atomic.Int32
+}
+
+// Client calls the cluster. It is safe for concurrent use.
+type Client struct {
+	public *nodeSet
+	admin  *nodeSet
+	hosts  map[string]bool // host:port of every configured node
+	jar    http.CookieJar
+	http   *http.Client
+
+	mu    sync.RWMutex
+	token string
+}
+
+// New checks opts and loads any certificates.
+func New(opts Options) (*Client, error) {
+	if len(opts.Nodes) == 0 {
+		return nil, errors.New("client: no nodes given")
+	}
+	c := &Client{hosts: map[string]bool{}, jar: opts.Jar, token: opts.Token}
+	var err error
+	if c.public, err = c.parseNodes(opts.Nodes); err != nil {
+		return nil, err
+	}
+	c.admin = c.public
+	if len(opts.AdminNodes) > 0 {
+		if c.admin, err = c.parseNodes(opts.AdminNodes); err != nil {
+			return nil, err
+		}
+	}
+	if c.jar == nil {
+		c.jar, _ = cookiejar.New(nil)
+	}
+	tlsConfig, err := loadTLS(opts.CAFile, opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	c.http = &http.Client{
+		Transport:     transport,
+		Jar:           c.jar,
+		Timeout:       timeout,
+		CheckRedirect: c.checkRedirect,
+	}
+	return c, nil
+}
+
+func (c *Client) parseNodes(raw []string) (*nodeSet, error) {
+	set := &nodeSet{}
+	for _, s := range raw {
+		s = strings.TrimRight(strings.TrimSpace(s), "/")
+		if !strings.Contains(s, "://") {
+			s = "http://" + s
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("client: bad node URL %q", s)
+		}
+		set.urls = append(set.urls, u)
+		c.hosts[u.Host] = true
+	}
+	return set, nil
+}
+
+// loadTLS builds the transport's TLS settings. It returns nil, the
+// default, when no file is given.
+func loadTLS(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("client: read CA bundle: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client: no certificates found in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("client: CertFile and KeyFile must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client: load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// SetToken replaces the admin token for later calls, for token rotation.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+}
+
+func (c *Client) currentToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// checkRedirect keeps the token on redirects to configured nodes only. The
+// transport drops it on any change of host or port.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if via[0].Header.Get("Authorization") == "" {
+		return nil
+	}
+	if c.hosts[req.URL.Host] {
+		req.Header.Set("Authorization", "Bearer "+c.currentToken())
+	} else {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+// shareCookies copies the cookies a node set to every other configured node.
+func (c *Client) shareCookies(from *url.URL, resp *http.Response) {
+	cookies := resp.Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+	for _, set := range []*nodeSet{c.public, c.admin} {
+		for _, u := range set.urls {
+			if u.Host != from.Host {
+				c.jar.SetCookies(u, cookies)
+			}
+		}
+	}
+}
+
+// request describes one call.
+type request struct {
+	method      string
+	path        string // with query, e.g. "/state"
+	body        []byte
+	contentType string
+	header      http.Header
+	admin       bool // send the token, and use AdminNodes
+	idempotent  bool // safe to resend after a lost answer or a 503
+}
+
+// response is a 2xx answer, read in full.
+type response struct {
+	node   string
+	header http.Header
+	body   []byte
+}
+
+// do sends req to the nodes in turn until one gives an answer that should
+// not be retried elsewhere.
+func (c *Client) do(ctx context.Context, req request) (*response, error) {
+	set := c.public
+	if req.admin {
+		set = c.admin
+	}
+	start := int(set.next.Load())
+	var lastErr error
+	for i := range set.urls {
+		idx := (start + i) % len(set.urls)
+		resp, err := c.send(ctx, set.urls[idx], req)
+		if err == nil {
+			set.next.Store(int32(idx))
+			return resp, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || !c.shouldFailOver(req, err) {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+func (c *Client) shouldFailOver(req request, err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return req.idempotent && apiErr.StatusCode == http.StatusServiceUnavailable
+	}
+	if errors.Is(err, ErrProtocol) {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true // never reached the node
+	}
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return false // the same CA bundle would fail everywhere
+	}
+	return req.idempotent
+}
+
+func (c *Client) send(ctx context.Context, base *url.URL, req request) (*response, error) {
+	node := base.String()
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, node+req.path, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.header {
+		httpReq.Header[k] = v
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if token := c.currentToken(); req.admin && token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", node, err)
+	}
+	defer resp.Body.Close()
+	c.shareCookies(base, resp)
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%s: read response: %w", node, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, apiError(node, resp, b)
+	}
+	return &response{node: node, header: resp.Header, body: b}, nil
+}
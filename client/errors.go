@@ -0,0 +1,81 @@
+package client
+
+// errors.go — Typed errors: what the node said no to vs what went wrong on
+// the way.
+//
+// A node that answers with a non-2xx status gives an *APIError carrying the
+// status, the X-Bid-Outcome code when there is one, the message and
+// Retry-After. errors.Is matches it against ErrUnauthorized (401, wrong or
+// missing token), ErrForbidden (403: admin endpoints disabled because the
+// node has no --admin-token, or a bidder not on an invite-only lot) and
+// ErrUnavailable (503, retry later). A 2xx answer the client cannot make
+// sense of wraps ErrProtocol. Transport and TLS failures, such as a refused
+// connection or a certificate that does not chain to the CA bundle, are
+// returned as net/http reports them.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnavailable  = errors.New("unavailable")
+	ErrProtocol     = errors.New("unexpected response")
+)
+
+// APIError is a non-2xx answer from a node.
+type APIError struct {
+	Node       string // base URL of the node that answered
+	StatusCode int
+	Outcome    string // X-Bid-Outcome on /bid answers, e.g. "outbid"
+	Message    string
+	RetryAfter time.Duration // from Retry-After, 0 if absent
+}
+
+func (e *APIError) Error() string {
+	code := strconv.Itoa(e.StatusCode)
+	if e.Outcome != "" {
+		code += " " + e.Outcome
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Node, code, e.Message)
+}
+
+// Is lets errors.Is match an APIError by status class.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrUnavailable:
+		return e.StatusCode == http.StatusServiceUnavailable
+	}
+	return false
+}
+
+// apiError builds the error for resp, whose body has been read.
+func apiError(node string, resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		Node:       node,
+		StatusCode: resp.StatusCode,
+		Outcome:    resp.Header.Get("X-Bid-Outcome"),
+		Message:    strings.TrimSpace(string(body)),
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
+
+func protocolError(node, what string, err error) error {
+	return fmt.Errorf("%s: %w: %s: %v", node, ErrProtocol, what, err)
+}
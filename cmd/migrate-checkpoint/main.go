@@ -0,0 +1,76 @@
+// migrate-checkpoint upgrades checkpoint files to the schema version of this
+// build, so operators can pre-migrate before a rolling upgrade. Each file is
+// backed up next to itself as <file>.v<old>.bak before being rewritten.
+//
+//	go run ./cmd/migrate-checkpoint checkpoints/*.json
+package main
+
+import (
+	"auction_node/node"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Report what would change without writing anything")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: migrate-checkpoint [--dry-run] FILE...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		if err := migrateFile(path, *dryRun); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func migrateFile(path string, dryRun bool) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, version, err := node.MigrateCheckpoint(b)
+	if err != nil {
+		return err
+	}
+	if version == node.CheckpointSchemaVersion {
+		fmt.Printf("%s: already at schema v%d\n", path, version)
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("%s: would migrate v%d → v%d\n", path, version, node.CheckpointSchemaVersion)
+		return nil
+	}
+
+	out, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if err := os.WriteFile(backup, b, 0o644); err != nil {
+		return fmt.Errorf("write backup: %w", err)
+	}
+	// Write then rename, as the node does, so a crash never leaves half a file.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0o644); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+	fmt.Printf("%s: migrated v%d → v%d (backup %s)\n", path, version, node.CheckpointSchemaVersion, backup)
+	return nil
+}
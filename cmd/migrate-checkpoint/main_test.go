@@ -0,0 +1,53 @@
+package main
+
+import (
+	"auction_node/node"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateFile(t *testing.T) {
+	v1, err := os.ReadFile(filepath.Join("..", "..", "node", "testdata", "checkpoint_v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "checkpoint_N1.json")
+	if err := os.WriteFile(path, v1, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateFile(path, true); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); !bytes.Equal(b, v1) {
+		t.Fatal("dry run rewrote the file")
+	}
+
+	if err := migrateFile(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if backup, err := os.ReadFile(path + ".v1.bak"); err != nil || !bytes.Equal(backup, v1) {
+		t.Fatalf("backup: %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, version, err := node.MigrateCheckpoint(b)
+	if err != nil || version != node.CheckpointSchemaVersion {
+		t.Fatalf("rewritten file: version %d, %v", version, err)
+	}
+	if cp.CurrentWinnerID == "" {
+		t.Error("rewritten file has no current winner ID")
+	}
+
+	// A second run finds nothing to do.
+	if err := migrateFile(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".v2.bak"); !os.IsNotExist(err) {
+		t.Errorf("second run wrote a backup: %v", err)
+	}
+}
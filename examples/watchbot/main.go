@@ -0,0 +1,194 @@
+// watchbot follows an auction and logs what happens: lots opening, new
+// leading bids, lots selling, and, given an admin token, alerts as they
+// fire. It keeps going through leader failovers and node restarts by
+// moving between the nodes it was given.
+//
+//	go run ./examples/watchbot --nodes https://10.0.0.5:8001,https://10.0.0.6:8002 \
+//	    --ca ca.pem --token-file /run/secrets/admin-token
+//
+// With --token-file the token is read again whenever a node answers 401,
+// so the bot survives an --admin-token rotation without a restart.
+package main
+
+import (
+	"auction_node/client"
+	"auction_node/node"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+func main() {
+	nodes := flag.String("nodes", "localhost:8001", "Comma-separated node URLs")
+	adminNodes := flag.String("admin-nodes", "", "Comma-separated --admin-port URLs, if the nodes have them")
+	caFile := flag.String("ca", "", "PEM CA bundle the nodes' certificates chain to")
+	certFile := flag.String("cert", "", "Client certificate, for nodes run with --require-client-cert")
+	keyFile := flag.String("key", "", "Private key for --cert")
+	token := flag.String("token", "", "Admin token; enables alert logging")
+	tokenFile := flag.String("token-file", "", "File holding the admin token, re-read on 401")
+	interval := flag.Duration("interval", time.Second, "Poll interval")
+	flag.Parse()
+
+	if *tokenFile != "" {
+		t, err := readToken(*tokenFile)
+		if err != nil {
+			log.Fatalf("Token file: %v", err)
+		}
+		*token = t
+	}
+	c, err := client.New(client.Options{
+		Nodes:      splitList(*nodes),
+		AdminNodes: splitList(*adminNodes),
+		CAFile:     *caFile,
+		CertFile:   *certFile,
+		KeyFile:    *keyFile,
+		Token:      *token,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	w := &watcher{c: c, tokenFile: *tokenFile, alerts: *token != "", seenAlerts: map[string]bool{}}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		w.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type watcher struct {
+	c          *client.Client
+	tokenFile  string
+	alerts     bool
+	refused    bool // the token file's token was refused on the last poll
+	seenAlerts map[string]bool
+
+	last      *node.StateV1
+	unhealthy bool
+}
+
+func (w *watcher) poll(ctx context.Context) {
+	state, err := w.c.State(ctx)
+	if err != nil {
+		if ctx.Err() == nil && !w.unhealthy {
+			log.Printf("⚠️  No node answered: %v", err)
+			w.unhealthy = true
+		}
+		return
+	}
+	if w.unhealthy {
+		log.Printf("✅ Cluster reachable again")
+		w.unhealthy = false
+	}
+	w.logChanges(state)
+	w.last = state
+	if w.alerts {
+		w.pollAlerts(ctx)
+	}
+}
+
+func (w *watcher) logChanges(s *node.StateV1) {
+	prev := w.last
+	if prev == nil {
+		prev = &node.StateV1{}
+	}
+	if s.Phase != prev.Phase {
+		log.Printf("Phase: %s", s.Phase)
+	}
+	if s.CurrentItem != nil && (prev.CurrentItem == nil || prev.CurrentItem.ID != s.CurrentItem.ID) {
+		log.Printf("🔨 Lot %s open: %s %s from $%d, %d left in the queue",
+			s.CurrentItem.ID, s.CurrentItem.Emoji, s.CurrentItem.Name, s.CurrentItem.StartingPrice, s.QueueLen)
+	}
+	if s.CurrentWinner != "" && (s.CurrentHighestBid != prev.CurrentHighestBid || s.CurrentWinner != prev.CurrentWinner) {
+		log.Printf("💰 %s leads at $%d (next bid at least $%d)", s.CurrentWinner, s.CurrentHighestBid, s.MinNextBid)
+	}
+	closed := (s.ResultsTrimmed + len(s.Results)) - (prev.ResultsTrimmed + len(prev.Results))
+	if closed > 0 && w.last != nil {
+		for _, r := range s.Results[len(s.Results)-min(closed, len(s.Results)):] {
+			if r.WinningBid > 0 {
+				log.Printf("🏁 %s sold to %s for $%d", r.Item.Name, r.Winner, r.WinningBid)
+			} else {
+				log.Printf("🏁 %s closed without bids", r.Item.Name)
+			}
+		}
+	}
+}
+
+func (w *watcher) pollAlerts(ctx context.Context) {
+	var alerts struct {
+		Active []node.Alert `json:"active"`
+		Recent []node.Alert `json:"recent"`
+	}
+	err := w.c.AdminJSON(ctx, "/alerts", &alerts)
+	if errors.Is(err, client.ErrUnauthorized) && w.tokenFile != "" {
+		// The token was probably rotated; pick up the new one and retry.
+		t, readErr := readToken(w.tokenFile)
+		if readErr != nil {
+			log.Printf("⚠️  Token file: %v", readErr)
+			return
+		}
+		w.c.SetToken(t)
+		err = w.c.AdminJSON(ctx, "/alerts", &alerts)
+	}
+	switch {
+	case errors.Is(err, client.ErrUnauthorized) && w.tokenFile != "":
+		if !w.refused {
+			log.Printf("⚠️  Token refused, retrying with the token file's contents each poll: %v", err)
+			w.refused = true
+		}
+		return
+	case errors.Is(err, client.ErrUnauthorized), errors.Is(err, client.ErrForbidden):
+		log.Printf("⚠️  Alerts off: %v", err)
+		w.alerts = false
+		return
+	case err != nil:
+		return // transient; the state poll reports reachability
+	}
+	if w.refused {
+		log.Printf("✅ Token accepted again")
+		w.refused = false
+	}
+	for _, a := range append(alerts.Active, alerts.Recent...) {
+		key := fmt.Sprintf("%s|%s|%d|%t", a.NodeID, a.Key, a.FiredUnix, a.Resolved)
+		if w.seenAlerts[key] {
+			continue
+		}
+		w.seenAlerts[key] = true
+		state := "fired"
+		if a.Resolved {
+			state = "resolved"
+		}
+		log.Printf("🚨 [%s] %s %s on %s: %s", a.Severity, a.Key, state, a.NodeID, a.Message)
+	}
+}
+
+func readToken(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -6,27 +6,85 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
 func main() {
-	id := flag.String("id", "", "Node ID")
+	id := flag.String("id", "", "Node ID, any non-empty string (e.g. Node1, auction-eu-1)")
+	rankFlag := flag.Int("rank", 0, "Election rank; the highest wins, ties go to the greater ID (default: the number in a NodeN ID)")
 	host := flag.String("host", "0.0.0.0", "Host/IP to bind on (use 0.0.0.0 for LAN)")
 	port := flag.String("port", "", "Port to listen on")
+	tlsCert := flag.String("tls-cert", "", "PEM certificate for cluster RPC over TLS (needs --tls-key)")
+	tlsKey := flag.String("tls-key", "", "PEM private key for --tls-cert")
+	tlsCA := flag.String("tls-ca", "", "PEM CA bundle that peer certificates must chain to (default: system roots)")
+	requireClientCert := flag.Bool("require-client-cert", false, "Mutual TLS: refuse RPC connections without a certificate signed by --tls-ca")
+	clusterSecret := flag.String("cluster-secret", "", "Shared secret that signs every cluster RPC; all members must use the same one (default: unsigned)")
+	rpcPort := flag.String("rpc-port", "", "Serve cluster RPC on this port instead of --port; --peers then lists peers' RPC ports (default: share --port)")
 	peersList := flag.String("peers", "", "Comma separated list of peer addresses (e.g. localhost:8081,localhost:8082)")
+	joinList := flag.String("join", "", "Comma separated list of existing members to bootstrap membership and state from")
+	advertise := flag.String("advertise", "", "Address other nodes should use to reach this node (default: host:port, localhost for 0.0.0.0)")
 	launchMode := flag.String("launch", "", "Launch mode: 'local' (4 nodes + monitor) or 'lan' (current node in terminal)")
 	logToFile := flag.Bool("log-to-file", false, "Redirect logs to node<ID>.log instead of stdout")
 	isMonitor := flag.Bool("monitor", false, "Run as an auction monitor dashboard")
 	isLogViewer := flag.Bool("log-viewer", false, "Run as a combined log viewer (tail -f node*.log)")
+	strictVersioning := flag.Bool("strict-versioning", false, "Refuse bids and admin writes while cluster members run incompatible protocol versions")
+	itemsURL := flag.String("items-url", "", "On first boot without a checkpoint, the coordinator seeds the queue from this JSON catalogue (same format as /items/batch)")
+	itemsSHA := flag.String("items-sha256", "", "Expected hex SHA-256 of the --items-url catalogue; a mismatch falls back to the cache or defaults")
+	emojiMap := flag.String("emoji-map", "", "JSON file of keyword→emoji/category rules used for items added without an emoji")
+	retainResults := flag.Int("retain-results", 0, "Keep only the newest N results in live state; older ones move to archive/ (0 = keep all)")
+	retainAudit := flag.Int("retain-audit", 0, "Keep only the newest N transaction log entries; older ones move to archive/ (0 = keep all)")
+	checkpointHistory := flag.Int("checkpoint-history", node.DefaultCheckpointHistory, "Number of committed checkpoints to keep for /admin/state-at and /admin/state-diff (0 = none)")
+	antiSnipe := flag.Int("anti-snipe", -1, "Seconds before the deadline in which a bid extends it (default 15; changeable live via /admin/config)")
+	alertWebhook := flag.String("alert-webhook", "", "URL to POST JSON alerts to (quorum loss, election churn, checkpoint failures, ...)")
+	scenario := flag.String("scenario", "", "Run a scripted scenario file against a fresh local cluster and report pass/fail")
+	canaryInterval := flag.Duration("canary-interval", 0, "Run a synthetic canary bid round this often while coordinator, e.g. 1m (0 = off); repeated failures mark /healthz degraded")
+	autoProfile := flag.Duration("auto-profile-threshold", 0, "Capture a goroutine dump and CPU profile when a coordinated bid runs longer than this, e.g. 750ms (0 = off)")
+	incidentRetention := flag.Duration("incident-retention", node.DefaultIncidentRetention, "How long resolved incidents stay in /incidents and the checkpoint, e.g. 72h")
+	strictConsistency := flag.Bool("strict-consistency", false, "Quarantine this node instead of applying a snapshot or decision that moves its state backwards; an operator must rebuild or resume it")
+	heartbeatInterval := flag.Duration("heartbeat-interval", node.DefaultHeartbeatInterval, "How often the coordinator sends heartbeats")
+	leaderTimeout := flag.Duration("leader-timeout", node.DefaultLeaderTimeout, "Start an election after this long without a heartbeat; must be more than twice --heartbeat-interval")
+	electionWait := flag.Duration("election-wait", node.DefaultElectionWait, "How long a candidate waits for a higher-ranked node to answer before claiming leadership; must be shorter than --leader-timeout")
+	syncInterval := flag.Duration("sync-interval", node.DefaultSyncInterval, "How often a follower pulls state from the coordinator when a heartbeat reports a change, e.g. 500ms for a display board")
+	majorityLossWindow := flag.Duration("majority-loss-window", node.DefaultMajorityLossWindow, "A coordinator that cannot reach a majority for this long steps down and re-syncs as a follower (0 = never)")
+	failoverGrace := flag.Duration("failover-grace", node.DefaultFailoverGrace, "Re-open a lot whose deadline passed while the cluster had no leader for this long")
+	mutex := flag.String("mutex", node.MutexRA, "Mutual exclusion algorithm: ra (a reply from every peer), quorum (votes from a majority) or token (a token passed round the ring); every node must use the same")
+	tokenTimeout := flag.Duration("token-timeout", node.DefaultTokenTimeout, "With --mutex=token, recreate the token when it has not been seen for this long")
+	serialize := flag.String("serialize", node.SerializeRA, "How the coordinator serializes bids and queue changes: ra (the cluster mutex, see --mutex) or leader-lock (a local lock, falling back to the cluster mutex when a second coordinator could exist)")
+	raAging := flag.Duration("ra-aging", 0, "Move a Ricart-Agrawala request ahead by one tick per this much accumulated waiting on earlier requests, to bound starvation (0 = off)")
+	raTimeout := flag.Duration("ra-timeout", node.DefaultRARequestTimeout, "Give up a Ricart-Agrawala request when a peer has not answered for this long; the bid fails with a retryable error (0 = wait forever)")
+	deadLetters := flag.Int("dead-letters", node.DefaultDeadLetters, "Number of refused peer messages kept for GET /admin/deadletters (0 = none)")
+	lateVoteGrace := flag.Duration("late-vote-grace", node.DefaultLateVoteGrace, "After a bid is decided, keep collecting Prepare votes this long and resend the decision to late YES voters (0 = stop at once)")
+	noGrace := flag.Bool("no-grace", false, "Close a lot that expired during a failover at once, with the bids committed before its deadline")
+	winnerDisplay := flag.String("winner-display", string(node.WinnerDisplayFull), "How /state and the UI name bidders: full, initials or paddle-number (admin views keep full names)")
+	faultInjection := flag.Bool("enable-fault-injection", false, "Serve /admin/latency to add latency to outbound RPC at runtime (demos only)")
+	adminToken := flag.String("admin-token", "", "Token required by /admin/profiles (Authorization: Bearer <token>)")
+	adminPort := flag.String("admin-port", "", "Serve /admin/*, /metrics, /checkpoint, /rpcstats and the other operator endpoints only on this port; needs --admin-token")
+	adminHost := flag.String("admin-host", "127.0.0.1", "Interface the --admin-port listener binds to")
+	showVersion := flag.Bool("version", false, "Print build version and exit")
 	flag.Parse()
 
+	if *showVersion {
+		fmt.Printf("auction_node %s (commit %s, built %s, protocol %d)\n",
+			node.Version, node.Commit, node.BuildDate, node.ProtocolVersion)
+		return
+	}
+
 	if *isMonitor {
 		node.RunMonitor()
 		return
 	}
 
+	if *scenario != "" {
+		if !node.RunScenario(*scenario) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *isLogViewer {
 		runLogViewer()
 		return
@@ -38,7 +96,8 @@ func main() {
 	}
 
 	if *id == "" || *port == "" {
-		fmt.Println("Usage: main --id <node_id> --port <port> --peers <peer_addresses>")
+		fmt.Println("Usage: main --id <node_id> [--rank <rank>] --port <port> --peers <peer_addresses>")
+		fmt.Println("       main --id <node_id> --port <port> --join <any_member_address>")
 		fmt.Println("       main --launch local")
 		fmt.Println("       main --launch lan --id Node1")
 		os.Exit(1)
@@ -58,23 +117,162 @@ func main() {
 	}
 
 	address := fmt.Sprintf("%s:%s", *host, *port)
-
-	// Derive rank from node ID (e.g. Node1 -> 1)
-	rankStr := strings.TrimPrefix(*id, "Node")
-	rank, err := strconv.Atoi(rankStr)
+	httpAddress := ""
+	if *rpcPort != "" && *rpcPort != *port {
+		// The node's cluster identity is its RPC address; the UI moves aside.
+		httpAddress, address = address, fmt.Sprintf("%s:%s", *host, *rpcPort)
+	}
+	adminAddress := ""
+	if *adminPort != "" {
+		if *adminToken == "" {
+			log.Fatalf("--admin-port needs --admin-token: every request on the admin listener must carry it")
+		}
+		adminAddress = fmt.Sprintf("%s:%s", *adminHost, *adminPort)
+	}
+	if err := node.CheckElectionTiming(*heartbeatInterval, *leaderTimeout, *electionWait, *majorityLossWindow); err != nil {
+		log.Fatalf("Invalid election timing: %v", err)
+	}
+	if err := node.CheckLateVoteGrace(*lateVoteGrace); err != nil {
+		log.Fatalf("Invalid --late-vote-grace: %v", err)
+	}
+	mutexKind, err := node.ParseMutexKind(*mutex)
+	if err != nil {
+		log.Fatalf("Invalid --mutex: %v", err)
+	}
+	if *raAging < 0 {
+		log.Fatalf("--ra-aging must be 0 or more, got %s", *raAging)
+	}
+	serializeMode, err := node.ParseSerializeMode(*serialize)
 	if err != nil {
-		fmt.Printf("Error: Node ID must be in format 'Node<number>', got '%s'\n", *id)
+		log.Fatalf("Invalid --serialize: %v", err)
+	}
+	if *tokenTimeout <= 0 {
+		log.Fatalf("--token-timeout must be positive, got %s", *tokenTimeout)
+	}
+	if *raTimeout < 0 {
+		log.Fatalf("--ra-timeout must be 0 or more, got %s", *raTimeout)
+	}
+	if *deadLetters < 0 {
+		log.Fatalf("--dead-letters must be 0 or more, got %d", *deadLetters)
+	}
+	display, err := node.ParseWinnerDisplay(*winnerDisplay)
+	if err != nil {
+		log.Fatalf("Invalid --winner-display: %v", err)
+	}
+
+	if strings.TrimSpace(*id) == "" {
+		fmt.Println("Error: --id is required (e.g. Node1 or auction-eu-1)")
 		os.Exit(1)
 	}
+	rank := *rankFlag
+	if rank == 0 {
+		// Without --rank, a NodeN ID keeps its old rank N.
+		parsed, err := strconv.Atoi(strings.TrimPrefix(*id, "Node"))
+		if err != nil || !strings.HasPrefix(*id, "Node") {
+			fmt.Printf("Error: --rank is required for node ID '%s' (only NodeN IDs imply a rank)\n", *id)
+			os.Exit(1)
+		}
+		rank = parsed
+	}
+
+	if *emojiMap != "" {
+		if err := node.LoadEmojiRules(*emojiMap); err != nil {
+			log.Fatalf("Could not load emoji map: %v", err)
+		}
+	}
+
+	releaseLock, err := node.AcquireNodeLock(*id, address)
+	if err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+	defer releaseLock()
 
 	n := node.NewNode(*id, address, peers, rank)
+	n.AdvertiseAddress = *advertise
+	n.HTTPAddress = httpAddress
+	n.AdminAddress = adminAddress
+	if *clusterSecret != "" {
+		n.UseClusterSecret(*clusterSecret)
+	}
+	if *tlsCert != "" || *tlsKey != "" || *requireClientCert {
+		clusterTLS, err := node.LoadClusterTLS(*tlsCert, *tlsKey, *tlsCA, *requireClientCert)
+		if err != nil {
+			log.Fatalf("TLS setup failed: %v", err)
+		}
+		n.UseTLS(clusterTLS)
+	}
+	n.CheckpointHistory = *checkpointHistory
+	n.CanaryInterval = *canaryInterval
+	n.AutoProfileThreshold = *autoProfile
+	n.AdminToken = *adminToken
+	n.FaultInjection = *faultInjection
+	n.StrictConsistency = *strictConsistency
+	n.HeartbeatInterval = *heartbeatInterval
+	n.LeaderTimeout = *leaderTimeout
+	n.ElectionWait = *electionWait
+	n.MajorityLossWindow = *majorityLossWindow
+	n.FailoverGrace = *failoverGrace
+	n.WinnerDisplay = display
+	n.LateVoteGrace = *lateVoteGrace
+	n.RA.RequestTimeout = *raTimeout
+	n.RA.AgingThreshold = *raAging
+	n.TokenTimeout = *tokenTimeout
+	n.SetMutex(mutexKind)
+	n.SetSerializeMode(serializeMode)
+	if *noGrace {
+		n.FailoverGrace = 0
+	}
+	n.SetIncidentRetention(*incidentRetention)
+	n.SetDeadLetters(*deadLetters)
+	n.ItemsURL = *itemsURL
+	n.ItemsSHA256 = *itemsSHA
+	cfg := node.DefaultRuntimeConfig()
+	cfg.StrictVersioning = *strictVersioning
+	cfg.RetainResults = *retainResults
+	cfg.RetainAudit = *retainAudit
+	cfg.AlertWebhook = *alertWebhook
+	if *antiSnipe >= 0 {
+		cfg.AntiSnipeSec = *antiSnipe
+	}
+	cfg.SyncIntervalMs = int(*syncInterval / time.Millisecond)
+	flagFields := map[string]string{
+		"strict-versioning": "strictVersioning", "retain-results": "retainResults",
+		"retain-audit": "retainAudit", "alert-webhook": "alertWebhook", "anti-snipe": "antiSnipeSec",
+		"sync-interval": "syncIntervalMs",
+	}
+	var fromFlags []string
+	flag.Visit(func(f *flag.Flag) {
+		if field, ok := flagFields[f.Name]; ok {
+			fromFlags = append(fromFlags, field)
+		}
+	})
+	if err := n.SetBaseConfig(cfg, fromFlags); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 	n.Start()
 
+	if *joinList != "" || (len(peers) == 0 && n.HasPeers()) {
+		seeds := []string{}
+		if *joinList != "" {
+			seeds = strings.Split(*joinList, ",")
+		}
+		// With no --join, this refreshes membership from peers remembered in the checkpoint.
+		if err := n.JoinCluster(seeds); err != nil {
+			if *joinList != "" && !n.HasPeers() {
+				log.Fatalf("Could not join cluster: %v", err)
+			}
+			log.Printf("Warning: cluster bootstrap skipped: %v", err)
+		}
+	}
+
 	// Start bully leader monitoring
 	go n.MonitorLeader()
 
-	// Block forever
-	select {}
+	// Run until interrupted, then drain and checkpoint before exiting.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	<-sigs
+	n.Stop()
 }
 
 func spawnTerminals(mode, nodeID string) {
@@ -0,0 +1,279 @@
+package node
+
+// access.go — Invite-only lots: per-item bidder allow-lists.
+//
+// An item with AllowedBidders only takes bids from those bidder IDs. The
+// list travels with the item through snapshots, prepares and checkpoints, so
+// every participant enforces the same list, but public responses (/state,
+// /checkpoint) show only Restricted. GET /me tells a browser whether its own
+// bidder ID may bid on the current lot.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// allows reports whether bidderID may bid on the item.
+func (it *AuctionItem) allows(bidderID string) bool {
+	if len(it.AllowedBidders) == 0 {
+		return true
+	}
+	for _, id := range it.AllowedBidders {
+		if id == bidderID {
+			return true
+		}
+	}
+	return false
+}
+
+// setAllowedBidders installs a cleaned allow-list; an empty list opens the lot.
+func (it *AuctionItem) setAllowedBidders(ids []string) {
+	seen := map[string]bool{}
+	var clean []string
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id != "" && !seen[id] {
+			seen[id] = true
+			clean = append(clean, id)
+		}
+	}
+	it.AllowedBidders = clean
+	it.Restricted = len(clean) > 0
+}
+
+// parseBidderList splits a comma-separated form value.
+func parseBidderList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// publicItems returns items with allow-lists removed, copying only if needed.
+func publicItems(items []AuctionItem) []AuctionItem {
+	for i := range items {
+		if items[i].AllowedBidders != nil {
+			out := append([]AuctionItem(nil), items...)
+			for j := range out {
+				out[j].AllowedBidders = nil
+			}
+			return out
+		}
+	}
+	return items
+}
+
+// publicResults is publicItems for results.
+func publicResults(results []ItemResult) []ItemResult {
+	for i := range results {
+		if results[i].Item.AllowedBidders != nil {
+			out := append([]ItemResult(nil), results...)
+			for j := range out {
+				out[j].Item.AllowedBidders = nil
+			}
+			return out
+		}
+	}
+	return results
+}
+
+// publicSnapshot strips allow-lists, budgets and paddles from a snapshot
+// before it is served to browsers. The snapshot's slices are shared, so
+// nothing is modified in place.
+func publicSnapshot(snap QueueSnapshot) QueueSnapshot {
+	snap.Budgets = nil
+	snap.Paddles = nil
+	if snap.CurrentItem != nil && snap.CurrentItem.AllowedBidders != nil {
+		item := *snap.CurrentItem
+		item.AllowedBidders = nil
+		snap.CurrentItem = &item
+	}
+	snap.RemainingItems = publicItems(snap.RemainingItems)
+	snap.Results = publicResults(snap.Results)
+	if ann := snap.Announcement; ann != nil && ann.Result.Item.AllowedBidders != nil {
+		copied := *ann
+		copied.Result.Item.AllowedBidders = nil
+		snap.Announcement = &copied
+	}
+	return snap
+}
+
+// publicCheckpoint is publicSnapshot for checkpoint files.
+func publicCheckpoint(cp CheckpointData) CheckpointData {
+	cp.Budgets = nil
+	cp.Paddles = nil
+	cp.BidBook = nil
+	cp.Incidents = nil // served by /incidents
+	if cp.CurrentItem != nil && cp.CurrentItem.AllowedBidders != nil {
+		item := *cp.CurrentItem
+		item.AllowedBidders = nil
+		cp.CurrentItem = &item
+	}
+	cp.RemainingQueue = publicItems(cp.RemainingQueue)
+	cp.Results = publicResults(cp.Results)
+	if ann := cp.Announcement; ann != nil && ann.Result.Item.AllowedBidders != nil {
+		copied := *ann
+		copied.Result.Item.AllowedBidders = nil
+		cp.Announcement = &copied
+	}
+	return cp
+}
+
+// ItemAccessArgs replaces the allow-list of a current or queued item.
+type ItemAccessArgs struct {
+	ItemID         string
+	AllowedBidders []string // empty opens the item to everyone
+}
+
+// setItemAccessAndBroadcast changes an item's allow-list. Coordinator only;
+// followers forward via SubmitItemAccessToCoordinator.
+func (n *Node) setItemAccessAndBroadcast(args ItemAccessArgs) (bool, string) {
+	if args.ItemID == "" {
+		return false, "itemId is required"
+	}
+	if msg := n.versionWriteBlock(); msg != "" {
+		return false, msg
+	}
+
+	release, err := n.enterCS()
+	if err != nil {
+		return false, err.Error()
+	}
+	defer release()
+
+	n.Queue.mu.Lock()
+	var target *AuctionItem
+	if n.Queue.CurrentItem != nil && n.Queue.CurrentItem.ID == args.ItemID {
+		// Replace rather than mutate: snapshots may share the old item.
+		item := *n.Queue.CurrentItem
+		n.Queue.CurrentItem = &item
+		target = &item
+	} else {
+		for i := range n.Queue.Queue {
+			if n.Queue.Queue[i].ID == args.ItemID {
+				n.Queue.Queue = append([]AuctionItem(nil), n.Queue.Queue...)
+				target = &n.Queue.Queue[i]
+				break
+			}
+		}
+	}
+	if target == nil {
+		n.Queue.mu.Unlock()
+		return false, fmt.Sprintf("Item %s is not current or queued", args.ItemID)
+	}
+	target.setAllowedBidders(args.AllowedBidders)
+	count, name := len(target.AllowedBidders), target.Name
+	n.Queue.touchLocked()
+	n.Queue.mu.Unlock()
+
+	if count == 0 {
+		log.Printf("[%s] 🔓 %s (%s) is open to all bidders\n", n.ID, name, args.ItemID)
+	} else {
+		log.Printf("[%s] 🔒 %s (%s) restricted to %d bidder(s)\n", n.ID, name, args.ItemID, count)
+	}
+	n.broadcastQueueState()
+	go n.initiateGlobalCheckpoint()
+	if count == 0 {
+		return true, fmt.Sprintf("%s is open to all bidders", name)
+	}
+	return true, fmt.Sprintf("%s is restricted to %d bidder(s)", name, count)
+}
+
+// SubmitItemAccessToCoordinator forwards POST /admin/item/access to the leader.
+func (rp *NodeRPC) SubmitItemAccessToCoordinator(args ItemAccessArgs, reply *CoordinatorActionReply) error {
+	if _, isLocal := rp.node.getCoordinatorAddress(); !isLocal {
+		reply.Accepted = false
+		reply.Message = "This node is not the coordinator"
+		return nil
+	}
+	reply.Accepted, reply.Message = rp.node.setItemAccessAndBroadcast(args)
+	return nil
+}
+
+// handleItemAccessRequest serves POST /admin/item/access, with a JSON body
+// {"itemId": "...", "allowedBidders": [...]} or form fields itemId and
+// allowedBidders (comma-separated).
+func (n *Node) handleItemAccessRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var args ItemAccessArgs
+	if strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "application/json") {
+		var req struct {
+			ItemID         string   `json:"itemId"`
+			AllowedBidders []string `json:"allowedBidders"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+		args = ItemAccessArgs{ItemID: req.ItemID, AllowedBidders: req.AllowedBidders}
+	} else {
+		args = ItemAccessArgs{ItemID: r.FormValue("itemId"), AllowedBidders: parseBidderList(r.FormValue("allowedBidders"))}
+	}
+
+	var status int
+	var message string
+	if !n.holdForClient(r, "admin_item_access", func() { status, message = n.submitItemAccess(args) }) {
+		return
+	}
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(message))
+}
+
+// submitItemAccess changes an allow-list on the coordinator, forwarding if needed.
+func (n *Node) submitItemAccess(args ItemAccessArgs) (int, string) {
+	var reply CoordinatorActionReply
+	coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
+	if isLocalCoordinator {
+		reply.Accepted, reply.Message = n.setItemAccessAndBroadcast(args)
+	} else {
+		if coordinatorAddress == "" {
+			return http.StatusServiceUnavailable, "Election in progress, please wait"
+		}
+		if err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitItemAccessToCoordinator", args, &reply); err != nil {
+			return http.StatusServiceUnavailable, "Leader unavailable; retry shortly"
+		}
+	}
+	if !reply.Accepted {
+		return http.StatusBadRequest, reply.Message
+	}
+	return http.StatusOK, reply.Message
+}
+
+// MeStatus is the body of GET /me.
+type MeStatus struct {
+	BidderID      string        `json:"bidderId"` // "" until the browser has bid once
+	CurrentItemID string        `json:"currentItemId,omitempty"`
+	Restricted    bool          `json:"restricted"`
+	Eligible      bool          `json:"eligible"`         // may bid on the current item
+	Budget        *BudgetStatus `json:"budget,omitempty"` // see budget.go
+}
+
+// handleMeRequest serves GET /me. It reads the bidder_id cookie but never
+// issues one.
+func (n *Node) handleMeRequest(w http.ResponseWriter, r *http.Request) {
+	me := MeStatus{Eligible: true}
+	if c, err := r.Cookie(bidderIDCookie); err == nil {
+		me.BidderID = c.Value
+	}
+	n.Queue.mu.Lock()
+	if item := n.Queue.CurrentItem; item != nil {
+		me.CurrentItemID = item.ID
+		me.Restricted = item.Restricted
+		me.Eligible = item.allows(me.BidderID)
+	}
+	if me.BidderID != "" {
+		me.Budget = n.Queue.budgetStatusLocked(me.BidderID)
+	}
+	n.Queue.mu.Unlock()
+	writeJSON(w, me)
+}
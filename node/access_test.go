@@ -0,0 +1,159 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setAccess posts an allow-list for lot1 to n.
+func setAccess(t *testing.T, n *Node, ids ...string) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"itemId": "lot1", "allowedBidders": ids})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/admin/item/access", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	n.handleItemAccessRequest(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("allow-list %v: %d %s", ids, rec.Code, rec.Body)
+	}
+}
+
+func allowedOn(n *Node) []string {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	return n.Queue.CurrentItem.AllowedBidders
+}
+
+func meOn(n *Node, bidderID string) MeStatus {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if bidderID != "" {
+		req.AddCookie(&http.Cookie{Name: bidderIDCookie, Value: bidderID})
+	}
+	n.handleMeRequest(rec, req)
+	var me MeStatus
+	_ = json.Unmarshal(rec.Body.Bytes(), &me)
+	return me
+}
+
+func TestInviteOnlyLot(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a := nodes[0]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	bid := func(bidder string, amount int) BidCode {
+		return nodes[1].submitBid(t.Context(), BidArgs{BidderID: bidder, DisplayName: bidder, Amount: amount, ItemID: "lot1"}).Code
+	}
+	// agree checks that every node gives bidder the same answer.
+	agree := func(when, bidder string, amount int, want bool) {
+		t.Helper()
+		for _, tn := range nodes {
+			if ok, reason := tn.canPrepareBid(BidArgs{BidderID: bidder, Amount: amount, ItemID: "lot1"}); ok != want {
+				t.Errorf("%s: %s may bid $%d on %s: %v %s, want %v", when, bidder, amount, tn.ID, ok, reason, want)
+			}
+		}
+	}
+	listed := func(want ...string) {
+		t.Helper()
+		for _, tn := range nodes {
+			waitFor(t, tn.ID+" to hold the allow-list", func() bool {
+				got := allowedOn(tn.Node)
+				return reflect.DeepEqual(got, want) || len(got) == 0 && len(want) == 0
+			})
+		}
+	}
+
+	// Set through a follower; the list is cleaned and reaches every node.
+	setAccess(t, nodes[1].Node, "b1", " b2 ", "b1", "")
+	listed("b1", "b2")
+	if got := bid("b3", 50); got != BidNotAllowed {
+		t.Errorf("uninvited bid = %s, want %s", got, BidNotAllowed)
+	}
+	agree("restricted", "b3", 50, false)
+	if got := bid("b1", 50); got != BidCommitted {
+		t.Fatalf("invited bid = %s", got)
+	}
+	for _, tn := range nodes {
+		waitFor(t, tn.ID+" to apply the bid", func() bool { return highestBid(tn.Node) == 50 })
+	}
+	agree("restricted", "b2", 60, true)
+
+	// b2 is struck off mid-item.
+	setAccess(t, a.Node, "b1")
+	listed("b1")
+	agree("after b2 was removed", "b2", 60, false)
+	if got := bid("b2", 60); got != BidNotAllowed {
+		t.Errorf("removed bidder's bid = %s, want %s", got, BidNotAllowed)
+	}
+
+	// Opening the lot lets anyone in.
+	setAccess(t, nodes[2].Node)
+	listed()
+	agree("opened", "b3", 60, true)
+	if got := bid("b3", 60); got != BidCommitted {
+		t.Errorf("bid on the opened lot = %s", got)
+	}
+	a.Queue.mu.Lock()
+	restricted := a.Queue.CurrentItem.Restricted
+	a.Queue.mu.Unlock()
+	if restricted {
+		t.Error("opened lot is still marked restricted")
+	}
+}
+
+func TestAllowListStaysPrivate(t *testing.T) {
+	n := biddingNode(t)
+	setLeader(n, n.ID, n.Address)
+	leading(t, n)
+	setAccess(t, n, "vip-7", "vip-9")
+	n.Queue.mu.Lock()
+	n.Queue.Queue = []AuctionItem{{ID: "lot2", Name: "Car", AllowedBidders: []string{"vip-8"}, Restricted: true}}
+	n.Queue.touchLocked()
+	n.Queue.mu.Unlock()
+
+	state, err := n.cachedStateJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(state), "vip-") || !strings.Contains(string(state), `"Restricted":true`) {
+		t.Errorf("/state shows the allow-list or hides the flag:\n%s", state)
+	}
+
+	// The checkpoint keeps the list for a restart, but /checkpoint does not show it.
+	if err := n.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	n.handleCheckpointRequest(rec, httptest.NewRequest(http.MethodGet, "/checkpoint", nil))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "vip-") {
+		t.Errorf("/checkpoint: %d %s", rec.Code, rec.Body)
+	}
+	restored := NewNode(n.ID, n.Address, nil, 1)
+	if got := allowedOn(restored); !reflect.DeepEqual(got, []string{"vip-7", "vip-9"}) {
+		t.Errorf("restored allow-list = %v", got)
+	}
+	restored.Queue.mu.Lock()
+	queued := restored.Queue.Queue[0].AllowedBidders
+	restored.Queue.mu.Unlock()
+	if !reflect.DeepEqual(queued, []string{"vip-8"}) {
+		t.Errorf("restored queued allow-list = %v", queued)
+	}
+
+	// /me tells each viewer only whether they may bid.
+	cases := []struct {
+		bidder   string
+		eligible bool
+	}{{"vip-7", true}, {"vip-8", false}, {"", false}}
+	for _, c := range cases {
+		if me := meOn(n, c.bidder); !me.Restricted || me.Eligible != c.eligible || me.CurrentItemID != "lot1" {
+			t.Errorf("/me for %q = %+v, want restricted and eligible=%v", c.bidder, me, c.eligible)
+		}
+	}
+}
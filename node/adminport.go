@@ -0,0 +1,178 @@
+package node
+
+// adminport.go — Optional internal listener for operator endpoints.
+//
+// By default one listener serves everything: the UI, bids, the read API and
+// the operator endpoints. With --admin-port the operator endpoints (/admin/*,
+// /items/batch, /metrics, /checkpoint, /api/v1/checkpoint, /rpcstats, /bidstats, /peers,
+// /version, /alerts, /topology, /incidents, /election-log) move to a listener of their own,
+// bound to --admin-host (127.0.0.1 by default), and the public port answers
+// them with 404 instead of falling through to the UI.
+//
+// The two listeners wrap their handlers differently. The public one keeps
+// the per-client read limit and the load-shedding gate. The admin one has
+// neither, so an operator is never throttled by bidder traffic, but every
+// request on it must carry --admin-token (Bearer header or ?token=). A good
+// ?token= also sets a cookie, so the UI served there can call the admin
+// endpoints without putting the token in every URL. The admin listener also
+// serves the UI and the read API, so it is a complete console on its own.
+
+import (
+	"crypto/subtle"
+	"log"
+	"net"
+	"net/http"
+)
+
+const adminTokenCookie = "admin_token"
+
+// routeFunc registers one handler; *http.ServeMux.HandleFunc fits.
+type routeFunc func(pattern string, handler func(http.ResponseWriter, *http.Request))
+
+// registerPublicRoutes adds the bidder-facing routes. limit wraps the
+// read-only ones; see limitReads.
+func (n *Node) registerPublicRoutes(handle routeFunc, limit func(http.HandlerFunc) http.HandlerFunc) {
+	handle("/", limit(n.handleUI))
+	handle("/bid", n.handleBidRequest)
+	handle("/state", limit(n.handleStateRequest))
+	handle("/me", limit(n.handleMeRequest))
+	handle("/me/bids", limit(n.handleMyBidsRequest))
+	handle("/api/v1/state", limit(n.handleStateV1Request))
+	handle("/healthz", n.handleHealthRequest)
+	handle("/events/history", limit(n.handleEventsHistoryRequest))
+	handle("/events/history/", limit(n.handleEventsHistoryRequest))
+	handle("/history", limit(n.handleHistoryUI))
+}
+
+// registerAdminRoutes adds the operator routes.
+func (n *Node) registerAdminRoutes(handle routeFunc, limit func(http.HandlerFunc) http.HandlerFunc) {
+	handle("/admin/item", n.handleAddItemRequest)
+	handle("/admin/item/access", n.handleItemAccessRequest)
+	handle("/admin/budget", n.handleBudgetRequest)
+	handle("/admin/budgets", n.handleBudgetRequest)
+	handle("/admin/paddle", n.handlePaddleRequest)
+	handle("/admin/paddles", n.handlePaddleRequest)
+	handle("/items/batch", n.handleBatchAddItemsRequest)
+	handle("/admin/auction", n.handleAuctionControlRequest)
+	handle("/admin/shuffle", n.handleShuffleRequest)
+	handle("/admin/config", n.handleConfigRequest)
+	handle("/checkpoint", limit(n.handleCheckpointRequest))
+	handle("/api/v1/checkpoint", limit(n.handleCheckpointV1Request))
+	handle("/admin/checkpoints", n.handleCheckpointHistoryRequest)
+	handle("/admin/state-at", n.handleStateAtRequest)
+	handle("/admin/state-diff", n.handleStateDiffRequest)
+	handle("/metrics", n.handleMetricsRequest)
+	handle("/version", n.handleVersionRequest)
+	handle("/peers", n.handlePeersRequest)
+	handle("/alerts", limit(n.handleAlertsRequest))
+	handle("/topology", limit(n.handleTopologyRequest))
+	handle("/incidents", limit(n.handleIncidentsRequest))
+	handle("/election-log", limit(n.handleElectionLogRequest))
+	handle("/admin/drain", n.handleDrainRequest)
+	handle("/admin/transfer-leader", n.handleTransferLeaderRequest)
+	handle("/admin/sync-now", n.handleSyncNowRequest)
+	handle("/admin/rebuild", n.handleRebuildRequest)
+	handle("/admin/inflight", n.handleInFlightRequest)
+	handle("/mutex", n.handleMutexRequest)
+	handle("/admin/deadletters", n.handleDeadLettersRequest)
+	handle("/admin/deadletters/", n.handleDeadLettersRequest)
+	handle("/rpcstats", n.handleRPCStatsRequest)
+	handle("/bidstats", limit(n.handleBidStatsRequest))
+	handle("/admin/latency", n.handleLatencyRequest)
+	handle("/admin/templates", n.handleTemplatesRequest)
+	handle("/admin/templates/", n.handleTemplateRequest)
+	handle("/admin/profiles", n.handleProfilesRequest)
+	handle("/admin/events/rename", n.handleEventRenameRequest)
+	handle("/admin/profiles/", n.handleProfilesRequest)
+}
+
+func unlimited(h http.HandlerFunc) http.HandlerFunc { return h }
+
+// notFoundRoutes registers http.NotFound in place of every handler, and
+// for the rest of /admin/, so a split public listener hides the admin
+// routes instead of serving the UI behind "/" for them.
+func notFoundRoutes(mux *http.ServeMux) routeFunc {
+	mux.HandleFunc("/admin/", http.NotFound)
+	return func(pattern string, _ func(http.ResponseWriter, *http.Request)) {
+		mux.HandleFunc(pattern, http.NotFound)
+	}
+}
+
+// requireAdminToken lets through requests carrying --admin-token, or the
+// cookie a previous ?token= request set.
+func (n *Node) requireAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.AdminToken != "" && r.Header.Get("Authorization") == "" && r.URL.Query().Get("token") == "" {
+			if c, err := r.Cookie(adminTokenCookie); err == nil && subtle.ConstantTimeCompare([]byte(c.Value), []byte(n.AdminToken)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if !n.adminAuthorized(w, r) {
+			n.Metrics.Inc("admin_auth_rejected_total")
+			return
+		}
+		if r.URL.Query().Get("token") != "" {
+			http.SetCookie(w, &http.Cookie{
+				Name: adminTokenCookie, Value: n.AdminToken, Path: "/",
+				HttpOnly: true, SameSite: http.SameSiteStrictMode,
+			})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listenAdmin opens the --admin-port listener and serves the full route set
+// on it behind requireAdminToken.
+func (n *Node) listenAdmin() {
+	listener, err := net.Listen("tcp", n.AdminAddress)
+	if err != nil {
+		log.Fatalf("Admin listen error: %v", err)
+	}
+	n.trackListener(listener)
+	handler := n.adminHandler()
+	go func() {
+		if err := http.Serve(listener, handler); err != nil && !n.stopping.Load() {
+			log.Printf("Admin HTTP server error on %s: %v", n.AdminAddress, err)
+		}
+	}()
+	log.Printf("[%s] 🔧 Admin endpoints on http://%s (token required); public port serves bidders only\n", n.ID, n.AdminAddress)
+}
+
+// adminHandler is the --admin-port listener's handler: every route, behind
+// requireAdminToken.
+func (n *Node) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	n.registerPublicRoutes(mux.HandleFunc, unlimited)
+	n.registerAdminRoutes(mux.HandleFunc, unlimited)
+	return n.requireAdminToken(mux)
+}
+
+// registerHTTPRoutes adds the public listener's routes to mux: all of them,
+// or without --admin-port's routes, which answer 404 there.
+func (n *Node) registerHTTPRoutes(mux *http.ServeMux) {
+	n.registerPublicRoutes(mux.HandleFunc, n.limitReads)
+	if n.AdminAddress == "" {
+		n.registerAdminRoutes(mux.HandleFunc, n.limitReads)
+	} else {
+		n.registerAdminRoutes(notFoundRoutes(mux), unlimited)
+	}
+}
+
+// trackListener remembers an HTTP listener for Stop to close.
+func (n *Node) trackListener(l net.Listener) {
+	n.listenersMu.Lock()
+	n.listeners = append(n.listeners, l)
+	n.listenersMu.Unlock()
+}
+
+// closeListeners closes the HTTP listeners opened by Start.
+func (n *Node) closeListeners() {
+	n.stopping.Store(true)
+	n.listenersMu.Lock()
+	defer n.listenersMu.Unlock()
+	for _, l := range n.listeners {
+		_ = l.Close()
+	}
+	n.listeners = nil
+}
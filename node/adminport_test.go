@@ -0,0 +1,78 @@
+package node
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// routesOf lists the patterns register adds.
+func routesOf(register func(routeFunc, func(http.HandlerFunc) http.HandlerFunc)) []string {
+	var patterns []string
+	register(func(pattern string, _ func(http.ResponseWriter, *http.Request)) {
+		patterns = append(patterns, pattern)
+	}, unlimited)
+	return patterns
+}
+
+func TestAdminPortSplitsRoutes(t *testing.T) {
+	t.Chdir(t.TempDir())
+	n := NewNode("T1", "127.0.0.1:9", nil, 1)
+	n.AdminAddress, n.AdminToken = "127.0.0.1:0", "secret"
+	public := http.NewServeMux()
+	n.registerHTTPRoutes(public)
+	admin := http.NewServeMux()
+	n.registerPublicRoutes(admin.HandleFunc, unlimited)
+	n.registerAdminRoutes(admin.HandleFunc, unlimited)
+
+	publicRoutes := routesOf(n.registerPublicRoutes)
+	adminRoutes := routesOf(n.registerAdminRoutes)
+	for _, pattern := range publicRoutes {
+		if pattern == "/api/v1/checkpoint" {
+			t.Error("/api/v1/checkpoint is a public route")
+		}
+		req := httptest.NewRequest(http.MethodGet, pattern, nil)
+		if _, got := public.Handler(req); got != pattern {
+			t.Errorf("public port: %s routed to %q", pattern, got)
+		}
+	}
+	for _, pattern := range append(adminRoutes, "/admin/no-such-route") {
+		rec := httptest.NewRecorder()
+		public.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, pattern, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("public port: %s answered %d, want 404", pattern, rec.Code)
+		}
+	}
+	for _, pattern := range append(adminRoutes, publicRoutes...) {
+		req := httptest.NewRequest(http.MethodGet, pattern, nil)
+		if _, got := admin.Handler(req); got != pattern {
+			t.Errorf("admin port: %s routed to %q", pattern, got)
+		}
+	}
+
+	// The admin listener wants the token on every route.
+	rec := httptest.NewRecorder()
+	n.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code == http.StatusOK {
+		t.Error("admin port served /healthz without the token")
+	}
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	n.adminHandler().ServeHTTP(rec, req)
+	if rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden {
+		t.Errorf("admin port refused the token: %d", rec.Code)
+	}
+}
+
+func TestSinglePortServesEverything(t *testing.T) {
+	t.Chdir(t.TempDir())
+	n := NewNode("T1", "127.0.0.1:9", nil, 1)
+	mux := http.NewServeMux()
+	n.registerHTTPRoutes(mux)
+	for _, pattern := range append(routesOf(n.registerAdminRoutes), routesOf(n.registerPublicRoutes)...) {
+		if _, got := mux.Handler(httptest.NewRequest(http.MethodGet, pattern, nil)); got != pattern {
+			t.Errorf("%s routed to %q", pattern, got)
+		}
+	}
+}
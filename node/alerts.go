@@ -0,0 +1,357 @@
+package node
+
+// alerts.go — Operator alert bus: conditions are raised and resolved by key,
+// delivered to the log, an optional webhook and the UI, and exposed at
+// GET /alerts.
+//
+// A condition fires once when raised and once more (as a recovery) when
+// resolved. Re-raising an active key is a no-op, and a key that flaps back
+// within alertCooldown after resolving is tracked as active again but not
+// re-delivered, so a flapping peer cannot flood the sinks.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+const (
+	alertCooldown       = 60 * time.Second
+	alertHistoryLimit   = 50
+	alertWebhookTimeout = 3 * time.Second
+	healthCheckInterval = 2 * time.Second
+	peerMissingAfter    = 10 * time.Second
+	electionChurnLimit  = 5
+	electionChurnWindow = 2 * time.Minute
+	checkpointFailLimit = 3
+)
+
+// Alert is one delivered notification or one active condition.
+type Alert struct {
+	Key       string `json:"key"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+	NodeID    string `json:"nodeId"`
+	Resolved  bool   `json:"resolved"`
+	FiredUnix int64  `json:"firedAtUnix"`
+}
+
+type alertState struct {
+	alert      Alert
+	delivered  bool // false when suppressed by the cool-down
+	resolvedAt time.Time
+}
+
+type AlertBus struct {
+	mu         sync.Mutex
+	nodeID     string
+	webhookURL string
+	active     map[string]*alertState
+	lastClear  map[string]time.Time
+	history    []Alert
+	client     *http.Client
+
+	// observe, if set, sees every raise and resolve, including ones the
+	// cool-down keeps from delivery, and every delivered one-off Notify.
+	observe func(a Alert, oneOff bool)
+}
+
+func NewAlertBus(nodeID string) *AlertBus {
+	return &AlertBus{
+		nodeID:    nodeID,
+		active:    map[string]*alertState{},
+		lastClear: map[string]time.Time{},
+		client:    &http.Client{Timeout: alertWebhookTimeout},
+	}
+}
+
+// SetWebhook configures the URL alerts are POSTed to as JSON.
+func (b *AlertBus) SetWebhook(url string) {
+	b.mu.Lock()
+	b.webhookURL = url
+	b.mu.Unlock()
+}
+
+// Raise activates a condition. It is delivered unless already active or
+// resolved less than alertCooldown ago.
+func (b *AlertBus) Raise(key, severity, message string) {
+	b.mu.Lock()
+	if _, ok := b.active[key]; ok {
+		b.mu.Unlock()
+		return
+	}
+	a := Alert{Key: key, Severity: severity, Message: message, NodeID: b.nodeID, FiredUnix: time.Now().Unix()}
+	st := &alertState{alert: a}
+	st.delivered = time.Since(b.lastClear[key]) >= alertCooldown
+	b.active[key] = st
+	b.mu.Unlock()
+
+	if b.observe != nil {
+		b.observe(a, false)
+	}
+	if st.delivered {
+		b.deliver(a)
+	}
+}
+
+// Resolve clears a condition and sends a recovery if the raise was delivered.
+func (b *AlertBus) Resolve(key, message string) {
+	b.mu.Lock()
+	st, ok := b.active[key]
+	if !ok {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.active, key)
+	b.lastClear[key] = time.Now()
+	b.mu.Unlock()
+
+	a := Alert{Key: key, Severity: SeverityInfo, Message: message, NodeID: b.nodeID, Resolved: true, FiredUnix: time.Now().Unix()}
+	if b.observe != nil {
+		b.observe(a, false)
+	}
+	if st.delivered {
+		b.deliver(a)
+	}
+}
+
+// Notify delivers a one-off event (no active state), subject to the cool-down.
+func (b *AlertBus) Notify(key, severity, message string) {
+	b.mu.Lock()
+	if time.Since(b.lastClear[key]) < alertCooldown {
+		b.mu.Unlock()
+		return
+	}
+	b.lastClear[key] = time.Now()
+	b.mu.Unlock()
+	a := Alert{Key: key, Severity: severity, Message: message, NodeID: b.nodeID, FiredUnix: time.Now().Unix()}
+	if b.observe != nil {
+		b.observe(a, true)
+	}
+	b.deliver(a)
+}
+
+// IsActive reports whether key is currently raised.
+func (b *AlertBus) IsActive(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.active[key]
+	return ok
+}
+
+// ActiveKeys lists the currently raised keys.
+func (b *AlertBus) ActiveKeys() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	keys := make([]string, 0, len(b.active))
+	for k := range b.active {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (b *AlertBus) deliver(a Alert) {
+	b.mu.Lock()
+	b.history = append(b.history, a)
+	if len(b.history) > alertHistoryLimit {
+		b.history = b.history[len(b.history)-alertHistoryLimit:]
+	}
+	url := b.webhookURL
+	b.mu.Unlock()
+
+	if a.Resolved {
+		log.Printf("[%s] ✅ ALERT RESOLVED %s: %s\n", a.NodeID, a.Key, a.Message)
+	} else {
+		log.Printf("[%s] 🚨 ALERT %s [%s]: %s\n", a.NodeID, a.Key, a.Severity, a.Message)
+	}
+	if url == "" {
+		return
+	}
+	go func() {
+		body, _ := json.Marshal(a)
+		resp, err := b.client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("[%s] Alert webhook failed: %v\n", a.NodeID, err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+// snapshot returns active conditions (oldest first) and recent deliveries.
+func (b *AlertBus) snapshot() ([]Alert, []Alert) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	active := make([]Alert, 0, len(b.active))
+	for _, st := range b.active {
+		active = append(active, st.alert)
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].FiredUnix < active[j].FiredUnix })
+	return active, append([]Alert(nil), b.history...)
+}
+
+// handleAlertsRequest serves GET /alerts.
+func (n *Node) handleAlertsRequest(w http.ResponseWriter, r *http.Request) {
+	active, recent := n.Alerts.snapshot()
+	writeJSON(w, struct {
+		Active []Alert `json:"active"`
+		Recent []Alert `json:"recent"`
+	}{active, recent})
+}
+
+// ── Condition detectors ──────────────────────────────────────────────────────
+
+// notePeerContact records the outcome of a heartbeat to a peer.
+func (n *Node) notePeerContact(peer string, ok bool) {
+	if !ok {
+		return
+	}
+	n.healthMu.Lock()
+	if n.peerLastSeen == nil {
+		n.peerLastSeen = map[string]time.Time{}
+	}
+	n.peerLastSeen[peer] = time.Now()
+	n.healthMu.Unlock()
+}
+
+// noteElection records an election start and raises election churn when
+// more than electionChurnLimit happen within electionChurnWindow.
+func (n *Node) noteElection() {
+	now := time.Now()
+	n.healthMu.Lock()
+	recent := n.electionTimes[:0]
+	for _, t := range n.electionTimes {
+		if now.Sub(t) <= electionChurnWindow {
+			recent = append(recent, t)
+		}
+	}
+	n.electionTimes = append(recent, now)
+	count := len(n.electionTimes)
+	n.healthMu.Unlock()
+	n.incidents.begin("election", "election", SeverityInfo, n.ID+" started an election")
+
+	if count > electionChurnLimit {
+		n.Alerts.Raise("election_churn", SeverityWarning,
+			fmt.Sprintf("%d elections in the last %s", count, electionChurnWindow))
+	}
+}
+
+// noteCheckpointResult tracks consecutive global checkpoint failures.
+func (n *Node) noteCheckpointResult(ok bool, reason string) {
+	n.healthMu.Lock()
+	if ok {
+		n.checkpointFailures = 0
+	} else {
+		n.checkpointFailures++
+	}
+	failures := n.checkpointFailures
+	n.healthMu.Unlock()
+
+	if ok {
+		n.Alerts.Resolve("checkpoint_failing", "global checkpoints succeeding again")
+		return
+	}
+	if failures >= checkpointFailLimit {
+		n.Alerts.Raise("checkpoint_failing", SeverityCritical,
+			fmt.Sprintf("%d consecutive global checkpoints failed (last: %s)", failures, reason))
+	}
+}
+
+// noteDivergence reports that reconciliation found a peer ahead of us.
+func (n *Node) noteDivergence(detail string) {
+	n.Alerts.Notify("state_divergence", SeverityWarning, "anti-entropy adopted newer peer state: "+detail)
+}
+
+// watchClusterHealth evaluates the time-based conditions every
+// healthCheckInterval.
+func (n *Node) watchClusterHealth() {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n.checkClusterHealth()
+	}
+}
+
+// checkClusterHealth evaluates time-based conditions: quorum reachability
+// and missing peers (coordinator only), and election churn decay.
+func (n *Node) checkClusterHealth() {
+	n.noteLeaderChange()
+	n.healthMu.Lock()
+	churn := 0
+	for _, t := range n.electionTimes {
+		if time.Since(t) <= electionChurnWindow {
+			churn++
+		}
+	}
+	n.healthMu.Unlock()
+	if churn <= electionChurnLimit {
+		n.Alerts.Resolve("election_churn", "election rate back to normal")
+	}
+
+	n.ElectionMutex.Lock()
+	isCoordinator := n.Coordinator == n.ID
+	n.ElectionMutex.Unlock()
+	peers := n.peerList()
+	if !isCoordinator || len(peers) == 0 {
+		n.healthMu.Lock()
+		n.leaderSince = time.Time{}
+		n.healthMu.Unlock()
+		for _, key := range n.Alerts.ActiveKeys() {
+			if key == "quorum_lost" || strings.HasPrefix(key, "peer_missing:") {
+				n.Alerts.Resolve(key, "no longer coordinator; condition not tracked here")
+			}
+		}
+		return
+	}
+
+	now := time.Now()
+	n.healthMu.Lock()
+	if n.leaderSince.IsZero() {
+		n.leaderSince = now
+	}
+	since := n.leaderSince
+	reachable := 0
+	missing := []string{}
+	for _, p := range peers {
+		last, ok := n.peerLastSeen[p]
+		if !ok {
+			last = since
+		}
+		if now.Sub(last) > peerMissingAfter {
+			missing = append(missing, p)
+		} else {
+			reachable++
+		}
+	}
+	n.healthMu.Unlock()
+
+	missingSet := sliceToSet(missing)
+	for _, p := range peers {
+		key := "peer_missing:" + p
+		if missingSet[p] {
+			n.Alerts.Raise(key, SeverityWarning, fmt.Sprintf("%s has not answered heartbeats for over %s", p, peerMissingAfter))
+		} else {
+			n.Alerts.Resolve(key, p+" answering heartbeats again")
+		}
+	}
+
+	quorum := quorumFor(len(peers))
+	if reachable+1 < quorum {
+		n.Alerts.Raise("quorum_lost", SeverityCritical,
+			fmt.Sprintf("only %d of %d nodes reachable; quorum is %d, bids will abort", reachable+1, len(peers)+1, quorum))
+	} else {
+		n.Alerts.Resolve("quorum_lost", fmt.Sprintf("quorum regained (%d of %d nodes reachable)", reachable+1, len(peers)+1))
+	}
+}
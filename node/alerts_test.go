@@ -0,0 +1,159 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// delivered lists what the bus delivered, as "key" for a raise or one-off
+// and "key resolved" for a recovery.
+func delivered(b *AlertBus) []string {
+	_, history := b.snapshot()
+	var out []string
+	for _, a := range history {
+		if a.Resolved {
+			out = append(out, a.Key+" resolved")
+		} else {
+			out = append(out, a.Key)
+		}
+	}
+	return out
+}
+
+func expectDelivered(t *testing.T, b *AlertBus, want ...string) {
+	t.Helper()
+	if got := delivered(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("delivered %v, want %v", got, want)
+	}
+}
+
+func TestAlertBusDedupAndCooldown(t *testing.T) {
+	var mu sync.Mutex
+	var posted []Alert
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		posted = append(posted, a)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	b := NewAlertBus("N1")
+	b.SetWebhook(hook.URL)
+
+	b.Raise("quorum_lost", SeverityCritical, "lost")
+	b.Raise("quorum_lost", SeverityCritical, "still lost")
+	b.Resolve("quorum_lost", "regained")
+	// Flapping back within the cool-down is tracked but not re-delivered.
+	b.Raise("quorum_lost", SeverityCritical, "lost again")
+	if !b.IsActive("quorum_lost") {
+		t.Error("re-raised condition not active")
+	}
+	b.Resolve("quorum_lost", "regained again")
+	b.Resolve("quorum_lost", "never raised")
+	b.Notify("state_divergence", SeverityWarning, "adopted peer state")
+	b.Notify("state_divergence", SeverityWarning, "adopted peer state again")
+
+	expectDelivered(t, b, "quorum_lost", "quorum_lost resolved", "state_divergence")
+	waitFor(t, "the webhook deliveries", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(posted) == 3
+	})
+	time.Sleep(50 * time.Millisecond) // nothing more arrives
+	mu.Lock()
+	defer mu.Unlock()
+	// Webhook posts are sent concurrently, so they may arrive in any order.
+	raised := 0
+	for _, a := range posted {
+		if a.Key == "quorum_lost" && !a.Resolved && a.Severity == SeverityCritical && a.NodeID == "N1" {
+			raised++
+		}
+	}
+	if len(posted) != 3 || raised != 1 {
+		t.Errorf("webhook got %+v, want the 3 deliveries", posted)
+	}
+}
+
+func TestClusterHealthAlerts(t *testing.T) {
+	n := electionNode(t, &fakeCaller{}, "p1:1", "p2:1")
+	setLeader(n, n.ID, n.Address)
+
+	// Leading for a while with neither peer heard from.
+	n.healthMu.Lock()
+	n.leaderSince = time.Now().Add(-2 * peerMissingAfter)
+	n.healthMu.Unlock()
+	n.checkClusterHealth()
+	n.checkClusterHealth()
+	expectDelivered(t, n.Alerts, "peer_missing:p1:1", "peer_missing:p2:1", "quorum_lost")
+	rec := httptest.NewRecorder()
+	n.handleAlertsRequest(rec, httptest.NewRequest(http.MethodGet, "/alerts", nil))
+	var body struct{ Active, Recent []Alert }
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Active) != 3 || len(body.Recent) != 3 {
+		t.Errorf("/alerts has %d active and %d recent, want 3 and 3", len(body.Active), len(body.Recent))
+	}
+
+	n.notePeerContact("p1:1", true)
+	n.checkClusterHealth()
+	n.checkClusterHealth()
+	expectDelivered(t, n.Alerts, "peer_missing:p1:1", "peer_missing:p2:1", "quorum_lost",
+		"peer_missing:p1:1 resolved", "quorum_lost resolved")
+	if got := n.Alerts.ActiveKeys(); !reflect.DeepEqual(got, []string{"peer_missing:p2:1"}) {
+		t.Errorf("active = %v, want only p2 missing", got)
+	}
+
+	// A follower does not track peers, so the condition is cleared.
+	setLeader(n, "N3", "p2:1")
+	n.checkClusterHealth()
+	if got := n.Alerts.ActiveKeys(); len(got) != 0 {
+		t.Errorf("active as a follower = %v, want none", got)
+	}
+}
+
+func TestElectionChurnAlert(t *testing.T) {
+	n := electionNode(t, &fakeCaller{})
+	for i := 0; i < electionChurnLimit+3; i++ {
+		n.noteElection()
+	}
+	expectDelivered(t, n.Alerts, "election_churn")
+
+	// The elections age out of the window.
+	n.healthMu.Lock()
+	for i := range n.electionTimes {
+		n.electionTimes[i] = n.electionTimes[i].Add(-2 * electionChurnWindow)
+	}
+	n.healthMu.Unlock()
+	n.checkClusterHealth()
+	n.checkClusterHealth()
+	expectDelivered(t, n.Alerts, "election_churn", "election_churn resolved")
+}
+
+func TestCheckpointFailingAlert(t *testing.T) {
+	n := electionNode(t, &fakeCaller{})
+	for i := 1; i < checkpointFailLimit; i++ {
+		n.noteCheckpointResult(false, "peer timeout")
+	}
+	expectDelivered(t, n.Alerts)
+	n.noteCheckpointResult(false, "peer timeout")
+	n.noteCheckpointResult(false, "peer timeout")
+	n.noteCheckpointResult(true, "")
+	n.noteCheckpointResult(true, "")
+	expectDelivered(t, n.Alerts, "checkpoint_failing", "checkpoint_failing resolved")
+}
+
+func TestDivergenceAlert(t *testing.T) {
+	n := electionNode(t, &fakeCaller{})
+	n.noteDivergence("peer N3 at version 12")
+	n.noteDivergence("peer N3 at version 13")
+	expectDelivered(t, n.Alerts, "state_divergence")
+}
@@ -0,0 +1,113 @@
+package node
+
+// announcement.go — Auction phases and the replicated "sold" interstitial.
+//
+// When an item closes, the coordinator does not jump straight to the next
+// lot. It records a SoldAnnouncement with a coordinator-chosen end time, so
+// every node's /state reports phase "sold-announcement" and every UI shows
+// the same "SOLD to … for $…" screen. The announcement is ordinary queue
+// state: it is broadcast, checkpointed, and resumed by a new coordinator.
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const soldAnnouncementDuration = 5 * time.Second
+
+// Auction phases reported in QueueSnapshot.Phase.
+const (
+	PhaseIdle             = "idle"
+	PhaseBidding          = "bidding"
+	PhasePaused           = "paused"
+	PhaseSoldAnnouncement = "sold-announcement"
+	PhaseEnded            = "ended"
+)
+
+// SoldAnnouncement is the interstitial shown after an item closes.
+type SoldAnnouncement struct {
+	Result    ItemResult
+	UntilUnix int64
+}
+
+// phaseLocked derives the auction phase from explicit state only (never the
+// wall clock), so cached /state bodies stay correct. Must hold mu.
+func (q *ItemQueueState) phaseLocked() string {
+	switch {
+	case q.Announcement != nil:
+		return PhaseSoldAnnouncement
+	case q.Active && q.CurrentItem != nil:
+		return PhaseBidding
+	case q.CurrentItem != nil:
+		return PhasePaused
+	case len(q.Results) > 0 && len(q.Queue) == 0:
+		return PhaseEnded
+	default:
+		return PhaseIdle
+	}
+}
+
+// beginSoldAnnouncementLocked records the interstitial for the item that was
+// just finalized. Must hold Queue.mu. Returns the announcement end time.
+func (n *Node) beginSoldAnnouncementLocked() int64 {
+	if len(n.Queue.Results) == 0 {
+		return 0
+	}
+	until := time.Now().Add(soldAnnouncementDuration).Unix()
+	n.Queue.Announcement = &SoldAnnouncement{
+		Result:    n.Queue.Results[len(n.Queue.Results)-1],
+		UntilUnix: until,
+	}
+	n.Queue.touchLocked()
+	return until
+}
+
+// runAnnouncementTimer ends the announcement at untilUnix and, if the auction
+// is still running, opens the next lot. Coordinator only.
+func (n *Node) runAnnouncementTimer(untilUnix int64) {
+	if !sleepWhileLeading(n.leaderContext(), time.Until(time.Unix(untilUnix, 0))) {
+		return
+	}
+
+	n.ElectionMutex.Lock()
+	isCoordinator := n.Coordinator == n.ID // cleared by a step-down; see stepdown.go
+	n.ElectionMutex.Unlock()
+	if !isCoordinator {
+		return
+	}
+
+	n.Queue.mu.Lock()
+	if n.Queue.Announcement == nil || n.Queue.Announcement.UntilUnix != untilUnix {
+		// Superseded (restart/start) or already handled.
+		n.Queue.mu.Unlock()
+		return
+	}
+	n.Queue.Announcement = nil
+	n.Queue.touchLocked()
+	active := n.Queue.Active
+	n.Queue.mu.Unlock()
+
+	if !active {
+		log.Printf("[%s] Sold announcement ended; auction is stopped\n", n.ID)
+		n.broadcastQueueState()
+		return
+	}
+	n.startNextItem()
+}
+
+// soldAnnouncementRejection returns the bid rejection shown while a sale is
+// being announced, or "" when no announcement is in progress.
+func (n *Node) soldAnnouncementRejection() string {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	ann := n.Queue.Announcement
+	if ann == nil {
+		return ""
+	}
+	if ann.Result.Winner == "No bids" {
+		return fmt.Sprintf("Bidding closed: %s went unsold; next lot starts shortly", ann.Result.Item.Name)
+	}
+	return fmt.Sprintf("Bidding closed: %s sold to %s for $%d; next lot starts shortly",
+		ann.Result.Item.Name, ann.Result.Winner, ann.Result.WinningBid)
+}
@@ -0,0 +1,78 @@
+package node
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSoldAnnouncementPhases(t *testing.T) {
+	n := biddingNode(t)
+	leading(t, n)
+	follower := NewNode("T2", "127.0.0.1:10", nil, 2)
+	n.Queue.mu.Lock()
+	n.Queue.CurrentItem.Name = "Clock"
+	n.Queue.CurrentHighestBid, n.Queue.CurrentWinner, n.Queue.CurrentWinnerID = 40, "Sam", "b-sam"
+	n.Queue.Queue = []AuctionItem{{ID: "lot2", StartingPrice: 20, DurationSec: 60}}
+	n.Queue.mu.Unlock()
+	if got := n.buildQueueSnapshot().Phase; got != PhaseBidding {
+		t.Fatalf("phase = %q, want %q", got, PhaseBidding)
+	}
+
+	n.Queue.mu.Lock()
+	n.finalizeCurrentItemLocked()
+	n.beginSoldAnnouncementLocked()
+	n.Queue.mu.Unlock()
+	snap := n.buildQueueSnapshot()
+	if snap.Phase != PhaseSoldAnnouncement || snap.Announcement == nil || snap.Announcement.Result.Winner != "Sam" {
+		t.Fatalf("after the close: phase %q, announcement %+v", snap.Phase, snap.Announcement)
+	}
+
+	// Every node shows the announcement.
+	if !follower.applyQueueSnapshot(snap, "test") {
+		t.Fatal("snapshot rejected")
+	}
+	if got := follower.buildQueueSnapshot().Phase; got != PhaseSoldAnnouncement {
+		t.Errorf("follower phase = %q, want %q", got, PhaseSoldAnnouncement)
+	}
+
+	// Bids during the announcement are turned away as closed.
+	reply := n.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 60, ItemID: "lot1"})
+	if reply.Code != BidClosed || !strings.Contains(reply.Message, "Clock sold to Sam for $40") {
+		t.Errorf("bid during the announcement = %s %q, want closed", reply.Code, reply.Message)
+	}
+
+	// The follower takes over mid-announcement and ends it on schedule,
+	// opening the next lot.
+	follower.Queue.mu.Lock()
+	until := time.Now().Unix() - 1
+	follower.Queue.Announcement.UntilUnix = until
+	follower.Queue.mu.Unlock()
+	leading(t, follower)
+	follower.runAnnouncementTimer(until)
+	snap = follower.buildQueueSnapshot()
+	if snap.Phase != PhaseBidding || snap.Announcement != nil || snap.CurrentItem == nil || snap.CurrentItem.ID != "lot2" {
+		t.Errorf("after the announcement: phase %q, item %v, announcement %+v", snap.Phase, snap.CurrentItem, snap.Announcement)
+	}
+}
+
+func TestAuctionPhase(t *testing.T) {
+	item := &AuctionItem{ID: "lot1"}
+	cases := []struct {
+		name  string
+		state *ItemQueueState
+		want  string
+	}{
+		{"idle", &ItemQueueState{}, PhaseIdle},
+		{"bidding", &ItemQueueState{Active: true, CurrentItem: item}, PhaseBidding},
+		{"paused", &ItemQueueState{CurrentItem: item}, PhasePaused},
+		{"announcing", &ItemQueueState{Active: true, Announcement: &SoldAnnouncement{}}, PhaseSoldAnnouncement},
+		{"ended", &ItemQueueState{Results: []ItemResult{{Item: *item}}}, PhaseEnded},
+	}
+	for _, c := range cases {
+		if got := c.state.phaseLocked(); got != c.want {
+			t.Errorf("%s: phase = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
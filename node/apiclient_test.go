@@ -0,0 +1,150 @@
+package node_test
+
+import (
+	"auction_node/client"
+	"auction_node/node"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"testing"
+)
+
+// deadURL is an https URL nothing listens on.
+func deadURL(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	return "https://" + l.Addr().String()
+}
+
+func newClient(t *testing.T, opts client.Options) *client.Client {
+	t.Helper()
+	c, err := client.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClientBidsOverMutualTLS(t *testing.T) {
+	cluster := node.StartAPICluster(t, "t1", "A", "B", "C")
+	urls := cluster.URLs()
+	ctx := context.Background()
+	jar, _ := cookiejar.New(nil)
+	opts := client.Options{
+		Nodes:  []string{deadURL(t), urls[0]},
+		CAFile: cluster.CAFile, CertFile: cluster.CertFile, KeyFile: cluster.KeyFile,
+		Jar: jar,
+	}
+	c := newClient(t, opts)
+
+	// The first node is down; A answers.
+	state, err := c.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.CurrentItem == nil || state.CurrentItem.ID != "lot1" || state.CurrentHighestBid != 10 || state.IsCoordinator {
+		t.Errorf("state from A = %+v", state)
+	}
+	res, err := c.PlaceBid(ctx, client.Bid{Bidder: "Ann", Amount: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Node != urls[0] || res.Outcome != "committed" || res.Coordinator != cluster.Node(2).ID || res.TxnID == "" {
+		t.Errorf("bid via A = %+v", res)
+	}
+
+	// A client on B with the same jar is the same bidder: A's cookie was
+	// shared with every configured node.
+	opts.Nodes = []string{urls[1]}
+	res, err = newClient(t, opts).PlaceBid(ctx, client.Bid{Bidder: "Ann", Amount: 20, ItemID: "lot1"})
+	if err != nil || res.Node != urls[1] {
+		t.Fatalf("bid via B = %+v, %v", res, err)
+	}
+	if bids := cluster.CommittedBids(2); len(bids) != 1 {
+		t.Errorf("coordinator's bid book = %v, want one bidder with both bids", bids)
+	} else {
+		for bidder, count := range bids {
+			if count != 2 {
+				t.Errorf("%s has %d committed bids, want 2", bidder, count)
+			}
+		}
+	}
+
+	// A rejection is an APIError carrying the outcome.
+	_, err = c.PlaceBid(ctx, client.Bid{Bidder: "Ann", Amount: 18})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.Outcome != "outbid" || apiErr.StatusCode == http.StatusOK {
+		t.Errorf("low bid = %v, want an outbid APIError", err)
+	}
+	if got := cluster.HighestBid(2); got != 20 {
+		t.Errorf("highest bid = %d, want 20", got)
+	}
+
+	// The nodes want a certificate from the cluster CA, and the client
+	// checks theirs.
+	opts.CertFile, opts.KeyFile = "", ""
+	if _, err := newClient(t, opts).State(ctx); err == nil || errors.As(err, &apiErr) {
+		t.Errorf("State without a client certificate = %v, want a TLS failure", err)
+	}
+	var certErr *tls.CertificateVerificationError
+	if _, err := newClient(t, client.Options{Nodes: urls}).State(ctx); !errors.As(err, &certErr) {
+		t.Errorf("State without the cluster CA = %v, want a verification error", err)
+	}
+}
+
+func TestClientAdminTokenRotation(t *testing.T) {
+	cluster := node.StartAPICluster(t, "t1", "A", "B", "C")
+	ctx := context.Background()
+	c := newClient(t, client.Options{
+		Nodes: cluster.URLs(), AdminNodes: cluster.AdminURLs(),
+		CAFile: cluster.CAFile, CertFile: cluster.CertFile, KeyFile: cluster.KeyFile,
+		Token: "t1",
+	})
+	var inflight map[string]any
+	if err := c.AdminJSON(ctx, "/admin/inflight", &inflight); err != nil || inflight["nodeId"] != "A" {
+		t.Fatalf("/admin/inflight = %v, %v", inflight, err)
+	}
+	batch, err := c.AddItems(ctx, []node.TemplateItem{{Name: "Lamp", Description: "Brass", StartingPrice: 25, DurationSec: 30}})
+	if err != nil || !batch.Accepted || len(batch.IDs) != 1 {
+		t.Fatalf("AddItems = %+v, %v", batch, err)
+	}
+	batch, err = c.AddItems(ctx, []node.TemplateItem{{Name: "Lamp"}})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || batch == nil || len(batch.Errors) != 1 {
+		t.Errorf("invalid batch = %+v, %v; want the per-item reasons", batch, err)
+	}
+	if err := c.AdminJSON(ctx, "/metrics", &inflight); !errors.Is(err, client.ErrProtocol) {
+		t.Errorf("AdminJSON on the text /metrics = %v, want ErrProtocol", err)
+	}
+
+	// The cluster moves to a new token mid-session. The old one is refused
+	// by the first node, and the client does not try it on the others.
+	cluster.RotateToken("t2")
+	if err := c.AdminJSON(ctx, "/admin/inflight", &inflight); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("old token after rotation = %v, want ErrUnauthorized", err)
+	}
+	rejected := 0.0
+	for i := range cluster.AdminURLs() {
+		rejected += cluster.Node(i).Metrics.Counter("admin_auth_rejected_total")
+	}
+	if rejected != 1 {
+		t.Errorf("admin_auth_rejected_total across the cluster = %v, want 1", rejected)
+	}
+	c.SetToken("t2")
+	if err := c.AdminJSON(ctx, "/admin/inflight", &inflight); err != nil {
+		t.Errorf("new token: %v", err)
+	}
+
+	// Nodes without a token refuse every admin call.
+	cluster.RotateToken("")
+	if err := c.AdminJSON(ctx, "/admin/inflight", &inflight); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("no token configured = %v, want ErrForbidden", err)
+	}
+}
@@ -0,0 +1,232 @@
+package node
+
+// apiv1.go — Versioned public JSON under /api/v1 with stable camelCase names.
+//
+// The legacy /state serves QueueSnapshot as-is, so its field names are the Go
+// names (CurrentItem, DeadlineUnix), and /checkpoint mixes camelCase with the
+// Go-cased items nested in it. Both keep their shapes for existing clients.
+// The v1 endpoints serve the same data through the types below instead,
+// which exist only for the wire: the internal structs can then be renamed
+// freely, and a v1 field changes only when these tags change.
+//
+//   GET /api/v1/state       — StateV1
+//   GET /api/v1/checkpoint  — CheckpointV1 (404 before the first checkpoint)
+//
+// Allow-lists, budgets and bid books have no field here at all. The v1 state
+// body is cached like /state; see statecache.go.
+
+import (
+	"net/http"
+	"os"
+)
+
+// ItemV1 is an auction lot.
+type ItemV1 struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	Emoji         string            `json:"emoji"`
+	Category      string            `json:"category"`
+	StartingPrice int               `json:"startingPrice"`
+	DurationSec   int               `json:"durationSec"`
+	Increments    []IncrementBand   `json:"increments,omitempty"`
+	Restricted    bool              `json:"restricted"`
+	Custom        map[string]string `json:"custom,omitempty"`
+}
+
+// ResultV1 is the outcome of a closed lot.
+type ResultV1 struct {
+	Item                 ItemV1 `json:"item"`
+	Winner               string `json:"winner"`
+	WinnerID             string `json:"winnerId"`
+	WinningBid           int    `json:"winningBid"`
+	OpenedAtUnix         int64  `json:"openedAtUnix"`
+	ClosedAtUnix         int64  `json:"closedAtUnix"`
+	ScheduledDurationSec int    `json:"scheduledDurationSec"`
+	ActualDurationSec    int    `json:"actualDurationSec"`
+}
+
+// AnnouncementV1 is the "sold" interstitial shown between lots.
+type AnnouncementV1 struct {
+	Result    ResultV1 `json:"result"`
+	UntilUnix int64    `json:"untilUnix"`
+}
+
+// ShuffleV1 describes the last queue shuffle; see shuffle.go.
+type ShuffleV1 struct {
+	Seed          string   `json:"seed"`
+	SeedInput     string   `json:"seedInput"`
+	ResultsDigest string   `json:"resultsDigest"`
+	Term          int      `json:"term"`
+	Round         int      `json:"round"`
+	Order         []string `json:"order"`
+	AtUnix        int64    `json:"atUnix"`
+}
+
+// StateV1 is the body of GET /api/v1/state.
+type StateV1 struct {
+	Phase             string                 `json:"phase"` // idle, bidding, paused, sold-announcement, ended
+	NodePhase         NodePhase              `json:"nodePhase"`
+	IsCoordinator     bool                   `json:"isCoordinator"`
+	Active            bool                   `json:"active"`
+	CurrentItem       *ItemV1                `json:"currentItem"`
+	CurrentHighestBid int                    `json:"currentHighestBid"`
+	CurrentWinner     string                 `json:"currentWinner"`
+	CurrentWinnerID   string                 `json:"currentWinnerId"`
+	MinNextBid        int                    `json:"minNextBid"`
+	BidIncrement      int                    `json:"bidIncrement"`
+	OpenedAtUnix      int64                  `json:"openedAtUnix"`
+	DeadlineUnix      int64                  `json:"deadlineUnix"`
+	GraceFromUnix     int64                  `json:"graceFromUnix,omitempty"` // original deadline during a failover grace
+	QueueLen          int                    `json:"queueLen"`
+	RemainingItems    []ItemV1               `json:"remainingItems"`
+	Results           []ResultV1             `json:"results"`
+	ResultsTrimmed    int                    `json:"resultsTrimmed"`
+	Announcement      *AnnouncementV1        `json:"announcement"`
+	Shuffle           *ShuffleV1             `json:"shuffle"`
+	BidderStyles      map[string]BidderStyle `json:"bidderStyles"`
+}
+
+// CheckpointV1 is the body of GET /api/v1/checkpoint.
+type CheckpointV1 struct {
+	SchemaVersion     int             `json:"schemaVersion"`
+	NodeID            string          `json:"nodeId"`
+	CheckpointTime    int64           `json:"checkpointTime"`
+	LamportStamp      int             `json:"lamportStamp"`
+	ElectionTerm      int             `json:"electionTerm"`
+	Active            bool            `json:"active"`
+	CurrentItem       *ItemV1         `json:"currentItem"`
+	CurrentHighestBid int             `json:"currentHighestBid"`
+	CurrentWinner     string          `json:"currentWinner"`
+	CurrentWinnerID   string          `json:"currentWinnerId"`
+	OpenedAtUnix      int64           `json:"openedAtUnix"`
+	DeadlineUnix      int64           `json:"deadlineUnix"`
+	RemainingItems    []ItemV1        `json:"remainingItems"`
+	Results           []ResultV1      `json:"results"`
+	ResultsTrimmed    int             `json:"resultsTrimmed"`
+	Announcement      *AnnouncementV1 `json:"announcement"`
+	Shuffle           *ShuffleV1      `json:"shuffle"`
+	PendingTxns       int             `json:"pendingTxns"` // prepared but undecided when saved
+	ConfigVersion     int             `json:"configVersion"`
+	Peers             []string        `json:"peers"`
+}
+
+func itemV1(item AuctionItem) ItemV1 {
+	return ItemV1{
+		ID: item.ID, Name: item.Name, Description: item.Description, Emoji: item.Emoji,
+		Category: item.Category, StartingPrice: item.StartingPrice, DurationSec: item.DurationSec,
+		Increments: item.Increments, Restricted: item.Restricted, Custom: item.Custom,
+	}
+}
+
+func itemPtrV1(item *AuctionItem) *ItemV1 {
+	if item == nil {
+		return nil
+	}
+	v := itemV1(*item)
+	return &v
+}
+
+func itemsV1(items []AuctionItem) []ItemV1 {
+	out := make([]ItemV1, len(items))
+	for i, item := range items {
+		out[i] = itemV1(item)
+	}
+	return out
+}
+
+func resultV1(r ItemResult) ResultV1 {
+	return ResultV1{
+		Item: itemV1(r.Item), Winner: r.Winner, WinnerID: r.WinnerID, WinningBid: r.WinningBid,
+		OpenedAtUnix: r.OpenedAtUnix, ClosedAtUnix: r.ClosedAtUnix,
+		ScheduledDurationSec: r.ScheduledDurationSec, ActualDurationSec: r.ActualDurationSec,
+	}
+}
+
+func resultsV1(results []ItemResult) []ResultV1 {
+	out := make([]ResultV1, len(results))
+	for i, r := range results {
+		out[i] = resultV1(r)
+	}
+	return out
+}
+
+func announcementV1(ann *SoldAnnouncement) *AnnouncementV1 {
+	if ann == nil {
+		return nil
+	}
+	return &AnnouncementV1{Result: resultV1(ann.Result), UntilUnix: ann.UntilUnix}
+}
+
+func shuffleV1(s *ShuffleRecord) *ShuffleV1 {
+	if s == nil {
+		return nil
+	}
+	return &ShuffleV1{
+		Seed: s.Seed, SeedInput: s.SeedInput, ResultsDigest: s.ResultsDigest,
+		Term: s.Term, Round: s.Round, Order: s.Order, AtUnix: s.AtUnix,
+	}
+}
+
+func stateV1(snap QueueSnapshot) StateV1 {
+	styles := snap.BidderStyles
+	if styles == nil {
+		styles = map[string]BidderStyle{}
+	}
+	return StateV1{
+		Phase: snap.Phase, NodePhase: snap.NodePhase, IsCoordinator: snap.IsCoordinator,
+		Active: snap.Active, CurrentItem: itemPtrV1(snap.CurrentItem),
+		CurrentHighestBid: snap.CurrentHighestBid, CurrentWinner: snap.CurrentWinner, CurrentWinnerID: snap.CurrentWinnerID,
+		MinNextBid: snap.MinNextBid, BidIncrement: snap.BidIncrement,
+		OpenedAtUnix: snap.OpenedAtUnix, DeadlineUnix: snap.DeadlineUnix, GraceFromUnix: snap.GraceFromUnix,
+		QueueLen: snap.QueueLen, RemainingItems: itemsV1(snap.RemainingItems),
+		Results: resultsV1(snap.Results), ResultsTrimmed: snap.ResultsTrimmed,
+		Announcement: announcementV1(snap.Announcement), Shuffle: shuffleV1(snap.Shuffle),
+		BidderStyles: styles,
+	}
+}
+
+func checkpointV1(cp CheckpointData) CheckpointV1 {
+	peers := cp.Peers
+	if peers == nil {
+		peers = []string{}
+	}
+	return CheckpointV1{
+		SchemaVersion: cp.SchemaVersion, NodeID: cp.NodeID,
+		CheckpointTime: cp.CheckpointTime, LamportStamp: cp.LamportStamp, ElectionTerm: cp.ElectionTerm,
+		Active: cp.Active, CurrentItem: itemPtrV1(cp.CurrentItem),
+		CurrentHighestBid: cp.CurrentHighestBid, CurrentWinner: cp.CurrentWinner, CurrentWinnerID: cp.CurrentWinnerID,
+		OpenedAtUnix: cp.OpenedAtUnix, DeadlineUnix: cp.DeadlineUnix,
+		RemainingItems: itemsV1(cp.RemainingQueue), Results: resultsV1(cp.Results), ResultsTrimmed: cp.ResultsTrimmed,
+		Announcement: announcementV1(cp.Announcement), Shuffle: shuffleV1(cp.Shuffle),
+		PendingTxns: len(cp.PendingTxns), ConfigVersion: cp.ConfigVersion, Peers: peers,
+	}
+}
+
+func (n *Node) handleStateV1Request(w http.ResponseWriter, r *http.Request) {
+	body, err := n.cachedStateV1JSON()
+	if err != nil {
+		http.Error(w, "Could not encode state", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+func (n *Node) handleCheckpointV1Request(w http.ResponseWriter, r *http.Request) {
+	b, err := os.ReadFile(checkpointPath(n.ID))
+	if os.IsNotExist(err) {
+		http.Error(w, "No checkpoint yet", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Could not read checkpoint", http.StatusInternalServerError)
+		return
+	}
+	cp, _, err := MigrateCheckpoint(b)
+	if err != nil {
+		http.Error(w, "Could not read checkpoint", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, checkpointV1(n.displayCheckpoint(*cp)))
+}
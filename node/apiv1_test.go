@@ -0,0 +1,125 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// expectGolden compares v, as indented JSON, with testdata/name.
+func expectGolden(t *testing.T, name string, v any) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := readFixture(t, name); !bytes.Equal(got, want) {
+		t.Errorf("%s changed; the v1 JSON is a public contract. Got:\n%s", name, got)
+	}
+}
+
+// goldenItem and goldenResult set every field the v1 types carry.
+var (
+	goldenItem = AuctionItem{
+		ID: "item-2", Name: "Road bike", Description: "Steel frame", Emoji: "🚲", Category: "Sports",
+		StartingPrice: 200, DurationSec: 90, Increments: []IncrementBand{{UpTo: 500, Increment: 10}, {Increment: 25}},
+		Restricted: true, Custom: map[string]string{"lot.no": "B-2"}, AllowedBidders: []string{"b1"},
+	}
+	goldenResult = ItemResult{
+		Item: AuctionItem{ID: "item-1", Name: "Vase", StartingPrice: 40, DurationSec: 60}, Winner: "Ann", WinnerID: "b1",
+		WinningBid: 55, OpenedAtUnix: 1_700_000_000, ClosedAtUnix: 1_700_000_065, ScheduledDurationSec: 60, ActualDurationSec: 65,
+	}
+	goldenShuffle = &ShuffleRecord{Seed: "ab12", SeedInput: "term=3|round=1", ResultsDigest: "cd34", Term: 3, Round: 1, Order: []string{"item-3"}, AtUnix: 1_700_000_070}
+)
+
+func TestStateV1Golden(t *testing.T) {
+	snap := QueueSnapshot{
+		Phase: "bidding", NodePhase: PhaseReady, IsCoordinator: true, Active: true,
+		CurrentItem: &goldenItem, CurrentHighestBid: 220, CurrentWinner: "Bob", CurrentWinnerID: "b2",
+		MinNextBid: 230, BidIncrement: 10, OpenedAtUnix: 1_700_000_100, DeadlineUnix: 1_700_000_190, GraceFromUnix: 1_700_000_180,
+		QueueLen: 1, RemainingItems: []AuctionItem{{ID: "item-3", Name: "Lamp", StartingPrice: 25, DurationSec: 30}},
+		Results: []ItemResult{goldenResult}, ResultsTrimmed: 4,
+		Announcement: &SoldAnnouncement{Result: goldenResult, UntilUnix: 1_700_000_070}, Shuffle: goldenShuffle,
+		BidderStyles: map[string]BidderStyle{"b2": {Color: "#ff9500", Avatar: "🦊"}},
+		Budgets:      map[string]BidderBudget{"b1": {Limit: 100}}, Paddles: &PaddleBook{Numbers: map[string]int{"b1": 1}, Last: 1},
+	}
+	expectGolden(t, "api_v1_state.json", stateV1(snap))
+	expectGolden(t, "api_v1_state_empty.json", stateV1(QueueSnapshot{Phase: "idle", NodePhase: PhaseStarting}))
+}
+
+func TestCheckpointV1Golden(t *testing.T) {
+	cp := CheckpointData{
+		SchemaVersion: CheckpointSchemaVersion, NodeID: "Node2", CheckpointTime: 1_700_000_200, LamportStamp: 42, ElectionTerm: 3,
+		Active: true, CurrentItem: &goldenItem, CurrentHighestBid: 220, CurrentWinner: "Bob", CurrentWinnerID: "b2",
+		OpenedAtUnix: 1_700_000_100, DeadlineUnix: 1_700_000_190,
+		RemainingQueue: []AuctionItem{{ID: "item-3", Name: "Lamp", StartingPrice: 25, DurationSec: 30}},
+		Results:        []ItemResult{goldenResult}, ResultsTrimmed: 4,
+		Announcement: &SoldAnnouncement{Result: goldenResult, UntilUnix: 1_700_000_070}, Shuffle: goldenShuffle,
+		PendingTxns:   map[string]PendingTxnCheckpoint{"Node1-9": {Bid: BidArgs{BidderID: "b1", Amount: 230}}},
+		ConfigVersion: 2, Peers: []string{"127.0.0.1:8001", "127.0.0.1:8003"},
+		Budgets: map[string]BidderBudget{"b1": {Limit: 100}}, BidBook: map[string][]BidRecord{"b1": {{Amount: 55}}},
+	}
+	expectGolden(t, "api_v1_checkpoint.json", checkpointV1(cp))
+}
+
+func TestV1EndpointsKeepLegacyShapes(t *testing.T) {
+	n := withLotUp(biddingNode(t))
+	get := func(h http.HandlerFunc, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	keys := func(body []byte) map[string]json.RawMessage {
+		t.Helper()
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Fatalf("%v: %s", err, body)
+		}
+		return m
+	}
+
+	legacy, v1 := keys(get(n.handleStateRequest, "/state").Body.Bytes()), keys(get(n.handleStateV1Request, "/api/v1/state").Body.Bytes())
+	if _, ok := legacy["CurrentItem"]; !ok {
+		t.Error("/state lost its Go-cased CurrentItem")
+	}
+	if _, ok := v1["currentItem"]; !ok {
+		t.Errorf("/api/v1/state = %v, want camelCase", v1)
+	}
+	for k := range v1 {
+		if strings.ToLower(k[:1]) != k[:1] || k == "budgets" || k == "paddles" {
+			t.Errorf("/api/v1/state has field %q", k)
+		}
+	}
+
+	if rec := get(n.handleCheckpointV1Request, "/api/v1/checkpoint"); rec.Code != http.StatusNotFound {
+		t.Errorf("/api/v1/checkpoint before any checkpoint: %d, want 404", rec.Code)
+	}
+	if err := n.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	rec := get(n.handleCheckpointV1Request, "/api/v1/checkpoint")
+	cp := keys(rec.Body.Bytes())
+	if rec.Code != http.StatusOK || string(cp["nodeId"]) != `"T1"` || cp["remainingItems"] == nil {
+		t.Errorf("/api/v1/checkpoint: %d %s", rec.Code, rec.Body)
+	}
+	for _, hidden := range []string{"bidBook", "budgets", "remainingQueue"} {
+		if _, ok := cp[hidden]; ok {
+			t.Errorf("/api/v1/checkpoint has %q", hidden)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package node
+
+// archive.go — Bounded history: retention limits, the on-disk archive that
+// trimmed data moves to, and per-structure size gauges.
+//
+// --retain-results keeps only the newest N results in replicated state. The
+// coordinator trims right after finalizing an item and the trim travels in
+// the next broadcast; ResultsTrimmed counts how many have left the live list
+// so every node can archive exactly the results it is about to drop.
+// --retain-audit caps each node's local transaction log the same way.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+const archiveDir = "archive"
+
+type archivedResult struct {
+	ArchivedAtUnix int64      `json:"archivedAtUnix"`
+	Index          int        `json:"index"` // position in the full, untrimmed results history
+	Result         ItemResult `json:"result"`
+}
+
+func resultsArchivePath(nodeID string) string {
+	return filepath.Join(archiveDir, fmt.Sprintf("results_%s.jsonl", nodeID))
+}
+
+func txnArchivePath(nodeID string) string {
+	return filepath.Join(archiveDir, fmt.Sprintf("txn_%s.jsonl", nodeID))
+}
+
+// appendLines appends pre-encoded JSON lines to an archive file.
+func appendLines(path string, lines [][]byte) error {
+	if err := os.MkdirAll(archiveDir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, l := range lines {
+		_, _ = w.Write(l)
+		_ = w.WriteByte('\n')
+	}
+	return w.Flush()
+}
+
+// archiveResultsLocked writes results to the archive, numbering them from
+// firstIndex. Must hold Queue.mu (archive order follows state order).
+func (n *Node) archiveResultsLocked(results []ItemResult, firstIndex int) {
+	if len(results) == 0 {
+		return
+	}
+	now := time.Now().Unix()
+	lines := make([][]byte, 0, len(results))
+	for i, r := range results {
+		b, err := json.Marshal(archivedResult{ArchivedAtUnix: now, Index: firstIndex + i, Result: r})
+		if err != nil {
+			continue
+		}
+		lines = append(lines, b)
+	}
+	if err := appendLines(resultsArchivePath(n.ID), lines); err != nil {
+		log.Printf("[%s] Warning: could not archive %d result(s): %v\n", n.ID, len(results), err)
+		return
+	}
+	n.Metrics.Add("archived_results_total", float64(len(lines)))
+}
+
+// trimResultsLocked enforces --retain-results on the coordinator. The newest
+// result (the one a sold announcement refers to) is always kept. Must hold
+// Queue.mu.
+func (n *Node) trimResultsLocked() {
+	keep := n.runtimeConfig().RetainResults
+	if keep <= 0 || len(n.Queue.Results) <= keep {
+		return
+	}
+	drop := len(n.Queue.Results) - keep
+	n.archiveResultsLocked(n.Queue.Results[:drop], n.Queue.ResultsTrimmed)
+	n.Queue.Results = append([]ItemResult(nil), n.Queue.Results[drop:]...)
+	n.Queue.ResultsTrimmed += drop
+	n.Queue.touchLocked()
+	log.Printf("[%s] Retention: archived %d result(s), keeping %d\n", n.ID, drop, keep)
+}
+
+// archiveResultsDroppedBySnapshotLocked archives the local results that an
+// incoming snapshot trimmed away, so followers keep a full archive too.
+// Must hold Queue.mu.
+func (n *Node) archiveResultsDroppedBySnapshotLocked(snap QueueSnapshot) {
+	local := n.Queue.ResultsTrimmed
+	if snap.ResultsTrimmed <= local {
+		return
+	}
+	drop := snap.ResultsTrimmed - local
+	if drop > len(n.Queue.Results) {
+		drop = len(n.Queue.Results)
+	}
+	n.archiveResultsLocked(n.Queue.Results[:drop], local)
+}
+
+// compactTxnLogLocked moves the oldest transaction-log lines to the archive
+// once the log exceeds --retain-audit by a quarter. Must hold TxnLogMutex.
+func (n *Node) compactTxnLogLocked() {
+	keep := n.runtimeConfig().RetainAudit
+	if keep <= 0 {
+		return
+	}
+	if n.txnLogLines < 0 {
+		n.txnLogLines = countLines(txnLogPath(n.ID))
+	}
+	if n.txnLogLines <= keep+keep/4 {
+		return
+	}
+	b, err := os.ReadFile(txnLogPath(n.ID))
+	if err != nil {
+		return
+	}
+	var lines [][]byte
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		lines = append(lines, append([]byte(nil), sc.Bytes()...))
+	}
+	if len(lines) <= keep {
+		n.txnLogLines = len(lines)
+		return
+	}
+	drop := len(lines) - keep
+	if err := appendLines(txnArchivePath(n.ID), lines[:drop]); err != nil {
+		log.Printf("[%s] Warning: could not archive txn log: %v\n", n.ID, err)
+		return
+	}
+	var kept []byte
+	for _, l := range lines[drop:] {
+		kept = append(kept, l...)
+		kept = append(kept, '\n')
+	}
+	tmp := txnLogPath(n.ID) + ".tmp"
+	if err := os.WriteFile(tmp, kept, 0o644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, txnLogPath(n.ID)); err != nil {
+		return
+	}
+	n.txnLogLines = keep
+	n.Metrics.Add("archived_txn_log_lines_total", float64(drop))
+}
+
+func countLines(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	count := 0
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		count++
+	}
+	return count
+}
+
+// refreshMemoryMetrics publishes approximate sizes (encoded bytes) of the
+// structures that grow with uptime, plus Go heap usage.
+func (n *Node) refreshMemoryMetrics() {
+	size := func(v interface{}) float64 {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return 0
+		}
+		return float64(len(b))
+	}
+
+	n.Queue.mu.Lock()
+	results := size(n.Queue.Results)
+	queue := size(n.Queue.Queue)
+	resultCount := len(n.Queue.Results)
+	n.Queue.mu.Unlock()
+
+	n.TxnMutex.Lock()
+	pending := size(n.PendingTxns)
+	n.TxnMutex.Unlock()
+
+	n.stateCache.mu.RLock()
+	snapshot := float64(len(n.stateCache.body))
+	n.stateCache.mu.RUnlock()
+
+	n.Metrics.Set(metricName("state_bytes", "structure", "results"), results)
+	n.Metrics.Set(metricName("state_bytes", "structure", "queue"), queue)
+	n.Metrics.Set(metricName("state_bytes", "structure", "pending_txns"), pending)
+	n.Metrics.Set(metricName("state_bytes", "structure", "snapshot"), snapshot)
+	n.Metrics.Set("state_results_count", float64(resultCount))
+	for name, path := range map[string]string{
+		"txn_log":         txnLogPath(n.ID),
+		"results_archive": resultsArchivePath(n.ID),
+		"txn_archive":     txnArchivePath(n.ID),
+	} {
+		if fi, err := os.Stat(path); err == nil {
+			n.Metrics.Set(metricName("file_bytes", "file", name), float64(fi.Size()))
+		}
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	n.Metrics.Set("go_heap_alloc_bytes", float64(ms.HeapAlloc))
+}
@@ -0,0 +1,100 @@
+package node
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func readResultsArchive(t *testing.T, nodeID string) []archivedResult {
+	t.Helper()
+	f, err := os.Open(resultsArchivePath(nodeID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var out []archivedResult
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r archivedResult
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestRetentionSoak(t *testing.T) {
+	const items, keepResults, keepAudit = 500, 20, 100
+	n := biddingNode(t)
+	follower := NewNode("T2", "127.0.0.1:10", nil, 2)
+	cfg := DefaultRuntimeConfig()
+	cfg.RetainResults, cfg.RetainAudit = keepResults, keepAudit
+	if err := n.SetBaseConfig(cfg, []string{"retainResults", "retainAudit"}); err != nil {
+		t.Fatal(err)
+	}
+	rp := &NodeRPC{node: n}
+
+	var midSize int
+	for i := range items {
+		id := fmt.Sprintf("lot%d", i)
+		n.Queue.mu.Lock()
+		n.Queue.CurrentItem = &AuctionItem{ID: id, Name: "Lot " + id, StartingPrice: 10, DurationSec: 60}
+		n.Queue.CurrentHighestBid, n.Queue.CurrentWinner, n.Queue.CurrentWinnerID = 9, "", ""
+		n.Queue.mu.Unlock()
+		commitBid(t, rp, fmt.Sprintf("C-%d", i), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 10 + i, ItemID: id})
+		n.Queue.mu.Lock()
+		n.finalizeCurrentItemLocked()
+		n.beginSoldAnnouncementLocked()
+		n.Queue.mu.Unlock()
+		if !follower.applyQueueSnapshot(n.buildQueueSnapshot(), "test") {
+			t.Fatalf("item %d: snapshot rejected", i)
+		}
+		if i == items/5 {
+			b, _ := json.Marshal(n.buildQueueSnapshot())
+			midSize = len(b)
+		}
+	}
+
+	n.Queue.mu.Lock()
+	results, trimmed := len(n.Queue.Results), n.Queue.ResultsTrimmed
+	last := n.Queue.Results[results-1].Item.ID
+	n.Queue.mu.Unlock()
+	if results != keepResults || trimmed != items-keepResults {
+		t.Fatalf("kept %d results with %d trimmed, want %d and %d", results, trimmed, keepResults, items-keepResults)
+	}
+	if last != fmt.Sprintf("lot%d", items-1) {
+		t.Errorf("newest result is %s, want the last lot", last)
+	}
+	b, _ := json.Marshal(n.buildQueueSnapshot())
+	if len(b) > midSize+midSize/10 {
+		t.Errorf("snapshot grew from %d bytes at item %d to %d at item %d", midSize, items/5, len(b), items)
+	}
+	n.refreshMemoryMetrics()
+	if got := gauge(n.Metrics, "state_results_count"); got != keepResults {
+		t.Errorf("state_results_count = %v, want %d", got, keepResults)
+	}
+	if lines := countLines(txnLogPath(n.ID)); lines > keepAudit+keepAudit/4 {
+		t.Errorf("txn log has %d lines, want at most %d", lines, keepAudit+keepAudit/4)
+	}
+
+	// Both nodes archived every trimmed result once, in order.
+	for _, node := range []*Node{n, follower} {
+		archived := readResultsArchive(t, node.ID)
+		if len(archived) != items-keepResults {
+			t.Fatalf("%s archived %d results, want %d", node.ID, len(archived), items-keepResults)
+		}
+		for i, a := range archived {
+			if a.Index != i || a.Result.Item.ID != fmt.Sprintf("lot%d", i) || a.Result.WinningBid != 10+i {
+				t.Fatalf("%s archive entry %d = %+v", node.ID, i, a)
+			}
+		}
+	}
+	archivedTxn := countLines(txnArchivePath(n.ID))
+	if live := countLines(txnLogPath(n.ID)); archivedTxn+live < 2*items {
+		t.Errorf("txn log lines: %d archived + %d live, want at least %d", archivedTxn, live, 2*items)
+	}
+}
@@ -0,0 +1,173 @@
+package node
+
+// batch.go — POST /items/batch: add a whole catalogue in one step.
+//
+// The batch is validated as a whole before anything changes: one bad entry
+// rejects every entry, with an error per offending index. A valid batch is
+// applied by the coordinator inside a single Ricart–Agrawala critical
+// section, so its items get consecutive IDs and land together; followers see
+// it as one snapshot and it is checkpointed once. A coordinator crash leaves
+// either the whole batch or none of it.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+const (
+	maxBatchItems     = 500
+	maxBatchBodyBytes = 4 << 20
+)
+
+// BatchItemError reports why one entry of a batch was rejected.
+type BatchItemError struct {
+	Index int    `json:"index"` // position in the request, from 0
+	Error string `json:"error"`
+}
+
+type BatchAddItemsArgs struct {
+	Items []AddItemArgs
+}
+
+type BatchAddItemsReply struct {
+	Accepted bool
+	Message  string
+	IDs      []string         // assigned IDs, in request order
+	Errors   []BatchItemError // per-item validation errors when rejected
+}
+
+// validateBatch checks every entry and returns all problems, not just the first.
+func validateBatch(items []AddItemArgs) []BatchItemError {
+	var errs []BatchItemError
+	for i, args := range items {
+		if err := validateAddItem(args); err != nil {
+			errs = append(errs, BatchItemError{Index: i, Error: err.Error()})
+		}
+	}
+	return errs
+}
+
+// addItemsBatchAndBroadcast queues every item or none. Coordinator only;
+// followers forward via SubmitBatchAddItemsToCoordinator.
+func (n *Node) addItemsBatchAndBroadcast(items []AddItemArgs) BatchAddItemsReply {
+	switch {
+	case len(items) == 0:
+		return BatchAddItemsReply{Message: "batch has no items"}
+	case len(items) > maxBatchItems:
+		return BatchAddItemsReply{Message: fmt.Sprintf("batch has %d items; the limit is %d", len(items), maxBatchItems)}
+	}
+	if errs := validateBatch(items); len(errs) > 0 {
+		return BatchAddItemsReply{
+			Message: fmt.Sprintf("batch rejected: %d of %d items are invalid; nothing was added", len(errs), len(items)),
+			Errors:  errs,
+		}
+	}
+	if msg := n.versionWriteBlock(); msg != "" {
+		return BatchAddItemsReply{Message: msg}
+	}
+
+	release, err := n.enterCS()
+	if err != nil {
+		return BatchAddItemsReply{Message: err.Error()}
+	}
+	defer release()
+	n.queueOps.Lock()
+	defer n.queueOps.Unlock()
+
+	n.Queue.mu.Lock()
+	before := n.Queue.censusLocked()
+	ids := make([]string, len(items))
+	for i, args := range items {
+		item := newAuctionItem(n.nextItemIDLocked(), args)
+		ids[i] = item.ID
+		n.Queue.Queue = append(n.Queue.Queue, item)
+	}
+	n.Queue.touchLocked()
+	violation := n.checkItemsLocked("add_items_batch", before, ids, false)
+	n.Queue.mu.Unlock()
+	n.alertItemInvariant(violation)
+
+	log.Printf("[%s] 📦 Batch of %d items queued (%s..%s)\n", n.ID, len(ids), ids[0], ids[len(ids)-1])
+	n.Metrics.Inc("items_batches_total")
+	n.Metrics.Add("items_batch_added_total", float64(len(ids)))
+	n.broadcastQueueState()
+	go n.initiateGlobalCheckpoint()
+	return BatchAddItemsReply{Accepted: true, Message: fmt.Sprintf("%d items added to queue", len(ids)), IDs: ids}
+}
+
+// SubmitBatchAddItemsToCoordinator forwards POST /items/batch to the leader.
+func (rp *NodeRPC) SubmitBatchAddItemsToCoordinator(args BatchAddItemsArgs, reply *BatchAddItemsReply) error {
+	if _, isLocal := rp.node.getCoordinatorAddress(); !isLocal {
+		reply.Message = "This node is not the coordinator"
+		return nil
+	}
+	*reply = rp.node.addItemsBatchAndBroadcast(args.Items)
+	return nil
+}
+
+// submitBatchAddItems adds a batch on the coordinator, forwarding if needed.
+func (n *Node) submitBatchAddItems(items []AddItemArgs) (int, BatchAddItemsReply) {
+	coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
+	var reply BatchAddItemsReply
+	if isLocalCoordinator {
+		reply = n.addItemsBatchAndBroadcast(items)
+	} else {
+		if coordinatorAddress == "" {
+			return http.StatusServiceUnavailable, BatchAddItemsReply{Message: "Election in progress, please wait"}
+		}
+		if err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitBatchAddItemsToCoordinator", BatchAddItemsArgs{Items: items}, &reply); err != nil {
+			return http.StatusServiceUnavailable, BatchAddItemsReply{Message: "Leader unavailable; retry shortly"}
+		}
+	}
+	if !reply.Accepted {
+		return http.StatusBadRequest, reply
+	}
+	return http.StatusOK, reply
+}
+
+// handleBatchAddItemsRequest serves POST /items/batch. The body is a JSON
+// array of items shaped like the JSON body of POST /admin/item, or an object
+// with that array under "items" (the template file format).
+func (n *Node) handleBatchAddItemsRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes))
+	var raw json.RawMessage
+	if err := body.Decode(&raw); err != nil {
+		http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+		return
+	}
+	var entries []TemplateItem
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		var wrapped struct {
+			Items []TemplateItem `json:"items"`
+		}
+		if err := json.Unmarshal(raw, &wrapped); err != nil {
+			http.Error(w, "Expected a JSON array of items or {\"items\": [...]}", http.StatusBadRequest)
+			return
+		}
+		entries = wrapped.Items
+	}
+	items := make([]AddItemArgs, len(entries))
+	for i, e := range entries {
+		items[i] = e.addItemArgs()
+	}
+
+	var status int
+	var reply BatchAddItemsReply
+	if !n.holdForClient(r, "items_batch", func() { status, reply = n.submitBatchAddItems(items) }) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Accepted bool             `json:"accepted"`
+		Message  string           `json:"message"`
+		IDs      []string         `json:"ids,omitempty"`
+		Errors   []BatchItemError `json:"errors,omitempty"`
+	}{reply.Accepted, reply.Message, reply.IDs, reply.Errors})
+}
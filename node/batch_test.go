@@ -0,0 +1,142 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type batchResponse struct {
+	Accepted bool             `json:"accepted"`
+	Message  string           `json:"message"`
+	IDs      []string         `json:"ids"`
+	Errors   []BatchItemError `json:"errors"`
+}
+
+func postBatch(t *testing.T, n *Node, body string) (int, batchResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	n.handleBatchAddItemsRequest(rec, httptest.NewRequest(http.MethodPost, "/items/batch", strings.NewReader(body)))
+	var resp batchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("POST /items/batch: %d %s", rec.Code, rec.Body)
+	}
+	return rec.Code, resp
+}
+
+func queueLen(n *Node) int {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	return len(n.Queue.Queue)
+}
+
+func TestBatchWithInvalidRowAddsNothing(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	before := queueLen(a.Node)
+
+	code, resp := postBatch(t, b.Node, `{"items": [
+		{"name": "Vase", "description": "Blue vase", "startingPrice": 40, "durationSec": 60},
+		{"name": "", "description": "No name", "startingPrice": 10, "durationSec": 60},
+		{"name": "Bike", "description": "Road bike", "startingPrice": 200, "durationSec": 90},
+		{"name": "Lamp", "description": "Desk lamp", "startingPrice": 0, "durationSec": 30}
+	]}`)
+	if code != http.StatusBadRequest || resp.Accepted || len(resp.IDs) != 0 {
+		t.Fatalf("batch with bad rows: %d %+v", code, resp)
+	}
+	if len(resp.Errors) != 2 || resp.Errors[0].Index != 1 || resp.Errors[1].Index != 3 {
+		t.Errorf("errors = %+v, want rows 1 and 3", resp.Errors)
+	}
+	for _, tn := range nodes {
+		if got := queueLen(tn.Node); got != before {
+			t.Errorf("%s queues %d lots after a rejected batch, want %d", tn.ID, got, before)
+		}
+	}
+
+	for _, body := range []string{`[]`, `not json`, `{"items": 3}`} {
+		rec := httptest.NewRecorder()
+		a.handleBatchAddItemsRequest(rec, httptest.NewRequest(http.MethodPost, "/items/batch", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("batch %s: %d %s, want 400", body, rec.Code, rec.Body)
+		}
+	}
+	// The rejected batch used up no IDs.
+	if code, resp := postBatch(t, a.Node, `[{"name": "Vase", "description": "Blue vase", "startingPrice": 40, "durationSec": 60}]`); code != http.StatusOK || len(resp.IDs) != 1 || resp.IDs[0] != "item-7" {
+		t.Errorf("next batch: %d %+v, want item-7", code, resp)
+	}
+}
+
+func TestLargeBatchLandsAtOnce(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	before := queueLen(b.Node)
+
+	const size = 200
+	rows := make([]string, size)
+	for i := range rows {
+		rows[i] = fmt.Sprintf(`{"name": "Lot %d", "description": "Catalogue entry %d", "startingPrice": %d, "durationSec": 60}`, i, i, 10+i)
+	}
+
+	// Watch B for any sight of a partly applied batch.
+	var seen sync.Map
+	stop := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		for {
+			seen.Store(queueLen(b.Node), true)
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+	}()
+
+	version := a.Queue.Version()
+	code, resp := postBatch(t, b.Node, "["+strings.Join(rows, ",")+"]")
+	if code != http.StatusOK || !resp.Accepted || len(resp.IDs) != size {
+		t.Fatalf("200-item batch: %d %s (%d IDs)", code, resp.Message, len(resp.IDs))
+	}
+	for i, id := range resp.IDs {
+		if want := fmt.Sprintf("item-%d", 7+i); id != want {
+			t.Fatalf("ID %d = %s, want %s", i, id, want)
+		}
+	}
+	if got := a.Queue.Version() - version; got != 1 {
+		t.Errorf("the batch changed A's queue %d times, want once", got)
+	}
+	waitFor(t, "B to receive the batch", func() bool { return queueLen(b.Node) == before+size })
+	close(stop)
+	<-watched
+	seen.Range(func(k, _ interface{}) bool {
+		if l := k.(int); l != before && l != before+size {
+			t.Errorf("B held %d lots mid-batch", l)
+		}
+		return true
+	})
+
+	b.Queue.mu.Lock()
+	for i, item := range b.Queue.Queue[before:] {
+		if item.ID != resp.IDs[i] || item.Name != fmt.Sprintf("Lot %d", i) || item.StartingPrice != 10+i {
+			t.Errorf("B lot %d = %s %q $%d", i, item.ID, item.Name, item.StartingPrice)
+			break
+		}
+	}
+	b.Queue.mu.Unlock()
+	if got := a.Metrics.Counter("items_batch_added_total"); got != size {
+		t.Errorf("items_batch_added_total = %v, want %d", got, size)
+	}
+}
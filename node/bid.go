@@ -4,66 +4,251 @@ package node
 // critical-section integration.
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
-// ProposeBid runs the full 2PC bid protocol as coordinator.
-func (n *Node) ProposeBid(amount int, bidder string) (bool, string) {
-	txnBid := BidArgs{Amount: amount, Bidder: bidder}
-	if !n.canPrepareBid(txnBid) {
-		return false, "Bid must be higher than current highest bid (or auction inactive)"
+// PrepareRejection says why a node would vote NO on a bid.
+type PrepareRejection string
+
+const (
+	RejectAuctionInactive PrepareRejection = "auction_inactive"
+	RejectNoCurrentItem   PrepareRejection = "no_current_item"
+	RejectBidTooLow       PrepareRejection = "bid_too_low"
+	RejectBelowIncrement  PrepareRejection = "below_increment"
+	RejectDeadlinePassed  PrepareRejection = "deadline_passed"
+	RejectNodeNotReady    PrepareRejection = "node_not_ready"        // still syncing, or draining
+	RejectItemChanged     PrepareRejection = "item_changed"          // bid names a lot that is no longer up
+	RejectIncompatible    PrepareRejection = "incompatible_protocol" // coordinator-side: peer could not decode the request or reply
+	RejectNotAllowed      PrepareRejection = "not_allowed"           // bidder is not on an invite-only item's list
+	RejectOverBudget      PrepareRejection = "over_budget"           // bid exceeds the bidder's available budget
+	RejectReserved        PrepareRejection = "reserved"              // an undecided txn holds an equal or higher bid on the lot
+	RejectDecided         PrepareRejection = "already_decided"       // the decision on this txn arrived before its prepare
+	RejectUnreachable     PrepareRejection = "unreachable"           // coordinator-side: peer call failed or timed out
+	RejectUnknown         PrepareRejection = "unknown"               // NO vote from a peer that sends no reason
+)
+
+// Message is the human-readable form of the rejection.
+func (r PrepareRejection) Message() string {
+	switch r {
+	case RejectAuctionInactive:
+		return "auction is not running"
+	case RejectNoCurrentItem:
+		return "no item is up for bidding"
+	case RejectBidTooLow:
+		return "bid is not higher than the current highest bid"
+	case RejectBelowIncrement:
+		return "bid raises the price by less than the minimum increment"
+	case RejectDeadlinePassed:
+		return "bidding deadline has passed"
+	case RejectNodeNotReady:
+		return "node is not ready (syncing or draining)"
+	case RejectItemChanged:
+		return "bid was for a different item than the one up for bidding"
+	case RejectUnreachable:
+		return "participant unreachable"
+	case RejectIncompatible:
+		return "participant speaks an incompatible protocol"
+	case RejectNotAllowed:
+		return "bidder is not on the item's allow-list"
+	case RejectOverBudget:
+		return "bid exceeds the bidder's available budget"
+	case RejectReserved:
+		return "another bid of at least this amount is awaiting its decision"
+	case RejectDecided:
+		return "this transaction has already been decided"
+	default:
+		return "rejected for an unknown reason"
+	}
+}
+
+// itemChangedMessage is returned when a bid names a lot other than the one
+// up for bidding. The ITEM_CHANGED prefix lets clients recognise it.
+const itemChangedMessage = "ITEM_CHANGED: the item up for bidding changed before your bid was placed; check the new lot and bid again"
+
+// voteResult is one peer's answer to a prepare.
+type voteResult struct {
+	peer   string
+	yes    bool
+	reason PrepareRejection
+}
+
+// ProposeBid runs the full 2PC bid protocol as coordinator. Identical bids
+// submitted concurrently (e.g. via two nodes) share a single round. If ctx
+// ends before the votes are in, the round is aborted and the reply is
+// BidCancelled; once the decision is made it is always carried out.
+func (n *Node) ProposeBid(ctx context.Context, bid BidArgs) CoordinatorBidReply {
+	if msg := n.versionWriteBlock(); msg != "" {
+		return rejectBid(BidUnavailable, msg)
+	}
+	bid = bid.withIdentity()
+	if bid.ItemID == "" {
+		// Older clients do not say which lot they mean: pin the one up now,
+		// so a swap during 2PC still cannot carry the bid to the next lot.
+		n.Queue.mu.Lock()
+		if n.Queue.CurrentItem != nil {
+			bid.ItemID = n.Queue.CurrentItem.ID
+		}
+		n.Queue.mu.Unlock()
+	}
+	return n.dedupBid(ctx, n.bidDedupKey(bid), func() CoordinatorBidReply {
+		return n.proposeBid(ctx, bid)
+	})
+}
+
+// rejectBid builds the reply for a bid that was not committed.
+func rejectBid(code BidCode, message string) CoordinatorBidReply {
+	return CoordinatorBidReply{Code: code, Message: message}
+}
+
+// cancelledMessage is returned when the caller gave up before the decision.
+const cancelledMessage = "Bid cancelled: the request ended before the participants voted"
+
+func (n *Node) proposeBid(ctx context.Context, txnBid BidArgs) CoordinatorBidReply {
+	amount, bidder := txnBid.Amount, txnBid.DisplayName
+	timer := n.startBidTimer()
+	txnPrefix := ""
+	if txnBid.Canary {
+		timer, txnPrefix = n.startCanaryTimer(), canaryTxnPrefix
+	}
+	defer timer.finish()
+	if msg := n.soldAnnouncementRejection(); msg != "" && !txnBid.Canary {
+		return rejectBid(BidClosed, msg)
+	}
+	if ok, reason := n.canPrepareBid(txnBid); !ok {
+		if reason == RejectBelowIncrement {
+			n.Queue.mu.Lock()
+			minBid, step := n.minNextBidLocked()
+			n.Queue.mu.Unlock()
+			return rejectBid(BidBelowIncrement, fmt.Sprintf("Bid must be at least $%d (minimum increment $%d)", minBid, step))
+		}
+		if reason == RejectOverBudget {
+			n.Queue.mu.Lock()
+			var available int
+			if n.Queue.CurrentItem != nil {
+				available, _ = n.Queue.availableForLocked(txnBid.BidderID, n.Queue.CurrentItem.ID)
+			}
+			n.Queue.mu.Unlock()
+			return rejectBid(BidOverBudget, fmt.Sprintf("Bid exceeds your remaining budget of $%d", max(available, 0)))
+		}
+		return rejectBid(bidCodeFor(reason), bidRejectionMessage(reason))
 	}
 
-	n.RA.RequestCS()
-	defer n.RA.ReleaseCS()
+	timer.skip()
+	round := n.rounds.begin(txnBid)
+	defer n.rounds.end(round)
+	if slow := n.watchSlowBid(round); slow != nil {
+		defer slow.Stop()
+	}
+	release, err := n.enterCS()
+	if err != nil {
+		// The peers missing are in the log and the ra_request_timeout alert.
+		return rejectBid(BidNoQuorum, "Bid not placed: other nodes did not answer in time; please try again")
+	}
+	defer release()
+	timer.lap(stageRAAcquire)
+	if ctx.Err() != nil {
+		n.Metrics.Inc("bids_cancelled_total")
+		return rejectBid(BidCancelled, cancelledMessage)
+	}
+	if _, isLocal := n.getCoordinatorAddress(); !isLocal {
+		// Leadership moved while we waited (a transfer or a step-down); the
+		// node that took the bid retries it with the new leader.
+		return rejectBid(BidNoLeader, "Leadership moved during coordination; retry")
+	}
 
 	// Re-check after acquiring the critical section
-	if !n.canPrepareBid(txnBid) {
-		return false, "Bid became stale during coordination"
+	if ok, reason := n.canPrepareBid(txnBid); !ok {
+		if reason == RejectItemChanged {
+			return rejectBid(BidItemChanged, itemChangedMessage)
+		}
+		return rejectBid(bidCodeFor(reason), "Bid became stale during coordination: "+reason.Message())
 	}
 
-	txnID := fmt.Sprintf("%s-%d", n.ID, n.Clock.Tick())
-	quorum := (len(n.Peers)+1)/2 + 1
+	peers := n.peerList()
+	term := n.currentTerm() // fences our decision if we are deposed mid-round
+	txnBid.Coordinator, txnBid.Term = n.ID, term
+	txnID := fmt.Sprintf("%s%s-%d", txnPrefix, n.ID, n.Clock.Tick())
+	quorum := quorumFor(len(peers))
 	votes := 1
-	n.logTxnEvent(txnID, "TXN_BEGIN", fmt.Sprintf("bid=%d bidder=%s quorum=%d", amount, bidder, quorum))
+	n.logTxnEvent(txnID, "TXN_BEGIN", fmt.Sprintf("bid=%d bidder=%s id=%s origin=%s quorum=%d%s", amount, bidder, txnBid.BidderID, txnBid.Origin, quorum, txnBid.timingNote()))
 
-	n.rememberPendingTxn(txnID, txnBid)
+	if reason, holder := n.rememberPendingTxn(txnID, txnBid); reason != "" {
+		// A round of an earlier leader is still undecided here.
+		n.logTxnEvent(txnID, "TXN_PREPARE_VOTE_NO", fmt.Sprintf("%s: held by %s", reason, holder))
+		return rejectBid(bidCodeFor(reason), "Bid not placed: "+reason.Message()+"; please try again")
+	}
+	n.rounds.prepare(round, txnID, peers, quorum)
 
-	type voteResult struct{ yes bool }
-	voteCh := make(chan voteResult, len(n.Peers))
+	// voteCh has room for every peer, so a prepare that answers after
+	// collection stopped never blocks. Votes still out when collection
+	// stops are drained for --late-vote-grace, which then cancels
+	// prepareCtx to end calls still waiting on slow peers; see latevotes.go.
+	// The drain outlives the caller, so prepareCtx is not ended with ctx.
+	voteCh := make(chan voteResult, len(peers))
+	prepareCtx, cancelPrepares := context.WithCancel(context.WithoutCancel(ctx))
+	authState := n.authoritativeState()
 
 	// Phase 1: Prepare — ask all peers to vote
-	for _, peer := range n.Peers {
-		go func(p string) {
-			var vote PrepareReply
-			err := n.callPeer(p, "NodeRPC.PrepareBid",
-				PrepareArgs{TxnID: txnID, Bid: txnBid, Timestamp: n.Clock.Tick()}, &vote)
-			if err != nil {
-				voteCh <- voteResult{yes: false}
-				return
-			}
-			voteCh <- voteResult{yes: vote.Vote}
-		}(peer)
-	}
+	fanOut(peers, func(p string) {
+		var vote PrepareReply
+		err := n.callPeerContext(prepareCtx, p, "NodeRPC.PrepareBid",
+			PrepareArgs{TxnID: txnID, Bid: txnBid, Timestamp: n.Clock.Tick(), State: authState}, &vote)
+		var pe *ProtocolError
+		if errors.As(err, &pe) {
+			voteCh <- voteResult{peer: p, reason: RejectIncompatible}
+			return
+		}
+		if err != nil {
+			voteCh <- voteResult{peer: p, reason: RejectUnreachable}
+			return
+		}
+		if vote.SelfHealed {
+			n.Metrics.Inc(metricName("prepare_self_heals_total", "peer", p))
+		}
+		reason := vote.Rejection
+		if !vote.Vote && reason == "" {
+			reason = RejectUnknown
+		}
+		voteCh <- voteResult{peer: p, yes: vote.Vote, reason: reason}
+	})
 
-	// Collect votes with a timeout
-	pendingResponses := len(n.Peers)
+	// Collect votes with a timeout. NO votes are tallied by reason; peers
+	// still silent when collection stops count as unreachable.
+	rejections := map[PrepareRejection]int{}
+	var incompatible []string
+	pendingResponses := len(peers)
+	received := 0
+	cancelled := false
 	voteTimer := time.NewTimer(voteWaitTimeout)
-	for pendingResponses > 0 {
+	for pendingResponses > 0 && !cancelled {
 		if votes >= quorum || votes+pendingResponses < quorum {
 			break
 		}
 		select {
 		case result := <-voteCh:
 			pendingResponses--
+			received++
+			n.rounds.vote(round, result.peer, result.yes, result.reason)
 			if result.yes {
 				votes++
+			} else {
+				rejections[result.reason]++
+				if result.reason == RejectIncompatible {
+					incompatible = append(incompatible, n.peerName(result.peer))
+				}
 			}
 		case <-voteTimer.C:
+			rejections[RejectUnreachable] += pendingResponses
 			pendingResponses = 0
+		case <-ctx.Done():
+			cancelled = true
 		}
 	}
 	if !voteTimer.Stop() {
@@ -72,25 +257,82 @@ func (n *Node) ProposeBid(amount int, bidder string) (bool, string) {
 		default:
 		}
 	}
+	timer.lap(stagePrepare)
+	defer timer.lap(stageDecide)
+	n.rounds.advance(round, stageDecide)
 
-	// Phase 2: Decide — apply locally and broadcast decision
+	// Phase 2: Decide — log, apply locally and broadcast decision. A commit
+	// that cannot be logged is not durable, so it becomes an abort. The item
+	// timer does not take the RA lock, so the lot may have closed while the
+	// votes came in; committing then would raise the price on participants
+	// that have not seen the close yet.
 	commit := votes >= quorum
+	if commit && !txnBid.Canary && !n.itemStillUp(txnBid.ItemID) {
+		commit = false
+		rejections[RejectItemChanged]++
+	}
+	unlogged := false
+	if err := n.recordDecision(txnID, commit, txnBid, commit); err != nil && commit {
+		commit, unlogged = false, true
+		n.recordDecision(txnID, false, txnBid, false)
+	}
 	n.applyDecision(txnID, commit, txnBid)
 
-	decision := DecisionArgs{TxnID: txnID, Commit: commit, Bid: txnBid, Leader: n.ID}
+	decision := DecisionArgs{TxnID: txnID, Commit: commit, Bid: txnBid, Leader: n.ID, Term: term}
+	n.drainLateVotes(txnID, decision, voteCh, len(peers)-received, cancelPrepares)
 	if !commit {
-		n.logTxnEvent(txnID, "TXN_ABORT", fmt.Sprintf("votes=%d quorum=%d", votes, quorum))
-		for _, peer := range n.Peers {
-			go func(p string) {
-				var ack bool
-				_ = n.callPeer(p, "NodeRPC.DecideBid", decision, &ack)
-			}(peer)
+		for reason, count := range rejections {
+			n.Metrics.Add(metricName("prepare_rejections_total", "reason", string(reason)), float64(count))
 		}
-		log.Printf("[%s] Txn %s aborted (votes=%d, quorum=%d)\n", n.ID, txnID, votes, quorum)
-		return false, fmt.Sprintf("Bid aborted: quorum not reached (%d/%d)", votes, quorum)
+		tally := formatRejections(rejections)
+		if cancelled {
+			tally += " cancelled"
+		}
+		if unlogged {
+			tally += " unlogged"
+		}
+		n.logTxnEvent(txnID, "TXN_ABORT", fmt.Sprintf("votes=%d quorum=%d no=[%s]", votes, quorum, tally))
+		if txnBid.Canary {
+			for _, peer := range peers {
+				p := peer
+				n.async.send(p, "DecideBid", func() error {
+					var ack bool
+					return n.callPeer(p, "NodeRPC.DecideBid", decision, &ack)
+				})
+			}
+		} else {
+			// A participant that misses the abort would hold the lot's
+			// reservation until the TTL; see delivery.go.
+			go n.sendDecision(txnID, decision)
+		}
+		log.Printf("[%s] Txn %s aborted (votes=%d, quorum=%d, no=[%s])\n", n.ID, txnID, votes, quorum, tally)
+		if cancelled {
+			n.Metrics.Inc("bids_cancelled_total")
+			return rejectBid(BidCancelled, cancelledMessage)
+		}
+		if unlogged {
+			return rejectBid(BidUnavailable, "Bid not placed: the coordinator could not record its decision; please try again")
+		}
+		if rejections[RejectItemChanged] > 0 {
+			return rejectBid(BidItemChanged, itemChangedMessage)
+		}
+		msg := fmt.Sprintf("Bid aborted: quorum not reached (%d/%d)", votes, quorum)
+		reason := dominantRejection(rejections)
+		if reason == RejectIncompatible {
+			msg += fmt.Sprintf(": peer %s speaks an incompatible protocol [%s]", strings.Join(incompatible, ", "), tally)
+		} else if reason != "" {
+			msg += fmt.Sprintf(": %s [%s]", reason.Message(), tally)
+		}
+		return rejectBid(bidCodeFor(reason), msg)
 	}
 
 	ackCount, allAcked, missingPeers := n.broadcastDecisionAndCollectAcks(txnID, decision)
+	if txnBid.Canary {
+		// Nothing visible changed, and a lost canary decision only leaves a
+		// prepared canary to expire, so there is nothing to push or retry.
+		return CoordinatorBidReply{Accepted: true, Code: BidCommitted,
+			Message: fmt.Sprintf("Canary committed; ACKs %d/%d", ackCount, len(peers))}
+	}
 
 	go n.broadcastQueueState()
 	// Anti-snipe: if a bid lands with less than 15s left, extend the deadline.
@@ -98,78 +340,267 @@ func (n *Node) ProposeBid(amount int, bidder string) (bool, string) {
 	log.Printf("[%s] Txn %s committed bid=%d bidder=%s\n", n.ID, txnID, amount, bidder)
 
 	if allAcked {
-		n.logTxnEvent(txnID, "TXN_TERMINATED", fmt.Sprintf("all participants ACKed (%d/%d)", ackCount, len(n.Peers)))
-		return true, "Bid committed by quorum and globally terminated"
+		n.terminateDecision(txnID)
+		n.logTxnEvent(txnID, "TXN_TERMINATED", fmt.Sprintf("all participants ACKed (%d/%d)", ackCount, len(peers)))
+		return CoordinatorBidReply{Accepted: true, Code: BidCommitted, Message: "Bid committed by quorum and globally terminated",
+			TxnID: txnID, Coordinator: n.ID, Term: term}
 	}
 
-	n.logTxnEvent(txnID, "TXN_TERMINATION_PENDING", fmt.Sprintf("ACKs=%d/%d missing=%s", ackCount, len(n.Peers), strings.Join(missingPeers, ",")))
-	go n.retryDecisionUntilAllAcked(txnID, decision, missingPeers)
-	return true, fmt.Sprintf("Bid committed by quorum; waiting for participant ACKs (%d/%d)", ackCount, len(n.Peers))
+	n.logTxnEvent(txnID, "TXN_TERMINATION_PENDING", fmt.Sprintf("ACKs=%d/%d missing=%s", ackCount, len(peers), strings.Join(missingPeers, ",")))
+	go n.deliverDecision(txnID, decision, missingPeers)
+	return CoordinatorBidReply{Accepted: true, Code: BidCommitted,
+		Message: fmt.Sprintf("Bid committed by quorum; waiting for participant ACKs (%d/%d)", ackCount, len(peers)),
+		TxnID:   txnID, Coordinator: n.ID, Term: term}
 }
 
-// canPrepareBid checks whether a bid is valid against current queue state.
-func (n *Node) canPrepareBid(bid BidArgs) bool {
+// canPrepareBid checks whether a bid is valid against current queue state
+// and, if not, says why.
+func (n *Node) canPrepareBid(bid BidArgs) (bool, PrepareRejection) {
+	if !n.IsReady() {
+		return false, RejectNodeNotReady
+	}
+	if bid.Canary {
+		return true, "" // touches no item; see canary.go
+	}
 	n.Queue.mu.Lock()
 	defer n.Queue.mu.Unlock()
-	return n.Queue.Active &&
-		n.Queue.CurrentItem != nil &&
-		bid.Amount > n.Queue.CurrentHighestBid &&
-		time.Now().Unix() < n.Queue.DeadlineUnix
+	switch {
+	case !n.Queue.Active:
+		return false, RejectAuctionInactive
+	case n.Queue.CurrentItem == nil:
+		return false, RejectNoCurrentItem
+	case bid.ItemID != "" && bid.ItemID != n.Queue.CurrentItem.ID:
+		return false, RejectItemChanged
+	case !n.Queue.CurrentItem.allows(bid.BidderID):
+		return false, RejectNotAllowed
+	case time.Now().Unix() >= n.Queue.DeadlineUnix:
+		return false, RejectDeadlinePassed
+	case bid.Amount <= n.Queue.CurrentHighestBid:
+		return false, RejectBidTooLow
+	}
+	if minBid, _ := n.minNextBidLocked(); bid.Amount < minBid {
+		return false, RejectBelowIncrement
+	}
+	if available, limited := n.Queue.availableForLocked(bid.BidderID, n.Queue.CurrentItem.ID); limited && bid.Amount > available {
+		return false, RejectOverBudget
+	}
+	return true, ""
+}
+
+// bidRejectionMessage is the bidder-facing text for a bid the coordinator
+// rejects before starting 2PC.
+func bidRejectionMessage(reason PrepareRejection) string {
+	switch reason {
+	case RejectBidTooLow:
+		return "Bid must be higher than current highest bid"
+	case RejectDeadlinePassed:
+		return "Bidding on this item has closed"
+	case RejectNoCurrentItem:
+		return "No item is currently up for bidding"
+	case RejectNodeNotReady:
+		return "Leader is not ready to take bids; retry shortly"
+	case RejectItemChanged:
+		return itemChangedMessage
+	case RejectNotAllowed:
+		return "This lot is invite-only and you are not on its bidder list"
+	case RejectOverBudget:
+		return "Bid exceeds your remaining budget"
+	default:
+		return "Auction is not running"
+	}
+}
+
+// dominantRejection returns the most common NO reason, preferring reasons
+// that describe auction state over unreachable peers on a tie.
+func dominantRejection(counts map[PrepareRejection]int) PrepareRejection {
+	var best PrepareRejection
+	for _, reason := range []PrepareRejection{
+		RejectItemChanged, RejectNotAllowed, RejectOverBudget, RejectIncompatible, RejectDeadlinePassed, RejectBidTooLow, RejectReserved,
+		RejectNoCurrentItem, RejectAuctionInactive, RejectUnknown, RejectUnreachable,
+	} {
+		if counts[reason] > counts[best] {
+			best = reason
+		}
+	}
+	return best
+}
+
+// formatRejections renders a tally like "deadline_passed=2 unreachable=1".
+func formatRejections(counts map[PrepareRejection]int) string {
+	parts := make([]string, 0, len(counts))
+	for reason, count := range counts {
+		parts = append(parts, fmt.Sprintf("%s=%d", reason, count))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
 }
 
 // rememberPendingTxn stores a prepared-but-not-yet-decided transaction.
-func (n *Node) rememberPendingTxn(txnID string, bid BidArgs) {
+// A prepared bid reserves its lot until its decision or preparedTxnTTL:
+// another transaction whose bid is not strictly higher is refused with
+// RejectReserved, and the txn holding the reservation returned, so two
+// coordinators cannot both gather a quorum for the same amount. Canary
+// bids neither reserve nor check. A txn whose decision was already
+// applied here is refused with RejectDecided.
+func (n *Node) rememberPendingTxn(txnID string, bid BidArgs) (PrepareRejection, string) {
 	n.TxnMutex.Lock()
+	if n.decisions.wasApplied(txnID) {
+		// The prepare lost a race with its own decision; holding it now
+		// would reserve the lot for a txn that is already over.
+		n.TxnMutex.Unlock()
+		return RejectDecided, ""
+	}
+	if holder := n.reservedByLocked(txnID, bid); holder != "" {
+		n.TxnMutex.Unlock()
+		return RejectReserved, holder
+	}
 	n.PendingTxns[txnID] = PendingTxn{Bid: bid, PreparedAt: time.Now()}
 	n.TxnMutex.Unlock()
-	n.logTxnEvent(txnID, "TXN_PREPARED", fmt.Sprintf("bid=%d bidder=%s", bid.Amount, bid.Bidder))
+	n.logTxnEvent(txnID, "TXN_PREPARED", fmt.Sprintf("bid=%d bidder=%s id=%s", bid.Amount, bid.DisplayName, bid.BidderID))
+	return "", ""
 }
 
-// applyDecision commits or aborts a transaction and updates queue state.
+// reservedByLocked returns the pending txn, other than txnID, holding a bid
+// on bid's lot at least as high, or "". A bid that names no lot, from an
+// older coordinator, is taken to be on every lot. Must hold TxnMutex.
+func (n *Node) reservedByLocked(txnID string, bid BidArgs) string {
+	if bid.Canary {
+		return ""
+	}
+	for id, pending := range n.PendingTxns {
+		p := pending.Bid
+		if id == txnID || p.Canary || p.Amount < bid.Amount {
+			continue
+		}
+		if p.ItemID == bid.ItemID || p.ItemID == "" || bid.ItemID == "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// applyDecision commits or aborts a transaction and updates queue state. A
+// decision that fails validateDecision changes nothing.
 func (n *Node) applyDecision(txnID string, commit bool, fallbackBid BidArgs) {
 	n.TxnMutex.Lock()
+	if n.decisions.wasApplied(txnID) {
+		// A retry that crossed our ACK; the bid it carries may be older
+		// than what the queue holds now.
+		n.TxnMutex.Unlock()
+		n.Metrics.Inc("decisions_duplicate_total")
+		n.logTxnEvent(txnID, "TXN_DECIDE_DUPLICATE", fmt.Sprintf("commit=%t already applied", commit))
+		return
+	}
 	pending, ok := n.PendingTxns[txnID]
 	bid := pending.Bid
 	if !ok {
 		bid = fallbackBid
 	}
+	if bid.Coordinator == "" {
+		// Prepared by an older coordinator; the decision may know better.
+		bid.Coordinator, bid.Term = fallbackBid.Coordinator, fallbackBid.Term
+	}
+	bid = bid.withIdentity()
+	if err := validateDecision(txnID, commit, bid); err != nil {
+		n.TxnMutex.Unlock()
+		source := fallbackBid.Coordinator // the decision's sender
+		if source == "" {
+			source = "txn " + txnID
+		}
+		n.rejectInvalid("decision", source, DecisionArgs{TxnID: txnID, Commit: commit, Bid: bid, Leader: fallbackBid.Coordinator, Term: fallbackBid.Term}, err)
+		return
+	}
 	delete(n.PendingTxns, txnID)
+	n.decisions.markApplied(txnID)
 	n.TxnMutex.Unlock()
+	n.recordDecision(txnID, commit, bid, false)
 
+	if bid.Canary {
+		if commit {
+			n.noteCanaryCommit()
+		}
+		return
+	}
 	if !commit {
-		n.logTxnEvent(txnID, "TXN_ABORT_APPLIED", fmt.Sprintf("bid=%d bidder=%s", bid.Amount, bid.Bidder))
+		n.logTxnEvent(txnID, "TXN_ABORT_APPLIED", fmt.Sprintf("bid=%d bidder=%s id=%s", bid.Amount, bid.DisplayName, bid.BidderID))
 		return
 	}
 
+	if !n.commitToQueue(txnID, bid) {
+		return
+	}
+	n.recordBid(txnID, bid, BidCommitted, "")
+	n.Metrics.Inc(metricName("bids_committed_by_origin_total", "origin", originLabel(bid.Origin)))
+	n.logTxnEvent(txnID, "TXN_COMMIT_APPLIED", fmt.Sprintf("bid=%d bidder=%s id=%s origin=%s coordinator=%s term=%d",
+		bid.Amount, bid.DisplayName, bid.BidderID, originLabel(bid.Origin), originLabel(bid.Coordinator), bid.Term))
+}
+
+// itemStillUp reports whether the lot itemID names is still up for bidding.
+// An empty itemID is a bid from a client that predates item pinning.
+func (n *Node) itemStillUp(itemID string) bool {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	return n.Queue.CurrentItem != nil && (itemID == "" || n.Queue.CurrentItem.ID == itemID)
+}
+
+// commitToQueue makes bid the highest bid if it is for the lot up and above
+// the current one. It reports false, and logs why, if the lot has changed.
+func (n *Node) commitToQueue(txnID string, bid BidArgs) bool {
 	n.Queue.mu.Lock()
+	if item := n.Queue.CurrentItem; bid.ItemID != "" && (item == nil || bid.ItemID != item.ID) {
+		n.Queue.mu.Unlock()
+		up := "no lot"
+		if item != nil {
+			up = item.ID
+		}
+		n.logTxnEvent(txnID, "TXN_COMMIT_SKIPPED", fmt.Sprintf("bid=%d for item %s but %s is up", bid.Amount, bid.ItemID, up))
+		return false
+	}
 	if n.Queue.Active && n.Queue.CurrentItem != nil && bid.Amount > n.Queue.CurrentHighestBid {
 		n.Queue.CurrentHighestBid = bid.Amount
-		n.Queue.CurrentWinner = bid.Bidder
+		n.Queue.CurrentWinner = bid.DisplayName
+		n.Queue.CurrentWinnerID = bid.BidderID
+		n.Queue.touchLocked()
 	}
 	n.Queue.mu.Unlock()
-	n.logTxnEvent(txnID, "TXN_COMMIT_APPLIED", fmt.Sprintf("bid=%d bidder=%s", bid.Amount, bid.Bidder))
+	return true
 }
 
-// abortStalePreparedTxns cleans up transactions that never received a decision (2PC timeout).
+// abortStalePreparedTxns settles transactions that never received a
+// decision (2PC timeout), asking the coordinators first; see decisionlog.go.
 func (n *Node) abortStalePreparedTxns() {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 	for range ticker.C {
 		now := time.Now()
+		stale := map[string]PendingTxn{}
 		n.TxnMutex.Lock()
 		for txnID, pending := range n.PendingTxns {
 			if now.Sub(pending.PreparedAt) > preparedTxnTTL {
-				delete(n.PendingTxns, txnID)
-				log.Printf("[%s] Auto-aborted stale txn %s\n", n.ID, txnID)
-				n.logTxnEvent(txnID, "TXN_STALE_ABORT", "prepared txn timed out before decision")
+				stale[txnID] = pending
 			}
 		}
 		n.TxnMutex.Unlock()
+		// Each asks its peers with a deadline; settle them side by side so
+		// one silent coordinator does not hold up the rest.
+		var wg sync.WaitGroup
+		for txnID, pending := range stale {
+			wg.Add(1)
+			go func(txnID string, pending PendingTxn) {
+				defer wg.Done()
+				n.resolveInDoubt(txnID, pending)
+			}(txnID, pending)
+		}
+		wg.Wait()
+		if err := n.decisions.compact(); err != nil {
+			log.Printf("[%s] Warning: could not compact the decision log: %v\n", n.ID, err)
+		}
 	}
 }
 
 func (n *Node) broadcastDecisionAndCollectAcks(txnID string, decision DecisionArgs) (int, bool, []string) {
-	if len(n.Peers) == 0 {
+	peers := n.peerList()
+	if len(peers) == 0 {
 		return 0, true, nil
 	}
 
@@ -177,19 +608,19 @@ func (n *Node) broadcastDecisionAndCollectAcks(txnID string, decision DecisionAr
 		peer string
 		ack  bool
 	}
-	ackCh := make(chan ackResult, len(n.Peers))
-	missing := make(map[string]bool, len(n.Peers))
-	for _, peer := range n.Peers {
+	ackCh := make(chan ackResult, len(peers))
+	missing := make(map[string]bool, len(peers))
+	for _, peer := range peers {
 		missing[peer] = true
-		go func(p string) {
-			var ack bool
-			err := n.callPeer(p, "NodeRPC.DecideBid", decision, &ack)
-			ackCh <- ackResult{peer: p, ack: err == nil && ack}
-		}(peer)
 	}
+	fanOut(peers, func(p string) {
+		var ack bool
+		err := n.callPeer(p, "NodeRPC.DecideBid", decision, &ack)
+		ackCh <- ackResult{peer: p, ack: err == nil && ack}
+	})
 
 	acks := 0
-	pending := len(n.Peers)
+	pending := len(peers)
 	timer := time.NewTimer(decisionAckWaitTimeout)
 	defer timer.Stop()
 
@@ -213,30 +644,3 @@ func (n *Node) broadcastDecisionAndCollectAcks(txnID string, decision DecisionAr
 	}
 	return acks, len(missingPeers) == 0, missingPeers
 }
-
-func (n *Node) retryDecisionUntilAllAcked(txnID string, decision DecisionArgs, missing []string) {
-	remaining := append([]string(nil), missing...)
-	for attempt := 1; attempt <= decisionAckMaxRetries && len(remaining) > 0; attempt++ {
-		time.Sleep(decisionAckRetryInterval)
-		nextRemaining := make([]string, 0, len(remaining))
-		for _, peer := range remaining {
-			var ack bool
-			err := n.callPeer(peer, "NodeRPC.DecideBid", decision, &ack)
-			if err == nil && ack {
-				n.logTxnEvent(txnID, "TXN_DECIDE_ACK_RETRY", fmt.Sprintf("peer=%s attempt=%d", peer, attempt))
-				continue
-			}
-			nextRemaining = append(nextRemaining, peer)
-		}
-		remaining = nextRemaining
-		if len(remaining) > 0 {
-			n.logTxnEvent(txnID, "TXN_TERMINATION_RETRY", fmt.Sprintf("attempt=%d remaining=%s", attempt, strings.Join(remaining, ",")))
-		}
-	}
-
-	if len(remaining) == 0 {
-		n.logTxnEvent(txnID, "TXN_TERMINATED", "all participants ACKed after retry")
-		return
-	}
-	n.logTxnEvent(txnID, "TXN_TERMINATION_INCOMPLETE", fmt.Sprintf("unacked participants=%s", strings.Join(remaining, ",")))
-}
@@ -0,0 +1,572 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// biddingNode returns a node with lot1 up for bidding at 10, writing its
+// logs under a temporary directory.
+func biddingNode(t testing.TB) *Node {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	return withLotUp(NewNode("T1", "127.0.0.1:9", nil, 1))
+}
+
+// withLotUp puts lot1 up for bidding on n at 10.
+func withLotUp(n *Node) *Node {
+	n.setPhase(PhaseReady, "test")
+	n.Queue.mu.Lock()
+	n.Queue.Active = true
+	n.Queue.CurrentItem = &AuctionItem{ID: "lot1", StartingPrice: 10}
+	n.Queue.CurrentHighestBid = 10
+	n.Queue.DeadlineUnix = time.Now().Add(time.Hour).Unix()
+	n.Queue.mu.Unlock()
+	return n
+}
+
+func highestBid(n *Node) int {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	return n.Queue.CurrentHighestBid
+}
+
+func commitBid(t *testing.T, rp *NodeRPC, txnID string, bid BidArgs) {
+	t.Helper()
+	var vote PrepareReply
+	if err := rp.PrepareBid(PrepareArgs{TxnID: txnID, Bid: bid}, &vote); err != nil || !vote.Vote {
+		t.Fatalf("prepare %s: vote=%v %s %v", txnID, vote.Vote, vote.Reason, err)
+	}
+	var ack bool
+	if err := rp.DecideBid(DecisionArgs{TxnID: txnID, Commit: true, Bid: bid}, &ack); err != nil || !ack {
+		t.Fatalf("decide %s: ack=%v %v", txnID, ack, err)
+	}
+}
+
+func TestApplyDecisionDuplicate(t *testing.T) {
+	n := biddingNode(t)
+	rp := &NodeRPC{node: n}
+	low := BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1", Coordinator: "C"}
+	high := BidArgs{BidderID: "b2", DisplayName: "Bob", Amount: 80, ItemID: "lot1", Coordinator: "C"}
+	commitBid(t, rp, "C-1", low)
+	commitBid(t, rp, "C-2", high)
+	committed := n.Metrics.Counter(metricName("bids_committed_by_origin_total", "origin", unknownOrigin))
+
+	// A retry of the first decision crosses the second commit.
+	var ack bool
+	if err := rp.DecideBid(DecisionArgs{TxnID: "C-1", Commit: true, Bid: low}, &ack); err != nil || !ack {
+		t.Fatalf("duplicate decide: ack=%v %v", ack, err)
+	}
+	if got := highestBid(n); got != 80 {
+		t.Errorf("highest bid after duplicate = %d, want 80", got)
+	}
+	if got := n.Metrics.Counter("decisions_duplicate_total"); got != 1 {
+		t.Errorf("decisions_duplicate_total = %v, want 1", got)
+	}
+	if got := n.Metrics.Counter(metricName("bids_committed_by_origin_total", "origin", unknownOrigin)); got != committed {
+		t.Errorf("commits counted = %v, want %v", got, committed)
+	}
+}
+
+func TestLatePrepareAfterDecision(t *testing.T) {
+	n := biddingNode(t)
+	rp := &NodeRPC{node: n}
+	bid := BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1", Coordinator: "C"}
+
+	// The abort overtakes its own prepare, which would still pass the
+	// auction checks.
+	var ack bool
+	if err := rp.DecideBid(DecisionArgs{TxnID: "C-1", Commit: false, Bid: bid}, &ack); err != nil || !ack {
+		t.Fatalf("decide: ack=%v %v", ack, err)
+	}
+	var vote PrepareReply
+	if err := rp.PrepareBid(PrepareArgs{TxnID: "C-1", Bid: bid}, &vote); err != nil {
+		t.Fatal(err)
+	}
+	if vote.Vote || vote.Rejection != RejectDecided {
+		t.Fatalf("late prepare: vote=%v rejection=%q, want NO %q", vote.Vote, vote.Rejection, RejectDecided)
+	}
+	n.TxnMutex.Lock()
+	_, pending := n.PendingTxns["C-1"]
+	n.TxnMutex.Unlock()
+	if pending {
+		t.Fatal("late prepare left a pending txn")
+	}
+
+	// The lot is not reserved: another txn for the same amount prepares.
+	var other PrepareReply
+	same := bid
+	same.BidderID = "b2"
+	if err := rp.PrepareBid(PrepareArgs{TxnID: "C-2", Bid: same}, &other); err != nil || !other.Vote {
+		t.Fatalf("prepare after late prepare: vote=%v %s %v", other.Vote, other.Reason, err)
+	}
+
+	// A retried decision is still a duplicate.
+	if err := rp.DecideBid(DecisionArgs{TxnID: "C-1", Commit: false, Bid: bid}, &ack); err != nil || !ack {
+		t.Fatalf("retried decide: ack=%v %v", ack, err)
+	}
+	if got := n.Metrics.Counter("decisions_duplicate_total"); got != 1 {
+		t.Errorf("decisions_duplicate_total = %v, want 1", got)
+	}
+}
+
+// prepareAll runs one coordinator's 2PC for txnID on the participants,
+// visiting them in order, and reports whether it committed and the
+// rejections it got. It commits on a majority of YES votes. It may run on
+// a goroutine of its own, so failures are reported with Error.
+func prepareAll(t *testing.T, participants []*NodeRPC, order []int, txnID string, bid BidArgs) (bool, []PrepareRejection) {
+	t.Helper()
+	yes := 0
+	var rejections []PrepareRejection
+	for _, i := range order {
+		var vote PrepareReply
+		if err := participants[i].PrepareBid(PrepareArgs{TxnID: txnID, Bid: bid}, &vote); err != nil {
+			t.Error(err)
+			return false, nil
+		}
+		if vote.Vote {
+			yes++
+		} else {
+			rejections = append(rejections, vote.Rejection)
+		}
+	}
+	commit := 2*yes > len(participants)
+	for _, rp := range participants {
+		var ack bool
+		if err := rp.DecideBid(DecisionArgs{TxnID: txnID, Commit: commit, Bid: bid}, &ack); err != nil || !ack {
+			t.Errorf("decide %s: ack=%v %v", txnID, ack, err)
+		}
+	}
+	return commit, rejections
+}
+
+func reservationCluster(t *testing.T) []*NodeRPC {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	var participants []*NodeRPC
+	for _, id := range []string{"P1", "P2", "P3"} {
+		participants = append(participants, &NodeRPC{node: withLotUp(NewNode(id, "127.0.0.1:9", nil, 1))})
+	}
+	return participants
+}
+
+func TestOverlappingPreparesAtEqualAmount(t *testing.T) {
+	participants := reservationCluster(t)
+	ann := BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1", Coordinator: "C1"}
+	bob := BidArgs{BidderID: "b2", DisplayName: "Bob", Amount: 50, ItemID: "lot1", Coordinator: "C2"}
+
+	// C1 reaches P1 and P2 first, C2 reaches P3 first: each holds one
+	// participant the other needs.
+	var vote PrepareReply
+	for _, i := range []int{0, 1} {
+		if err := participants[i].PrepareBid(PrepareArgs{TxnID: "C1-1", Bid: ann}, &vote); err != nil || !vote.Vote {
+			t.Fatalf("C1 prepare on P%d: vote=%v %s %v", i+1, vote.Vote, vote.Reason, err)
+		}
+	}
+	bobCommitted, bobRejections := prepareAll(t, participants, []int{2, 0, 1}, "C2-1", bob)
+	if bobCommitted {
+		t.Fatal("C2 committed a bid equal to one C1 holds on a majority")
+	}
+	if len(bobRejections) != 2 || bobRejections[0] != RejectReserved || bobRejections[1] != RejectReserved {
+		t.Errorf("C2 rejections = %v, want two %q", bobRejections, RejectReserved)
+	}
+	if err := participants[2].PrepareBid(PrepareArgs{TxnID: "C1-1", Bid: ann}, &vote); err != nil {
+		t.Fatal(err)
+	}
+	var ack bool
+	for _, rp := range participants {
+		if err := rp.DecideBid(DecisionArgs{TxnID: "C1-1", Commit: true, Bid: ann}, &ack); err != nil || !ack {
+			t.Fatalf("C1 decide: ack=%v %v", ack, err)
+		}
+	}
+	for i, rp := range participants {
+		rp.node.Queue.mu.Lock()
+		winner, amount := rp.node.Queue.CurrentWinnerID, rp.node.Queue.CurrentHighestBid
+		rp.node.Queue.mu.Unlock()
+		if winner != "b1" || amount != 50 {
+			t.Errorf("P%d: winner %s at %d, want b1 at 50", i+1, winner, amount)
+		}
+	}
+}
+
+func TestConcurrentPreparesCommitAtMostOne(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		participants := reservationCluster(t)
+		bids := []BidArgs{
+			{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1", Coordinator: "C1"},
+			{BidderID: "b2", DisplayName: "Bob", Amount: 50, ItemID: "lot1", Coordinator: "C2"},
+		}
+		orders := [][]int{{0, 1, 2}, {2, 1, 0}}
+		committed := make([]bool, len(bids))
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for c := range bids {
+			wg.Add(1)
+			go func(c int) {
+				defer wg.Done()
+				<-start
+				committed[c], _ = prepareAll(t, participants, orders[c], fmt.Sprintf("C%d-%d", c+1, round), bids[c])
+			}(c)
+		}
+		close(start)
+		wg.Wait()
+		if committed[0] && committed[1] {
+			t.Fatalf("round %d: both coordinators committed $50", round)
+		}
+		winners := map[string]bool{}
+		for _, rp := range participants {
+			rp.node.Queue.mu.Lock()
+			winners[rp.node.Queue.CurrentWinnerID] = true
+			rp.node.Queue.mu.Unlock()
+		}
+		if len(winners) != 1 {
+			t.Fatalf("round %d: participants disagree on the winner: %v", round, winners)
+		}
+	}
+}
+
+func TestCanPrepareBidReasons(t *testing.T) {
+	cases := []struct {
+		name  string
+		setup func(n *Node)
+		bid   BidArgs
+		want  PrepareRejection
+	}{
+		{"ok", func(*Node) {}, BidArgs{Amount: 20, ItemID: "lot1"}, ""},
+		{"inactive", func(n *Node) { n.Queue.Active = false }, BidArgs{Amount: 20}, RejectAuctionInactive},
+		{"no item", func(n *Node) { n.Queue.CurrentItem = nil }, BidArgs{Amount: 20}, RejectNoCurrentItem},
+		{"deadline", func(n *Node) { n.Queue.DeadlineUnix = time.Now().Add(-time.Second).Unix() }, BidArgs{Amount: 20}, RejectDeadlinePassed},
+		{"too low", func(*Node) {}, BidArgs{Amount: 10}, RejectBidTooLow},
+		{"other lot", func(*Node) {}, BidArgs{Amount: 20, ItemID: "lot9"}, RejectItemChanged},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			n := biddingNode(t)
+			n.Queue.mu.Lock()
+			c.setup(n)
+			n.Queue.mu.Unlock()
+			if ok, reason := n.canPrepareBid(c.bid); reason != c.want || ok != (c.want == "") {
+				t.Errorf("canPrepareBid = %v %q, want %q", ok, reason, c.want)
+			}
+		})
+	}
+	n := biddingNode(t)
+	n.setPhase(PhaseSyncing, "test")
+	if _, reason := n.canPrepareBid(BidArgs{Amount: 20}); reason != RejectNodeNotReady {
+		t.Errorf("syncing node: reason %q, want %q", reason, RejectNodeNotReady)
+	}
+}
+
+// votingPeer answers prepares with reason as its NO vote ("yes" votes yes,
+// "legacy" votes NO with no reason) and acknowledges decisions.
+func votingPeer(reason string) func(context.Context, string, interface{}) error {
+	return func(_ context.Context, method string, reply interface{}) error {
+		switch r := reply.(type) {
+		case *PrepareReply:
+			switch reason {
+			case "yes":
+				r.Vote = true
+			case "legacy":
+				r.Reason = "bid not higher, auction inactive, or time expired"
+			default:
+				r.Rejection = PrepareRejection(reason)
+				r.Reason = r.Rejection.Message()
+			}
+		case *bool:
+			*r = true
+		}
+		return nil
+	}
+}
+
+func TestAbortAggregatesPrepareRejections(t *testing.T) {
+	// Four nodes need all three peers, so any two NO votes decide the round.
+	cases := []struct {
+		name  string
+		votes []string // "" is a peer unreachable during prepare
+		code  BidCode
+		want  string
+	}{
+		{"deadline", []string{"deadline_passed", "deadline_passed", "yes"}, BidClosed,
+			"bidding deadline has passed [deadline_passed=2]"},
+		{"closed for mixed reasons", []string{"auction_inactive", "no_current_item", "yes"}, BidClosed,
+			"no item is up for bidding [auction_inactive=1 no_current_item=1]"},
+		{"outbid", []string{"bid_too_low", "bid_too_low", "yes"}, BidOutbid,
+			"bid is not higher than the current highest bid [bid_too_low=2]"},
+		{"unreachable", []string{"", "", "yes"}, BidNoQuorum, "participant unreachable [unreachable=2]"},
+		{"legacy peers", []string{"legacy", "legacy", "yes"}, BidRejected, "[unknown=2]"},
+		{"item changed", []string{"item_changed", "deadline_passed", "yes"}, BidItemChanged, itemChangedMessage},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{}}
+			peers := []string{"p1:1", "p2:1", "p3:1"}
+			for i, vote := range c.votes {
+				if vote != "" {
+					caller.peers[peers[i]] = votingPeer(vote)
+					continue
+				}
+				caller.peers[peers[i]] = func(_ context.Context, _ string, reply interface{}) error {
+					if ack, ok := reply.(*bool); ok {
+						*ack = true // back in time to hear the abort
+						return nil
+					}
+					return errors.New("connection refused")
+				}
+			}
+			n := withLotUp(electionNode(t, caller, peers...))
+			n.LateVoteGrace = 0
+			setLeader(n, n.ID, n.Address)
+
+			reply := n.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+			// The abort goes out after the reply; let it land before cleanup.
+			waitNoGoroutines(t, bidRoundGoroutines)
+			if reply.Code != c.code || !strings.Contains(reply.Message, c.want) {
+				t.Errorf("reply = %s %q, want %s containing %q", reply.Code, reply.Message, c.code, c.want)
+			}
+			tally := map[PrepareRejection]int{}
+			for _, vote := range c.votes {
+				switch vote {
+				case "":
+					tally[RejectUnreachable]++
+				case "legacy":
+					tally[RejectUnknown]++
+				case "yes":
+				default:
+					tally[PrepareRejection(vote)]++
+				}
+			}
+			for reason, count := range tally {
+				if got := n.Metrics.Counter(metricName("prepare_rejections_total", "reason", string(reason))); got != float64(count) {
+					t.Errorf("prepare_rejections_total{reason=%s} = %v, want %d", reason, got, count)
+				}
+			}
+		})
+	}
+}
+
+// interceptCaller passes calls on to the node's client. Calls of method
+// run before once, ahead of the first of them, and after once, before any
+// of them returns.
+type interceptCaller struct {
+	next                  peerCaller
+	method                string
+	before, after         func()
+	beforeOnce, afterOnce sync.Once
+	args                  interface{} // of the first call of method
+}
+
+func (c *interceptCaller) CallContext(ctx context.Context, address, method string, args interface{}, reply interface{}) error {
+	if method != c.method {
+		return c.next.CallContext(ctx, address, method, args, reply)
+	}
+	c.beforeOnce.Do(func() {
+		c.args = args
+		if c.before != nil {
+			c.before()
+		}
+	})
+	err := c.next.CallContext(ctx, address, method, args, reply)
+	c.afterOnce.Do(func() {
+		if c.after != nil {
+			c.after()
+		}
+	})
+	return err
+}
+
+// nextLot closes lot1 on n and puts lot2 up at 20, as startNextItem does.
+func nextLot(n *Node) {
+	n.Queue.mu.Lock()
+	n.Queue.CurrentItem = &AuctionItem{ID: "lot2", StartingPrice: 20}
+	n.Queue.CurrentHighestBid, n.Queue.CurrentWinner, n.Queue.CurrentWinnerID = 20, "", ""
+	n.Queue.touchLocked()
+	n.Queue.mu.Unlock()
+}
+
+func TestItemChangedMidRound(t *testing.T) {
+	cases := []struct {
+		name string
+		// flip switches lots on some nodes while the coordinator's first
+		// prepare is in flight; it returns the nodes now on lot2.
+		flip func(c *interceptCaller, nodes []*testNode) []*testNode
+	}{
+		{"participants move on before voting", func(c *interceptCaller, nodes []*testNode) []*testNode {
+			c.before = func() {
+				a := nodes[0]
+				nextLot(a.Node)
+				snap := a.buildQueueSnapshot()
+				for _, tn := range nodes[1:] {
+					tn.applyQueueSnapshot(snap, a.Address)
+				}
+			}
+			return nodes
+		}},
+		{"coordinator closes the lot as the votes arrive", func(c *interceptCaller, nodes []*testNode) []*testNode {
+			c.after = func() { nextLot(nodes[0].Node) }
+			return nodes[:1]
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodes := testCluster(t, "A", "B", "C")
+			a := nodes[0]
+			for _, tn := range nodes {
+				withLotUp(tn.Node)
+				setLeader(tn.Node, a.ID, a.Address)
+			}
+			leading(t, a.Node)
+			c := &interceptCaller{next: a.Client, method: "NodeRPC.PrepareBid"}
+			moved := tc.flip(c, nodes)
+			a.caller = c
+
+			reply := a.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+			if reply.Code != BidItemChanged || !strings.HasPrefix(reply.Message, "ITEM_CHANGED") {
+				t.Fatalf("reply = %s %q, want %s", reply.Code, reply.Message, BidItemChanged)
+			}
+			for _, tn := range nodes {
+				waitFor(t, tn.ID+" to settle the round", func() bool { return pendingTxns(tn.Node) == 0 })
+				want := 10
+				for _, m := range moved {
+					if m == tn {
+						want = 20
+					}
+				}
+				tn.Queue.mu.Lock()
+				price, winner := tn.Queue.CurrentHighestBid, tn.Queue.CurrentWinnerID
+				tn.Queue.mu.Unlock()
+				if price != want || winner != "" {
+					t.Errorf("%s: price %d won by %q, want %d with no winner", tn.ID, price, winner, want)
+				}
+				if _, ok := tn.bids.committed("b1", "lot1", 50); ok {
+					t.Errorf("%s recorded the bid as committed", tn.ID)
+				}
+			}
+			txnID := c.args.(PrepareArgs).TxnID
+			waitFor(t, "every participant to acknowledge the abort", func() bool {
+				return hasEvent(txnEvents(t, a.Node, txnID), "TXN_TERMINATED")
+			})
+		})
+	}
+}
+
+func TestAbortNamesIncompatiblePeer(t *testing.T) {
+	t.Chdir(t.TempDir())
+	drifted := serveAsNodeRPC(t, driftedRPC{})
+	n := withLotUp(NewNode("A", "127.0.0.1:9", []string{drifted}, 2))
+	t.Cleanup(n.Client.Close)
+	n.LateVoteGrace = 0
+	setLeader(n, n.ID, n.Address)
+	n.recordPeerVersion(drifted, &VersionInfo{NodeID: "Node4"})
+
+	reply := n.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+	waitNoGoroutines(t, bidRoundGoroutines)
+	if reply.Code != BidUnavailable || !strings.Contains(reply.Message, "peer Node4 speaks an incompatible protocol [incompatible_protocol=1]") {
+		t.Errorf("reply = %s %q, want unavailable naming Node4", reply.Code, reply.Message)
+	}
+	if got := n.Metrics.Counter(metricName("rpc_protocol_errors_total", "peer", drifted, "method", "PrepareBid")); got != 1 {
+		t.Errorf("rpc_protocol_errors_total{method=PrepareBid} = %v, want 1", got)
+	}
+	if got := n.Metrics.Counter(metricName("prepare_rejections_total", "reason", string(RejectIncompatible))); got != 1 {
+		t.Errorf("prepare_rejections_total{reason=incompatible_protocol} = %v, want 1", got)
+	}
+	waitFor(t, "the incompatible-protocol alert", func() bool {
+		for _, key := range delivered(n.Alerts) {
+			if key == "incompatible_protocol:"+drifted {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// hangingPeer blocks PrepareBid until the call's context ends, counting
+// the calls it saw end that way, and acknowledges decisions.
+func hangingPeer(ended *atomic.Int32) func(context.Context, string, interface{}) error {
+	return func(ctx context.Context, method string, reply interface{}) error {
+		if _, ok := reply.(*PrepareReply); !ok {
+			return votingPeer("yes")(ctx, method, reply)
+		}
+		<-ctx.Done()
+		ended.Add(1)
+		return ctx.Err()
+	}
+}
+
+func TestAbortedBidRequestStopsWaiting(t *testing.T) {
+	var ended atomic.Int32
+	caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{
+		"p1:1": hangingPeer(&ended),
+		"p2:1": hangingPeer(&ended),
+	}}
+	n := withLotUp(electionNode(t, caller, "p1:1", "p2:1"))
+	n.LateVoteGrace = 50 * time.Millisecond
+	setLeader(n, n.ID, n.Address)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/bid", strings.NewReader(url.Values{"bidder": {"Ann"}, "amount": {"50"}, "itemId": {"lot1"}}.Encode())).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		n.handleBidRequest(rec, req)
+		close(done)
+	}()
+	waitFor(t, "both prepares to be in flight", func() bool {
+		rounds := n.rounds.snapshot()
+		return len(rounds) == 1 && len(rounds[0].Awaiting) == 2
+	})
+	txnID := n.rounds.snapshot()[0].TxnID
+
+	// The client goes away long before the vote timeout, and the round
+	// stops waiting on the votes with it.
+	cancel()
+	<-done
+	deadline := time.Now().Add(voteWaitTimeout / 2)
+	for len(n.rounds.snapshot()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("round still waiting on votes after the client left")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := n.Metrics.Counter("bids_cancelled_total"); got != 1 || highestBid(n) != 10 {
+		t.Errorf("bids_cancelled_total = %v, highest bid %d; want 1 and 10", got, highestBid(n))
+	}
+	if events := txnEvents(t, n, txnID); !hasEvent(events, "TXN_ABORT") {
+		t.Errorf("events = %v, want TXN_ABORT", events)
+	}
+	waitNoGoroutines(t, bidRoundGoroutines)
+	if got := ended.Load(); got != 2 {
+		t.Errorf("%d prepare calls cancelled, want 2", got)
+	}
+}
+
+func TestQuorumCancelsStragglingPrepare(t *testing.T) {
+	var ended atomic.Int32
+	caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{
+		"p1:1": votingPeer("yes"),
+		"p2:1": votingPeer("yes"),
+		"p3:1": hangingPeer(&ended),
+	}}
+	n := withLotUp(electionNode(t, caller, "p1:1", "p2:1", "p3:1"))
+	n.LateVoteGrace = 50 * time.Millisecond
+	setLeader(n, n.ID, n.Address)
+
+	reply := n.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+	if reply.Code != BidCommitted {
+		t.Fatalf("reply = %s %q, want committed without p3", reply.Code, reply.Message)
+	}
+	waitNoGoroutines(t, bidRoundGoroutines)
+	if got := ended.Load(); got != 1 {
+		t.Errorf("p3's prepare cancelled %d times, want once", got)
+	}
+}
@@ -0,0 +1,84 @@
+package node
+
+// biddedup.go — Coordinator-side collapsing of identical concurrent bids.
+//
+// A user with two tabs on two nodes can have the same bid forwarded twice
+// within milliseconds. Rather than run a second 2PC round that can only
+// abort, the coordinator joins the duplicate onto the first round and hands
+// both callers the same outcome. Outcomes are remembered briefly so a
+// duplicate arriving just after the round finished is collapsed as well.
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const bidDedupWindow = 2 * time.Second
+
+type bidFlight struct {
+	done       chan struct{}
+	reply      CoordinatorBidReply
+	finishedAt time.Time
+}
+
+type bidDeduper struct {
+	mu      sync.Mutex
+	flights map[string]*bidFlight
+}
+
+// bidDedupKey identifies "the same bid". An explicit idempotency key wins;
+// otherwise it is (bidder ID, item, amount), so equal amounts from different
+// bidders are never merged.
+func (n *Node) bidDedupKey(bid BidArgs) string {
+	if bid.IdempotencyKey != "" {
+		return "key:" + bid.IdempotencyKey
+	}
+	return fmt.Sprintf("bid:%s|%s|%d", bid.BidderID, bid.ItemID, bid.Amount)
+}
+
+// dedupBid runs propose once per key within the dedup window; concurrent and
+// recent duplicates receive the first caller's result. A round cancelled by
+// its caller is not shared: waiting duplicates run their own.
+func (n *Node) dedupBid(ctx context.Context, key string, propose func() CoordinatorBidReply) CoordinatorBidReply {
+	d := &n.bidDedup
+	d.mu.Lock()
+	if d.flights == nil {
+		d.flights = map[string]*bidFlight{}
+	}
+	now := time.Now()
+	for k, f := range d.flights {
+		if !f.finishedAt.IsZero() && now.Sub(f.finishedAt) > bidDedupWindow {
+			delete(d.flights, k)
+		}
+	}
+	if f, ok := d.flights[key]; ok {
+		d.mu.Unlock()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return rejectBid(BidCancelled, cancelledMessage)
+		}
+		if f.reply.Code == BidCancelled {
+			return n.dedupBid(ctx, key, propose)
+		}
+		n.Metrics.Inc("bid_duplicates_collapsed_total")
+		return f.reply
+	}
+	f := &bidFlight{done: make(chan struct{})}
+	d.flights[key] = f
+	d.mu.Unlock()
+
+	reply := propose()
+
+	d.mu.Lock()
+	f.reply = reply
+	f.finishedAt = time.Now()
+	if reply.Code == BidCancelled {
+		delete(d.flights, key)
+	}
+	d.mu.Unlock()
+	close(f.done)
+	return reply
+}
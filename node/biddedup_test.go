@@ -0,0 +1,105 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestDuplicateBidsThroughTwoNodesCollapse(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+
+	// One bidder, two tabs, two nodes.
+	bid := BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"}
+	replies := make([]CoordinatorBidReply, 2)
+	var wg sync.WaitGroup
+	for i, via := range []*testNode{b, c} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replies[i] = via.submitBid(context.Background(), bid)
+		}()
+	}
+	wg.Wait()
+
+	for i, r := range replies {
+		if r.Code != BidCommitted {
+			t.Fatalf("reply %d = %s %q, want committed", i, r.Code, r.Message)
+		}
+	}
+	if replies[0].TxnID != replies[1].TxnID {
+		t.Errorf("duplicates ran two rounds: %s and %s", replies[0].TxnID, replies[1].TxnID)
+	}
+	if got := a.Metrics.Counter("bid_duplicates_collapsed_total"); got != 1 {
+		t.Errorf("bid_duplicates_collapsed_total = %v, want 1", got)
+	}
+}
+
+func TestEqualBidsFromDifferentBiddersNotCollapsed(t *testing.T) {
+	n := biddingNode(t)
+	leading(t, n)
+	first := n.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+	second := n.ProposeBid(context.Background(), BidArgs{BidderID: "b2", DisplayName: "Bob", Amount: 50, ItemID: "lot1"})
+	if first.Code != BidCommitted {
+		t.Fatalf("first bid = %s %q", first.Code, first.Message)
+	}
+	if second.Code == BidCommitted || second.TxnID == first.TxnID {
+		t.Errorf("second bidder's equal bid = %s %q (txn %s), want its own rejection", second.Code, second.Message, second.TxnID)
+	}
+	if got := n.Metrics.Counter("bid_duplicates_collapsed_total"); got != 0 {
+		t.Errorf("bid_duplicates_collapsed_total = %v, want 0", got)
+	}
+}
+
+func TestBidDedupKey(t *testing.T) {
+	n := &Node{}
+	bid := BidArgs{BidderID: "b1", ItemID: "lot1", Amount: 50}
+	other := bid
+	other.BidderID = "b2"
+	if n.bidDedupKey(bid) == n.bidDedupKey(other) {
+		t.Error("different bidders share a dedup key")
+	}
+	// An explicit key overrides the content, both ways.
+	keyed, keyedOther := bid, other
+	keyed.IdempotencyKey, keyedOther.IdempotencyKey = "k1", "k1"
+	if n.bidDedupKey(keyed) != n.bidDedupKey(keyedOther) {
+		t.Error("bids with one idempotency key have different dedup keys")
+	}
+	keyedOther.IdempotencyKey = "k2"
+	keyedOther.BidderID = "b1"
+	if n.bidDedupKey(keyed) == n.bidDedupKey(keyedOther) {
+		t.Error("bids with different idempotency keys share a dedup key")
+	}
+}
+
+func TestDedupBidDoesNotShareCancelledRound(t *testing.T) {
+	n := biddingNode(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	first := make(chan CoordinatorBidReply, 1)
+	go func() {
+		first <- n.dedupBid(context.Background(), "k", func() CoordinatorBidReply {
+			close(started)
+			<-release
+			return rejectBid(BidCancelled, cancelledMessage)
+		})
+	}()
+	<-started
+	second := make(chan CoordinatorBidReply, 1)
+	go func() {
+		second <- n.dedupBid(context.Background(), "k", func() CoordinatorBidReply {
+			return CoordinatorBidReply{Accepted: true, Code: BidCommitted}
+		})
+	}()
+	close(release)
+	if r := <-first; r.Code != BidCancelled {
+		t.Errorf("first = %s", r.Code)
+	}
+	if r := <-second; r.Code != BidCommitted {
+		t.Errorf("duplicate of a cancelled round = %s, want its own round's result", r.Code)
+	}
+}
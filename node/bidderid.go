@@ -0,0 +1,83 @@
+package node
+
+// bidderid.go — Stable bidder identity, separate from the display name.
+//
+// Enforcement keys on BidderID; only the UI and logs use DisplayName. Browsers
+// get a per-session ID in the bidder_id cookie. Clients and peers that predate
+// the split send only Bidder, and get an ID derived from that name. Bidders
+// who give no name are shown as "Guest-xxxx", derived from their BidderID,
+// never as the node that happened to receive the bid.
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+)
+
+const bidderIDCookie = "bidder_id"
+
+// withIdentity fills in whichever of Bidder/DisplayName/BidderID is missing so
+// old and new peers interoperate: Bidder always mirrors DisplayName.
+func (b BidArgs) withIdentity() BidArgs {
+	if b.DisplayName == "" {
+		b.DisplayName = b.Bidder
+	}
+	b.Bidder = b.DisplayName
+	if b.BidderID == "" && b.DisplayName != "" {
+		b.BidderID = legacyBidderID(b.DisplayName)
+	}
+	return b
+}
+
+// legacyBidderID derives a deterministic ID from a name for clients that only
+// send Bidder. Two people typing the same name still collide here, which is
+// exactly the pre-split behaviour.
+func legacyBidderID(name string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.ToLower(strings.TrimSpace(name))))
+	return "legacy-" + hex.EncodeToString(h.Sum(nil))
+}
+
+// guestName is the display name for a bidder who gave none. It is derived
+// from the bidder ID, so one session keeps the same guest name on every node.
+func guestName(bidderID string) string {
+	if bidderID == "" {
+		bidderID = newSessionBidderID()
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(bidderID))
+	return fmt.Sprintf("Guest-%04x", h.Sum32()&0xffff)
+}
+
+// newSessionBidderID returns a random ID for an anonymous browser session.
+func newSessionBidderID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return "anon-" + hex.EncodeToString(b)
+}
+
+// sessionBidderID returns the caller's bidder ID from the bidder_id cookie,
+// issuing a new session cookie when there is none. Returns "" if no ID could
+// be generated; withIdentity then falls back to a name-derived ID.
+func sessionBidderID(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(bidderIDCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+	id := newSessionBidderID()
+	if id == "" {
+		return ""
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     bidderIDCookie,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
@@ -0,0 +1,165 @@
+package node
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithIdentity(t *testing.T) {
+	cases := []struct {
+		name string
+		in   BidArgs
+		want BidArgs
+	}{
+		{"legacy", BidArgs{Bidder: "Sam"},
+			BidArgs{Bidder: "Sam", DisplayName: "Sam", BidderID: legacyBidderID("Sam")}},
+		{"split", BidArgs{BidderID: "b-1", DisplayName: "Sam"},
+			BidArgs{Bidder: "Sam", DisplayName: "Sam", BidderID: "b-1"}},
+		{"display name wins", BidArgs{Bidder: "old", BidderID: "b-1", DisplayName: "Sam"},
+			BidArgs{Bidder: "Sam", DisplayName: "Sam", BidderID: "b-1"}},
+		{"anonymous", BidArgs{}, BidArgs{}},
+	}
+	for _, c := range cases {
+		if got := c.in.withIdentity(); got != c.want {
+			t.Errorf("%s: %+v.withIdentity() = %+v, want %+v", c.name, c.in, got, c.want)
+		}
+	}
+	if legacyBidderID(" SAM ") != legacyBidderID("sam") {
+		t.Error("legacy IDs depend on case or padding")
+	}
+}
+
+// legacyBidArgs is BidArgs as sent by a build from before the split.
+type legacyBidArgs struct {
+	Amount int
+	Bidder string
+	ItemID string
+}
+
+func TestDecodeLegacyBidArgs(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(legacyBidArgs{Amount: 50, Bidder: "Sam", ItemID: "lot1"}); err != nil {
+		t.Fatal(err)
+	}
+	var bid BidArgs
+	if err := gob.NewDecoder(&buf).Decode(&bid); err != nil {
+		t.Fatal(err)
+	}
+	bid = bid.withIdentity()
+	if bid.Amount != 50 || bid.DisplayName != "Sam" || bid.BidderID != legacyBidderID("Sam") {
+		t.Errorf("decoded legacy bid = %+v", bid)
+	}
+
+	// And a new bid still reads on an old peer, which knows only Bidder.
+	buf.Reset()
+	if err := gob.NewEncoder(&buf).Encode(BidArgs{Amount: 60, BidderID: "b-1", DisplayName: "Sam"}.withIdentity()); err != nil {
+		t.Fatal(err)
+	}
+	var old legacyBidArgs
+	if err := gob.NewDecoder(&buf).Decode(&old); err != nil {
+		t.Fatal(err)
+	}
+	if old.Bidder != "Sam" || old.Amount != 60 {
+		t.Errorf("old peer decoded %+v", old)
+	}
+}
+
+func TestSameNameBiddersKeptApart(t *testing.T) {
+	n := biddingNode(t)
+	rp := &NodeRPC{node: n}
+	commitBid(t, rp, "C-1", BidArgs{BidderID: "b-1", DisplayName: "Sam", Amount: 50, ItemID: "lot1"})
+	commitBid(t, rp, "C-2", BidArgs{BidderID: "b-2", DisplayName: "Sam", Amount: 60, ItemID: "lot1"})
+
+	n.Queue.mu.Lock()
+	winner, winnerID := n.Queue.CurrentWinner, n.Queue.CurrentWinnerID
+	n.Queue.mu.Unlock()
+	if winner != "Sam" || winnerID != "b-2" {
+		t.Errorf("leader = %s/%s, want Sam/b-2", winner, winnerID)
+	}
+	for _, id := range []string{"b-1", "b-2"} {
+		if total, _ := n.bids.page(id, 0, 10); total != 1 {
+			t.Errorf("%s has %d bids on record, want 1", id, total)
+		}
+	}
+}
+
+func TestSessionBidderID(t *testing.T) {
+	w := httptest.NewRecorder()
+	id := sessionBidderID(w, httptest.NewRequest(http.MethodPost, "/bid", nil))
+	if !strings.HasPrefix(id, "anon-") {
+		t.Fatalf("new session ID = %q", id)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != bidderIDCookie || cookies[0].Value != id {
+		t.Fatalf("cookies = %v, want %s=%s", cookies, bidderIDCookie, id)
+	}
+
+	// The cookie is reused, not reissued.
+	r := httptest.NewRequest(http.MethodPost, "/bid", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	if got := sessionBidderID(w, r); got != id {
+		t.Errorf("ID with the cookie = %q, want %q", got, id)
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("cookie reissued")
+	}
+	if guestName(id) != guestName(id) || !strings.HasPrefix(guestName(id), "Guest-") {
+		t.Errorf("guest name %q is not stable", guestName(id))
+	}
+}
+
+func TestAnonymousBidsFromTwoNodes(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a := nodes[0]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	// Each bidder sends /bid, with no name, to a different follower.
+	bid := func(n *Node, amount string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/bid", strings.NewReader("itemId=lot1&amount="+amount))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		n.handleBidRequest(w, r)
+		if got := w.Result().Header.Get("X-Bid-Outcome"); got != string(BidCommitted) {
+			t.Fatalf("bid of %s via %s = %d %s %s", amount, n.ID, w.Code, got, w.Body)
+		}
+		if origin := w.Result().Header.Get("X-Bid-Origin"); origin != n.ID {
+			t.Errorf("X-Bid-Origin = %q, want %s", origin, n.ID)
+		}
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("cookies = %v, want the session cookie", cookies)
+		}
+		return cookies[0].Value
+	}
+	first := bid(nodes[1].Node, "50")
+	second := bid(nodes[2].Node, "60")
+	if first == second || guestName(first) == guestName(second) {
+		t.Fatalf("both bidders got %s (%s)", first, guestName(first))
+	}
+
+	for _, tn := range nodes {
+		waitFor(t, tn.ID+" to apply both bids", func() bool { return highestBid(tn.Node) == 60 })
+		tn.Queue.mu.Lock()
+		winner, winnerID := tn.Queue.CurrentWinner, tn.Queue.CurrentWinnerID
+		tn.Queue.mu.Unlock()
+		if winner != guestName(second) || winnerID != second {
+			t.Errorf("%s shows the winner as %s/%s, want %s/%s", tn.ID, winner, winnerID, guestName(second), second)
+		}
+	}
+	// The node the bid came through is kept for the audit views only.
+	for id, want := range map[string]string{first: "B", second: "C"} {
+		amount := map[string]int{first: 50, second: 60}[id]
+		rec, ok := a.bids.committed(id, "lot1", amount)
+		if !ok || rec.Origin != want || rec.Name != guestName(id) {
+			t.Errorf("record of %s = %+v, want origin %s shown as %s", id, rec, want, guestName(id))
+		}
+	}
+}
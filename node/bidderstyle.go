@@ -0,0 +1,60 @@
+package node
+
+// bidderstyle.go — Stable per-bidder color and avatar, derived server-side so
+// every node renders the same bidder identically.
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// BidderStyle is the display decoration for one bidder.
+type BidderStyle struct {
+	Color  string `json:"color"`
+	Avatar string `json:"avatar"`
+}
+
+var bidderPalette = []string{
+	"#ff9f0a", "#30d158", "#0a84ff", "#bf5af2", "#ff375f", "#64d2ff",
+	"#ffd60a", "#ac8e68", "#5e5ce6", "#ff6961", "#66d4cf", "#a2845e",
+}
+
+var bidderAvatars = []string{
+	"🦊", "🐼", "🐯", "🦉", "🐙", "🦁", "🐸", "🐨", "🦄", "🐝", "🐧", "🦋",
+}
+
+// bidderStyleFor derives a style from the bidder's identity. The mapping is
+// pure (FNV-1a of the normalized ID) so all nodes agree without coordination.
+func bidderStyleFor(bidderID string) BidderStyle {
+	key := strings.ToLower(strings.TrimSpace(bidderID))
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum32()
+	return BidderStyle{
+		Color:  bidderPalette[sum%uint32(len(bidderPalette))],
+		Avatar: bidderAvatars[(sum/uint32(len(bidderPalette)))%uint32(len(bidderAvatars))],
+	}
+}
+
+// bidderStylesLocked collects styles for every bidder named in the queue
+// state (current leader and past winners), keyed by display name but derived
+// from the bidder ID. Must hold Queue.mu.
+func (n *Node) bidderStylesLocked() map[string]BidderStyle {
+	styles := map[string]BidderStyle{}
+	add := func(name, id string) {
+		if name == "" || name == "No bids" {
+			return
+		}
+		if id == "" {
+			id = legacyBidderID(name)
+		}
+		if _, ok := styles[name]; !ok {
+			styles[name] = bidderStyleFor(id)
+		}
+	}
+	add(n.Queue.CurrentWinner, n.Queue.CurrentWinnerID)
+	for _, r := range n.Queue.Results {
+		add(r.Winner, r.WinnerID)
+	}
+	return styles
+}
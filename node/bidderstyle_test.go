@@ -0,0 +1,55 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBidderStyleStable(t *testing.T) {
+	a := bidderStyleFor("b-ann")
+	if got := bidderStyleFor("  B-Ann "); got != a {
+		t.Errorf("style of a re-cased ID = %+v, want %+v", got, a)
+	}
+	if a.Color == "" || a.Avatar == "" {
+		t.Fatalf("empty style %+v", a)
+	}
+	// Not every bidder looks alike.
+	seen := map[BidderStyle]bool{}
+	for _, id := range []string{"b1", "b2", "b3", "b4", "b5", "b6", "b7", "b8"} {
+		seen[bidderStyleFor(id)] = true
+	}
+	if len(seen) < 4 {
+		t.Errorf("8 bidders share %d styles", len(seen))
+	}
+}
+
+func TestBidderStylesAgreeAcrossNodes(t *testing.T) {
+	leader := biddingNode(t)
+	follower := NewNode("T2", "127.0.0.1:10", nil, 2)
+	leader.Queue.mu.Lock()
+	leader.Queue.CurrentHighestBid, leader.Queue.CurrentWinner, leader.Queue.CurrentWinnerID = 40, "Ann", "b-ann"
+	leader.Queue.Results = []ItemResult{
+		{Item: AuctionItem{ID: "lot0"}, Winner: "Bob", WinnerID: "b-bob", WinningBid: 70},
+		{Item: AuctionItem{ID: "lot00"}, Winner: "Carol", WinningBid: 20}, // from before bidder IDs
+		{Item: AuctionItem{ID: "lot000"}, Winner: "No bids"},
+	}
+	leader.Queue.mu.Unlock()
+
+	if !follower.applyQueueSnapshot(leader.buildQueueSnapshot(), "test") {
+		t.Fatal("snapshot rejected")
+	}
+	follower.Queue.mu.Lock()
+	got := follower.bidderStylesLocked()
+	follower.Queue.mu.Unlock()
+	want := map[string]BidderStyle{
+		"Ann":   bidderStyleFor("b-ann"),
+		"Bob":   bidderStyleFor("b-bob"),
+		"Carol": bidderStyleFor(legacyBidderID("Carol")),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("follower styles = %v, want %v", got, want)
+	}
+	if snap := leader.buildQueueSnapshot(); !reflect.DeepEqual(snap.BidderStyles, got) {
+		t.Errorf("leader styles = %v, follower %v", snap.BidderStyles, got)
+	}
+}
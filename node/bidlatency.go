@@ -0,0 +1,73 @@
+package node
+
+// bidlatency.go — Per-stage latency of the bid path, exported as the
+// bid_stage_seconds histogram.
+//
+// Stages: forward (follower → coordinator round trip, measured on the
+// follower), ra_acquire (waiting for the Ricart–Agrawala critical section),
+// prepare (collecting 2PC votes until quorum is decided), decide (applying
+// and broadcasting the decision, including ACK collection on commit) and
+// total (the coordinator's whole proposeBid). The histograms are resolved
+// once in NewNode; timing a bid costs a bidTimer on the stack. Canary rounds
+// are timed separately, as canary_stage_seconds.
+
+import "time"
+
+type bidStage int
+
+const (
+	stageForward bidStage = iota
+	stageRAAcquire
+	stagePrepare
+	stageDecide
+	stageTotal
+	numBidStages
+)
+
+var bidStageNames = [numBidStages]string{"forward", "ra_acquire", "prepare", "decide", "total"}
+
+type bidStageHistograms [numBidStages]*Histogram
+
+func newBidStageHistograms(m *Metrics, base string) bidStageHistograms {
+	var h bidStageHistograms
+	for s, name := range bidStageNames {
+		h[s] = m.Histogram(metricName(base, "stage", name))
+	}
+	return h
+}
+
+// bidTimer measures consecutive stages of one bid.
+type bidTimer struct {
+	hist  *bidStageHistograms
+	start time.Time
+	last  time.Time
+}
+
+func (n *Node) startBidTimer() bidTimer {
+	now := time.Now()
+	return bidTimer{hist: &n.bidStages, start: now, last: now}
+}
+
+// startCanaryTimer times a canary round into canary_stage_seconds instead.
+func (n *Node) startCanaryTimer() bidTimer {
+	t := n.startBidTimer()
+	t.hist = &n.canaryStages
+	return t
+}
+
+// lap records the time since the previous lap (or the start) as stage s.
+func (t *bidTimer) lap(s bidStage) {
+	now := time.Now()
+	t.hist[s].Observe(now.Sub(t.last).Seconds())
+	t.last = now
+}
+
+// skip starts the next stage now without recording the time since the last lap.
+func (t *bidTimer) skip() {
+	t.last = time.Now()
+}
+
+// finish records the time since the start as the total.
+func (t *bidTimer) finish() {
+	t.hist[stageTotal].Observe(time.Since(t.start).Seconds())
+}
@@ -0,0 +1,97 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// stageStats returns the count and sum of n's bid_stage_seconds for stage.
+func stageStats(n *Node, stage string) (uint64, float64) {
+	h := n.Metrics.Histogram(metricName("bid_stage_seconds", "stage", stage))
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count, h.sum
+}
+
+func TestHistogramExposition(t *testing.T) {
+	m := NewMetrics()
+	h := m.Histogram(metricName("bid_stage_seconds", "stage", "prepare"))
+	for _, v := range []float64{0.0005, 0.004, 0.004, 0.3, 20} {
+		h.Observe(v)
+	}
+	var out bytes.Buffer
+	m.WritePrometheus(&out)
+	for _, want := range []string{
+		"# TYPE bid_stage_seconds histogram",
+		`bid_stage_seconds_bucket{stage="prepare",le="0.001"} 1`,
+		`bid_stage_seconds_bucket{stage="prepare",le="0.005"} 3`,
+		`bid_stage_seconds_bucket{stage="prepare",le="0.25"} 3`,
+		`bid_stage_seconds_bucket{stage="prepare",le="0.5"} 4`,
+		`bid_stage_seconds_bucket{stage="prepare",le="10"} 4`,
+		`bid_stage_seconds_bucket{stage="prepare",le="+Inf"} 5`,
+		`bid_stage_seconds_sum{stage="prepare"} 20.3085`,
+		`bid_stage_seconds_count{stage="prepare"} 5`,
+	} {
+		if !strings.Contains(out.String(), want+"\n") {
+			t.Errorf("exposition lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestBidStageLatency(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	// B is slow to answer the coordinator, and its vote is needed.
+	a.Client.SetLatency(LatencyRule{Peer: b.Address, DelayMs: 60})
+
+	reply := b.submitBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1", Origin: b.ID})
+	if reply.Code != BidCommitted {
+		t.Fatalf("bid = %s %q, want committed", reply.Code, reply.Message)
+	}
+
+	// The follower times the forward; the coordinator times the rest.
+	if count, sum := stageStats(b.Node, "forward"); count != 1 || sum < 0.06 {
+		t.Errorf("B forward: %d observation(s) totalling %.3fs, want 1 of at least 0.06s", count, sum)
+	}
+	if count, _ := stageStats(a.Node, "forward"); count != 0 {
+		t.Errorf("A timed %d forward(s), want 0", count)
+	}
+	var stages float64
+	for _, stage := range []string{"ra_acquire", "prepare", "decide"} {
+		count, sum := stageStats(a.Node, stage)
+		if count != 1 {
+			t.Errorf("A %s: %d observation(s), want 1", stage, count)
+		}
+		stages += sum
+	}
+	_, prepare := stageStats(a.Node, "prepare")
+	count, total := stageStats(a.Node, "total")
+	if count != 1 || prepare < 0.06 || stages > total {
+		t.Errorf("A total %d×%.3fs with prepare %.3fs and stages summing to %.3fs; want prepare ≥ 0.06s within the total", count, total, prepare, stages)
+	}
+	if _, forward := stageStats(b.Node, "forward"); forward < total {
+		t.Errorf("B's forward (%.3fs) is shorter than A's total (%.3fs)", forward, total)
+	}
+}
+
+func TestBidTimerDoesNotAllocate(t *testing.T) {
+	n := biddingNode(t)
+	allocs := testing.AllocsPerRun(100, func() {
+		timer := n.startBidTimer()
+		timer.skip()
+		timer.lap(stageRAAcquire)
+		timer.lap(stagePrepare)
+		timer.lap(stageDecide)
+		timer.finish()
+	})
+	if allocs != 0 {
+		t.Errorf("timing a bid allocates %v times, want 0", allocs)
+	}
+}
@@ -0,0 +1,114 @@
+package node
+
+// bidoutcome.go — Bid outcome codes and their HTTP statuses.
+//
+// The coordinator classifies every bid it answers and returns the code in
+// CoordinatorBidReply, so a follower forwarding the bid reports the same
+// status the coordinator would have. Clients can retry on 503, refresh on
+// 409 and show 422 to the bidder as-is. A 504 means the outcome is unknown,
+// so the client should check the bidder's bids before sending it again.
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// BidCode classifies the outcome of a bid.
+type BidCode string
+
+const (
+	BidCommitted      BidCode = "committed"
+	BidInvalid        BidCode = "invalid"         // malformed request
+	BidOutbid         BidCode = "outbid"          // not above the current highest bid
+	BidBelowIncrement BidCode = "below_increment" // above it, but by less than the increment
+	BidClosed         BidCode = "closed"          // no lot open: auction stopped, deadline passed, or sold
+	BidItemChanged    BidCode = "item_changed"    // aimed at a lot that is no longer up
+	BidNotAllowed     BidCode = "not_allowed"     // bidder is not on an invite-only lot's list
+	BidOverBudget     BidCode = "over_budget"     // more than the bidder's remaining budget
+	BidRejected       BidCode = "rejected"        // participants voted NO for another reason
+	BidNotReady       BidCode = "not_ready"       // node or leader still syncing, or draining
+	BidNoLeader       BidCode = "no_leader"       // election in progress or leader unreachable
+	BidNoQuorum       BidCode = "no_quorum"       // too few participants reachable
+	BidUnavailable    BidCode = "unavailable"     // writes disabled, e.g. mixed protocol versions
+	BidCancelled      BidCode = "cancelled"       // the request ended before the vote finished; nothing changed
+	BidUnknown        BidCode = "unknown"         // the leader stopped answering mid-request; the bid may have committed
+)
+
+// bidRetryAfterSec is the Retry-After hint sent with 503 bid responses.
+const bidRetryAfterSec = 1
+
+// HTTPStatus maps a code to the status /bid answers with.
+func (c BidCode) HTTPStatus() int {
+	switch c {
+	case BidCommitted:
+		return http.StatusOK
+	case BidInvalid:
+		return http.StatusBadRequest
+	case BidItemChanged:
+		return http.StatusConflict
+	case BidNotAllowed:
+		return http.StatusForbidden
+	case BidNotReady, BidNoLeader, BidNoQuorum, BidUnavailable, BidCancelled:
+		return http.StatusServiceUnavailable
+	case BidUnknown:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// bidCodeFor classifies a 2PC rejection reason.
+func bidCodeFor(reason PrepareRejection) BidCode {
+	switch reason {
+	case RejectBidTooLow:
+		return BidOutbid
+	case RejectBelowIncrement:
+		return BidBelowIncrement
+	case RejectAuctionInactive, RejectNoCurrentItem, RejectDeadlinePassed:
+		return BidClosed
+	case RejectItemChanged:
+		return BidItemChanged
+	case RejectNotAllowed:
+		return BidNotAllowed
+	case RejectOverBudget:
+		return BidOverBudget
+	case RejectNodeNotReady:
+		return BidNotReady
+	case RejectUnreachable, RejectReserved:
+		// A reservation ends with its decision or the prepared-txn TTL, so
+		// a retry can succeed.
+		return BidNoQuorum
+	case RejectIncompatible:
+		return BidUnavailable
+	default:
+		return BidRejected
+	}
+}
+
+// withCode fills in Code for replies from coordinators that predate it.
+func (r CoordinatorBidReply) withCode() CoordinatorBidReply {
+	if r.Code == "" {
+		r.Code = BidRejected
+		if r.Accepted {
+			r.Code = BidCommitted
+		}
+	}
+	return r
+}
+
+// writeBidReply answers /bid with the status for reply's code. The code is
+// also sent as X-Bid-Outcome for clients that want more than the status.
+func writeBidReply(w http.ResponseWriter, reply CoordinatorBidReply) {
+	reply = reply.withCode()
+	status := reply.Code.HTTPStatus()
+	w.Header().Set("X-Bid-Outcome", string(reply.Code))
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", strconv.Itoa(bidRetryAfterSec))
+	}
+	if status != http.StatusOK {
+		http.Error(w, reply.Message, status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(reply.Message))
+}
@@ -0,0 +1,156 @@
+package node
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// bidStatuses is the status /bid answers with for every outcome.
+var bidStatuses = []struct {
+	code   BidCode
+	status int
+}{
+	{BidCommitted, http.StatusOK},
+	{BidInvalid, http.StatusBadRequest},
+	{BidOutbid, http.StatusUnprocessableEntity},
+	{BidBelowIncrement, http.StatusUnprocessableEntity},
+	{BidClosed, http.StatusUnprocessableEntity},
+	{BidOverBudget, http.StatusUnprocessableEntity},
+	{BidRejected, http.StatusUnprocessableEntity},
+	{BidItemChanged, http.StatusConflict},
+	{BidNotAllowed, http.StatusForbidden},
+	{BidNotReady, http.StatusServiceUnavailable},
+	{BidNoLeader, http.StatusServiceUnavailable},
+	{BidNoQuorum, http.StatusServiceUnavailable},
+	{BidUnavailable, http.StatusServiceUnavailable},
+	{BidCancelled, http.StatusServiceUnavailable},
+	{BidUnknown, http.StatusGatewayTimeout},
+}
+
+// expectBidReply checks the status, outcome header and Retry-After of a
+// /bid response.
+func expectBidReply(t *testing.T, what string, rec *httptest.ResponseRecorder, code BidCode, status int) {
+	t.Helper()
+	if rec.Code != status || rec.Header().Get("X-Bid-Outcome") != string(code) {
+		t.Errorf("%s: %d %s %q, want %d %s", what, rec.Code, rec.Header().Get("X-Bid-Outcome"), rec.Body, status, code)
+	}
+	if retry := rec.Header().Get("Retry-After"); (retry != "") != (status == http.StatusServiceUnavailable) {
+		t.Errorf("%s: Retry-After = %q with status %d", what, retry, status)
+	}
+}
+
+func postBid(n *Node, form url.Values) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/bid", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	n.handleBidRequest(rec, req)
+	return rec
+}
+
+func TestWriteBidReply(t *testing.T) {
+	for _, c := range bidStatuses {
+		rec := httptest.NewRecorder()
+		writeBidReply(rec, CoordinatorBidReply{Accepted: c.code == BidCommitted, Code: c.code, Message: "msg"})
+		expectBidReply(t, string(c.code), rec, c.code, c.status)
+		if !strings.Contains(rec.Body.String(), "msg") {
+			t.Errorf("%s: body %q lacks the message", c.code, rec.Body)
+		}
+	}
+
+	// Coordinators that predate Code only say whether the bid was accepted.
+	rec := httptest.NewRecorder()
+	writeBidReply(rec, CoordinatorBidReply{Accepted: true})
+	expectBidReply(t, "legacy accept", rec, BidCommitted, http.StatusOK)
+	rec = httptest.NewRecorder()
+	writeBidReply(rec, CoordinatorBidReply{})
+	expectBidReply(t, "legacy reject", rec, BidRejected, http.StatusUnprocessableEntity)
+}
+
+func TestBidCodeFor(t *testing.T) {
+	cases := map[PrepareRejection]BidCode{
+		RejectBidTooLow:       BidOutbid,
+		RejectBelowIncrement:  BidBelowIncrement,
+		RejectAuctionInactive: BidClosed,
+		RejectNoCurrentItem:   BidClosed,
+		RejectDeadlinePassed:  BidClosed,
+		RejectItemChanged:     BidItemChanged,
+		RejectNotAllowed:      BidNotAllowed,
+		RejectOverBudget:      BidOverBudget,
+		RejectNodeNotReady:    BidNotReady,
+		RejectUnreachable:     BidNoQuorum,
+		RejectReserved:        BidNoQuorum,
+		RejectIncompatible:    BidUnavailable,
+		RejectDecided:         BidRejected,
+		RejectUnknown:         BidRejected,
+		"":                    BidRejected,
+	}
+	for reason, want := range cases {
+		if got := bidCodeFor(reason); got != want {
+			t.Errorf("bidCodeFor(%q) = %s, want %s", reason, got, want)
+		}
+	}
+}
+
+func TestBidHandlerStatuses(t *testing.T) {
+	n := biddingNode(t)
+	setLeader(n, n.ID, n.Address)
+	leading(t, n)
+	bid := func(amount, itemID string) url.Values {
+		return url.Values{"bidder": {"Ann"}, "amount": {amount}, "itemId": {itemID}}
+	}
+
+	expectBidReply(t, "malformed amount", postBid(n, bid("ten", "lot1")), BidInvalid, http.StatusBadRequest)
+	expectBidReply(t, "negative amount", postBid(n, bid("-5", "lot1")), BidInvalid, http.StatusBadRequest)
+	expectBidReply(t, "valid bid", postBid(n, bid("50", "lot1")), BidCommitted, http.StatusOK)
+	expectBidReply(t, "same amount", postBid(n, bid("50", "lot1")), BidOutbid, http.StatusUnprocessableEntity)
+	expectBidReply(t, "wrong lot", postBid(n, bid("70", "lot9")), BidItemChanged, http.StatusConflict)
+
+	n.Queue.mu.Lock()
+	n.Queue.CurrentItem.Increments = []IncrementBand{{Increment: 10}}
+	n.Queue.mu.Unlock()
+	expectBidReply(t, "under the increment", postBid(n, bid("55", "lot1")), BidBelowIncrement, http.StatusUnprocessableEntity)
+
+	n.Queue.mu.Lock()
+	n.Queue.Active = false
+	n.Queue.mu.Unlock()
+	expectBidReply(t, "auction stopped", postBid(n, bid("90", "lot1")), BidClosed, http.StatusUnprocessableEntity)
+
+	n.setPhase(PhaseDraining, "test")
+	expectBidReply(t, "draining node", postBid(n, bid("90", "lot1")), BidNotReady, http.StatusServiceUnavailable)
+
+	rec := httptest.NewRecorder()
+	n.handleBidRequest(rec, httptest.NewRequest(http.MethodGet, "/bid", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /bid = %d, want 405", rec.Code)
+	}
+}
+
+// scriptedCoordinator answers every forwarded bid with reply.
+type scriptedCoordinator struct{ reply CoordinatorBidReply }
+
+func (s *scriptedCoordinator) SubmitBidToCoordinator(args BidArgs, reply *CoordinatorBidReply) error {
+	*reply = s.reply
+	return nil
+}
+
+func TestForwardedBidKeepsCoordinatorCode(t *testing.T) {
+	coord := &scriptedCoordinator{}
+	n := withLotUp(followerOf(t, "L1", serveAsNodeRPC(t, coord)))
+	for _, c := range bidStatuses {
+		if c.code == BidNoLeader || c.code == BidInvalid {
+			continue // retried by the follower; caught by the follower
+		}
+		coord.reply = CoordinatorBidReply{Accepted: c.code == BidCommitted, Code: c.code, Message: "from L1"}
+		rec := postBid(n, url.Values{"bidder": {"Ann"}, "amount": {"50"}, "itemId": {"lot1"}})
+		expectBidReply(t, "forwarded "+string(c.code), rec, c.code, c.status)
+		if !strings.Contains(rec.Body.String(), "from L1") {
+			t.Errorf("forwarded %s: body %q is not the coordinator's", c.code, rec.Body)
+		}
+	}
+
+	coord.reply = CoordinatorBidReply{Message: "Bid rejected"}
+	expectBidReply(t, "legacy coordinator", postBid(n, url.Values{"amount": {"50"}, "itemId": {"lot1"}}), BidRejected, http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,87 @@
+package node
+
+// bidtiming.go — Client-claimed submission times, for fairness questions
+// after an event ("did the projector lag cost me the win?").
+//
+// A client may send clientTime with /bid: when the bidder pressed the
+// button, as Unix milliseconds or RFC 3339. The node that takes the HTTP
+// request stamps its own receive time next to it, and both travel with the
+// bid into the bid book and the transaction log. The claim is advisory
+// only: it never affects ordering, which stays commit order, or validity.
+//
+// A claim more than clientSkewFlag away from the receive time is kept but
+// flagged; one more than clientTimeBound away, or unparseable, is dropped and
+// flagged. The receiving node observes the claimed network delay (receive
+// minus claim) and its own processing delay (receive until the reply) in
+// bid_client_delay_seconds{part}.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	clientSkewFlag  = 30 * time.Second
+	clientTimeBound = 24 * time.Hour
+)
+
+// stampBidTiming records when bid was received and what the client claimed.
+func (n *Node) stampBidTiming(bid *BidArgs, claim string, received time.Time) {
+	bid.ReceivedAtMs = received.UnixMilli()
+	result := "missing"
+	if claim = strings.TrimSpace(claim); claim != "" {
+		at, ok := parseClientTime(claim)
+		skew := received.Sub(at)
+		switch {
+		case !ok || skew > clientTimeBound || skew < -clientTimeBound:
+			result = "discarded"
+			bid.ClientSkewFlagged = true
+		case skew > clientSkewFlag || skew < -clientSkewFlag:
+			result = "skewed"
+			bid.ClientAtMs, bid.ClientSkewFlagged = at.UnixMilli(), true
+		default:
+			result = "ok"
+			bid.ClientAtMs = at.UnixMilli()
+			if skew >= 0 {
+				n.Metrics.Histogram(metricName("bid_client_delay_seconds", "part", "network")).Observe(skew.Seconds())
+			}
+		}
+	}
+	n.Metrics.Inc(metricName("bid_client_time_total", "result", result))
+}
+
+// parseClientTime accepts Unix milliseconds or RFC 3339.
+func parseClientTime(v string) (time.Time, bool) {
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms), ms > 0
+	}
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// observeBidProcessing records how long the receiving node took to answer.
+func (n *Node) observeBidProcessing(bid BidArgs) {
+	if bid.ReceivedAtMs > 0 {
+		d := time.Since(time.UnixMilli(bid.ReceivedAtMs))
+		n.Metrics.Histogram(metricName("bid_client_delay_seconds", "part", "processing")).Observe(d.Seconds())
+	}
+}
+
+// timingNote describes the bid's timing for the transaction log.
+func (bid BidArgs) timingNote() string {
+	if bid.ReceivedAtMs == 0 {
+		return ""
+	}
+	note := fmt.Sprintf(" received_ms=%d", bid.ReceivedAtMs)
+	if bid.ClientAtMs > 0 {
+		note += fmt.Sprintf(" client_ms=%d delay_ms=%d", bid.ClientAtMs, bid.ReceivedAtMs-bid.ClientAtMs)
+	}
+	if bid.ClientSkewFlagged {
+		note += " client_skew_flagged=true"
+	}
+	return note
+}
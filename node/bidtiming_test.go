@@ -0,0 +1,129 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientTimestamps(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	now := time.Now()
+
+	cases := []struct {
+		name, bidder, claim string
+		wantClient          time.Time // zero if the claim is not kept
+		flagged             bool
+		result              string
+	}{
+		{"missing", "b1", "", time.Time{}, false, "missing"},
+		{"sane", "b2", fmt.Sprint(now.Add(-200 * time.Millisecond).UnixMilli()), now.Add(-200 * time.Millisecond), false, "ok"},
+		{"skewed", "b3", now.Add(-time.Minute).Format(time.RFC3339Nano), now.Add(-time.Minute), true, "skewed"},
+		{"far future", "b4", fmt.Sprint(now.Add(48 * time.Hour).UnixMilli()), time.Time{}, true, "discarded"},
+		{"garbage", "b5", "yesterday", time.Time{}, true, "discarded"},
+	}
+	for i, c := range cases {
+		// Each bid goes through B, outbidding the last whatever it claims.
+		amount := 20 + 10*i
+		form := url.Values{"bidder": {c.bidder}, "amount": {fmt.Sprint(amount)}, "itemId": {"lot1"}}
+		if c.claim != "" {
+			form.Set("clientTime", c.claim)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/bid", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: bidderIDCookie, Value: c.bidder})
+		rec := httptest.NewRecorder()
+		before := time.Now().UnixMilli()
+		b.handleBidRequest(rec, req)
+		expectBidReply(t, c.name, rec, BidCommitted, http.StatusOK)
+
+		// The timing travels with the bid into every bid book.
+		for _, n := range []*Node{a.Node, b.Node} {
+			bids := myBidsOn(t, n, c.bidder, "").Bids
+			if len(bids) != 1 {
+				t.Fatalf("%s: %s has %d bids for %s", c.name, n.ID, len(bids), c.bidder)
+			}
+			rec := bids[0]
+			if rec.ReceivedAtMs < before || rec.ReceivedAtMs > time.Now().UnixMilli() {
+				t.Errorf("%s on %s: receivedAtMs %d outside the request", c.name, n.ID, rec.ReceivedAtMs)
+			}
+			wantMs := int64(0)
+			if !c.wantClient.IsZero() {
+				wantMs = c.wantClient.UnixMilli()
+			}
+			if rec.ClientAtMs != wantMs || rec.SkewFlagged != c.flagged {
+				t.Errorf("%s on %s: clientAtMs %d flagged %v, want %d %v", c.name, n.ID, rec.ClientAtMs, rec.SkewFlagged, wantMs, c.flagged)
+			}
+			if wantMs != 0 && rec.DelayMs != rec.ReceivedAtMs-wantMs {
+				t.Errorf("%s on %s: delayMs %d", c.name, n.ID, rec.DelayMs)
+			}
+		}
+		if highestBid(a.Node) != amount {
+			t.Errorf("%s: highest bid $%d, want $%d in commit order", c.name, highestBid(a.Node), amount)
+		}
+	}
+
+	for result, want := range map[string]float64{"missing": 1, "ok": 1, "skewed": 1, "discarded": 2} {
+		if got := b.Metrics.Counter(metricName("bid_client_time_total", "result", result)); got != want {
+			t.Errorf("bid_client_time_total{result=%s} = %v, want %v", result, got, want)
+		}
+	}
+	network := b.Metrics.Histogram(metricName("bid_client_delay_seconds", "part", "network"))
+	processing := b.Metrics.Histogram(metricName("bid_client_delay_seconds", "part", "processing"))
+	network.mu.Lock()
+	if network.count != 1 || network.sum < 0.2 {
+		t.Errorf("network delay: %d samples, %.3fs; want the sane claim's 200ms", network.count, network.sum)
+	}
+	network.mu.Unlock()
+	processing.mu.Lock()
+	if processing.count != uint64(len(cases)) {
+		t.Errorf("processing delay: %d samples, want %d", processing.count, len(cases))
+	}
+	processing.mu.Unlock()
+
+	// The coordinator's audit trail has both times.
+	log, err := os.ReadFile(txnLogPath(a.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		fmt.Sprintf("client_ms=%d delay_ms=", now.Add(-200*time.Millisecond).UnixMilli()),
+		"client_skew_flagged=true",
+		"received_ms=",
+	} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("transaction log lacks %q", want)
+		}
+	}
+}
+
+func TestParseClientTime(t *testing.T) {
+	at := time.Date(2026, 5, 1, 12, 0, 0, 250e6, time.UTC)
+	for _, c := range []struct {
+		in string
+		ok bool
+	}{
+		{fmt.Sprint(at.UnixMilli()), true},
+		{at.Format(time.RFC3339Nano), true},
+		{at.Format(time.RFC3339), true},
+		{"0", false},
+		{"-5", false},
+		{"12:00", false},
+	} {
+		got, ok := parseClientTime(c.in)
+		if ok != c.ok || (ok && got.Unix() != at.Unix()) {
+			t.Errorf("parseClientTime(%q) = %v, %v", c.in, got, ok)
+		}
+	}
+}
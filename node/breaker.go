@@ -0,0 +1,198 @@
+package node
+
+// breaker.go — Per-peer circuit breaker inside RPCClient.
+//
+// A dead peer otherwise costs every heartbeat, checkpoint round, snapshot
+// push and 2PC prepare a full dial and the wait for it to fail. After
+// breakerThreshold consecutive connection failures to an address the
+// breaker opens and calls to it fail at once with *BreakerOpenError. Once
+// breakerCooldown has passed, the next call is let through as a probe
+// (half-open): if it connects the breaker closes, otherwise it opens for
+// another cool-down. Other calls keep failing fast while the probe runs.
+//
+// Only failures to reach the peer count. An error returned by the remote
+// method, or a protocol error, proves the peer is up and closes the breaker;
+// a caller giving up (context done) counts as neither. A peer that joins the
+// cluster again resets its breaker, so it is not shunned for the rest of the
+// cool-down.
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	breakerThreshold = 3               // consecutive connection failures that open the breaker
+	breakerCooldown  = 5 * time.Second // how long an open breaker fails fast before probing
+)
+
+// BreakerState is the state of one peer's circuit breaker.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStatus describes one peer's breaker, as reported in GET /peers.
+type BreakerStatus struct {
+	Address      string       `json:"address"`
+	State        BreakerState `json:"state"`
+	Failures     int          `json:"failures"` // consecutive connection failures
+	LastError    string       `json:"lastError,omitempty"`
+	OpenedAtUnix int64        `json:"openedAtUnix,omitempty"`
+	FastFails    int64        `json:"fastFails"` // calls refused without dialing, since the node started
+}
+
+// BreakerOpenError is returned instead of dialing a peer whose breaker is open.
+type BreakerOpenError struct {
+	Peer    string
+	RetryIn time.Duration
+	LastErr string
+}
+
+func (e *BreakerOpenError) Error() string {
+	return fmt.Sprintf("circuit to %s open (retry in %s): %s", e.Peer, e.RetryIn.Round(time.Millisecond), e.LastErr)
+}
+
+type peerBreaker struct {
+	state     BreakerState
+	failures  int
+	lastErr   string
+	openedAt  time.Time
+	probing   bool // half-open and the probe call has not come back yet
+	fastFails int64
+}
+
+// allow reports whether a call to address may go ahead, turning an open
+// breaker half-open once its cool-down is over.
+func (c *RPCClient) allow(address string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := c.breakers[address]
+	if b == nil || b.state == BreakerClosed {
+		return nil
+	}
+	if b.state == BreakerOpen {
+		if wait := breakerCooldown - time.Since(b.openedAt); wait > 0 {
+			b.fastFails++
+			return &BreakerOpenError{Peer: address, RetryIn: wait, LastErr: b.lastErr}
+		}
+		b.state = BreakerHalfOpen
+	}
+	if b.probing {
+		b.fastFails++
+		return &BreakerOpenError{Peer: address, LastErr: b.lastErr}
+	}
+	b.probing = true
+	return nil
+}
+
+// record feeds the outcome of a call that allow let through. reached is
+// false when the peer could not be reached; ignore is set when the caller
+// gave up before knowing.
+func (c *RPCClient) record(address string, reached, ignore bool, err error) {
+	c.mu.Lock()
+	b := c.breakers[address]
+	if b == nil {
+		if reached || ignore {
+			c.mu.Unlock()
+			return
+		}
+		b = &peerBreaker{state: BreakerClosed}
+		if c.breakers == nil {
+			c.breakers = map[string]*peerBreaker{}
+		}
+		c.breakers[address] = b
+	}
+	wasProbe := b.probing
+	b.probing = false
+	before := b.state
+	switch {
+	case ignore:
+	case reached:
+		b.state, b.failures, b.lastErr = BreakerClosed, 0, ""
+	default:
+		b.failures++
+		b.lastErr = err.Error()
+		if wasProbe || b.failures >= breakerThreshold {
+			b.state, b.openedAt = BreakerOpen, time.Now()
+		}
+	}
+	changed := (before == BreakerClosed) != (b.state == BreakerClosed) // a failed probe re-opening is not news
+	status := b.status(address)
+	c.mu.Unlock()
+	if changed && c.OnBreakerChange != nil {
+		c.OnBreakerChange(status)
+	}
+}
+
+func (b *peerBreaker) status(address string) BreakerStatus {
+	s := BreakerStatus{
+		Address:   address,
+		State:     b.state,
+		Failures:  b.failures,
+		LastError: b.lastErr,
+		FastFails: b.fastFails,
+	}
+	if b.state != BreakerClosed {
+		s.OpenedAtUnix = b.openedAt.Unix()
+	}
+	return s
+}
+
+// Breaker returns the breaker state for address. A peer that has never
+// failed is closed.
+func (c *RPCClient) Breaker(address string) BreakerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b := c.breakers[address]; b != nil {
+		return b.status(address)
+	}
+	return BreakerStatus{Address: address, State: BreakerClosed}
+}
+
+// Breakers returns every breaker that has seen a failure, sorted by address.
+func (c *RPCClient) Breakers() []BreakerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]BreakerStatus, 0, len(c.breakers))
+	for address, b := range c.breakers {
+		out = append(out, b.status(address))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out
+}
+
+// ResetBreaker closes the breaker for address, e.g. when the peer has just
+// contacted this node and is evidently up.
+func (c *RPCClient) ResetBreaker(address string) {
+	c.mu.Lock()
+	b := c.breakers[address]
+	if b == nil || b.state == BreakerClosed {
+		c.mu.Unlock()
+		return
+	}
+	b.state, b.failures, b.lastErr, b.probing = BreakerClosed, 0, "", false
+	status := b.status(address)
+	c.mu.Unlock()
+	if c.OnBreakerChange != nil {
+		c.OnBreakerChange(status)
+	}
+}
+
+// noteBreakerChange logs a breaker opening or closing and keeps the
+// rpc_breaker_open gauge in step.
+func (n *Node) noteBreakerChange(s BreakerStatus) {
+	if s.State == BreakerClosed {
+		n.Metrics.Set(metricName("rpc_breaker_open", "peer", s.Address), 0)
+		log.Printf("[%s] 🔌 Circuit to %s closed; calls go through again\n", n.ID, s.Address)
+		return
+	}
+	n.Metrics.Set(metricName("rpc_breaker_open", "peer", s.Address), 1)
+	log.Printf("[%s] 🔌 Circuit to %s opened after %d failures (%s); failing fast for %s\n",
+		n.ID, s.Address, s.Failures, s.LastError, breakerCooldown)
+}
@@ -0,0 +1,158 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/rpc"
+	"testing"
+	"time"
+)
+
+// expireCooldown makes address's open breaker due for a probe.
+func expireCooldown(c *RPCClient, address string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.breakers[address].openedAt = time.Now().Add(-breakerCooldown)
+}
+
+func TestBreakerFailsFastOnDeadPeer(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	var changes []BreakerState
+	a.Client.OnBreakerChange = func(s BreakerStatus) {
+		changes = append(changes, s.State)
+		a.noteBreakerChange(s)
+	}
+	breakerGauge := metricName("rpc_breaker_open", "peer", b.Address)
+	ping := func() error {
+		var snap QueueSnapshot
+		return a.callPeer(b.Address, "NodeRPC.GetQueueState", EmptyArgs{}, &snap)
+	}
+
+	b.kill()
+	for i := 1; i <= breakerThreshold; i++ {
+		var open *BreakerOpenError
+		if err := ping(); err == nil || errors.As(err, &open) {
+			t.Fatalf("call %d to dead B = %v, want a dial error", i, err)
+		}
+	}
+	s := a.Client.Breaker(b.Address)
+	if s.State != BreakerOpen || s.Failures != breakerThreshold || s.LastError == "" || s.OpenedAtUnix == 0 {
+		t.Errorf("after %d failures: %+v, want open with the last error", breakerThreshold, s)
+	}
+	if got := gauge(a.Metrics, breakerGauge); got != 1 {
+		t.Errorf("rpc_breaker_open = %v, want 1", got)
+	}
+
+	// While open, calls fail at once without dialing, even once B is back.
+	b = b.restart(t)
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		err := ping()
+		var open *BreakerOpenError
+		if !errors.As(err, &open) || open.Peer != b.Address || open.RetryIn <= 0 || open.LastErr != s.LastError {
+			t.Fatalf("call while open = %v, want a BreakerOpenError for %s", err, b.Address)
+		}
+		if d := time.Since(start); d > 50*time.Millisecond {
+			t.Errorf("fast fail took %s", d)
+		}
+	}
+	if got := a.Client.Breaker(b.Address).FastFails; got != 2 {
+		t.Errorf("fast fails = %d, want 2", got)
+	}
+
+	// Once the cool-down is over, the probe reaches B and closes the circuit.
+	expireCooldown(a.Client, b.Address)
+	if err := ping(); err != nil {
+		t.Fatalf("probe after the cool-down: %v", err)
+	}
+	if s := a.Client.Breaker(b.Address); s.State != BreakerClosed || s.Failures != 0 || s.LastError != "" {
+		t.Errorf("after recovery: %+v, want closed", s)
+	}
+	if got := gauge(a.Metrics, breakerGauge); got != 0 {
+		t.Errorf("rpc_breaker_open after recovery = %v, want 0", got)
+	}
+	if len(changes) != 2 || changes[0] != BreakerOpen || changes[1] != BreakerClosed {
+		t.Errorf("breaker changes = %v, want open then closed", changes)
+	}
+}
+
+// gateRPC answers Ping at once and Wait when gate is closed.
+type gateRPC struct{ gate chan struct{} }
+
+func (g *gateRPC) Ping(_ EmptyArgs, reply *bool) error {
+	*reply = true
+	return nil
+}
+
+func (g *gateRPC) Wait(_ EmptyArgs, reply *bool) error {
+	<-g.gate
+	*reply = true
+	return nil
+}
+
+func TestBreakerProbesOnce(t *testing.T) {
+	addr := closedAddr(t)
+	var changes []BreakerState
+	c := &RPCClient{OnBreakerChange: func(s BreakerStatus) { changes = append(changes, s.State) }}
+	defer c.Close()
+	var ok bool
+	for i := 0; i < breakerThreshold; i++ {
+		c.Call(addr, "Gate.Ping", EmptyArgs{}, &ok)
+	}
+
+	// A probe that still cannot connect opens the breaker again at once.
+	expireCooldown(c, addr)
+	if err := c.Call(addr, "Gate.Ping", EmptyArgs{}, &ok); err == nil {
+		t.Fatal("probe to a dead peer succeeded")
+	}
+	var open *BreakerOpenError
+	if s := c.Breaker(addr); s.State != BreakerOpen || s.Failures != breakerThreshold+1 {
+		t.Errorf("after a failed probe: %+v, want open", s)
+	}
+	if err := c.Call(addr, "Gate.Ping", EmptyArgs{}, &ok); !errors.As(err, &open) {
+		t.Errorf("call after a failed probe = %v, want a fast fail", err)
+	}
+
+	// The peer comes back; only the probe goes through until it answers.
+	g := &gateRPC{gate: make(chan struct{})}
+	s := rpc.NewServer()
+	if err := s.RegisterName("Gate", g); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, s)
+	go http.Serve(l, mux)
+
+	expireCooldown(c, addr)
+	probe := make(chan error, 1)
+	go func() {
+		var ok bool
+		probe <- c.CallContext(context.Background(), addr, "Gate.Wait", EmptyArgs{}, &ok)
+	}()
+	waitFor(t, "the probe to start", func() bool { return c.Breaker(addr).State == BreakerHalfOpen })
+	if err := c.Call(addr, "Gate.Ping", EmptyArgs{}, &ok); !errors.As(err, &open) {
+		t.Errorf("call during the probe = %v, want a fast fail", err)
+	}
+	close(g.gate)
+	if err := <-probe; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if st := c.Breaker(addr); st.State != BreakerClosed {
+		t.Errorf("after the probe: %+v, want closed", st)
+	}
+	if err := c.Call(addr, "Gate.Ping", EmptyArgs{}, &ok); err != nil || !ok {
+		t.Errorf("call after recovery = %v, %v", ok, err)
+	}
+	// A failed probe re-opening is not a change; only open and closed are.
+	if len(changes) != 2 || changes[0] != BreakerOpen || changes[1] != BreakerClosed {
+		t.Errorf("breaker changes = %v, want open then closed", changes)
+	}
+}
@@ -0,0 +1,263 @@
+package node
+
+// budget.go — Per-bidder budgets with holds on leading bids.
+//
+// An admin can give a bidder ID a budget. The bidder's leading bid on the
+// open lot is held against it, and when the lot closes the hold becomes
+// spend. A bid is only accepted if it fits in what is left:
+//
+//	available = limit - spent - held on other lots
+//
+// The bidder's own hold on the lot being bid on is not counted, since the
+// new bid replaces it. Being outbid releases a hold automatically, because
+// the hold is derived from the lot's current leader. Limits and spend live
+// in the replicated queue state, so they travel with snapshots and
+// checkpoints and survive failover like the rest of it. Bidders without a
+// budget are not limited.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BidderBudget is one bidder's entry in the replicated budget table.
+type BidderBudget struct {
+	Limit int `json:"limit"`
+	Spent int `json:"spent"` // winning bids on closed lots since the last restart
+}
+
+// BudgetStatus is a budget with its current hold, as served by
+// GET /admin/budgets and GET /me.
+type BudgetStatus struct {
+	BidderID  string `json:"bidderId"`
+	Limit     int    `json:"limit"`
+	Spent     int    `json:"spent"`
+	Held      int    `json:"held"`
+	Available int    `json:"available"`
+}
+
+// heldLocked returns the amounts bidderID currently holds, by item ID. Must
+// hold mu.
+func (q *ItemQueueState) heldLocked(bidderID string) map[string]int {
+	if q.CurrentItem == nil || bidderID == "" || q.CurrentWinnerID != bidderID {
+		return nil
+	}
+	return map[string]int{q.CurrentItem.ID: q.CurrentHighestBid}
+}
+
+// budgetStatusLocked returns bidderID's budget, or nil if it has none. Must
+// hold mu.
+func (q *ItemQueueState) budgetStatusLocked(bidderID string) *BudgetStatus {
+	b, ok := q.Budgets[bidderID]
+	if !ok {
+		return nil
+	}
+	s := &BudgetStatus{BidderID: bidderID, Limit: b.Limit, Spent: b.Spent}
+	for _, amount := range q.heldLocked(bidderID) {
+		s.Held += amount
+	}
+	s.Available = s.Limit - s.Spent - s.Held
+	return s
+}
+
+// availableForLocked returns how much bidderID may bid on itemID, and false
+// if the bidder has no budget. Must hold mu.
+func (q *ItemQueueState) availableForLocked(bidderID, itemID string) (int, bool) {
+	b, ok := q.Budgets[bidderID]
+	if !ok {
+		return 0, false
+	}
+	available := b.Limit - b.Spent
+	for id, amount := range q.heldLocked(bidderID) {
+		if id != itemID {
+			available -= amount
+		}
+	}
+	return available, true
+}
+
+// chargeWinnerLocked turns the winner's hold on a closed lot into spend.
+// Must hold mu.
+func (q *ItemQueueState) chargeWinnerLocked(result ItemResult) {
+	b, ok := q.Budgets[result.WinnerID]
+	if !ok || result.WinningBid <= 0 {
+		return
+	}
+	b.Spent += result.WinningBid
+	q.Budgets = copyBudgets(q.Budgets)
+	q.Budgets[result.WinnerID] = b
+}
+
+// resetSpendLocked zeroes every bidder's spend, keeping the limits. Used when
+// the auction restarts and its results are cleared. Must hold mu.
+func (q *ItemQueueState) resetSpendLocked() {
+	if len(q.Budgets) == 0 {
+		return
+	}
+	budgets := make(map[string]BidderBudget, len(q.Budgets))
+	for id, b := range q.Budgets {
+		budgets[id] = BidderBudget{Limit: b.Limit}
+	}
+	q.Budgets = budgets
+}
+
+// copyBudgets copies the table; snapshots share the old map, so it is
+// replaced rather than modified.
+func copyBudgets(budgets map[string]BidderBudget) map[string]BidderBudget {
+	if budgets == nil {
+		return nil
+	}
+	out := make(map[string]BidderBudget, len(budgets))
+	for id, b := range budgets {
+		out[id] = b
+	}
+	return out
+}
+
+// BudgetArgs sets a bidder's budget limit.
+type BudgetArgs struct {
+	BidderID string
+	Limit    int // 0 removes the budget
+}
+
+// setBudgetAndBroadcast changes a budget limit. Coordinator only; followers
+// forward via SubmitBudgetToCoordinator.
+func (n *Node) setBudgetAndBroadcast(args BudgetArgs) (bool, string) {
+	args.BidderID = strings.TrimSpace(args.BidderID)
+	switch {
+	case args.BidderID == "":
+		return false, "bidderId is required"
+	case args.Limit < 0:
+		return false, "limit must be 0 (no budget) or more"
+	}
+	if msg := n.versionWriteBlock(); msg != "" {
+		return false, msg
+	}
+
+	release, err := n.enterCS()
+	if err != nil {
+		return false, err.Error()
+	}
+	defer release()
+
+	n.Queue.mu.Lock()
+	budgets := copyBudgets(n.Queue.Budgets)
+	if args.Limit == 0 {
+		delete(budgets, args.BidderID)
+	} else {
+		if budgets == nil {
+			budgets = map[string]BidderBudget{}
+		}
+		b := budgets[args.BidderID]
+		b.Limit = args.Limit
+		budgets[args.BidderID] = b
+	}
+	n.Queue.Budgets = budgets
+	n.Queue.touchLocked()
+	n.Queue.mu.Unlock()
+
+	var message string
+	if args.Limit == 0 {
+		message = fmt.Sprintf("Budget removed for %s", args.BidderID)
+		log.Printf("[%s] 💰 Budget removed for %s\n", n.ID, args.BidderID)
+	} else {
+		message = fmt.Sprintf("Budget for %s set to $%d", args.BidderID, args.Limit)
+		log.Printf("[%s] 💰 Budget for %s set to $%d\n", n.ID, args.BidderID, args.Limit)
+	}
+	n.broadcastQueueState()
+	go n.initiateGlobalCheckpoint()
+	return true, message
+}
+
+// SubmitBudgetToCoordinator forwards POST /admin/budget to the leader.
+func (rp *NodeRPC) SubmitBudgetToCoordinator(args BudgetArgs, reply *CoordinatorActionReply) error {
+	if _, isLocal := rp.node.getCoordinatorAddress(); !isLocal {
+		reply.Accepted = false
+		reply.Message = "This node is not the coordinator"
+		return nil
+	}
+	reply.Accepted, reply.Message = rp.node.setBudgetAndBroadcast(args)
+	return nil
+}
+
+// submitBudget changes a budget on the coordinator, forwarding if needed.
+func (n *Node) submitBudget(args BudgetArgs) (int, string) {
+	var reply CoordinatorActionReply
+	coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
+	if isLocalCoordinator {
+		reply.Accepted, reply.Message = n.setBudgetAndBroadcast(args)
+	} else {
+		if coordinatorAddress == "" {
+			return http.StatusServiceUnavailable, "Election in progress, please wait"
+		}
+		if err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitBudgetToCoordinator", args, &reply); err != nil {
+			return http.StatusServiceUnavailable, "Leader unavailable; retry shortly"
+		}
+	}
+	if !reply.Accepted {
+		return http.StatusBadRequest, reply.Message
+	}
+	return http.StatusOK, reply.Message
+}
+
+// budgetTable lists every budget with its current hold, sorted by bidder ID.
+func (n *Node) budgetTable() []BudgetStatus {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	rows := make([]BudgetStatus, 0, len(n.Queue.Budgets))
+	for id := range n.Queue.Budgets {
+		rows = append(rows, *n.Queue.budgetStatusLocked(id))
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].BidderID < rows[j].BidderID })
+	return rows
+}
+
+// handleBudgetRequest serves GET /admin/budgets (list) and POST /admin/budget
+// with a JSON body {"bidderId": "...", "limit": 500} or the same form fields.
+func (n *Node) handleBudgetRequest(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/admin/budgets":
+		writeJSON(w, n.budgetTable())
+		return
+	case r.Method != http.MethodPost || r.URL.Path != "/admin/budget":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var args BudgetArgs
+	if strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "application/json") {
+		var req struct {
+			BidderID string `json:"bidderId"`
+			Limit    int    `json:"limit"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+		args = BudgetArgs{BidderID: req.BidderID, Limit: req.Limit}
+	} else {
+		limit, err := strconv.Atoi(strings.TrimSpace(r.FormValue("limit")))
+		if err != nil {
+			http.Error(w, "limit must be a whole number", http.StatusBadRequest)
+			return
+		}
+		args = BudgetArgs{BidderID: r.FormValue("bidderId"), Limit: limit}
+	}
+
+	var status int
+	var message string
+	if !n.holdForClient(r, "admin_budget", func() { status, message = n.submitBudget(args) }) {
+		return
+	}
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(message))
+}
@@ -0,0 +1,96 @@
+package node
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func budgetOf(n *Node, bidderID string) BudgetStatus {
+	for _, b := range n.budgetTable() {
+		if b.BidderID == bidderID {
+			return b
+		}
+	}
+	return BudgetStatus{BidderID: bidderID}
+}
+
+func TestBudgetHoldCarriesIntoNextLot(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	bid := func(via *testNode, bidder string, amount int, itemID string) BidCode {
+		t.Helper()
+		return via.submitBid(context.Background(), BidArgs{BidderID: bidder, DisplayName: bidder, Amount: amount, ItemID: itemID}).Code
+	}
+	expectBudget := func(when string, held, available int) {
+		t.Helper()
+		for _, tn := range nodes {
+			waitFor(t, tn.ID+" to agree on b1's budget "+when, func() bool {
+				s := budgetOf(tn.Node, "b1")
+				return s.Limit == 100 && s.Held == held && s.Available == available
+			})
+		}
+	}
+
+	if status, msg := b.submitBudget(BudgetArgs{BidderID: " b1 ", Limit: 100}); status != http.StatusOK {
+		t.Fatalf("set budget via B: %d %s", status, msg)
+	}
+	expectBudget("once set", 0, 100)
+
+	// b1's own hold on the lot does not count against a raise.
+	for _, amount := range []int{60, 80} {
+		if got := bid(b, "b1", amount, "lot1"); got != BidCommitted {
+			t.Fatalf("b1 at $%d = %s", amount, got)
+		}
+	}
+	expectBudget("leading at $80", 80, 20)
+	if got := bid(b, "b1", 110, "lot1"); got != BidOverBudget {
+		t.Errorf("b1 over the limit = %s, want %s", got, BidOverBudget)
+	}
+
+	// Being outbid releases the hold; leading again takes it back.
+	if got := bid(b, "b2", 90, "lot1"); got != BidCommitted {
+		t.Fatalf("b2 outbids = %s", got)
+	}
+	expectBudget("after being outbid", 0, 100)
+	if got := bid(a, "b1", 95, "lot1"); got != BidCommitted {
+		t.Fatalf("b1 retakes the lead = %s", got)
+	}
+
+	// b1 wins lot1, and the hold turns into spend on every node.
+	a.Queue.mu.Lock()
+	a.finalizeCurrentItemLocked()
+	a.Queue.mu.Unlock()
+	nextLot(a.Node)
+	a.broadcastQueueState()
+	expectBudget("after winning lot1", 0, 5)
+	for _, tn := range nodes {
+		if ok, reason := tn.canPrepareBid(BidArgs{BidderID: "b1", Amount: 25, ItemID: "lot2"}); ok || reason != RejectOverBudget {
+			t.Errorf("%s prepares b1's $25 on lot2: %v %s, want %s", tn.ID, ok, reason, RejectOverBudget)
+		}
+	}
+	if got := bid(b, "b1", 25, "lot2"); got != BidOverBudget {
+		t.Errorf("b1 leading lot2 past the remainder = %s, want %s", got, BidOverBudget)
+	}
+
+	// The spend survives failover to B and a restart from B's checkpoint.
+	a.kill()
+	for _, tn := range nodes[1:] {
+		setLeader(tn.Node, b.ID, b.Address)
+	}
+	leading(t, b.Node)
+	if got := bid(b, "b1", 25, "lot2"); got != BidOverBudget {
+		t.Errorf("b1 on lot2 after failover = %s, want %s", got, BidOverBudget)
+	}
+	if err := b.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if s := budgetOf(NewNode(b.ID, b.Address, nil, b.Rank), "b1"); s.Limit != 100 || s.Spent != 95 {
+		t.Errorf("b1's budget after a restart = %+v, want limit 100 and 95 spent", s)
+	}
+}
@@ -1,107 +1,395 @@
 package node
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 )
 
+// Default Bully timings. The leader sends a heartbeat every
+// DefaultHeartbeatInterval; a follower that hears none for
+// DefaultLeaderTimeout starts an election; a candidate waits
+// DefaultElectionWait for a higher-ranked peer to answer OK before it
+// declares itself coordinator. --heartbeat-interval, --leader-timeout and
+// --election-wait override them, within the limits of CheckElectionTiming.
+const (
+	DefaultHeartbeatInterval = 1 * time.Second
+	DefaultLeaderTimeout     = 3 * time.Second
+	DefaultElectionWait      = 2 * time.Second
+)
+
+const minElectionTiming = 10 * time.Millisecond
+
+// CheckElectionTiming rejects timings under which the failure detector
+// cannot work: a leader timeout that a single late heartbeat can trip, an
+// election wait that outlasts the leader timeout so that followers give up
+// on a candidate before it can claim, or a majority-loss window (when set)
+// shorter than two heartbeats, which would depose a healthy leader.
+func CheckElectionTiming(heartbeat, leaderTimeout, electionWait, majorityLossWindow time.Duration) error {
+	switch {
+	case heartbeat < minElectionTiming || leaderTimeout < minElectionTiming || electionWait < minElectionTiming:
+		return fmt.Errorf("heartbeat interval, leader timeout and election wait must each be at least %s", minElectionTiming)
+	case leaderTimeout <= 2*heartbeat:
+		return fmt.Errorf("leader timeout (%s) must be more than twice the heartbeat interval (%s)", leaderTimeout, heartbeat)
+	case electionWait >= leaderTimeout:
+		return fmt.Errorf("election wait (%s) must be shorter than the leader timeout (%s)", electionWait, leaderTimeout)
+	case majorityLossWindow > 0 && majorityLossWindow <= 2*heartbeat:
+		return fmt.Errorf("majority-loss window (%s) must be more than twice the heartbeat interval (%s), or 0", majorityLossWindow, heartbeat)
+	}
+	return nil
+}
+
+// Election terms: every successful election starts a new term, carried by
+// all Bully messages and persisted in the checkpoint. Messages from a term
+// older than the receiver's are stale: their sender has missed at least one
+// election (typically it was down), so it must learn the current leader from
+// heartbeats before it may campaign. This stops a flapping high-rank node
+// from deposing a healthy leader every time it comes back.
+
 type BullyMessage struct {
-	NodeID string
-	Rank   int
+	NodeID     string
+	Rank       int
+	Term       int    // election: sender's current term; coordinator/heartbeat: the leader's term
+	ConfigHash string // heartbeats only: hash of the leader's config overrides
+	Address    string // sender's advertised RPC address; empty from older builds
+	// StateVersion is, on heartbeats, the leader's queue mutation counter;
+	// see followersync.go. 0 from older builds.
+	StateVersion uint64
+}
+
+// outranks reports whether this node beats the sender of msg in an
+// election: the higher rank wins, and equal ranks go to the greater ID, so
+// every node orders the cluster the same way.
+func (n *Node) outranks(msg BullyMessage) bool {
+	if n.Rank != msg.Rank {
+		return n.Rank > msg.Rank
+	}
+	return n.ID > msg.NodeID
+}
+
+// noteBullyAddressLocked remembers where the sender of a Bully message can
+// be reached, for getCoordinatorAddress. Must hold ElectionMutex.
+func (n *Node) noteBullyAddressLocked(args BullyMessage) {
+	if args.Address == "" || args.NodeID == "" || args.NodeID == n.ID {
+		return
+	}
+	if n.bullyAddrs == nil {
+		n.bullyAddrs = map[string]string{}
+	}
+	n.bullyAddrs[args.NodeID] = args.Address
 }
 
+// errStaleTerm answers a snapshot or checkpoint request from a deposed leader.
+var errStaleTerm = errors.New("stale election term: the sender is no longer coordinator")
+
+// currentTerm returns the highest election term this node has seen.
+func (n *Node) currentTerm() int {
+	n.ElectionMutex.Lock()
+	defer n.ElectionMutex.Unlock()
+	return n.Term
+}
+
+// admitTerm fences leader traffic that is not a Bully message: snapshots,
+// 2PC decisions and checkpoint requests. A message from an older term comes
+// from a deposed leader that has not noticed yet, typically one that was cut
+// off by a partition, and is refused. A newer term means an election was won
+// without us: it is adopted, and if we were coordinator we step down, which
+// stops our heartbeats and item timer. from is the sender's node ID when
+// known. Term 0 comes from builds that predate fencing and is let through.
+func (n *Node) admitTerm(term int, from, kind string) bool {
+	if term == 0 {
+		return true
+	}
+	n.ElectionMutex.Lock()
+	current, wasLeader := n.Term, n.Coordinator == n.ID
+	switch {
+	case term < current:
+		n.ElectionMutex.Unlock()
+		if from == "" {
+			from = "(unknown)"
+		}
+		log.Printf("[%s] Rejecting %s from stale leader %s (term %d < %d)\n", n.ID, kind, from, term, current)
+		n.Metrics.Inc(metricName("election_stale_messages_total", "kind", kind))
+		return false
+	case term > current:
+		n.Term = term
+		n.noteElectionEvent(LeaderLearned, term, from, fmt.Sprintf("from a %s; our term was %d", kind, current))
+		if wasLeader || from != "" {
+			// An unknown sender leaves the leader open until its heartbeat names it.
+			n.setCoordinatorLocked(from)
+			if from != "" {
+				n.renewLeaderLease()
+			}
+		}
+	}
+	n.ElectionMutex.Unlock()
+	if term > current {
+		n.Metrics.Set("election_term", float64(term))
+		if wasLeader {
+			n.noteStepDown(term, from)
+		}
+	}
+	return true
+}
+
+// noteStepDown logs a coordinator learning that it was deposed.
+func (n *Node) noteStepDown(term int, leader string) {
+	if leader == "" {
+		leader = "an unknown node"
+	}
+	log.Printf("[%s] ⬇️  Stepping down: %s leads term %d\n", n.ID, leader, term)
+	n.Metrics.Inc("leader_step_downs_total")
+	n.noteElectionEvent(LeaderSteppedDown, term, "", leader+" leads")
+}
+
+// StartElection runs a Bully election. At most one runs at a time: a
+// trigger that arrives while one is running (typically Election messages
+// from several lower-ranked peers at once) is coalesced into it, and only
+// starts one more round afterwards if the leader is still unknown.
 func (n *Node) StartElection() {
-	log.Printf("[%s] Starting election (Rank: %d)\n", n.ID, n.Rank)
+	if !n.electionRunning.CompareAndSwap(false, true) {
+		n.electionQueued.Store(true)
+		n.Metrics.Inc("elections_coalesced_total")
+		return
+	}
+	for {
+		n.runElection()
+		n.electionRunning.Store(false)
+		if !n.electionQueued.Swap(false) || n.leaderKnown() {
+			return
+		}
+		if !n.electionRunning.CompareAndSwap(false, true) {
+			return // a new trigger started its own round
+		}
+		log.Printf("[%s] Leader still unknown after election; running the queued one\n", n.ID)
+	}
+}
+
+func (n *Node) leaderKnown() bool {
+	n.ElectionMutex.Lock()
+	defer n.ElectionMutex.Unlock()
+	return n.Coordinator != ""
+}
+
+func (n *Node) runElection() {
+	if p := n.Phase(); p == PhaseQuarantined || p == PhaseDraining || p == PhaseStopped {
+		// Its state is suspect, or it is out of service (see transfer.go); it
+		// must not lead until an operator releases it.
+		return
+	}
+	peers := n.peerList()
+	if len(peers) == 0 {
+		n.becomeSingleNodeCoordinator()
+		return
+	}
+	n.ElectionMutex.Lock()
+	term := n.Term
+	n.ElectionMutex.Unlock()
+	self := n.advertiseAddress()
+	log.Printf("[%s] Starting election (Rank: %d, term %d)\n", n.ID, n.Rank, term)
+	n.noteElection()
+	n.noteElectionEvent(ElectionStarted, term, "", fmt.Sprintf("rank %d, %d peer(s)", n.Rank, len(peers)))
+
+	// Ask every peer; a higher-ranked one that is alive answers OK.
+	ctx, cancel := context.WithTimeout(context.Background(), n.ElectionWait)
+	defer cancel()
+	type answer struct {
+		peer        string
+		reached, ok bool
+	}
+	okCh := make(chan answer, len(peers))
+	fanOut(peers, func(addr string) {
+		var ok bool
+		err := n.callPeerContext(ctx, addr, "NodeRPC.HandleElection", BullyMessage{NodeID: n.ID, Rank: n.Rank, Term: term, Address: self}, &ok)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[%s] Error sending Election to %s: %v\n", n.ID, addr, err)
+		}
+		okCh <- answer{peer: addr, reached: err == nil, ok: err == nil && ok}
+	})
 
+	// Wait until a peer says OK, every peer has answered, or time runs out.
 	receivedOK := false
-	for _, peerAddress := range n.Peers {
-		go func(addr string) {
-			var ok bool
-			err := n.callPeer(addr, "NodeRPC.HandleElection", BullyMessage{NodeID: n.ID, Rank: n.Rank}, &ok)
-			if err == nil && ok {
-				n.ElectionMutex.Lock()
-				receivedOK = true
-				n.ElectionMutex.Unlock()
-			} else if err != nil {
-				log.Printf("[%s] Error sending Election to %s: %v\n", n.ID, addr, err)
+	reachable := 1
+	for answered := 0; answered < len(peers) && !receivedOK; {
+		select {
+		case a := <-okCh:
+			answered++
+			receivedOK = a.ok
+			if a.ok {
+				n.noteElectionEvent(ElectionOKReceived, term, n.peerName(a.peer), "")
+			}
+			if a.reached {
+				reachable++
 			}
-		}(peerAddress)
+		case <-ctx.Done():
+			answered = len(peers)
+		}
 	}
 
-	// Wait for OK responses
-	time.Sleep(2 * time.Second)
+	// No OK is not enough to claim: a node cut off from everyone hears none
+	// either, and would run an auction of its own. Any answer, OK or not,
+	// shows a peer is reachable; the claim needs a majority counting self.
+	if quorum := quorumFor(len(peers)); !receivedOK && reachable < quorum {
+		n.withheldRounds.Add(1)
+		log.Printf("[%s] Not claiming leadership: only %d of %d nodes reachable (quorum %d); retrying in %s\n",
+			n.ID, reachable, len(peers)+1, quorum, n.electionRetryDelay())
+		n.Metrics.Inc("elections_withheld_total")
+		n.noteElectionEvent(ElectionWithheld, term, "", fmt.Sprintf("%d of %d nodes reachable, quorum %d", reachable, len(peers)+1, quorum))
+		return
+	}
+	n.withheldRounds.Store(0)
 
 	n.ElectionMutex.Lock()
 	isHighest := !receivedOK
+	if isHighest && n.Term != term {
+		// A concurrent election of ours already won, or a heartbeat from a
+		// newer term arrived while we waited: either way this claim is stale.
+		isHighest = false
+		if n.Coordinator != n.ID {
+			log.Printf("[%s] Term advanced to %d during election; deferring to %s\n", n.ID, n.Term, n.Coordinator)
+		}
+	}
+	if isHighest {
+		n.Term = term + 1
+		term = n.Term
+		n.setCoordinatorLocked(n.ID)
+	}
 	n.ElectionMutex.Unlock()
 
 	if isHighest {
-		log.Printf("[%s] No higher nodes, becoming leader!\n", n.ID)
-
-		n.ElectionMutex.Lock()
-		n.Coordinator = n.ID
-		n.ElectionMutex.Unlock()
+		log.Printf("[%s] No higher nodes, becoming leader for term %d!\n", n.ID, term)
+		n.noteElectionEvent(ElectionWon, term, "", "")
+		n.Metrics.Set("election_term", float64(term))
 
 		// Broadcast coordinator
-		for _, peerAddress := range n.Peers {
-			go func(addr string) {
+		for _, peerAddress := range peers {
+			addr := peerAddress
+			n.async.send(addr, "HandleCoordinator", func() error {
 				var dummy bool
-				err := n.callPeer(addr, "NodeRPC.HandleCoordinator", BullyMessage{NodeID: n.ID, Rank: n.Rank}, &dummy)
+				err := n.callPeer(addr, "NodeRPC.HandleCoordinator", BullyMessage{NodeID: n.ID, Rank: n.Rank, Term: term, Address: self}, &dummy)
 				if err != nil {
 					log.Printf("[%s] Error sending Coordinator to %s: %v\n", n.ID, addr, err)
 				}
-			}(peerAddress)
+				return err
+			})
 		}
 
-		// Leader broadcasts heartbeats continuously
-		go n.BroadcastHeartbeats()
+		// Heartbeats, checkpoints and the item queue timer run until demoted.
+		n.startLeading()
+	}
+}
 
-		// Start / resume the item queue timer
-		go n.OnBecomeCoordinator()
+// becomeSingleNodeCoordinator takes leadership without an election when the
+// node has no peers. Heartbeats still start so that nodes joining later see
+// a live leader.
+func (n *Node) becomeSingleNodeCoordinator() {
+	n.ElectionMutex.Lock()
+	already := n.Coordinator == n.ID
+	n.setCoordinatorLocked(n.ID)
+	if !already {
+		n.Term++
+	}
+	n.ElectionMutex.Unlock()
+	if already {
+		return
 	}
+	log.Printf("[%s] Single-node mode: no peers, acting as coordinator\n", n.ID)
+	n.noteElectionEvent(ElectionWon, n.currentTerm(), "", "single-node mode, no peers")
+	n.startLeading()
 }
 
-func (n *Node) BroadcastHeartbeats() {
+// BroadcastHeartbeats sends heartbeats until ctx, the spell as coordinator,
+// ends.
+func (n *Node) BroadcastHeartbeats(ctx context.Context) {
+	leaderSince := time.Now()
+	lastMajority := leaderSince
+	self := n.advertiseAddress()
 	for {
 		n.ElectionMutex.Lock()
 		if n.Coordinator != n.ID {
 			n.ElectionMutex.Unlock()
 			break // stop sending heartbeats if no longer leader
 		}
+		term := n.Term
 		n.ElectionMutex.Unlock()
 
-		for _, peerAddress := range n.Peers {
-			go func(addr string) {
+		peers := n.peerList()
+		if window := n.MajorityLossWindow; window > 0 && len(peers) > 0 {
+			reachable, quorum := n.heartbeatMajority(peers, leaderSince, window)
+			n.Metrics.Set("leader_reachable_nodes", float64(reachable))
+			if reachable >= quorum {
+				lastMajority = time.Now()
+			} else if time.Since(lastMajority) >= window && n.stepDownLostMajority(reachable, len(peers)+1) {
+				break
+			}
+		}
+
+		for _, peerAddress := range peers {
+			addr := peerAddress
+			n.async.sendLatest(addr, "HandleHeartbeat", func() error {
 				var dummy bool
-				n.callPeer(addr, "NodeRPC.HandleHeartbeat", BullyMessage{NodeID: n.ID, Rank: n.Rank}, &dummy)
-			}(peerAddress)
+				err := n.callPeer(addr, "NodeRPC.HandleHeartbeat", BullyMessage{NodeID: n.ID, Rank: n.Rank, Term: term, ConfigHash: n.configHash(), Address: self, StateVersion: n.Queue.Version()}, &dummy)
+				n.notePeerContact(addr, err == nil)
+				return err
+			})
 		}
 
-		time.Sleep(1 * time.Second)
+		if !sleepWhileLeading(ctx, n.HeartbeatInterval) {
+			break
+		}
+	}
+}
+
+// maxElectionBackoff caps how far electionRetryDelay backs off, as a
+// multiple of the leader timeout.
+const maxElectionBackoff = 8
+
+// electionRetryDelay is how long MonitorLeader waits for a heartbeat before
+// campaigning: the leader timeout, doubled for each election round in a row
+// that was withheld for lack of a quorum, so that an isolated node does not
+// flood the network with Election messages.
+func (n *Node) electionRetryDelay() time.Duration {
+	backoff := 1
+	for i := n.withheldRounds.Load(); i > 0 && backoff < maxElectionBackoff; i-- {
+		backoff *= 2
 	}
+	return n.LeaderTimeout * time.Duration(backoff)
 }
 
+// MonitorLeader is the failure detector; it runs until the node is stopped.
 func (n *Node) MonitorLeader() {
-	// Trigger an initial election on startup
-	n.StartElection()
+	// Trigger an initial election on startup, unless the cluster already
+	// has a live leader (see prevote.go).
+	n.campaign()
 
-	for {
+	for n.Phase() != PhaseStopped {
 		n.ElectionMutex.Lock()
 		isLeader := (n.Coordinator == n.ID)
 		n.ElectionMutex.Unlock()
 
 		if isLeader {
-			time.Sleep(2 * time.Second)
+			time.Sleep(n.HeartbeatInterval)
 			continue
 		}
 
 		select {
 		case <-n.LeaderChan:
 			// Heartbeat received, reset timeout
-		case <-time.After(3 * time.Second):
+			n.withheldRounds.Store(0)
+		case <-time.After(n.electionRetryDelay()):
+			n.ElectionMutex.Lock()
+			isLeader = n.Coordinator == n.ID
+			n.ElectionMutex.Unlock()
+			if isLeader || n.Phase() == PhaseStopped {
+				// Won a takeover election while waiting, or shut down;
+				// nothing to detect.
+				continue
+			}
 			// Timeout triggered!
 			log.Printf("[%s] Failure detected: leader heartbeat timed out\n", n.ID)
-			n.StartElection()
+			n.campaign()
 		}
 	}
 }
@@ -111,13 +399,25 @@ func (n *Node) MonitorLeader() {
 func (rp *NodeRPC) HandleElection(args BullyMessage, reply *bool) error {
 	rp.node.ElectionMutex.Lock()
 	defer rp.node.ElectionMutex.Unlock()
+	rp.node.noteBullyAddressLocked(args)
 
-	if rp.node.Rank > args.Rank {
-		*reply = true // Meaning "I will take over"
-		// Only start election if we haven't already. To simplify, we can just start it. The timer in StartElection will serialize things.
-		go rp.node.StartElection()
+	if args.Term < rp.node.Term {
+		// Stale candidate: answer OK so it stands down, but do not start an
+		// election of our own. It catches up from the leader's heartbeats.
+		log.Printf("[%s] Ignoring election from %s: its term %d is behind ours (%d)\n",
+			rp.node.ID, args.NodeID, args.Term, rp.node.Term)
+		rp.node.Metrics.Inc(metricName("election_stale_messages_total", "kind", "election"))
+		rp.node.noteElectionEvent(ElectionReceived, rp.node.Term, args.NodeID, fmt.Sprintf("stale term %d; answered OK without campaigning", args.Term))
+		*reply = true
+		return nil
+	}
+	if p := rp.node.Phase(); rp.node.outranks(args) && p != PhaseQuarantined && p != PhaseDraining && p != PhaseStopped {
+		*reply = true              // Meaning "I will take over"
+		go rp.node.StartElection() // coalesced if one is already running
+		rp.node.noteElectionEvent(ElectionReceived, rp.node.Term, args.NodeID, "answered OK; taking over")
 	} else {
 		*reply = false
+		rp.node.noteElectionEvent(ElectionReceived, rp.node.Term, args.NodeID, "no OK: candidate outranks us or we are out of service")
 	}
 	return nil
 }
@@ -125,10 +425,27 @@ func (rp *NodeRPC) HandleElection(args BullyMessage, reply *bool) error {
 func (rp *NodeRPC) HandleCoordinator(args BullyMessage, reply *bool) error {
 	rp.node.ElectionMutex.Lock()
 	defer rp.node.ElectionMutex.Unlock()
+	rp.node.noteBullyAddressLocked(args)
 
+	if args.Term < rp.node.Term {
+		log.Printf("[%s] Rejecting stale leader claim from %s (term %d < %d)\n",
+			rp.node.ID, args.NodeID, args.Term, rp.node.Term)
+		rp.node.Metrics.Inc(metricName("election_stale_messages_total", "kind", "coordinator"))
+		rp.node.noteElectionEvent(CoordinatorRejected, rp.node.Term, args.NodeID, fmt.Sprintf("stale term %d", args.Term))
+		rp.node.noteRivalLeaderLocked()
+		*reply = false
+		return nil
+	}
+	rp.node.Term = args.Term
+	rp.node.Metrics.Set("election_term", float64(args.Term))
+	rp.node.renewLeaderLease()
 	if rp.node.Coordinator != args.NodeID {
-		rp.node.Coordinator = args.NodeID
-		log.Printf("[%s] New leader elected: %s\n", rp.node.ID, args.NodeID)
+		if rp.node.Coordinator == rp.node.ID {
+			rp.node.noteStepDown(args.Term, args.NodeID)
+		}
+		rp.node.setCoordinatorLocked(args.NodeID)
+		log.Printf("[%s] New leader elected: %s (term %d)\n", rp.node.ID, args.NodeID, args.Term)
+		rp.node.noteElectionEvent(CoordinatorAccepted, args.Term, args.NodeID, "")
 
 		// Flush LeaderChan to avoid stale heartbeats, but a non-blocking read is fine
 		select {
@@ -141,17 +458,73 @@ func (rp *NodeRPC) HandleCoordinator(args BullyMessage, reply *bool) error {
 }
 
 func (rp *NodeRPC) HandleHeartbeat(args BullyMessage, reply *bool) error {
+	n := rp.node
+	stepDown := false
+	n.ElectionMutex.Lock()
+	n.noteBullyAddressLocked(args)
+	if args.Term < n.Term {
+		// A deposed leader that has not noticed yet; don't let it reset our
+		// failure detector.
+		n.noteRivalLeaderLocked()
+		n.ElectionMutex.Unlock()
+		n.Metrics.Inc(metricName("election_stale_messages_total", "kind", "heartbeat"))
+		*reply = false
+		return nil
+	}
 	// Discard heartbeat if it's from a lower rank node proposing themselves as leader mistakenly
-	if args.Rank < rp.node.Rank && rp.node.Coordinator == rp.node.ID {
+	if args.Term == n.Term && n.outranks(args) && n.Coordinator == n.ID {
+		n.noteRivalLeaderLocked()
+		n.ElectionMutex.Unlock()
 		*reply = false
 		return nil
 	}
+	if args.Term > n.Term || n.Coordinator == "" {
+		// Catch up on an election we missed.
+		if args.Term > n.Term {
+			log.Printf("[%s] Synced election term %d → %d; leader is %s\n", n.ID, n.Term, args.Term, args.NodeID)
+		}
+		stepDown = n.Coordinator == n.ID
+		n.noteElectionEvent(LeaderLearned, args.Term, args.NodeID, fmt.Sprintf("from a heartbeat; our term was %d", n.Term))
+		n.Term = args.Term
+		n.setCoordinatorLocked(args.NodeID)
+		n.Metrics.Set("election_term", float64(args.Term))
+	}
+	fromLeader := n.Coordinator == args.NodeID
+	n.ElectionMutex.Unlock()
+	if stepDown {
+		n.noteStepDown(args.Term, args.NodeID)
+	}
 
 	select {
-	case rp.node.LeaderChan <- true:
+	case n.LeaderChan <- true:
 	default:
 	}
 
+	if fromLeader {
+		n.renewLeaderLease()
+		n.checkConfigDrift(args.NodeID, args.ConfigHash)
+		if n.noteLeaderVersion(args.Term, args.StateVersion) > 0 {
+			n.fastSync()
+		}
+		if n.outranks(args) {
+			n.maybeTakeOver(args.NodeID)
+		}
+	}
+
 	*reply = true
 	return nil
 }
+
+// maybeTakeOver lets a higher-rank node that was held back by a stale term
+// claim leadership once it is caught up: it knows the current term and has
+// synced state (phase Ready). Only one takeover attempt runs at a time.
+func (n *Node) maybeTakeOver(leader string) {
+	if !n.IsReady() || !n.takeoverRunning.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer n.takeoverRunning.Store(false)
+		log.Printf("[%s] Caught up with lower-rank leader %s; starting takeover election\n", n.ID, leader)
+		n.StartElection()
+	}()
+}
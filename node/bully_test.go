@@ -0,0 +1,609 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCaller answers calls with the function registered for the peer's
+// address and records what each peer was sent.
+type fakeCaller struct {
+	mu    sync.Mutex
+	peers map[string]func(ctx context.Context, method string, reply interface{}) error
+	calls map[string][]string
+}
+
+func (f *fakeCaller) CallContext(ctx context.Context, address, method string, _ interface{}, reply interface{}) error {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = map[string][]string{}
+	}
+	f.calls[address] = append(f.calls[address], method)
+	answer := f.peers[address]
+	f.mu.Unlock()
+	if answer == nil {
+		return errors.New("connection refused")
+	}
+	return answer(ctx, method, reply)
+}
+
+func (f *fakeCaller) sent(address string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls[address]...)
+}
+
+// electionOK answers an election as a live peer: OK if it outranks the
+// candidate.
+func electionOK(ok bool, delay time.Duration) func(context.Context, string, interface{}) error {
+	return func(ctx context.Context, method string, reply interface{}) error {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		*reply.(*bool) = ok
+		return nil
+	}
+}
+
+// hangUntilCancelled is a peer whose calls never return on their own.
+func hangUntilCancelled(ctx context.Context, _ string, _ interface{}) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func electionNode(t *testing.T, caller *fakeCaller, peers ...string) *Node {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	n := NewNode("N2", "127.0.0.1:9", peers, 2)
+	n.caller = caller
+	return n
+}
+
+func TestRunElectionDefersToHigherPeer(t *testing.T) {
+	caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{
+		"low:1":  electionOK(false, 0),
+		"high:1": electionOK(true, 20*time.Millisecond),
+	}}
+	n := electionNode(t, caller, "low:1", "high:1")
+
+	n.runElection()
+
+	n.ElectionMutex.Lock()
+	coordinator, term := n.Coordinator, n.Term
+	n.ElectionMutex.Unlock()
+	if coordinator == n.ID {
+		t.Fatal("claimed leadership although a higher-ranked peer answered OK")
+	}
+	if term != 0 {
+		t.Errorf("term = %d, want 0", term)
+	}
+	for _, peer := range []string{"low:1", "high:1"} {
+		for _, method := range caller.sent(peer) {
+			if method != "NodeRPC.HandleElection" {
+				t.Errorf("%s was sent %s", peer, method)
+			}
+		}
+	}
+}
+
+func TestRunElectionStopsWaitingAtFirstOK(t *testing.T) {
+	caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{
+		"slow:1": hangUntilCancelled,
+		"high:1": electionOK(true, 0),
+	}}
+	n := electionNode(t, caller, "slow:1", "high:1")
+	n.ElectionWait = 10 * time.Second
+
+	start := time.Now()
+	n.runElection()
+	if waited := time.Since(start); waited > 2*time.Second {
+		t.Fatalf("election waited %s after the OK", waited)
+	}
+	n.ElectionMutex.Lock()
+	coordinator := n.Coordinator
+	n.ElectionMutex.Unlock()
+	if coordinator == n.ID {
+		t.Fatal("claimed leadership although a higher-ranked peer answered OK")
+	}
+}
+
+func TestRunElectionWithheldWithoutQuorum(t *testing.T) {
+	// Neither peer can be reached: no OK, but no majority either.
+	n := electionNode(t, &fakeCaller{}, "a:1", "b:1")
+	n.ElectionWait = 200 * time.Millisecond
+
+	n.runElection()
+
+	n.ElectionMutex.Lock()
+	coordinator := n.Coordinator
+	n.ElectionMutex.Unlock()
+	if coordinator == n.ID {
+		t.Fatal("claimed leadership without a majority")
+	}
+	if got := n.Metrics.Counter("elections_withheld_total"); got != 1 {
+		t.Errorf("elections_withheld_total = %v, want 1", got)
+	}
+}
+
+// lowerPeer answers Election with no OK and fails every other call.
+func lowerPeer(_ context.Context, method string, reply interface{}) error {
+	if method != "NodeRPC.HandleElection" {
+		return errors.New("connection refused")
+	}
+	*reply.(*bool) = false
+	return nil
+}
+
+func TestElectionNeedsAMajorityToClaim(t *testing.T) {
+	// Five nodes: the candidate needs two peers besides itself. A peer that
+	// answers without an OK still counts as reached.
+	for reached := 0; reached <= 3; reached++ {
+		caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{}}
+		peers := []string{"p1:1", "p2:1", "p3:1", "p4:1"}
+		for _, p := range peers[:reached] {
+			caller.peers[p] = lowerPeer
+		}
+		n := electionNode(t, caller, peers...)
+		n.ElectionWait = 200 * time.Millisecond
+
+		n.runElection()
+		claimed := coordinatorOf(n) == n.ID
+		if claimed {
+			stepDown(n)
+		}
+		if want := reached >= 2; claimed != want {
+			t.Errorf("%d of 4 peers reached: claimed %v, want %v", reached, claimed, want)
+		}
+		announced := false
+		for _, p := range peers {
+			for _, method := range caller.sent(p) {
+				announced = announced || method == "NodeRPC.HandleCoordinator"
+			}
+		}
+		if !claimed && announced {
+			t.Errorf("%d of 4 peers reached: withheld claim still announced", reached)
+		}
+		if got, want := n.Metrics.Counter("elections_withheld_total"), map[bool]float64{true: 0, false: 1}[claimed]; got != want {
+			t.Errorf("%d of 4 peers reached: elections_withheld_total = %v, want %v", reached, got, want)
+		}
+	}
+}
+
+func TestWithheldElectionsBackOff(t *testing.T) {
+	n := electionNode(t, &fakeCaller{}, "a:1", "b:1")
+	n.ElectionWait = 10 * time.Millisecond
+	n.LeaderTimeout = time.Second
+	for i, want := range []time.Duration{2, 4, 8, 8, 8} {
+		n.runElection()
+		if got := n.electionRetryDelay(); got != want*time.Second {
+			t.Errorf("after %d withheld rounds: retry in %s, want %ds", i+1, got, want)
+		}
+	}
+
+	// Reaching a majority ends the backoff.
+	n.caller = &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{"a:1": lowerPeer}}
+	n.runElection()
+	if coordinatorOf(n) != n.ID {
+		t.Fatal("did not claim with a majority")
+	}
+	stepDown(n)
+	if got := n.electionRetryDelay(); got != time.Second {
+		t.Errorf("retry after a claim = %s, want the plain leader timeout", got)
+	}
+}
+
+func TestFlappingHighRankNodeDeposesLeaderOnce(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range []*testNode{a, b} {
+		tn.ElectionMutex.Lock()
+		tn.Term = 5
+		tn.ElectionMutex.Unlock()
+		withLotUp(tn.Node)
+	}
+	leading(t, a.Node)
+	setLeader(b.Node, a.ID, a.Address)
+	stillLeading := func(when string) {
+		t.Helper()
+		a.ElectionMutex.Lock()
+		coordinator, term := a.Coordinator, a.Term
+		a.ElectionMutex.Unlock()
+		if coordinator != a.ID || term != 5 {
+			t.Fatalf("%s: A sees %s leading term %d, want A in term 5", when, coordinator, term)
+		}
+	}
+
+	// C has the highest rank but has never seen term 5. Its election is
+	// answered as stale: it stands down without anyone campaigning.
+	c.runElection()
+	stillLeading("after C's stale election")
+	waitFor(t, "A to count the stale election", func() bool {
+		return a.Metrics.Counter(metricName("election_stale_messages_total", "kind", "election")) == 1
+	})
+
+	// It then flaps: each time it comes back it finds the leader by
+	// pre-vote and restarts with the term it saved.
+	for i := 1; i <= 5; i++ {
+		c.campaign()
+		stillLeading("flap " + strconv.Itoa(i))
+		if got := c.currentTerm(); got != 5 {
+			t.Fatalf("flap %d: C is at term %d, want 5", i, got)
+		}
+		if err := c.takeLocalCheckpoint(); err != nil {
+			t.Fatal(err)
+		}
+		c.kill()
+		c = c.restart(t)
+		if got := c.currentTerm(); got != 5 {
+			t.Fatalf("flap %d: C restarted at term %d, want the saved 5", i, got)
+		}
+	}
+
+	// Caught up and ready, C takes over on the leader's next heartbeat.
+	c.campaign()
+	c.setPhase(PhaseSyncing, "test")
+	c.markSynced("test")
+	var ok bool
+	if err := (&NodeRPC{node: c.Node}).HandleHeartbeat(BullyMessage{NodeID: a.ID, Rank: a.Rank, Term: 5, Address: a.Address}, &ok); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "C to take over", func() bool {
+		a.ElectionMutex.Lock()
+		defer a.ElectionMutex.Unlock()
+		return a.Coordinator == c.ID
+	})
+	leading(t, c.Node) // steps C down at cleanup
+	if got := a.Metrics.Counter("leader_step_downs_total"); got != 1 {
+		t.Errorf("A stepped down %v times, want 1", got)
+	}
+	if got := c.currentTerm(); got != 6 {
+		t.Errorf("C leads term %d, want 6", got)
+	}
+}
+
+func TestConcurrentElectionTriggersCoalesce(t *testing.T) {
+	// Both peers rank below N2; they answer elections slowly and take the
+	// coordinator announcement and heartbeats.
+	lowPeer := func(ctx context.Context, method string, reply interface{}) error {
+		if method != "NodeRPC.HandleElection" {
+			return nil
+		}
+		return electionOK(false, 200*time.Millisecond)(ctx, method, reply)
+	}
+	caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{"p0:1": lowPeer, "p1:1": lowPeer}}
+	n := electionNode(t, caller, "p0:1", "p1:1")
+	n.ElectionWait = 2 * time.Second
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var ok bool
+			if err := (&NodeRPC{node: n}).HandleElection(BullyMessage{NodeID: "N1", Rank: 1, Address: "p1:1"}, &ok); err != nil || !ok {
+				t.Errorf("HandleElection: ok %v, err %v", ok, err)
+			}
+		}()
+	}
+	wg.Wait()
+	waitFor(t, "N2 to win", func() bool { return n.leaderKnown() })
+	leading(t, n) // steps N2 down at cleanup
+	waitFor(t, "the coordinator broadcast", func() bool {
+		return len(methodsSent(caller, "p0:1", "NodeRPC.HandleCoordinator")) == 1 &&
+			len(methodsSent(caller, "p1:1", "NodeRPC.HandleCoordinator")) == 1
+	})
+	time.Sleep(50 * time.Millisecond) // a second round would have started by now
+
+	for _, peer := range []string{"p0:1", "p1:1"} {
+		if got := len(methodsSent(caller, peer, "NodeRPC.HandleElection")); got != 1 {
+			t.Errorf("%s got %d Election messages, want 1", peer, got)
+		}
+		if got := len(methodsSent(caller, peer, "NodeRPC.HandleCoordinator")); got != 1 {
+			t.Errorf("%s got %d Coordinator messages, want 1", peer, got)
+		}
+	}
+	if got := n.Metrics.Counter("elections_coalesced_total"); got != 9 {
+		t.Errorf("elections_coalesced_total = %v, want 9", got)
+	}
+	if got := n.currentTerm(); got != 1 {
+		t.Errorf("term = %d, want 1", got)
+	}
+}
+
+func methodsSent(f *fakeCaller, peer, method string) []string {
+	var out []string
+	for _, m := range f.sent(peer) {
+		if m == method {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func TestQueuedElectionRerunsWhileLeaderUnknown(t *testing.T) {
+	// The higher peer answers OK but never announces itself.
+	caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{"high:1": electionOK(true, 100*time.Millisecond)}}
+	n := electionNode(t, caller, "high:1")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.StartElection()
+		}()
+	}
+	wg.Wait()
+	// The triggers that arrived during the first round share one more.
+	if got := len(caller.sent("high:1")); got != 2 {
+		t.Errorf("ran %d election rounds, want 2", got)
+	}
+	if got := n.Metrics.Counter("elections_coalesced_total"); got != 2 {
+		t.Errorf("elections_coalesced_total = %v, want 2", got)
+	}
+}
+
+func TestDeposedLeaderIsFenced(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+	}
+	// A led term 1 and was cut off; C won term 2 without it.
+	a.ElectionMutex.Lock()
+	a.Term = 1
+	a.ElectionMutex.Unlock()
+	leading(t, a.Node)
+	for _, tn := range []*testNode{b, c} {
+		tn.ElectionMutex.Lock()
+		tn.Term = 2
+		tn.ElectionMutex.Unlock()
+		setLeader(tn.Node, c.ID, c.Address)
+	}
+	leading(t, c.Node)
+
+	// A reappears and carries on as coordinator.
+	before := highestBid(b.Node)
+	a.Queue.mu.Lock()
+	a.Queue.CurrentHighestBid, a.Queue.CurrentWinner = 40, "b9"
+	a.Queue.mu.Unlock()
+	staleSnapshot := func(to *testNode) error {
+		var ok bool
+		return a.callPeer(to.Address, "NodeRPC.SyncQueueState", a.buildQueueSnapshot(), &ok)
+	}
+	if err := staleSnapshot(b); err == nil || !strings.Contains(err.Error(), "stale election term") {
+		t.Errorf("snapshot from A: %v, want it refused", err)
+	}
+	var applied bool
+	decision := DecisionArgs{TxnID: "A-7", Commit: true, Bid: BidArgs{BidderID: "b9", DisplayName: "b9", Amount: 40, ItemID: "lot1"}, Leader: a.ID, Term: 1}
+	if err := a.callPeer(b.Address, "NodeRPC.DecideBid", decision, &applied); err != nil || applied {
+		t.Errorf("decision from A: applied %v, err %v", applied, err)
+	}
+	var ckpt TakeCheckpointReply
+	if err := a.callPeer(b.Address, "NodeRPC.TakeCheckpoint", TakeCheckpointArgs{InitiatorID: a.ID, Term: 1}, &ckpt); err != nil || ckpt.OK || ckpt.Error != errStaleTerm.Error() {
+		t.Errorf("checkpoint request from A: %+v, %v", ckpt, err)
+	}
+	var ok bool
+	if err := a.callPeer(b.Address, "NodeRPC.HandleHeartbeat", BullyMessage{NodeID: a.ID, Rank: a.Rank, Term: 1, Address: a.Address}, &ok); err != nil || ok {
+		t.Errorf("heartbeat from A: ok %v, err %v", ok, err)
+	}
+
+	// B still follows C, with none of A's state.
+	if highestBid(b.Node) != before || b.currentTerm() != 2 {
+		t.Errorf("B: highest bid $%d in term %d, want $%d in term 2", highestBid(b.Node), b.currentTerm(), before)
+	}
+	b.ElectionMutex.Lock()
+	leader := b.Coordinator
+	b.ElectionMutex.Unlock()
+	if leader != c.ID {
+		t.Errorf("B follows %s, want C", leader)
+	}
+	for _, kind := range []string{"snapshot", "decision", "checkpoint", "heartbeat"} {
+		if got := b.Metrics.Counter(metricName("election_stale_messages_total", "kind", kind)); got != 1 {
+			t.Errorf("election_stale_messages_total{kind=%s} = %v, want 1", kind, got)
+		}
+	}
+
+	// C's next heartbeat tells A it was deposed: it steps down and its
+	// heartbeats and item timer stop with its spell.
+	spell := a.leaderContext()
+	if err := c.callPeer(a.Address, "NodeRPC.HandleHeartbeat", BullyMessage{NodeID: c.ID, Rank: c.Rank, Term: 2, Address: c.Address}, &ok); err != nil || !ok {
+		t.Fatalf("heartbeat from C: ok %v, err %v", ok, err)
+	}
+	if spell.Err() == nil || a.currentTerm() != 2 {
+		t.Errorf("A still leading (term %d)", a.currentTerm())
+	}
+	if got := a.Metrics.Counter("leader_step_downs_total"); got != 1 {
+		t.Errorf("leader_step_downs_total = %v, want 1", got)
+	}
+
+	// The term B has seen survives a restart.
+	if err := b.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	b.kill()
+	b = b.restart(t)
+	if got := b.currentTerm(); got != 2 {
+		t.Fatalf("B restarted at term %d, want 2", got)
+	}
+	a.ElectionMutex.Lock()
+	a.Term = 1 // as if A had never heard from C
+	a.ElectionMutex.Unlock()
+	if err := staleSnapshot(b); err == nil {
+		t.Error("restarted B took a snapshot from term 1")
+	}
+}
+
+func TestAdmitTermAcceptsUnfencedPeers(t *testing.T) {
+	n := electionNode(t, &fakeCaller{})
+	n.ElectionMutex.Lock()
+	n.Term = 4
+	n.ElectionMutex.Unlock()
+	if !n.admitTerm(0, "old", "snapshot") {
+		t.Error("term 0 from an older build refused")
+	}
+	if !n.admitTerm(6, "", "snapshot") || n.currentTerm() != 6 || n.leaderKnown() {
+		t.Errorf("newer term from an unknown sender: term %d, leader known %v", n.currentTerm(), n.leaderKnown())
+	}
+}
+
+// monitoring runs n's failure detector, as main does, until the test ends.
+func monitoring(t *testing.T, n *Node) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n.MonitorLeader()
+	}()
+	t.Cleanup(func() {
+		n.setPhase(PhaseStopped, "test over")
+		<-done
+		stepDown(n)
+	})
+}
+
+func coordinatorOf(n *Node) string {
+	n.ElectionMutex.Lock()
+	defer n.ElectionMutex.Unlock()
+	return n.Coordinator
+}
+
+func TestFastTimingsFailOverInUnderASecond(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		tn.HeartbeatInterval, tn.LeaderTimeout, tn.ElectionWait = 100*time.Millisecond, 300*time.Millisecond, 200*time.Millisecond
+		if err := CheckElectionTiming(tn.HeartbeatInterval, tn.LeaderTimeout, tn.ElectionWait, tn.MajorityLossWindow); err != nil {
+			t.Fatal(err)
+		}
+		monitoring(t, tn.Node)
+	}
+	waitFor(t, "C to win the first election", func() bool {
+		return coordinatorOf(a.Node) == c.ID && coordinatorOf(b.Node) == c.ID && coordinatorOf(c.Node) == c.ID
+	})
+
+	// C crashes: it stops answering and stops sending heartbeats.
+	c.kill()
+	c.setPhase(PhaseStopped, "crashed")
+	c.ElectionMutex.Lock()
+	c.setCoordinatorLocked("")
+	c.ElectionMutex.Unlock()
+	crashed := time.Now()
+	waitFor(t, "B to take over", func() bool { return coordinatorOf(a.Node) == b.ID && coordinatorOf(b.Node) == b.ID })
+	if took := time.Since(crashed); took > 800*time.Millisecond {
+		t.Errorf("failover took %s with a 300ms leader timeout", took)
+	}
+	if took := time.Since(crashed); took < b.LeaderTimeout/2 {
+		t.Errorf("failover after %s; B cannot have waited for the leader timeout", took)
+	}
+}
+
+func TestCheckElectionTiming(t *testing.T) {
+	ms := time.Millisecond
+	for _, tc := range []struct {
+		heartbeat, leaderTimeout, electionWait, window time.Duration
+		err                                            string
+	}{
+		{DefaultHeartbeatInterval, DefaultLeaderTimeout, DefaultElectionWait, DefaultMajorityLossWindow, ""},
+		{100 * ms, 300 * ms, 200 * ms, 0, ""},
+		{5 * ms, 300 * ms, 200 * ms, 0, "at least 10ms"},
+		{100 * ms, 200 * ms, 150 * ms, 0, "more than twice the heartbeat"},
+		{100 * ms, 300 * ms, 300 * ms, 0, "shorter than the leader timeout"},
+		{100 * ms, 300 * ms, 200 * ms, 200 * ms, "majority-loss window"},
+	} {
+		err := CheckElectionTiming(tc.heartbeat, tc.leaderTimeout, tc.electionWait, tc.window)
+		if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%s/%s/%s/%s: %v, want %q", tc.heartbeat, tc.leaderTimeout, tc.electionWait, tc.window, err, tc.err)
+		}
+	}
+}
+
+func TestCoordinatorAddressFromBullyMessages(t *testing.T) {
+	nodes := testCluster(t, "follower", "leader", "other")
+	f, l, o := nodes[0], nodes[1], nodes[2]
+	rp := &NodeRPC{node: f.Node}
+	var ok bool
+	coordinatorAddress := func() string {
+		addr, local := f.getCoordinatorAddress()
+		if local {
+			t.Fatal("follower thinks it leads")
+		}
+		return addr
+	}
+
+	// The leader's heartbeat says where it listens.
+	if err := rp.HandleHeartbeat(BullyMessage{NodeID: l.ID, Rank: l.Rank, Term: 1, Address: l.Address}, &ok); err != nil || !ok {
+		t.Fatalf("HandleHeartbeat: %v %v", ok, err)
+	}
+	if got := coordinatorAddress(); got != l.Address {
+		t.Errorf("coordinator address = %q, want %s", got, l.Address)
+	}
+	// It comes back on another port; its claim carries the new one.
+	moved := closedAddr(t)
+	if err := rp.HandleCoordinator(BullyMessage{NodeID: l.ID, Rank: l.Rank, Term: 2, Address: moved}, &ok); err != nil || !ok {
+		t.Fatalf("HandleCoordinator: %v %v", ok, err)
+	}
+	if got := coordinatorAddress(); got != moved {
+		t.Errorf("coordinator address after the move = %q, want %s", got, moved)
+	}
+
+	// An older build sends no address: found through /version, or not at all.
+	if err := rp.HandleHeartbeat(BullyMessage{NodeID: "legacy", Rank: 9, Term: 3}, &ok); err != nil || !ok {
+		t.Fatalf("HandleHeartbeat: %v %v", ok, err)
+	}
+	if got := coordinatorAddress(); got != "" {
+		t.Errorf("coordinator address of an unknown legacy leader = %q, want none", got)
+	}
+	f.recordPeerVersion(o.Address, &VersionInfo{NodeID: "legacy"})
+	if got := coordinatorAddress(); got != o.Address {
+		t.Errorf("coordinator address from /version = %q, want %s", got, o.Address)
+	}
+
+	// Election messages are remembered too.
+	if err := rp.HandleElection(BullyMessage{NodeID: "candidate", Rank: 10, Term: 3, Address: "10.0.0.7:9107"}, &ok); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.peerAddressByID("candidate"); got != "10.0.0.7:9107" {
+		t.Errorf("candidate's address = %q", got)
+	}
+}
+
+func TestEqualRanksElectTheGreaterID(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ids, ranks := []string{"eu-a", "eu-b", "eu-c"}, []int{1, 5, 5}
+	listeners := make([]net.Listener, len(ids))
+	addrs := make([]string, len(ids))
+	for i := range ids {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners[i], addrs[i] = l, l.Addr().String()
+	}
+	nodes := make([]*testNode, len(ids))
+	for i, id := range ids {
+		nodes[i] = serveTestNode(t, NewNode(id, addrs[i], addrs, ranks[i]), listeners[i], addrs)
+	}
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	if b.outranks(BullyMessage{NodeID: c.ID, Rank: 5}) || !c.outranks(BullyMessage{NodeID: b.ID, Rank: 5}) || !b.outranks(BullyMessage{NodeID: a.ID, Rank: 1}) {
+		t.Error("outranks does not break the tie by ID")
+	}
+
+	// eu-b campaigns; eu-c, tied on rank, takes over.
+	b.runElection()
+	waitFor(t, "everyone to follow eu-c", func() bool {
+		return coordinatorOf(a.Node) == c.ID && coordinatorOf(b.Node) == c.ID && coordinatorOf(c.Node) == c.ID
+	})
+	leading(t, c.Node)
+	if addr, _ := a.getCoordinatorAddress(); addr != c.Address {
+		t.Errorf("eu-a finds the leader at %q, want %s", addr, c.Address)
+	}
+}
@@ -0,0 +1,196 @@
+package node
+
+// canary.go — Continuous synthetic check of the bid write path.
+//
+// With --canary-interval set, the coordinator regularly runs a real bid
+// round for a hidden canary bid: Ricart–Agrawala, 2PC prepare on every peer,
+// decision and ACK collection. Participants prepare it like any bid but, on
+// commit, only bump their canary counter; no item, winner or snapshot
+// changes. Canary transactions are not written to the txn log.
+//
+// Heartbeats only prove that peers answer. The canary proves that a bid can
+// still get through, so it catches partial failures such as a wedged RA
+// manager. After canaryFailureThreshold consecutive failed rounds the
+// coordinator's /healthz reports Degraded (503) and the canary_failing alert
+// is raised until a round succeeds again.
+//
+// Canary bids carry a field older builds do not know. Those builds vote NO
+// on them, so leave the canary off while upgrading from such a build.
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	canaryTxnPrefix        = "canary-"
+	canaryItemID           = "canary"
+	canaryFailureThreshold = 3
+	canaryMaxWait          = 10 * time.Second // a round still running after this counts as failed
+)
+
+// CanaryStatus is the canary section of /healthz on the coordinator.
+type CanaryStatus struct {
+	IntervalSec         float64
+	Rounds              int
+	Failures            int
+	ConsecutiveFailures int
+	Degraded            bool
+	LastRoundUnix       int64
+	LastSuccessUnix     int64
+	LastLatencyMs       int64
+	LastError           string `json:",omitempty"`
+	CommitsApplied      int64  // canary commits applied on this node, as coordinator or participant
+}
+
+type canaryState struct {
+	mu      sync.Mutex
+	status  CanaryStatus
+	running bool // a round is in progress, possibly stuck
+}
+
+func isCanaryTxn(txnID string) bool {
+	return strings.HasPrefix(txnID, canaryTxnPrefix)
+}
+
+// runCanary drives canary rounds every CanaryInterval while this node is the
+// coordinator and ready.
+func (n *Node) runCanary() {
+	n.canary.mu.Lock()
+	n.canary.status.IntervalSec = n.CanaryInterval.Seconds()
+	n.canary.mu.Unlock()
+	log.Printf("[%s] 🐤 Canary rounds every %s while coordinator\n", n.ID, n.CanaryInterval)
+
+	ticker := time.NewTicker(n.CanaryInterval)
+	defer ticker.Stop()
+	var seq int
+	for range ticker.C {
+		if _, isLocal := n.getCoordinatorAddress(); !isLocal || !n.IsReady() {
+			n.clearCanaryDegraded()
+			continue
+		}
+		seq++
+		n.canaryRound(seq)
+	}
+}
+
+// canaryRound runs one round and waits at most canaryMaxWait for it.
+func (n *Node) canaryRound(seq int) {
+	n.canary.mu.Lock()
+	if n.canary.running {
+		n.canary.mu.Unlock()
+		n.recordCanary("stuck", 0, "previous canary round has not finished")
+		return
+	}
+	n.canary.running = true
+	n.canary.mu.Unlock()
+
+	bid := BidArgs{
+		Amount:      seq,
+		BidderID:    "canary",
+		DisplayName: "canary",
+		Origin:      n.ID,
+		ItemID:      canaryItemID,
+		Canary:      true,
+	}
+	start := time.Now()
+	done := make(chan CoordinatorBidReply, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), canaryMaxWait)
+	go func() {
+		defer cancel()
+		reply := n.proposeBid(ctx, bid)
+		n.canary.mu.Lock()
+		n.canary.running = false
+		n.canary.mu.Unlock()
+		done <- reply
+	}()
+
+	timer := time.NewTimer(canaryMaxWait)
+	defer timer.Stop()
+	select {
+	case reply := <-done:
+		if reply.Accepted {
+			n.recordCanary("ok", time.Since(start), "")
+		} else {
+			n.recordCanary("failed", time.Since(start), reply.Message)
+		}
+	case <-timer.C:
+		n.recordCanary("timeout", time.Since(start), fmt.Sprintf("round did not finish within %s", canaryMaxWait))
+	}
+}
+
+func (n *Node) recordCanary(result string, latency time.Duration, errMsg string) {
+	n.Metrics.Inc(metricName("canary_rounds_total", "result", result))
+	if latency > 0 {
+		n.Metrics.Histogram("canary_round_seconds").Observe(latency.Seconds())
+	}
+
+	n.canary.mu.Lock()
+	s := &n.canary.status
+	now := time.Now().Unix()
+	s.Rounds++
+	s.LastRoundUnix, s.LastLatencyMs = now, latency.Milliseconds()
+	wasDegraded := s.Degraded
+	if result == "ok" {
+		s.ConsecutiveFailures, s.LastError = 0, ""
+		s.LastSuccessUnix = now
+	} else {
+		s.Failures++
+		s.ConsecutiveFailures++
+		s.LastError = errMsg
+	}
+	s.Degraded = s.ConsecutiveFailures >= canaryFailureThreshold
+	degraded, failures := s.Degraded, s.ConsecutiveFailures
+	n.canary.mu.Unlock()
+	n.Metrics.Set("canary_consecutive_failures", float64(failures))
+
+	switch {
+	case result != "ok":
+		log.Printf("[%s] 🐤 Canary round %s: %s\n", n.ID, result, errMsg)
+	case wasDegraded:
+		log.Printf("[%s] 🐤 Canary round succeeded again\n", n.ID)
+	}
+	if degraded && !wasDegraded {
+		n.Alerts.Raise("canary_failing", SeverityCritical,
+			fmt.Sprintf("%d consecutive canary bid rounds failed: %s", failures, errMsg))
+	} else if !degraded && wasDegraded {
+		n.Alerts.Resolve("canary_failing", "canary bid rounds succeeding again")
+	}
+}
+
+// clearCanaryDegraded drops a degraded verdict once this node stops running
+// canary rounds, e.g. after losing leadership.
+func (n *Node) clearCanaryDegraded() {
+	n.canary.mu.Lock()
+	wasDegraded := n.canary.status.Degraded
+	n.canary.status.Degraded, n.canary.status.ConsecutiveFailures = false, 0
+	n.canary.mu.Unlock()
+	if wasDegraded {
+		n.Metrics.Set("canary_consecutive_failures", 0)
+		n.Alerts.Resolve("canary_failing", "no longer coordinator; canary not running here")
+	}
+}
+
+// noteCanaryCommit is applyDecision for a canary: it only bumps the counter.
+func (n *Node) noteCanaryCommit() {
+	n.canary.mu.Lock()
+	n.canary.status.CommitsApplied++
+	n.canary.mu.Unlock()
+	n.Metrics.Inc("canary_commits_applied_total")
+}
+
+// canaryStatus returns the canary state, or nil on a node that neither runs
+// canary rounds nor has taken part in one.
+func (n *Node) canaryStatus() *CanaryStatus {
+	n.canary.mu.Lock()
+	defer n.canary.mu.Unlock()
+	if n.CanaryInterval <= 0 && n.canary.status.CommitsApplied == 0 {
+		return nil
+	}
+	s := n.canary.status
+	return &s
+}
@@ -0,0 +1,76 @@
+package node
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanaryCatchesWedgedRA(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	// C outranks the others, so heartbeats from it stand unchallenged.
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, c.ID, c.Address)
+	}
+	leading(t, c.Node)
+	c.CanaryInterval = time.Minute
+	c.RA.RequestTimeout = 100 * time.Millisecond
+
+	c.canaryRound(1)
+	if code, h := healthz(t, c.Node); code != http.StatusOK || h.Degraded || h.Canary == nil || h.Canary.LastSuccessUnix == 0 {
+		t.Fatalf("healthy canary: %d %+v", code, h.Canary)
+	}
+
+	// B takes the critical section and never lets go, but still answers.
+	if err := b.RA.RequestCS(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 2; i <= canaryFailureThreshold+1; i++ {
+		c.ElectionMutex.Lock()
+		term := c.Term
+		c.ElectionMutex.Unlock()
+		var ok bool
+		if err := c.callPeer(b.Address, "NodeRPC.HandleHeartbeat", BullyMessage{NodeID: c.ID, Rank: c.Rank, Term: term, Address: c.Address}, &ok); err != nil {
+			t.Fatalf("heartbeat to B: %v", err)
+		}
+		c.canaryRound(i)
+		code, h := healthz(t, c.Node)
+		if failing := i - 1; h.Canary.ConsecutiveFailures != failing || h.Degraded != (failing >= canaryFailureThreshold) {
+			t.Errorf("after %d failed rounds: %d %+v", failing, code, h.Canary)
+		}
+		if !strings.Contains(h.Canary.LastError, "did not answer") {
+			t.Errorf("last error = %q", h.Canary.LastError)
+		}
+	}
+	if code, h := healthz(t, c.Node); code != http.StatusServiceUnavailable || !h.Ready || !h.Degraded {
+		t.Errorf("wedged RA: %d ready=%v degraded=%v, want 503, ready and degraded", code, h.Ready, h.Degraded)
+	}
+	if !c.Alerts.IsActive("canary_failing") {
+		t.Error("canary_failing alert not raised")
+	}
+	if got := c.Metrics.Counter(metricName("canary_rounds_total", "result", "failed")); got != canaryFailureThreshold {
+		t.Errorf("canary_rounds_total{result=failed} = %v, want %d", got, canaryFailureThreshold)
+	}
+
+	b.RA.ReleaseCS()
+	c.canaryRound(canaryFailureThreshold + 2)
+	if code, h := healthz(t, c.Node); code != http.StatusOK || h.Degraded || h.Canary.ConsecutiveFailures != 0 {
+		t.Errorf("after recovery: %d %+v", code, h.Canary)
+	}
+	if c.Alerts.IsActive("canary_failing") {
+		t.Error("canary_failing alert still active after a good round")
+	}
+
+	// Participants only count canary commits; the real lot is untouched.
+	for _, tn := range []*testNode{a, b} {
+		if s := tn.canaryStatus(); s == nil || s.CommitsApplied != 2 {
+			t.Errorf("%s canary status = %+v, want 2 commits applied", tn.ID, s)
+		}
+		if got := highestBid(tn.Node); got != 10 {
+			t.Errorf("%s highest bid = %d after canary rounds, want 10", tn.ID, got)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package node
+
+// catalogue.go — Cold-start catalogue import from --items-url.
+//
+// A node that boots without a checkpoint normally seeds the built-in demo
+// items. With --items-url, the first node of a fresh cluster to become
+// coordinator downloads the catalogue instead, checks it against
+// --items-sha256 when given, validates it with the /items/batch rules and
+// replaces the untouched default queue with it. Followers never fetch: the
+// new queue reaches them with the next snapshot.
+//
+// A good download is cached under checkpoints/, so a later cold start of the
+// same node does not depend on the remote being up. If the download fails
+// the cache is used, and failing that the defaults stay, with a warning in
+// the log and an alert. Nothing is fetched once the cluster has state of its
+// own: a checkpoint, a started auction, results, or a changed queue.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const catalogueFetchTimeout = 15 * time.Second
+
+func catalogueCachePath(nodeID string) string {
+	return filepath.Join(checkpointDir, fmt.Sprintf("catalogue_%s.json", nodeID))
+}
+
+// parseCatalogue reads a catalogue in the /items/batch format (an array of
+// items, or {"items": [...]}) and validates every entry.
+func parseCatalogue(body []byte) ([]AddItemArgs, error) {
+	var entries []TemplateItem
+	if err := json.Unmarshal(body, &entries); err != nil {
+		var wrapped struct {
+			Items []TemplateItem `json:"items"`
+		}
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, errors.New(`expected a JSON array of items or {"items": [...]}`)
+		}
+		entries = wrapped.Items
+	}
+	switch {
+	case len(entries) == 0:
+		return nil, errors.New("catalogue has no items")
+	case len(entries) > maxBatchItems:
+		return nil, fmt.Errorf("catalogue has %d items; the limit is %d", len(entries), maxBatchItems)
+	}
+	items := make([]AddItemArgs, len(entries))
+	for i, e := range entries {
+		items[i] = e.addItemArgs()
+	}
+	if errs := validateBatch(items); len(errs) > 0 {
+		return nil, fmt.Errorf("item %d: %s (%d invalid item(s) in total)", errs[0].Index, errs[0].Error, len(errs))
+	}
+	return items, nil
+}
+
+// checkDigest compares body with the expected hex SHA-256, if one is set.
+func checkDigest(body []byte, want string) error {
+	if want == "" {
+		return nil
+	}
+	sum := sha256.Sum256(body)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return fmt.Errorf("checksum mismatch: got sha256 %s, want %s", got, want)
+	}
+	return nil
+}
+
+// fetchCatalogue downloads, verifies and parses the catalogue at url.
+func fetchCatalogue(url, sha string) ([]byte, []AddItemArgs, error) {
+	client := &http.Client{Timeout: catalogueFetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBatchBodyBytes+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) > maxBatchBodyBytes {
+		return nil, nil, fmt.Errorf("catalogue is larger than %d bytes", maxBatchBodyBytes)
+	}
+	if err := checkDigest(body, sha); err != nil {
+		return nil, nil, err
+	}
+	items, err := parseCatalogue(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, items, nil
+}
+
+// cacheCatalogue saves a verified download for later cold starts.
+func cacheCatalogue(nodeID string, body []byte) error {
+	if err := os.MkdirAll(checkpointDir, 0o755); err != nil {
+		return err
+	}
+	tmp := catalogueCachePath(nodeID) + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, catalogueCachePath(nodeID))
+}
+
+// loadCachedCatalogue reads the catalogue cached by an earlier fetch. With
+// --items-sha256 set, a cache of another version is ignored.
+func loadCachedCatalogue(nodeID, sha string) ([]AddItemArgs, error) {
+	body, err := os.ReadFile(catalogueCachePath(nodeID))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDigest(body, sha); err != nil {
+		return nil, err
+	}
+	return parseCatalogue(body)
+}
+
+// hasDefaultQueueLocked reports whether the queue is still exactly the
+// built-in seed: nothing started, sold, added or removed. Must hold Queue.mu.
+func (n *Node) hasDefaultQueueLocked() bool {
+	q := n.Queue
+	if q.Active || q.CurrentItem != nil || len(q.Results) > 0 || q.ResultsTrimmed > 0 || q.Announcement != nil {
+		return false
+	}
+	defaults := defaultItems()
+	if len(q.Queue) != len(defaults) {
+		return false
+	}
+	for i, item := range q.Queue {
+		if item.ID != defaults[i].ID || item.Name != defaults[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+// seedCatalogueFromURL replaces the default queue with the --items-url
+// catalogue. Coordinator only, on a cold start; a no-op otherwise.
+func (n *Node) seedCatalogueFromURL() {
+	// One attempt per boot; later leaderships keep what the cluster has.
+	if n.ItemsURL == "" || !n.coldStart.CompareAndSwap(true, false) {
+		return
+	}
+	n.Queue.mu.Lock()
+	pristine := n.hasDefaultQueueLocked()
+	n.Queue.mu.Unlock()
+	if !pristine {
+		log.Printf("[%s] Cluster already has a catalogue; not importing %s\n", n.ID, n.ItemsURL)
+		return
+	}
+
+	source := n.ItemsURL
+	body, items, err := fetchCatalogue(n.ItemsURL, n.ItemsSHA256)
+	if err == nil {
+		if werr := cacheCatalogue(n.ID, body); werr != nil {
+			log.Printf("[%s] Warning: could not cache catalogue: %v\n", n.ID, werr)
+		}
+		n.Metrics.Inc(metricName("catalogue_imports_total", "source", "url"))
+	} else {
+		log.Printf("[%s] ⚠️  ==============================================================\n", n.ID)
+		log.Printf("[%s] ⚠️  Could not import catalogue from %s: %v\n", n.ID, n.ItemsURL, err)
+		var cacheErr error
+		if items, cacheErr = loadCachedCatalogue(n.ID, n.ItemsSHA256); cacheErr == nil {
+			source = catalogueCachePath(n.ID)
+			log.Printf("[%s] ⚠️  Using the cached copy in %s\n", n.ID, source)
+			n.Metrics.Inc(metricName("catalogue_imports_total", "source", "cache"))
+		} else {
+			log.Printf("[%s] ⚠️  No usable cached copy (%v); keeping the built-in demo items\n", n.ID, cacheErr)
+			n.Metrics.Inc(metricName("catalogue_imports_total", "source", "defaults"))
+		}
+		log.Printf("[%s] ⚠️  ==============================================================\n", n.ID)
+		n.Alerts.Notify("catalogue_import_failed", SeverityWarning,
+			fmt.Sprintf("Catalogue import from %s failed: %v", n.ItemsURL, err))
+		if items == nil {
+			return
+		}
+	}
+
+	n.Queue.mu.Lock()
+	if !n.hasDefaultQueueLocked() {
+		// An admin changed the queue while we were downloading; theirs wins.
+		n.Queue.mu.Unlock()
+		log.Printf("[%s] Queue changed during catalogue import; discarding %s\n", n.ID, source)
+		return
+	}
+	n.Queue.Queue = nil
+	for _, args := range items {
+		n.Queue.Queue = append(n.Queue.Queue, newAuctionItem(n.nextItemIDLocked(), args))
+	}
+	n.Queue.touchLocked()
+	n.Queue.mu.Unlock()
+	log.Printf("[%s] 📦 Seeded %d item(s) from %s\n", n.ID, len(items), source)
+	n.broadcastQueueState()
+}
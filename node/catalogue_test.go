@@ -0,0 +1,168 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+const springCatalogue = `{"items": [
+  {"name": "Tulip bulbs", "description": "Bag of 20", "startingPrice": 15, "durationSec": 45, "category": "Garden"},
+  {"name": "Bird bath", "description": "Stone", "startingPrice": 60, "durationSec": 60}
+]}`
+
+// serveCatalogue serves body at /catalogue.json and counts the downloads.
+func serveCatalogue(t *testing.T, body string) (string, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/catalogue.json", &hits
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func queuedNames(n *Node) []string {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	names := make([]string, len(n.Queue.Queue))
+	for i, item := range n.Queue.Queue {
+		names[i] = item.Name
+	}
+	return names
+}
+
+func defaultNames() []string {
+	var names []string
+	for _, item := range defaultItems() {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+// coldNode is a fresh node with no checkpoint, importing from url.
+func coldNode(t *testing.T, url, sha string) *Node {
+	t.Helper()
+	n := NewNode("A", "127.0.0.1:9", nil, 1)
+	n.ItemsURL, n.ItemsSHA256 = url, sha
+	return n
+}
+
+func TestCatalogueImportReachesFollowers(t *testing.T) {
+	url, hits := serveCatalogue(t, springCatalogue)
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		tn.ItemsURL, tn.ItemsSHA256 = url, sha256Hex(springCatalogue)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+
+	a.seedCatalogueFromURL()
+	want := []string{"Tulip bulbs", "Bird bath"}
+	if got := queuedNames(a.Node); !reflect.DeepEqual(got, want) {
+		t.Fatalf("A's queue = %v, want %v", got, want)
+	}
+	waitFor(t, "B to adopt the catalogue", func() bool { return reflect.DeepEqual(queuedNames(b.Node), want) })
+	if cached, err := os.ReadFile(catalogueCachePath(a.ID)); err != nil || string(cached) != springCatalogue {
+		t.Errorf("cache = %q, %v", cached, err)
+	}
+	if got := a.Metrics.Counter(metricName("catalogue_imports_total", "source", "url")); got != 1 {
+		t.Errorf("catalogue_imports_total{source=url} = %v, want 1", got)
+	}
+
+	// Only one attempt per boot, and followers never fetch.
+	a.seedCatalogueFromURL()
+	if got := hits.Load(); got != 1 {
+		t.Errorf("catalogue fetched %d times, want once", got)
+	}
+	if _, err := os.Stat(catalogueCachePath(b.ID)); !os.IsNotExist(err) {
+		t.Errorf("B cached a catalogue of its own: %v", err)
+	}
+}
+
+func TestCatalogueChecksumMismatchKeepsDefaults(t *testing.T) {
+	t.Chdir(t.TempDir())
+	url, _ := serveCatalogue(t, springCatalogue)
+	n := coldNode(t, url, sha256Hex("a different catalogue"))
+	n.seedCatalogueFromURL()
+	if got := queuedNames(n); !reflect.DeepEqual(got, defaultNames()) {
+		t.Errorf("queue after a checksum mismatch = %v, want the defaults", got)
+	}
+	if !slices.Contains(delivered(n.Alerts), "catalogue_import_failed") {
+		t.Errorf("alerts %v lack catalogue_import_failed", delivered(n.Alerts))
+	}
+	if got := n.Metrics.Counter(metricName("catalogue_imports_total", "source", "defaults")); got != 1 {
+		t.Errorf("catalogue_imports_total{source=defaults} = %v, want 1", got)
+	}
+	if _, err := os.Stat(catalogueCachePath(n.ID)); !os.IsNotExist(err) {
+		t.Errorf("an unverified catalogue was cached: %v", err)
+	}
+}
+
+func TestCatalogueFallsBackToCache(t *testing.T) {
+	t.Chdir(t.TempDir())
+	url, _ := serveCatalogue(t, springCatalogue)
+	coldNode(t, url, "").seedCatalogueFromURL()
+
+	// The next cold start finds the remote down and uses the cached copy.
+	down := "http://" + closedAddr(t) + "/catalogue.json"
+	n := coldNode(t, down, sha256Hex(springCatalogue))
+	n.seedCatalogueFromURL()
+	if got := queuedNames(n); !reflect.DeepEqual(got, []string{"Tulip bulbs", "Bird bath"}) {
+		t.Errorf("queue from the cache = %v", got)
+	}
+	if got := n.Metrics.Counter(metricName("catalogue_imports_total", "source", "cache")); got != 1 {
+		t.Errorf("catalogue_imports_total{source=cache} = %v, want 1", got)
+	}
+
+	// A cache of another version is not used either.
+	n = coldNode(t, down, sha256Hex("next season's catalogue"))
+	n.seedCatalogueFromURL()
+	if got := queuedNames(n); !reflect.DeepEqual(got, defaultNames()) {
+		t.Errorf("queue with a stale cache = %v, want the defaults", got)
+	}
+}
+
+func TestCatalogueImportSkipsAndRejects(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// An invalid catalogue fails validation like a bad /items/batch.
+	bad, _ := serveCatalogue(t, `[{"name": "", "startingPrice": -1, "durationSec": 30}]`)
+	n := coldNode(t, bad, "")
+	n.seedCatalogueFromURL()
+	if got := queuedNames(n); !reflect.DeepEqual(got, defaultNames()) {
+		t.Errorf("queue after an invalid catalogue = %v", got)
+	}
+
+	// A queue someone already changed is left alone, and nothing is fetched.
+	url, hits := serveCatalogue(t, springCatalogue)
+	n = coldNode(t, url, "")
+	n.Queue.mu.Lock()
+	n.Queue.Queue = n.Queue.Queue[1:]
+	n.Queue.mu.Unlock()
+	n.seedCatalogueFromURL()
+	if hits.Load() != 0 || len(queuedNames(n)) != len(defaultNames())-1 {
+		t.Errorf("changed queue: %d fetches, queue %v", hits.Load(), queuedNames(n))
+	}
+
+	for _, body := range []string{`{"items": []}`, `"just a string"`} {
+		if _, err := parseCatalogue([]byte(body)); err == nil {
+			t.Errorf("parseCatalogue(%s) succeeded", body)
+		}
+	}
+	if items, err := parseCatalogue([]byte(springCatalogue)); err != nil || len(items) != 2 || items[0].Category != "Garden" {
+		t.Errorf("wrapped catalogue: %+v, %v", items, err)
+	}
+}
@@ -12,6 +12,7 @@ package node
 //  4) Commit moves tentative file atomically to stable checkpoint file.
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -28,18 +29,33 @@ const (
 
 // CheckpointData is the full serialisable state of a node, written to disk.
 type CheckpointData struct {
+	SchemaVersion     int                             `json:"schemaVersion"` // see checkpointschema.go
 	NodeID            string                          `json:"nodeId"`
 	LamportTime       int                             `json:"lamportTime"`
 	CurrentItem       *AuctionItem                    `json:"currentItem"`
 	RemainingQueue    []AuctionItem                   `json:"remainingQueue"`
 	Results           []ItemResult                    `json:"results"`
+	ResultsTrimmed    int                             `json:"resultsTrimmed,omitempty"`
+	Announcement      *SoldAnnouncement               `json:"announcement,omitempty"`
+	Shuffle           *ShuffleRecord                  `json:"shuffle,omitempty"`
 	CurrentHighestBid int                             `json:"currentHighestBid"`
 	CurrentWinner     string                          `json:"currentWinner"`
+	CurrentWinnerID   string                          `json:"currentWinnerId,omitempty"`
 	DeadlineUnix      int64                           `json:"deadlineUnix"`
+	GraceFromUnix     int64                           `json:"graceFromUnix,omitempty"`
+	OpenedAtUnix      int64                           `json:"openedAtUnix"`
 	Active            bool                            `json:"active"`
 	PendingTxns       map[string]PendingTxnCheckpoint `json:"pendingTxns"`
-	CheckpointTime    int64                           `json:"checkpointTime"` // wall-clock Unix
-	LamportStamp      int                             `json:"lamportStamp"`   // Lamport time at checkpoint
+	Budgets           map[string]BidderBudget         `json:"budgets,omitempty"` // see budget.go
+	Paddles           *PaddleBook                     `json:"paddles,omitempty"` // see paddles.go
+	BidBook           map[string][]BidRecord          `json:"bidBook,omitempty"` // see mybids.go
+	Peers             []string                        `json:"peers,omitempty"`   // learned membership
+	ConfigOverrides   map[string]json.RawMessage      `json:"configOverrides,omitempty"`
+	ConfigVersion     int                             `json:"configVersion,omitempty"`
+	ElectionTerm      int                             `json:"electionTerm,omitempty"` // highest Bully term seen
+	Incidents         []Incident                      `json:"incidents,omitempty"`    // see incidents.go
+	CheckpointTime    int64                           `json:"checkpointTime"`         // wall-clock Unix
+	LamportStamp      int                             `json:"lamportStamp"`           // Lamport time at checkpoint
 }
 
 type PendingTxnCheckpoint struct {
@@ -92,24 +108,36 @@ func loadCheckpoint(nodeID string) (*CheckpointData, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read checkpoint: %w", err)
 	}
-	var data CheckpointData
-	if err := json.Unmarshal(b, &data); err != nil {
-		return nil, fmt.Errorf("parse checkpoint: %w", err)
+	data, version, err := MigrateCheckpoint(b)
+	if err != nil {
+		return nil, err
+	}
+	if version != CheckpointSchemaVersion {
+		log.Printf("[%s] Migrated checkpoint from schema v%d to v%d\n", nodeID, version, CheckpointSchemaVersion)
 	}
-	return &data, nil
+	return data, nil
 }
 
 func (n *Node) buildCheckpointData() CheckpointData {
 	n.Queue.mu.Lock()
 	data := CheckpointData{
+		SchemaVersion:     CheckpointSchemaVersion,
 		NodeID:            n.ID,
 		LamportTime:       n.Clock.Get(),
 		LamportStamp:      n.Clock.Get(),
 		CurrentHighestBid: n.Queue.CurrentHighestBid,
 		CurrentWinner:     n.Queue.CurrentWinner,
+		CurrentWinnerID:   n.Queue.CurrentWinnerID,
 		DeadlineUnix:      n.Queue.DeadlineUnix,
+		GraceFromUnix:     n.Queue.GraceFromUnix,
+		OpenedAtUnix:      n.Queue.OpenedAtUnix,
 		Active:            n.Queue.Active,
 		Results:           append([]ItemResult(nil), n.Queue.Results...),
+		ResultsTrimmed:    n.Queue.ResultsTrimmed,
+		Announcement:      n.Queue.Announcement,
+		Shuffle:           n.Queue.LastShuffle,
+		Budgets:           copyBudgets(n.Queue.Budgets),
+		Paddles:           n.Queue.Paddles,
 		RemainingQueue:    append([]AuctionItem(nil), n.Queue.Queue...),
 		PendingTxns:       map[string]PendingTxnCheckpoint{},
 		CheckpointTime:    time.Now().Unix(),
@@ -120,6 +148,12 @@ func (n *Node) buildCheckpointData() CheckpointData {
 	}
 	n.Queue.mu.Unlock()
 
+	data.Peers = n.peerList()
+	data.BidBook = n.bids.snapshot()
+	data.ConfigOverrides, data.ConfigVersion = n.configOverrides()
+	data.ElectionTerm = n.currentTerm()
+	data.Incidents = n.incidents.list()
+
 	n.TxnMutex.Lock()
 	for txnID, pending := range n.PendingTxns {
 		data.PendingTxns[txnID] = PendingTxnCheckpoint{
@@ -139,6 +173,9 @@ func (n *Node) takeLocalCheckpoint() error {
 	if err := saveCheckpoint(data); err != nil {
 		return err
 	}
+	if b, err := json.Marshal(data); err == nil {
+		n.recordCheckpointHistory(b)
+	}
 	log.Printf("[%s] 📸 Checkpoint saved (lamport=%d, item=%v, results=%d, pendingTxns=%d)\n",
 		n.ID, data.LamportStamp, itemName(data.CurrentItem), len(data.Results), len(data.PendingTxns))
 	return nil
@@ -173,6 +210,7 @@ func (n *Node) commitTentativeCheckpoint(roundID string) error {
 		return fmt.Errorf("rename final checkpoint: %w", err)
 	}
 	_ = os.Remove(tentative)
+	n.recordCheckpointHistory(b)
 	log.Printf("[%s] ✅ Committed checkpoint round=%s\n", n.ID, roundID)
 	return nil
 }
@@ -240,6 +278,7 @@ func (n *Node) handleKTTentativeRequest(args KTTentativeArgs) (bool, []string, s
 			LamportTime: n.Clock.Get(),
 			From:        n.Address,
 			Visited:     nextVisited,
+			Term:        args.Term,
 		}, &reply)
 		if err != nil || !reply.OK {
 			n.abortTentativeCheckpoint(args.RoundID)
@@ -320,40 +359,44 @@ func (n *Node) initiateGlobalCheckpoint() {
 		LamportTime: lamport,
 		From:        n.Address,
 		Visited:     []string{n.Address},
+		Term:        n.currentTerm(),
 	})
 
 	participantSet := sliceToSet(participants)
 	if !ok {
 		log.Printf("[%s] ⚠️ Koo-Toueg tentative phase failed: %s\n", n.ID, reason)
 		n.finalizeKTRound(roundID, false)
+		n.noteCheckpointResult(false, reason)
 		return
 	}
 
 	n.finalizeKTRound(roundID, true)
+	n.noteCheckpointResult(true, "")
 
 	type finalizeResult struct {
 		peer string
 		err  error
 	}
 	finalizeCh := make(chan finalizeResult, len(participantSet))
+	others := make([]string, 0, len(participantSet))
 	for peer := range participantSet {
-		if peer == n.Address {
-			continue
+		if peer != n.Address {
+			others = append(others, peer)
 		}
-		go func(p string) {
-			var ack bool
-			err := n.callPeer(p, "NodeRPC.HandleKTFinalizeCheckpoint", KTFinalizeArgs{RoundID: roundID, Commit: true}, &ack)
-			if err != nil || !ack {
-				finalizeCh <- finalizeResult{peer: p, err: fmt.Errorf("finalize failed")}
-				return
-			}
-			finalizeCh <- finalizeResult{peer: p, err: nil}
-		}(peer)
 	}
+	fanOut(others, func(p string) {
+		var ack bool
+		err := n.callPeer(p, "NodeRPC.HandleKTFinalizeCheckpoint", KTFinalizeArgs{RoundID: roundID, Commit: true}, &ack)
+		if err != nil || !ack {
+			finalizeCh <- finalizeResult{peer: p, err: fmt.Errorf("finalize failed")}
+			return
+		}
+		finalizeCh <- finalizeResult{peer: p, err: nil}
+	})
 
 	timer := time.NewTimer(checkpointAckTimeout)
 	defer timer.Stop()
-	remaining := len(participantSet) - 1
+	remaining := len(others)
 	for remaining > 0 {
 		select {
 		case res := <-finalizeCh:
@@ -373,15 +416,16 @@ func (n *Node) initiateGlobalCheckpoint() {
 		n.ID, roundID, len(participantSet))
 }
 
-// runPeriodicCheckpointing triggers a global checkpoint every 30s (coordinator only).
-func (n *Node) runPeriodicCheckpointing() {
+// runPeriodicCheckpointing triggers a global checkpoint every 30s until ctx,
+// the spell as coordinator, ends.
+func (n *Node) runPeriodicCheckpointing(ctx context.Context) {
 	ticker := time.NewTicker(checkpointInterval)
 	defer ticker.Stop()
-	for range ticker.C {
-		n.ElectionMutex.Lock()
-		isCoordinator := n.Coordinator == n.ID
-		n.ElectionMutex.Unlock()
-		if isCoordinator {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 			go n.initiateGlobalCheckpoint()
 		}
 	}
@@ -0,0 +1,116 @@
+package node
+
+// checkpointschema.go — Checkpoint schema versions and the migrations that
+// bring older files up to date.
+//
+// Every checkpoint records the schemaVersion it was written with. Files from
+// before versioning count as version 1. Loading decodes the file as generic
+// JSON, runs each migration from its version up to CheckpointSchemaVersion,
+// and only then decodes into CheckpointData, so a migration can rename or
+// reshape fields as well as fill them in. A file from a newer build is
+// refused: decoding it would silently drop the fields this build does not
+// know, and the next checkpoint would overwrite them for good.
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CheckpointSchemaVersion is the version written by this build.
+//
+//	1  original format: bids and winners are identified by display name only
+//	2  bidder IDs on the current winner, results and pending bids; schemaVersion
+const CheckpointSchemaVersion = 2
+
+// ErrCheckpointTooNew is returned for a checkpoint written by a newer build.
+type ErrCheckpointTooNew struct {
+	Version int
+}
+
+func (e ErrCheckpointTooNew) Error() string {
+	return fmt.Sprintf("checkpoint schema version %d is newer than this build supports (%d); upgrade the node instead of loading it with an older build",
+		e.Version, CheckpointSchemaVersion)
+}
+
+// checkpointMigrations[v] upgrades a decoded version-v checkpoint to v+1 in
+// place. Migrations must tolerate fields that are already present: files
+// written between the addition of a field and the next version bump carry it
+// without the matching schemaVersion.
+var checkpointMigrations = map[int]func(cp map[string]interface{}) error{
+	1: migrateCheckpointV1,
+}
+
+// migrateCheckpointV1 derives the bidder IDs that version 2 stores alongside
+// display names, exactly as withIdentity does for bids from older peers.
+func migrateCheckpointV1(cp map[string]interface{}) error {
+	if winner, _ := cp["currentWinner"].(string); winner != "" && cp["currentWinnerId"] == nil {
+		cp["currentWinnerId"] = legacyBidderID(winner)
+	}
+	results, _ := cp["results"].([]interface{})
+	for _, r := range results {
+		result, ok := r.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("results: unexpected %T", r)
+		}
+		winner, _ := result["Winner"].(string)
+		if id, _ := result["WinnerID"].(string); id == "" && winner != "" && winner != "No bids" {
+			result["WinnerID"] = legacyBidderID(winner)
+		}
+	}
+	pending, _ := cp["pendingTxns"].(map[string]interface{})
+	for txnID, p := range pending {
+		txn, ok := p.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("pendingTxns[%s]: unexpected %T", txnID, p)
+		}
+		bid, ok := txn["bid"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := bid["DisplayName"].(string)
+		if name == "" {
+			name, _ = bid["Bidder"].(string)
+			bid["DisplayName"] = name
+		}
+		if id, _ := bid["BidderID"].(string); id == "" && name != "" {
+			bid["BidderID"] = legacyBidderID(name)
+		}
+	}
+	return nil
+}
+
+// MigrateCheckpoint decodes a checkpoint file of any supported version into
+// the current schema. It also returns the version the file was written with.
+func MigrateCheckpoint(b []byte) (*CheckpointData, int, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, 0, fmt.Errorf("parse checkpoint: %w", err)
+	}
+	version := 1
+	if v, ok := raw["schemaVersion"].(float64); ok && v > 0 {
+		version = int(v)
+	}
+	if version > CheckpointSchemaVersion {
+		return nil, version, ErrCheckpointTooNew{Version: version}
+	}
+	for v := version; v < CheckpointSchemaVersion; v++ {
+		migrate, ok := checkpointMigrations[v]
+		if !ok {
+			return nil, version, fmt.Errorf("no migration from checkpoint schema version %d", v)
+		}
+		if err := migrate(raw); err != nil {
+			return nil, version, fmt.Errorf("migrate checkpoint v%d→v%d: %w", v, v+1, err)
+		}
+	}
+	raw["schemaVersion"] = CheckpointSchemaVersion
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, version, fmt.Errorf("re-encode checkpoint: %w", err)
+	}
+	var data CheckpointData
+	if err := json.Unmarshal(migrated, &data); err != nil {
+		return nil, version, fmt.Errorf("parse migrated checkpoint: %w", err)
+	}
+	return &data, version, nil
+}
@@ -0,0 +1,104 @@
+package node
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestMigrateCheckpoint(t *testing.T) {
+	cases := []struct {
+		fixture                       string
+		version                       int
+		winnerID, resultID, pendingID string
+	}{
+		// Version 1 carries names only; the IDs are derived from them.
+		{"checkpoint_v1.json", 1, legacyBidderID("Ann"), legacyBidderID("Bob"), legacyBidderID("Carol")},
+		// Version 2 IDs are kept as written.
+		{"checkpoint_v2.json", 2, "b-ann", "b-bob", "b-carol"},
+	}
+	for _, c := range cases {
+		t.Run(c.fixture, func(t *testing.T) {
+			cp, version, err := MigrateCheckpoint(readFixture(t, c.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != c.version || cp.SchemaVersion != CheckpointSchemaVersion {
+				t.Errorf("version = %d → %d, want %d → %d", version, cp.SchemaVersion, c.version, CheckpointSchemaVersion)
+			}
+			if cp.CurrentWinner != "Ann" || cp.CurrentWinnerID != c.winnerID {
+				t.Errorf("current winner = %q/%q, want Ann/%q", cp.CurrentWinner, cp.CurrentWinnerID, c.winnerID)
+			}
+			if len(cp.Results) != 2 {
+				t.Fatalf("results = %d, want 2", len(cp.Results))
+			}
+			if got := cp.Results[0].WinnerID; got != c.resultID {
+				t.Errorf("sold result WinnerID = %q, want %q", got, c.resultID)
+			}
+			if got := cp.Results[1].WinnerID; got != "" {
+				t.Errorf("unsold result WinnerID = %q, want none", got)
+			}
+			bid := cp.PendingTxns["N1-7"].Bid
+			if bid.DisplayName != "Carol" || bid.BidderID != c.pendingID {
+				t.Errorf("pending bid = %q/%q, want Carol/%q", bid.DisplayName, bid.BidderID, c.pendingID)
+			}
+		})
+	}
+}
+
+func TestRestoreMigratedCheckpoint(t *testing.T) {
+	for _, fixture := range []string{"checkpoint_v1.json", "checkpoint_v2.json"} {
+		t.Run(fixture, func(t *testing.T) {
+			b := readFixture(t, fixture)
+			want, _, err := MigrateCheckpoint(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Chdir(t.TempDir())
+			if err := os.MkdirAll(checkpointDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(checkpointPath("N1"), b, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			n := NewNode("N1", "127.0.0.1:9", nil, 1)
+			n.Queue.mu.Lock()
+			item, winnerID, highest := n.Queue.CurrentItem, n.Queue.CurrentWinnerID, n.Queue.CurrentHighestBid
+			results, remaining := len(n.Queue.Results), len(n.Queue.Queue)
+			n.Queue.mu.Unlock()
+			if item == nil || item.ID != "lot2" || highest != 150 || winnerID != want.CurrentWinnerID {
+				t.Errorf("restored lot %v at %d to %q, want lot2 at 150 to %q", item, highest, winnerID, want.CurrentWinnerID)
+			}
+			if results != 2 || remaining != 1 {
+				t.Errorf("restored %d results and %d lots to come, want 2 and 1", results, remaining)
+			}
+			n.TxnMutex.Lock()
+			pending, ok := n.PendingTxns["N1-7"]
+			n.TxnMutex.Unlock()
+			if !ok || pending.Bid.BidderID != want.PendingTxns["N1-7"].Bid.BidderID {
+				t.Errorf("restored pending txn = %+v, %v", pending, ok)
+			}
+		})
+	}
+}
+
+func TestMigrateCheckpointTooNew(t *testing.T) {
+	b := bytes.Replace(readFixture(t, "checkpoint_v2.json"), []byte(`"schemaVersion": 2`), []byte(`"schemaVersion": 99`), 1)
+	_, version, err := MigrateCheckpoint(b)
+	var tooNew ErrCheckpointTooNew
+	if !errors.As(err, &tooNew) || tooNew.Version != 99 || version != 99 {
+		t.Fatalf("MigrateCheckpoint of a v99 file: version %d, err %v; want ErrCheckpointTooNew{99}", version, err)
+	}
+}
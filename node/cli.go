@@ -2,6 +2,7 @@ package node
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strconv"
@@ -54,6 +55,7 @@ func (n *Node) StartCLI() {
 			n.handleCLIControl("restart")
 		case "exit", "quit":
 			fmt.Println("Exiting process...")
+			n.Stop()
 			os.Exit(0)
 		default:
 			fmt.Printf("Unknown command: %s. Type 'help' for usage.\n", cmd)
@@ -101,6 +103,7 @@ func (n *Node) printStatus() {
 	fmt.Printf("Items in Queue: %d\n", snap.QueueLen)
 	fmt.Printf("Items Sold:     %d\n", len(snap.Results))
 	fmt.Printf("Is Leader:      %v\n", snap.IsCoordinator)
+	fmt.Printf("Node Phase:     %s\n", snap.NodePhase)
 	fmt.Println("----------------------")
 }
 
@@ -120,10 +123,11 @@ func (n *Node) printQueue() {
 
 func (n *Node) printPeers() {
 	fmt.Println("\n--- Peer Nodes ---")
-	if len(n.Peers) == 0 {
+	peers := n.peerList()
+	if len(peers) == 0 {
 		fmt.Println("No peers configured.")
 	} else {
-		for i, p := range n.Peers {
+		for i, p := range peers {
 			fmt.Printf("[%d] %s\n", i+1, p)
 		}
 	}
@@ -142,10 +146,19 @@ func (n *Node) handleCLIBid(args []string) {
 		return
 	}
 
-	bidder := n.ID
+	bid := BidArgs{Amount: amount, BidderID: "cli-" + n.ID, Origin: n.ID}
 	if len(args) >= 2 {
-		bidder = strings.Join(args[1:], " ")
+		bid.DisplayName = strings.Join(args[1:], " ")
+		bid.BidderID = ""
+	} else {
+		bid.DisplayName = guestName(bid.BidderID)
+	}
+	n.Queue.mu.Lock()
+	if n.Queue.CurrentItem != nil {
+		bid.ItemID = n.Queue.CurrentItem.ID
 	}
+	n.Queue.mu.Unlock()
+	bid = bid.withIdentity()
 
 	coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
 	if !isLocalCoordinator {
@@ -155,7 +168,7 @@ func (n *Node) handleCLIBid(args []string) {
 		}
 		var reply CoordinatorBidReply
 		err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitBidToCoordinator",
-			BidArgs{Amount: amount, Bidder: bidder}, &reply)
+			bid, &reply)
 		if err != nil {
 			fmt.Printf("Error forwarding bid to coordinator: %v\n", err)
 			return
@@ -168,11 +181,11 @@ func (n *Node) handleCLIBid(args []string) {
 		return
 	}
 
-	accepted, message := n.ProposeBid(amount, bidder)
-	if !accepted {
-		fmt.Printf("Bid rejected: %s\n", message)
+	reply := n.ProposeBid(context.Background(), bid)
+	if !reply.Accepted {
+		fmt.Printf("Bid rejected: %s\n", reply.Message)
 	} else {
-		fmt.Printf("Bid accepted: %s\n", message)
+		fmt.Printf("Bid accepted: %s\n", reply.Message)
 	}
 }
 
@@ -227,7 +240,7 @@ func (n *Node) handleCLIAdd(scanner *bufio.Scanner) {
 		return
 	}
 
-	accepted, message := n.addItemAndBroadcast(name, desc, price, dur)
+	accepted, message := n.addItemAndBroadcast(AddItemArgs{Name: name, Description: desc, StartingPrice: price, DurationSec: dur})
 	fmt.Printf("[%v] %s\n", accepted, message)
 }
 
@@ -250,7 +263,7 @@ func (n *Node) handleCLIControl(action string) {
 	case "stop":
 		accepted, message = n.stopAuctionAndBroadcast()
 	case "restart":
-		accepted, message = n.restartAuctionAndBroadcast()
+		accepted, message = n.restartAuctionAndBroadcast(false)
 	}
 	fmt.Printf("[%v] %s\n", accepted, message)
 }
@@ -1,28 +1,111 @@
 package node
 
+// client.go — Outgoing net/rpc calls to peers.
+//
+// RPCClient keeps one persistent connection per peer address and multiplexes
+// every call to that peer over it, so heartbeats, RA requests and 2PC rounds
+// do not each pay a TCP and HTTP CONNECT handshake. net/rpc matches replies
+// to calls by sequence number, so concurrent fan-outs can share a
+// connection. A connection that breaks, or on which a call times out, is
+// dropped and the next call redials: a peer that stops answering without
+// closing the connection would otherwise hang every later call on it.
+// A peer that keeps failing is cut off for a while by its circuit breaker
+// (see breaker.go). With SetTLS, peers are dialed over TLS (see tls.go);
+// with SetSecret, every request is signed (see rpcauth.go). SetLatency
+// delays calls to a peer for fault-injection demos (see faults.go).
+
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/rpc"
+	"strings"
+	"sync"
 	"time"
 )
 
-const rpcDialTimeout = 3 * time.Second // fail fast for unreachable peers
+const (
+	rpcDialTimeout = 3 * time.Second // fail fast for unreachable peers
+	// rpcCallTimeout bounds a call whose context has no deadline. It is
+	// long enough for a forwarded bid to wait out the critical section and
+	// a 2PC round on the coordinator.
+	rpcCallTimeout = 30 * time.Second
+)
+
+type RPCClient struct {
+	// OnProtocolError, if set, is told about every call that failed because
+	// the two sides could not encode or decode each other's messages.
+	OnProtocolError func(*ProtocolError)
+	// OnBreakerChange, if set, is told when a peer's breaker opens or closes.
+	OnBreakerChange func(BreakerStatus)
+	// OnRoundTrip, if set, is told how long each successful call took.
+	OnRoundTrip func(address string, d time.Duration)
+
+	mu        sync.Mutex
+	conns     map[string]*rpc.Client // by peer address
+	breakers  map[string]*peerBreaker
+	tlsConfig *tls.Config // nil dials plain TCP
+	secret    []byte      // cluster secret; nil sends unsigned requests
+	latency   map[string]LatencyRule
+	rtt       map[string]*RTTStats
+}
+
+// ProtocolError is an RPC failure caused by mismatched message shapes (a gob
+// encode or decode error) rather than by the network. It almost always means
+// the peer runs an incompatible build.
+type ProtocolError struct {
+	Peer   string
+	Method string
+	Err    error
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s on %s: incompatible protocol: %v", e.Method, e.Peer, e.Err)
+}
+
+func (e *ProtocolError) Unwrap() error { return e.Err }
 
-type RPCClient struct{}
+// isCodecError reports whether err came from gob. gob errors reach the
+// caller as "gob: ..." (local encode, or the server's decode echoed back) or
+// "reading body gob: ..." (local decode of the reply). Missing methods are
+// not included: callers probe older peers for optional RPCs on purpose.
+func isCodecError(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "gob:") || strings.HasPrefix(msg, "reading body gob:")
+}
+
+// isAuthError reports whether the peer refused the call's signature.
+func isAuthError(err error) bool {
+	var se rpc.ServerError
+	return errors.As(err, &se) && strings.HasPrefix(string(se), "cluster auth:")
+}
 
 // dialHTTPTimeout is like rpc.DialHTTP but with a connect timeout so the
-// system doesn't hang when peers are offline.
-func dialHTTPTimeout(network, address string, timeout time.Duration) (*rpc.Client, error) {
-	conn, err := net.DialTimeout(network, address, timeout)
+// system doesn't hang when peers are offline. A non-nil tlsConfig runs the
+// TLS handshake, within the same timeout, before the CONNECT. With a secret
+// it connects to the signed endpoint instead.
+func dialHTTPTimeout(network, address string, timeout time.Duration, tlsConfig *tls.Config, secret []byte) (*rpc.Client, error) {
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, network, address, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout(network, address, timeout)
+	}
 	if err != nil {
 		return nil, err
 	}
+	path := rpc.DefaultRPCPath
+	if len(secret) > 0 {
+		path = authRPCPath
+	}
 	// Reproduce what rpc.DialHTTPPath does: send CONNECT, read response
-	_, _ = io.WriteString(conn, "CONNECT "+rpc.DefaultRPCPath+" HTTP/1.0\n\n")
+	_, _ = io.WriteString(conn, "CONNECT "+path+" HTTP/1.0\n\n")
 	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
 	if err != nil {
 		conn.Close()
@@ -30,16 +113,186 @@ func dialHTTPTimeout(network, address string, timeout time.Duration) (*rpc.Clien
 	}
 	if resp.StatusCode != http.StatusOK {
 		conn.Close()
+		switch {
+		case resp.StatusCode == http.StatusForbidden && len(secret) == 0:
+			return nil, errSecretRequired
+		case resp.StatusCode == http.StatusNotFound && len(secret) > 0:
+			return nil, errSecretUnknown
+		}
 		return nil, fmt.Errorf("unexpected HTTP response: %d %s", resp.StatusCode, resp.Status)
 	}
+	if len(secret) > 0 {
+		return rpc.NewClientWithCodec(newAuthClientCodec(conn, secret)), nil
+	}
 	return rpc.NewClient(conn), nil
 }
 
+// conn returns the pooled connection to address, dialing if there is none.
+func (c *RPCClient) conn(address string) (*rpc.Client, error) {
+	c.mu.Lock()
+	client, ok := c.conns[address]
+	tlsConfig, secret := c.tlsConfig, c.secret
+	c.mu.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	client, err := dialHTTPTimeout("tcp", address, rpcDialTimeout, tlsConfig, secret)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if existing, ok := c.conns[address]; ok {
+		// Another call dialed the same peer first; keep one connection.
+		client.Close()
+		return existing, nil
+	}
+	if c.conns == nil {
+		c.conns = map[string]*rpc.Client{}
+	}
+	c.conns[address] = client
+	return client, nil
+}
+
+// drop closes client and forgets it, unless it was already replaced.
+func (c *RPCClient) drop(address string, client *rpc.Client) {
+	c.mu.Lock()
+	if c.conns[address] == client {
+		delete(c.conns, address)
+	}
+	c.mu.Unlock()
+	client.Close()
+}
+
+// brokenConn reports whether err means the connection itself is unusable,
+// as opposed to an error returned by the remote method or a caller giving up.
+// A call that timed out counts as broken: the peer, or the connection to it,
+// may be hung.
+func brokenConn(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, rpc.ErrShutdown) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Call calls method on the peer at address, giving up after rpcCallTimeout.
 func (c *RPCClient) Call(address string, method string, args interface{}, reply interface{}) error {
-	client, err := dialHTTPTimeout("tcp", address, rpcDialTimeout)
+	return c.CallContext(context.Background(), address, method, args, reply)
+}
+
+// CallContext is Call that stops waiting when ctx is done and returns
+// ctx.Err(). The request may already have reached the peer; its reply, when
+// it comes, is discarded. A ctx without a deadline gets rpcCallTimeout. A
+// call that times out drops the connection and counts against the peer's
+// breaker; one the caller cancels does neither.
+func (c *RPCClient) CallContext(ctx context.Context, address string, method string, args interface{}, reply interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rpcCallTimeout)
+		defer cancel()
+	}
+	if err := c.allow(address); err != nil {
+		return err
+	}
+	start := time.Now()
+	if d := c.injectedDelay(address); d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+	client, err := c.conn(address)
 	if err != nil {
+		c.record(address, false, false, err)
+		if errors.Is(err, errSecretRequired) || errors.Is(err, errSecretUnknown) {
+			pe := &ProtocolError{Peer: address, Method: method, Err: err}
+			if c.OnProtocolError != nil {
+				c.OnProtocolError(pe)
+			}
+			return pe
+		}
 		return err
 	}
-	defer client.Close()
-	return client.Call(method, args, reply)
+	err = callWithContext(ctx, client, method, args, reply)
+	if errors.Is(err, rpc.ErrShutdown) {
+		// The connection died before this call was sent (e.g. the peer
+		// restarted since the last call), so one retry on a fresh
+		// connection cannot run the method twice.
+		c.drop(address, client)
+		if client, err = c.conn(address); err != nil {
+			c.record(address, false, false, err)
+			return err
+		}
+		err = callWithContext(ctx, client, method, args, reply)
+	}
+	c.record(address, err == nil || !brokenConn(err), errors.Is(err, context.Canceled), err)
+	if err != nil && (isCodecError(err) || isAuthError(err)) {
+		// A gob stream that failed to decode may be out of step; start over.
+		c.drop(address, client)
+		pe := &ProtocolError{Peer: address, Method: method, Err: err}
+		if c.OnProtocolError != nil {
+			c.OnProtocolError(pe)
+		}
+		return pe
+	}
+	if err != nil && brokenConn(err) {
+		c.drop(address, client)
+	}
+	if err == nil {
+		c.observeRTT(address, time.Since(start))
+	}
+	return err
+}
+
+// callWithContext runs one call on client. net/rpc buffers the Done channel,
+// so a call abandoned here completes later without blocking anything.
+func callWithContext(ctx context.Context, client *rpc.Client, method string, args interface{}, reply interface{}) error {
+	call := client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetTLS makes later connections use config (nil for plain TCP) and closes
+// the pooled ones so no call keeps going over the old transport.
+func (c *RPCClient) SetTLS(config *tls.Config) {
+	c.mu.Lock()
+	c.tlsConfig = config
+	c.mu.Unlock()
+	c.Close()
+}
+
+// SetSecret makes later connections sign every request with secret (nil for
+// unsigned) and closes the pooled ones.
+func (c *RPCClient) SetSecret(secret []byte) {
+	c.mu.Lock()
+	c.secret = secret
+	c.mu.Unlock()
+	c.Close()
+}
+
+// Close closes every pooled connection. Later calls dial again.
+func (c *RPCClient) Close() {
+	c.mu.Lock()
+	conns := c.conns
+	c.conns = nil
+	c.mu.Unlock()
+	for _, client := range conns {
+		client.Close()
+	}
 }
@@ -0,0 +1,205 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/rpc"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBrokenConn(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{context.Canceled, false},
+		{context.DeadlineExceeded, true},
+		{rpc.ErrShutdown, true},
+		{io.ErrUnexpectedEOF, true},
+		{rpc.ServerError("no such lot"), false},
+	}
+	for _, c := range cases {
+		if got := brokenConn(c.err); got != c.want {
+			t.Errorf("brokenConn(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+// hangRPC answers Ping and never answers Hang.
+type hangRPC struct{ stop chan struct{} }
+
+func (h *hangRPC) Ping(_ EmptyArgs, reply *bool) error {
+	*reply = true
+	return nil
+}
+
+func (h *hangRPC) Hang(_ EmptyArgs, reply *bool) error {
+	<-h.stop
+	return nil
+}
+
+func serveHangRPC(t *testing.T) string {
+	t.Helper()
+	h := &hangRPC{stop: make(chan struct{})}
+	s := rpc.NewServer()
+	if err := s.RegisterName("Hang", h); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, s)
+	go http.Serve(l, mux)
+	t.Cleanup(func() {
+		close(h.stop)
+		l.Close()
+	})
+	return l.Addr().String()
+}
+
+func TestCallContextTimeoutDropsConn(t *testing.T) {
+	addr := serveHangRPC(t)
+	c := &RPCClient{}
+	defer c.Close()
+	var ok bool
+	if err := c.Call(addr, "Hang.Ping", EmptyArgs{}, &ok); err != nil || !ok {
+		t.Fatalf("Ping: %v", err)
+	}
+	c.mu.Lock()
+	before := c.conns[addr]
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := c.CallContext(ctx, addr, "Hang.Hang", EmptyArgs{}, &ok); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Hang: got %v, want DeadlineExceeded", err)
+	}
+	c.mu.Lock()
+	_, pooled := c.conns[addr]
+	failures := c.breakers[addr].failures
+	c.mu.Unlock()
+	if pooled {
+		t.Error("connection kept after a timeout")
+	}
+	if failures != 1 {
+		t.Errorf("breaker failures = %d, want 1", failures)
+	}
+
+	if err := c.Call(addr, "Hang.Ping", EmptyArgs{}, &ok); err != nil {
+		t.Fatalf("Ping after timeout: %v", err)
+	}
+	c.mu.Lock()
+	after := c.conns[addr]
+	failures = c.breakers[addr].failures
+	c.mu.Unlock()
+	if after == before {
+		t.Error("Ping after the timeout reused the old connection")
+	}
+	if failures != 0 {
+		t.Errorf("breaker failures after success = %d, want 0", failures)
+	}
+}
+
+func TestCallContextCancelKeepsConn(t *testing.T) {
+	addr := serveHangRPC(t)
+	c := &RPCClient{}
+	defer c.Close()
+	var ok bool
+	if err := c.Call(addr, "Hang.Ping", EmptyArgs{}, &ok); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := c.CallContext(ctx, addr, "Hang.Hang", EmptyArgs{}, &ok); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Hang: got %v, want Canceled", err)
+	}
+	c.mu.Lock()
+	_, pooled := c.conns[addr]
+	b := c.breakers[addr]
+	c.mu.Unlock()
+	if !pooled {
+		t.Error("connection dropped after the caller cancelled")
+	}
+	if b != nil && b.failures != 0 {
+		t.Errorf("breaker failures = %d, want 0", b.failures)
+	}
+}
+
+// DriftedVote is PrepareReply as an incompatible build might declare it.
+// net/rpc only serves methods whose argument types are exported.
+type DriftedVote struct{ Vote string }
+
+// DriftedHeartbeat is BullyMessage as an incompatible build might declare it.
+type DriftedHeartbeat struct{ Term string }
+
+// driftedRPC is a NodeRPC whose message shapes have drifted from ours.
+// DecideBid is unchanged, so a coordinator can still abort through it.
+type driftedRPC struct{}
+
+func (driftedRPC) PrepareBid(_ PrepareArgs, reply *DriftedVote) error {
+	reply.Vote = "yes"
+	return nil
+}
+
+func (driftedRPC) HandleHeartbeat(_ DriftedHeartbeat, reply *bool) error {
+	*reply = true
+	return nil
+}
+
+func (driftedRPC) DecideBid(_ DecisionArgs, reply *bool) error {
+	*reply = true
+	return nil
+}
+
+func TestCodecMismatchIsProtocolError(t *testing.T) {
+	addr := serveAsNodeRPC(t, driftedRPC{})
+	var seen []*ProtocolError
+	c := &RPCClient{OnProtocolError: func(pe *ProtocolError) { seen = append(seen, pe) }}
+	defer c.Close()
+
+	cases := []struct {
+		name   string
+		method string
+		args   interface{}
+		reply  interface{}
+	}{
+		{"reply we cannot decode", "NodeRPC.PrepareBid", PrepareArgs{TxnID: "t1"}, &PrepareReply{}},
+		{"args the peer cannot decode", "NodeRPC.HandleHeartbeat", BullyMessage{NodeID: "A", Term: 3}, new(bool)},
+	}
+	for _, tc := range cases {
+		err := c.Call(addr, tc.method, tc.args, tc.reply)
+		var pe *ProtocolError
+		if !errors.As(err, &pe) || pe.Peer != addr || pe.Method != tc.method || !strings.Contains(err.Error(), "incompatible protocol") {
+			t.Errorf("%s: err = %v, want a ProtocolError for %s on %s", tc.name, err, tc.method, addr)
+		}
+		c.mu.Lock()
+		_, pooled := c.conns[addr]
+		c.mu.Unlock()
+		if pooled {
+			t.Errorf("%s: the connection was kept after a codec error", tc.name)
+		}
+	}
+	if len(seen) != len(cases) {
+		t.Errorf("OnProtocolError called %d times, want %d", len(seen), len(cases))
+	}
+
+	// A call that decodes is fine, and a missing method is not a protocol error.
+	var ack bool
+	if err := c.Call(addr, "NodeRPC.DecideBid", DecisionArgs{TxnID: "t1"}, &ack); err != nil || !ack {
+		t.Errorf("DecideBid = %v, %v", ack, err)
+	}
+	var pe *ProtocolError
+	if err := c.Call(addr, "NodeRPC.GetVersion", EmptyArgs{}, new(VersionInfo)); err == nil || errors.As(err, &pe) {
+		t.Errorf("missing method: err = %v, want a plain error", err)
+	}
+	if len(seen) != len(cases) {
+		t.Errorf("OnProtocolError called %d times, want %d", len(seen), len(cases))
+	}
+}
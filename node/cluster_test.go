@@ -0,0 +1,146 @@
+package node
+
+import (
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testNode is a node serving NodeRPC on a loopback listener.
+type testNode struct {
+	*Node
+	l     net.Listener
+	mux   *http.ServeMux // routes served on l; RPC only unless a test adds more
+	peers []string
+	mu    sync.Mutex
+	conns []net.Conn
+	dead  bool
+}
+
+// Accept tracks connections so kill can cut them.
+func (tn *testNode) Accept() (net.Conn, error) {
+	conn, err := tn.l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tn.mu.Lock()
+	defer tn.mu.Unlock()
+	if tn.dead {
+		conn.Close()
+		return nil, net.ErrClosed
+	}
+	tn.conns = append(tn.conns, conn)
+	return conn, nil
+}
+
+func (tn *testNode) Close() error   { return tn.l.Close() }
+func (tn *testNode) Addr() net.Addr { return tn.l.Addr() }
+
+// kill stops the node answering RPCs, as if it had crashed: the listener
+// and every open connection are closed.
+func (tn *testNode) kill() {
+	tn.mu.Lock()
+	tn.dead = true
+	conns := tn.conns
+	tn.conns = nil
+	tn.mu.Unlock()
+	tn.l.Close()
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// testCluster starts one node per ID on loopback listeners, ranked in the
+// order given, with every other node as a peer. Their files go under a
+// temporary directory.
+func testCluster(t testing.TB, ids ...string) []*testNode {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	listeners := make([]net.Listener, len(ids))
+	addrs := make([]string, len(ids))
+	for i := range ids {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners[i], addrs[i] = l, l.Addr().String()
+	}
+	nodes := make([]*testNode, len(ids))
+	for i, id := range ids {
+		nodes[i] = serveTestNode(t, NewNode(id, addrs[i], addrs, i+1), listeners[i], addrs)
+	}
+	return nodes
+}
+
+func serveTestNode(t testing.TB, n *Node, l net.Listener, peers []string) *testNode {
+	t.Helper()
+	n.RA.Address = n.Address
+	tn := &testNode{Node: n, l: l, mux: http.NewServeMux(), peers: peers}
+	server := rpc.NewServer()
+	if err := server.Register(&NodeRPC{node: n}); err != nil {
+		t.Fatal(err)
+	}
+	for path, h := range n.rpcHandlers(server) {
+		tn.mux.Handle(path, h)
+	}
+	go http.Serve(tn, n.gatedHandler(tn.mux))
+	t.Cleanup(func() {
+		tn.kill()
+		n.Client.Close()
+	})
+	return tn
+}
+
+// restart brings a killed node back on its address with no memory of
+// what it was doing.
+func (tn *testNode) restart(t *testing.T) *testNode {
+	t.Helper()
+	l, err := net.Listen("tcp", tn.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveTestNode(t, NewNode(tn.ID, tn.Address, tn.peers, tn.Rank), l, tn.peers)
+}
+
+// startTestNode starts one more node, with no peers, alongside a running
+// test cluster.
+func startTestNode(t *testing.T, id string, rank int) *testNode {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveTestNode(t, NewNode(id, l.Addr().String(), nil, rank), l, nil)
+}
+
+// leading makes n its own coordinator for the rest of the test. Cleanup
+// steps it down and waits out any checkpoint round it started, so nothing
+// writes to the test's directory after it is removed.
+func leading(t testing.TB, n *Node) {
+	t.Helper()
+	n.ElectionMutex.Lock()
+	n.setCoordinatorLocked(n.ID)
+	n.ElectionMutex.Unlock()
+	t.Cleanup(func() { stepDown(n) })
+}
+
+// stepDown ends n's spell as coordinator and waits out any checkpoint
+// round it started.
+func stepDown(n *Node) {
+	n.ElectionMutex.Lock()
+	n.setCoordinatorLocked("")
+	n.ElectionMutex.Unlock()
+	for {
+		n.CkptMutex.Lock()
+		if !n.CkptInFlight {
+			n.CkptInFlight = true // turns away rounds started later
+			n.CkptMutex.Unlock()
+			return
+		}
+		n.CkptMutex.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+}
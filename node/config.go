@@ -0,0 +1,408 @@
+package node
+
+// config.go — Runtime-tunable settings and their hot reload.
+//
+// RuntimeConfig holds the settings that may change without a restart. The
+// base values come from flags at startup; POST /admin/config on the
+// coordinator layers overrides on top, pushes them to every follower via
+// NodeRPC.UpdateConfig and persists them in the checkpoint. Heartbeats carry
+// a hash of the effective config so a follower that missed an update notices
+// the drift and pulls the coordinator's overrides. Values set by flags are
+// per node; only the overrides are cluster-wide.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RuntimeConfig is the hot-reloadable subset of node configuration. JSON
+// field names are the keys accepted by POST /admin/config.
+type RuntimeConfig struct {
+	AntiSnipeSec     int     `json:"antiSnipeSec"`
+	ReadRatePerSec   float64 `json:"readRatePerSec"`
+	ReadRateBurst    float64 `json:"readRateBurst"`
+	AlertWebhook     string  `json:"alertWebhook"`
+	RetainResults    int     `json:"retainResults"`
+	RetainAudit      int     `json:"retainAudit"`
+	StrictVersioning bool    `json:"strictVersioning"`
+	SyncIntervalMs   int     `json:"syncIntervalMs"` // follower pull interval; see followersync.go
+
+	BidIncrements []IncrementBand `json:"bidIncrements"` // see increments.go; empty = any higher bid
+}
+
+// DefaultRuntimeConfig returns the built-in values.
+func DefaultRuntimeConfig() RuntimeConfig {
+	return RuntimeConfig{
+		AntiSnipeSec:   int(antiSnipeWindow),
+		ReadRatePerSec: readRateLimitPerSec,
+		ReadRateBurst:  readRateLimitBurst,
+		SyncIntervalMs: int(DefaultSyncInterval / time.Millisecond),
+	}
+}
+
+// staticConfigFields are settings that exist but need a restart to change.
+var staticConfigFields = map[string]bool{
+	"id": true, "host": true, "port": true, "peers": true, "join": true,
+	"advertise": true, "checkpointHistory": true, "emojiMap": true, "tls": true,
+}
+
+func (c RuntimeConfig) validate() error {
+	switch {
+	case c.AntiSnipeSec < 0 || c.AntiSnipeSec > 600:
+		return fmt.Errorf("antiSnipeSec must be between 0 and 600")
+	case c.ReadRatePerSec <= 0:
+		return fmt.Errorf("readRatePerSec must be positive")
+	case c.ReadRateBurst < 1:
+		return fmt.Errorf("readRateBurst must be at least 1")
+	case c.SyncIntervalMs < minSyncIntervalMs || c.SyncIntervalMs > maxSyncIntervalMs:
+		return fmt.Errorf("syncIntervalMs must be between %d and %d", minSyncIntervalMs, maxSyncIntervalMs)
+	case c.RetainResults < 0 || c.RetainAudit < 0:
+		return fmt.Errorf("retainResults and retainAudit must not be negative")
+	case c.AlertWebhook != "" && !strings.HasPrefix(c.AlertWebhook, "http://") && !strings.HasPrefix(c.AlertWebhook, "https://"):
+		return fmt.Errorf("alertWebhook must be an http(s) URL")
+	}
+	if err := validateIncrements(c.BidIncrements); err != nil {
+		return fmt.Errorf("bidIncrements: %w", err)
+	}
+	return nil
+}
+
+// runtimeConfigState is the node's config bookkeeping, guarded by Node.configMu.
+type runtimeConfigState struct {
+	base      RuntimeConfig
+	sources   map[string]string          // field → "default" or "flag" for base values
+	overrides map[string]json.RawMessage // field → value set via /admin/config
+	version   int                        // bumped by the coordinator on each accepted change
+	effective RuntimeConfig
+}
+
+// overlayConfig applies overrides on top of base, rejecting unknown and
+// restart-only fields.
+func overlayConfig(base RuntimeConfig, overrides map[string]json.RawMessage) (RuntimeConfig, error) {
+	fields := map[string]json.RawMessage{}
+	b, _ := json.Marshal(base)
+	_ = json.Unmarshal(b, &fields)
+	for name, value := range overrides {
+		if staticConfigFields[name] {
+			return base, fmt.Errorf("%s cannot be changed at runtime; restart the node with the new flag", name)
+		}
+		if _, ok := fields[name]; !ok {
+			return base, fmt.Errorf("unknown config field %q", name)
+		}
+		fields[name] = value
+	}
+	merged, _ := json.Marshal(fields)
+	var cfg RuntimeConfig
+	if err := json.Unmarshal(merged, &cfg); err != nil {
+		return base, fmt.Errorf("invalid value: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return base, err
+	}
+	return cfg, nil
+}
+
+// runtimeConfig returns the effective config.
+func (n *Node) runtimeConfig() RuntimeConfig {
+	n.configMu.RLock()
+	defer n.configMu.RUnlock()
+	return n.config.effective
+}
+
+// SetBaseConfig installs the flag-derived config. flagsSet names the fields
+// given explicitly on the command line; the rest are reported as defaults.
+// Overrides restored from the checkpoint stay on top.
+func (n *Node) SetBaseConfig(base RuntimeConfig, flagsSet []string) error {
+	if err := base.validate(); err != nil {
+		return err
+	}
+	n.configMu.Lock()
+	n.config.base = base
+	n.config.sources = map[string]string{}
+	for _, f := range flagsSet {
+		n.config.sources[f] = "flag"
+	}
+	effective, err := overlayConfig(base, n.config.overrides)
+	if err != nil {
+		log.Printf("[%s] Warning: dropping saved config overrides: %v\n", n.ID, err)
+		n.config.overrides = nil
+		effective = base
+	}
+	n.config.effective = effective
+	n.configMu.Unlock()
+	n.applyRuntimeConfig(effective)
+	return nil
+}
+
+// applyRuntimeConfig pushes effective values into the components that cache
+// them.
+func (n *Node) applyRuntimeConfig(cfg RuntimeConfig) {
+	n.readLimiter.SetRate(cfg.ReadRatePerSec, cfg.ReadRateBurst)
+	n.Alerts.SetWebhook(cfg.AlertWebhook)
+	// MinNextBid in /state depends on the increments table.
+	n.Queue.mu.Lock()
+	n.Queue.touchLocked()
+	n.Queue.mu.Unlock()
+}
+
+// setConfigOverrides replaces the override set at version. Unless force is
+// set, versions not newer than the current one are ignored, which keeps
+// out-of-order broadcasts from rolling a change back. Returns the effective
+// config and whether anything was applied.
+func (n *Node) setConfigOverrides(overrides map[string]json.RawMessage, version int, force bool) (RuntimeConfig, bool, error) {
+	n.configMu.Lock()
+	if version <= n.config.version && !force {
+		cfg := n.config.effective
+		n.configMu.Unlock()
+		return cfg, false, nil
+	}
+	effective, err := overlayConfig(n.config.base, overrides)
+	if err != nil {
+		n.configMu.Unlock()
+		return RuntimeConfig{}, false, err
+	}
+	n.config.overrides = overrides
+	n.config.version = version
+	n.config.effective = effective
+	n.configMu.Unlock()
+	n.applyRuntimeConfig(effective)
+	return effective, true, nil
+}
+
+func (n *Node) configOverrides() (map[string]json.RawMessage, int) {
+	n.configMu.RLock()
+	defer n.configMu.RUnlock()
+	out := make(map[string]json.RawMessage, len(n.config.overrides))
+	for k, v := range n.config.overrides {
+		out[k] = v
+	}
+	return out, n.config.version
+}
+
+// configHash fingerprints the overrides and their version for heartbeats.
+// Flag values may legitimately differ per node, so they are left out.
+func (n *Node) configHash() string {
+	n.configMu.RLock()
+	b, _ := json.Marshal(n.config.overrides) // map keys marshal sorted
+	version := n.config.version
+	n.configMu.RUnlock()
+	h := sha256.Sum256(append(b, fmt.Sprintf("|v%d", version)...))
+	return hex.EncodeToString(h[:])[:16]
+}
+
+type ConfigUpdateArgs struct {
+	Leader    string // coordinator that issued the change
+	Version   int
+	Overrides map[string]json.RawMessage
+}
+
+type ConfigUpdateReply struct {
+	Applied bool
+	Message string
+	Config  RuntimeConfig
+	Version int
+}
+
+// proposeConfigChange merges patch into the current overrides, applies it
+// locally and pushes it to every follower. Coordinator only.
+func (n *Node) proposeConfigChange(patch map[string]json.RawMessage) (bool, string) {
+	if len(patch) == 0 {
+		return false, "no config fields given"
+	}
+	overrides, version := n.configOverrides()
+	for k, v := range patch {
+		overrides[k] = v
+	}
+	_, changed, err := n.setConfigOverrides(overrides, version+1, false)
+	if err != nil {
+		return false, err.Error()
+	}
+	if !changed {
+		return false, "config changed concurrently; retry"
+	}
+	names := make([]string, 0, len(patch))
+	for k := range patch {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	log.Printf("[%s] ⚙️  Config v%d applied (%s)\n", n.ID, version+1, strings.Join(names, ", "))
+	n.logTxnEvent("config", "CONFIG_UPDATED", fmt.Sprintf("version=%d fields=%s", version+1, strings.Join(names, ",")))
+	n.broadcastConfig()
+	go n.initiateGlobalCheckpoint()
+	return true, fmt.Sprintf("Config v%d applied", version+1)
+}
+
+// broadcastConfig pushes the current overrides to all followers.
+func (n *Node) broadcastConfig() {
+	overrides, version := n.configOverrides()
+	args := ConfigUpdateArgs{Leader: n.ID, Version: version, Overrides: overrides}
+	for _, peer := range n.peerList() {
+		p := peer
+		n.async.send(p, "UpdateConfig", func() error {
+			var reply ConfigUpdateReply
+			err := n.callPeer(p, "NodeRPC.UpdateConfig", args, &reply)
+			if err != nil || !reply.Applied {
+				log.Printf("[%s] Config v%d not applied on %s: %v %s\n", n.ID, version, p, err, reply.Message)
+			}
+			return err
+		})
+	}
+}
+
+// UpdateConfig installs the coordinator's overrides. Updates from a node
+// this follower does not consider the coordinator are refused.
+func (rp *NodeRPC) UpdateConfig(args ConfigUpdateArgs, reply *ConfigUpdateReply) error {
+	n := rp.node
+	n.ElectionMutex.Lock()
+	coordinator := n.Coordinator
+	n.ElectionMutex.Unlock()
+	if coordinator != "" && args.Leader != coordinator {
+		reply.Message = fmt.Sprintf("not from the current coordinator (%s)", coordinator)
+		return nil
+	}
+	cfg, changed, err := n.setConfigOverrides(args.Overrides, args.Version, false)
+	if err != nil {
+		reply.Message = err.Error()
+		return nil
+	}
+	if changed {
+		log.Printf("[%s] ⚙️  Config v%d received from %s\n", n.ID, args.Version, args.Leader)
+	}
+	reply.Applied = true
+	reply.Config = cfg
+	reply.Version = args.Version
+	return nil
+}
+
+// GetConfig returns this node's overrides so a drifted follower can resync.
+func (rp *NodeRPC) GetConfig(_ EmptyArgs, reply *ConfigUpdateArgs) error {
+	reply.Overrides, reply.Version = rp.node.configOverrides()
+	reply.Leader = rp.node.ID
+	return nil
+}
+
+// checkConfigDrift compares the coordinator's heartbeat config hash with
+// ours and, if they differ, pulls the coordinator's overrides. The pulled
+// set wins even if its version is lower: after a failover the new
+// coordinator's view is authoritative.
+func (n *Node) checkConfigDrift(leaderID, hash string) {
+	if hash == "" || hash == n.configHash() {
+		n.Metrics.Set("config_drift", 0)
+		return
+	}
+	n.Metrics.Set("config_drift", 1)
+	if !n.configSyncing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer n.configSyncing.Store(false)
+		addr, isLocal := n.getCoordinatorAddress()
+		if addr == "" || isLocal {
+			return
+		}
+		var remote ConfigUpdateArgs
+		if err := n.callPeer(addr, "NodeRPC.GetConfig", EmptyArgs{}, &remote); err != nil {
+			return
+		}
+		if remote.Leader != leaderID {
+			return
+		}
+		if _, changed, err := n.setConfigOverrides(remote.Overrides, remote.Version, true); err == nil && changed {
+			log.Printf("[%s] ⚙️  Config drift repaired: pulled v%d from %s\n", n.ID, remote.Version, leaderID)
+			n.Metrics.Inc("config_resyncs_total")
+		}
+	}()
+}
+
+type configFieldView struct {
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"` // default, flag or override
+}
+
+// handleConfigRequest serves GET/POST /admin/config.
+func (n *Node) handleConfigRequest(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		n.configMu.RLock()
+		fields := map[string]interface{}{}
+		b, _ := json.Marshal(n.config.effective)
+		_ = json.Unmarshal(b, &fields)
+		view := make(map[string]configFieldView, len(fields))
+		for name, value := range fields {
+			source := n.config.sources[name]
+			if source == "" {
+				source = "default"
+			}
+			if _, ok := n.config.overrides[name]; ok {
+				source = "override"
+			}
+			view[name] = configFieldView{Value: value, Source: source}
+		}
+		version := n.config.version
+		n.configMu.RUnlock()
+		writeJSON(w, struct {
+			Version int                        `json:"version"`
+			Hash    string                     `json:"hash"`
+			Fields  map[string]configFieldView `json:"fields"`
+		}{version, n.configHash(), view})
+	case "POST":
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		var patch map[string]json.RawMessage
+		if err := json.Unmarshal(body, &patch); err != nil {
+			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+		coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
+		if !isLocalCoordinator {
+			if coordinatorAddress == "" {
+				http.Error(w, "Election in progress, please wait", http.StatusServiceUnavailable)
+				return
+			}
+			var reply CoordinatorActionReply
+			if err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitConfigToCoordinator", patch, &reply); err != nil {
+				http.Error(w, "Leader unavailable; retry shortly", http.StatusServiceUnavailable)
+				return
+			}
+			if !reply.Accepted {
+				http.Error(w, reply.Message, http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(reply.Message))
+			return
+		}
+		accepted, message := n.proposeConfigChange(patch)
+		if !accepted {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(message))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (rp *NodeRPC) SubmitConfigToCoordinator(patch map[string]json.RawMessage, reply *CoordinatorActionReply) error {
+	rp.node.ElectionMutex.Lock()
+	isCoordinator := rp.node.Coordinator == rp.node.ID
+	rp.node.ElectionMutex.Unlock()
+
+	if !isCoordinator {
+		reply.Accepted = false
+		reply.Message = "This node is not the coordinator"
+		return nil
+	}
+	reply.Accepted, reply.Message = rp.node.proposeConfigChange(patch)
+	return nil
+}
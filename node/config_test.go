@@ -0,0 +1,182 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postConfig(n *Node, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	n.handleConfigRequest(rec, httptest.NewRequest(http.MethodPost, "/admin/config", strings.NewReader(body)))
+	return rec
+}
+
+func deadline(n *Node) int64 {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	return n.Queue.DeadlineUnix
+}
+
+func TestAntiSnipeWindowReloadedLive(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a := nodes[0]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+
+	// Sent to a follower, which forwards it to the coordinator.
+	if rec := postConfig(nodes[1].Node, `{"antiSnipeSec": 120}`); rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/config: %d %s", rec.Code, rec.Body)
+	}
+	for _, tn := range nodes {
+		waitFor(t, tn.ID+" to apply the new window", func() bool { return tn.runtimeConfig().AntiSnipeSec == 120 })
+		if tn.configHash() != a.configHash() {
+			t.Errorf("%s config hash differs from the coordinator's", tn.ID)
+		}
+	}
+
+	// A bid two seconds before the close extends it by the new window.
+	closesAt := time.Now().Add(2 * time.Second).Unix()
+	for _, tn := range nodes {
+		tn.Queue.mu.Lock()
+		tn.Queue.DeadlineUnix = closesAt
+		tn.Queue.touchLocked()
+		tn.Queue.mu.Unlock()
+	}
+	before := time.Now().Unix()
+	reply := a.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+	if reply.Code != BidCommitted {
+		t.Fatalf("late bid = %s %q, want committed", reply.Code, reply.Message)
+	}
+	for _, tn := range nodes {
+		waitFor(t, tn.ID+" to see the extended deadline", func() bool { return deadline(tn.Node) >= before+120 })
+		if got := deadline(tn.Node); got > time.Now().Unix()+120 {
+			t.Errorf("%s deadline is %ds away, want at most 120", tn.ID, got-time.Now().Unix())
+		}
+	}
+}
+
+func TestConfigRejections(t *testing.T) {
+	n := biddingNode(t)
+	setLeader(n, n.ID, n.Address)
+	cases := []struct{ body, want string }{
+		{`{"port": 9000}`, "cannot be changed at runtime"},
+		{`{"tls": true}`, "cannot be changed at runtime"},
+		{`{"antiSnipeSec": 9999}`, "between 0 and 600"},
+		{`{"colour": "red"}`, "unknown config field"},
+		{`{"antiSnipeSec": "soon"}`, "invalid value"},
+		{`{}`, "no config fields given"},
+	}
+	for _, c := range cases {
+		rec := postConfig(n, c.body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), c.want) {
+			t.Errorf("%s: %d %q, want 400 with %q", c.body, rec.Code, strings.TrimSpace(rec.Body.String()), c.want)
+		}
+	}
+	if _, version := n.configOverrides(); version != 0 {
+		t.Errorf("config version = %d after rejected changes, want 0", version)
+	}
+}
+
+func TestConfigSourcesAndRestart(t *testing.T) {
+	n := biddingNode(t)
+	setLeader(n, n.ID, n.Address)
+	leading(t, n)
+	base := DefaultRuntimeConfig()
+	base.RetainResults = 30
+	if err := n.SetBaseConfig(base, []string{"retainResults"}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := postConfig(n, `{"antiSnipeSec": 45}`); rec.Code != http.StatusOK {
+		t.Fatalf("POST: %d %s", rec.Code, rec.Body)
+	}
+
+	rec := httptest.NewRecorder()
+	n.handleConfigRequest(rec, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+	var view struct {
+		Version int
+		Fields  map[string]configFieldView
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string]string{"antiSnipeSec": "override", "retainResults": "flag", "readRateBurst": "default"}
+	for field, want := range sources {
+		if got := view.Fields[field].Source; got != want {
+			t.Errorf("%s source = %q, want %q", field, got, want)
+		}
+	}
+	if view.Version != 1 || view.Fields["antiSnipeSec"].Value != float64(45) {
+		t.Errorf("version %d, antiSnipeSec %v; want 1 and 45", view.Version, view.Fields["antiSnipeSec"].Value)
+	}
+
+	// The override survives a restart from the checkpoint.
+	if err := n.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	restarted := NewNode(n.ID, n.Address, nil, 1)
+	if err := restarted.SetBaseConfig(DefaultRuntimeConfig(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := restarted.runtimeConfig().AntiSnipeSec; got != 45 {
+		t.Errorf("antiSnipeSec after restart = %d, want 45", got)
+	}
+}
+
+func TestUpdateConfigFencing(t *testing.T) {
+	n := biddingNode(t)
+	setLeader(n, "L1", "127.0.0.1:1")
+	rp := &NodeRPC{node: n}
+	update := func(leader string, version, window int) ConfigUpdateReply {
+		t.Helper()
+		var reply ConfigUpdateReply
+		args := ConfigUpdateArgs{Leader: leader, Version: version,
+			Overrides: map[string]json.RawMessage{"antiSnipeSec": json.RawMessage(strconv.Itoa(window))}}
+		if err := rp.UpdateConfig(args, &reply); err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	if reply := update("L0", 5, 90); reply.Applied {
+		t.Error("applied an update from a node that is not the coordinator")
+	}
+	if reply := update("L1", 2, 60); !reply.Applied {
+		t.Errorf("coordinator's update refused: %s", reply.Message)
+	}
+	update("L1", 1, 15) // delivered out of order
+	if got := n.runtimeConfig().AntiSnipeSec; got != 60 {
+		t.Errorf("antiSnipeSec = %d after an older update, want 60", got)
+	}
+}
+
+func TestConfigDriftRepaired(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	// B missed the broadcast of v1.
+	if _, _, err := a.setConfigOverrides(map[string]json.RawMessage{"antiSnipeSec": json.RawMessage("75")}, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	b.checkConfigDrift(a.ID, a.configHash())
+	if got := gauge(b.Metrics, "config_drift"); got != 1 {
+		t.Errorf("config_drift = %v, want 1", got)
+	}
+	waitFor(t, "B to pull the coordinator's config", func() bool { return b.runtimeConfig().AntiSnipeSec == 75 })
+	b.checkConfigDrift(a.ID, a.configHash())
+	if got := gauge(b.Metrics, "config_drift"); got != 0 {
+		t.Errorf("config_drift after the resync = %v, want 0", got)
+	}
+	if got := b.Metrics.Counter("config_resyncs_total"); got != 1 {
+		t.Errorf("config_resyncs_total = %v, want 1", got)
+	}
+}
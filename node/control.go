@@ -0,0 +1,215 @@
+package node
+
+// control.go — Auction start/stop/restart, split into a side-effect-free
+// planning phase and an apply phase so actions can be previewed (dry run).
+
+import (
+	"time"
+)
+
+// ControlPlan describes what an auction control action would do to the
+// current state. Planning never mutates anything or takes the RA lock.
+type ControlPlan struct {
+	Action   string `json:"action"`
+	Accepted bool   `json:"accepted"`
+	Message  string `json:"message"`
+	NoOp     bool   `json:"noOp"` // accepted, but nothing would change
+
+	// CurrentItem is the live item before the action; CurrentItemDisposition
+	// says what happens to it: "resumed", "paused", "discarded" or "none".
+	CurrentItem            *AuctionItem `json:"currentItem"`
+	CurrentItemDisposition string       `json:"currentItemDisposition"`
+	CurrentHighestBid      int          `json:"currentHighestBid"`
+	CurrentWinner          string       `json:"currentWinner"`
+
+	// NextItem is the item that would be live after the action (if any).
+	NextItem *AuctionItem `json:"nextItem"`
+	// ItemsDropped are not-yet-started items that would be removed from the queue.
+	ItemsDropped []AuctionItem `json:"itemsDropped"`
+	// ResultsCleared are completed results that would be wiped.
+	ResultsCleared []ItemResult `json:"resultsCleared"`
+	// QueueAfter is the remaining queue after the action.
+	QueueAfter  []AuctionItem `json:"queueAfter"`
+	ActiveAfter bool          `json:"activeAfter"`
+
+	// Apply-phase instructions (not part of the preview).
+	replaceCurrent bool
+	clearResults   bool
+	resetDeadline  bool
+	startTimer     bool
+	seeded         []string // IDs of demo items a start re-seeded
+}
+
+// planAuctionControlLocked computes the plan for action. Must hold Queue.mu.
+func (n *Node) planAuctionControlLocked(action string) ControlPlan {
+	q := n.Queue
+	plan := ControlPlan{
+		Action:                 action,
+		CurrentHighestBid:      q.CurrentHighestBid,
+		CurrentWinner:          q.CurrentWinner,
+		CurrentItemDisposition: "none",
+		ActiveAfter:            q.Active,
+		QueueAfter:             append([]AuctionItem(nil), q.Queue...),
+	}
+	if q.CurrentItem != nil {
+		item := *q.CurrentItem
+		plan.CurrentItem = &item
+		plan.NextItem = &item
+	}
+
+	switch action {
+	case "start":
+		plan.Accepted = true
+		if q.Active && q.CurrentItem != nil && q.DeadlineUnix > time.Now().Unix() {
+			plan.NoOp = true
+			plan.Message = "Auction already running"
+			plan.CurrentItemDisposition = "unchanged"
+			return plan
+		}
+		if q.CurrentItem == nil {
+			if len(plan.QueueAfter) == 0 {
+				plan.QueueAfter = n.reseedItemsLocked()
+				for _, item := range plan.QueueAfter {
+					plan.seeded = append(plan.seeded, item.ID)
+				}
+			}
+			next := plan.QueueAfter[0]
+			plan.QueueAfter = plan.QueueAfter[1:]
+			plan.NextItem = &next
+			plan.replaceCurrent = true
+		} else {
+			plan.CurrentItemDisposition = "resumed"
+		}
+		plan.ActiveAfter = true
+		plan.resetDeadline = true
+		plan.startTimer = true
+		plan.Message = "Auction started"
+
+	case "restart":
+		items := defaultItems()
+		first := items[0]
+		plan.Accepted = true
+		plan.ItemsDropped = append([]AuctionItem(nil), q.Queue...)
+		plan.ResultsCleared = append([]ItemResult(nil), q.Results...)
+		plan.QueueAfter = items[1:]
+		plan.NextItem = &first
+		plan.ActiveAfter = true
+		if q.CurrentItem != nil {
+			plan.CurrentItemDisposition = "discarded"
+		}
+		plan.replaceCurrent = true
+		plan.clearResults = true
+		plan.resetDeadline = true
+		plan.startTimer = true
+		plan.Message = "Auction restarted"
+
+	case "stop":
+		if !q.Active {
+			plan.Message = "Auction already stopped"
+			return plan
+		}
+		plan.Accepted = true
+		plan.ActiveAfter = false
+		if q.CurrentItem != nil {
+			plan.CurrentItemDisposition = "paused"
+		}
+		plan.Message = "Auction stopped"
+
+	default:
+		plan.Message = "Unsupported action"
+	}
+	return plan
+}
+
+// previewAuctionControl returns the plan for action without changing anything.
+func (n *Node) previewAuctionControl(action string) ControlPlan {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	return n.planAuctionControlLocked(action)
+}
+
+// applyControlPlanLocked installs the outcome of plan. Must hold Queue.mu.
+func (n *Node) applyControlPlanLocked(plan ControlPlan) {
+	q := n.Queue
+	q.Queue = plan.QueueAfter
+	if plan.replaceCurrent {
+		next := *plan.NextItem
+		q.Announcement = nil
+		q.CurrentItem = &next
+		q.CurrentHighestBid = next.StartingPrice - 1
+		q.CurrentWinner = ""
+		q.CurrentWinnerID = ""
+		q.OpenedAtUnix = 0
+		q.GraceFromUnix = 0
+	}
+	if plan.clearResults {
+		q.Results = nil
+		q.ResultsTrimmed = 0
+		q.resetSpendLocked()
+	}
+	q.Active = plan.ActiveAfter
+	if plan.resetDeadline && q.CurrentItem != nil {
+		now := time.Now().Unix()
+		if q.OpenedAtUnix == 0 {
+			q.OpenedAtUnix = now
+		}
+		q.DeadlineUnix = now + int64(q.CurrentItem.DurationSec)
+		q.GraceFromUnix = 0
+	}
+	q.touchLocked()
+}
+
+// runAuctionControl plans and applies action as coordinator, then replicates
+// it. With archive set, results that a restart clears are first filed as a
+// historical event.
+func (n *Node) runAuctionControl(action string, archive bool) (bool, string) {
+	if msg := n.versionWriteBlock(); msg != "" {
+		return false, msg
+	}
+	release, err := n.enterCS()
+	if err != nil {
+		return false, err.Error()
+	}
+	defer release()
+	n.queueOps.Lock()
+	defer n.queueOps.Unlock()
+
+	n.Queue.mu.Lock()
+	plan := n.planAuctionControlLocked(action)
+	if !plan.Accepted || plan.NoOp {
+		n.Queue.mu.Unlock()
+		return plan.Accepted, plan.Message
+	}
+	before := n.Queue.censusLocked()
+	n.applyControlPlanLocked(plan)
+	violation := n.checkItemsLocked(action, before, plan.seeded, plan.clearResults) // a restart replaces the catalogue
+	var itemID string
+	deadline := n.Queue.DeadlineUnix
+	if n.Queue.CurrentItem != nil {
+		itemID = n.Queue.CurrentItem.ID
+	}
+	n.Queue.mu.Unlock()
+	n.alertItemInvariant(violation)
+
+	n.broadcastQueueState()
+	go n.initiateGlobalCheckpoint()
+	if archive && plan.clearResults {
+		go n.archiveEvent("restarted", plan.ResultsCleared)
+	}
+	if plan.startTimer && itemID != "" {
+		go n.runItemTimer(itemID, deadline)
+	}
+	return true, plan.Message
+}
+
+func (n *Node) startAuctionAndBroadcast() (bool, string) {
+	return n.runAuctionControl("start", false)
+}
+
+func (n *Node) restartAuctionAndBroadcast(archive bool) (bool, string) {
+	return n.runAuctionControl("restart", archive)
+}
+
+func (n *Node) stopAuctionAndBroadcast() (bool, string) {
+	return n.runAuctionControl("stop", false)
+}
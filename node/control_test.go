@@ -0,0 +1,83 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+)
+
+// liveAuction is a node partway through an auction: lot1 live with a bid,
+// two lots to come and one sold.
+func liveAuction(t *testing.T) *Node {
+	t.Helper()
+	n := biddingNode(t)
+	n.Queue.mu.Lock()
+	n.Queue.CurrentItem.DurationSec = 60
+	n.Queue.CurrentHighestBid, n.Queue.CurrentWinner, n.Queue.CurrentWinnerID = 40, "Ann", "b1"
+	n.Queue.Queue = []AuctionItem{{ID: "lot2", StartingPrice: 20, DurationSec: 60}, {ID: "lot3", StartingPrice: 30, DurationSec: 60}}
+	n.Queue.Results = []ItemResult{{Item: AuctionItem{ID: "lot0"}, Winner: "Bob", WinnerID: "b2", WinningBid: 70}}
+	n.Queue.mu.Unlock()
+	return n
+}
+
+func TestControlPreviewMatchesAction(t *testing.T) {
+	for _, action := range []string{"stop", "restart", "start"} {
+		t.Run(action, func(t *testing.T) {
+			n := liveAuction(t)
+			if action == "start" {
+				// Starting resumes a stopped auction.
+				n.Queue.mu.Lock()
+				n.Queue.Active = false
+				n.Queue.mu.Unlock()
+			}
+			version := n.Queue.Version()
+			plan := n.previewAuctionControl(action)
+			if got := n.Queue.Version(); got != version {
+				t.Fatalf("preview changed the state: version %d → %d", version, got)
+			}
+			if !plan.Accepted || plan.NoOp {
+				t.Fatalf("plan = %+v, want an accepted change", plan)
+			}
+
+			resultsBefore := len(n.Queue.Results)
+			if ok, msg := n.runAuctionControl(action, false); !ok {
+				t.Fatalf("%s: %s", action, msg)
+			}
+			n.Queue.mu.Lock()
+			defer n.Queue.mu.Unlock()
+			if n.Queue.Active != plan.ActiveAfter {
+				t.Errorf("Active = %v, plan said %v", n.Queue.Active, plan.ActiveAfter)
+			}
+			if !reflect.DeepEqual(n.Queue.Queue, plan.QueueAfter) {
+				t.Errorf("queue = %v, plan said %v", n.Queue.Queue, plan.QueueAfter)
+			}
+			if plan.NextItem == nil || n.Queue.CurrentItem == nil || n.Queue.CurrentItem.ID != plan.NextItem.ID {
+				t.Errorf("current item = %v, plan said %v", n.Queue.CurrentItem, plan.NextItem)
+			}
+			if want := resultsBefore - len(plan.ResultsCleared); len(n.Queue.Results) != want {
+				t.Errorf("%d results left, plan cleared %d of %d", len(n.Queue.Results), len(plan.ResultsCleared), resultsBefore)
+			}
+		})
+	}
+}
+
+func TestControlPreviewDispositions(t *testing.T) {
+	n := liveAuction(t)
+	cases := []struct {
+		action, disposition string
+		dropped             int
+	}{
+		{"stop", "paused", 0},
+		{"restart", "discarded", 2},
+		{"start", "unchanged", 0},
+	}
+	for _, c := range cases {
+		plan := n.previewAuctionControl(c.action)
+		if plan.CurrentItemDisposition != c.disposition || len(plan.ItemsDropped) != c.dropped {
+			t.Errorf("%s: disposition %q with %d dropped, want %q with %d",
+				c.action, plan.CurrentItemDisposition, len(plan.ItemsDropped), c.disposition, c.dropped)
+		}
+	}
+	if plan := n.previewAuctionControl("rewind"); plan.Accepted {
+		t.Errorf("unknown action accepted: %+v", plan)
+	}
+}
@@ -0,0 +1,77 @@
+package node
+
+// customfields.go — Operator-defined metadata on items.
+//
+// AuctionItem.Custom holds free-form string pairs such as a catalogue lot
+// number or a donor name. The cluster never interprets them; they are
+// carried with the item through snapshots, checkpoints, templates and
+// results, and shown in the UI's item details. Keys starting with a
+// reserved prefix are kept for future built-in fields.
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxCustomFields      = 20
+	maxCustomValueLen    = 512       // bytes
+	customFormFieldLabel = "custom." // form requests send custom.<key>=<value>
+)
+
+var (
+	customKeyPattern       = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+	reservedCustomPrefixes = []string{"_", "auction."}
+)
+
+// validateCustomFields enforces the key, size and count limits.
+func validateCustomFields(custom map[string]string) error {
+	if len(custom) > maxCustomFields {
+		return fmt.Errorf("at most %d custom fields per item, got %d", maxCustomFields, len(custom))
+	}
+	for key, value := range custom {
+		if !customKeyPattern.MatchString(key) {
+			return fmt.Errorf("custom field %q: keys are 1-64 letters, digits, '.', '-' or '_'", key)
+		}
+		for _, prefix := range reservedCustomPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return fmt.Errorf("custom field %q: the %q prefix is reserved", key, prefix)
+			}
+		}
+		if len(value) > maxCustomValueLen {
+			return fmt.Errorf("custom field %q: value is %d bytes; the limit is %d", key, len(value), maxCustomValueLen)
+		}
+		if !utf8.ValidString(value) {
+			return fmt.Errorf("custom field %q: value is not valid UTF-8", key)
+		}
+	}
+	return nil
+}
+
+// customFromForm collects custom.<key> form fields. Empty values are skipped.
+func customFromForm(form url.Values) map[string]string {
+	var custom map[string]string
+	for field, values := range form {
+		key, ok := strings.CutPrefix(field, customFormFieldLabel)
+		if !ok || len(values) == 0 || values[0] == "" {
+			continue
+		}
+		if custom == nil {
+			custom = map[string]string{}
+		}
+		custom[key] = values[0]
+	}
+	return custom
+}
+
+// cloneCustom copies custom so an item never shares its map with a request.
+func cloneCustom(custom map[string]string) map[string]string {
+	if len(custom) == 0 {
+		return nil
+	}
+	return maps.Clone(custom)
+}
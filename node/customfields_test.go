@@ -0,0 +1,137 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateCustomFields(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := 0; i <= maxCustomFields; i++ {
+		tooMany[fmt.Sprintf("k%d", i)] = "v"
+	}
+	atLimit := map[string]string{}
+	for i := 0; i < maxCustomFields; i++ {
+		atLimit[fmt.Sprintf("k%d", i)] = strings.Repeat("v", maxCustomValueLen)
+	}
+	cases := []struct {
+		name   string
+		custom map[string]string
+		err    string
+	}{
+		{"none", nil, ""},
+		{"catalogue fields", map[string]string{"lot.no": "A-17", "donor_name": "Ann <b>", "appraisal-usd": "1200"}, ""},
+		{"at the limits", atLimit, ""},
+		{"too many", tooMany, "at most 20 custom fields"},
+		{"value too long", map[string]string{"notes": strings.Repeat("x", maxCustomValueLen+1)}, "value is 513 bytes"},
+		{"bad UTF-8", map[string]string{"donor": "\xff"}, "not valid UTF-8"},
+		{"empty key", map[string]string{"": "v"}, "keys are 1-64"},
+		{"key with space", map[string]string{"lot no": "v"}, "keys are 1-64"},
+		{"key too long", map[string]string{strings.Repeat("k", 65): "v"}, "keys are 1-64"},
+		{"underscore prefix", map[string]string{"_id": "v"}, `"_" prefix is reserved`},
+		{"auction prefix", map[string]string{"auction.winner": "v"}, `"auction." prefix is reserved`},
+	}
+	for _, c := range cases {
+		err := validateCustomFields(c.custom)
+		if c.err == "" && err != nil || c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("%s: %v, want %q", c.name, err, c.err)
+		}
+	}
+}
+
+func TestCustomFieldsOnAddItem(t *testing.T) {
+	n := biddingNode(t)
+	setLeader(n, n.ID, n.Address)
+	leading(t, n)
+	lastCustom := func() map[string]string {
+		n.Queue.mu.Lock()
+		defer n.Queue.mu.Unlock()
+		return n.Queue.Queue[len(n.Queue.Queue)-1].Custom
+	}
+	postJSON := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/item", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		n.handleAddItemRequest(rec, req)
+		return rec
+	}
+
+	rec := postJSON(`{"name": "Vase", "description": "Blue", "startingPrice": 40, "durationSec": 60, "custom": {"lot.no": "A-17", "donor": "Ann"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("JSON add: %d %s", rec.Code, rec.Body)
+	}
+	if got := lastCustom(); !reflect.DeepEqual(got, map[string]string{"lot.no": "A-17", "donor": "Ann"}) {
+		t.Errorf("JSON custom = %v", got)
+	}
+
+	form := url.Values{"name": {"Bike"}, "description": {"Road"}, "startingPrice": {"200"}, "durationSec": {"90"},
+		"custom.donor": {"Bob"}, "custom.empty": {""}, "customdonor": {"not a custom field"}}
+	if rec := postForm(n.handleAddItemRequest, "/admin/item", form); rec.Code != http.StatusOK {
+		t.Fatalf("form add: %d %s", rec.Code, rec.Body)
+	}
+	if got := lastCustom(); !reflect.DeepEqual(got, map[string]string{"donor": "Bob"}) {
+		t.Errorf("form custom = %v, want only donor", got)
+	}
+
+	n.Queue.mu.Lock()
+	queued := len(n.Queue.Queue)
+	n.Queue.mu.Unlock()
+	rec = postJSON(`{"name": "Lamp", "description": "Brass", "startingPrice": 25, "durationSec": 30, "custom": {"auction.id": "x"}}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "reserved") {
+		t.Errorf("reserved key: %d %s, want 400", rec.Code, rec.Body)
+	}
+	code, resp := postBatch(t, n, `[{"name": "Lamp", "description": "Brass", "startingPrice": 25, "durationSec": 30, "custom": {"notes": "`+strings.Repeat("x", maxCustomValueLen+1)+`"}}]`)
+	if code != http.StatusBadRequest || len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Error, "513 bytes") {
+		t.Errorf("oversized batch value: %d %+v", code, resp)
+	}
+	n.Queue.mu.Lock()
+	added := len(n.Queue.Queue) - queued
+	n.Queue.mu.Unlock()
+	if added != 0 {
+		t.Errorf("rejected items added %d lots", added)
+	}
+}
+
+func TestCustomFieldsSurviveRestart(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	donor := map[string]string{"donor": "Ann", "lot.no": "A-17"}
+	a.Queue.mu.Lock()
+	a.Queue.CurrentItem.Custom = donor
+	a.Queue.Queue = []AuctionItem{{ID: "lot2", Name: "Bike", StartingPrice: 20, Custom: map[string]string{"donor": "Bob"}}}
+	a.Queue.CurrentHighestBid, a.Queue.CurrentWinner, a.Queue.CurrentWinnerID = 50, "Cy", "b3"
+	a.finalizeCurrentItemLocked()
+	a.Queue.CurrentItem, a.Queue.Queue = &a.Queue.Queue[0], nil
+	a.Queue.CurrentHighestBid, a.Queue.CurrentWinner, a.Queue.CurrentWinnerID = 20, "", ""
+	a.Queue.touchLocked()
+	a.Queue.mu.Unlock()
+	a.broadcastQueueState()
+	waitFor(t, "B to receive the result and the next lot", func() bool {
+		b.Queue.mu.Lock()
+		defer b.Queue.mu.Unlock()
+		return len(b.Queue.Results) == 1
+	})
+
+	if err := b.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewNode(b.ID, b.Address, nil, b.Rank)
+	restored.Queue.mu.Lock()
+	defer restored.Queue.mu.Unlock()
+	if got := restored.Queue.Results[0].Item.Custom; !reflect.DeepEqual(got, donor) {
+		t.Errorf("restored result custom = %v, want %v", got, donor)
+	}
+	if got := restored.Queue.CurrentItem.Custom; !reflect.DeepEqual(got, map[string]string{"donor": "Bob"}) {
+		t.Errorf("restored current lot's custom = %v", got)
+	}
+}
@@ -0,0 +1,244 @@
+package node
+
+// deadletters.go — Messages the validators refused, kept for debugging.
+//
+// A rejected snapshot or decision used to leave one log line naming the
+// broken rule; the message itself, which is what it takes to find the bug
+// in the sender, was gone. Each node now keeps the last --dead-letters
+// refused messages (100 by default, 0 turns capture off) in memory, with
+// the kind of message, who sent it, the rule or reason it failed and when.
+// Captured are:
+//
+//   - snapshots and 2PC decisions that fail the invariant checks in
+//     validate.go, and split-brain result offers likewise
+//   - snapshots and decisions refused for a stale term, or in strict mode
+//     for regressing local state (strict.go); once quarantined, the node
+//     refuses everything, and only the message that quarantined it is kept
+//   - join requests refused for a config mismatch or a duplicate node ID
+//
+// Prepares have no structural checks in this build; a NO vote is an
+// ordinary outcome and goes to the transaction log.
+//
+// GET /admin/deadletters lists the entries, newest last, without their
+// payloads; GET /admin/deadletters/{seq} downloads one entry in full. Every
+// capture is counted in dead_letters_total{kind,reason}, including those
+// evicted since. Payloads are scrubbed like the transaction log: bidder IDs,
+// names and amounts are kept, since they are what the rules check, but
+// client-supplied idempotency keys are dropped and bidder allow-lists are
+// cut to their size. A payload over maxDeadLetterPayload bytes is kept
+// truncated, as a string.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultDeadLetters   = 100
+	maxDeadLetterPayload = 1 << 20
+)
+
+// DeadLetter is one refused message.
+type DeadLetter struct {
+	Seq          int             `json:"seq"` // per node, from 1 since start
+	NodeID       string          `json:"nodeId"`
+	Kind         string          `json:"kind"`   // snapshot, decision, result offer, join
+	Source       string          `json:"source"` // sender, as the receiving path knows it
+	Reason       string          `json:"reason"` // invariant rule, stale_term, regression, ...
+	Error        string          `json:"error"`
+	AtUnixMs     int64           `json:"atUnixMs"`
+	PayloadBytes int             `json:"payloadBytes"`
+	Truncated    bool            `json:"truncated,omitempty"`
+	Payload      json.RawMessage `json:"payload,omitempty"`
+}
+
+type deadLetterBox struct {
+	mu       sync.Mutex
+	capacity int
+	seq      int
+	entries  []DeadLetter
+}
+
+func (b *deadLetterBox) setCapacity(capacity int) {
+	b.mu.Lock()
+	b.capacity = max(capacity, 0)
+	b.trimLocked()
+	b.mu.Unlock()
+}
+
+func (b *deadLetterBox) trimLocked() {
+	if excess := len(b.entries) - b.capacity; excess > 0 {
+		b.entries = append([]DeadLetter(nil), b.entries[excess:]...)
+	}
+}
+
+// add stores d and returns how many entries are held, or false when
+// capture is off.
+func (b *deadLetterBox) add(d DeadLetter) (int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.capacity == 0 {
+		return 0, false
+	}
+	b.seq++
+	d.Seq = b.seq
+	b.entries = append(b.entries, d)
+	b.trimLocked()
+	return len(b.entries), true
+}
+
+// list returns the entries held, oldest first, without payloads.
+func (b *deadLetterBox) list() []DeadLetter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]DeadLetter, len(b.entries))
+	for i, d := range b.entries {
+		d.Payload = nil
+		out[i] = d
+	}
+	return out
+}
+
+func (b *deadLetterBox) get(seq int) (DeadLetter, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, d := range b.entries {
+		if d.Seq == seq {
+			return d, true
+		}
+	}
+	return DeadLetter{}, false
+}
+
+// SetDeadLetters sets how many refused messages are kept (--dead-letters).
+func (n *Node) SetDeadLetters(capacity int) {
+	n.deadLetters.setCapacity(capacity)
+}
+
+// captureDeadLetter records a refused message. msg is scrubbed before it
+// is encoded.
+func (n *Node) captureDeadLetter(kind, source, reason string, msg any, err error) {
+	n.Metrics.Inc(metricName("dead_letters_total", "kind", kind, "reason", reason))
+	d := DeadLetter{
+		NodeID: n.ID, Kind: kind, Source: source, Reason: reason,
+		Error: err.Error(), AtUnixMs: time.Now().UnixMilli(),
+	}
+	payload, encErr := json.Marshal(scrubDeadLetter(msg))
+	if encErr != nil {
+		payload, _ = json.Marshal(fmt.Sprintf("could not encode %T: %v", msg, encErr))
+	}
+	d.PayloadBytes = len(payload)
+	if len(payload) > maxDeadLetterPayload {
+		d.Truncated = true
+		payload, _ = json.Marshal(string(payload[:maxDeadLetterPayload]))
+	}
+	d.Payload = payload
+	if held, ok := n.deadLetters.add(d); ok {
+		n.Metrics.Set("dead_letters_held", float64(held))
+	}
+}
+
+// invariantRule is the rule an invariantError names, or fallback.
+func invariantRule(err error, fallback string) string {
+	if ie, ok := err.(*invariantError); ok {
+		return ie.rule
+	}
+	return fallback
+}
+
+// scrubDeadLetter returns a copy of msg fit to keep. Shared slices are
+// copied before anything in them changes.
+func scrubDeadLetter(msg any) any {
+	switch m := msg.(type) {
+	case QueueSnapshot:
+		if m.CurrentItem != nil {
+			item := scrubItem(*m.CurrentItem)
+			m.CurrentItem = &item
+		}
+		m.RemainingItems = scrubItems(m.RemainingItems)
+		m.Results = scrubResults(m.Results)
+		if m.Announcement != nil {
+			ann := *m.Announcement
+			ann.Result.Item = scrubItem(ann.Result.Item)
+			m.Announcement = &ann
+		}
+		return m
+	case DecisionArgs:
+		m.Bid.IdempotencyKey = ""
+		return m
+	case ResultOfferArgs:
+		m.Results = scrubResults(m.Results)
+		return m
+	}
+	return msg
+}
+
+func scrubItem(item AuctionItem) AuctionItem {
+	if len(item.AllowedBidders) > 0 {
+		item.AllowedBidders = []string{fmt.Sprintf("(%d bidder IDs)", len(item.AllowedBidders))}
+	}
+	return item
+}
+
+func scrubItems(items []AuctionItem) []AuctionItem {
+	if items == nil {
+		return nil
+	}
+	out := make([]AuctionItem, len(items))
+	for i, item := range items {
+		out[i] = scrubItem(item)
+	}
+	return out
+}
+
+func scrubResults(results []ItemResult) []ItemResult {
+	if results == nil {
+		return nil
+	}
+	out := make([]ItemResult, len(results))
+	for i, r := range results {
+		r.Item = scrubItem(r.Item)
+		out[i] = r
+	}
+	return out
+}
+
+// handleDeadLettersRequest serves GET /admin/deadletters (the list) and
+// GET /admin/deadletters/{seq} (one entry, as a download).
+func (n *Node) handleDeadLettersRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/deadletters"), "/")
+	if rest == "" {
+		n.deadLetters.mu.Lock()
+		capacity, captured := n.deadLetters.capacity, n.deadLetters.seq
+		n.deadLetters.mu.Unlock()
+		writeJSON(w, struct {
+			NodeID   string       `json:"nodeId"`
+			Capacity int          `json:"capacity"`
+			Captured int          `json:"captured"` // since start, evicted ones included
+			Entries  []DeadLetter `json:"entries"`
+		}{n.ID, capacity, captured, n.deadLetters.list()})
+		return
+	}
+	seq, err := strconv.Atoi(rest)
+	if err != nil {
+		http.Error(w, "Expected /admin/deadletters/{seq}", http.StatusBadRequest)
+		return
+	}
+	d, ok := n.deadLetters.get(seq)
+	if !ok {
+		http.Error(w, "No such dead letter; it may have been evicted", http.StatusNotFound)
+		return
+	}
+	name := fmt.Sprintf("deadletter-%s-%d-%s.json", n.ID, d.Seq, strings.ReplaceAll(d.Kind, " ", "-"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	writeJSON(w, d)
+}
@@ -0,0 +1,142 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deadLettersOf reads GET /admin/deadletters from n.
+func deadLettersOf(t *testing.T, n *Node) (capacity, captured int, entries []DeadLetter) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	n.handleDeadLettersRequest(rec, httptest.NewRequest(http.MethodGet, "/admin/deadletters", nil))
+	var body struct {
+		Capacity, Captured int
+		Entries            []DeadLetter
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	return body.Capacity, body.Captured, body.Entries
+}
+
+func TestDeadLettersCaptureRejectedMessages(t *testing.T) {
+	n := withLotUp(biddingNode(t))
+	rp := &NodeRPC{node: n}
+	var ok bool
+
+	snap := goodSnapshot()
+	snap.RemainingItems[0].AllowedBidders = []string{"b1", "b2", "b3"}
+	snap.RemainingItems = append(snap.RemainingItems, snap.RemainingItems[0])
+	if err := rp.SyncQueueState(snap, &ok); err == nil {
+		t.Fatal("snapshot with a lot queued twice was accepted")
+	}
+	decision := DecisionArgs{TxnID: "L-7", Commit: true, Leader: "L", Term: 0,
+		Bid: BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: -5, ItemID: "lot1", IdempotencyKey: "secret-key"}}
+	if err := rp.DecideBid(decision, &ok); err != nil {
+		t.Fatal(err)
+	}
+
+	capacity, captured, entries := deadLettersOf(t, n)
+	if capacity != DefaultDeadLetters || captured != 2 || len(entries) != 2 {
+		t.Fatalf("capacity %d, captured %d, entries %+v", capacity, captured, entries)
+	}
+	want := []DeadLetter{
+		{Seq: 1, Kind: "snapshot", Source: "pushed snapshot", Reason: "duplicate_item"},
+		{Seq: 2, Kind: "decision", Source: "L", Reason: "bad_amount"},
+	}
+	for i, e := range entries {
+		w := want[i]
+		if e.Seq != w.Seq || e.Kind != w.Kind || e.Source != w.Source || e.Reason != w.Reason || e.NodeID != n.ID {
+			t.Errorf("entry %d = %+v, want %+v", i, e, w)
+		}
+		if e.Payload != nil || e.PayloadBytes == 0 || e.Error == "" || time.Since(time.UnixMilli(e.AtUnixMs)) > time.Minute {
+			t.Errorf("entry %d listed as %+v", i, e)
+		}
+	}
+	for _, m := range []string{
+		metricName("dead_letters_total", "kind", "snapshot", "reason", "duplicate_item"),
+		metricName("dead_letters_total", "kind", "decision", "reason", "bad_amount"),
+	} {
+		if got := n.Metrics.Counter(m); got != 1 {
+			t.Errorf("%s = %v, want 1", m, got)
+		}
+	}
+
+	// Each entry downloads in full, scrubbed.
+	download := func(seq string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		n.handleDeadLettersRequest(rec, httptest.NewRequest(http.MethodGet, "/admin/deadletters/"+seq, nil))
+		return rec
+	}
+	rec := download("1")
+	var gotSnap struct{ Payload QueueSnapshot }
+	if err := json.Unmarshal(rec.Body.Bytes(), &gotSnap); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("download 1: %d %v", rec.Code, err)
+	}
+	if got := gotSnap.Payload.RemainingItems; len(got) != 2 || len(got[0].AllowedBidders) != 1 || got[0].AllowedBidders[0] != "(3 bidder IDs)" {
+		t.Errorf("snapshot payload's queue = %+v, want allow-lists cut to a count", got)
+	}
+	if snap.RemainingItems[0].AllowedBidders[0] != "b1" {
+		t.Error("scrubbing changed the sender's snapshot")
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "deadletter-T1-1-snapshot.json") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	rec = download("2")
+	var gotDecision struct{ Payload DecisionArgs }
+	if err := json.Unmarshal(rec.Body.Bytes(), &gotDecision); err != nil {
+		t.Fatal(err)
+	}
+	if p := gotDecision.Payload; p.TxnID != "L-7" || p.Leader != "L" || p.Bid.Amount != -5 || p.Bid.BidderID != "b1" || p.Bid.IdempotencyKey != "" {
+		t.Errorf("decision payload = %+v, want it whole but for the idempotency key", p)
+	}
+	if strings.Contains(rec.Body.String(), "secret-key") {
+		t.Error("download carries the idempotency key")
+	}
+	for seq, code := range map[string]int{"9": http.StatusNotFound, "x": http.StatusBadRequest} {
+		if rec := download(seq); rec.Code != code {
+			t.Errorf("GET /admin/deadletters/%s: %d, want %d", seq, rec.Code, code)
+		}
+	}
+}
+
+func TestDeadLettersEvictOldest(t *testing.T) {
+	n := biddingNode(t)
+	n.SetDeadLetters(3)
+	reject := func(i int) {
+		n.rejectInvalid("decision", "L", DecisionArgs{TxnID: "L-" + strconv.Itoa(i)}, validateDecision("", true, BidArgs{}))
+	}
+	for i := 1; i <= 5; i++ {
+		reject(i)
+	}
+	capacity, captured, entries := deadLettersOf(t, n)
+	if capacity != 3 || captured != 5 || len(entries) != 3 || entries[0].Seq != 3 || entries[2].Seq != 5 {
+		t.Fatalf("capacity %d, captured %d, entries %+v; want 3..5 kept", capacity, captured, entries)
+	}
+	if _, ok := n.deadLetters.get(1); ok {
+		t.Error("evicted entry still downloadable")
+	}
+	if got := gauge(n.Metrics, "dead_letters_held"); got != 3 {
+		t.Errorf("dead_letters_held = %v, want 3", got)
+	}
+
+	// Shrinking drops the oldest at once; 0 turns capture off but still counts.
+	n.SetDeadLetters(1)
+	if _, _, entries := deadLettersOf(t, n); len(entries) != 1 || entries[0].Seq != 5 {
+		t.Errorf("after shrinking to 1: %+v", entries)
+	}
+	n.SetDeadLetters(0)
+	reject(6)
+	if _, captured, entries := deadLettersOf(t, n); captured != 5 || len(entries) != 0 {
+		t.Errorf("capture off: captured %d, entries %+v", captured, entries)
+	}
+	if got := n.Metrics.Counter(metricName("dead_letters_total", "kind", "decision", "reason", "decision_without_txn")); got != 6 {
+		t.Errorf("dead_letters_total = %v, want 6", got)
+	}
+}
@@ -0,0 +1,451 @@
+package node
+
+// decisionlog.go — Remembered 2PC decisions and recovery of in-doubt
+// transactions.
+//
+// A participant that voted YES and then heard nothing used to abort the
+// prepared txn once preparedTxnTTL passed. If the coordinator had committed
+// before it crashed, that participant now disagreed with every node that got
+// the decision until the new leader's next snapshot. Every node now records
+// each decision it applies, commit or abort, in txlogs/decisions_<ID>.jsonl
+// and keeps it for decisionRetention, and answers NodeRPC.QueryDecision from
+// that record. Before dropping a stale prepared txn a node asks the txn's
+// coordinator and the current one. A known decision is applied as if
+// DecideBid had delivered it; only when nobody reachable knows the outcome is
+// the txn aborted, as before.
+//
+// On the coordinator the log is written ahead: a decision is on disk before
+// it is applied or sent, and a commit that cannot be written becomes an
+// abort. Once every participant has acknowledged a commit a terminated
+// marker follows it. A coordinator that crashed in between replays its
+// unterminated commits when it next becomes coordinator. Aborts are not
+// replayed. Every decision, terminated or not, is kept for
+// decisionRetention, since a participant cut off for longer than
+// preparedTxnTTL is still in doubt when it asks. Delivering each
+// decision to every participant is delivery.go's job.
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// decisionRetention is how long decisions are kept for QueryDecision.
+	// It is well past preparedTxnTTL, so a participant that was down while
+	// its prepare went stale can still ask after a restart.
+	decisionRetention    = 5 * time.Minute
+	decisionQueryTimeout = 2 * time.Second
+	decisionCompactSlack = 64 // lines beyond twice the live records before a rewrite
+)
+
+// DecisionRecord is one line of the decision log. A line with Terminated
+// set and nothing else marks an earlier decision as acknowledged by every
+// participant.
+type DecisionRecord struct {
+	TxnID       string  `json:"txnId"`
+	Commit      bool    `json:"commit"`
+	Bid         BidArgs `json:"bid"`
+	Lamport     int     `json:"lamport"`
+	DecidedUnix int64   `json:"decidedUnix"`
+	Terminated  bool    `json:"terminated,omitempty"`
+}
+
+type decisionLog struct {
+	mu      sync.Mutex
+	path    string
+	records map[string]DecisionRecord
+	lines   int                  // lines in the file, for compaction
+	applied map[string]time.Time // txns applied here since start; see applyDecision
+}
+
+func decisionLogPath(nodeID string) string {
+	return filepath.Join(txnLogDir, fmt.Sprintf("decisions_%s.jsonl", nodeID))
+}
+
+// loadDecisionLog reads the decisions still within decisionRetention. A
+// missing or torn file loses only what could not be read.
+func loadDecisionLog(nodeID string) *decisionLog {
+	return readDecisionLog(decisionLogPath(nodeID))
+}
+
+func readDecisionLog(path string) *decisionLog {
+	l := &decisionLog{path: path, records: map[string]DecisionRecord{}, applied: map[string]time.Time{}}
+	f, err := os.Open(l.path)
+	if err != nil {
+		return l
+	}
+	defer f.Close()
+	cutoff := time.Now().Add(-decisionRetention).Unix()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		l.lines++
+		var rec DecisionRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil || rec.TxnID == "" {
+			continue
+		}
+		if rec.Terminated && rec.Bid == (BidArgs{}) {
+			if prev, ok := l.records[rec.TxnID]; ok {
+				prev.Terminated = true
+				l.records[rec.TxnID] = prev
+			}
+			continue
+		}
+		if rec.DecidedUnix >= cutoff {
+			l.records[rec.TxnID] = rec
+		}
+	}
+	return l
+}
+
+// record appends rec to the log, synced to disk if sync is set, and
+// remembers it. A decision already recorded the same way is not written
+// again.
+func (l *decisionLog) record(rec DecisionRecord, sync bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if prev, ok := l.records[rec.TxnID]; ok && prev.Commit == rec.Commit {
+		return nil
+	}
+	if err := l.appendLocked(rec, sync); err != nil {
+		return err
+	}
+	l.records[rec.TxnID] = rec
+	return nil
+}
+
+// terminate marks txnID as acknowledged by every participant, so it is
+// not replayed. The marker is not synced: if a crash loses it, the commit
+// is only replayed, and participants ignore a decision they have applied.
+func (l *decisionLog) terminate(txnID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec, ok := l.records[txnID]
+	if !ok || rec.Terminated {
+		return nil
+	}
+	rec.Terminated = true
+	l.records[txnID] = rec
+	return l.appendLocked(DecisionRecord{TxnID: txnID, Terminated: true}, false)
+}
+
+// markApplied notes that a decision on txnID took effect here.
+func (l *decisionLog) markApplied(txnID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.applied[txnID] = time.Now()
+}
+
+// wasApplied reports whether a decision on txnID took effect here since
+// start. It is not kept on disk: after a restart the state comes from the
+// leader's snapshot, and a replayed commit must still apply.
+func (l *decisionLog) wasApplied(txnID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.applied[txnID]
+	return ok
+}
+
+// appendLocked writes one line, and syncs it if sync is set. Must hold l.mu.
+func (l *decisionLog) appendLocked(rec DecisionRecord, sync bool) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if err == nil && sync {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		l.lines++
+	}
+	return err
+}
+
+// compact forgets decisions past decisionRetention, terminated or not: a
+// participant cut off for longer than preparedTxnTTL may still ask. Once
+// the file holds twice the lines it needs, it is rewritten through
+// writeFileSynced, so a crash leaves either the old log or the new one.
+func (l *decisionLog) compact() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for id, rec := range l.records {
+		if now.Sub(time.Unix(rec.DecidedUnix, 0)) > decisionRetention {
+			delete(l.records, id)
+		}
+	}
+	for id, at := range l.applied {
+		if now.Sub(at) > decisionRetention {
+			delete(l.applied, id)
+		}
+	}
+	if l.lines <= 2*len(l.records)+decisionCompactSlack {
+		return nil
+	}
+	var kept []byte
+	for _, rec := range l.sortedLocked() {
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		kept = append(append(kept, b...), '\n')
+	}
+	if err := writeFileSynced(l.path, kept); err != nil {
+		return err
+	}
+	l.lines = len(l.records)
+	return nil
+}
+
+// writeFileSynced replaces path with data: it writes a temporary file,
+// syncs it, renames it over path and syncs the directory, so the rename
+// itself survives a crash.
+func writeFileSynced(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// sortedLocked returns the records oldest first. Must hold l.mu.
+func (l *decisionLog) sortedLocked() []DecisionRecord {
+	recs := make([]DecisionRecord, 0, len(l.records))
+	for _, rec := range l.records {
+		recs = append(recs, rec)
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Lamport != recs[j].Lamport {
+			return recs[i].Lamport < recs[j].Lamport
+		}
+		return recs[i].TxnID < recs[j].TxnID
+	})
+	return recs
+}
+
+// unterminated returns this node's commits that some participant has not
+// acknowledged, oldest first.
+func (l *decisionLog) unterminated(coordinator string) []DecisionRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var recs []DecisionRecord
+	for _, rec := range l.sortedLocked() {
+		if rec.Commit && !rec.Terminated && rec.Bid.Coordinator == coordinator {
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
+
+// lookup returns the decision on txnID, if still remembered.
+func (l *decisionLog) lookup(txnID string) (DecisionRecord, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec, ok := l.records[txnID]
+	return rec, ok
+}
+
+// recordDecision writes a decision to the log. The coordinator calls it
+// with writeAhead set for a commit, before applying or sending it, and the
+// line is synced to disk. Every node calls it again, to no effect on the
+// coordinator, when applying a decision; that copy only serves
+// QueryDecision, so it is not synced and costs no disk wait on the bid's
+// path. Aborts are written as terminated: a participant that misses one
+// aborts on its own. Canary decisions are not kept; a lost one only leaves
+// a canary to expire.
+func (n *Node) recordDecision(txnID string, commit bool, bid BidArgs, writeAhead bool) error {
+	if bid.Canary {
+		return nil
+	}
+	rec := DecisionRecord{TxnID: txnID, Commit: commit, Bid: bid, Lamport: n.Clock.Get(),
+		DecidedUnix: time.Now().Unix(), Terminated: !commit}
+	err := n.decisions.record(rec, writeAhead)
+	if err != nil {
+		n.Metrics.Inc("decision_log_errors_total")
+		log.Printf("[%s] Warning: could not write decision for %s: %v\n", n.ID, txnID, err)
+	}
+	return err
+}
+
+// terminateDecision notes that every participant has acknowledged txnID.
+func (n *Node) terminateDecision(txnID string) {
+	if err := n.decisions.terminate(txnID); err != nil {
+		n.Metrics.Inc("decision_log_errors_total")
+		log.Printf("[%s] Warning: could not mark %s terminated in the decision log: %v\n", n.ID, txnID, err)
+	}
+}
+
+// replayDecisions applies and resends the commits this node decided that
+// not every participant acknowledged, typically because it crashed right
+// after deciding. It runs when this node becomes coordinator, after state
+// reconciliation; see replayCommit.
+func (n *Node) replayDecisions() {
+	recs := n.decisions.unterminated(n.ID)
+	if len(recs) == 0 {
+		return
+	}
+	log.Printf("[%s] 📼 Replaying %d unacknowledged decision(s) from the decision log\n", n.ID, len(recs))
+	term := n.currentTerm()
+	for _, rec := range recs {
+		n.Metrics.Inc("decisions_replayed_total")
+		n.logTxnEvent(rec.TxnID, "TXN_DECISION_REPLAYED", fmt.Sprintf("bid=%d bidder=%s term=%d", rec.Bid.Amount, rec.Bid.DisplayName, term))
+		n.replayCommit(rec)
+		decision := DecisionArgs{TxnID: rec.TxnID, Commit: true, Bid: rec.Bid, Leader: n.ID, Term: term}
+		go n.sendDecision(rec.TxnID, decision)
+	}
+}
+
+// replayCommit restores a logged commit without applying it a second time.
+// The record is already in the decision log and was counted when it was
+// first applied, and the bid book kept with the checkpoint usually has it;
+// a replay only raises the highest bid if the state lacks it, and enters
+// the bid in the book if the checkpoint was taken before the commit.
+func (n *Node) replayCommit(rec DecisionRecord) {
+	n.TxnMutex.Lock()
+	if n.decisions.wasApplied(rec.TxnID) {
+		n.TxnMutex.Unlock()
+		return
+	}
+	delete(n.PendingTxns, rec.TxnID)
+	n.decisions.markApplied(rec.TxnID)
+	n.TxnMutex.Unlock()
+	if !n.commitToQueue(rec.TxnID, rec.Bid) {
+		return
+	}
+	if !n.bids.has(rec.TxnID) {
+		n.recordBid(rec.TxnID, rec.Bid, BidCommitted, "")
+	}
+}
+
+// QueryDecisionArgs asks a node whether it knows how a txn was decided.
+type QueryDecisionArgs struct {
+	TxnID string
+	From  string
+}
+
+// QueryDecisionReply is the answer; Known is false when the node has no
+// record of the decision.
+type QueryDecisionReply struct {
+	Known  bool
+	Commit bool
+	Bid    BidArgs
+}
+
+// QueryDecision answers a participant holding TxnID in doubt.
+func (rp *NodeRPC) QueryDecision(args QueryDecisionArgs, reply *QueryDecisionReply) error {
+	rec, ok := rp.node.decisions.lookup(args.TxnID)
+	if !ok {
+		return nil
+	}
+	reply.Known, reply.Commit, reply.Bid = true, rec.Commit, rec.Bid
+	return nil
+}
+
+// queryDecision asks the txn's coordinator and the current one, side by
+// side and for at most decisionQueryTimeout, how txnID was decided. The
+// first that knows answers. It reports false if neither knows or answers.
+func (n *Node) queryDecision(txnID string, bid BidArgs) (QueryDecisionReply, string, bool) {
+	if rec, ok := n.decisions.lookup(txnID); ok {
+		return QueryDecisionReply{Known: true, Commit: rec.Commit, Bid: rec.Bid}, n.ID, true
+	}
+	var asked []string
+	if bid.Coordinator != "" && bid.Coordinator != n.ID {
+		if addr := n.peerAddressByID(bid.Coordinator); addr != "" {
+			asked = append(asked, addr)
+		}
+	}
+	if addr, isLocal := n.getCoordinatorAddress(); addr != "" && !isLocal && (len(asked) == 0 || asked[0] != addr) {
+		asked = append(asked, addr)
+	}
+	type answer struct {
+		addr  string
+		reply QueryDecisionReply
+	}
+	answers := make(chan answer, len(asked))
+	ctx, cancel := context.WithTimeout(context.Background(), decisionQueryTimeout)
+	defer cancel()
+	fanOut(asked, func(addr string) {
+		var reply QueryDecisionReply
+		if err := n.callPeerContext(ctx, addr, "NodeRPC.QueryDecision", QueryDecisionArgs{TxnID: txnID, From: n.ID}, &reply); err != nil {
+			answers <- answer{addr: addr} // reply may still be written by the abandoned call
+			return
+		}
+		answers <- answer{addr, reply}
+	})
+	for range asked {
+		if a := <-answers; a.reply.Known {
+			return a.reply, a.addr, true
+		}
+	}
+	return QueryDecisionReply{}, "", false
+}
+
+// resolveInDoubt settles a prepared txn that outlived preparedTxnTTL: the
+// decision if some node knows it, else an abort.
+func (n *Node) resolveInDoubt(txnID string, pending PendingTxn) {
+	decision, source, known := n.queryDecision(txnID, pending.Bid)
+
+	n.TxnMutex.Lock()
+	if _, still := n.PendingTxns[txnID]; !still {
+		// DecideBid got here while we asked.
+		n.TxnMutex.Unlock()
+		return
+	}
+	if !known {
+		delete(n.PendingTxns, txnID)
+		n.TxnMutex.Unlock()
+		n.Metrics.Inc(metricName("txn_in_doubt_resolved_total", "outcome", "unknown"))
+		log.Printf("[%s] Auto-aborted stale txn %s\n", n.ID, txnID)
+		n.logTxnEvent(txnID, "TXN_STALE_ABORT", "prepared txn timed out before decision; no node knows the outcome")
+		return
+	}
+	n.TxnMutex.Unlock()
+
+	outcome := "abort"
+	if decision.Commit {
+		outcome = "commit"
+	}
+	n.Metrics.Inc(metricName("txn_in_doubt_resolved_total", "outcome", outcome))
+	log.Printf("[%s] Resolved in-doubt txn %s: %s, as told by %s\n", n.ID, txnID, outcome, source)
+	n.logTxnEvent(txnID, "TXN_IN_DOUBT_RESOLVED", fmt.Sprintf("%s from %s", outcome, source))
+	n.applyDecision(txnID, decision.Commit, decision.Bid)
+}
@@ -0,0 +1,138 @@
+package node
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDecisionLogCompactRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+	l := readDecisionLog(path)
+	now := time.Now()
+	recent := now.Add(-2 * preparedTxnTTL).Unix() // past the TTL, within retention
+	expired := now.Add(-decisionRetention - time.Minute).Unix()
+	bid := BidArgs{BidderID: "b1", DisplayName: "Bob", Amount: 50, ItemID: "lot1", Coordinator: "N1"}
+
+	for _, rec := range []DecisionRecord{
+		{TxnID: "abort", Commit: false, Bid: bid, DecidedUnix: recent, Terminated: true},
+		{TxnID: "commit", Commit: true, Bid: bid, DecidedUnix: recent},
+		{TxnID: "old", Commit: true, Bid: bid, DecidedUnix: expired},
+	} {
+		if err := l.record(rec, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.terminate("commit"); err != nil {
+		t.Fatal(err)
+	}
+	// Pad the file so compact rewrites it.
+	for i := 0; i <= decisionCompactSlack; i++ {
+		id := fmt.Sprintf("pad-%d", i)
+		if err := l.record(DecisionRecord{TxnID: id, Commit: true, Bid: bid, DecidedUnix: expired}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, got := range []*decisionLog{l, readDecisionLog(path)} {
+		if rec, ok := got.lookup("abort"); !ok || rec.Commit {
+			t.Errorf("terminated abort within retention: got %+v, %v", rec, ok)
+		}
+		if rec, ok := got.lookup("commit"); !ok || !rec.Commit || !rec.Terminated {
+			t.Errorf("terminated commit within retention: got %+v, %v", rec, ok)
+		}
+		if _, ok := got.lookup("old"); ok {
+			t.Error("decision past retention kept")
+		}
+		if _, ok := got.lookup("pad-0"); ok {
+			t.Error("padding past retention kept")
+		}
+	}
+	if l.lines != 2 {
+		t.Errorf("lines after rewrite = %d, want 2", l.lines)
+	}
+}
+
+// restarted opens a new node on n's directory, as if n had restarted from a
+// checkpoint holding book as its bid book and its current queue state.
+func restarted(t *testing.T, n *Node, book map[string][]BidRecord) *Node {
+	t.Helper()
+	r := NewNode(n.ID, n.Address, nil, 1)
+	r.bids.restore(book)
+	n.Queue.mu.Lock()
+	r.Queue.Active = n.Queue.Active
+	r.Queue.CurrentItem = n.Queue.CurrentItem
+	r.Queue.CurrentHighestBid = n.Queue.CurrentHighestBid
+	r.Queue.CurrentWinner, r.Queue.CurrentWinnerID = n.Queue.CurrentWinner, n.Queue.CurrentWinnerID
+	r.Queue.DeadlineUnix = n.Queue.DeadlineUnix
+	n.Queue.mu.Unlock()
+	return r
+}
+
+// replayed runs replayDecisions on n and waits for the resent decision on
+// txnID to terminate.
+func replayed(t *testing.T, n *Node, txnID string) {
+	t.Helper()
+	n.replayDecisions()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if rec, ok := n.decisions.lookup(txnID); ok && rec.Terminated {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("replayed decision %s never terminated", txnID)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReplayDecisionsDoesNotDuplicateBids(t *testing.T) {
+	n := biddingNode(t)
+	bid := BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1", Coordinator: n.ID}
+	commitBid(t, &NodeRPC{node: n}, "T1-1", bid)
+	book := n.bids.snapshot()
+	commitMetric := metricName("bids_committed_by_origin_total", "origin", unknownOrigin)
+
+	// Restarted from a checkpoint that already has the bid, twice over.
+	for i := 0; i < 2; i++ {
+		r := restarted(t, n, book)
+		replayed(t, r, "T1-1")
+		if total, recs := r.bids.page("b1", 0, 10); total != 1 || recs[0].TxnID != "T1-1" {
+			t.Errorf("restart %d: book has %d entries for b1, want 1: %+v", i+1, total, recs)
+		}
+		if got := r.Metrics.Counter(commitMetric); got != 0 {
+			t.Errorf("restart %d: replay counted the commit again (%v)", i+1, got)
+		}
+		if got := highestBid(r); got != 50 {
+			t.Errorf("restart %d: highest bid = %d, want 50", i+1, got)
+		}
+		book = r.bids.snapshot()
+		// Let the next restart replay it again.
+		if err := r.decisions.record(DecisionRecord{TxnID: "T1-1", Commit: true, Bid: bid, DecidedUnix: time.Now().Unix()}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReplayDecisionsRestoresBidMissingFromCheckpoint(t *testing.T) {
+	n := biddingNode(t)
+	bid := BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1", Coordinator: n.ID}
+	commitBid(t, &NodeRPC{node: n}, "T1-1", bid)
+	n.Queue.mu.Lock()
+	n.Queue.CurrentHighestBid, n.Queue.CurrentWinner, n.Queue.CurrentWinnerID = 10, "", ""
+	n.Queue.mu.Unlock()
+
+	// The checkpoint was taken before the commit.
+	r := restarted(t, n, nil)
+	replayed(t, r, "T1-1")
+	if total, _ := r.bids.page("b1", 0, 10); total != 1 {
+		t.Errorf("book has %d entries for b1, want 1", total)
+	}
+	if got := highestBid(r); got != 50 {
+		t.Errorf("highest bid = %d, want 50", got)
+	}
+}
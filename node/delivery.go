@@ -0,0 +1,160 @@
+package node
+
+// delivery.go — Getting each Phase-2 decision to every participant.
+//
+// Commits were retried five times, two seconds apart, one peer after
+// another, so a single hanging peer held up the rest. Aborts were sent once
+// and forgotten: a participant that missed one kept the prepared txn, and
+// with it the lot's reservation, until preparedTxnTTL. Every decision that
+// some peer has not acknowledged now gets a delivery. The missing peers are
+// retried in parallel, with a backoff that starts at decisionRetryBase and
+// doubles up to decisionRetryMax, for decisionRetryWindow. The delivery ends
+// when every peer has acknowledged, or the window runs out and the
+// stragglers are logged. Deliveries in progress show in /admin/inflight.
+// Participants treat a decision they already applied as a no-op, so a
+// retry that crosses a slow first attempt changes nothing.
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	decisionRetryBase   = 500 * time.Millisecond
+	decisionRetryMax    = 4 * time.Second
+	decisionRetryWindow = 30 * time.Second
+)
+
+// decisionDelivery is one decision still owed to some peers.
+type decisionDelivery struct {
+	commit   bool
+	started  time.Time
+	attempts int
+	missing  []string
+}
+
+// deliveryTracker holds the deliveries in progress, by txn ID.
+type deliveryTracker struct {
+	mu     sync.Mutex
+	active map[string]*decisionDelivery
+}
+
+// DeliveryStatus is one row of "deliveries" in /admin/inflight.
+type DeliveryStatus struct {
+	TxnID    string   `json:"txnId"`
+	Commit   bool     `json:"commit"`
+	AgeMs    int64    `json:"ageMs"`
+	Attempts int      `json:"attempts"`
+	Missing  []string `json:"missing"`
+}
+
+func (t *deliveryTracker) begin(txnID string, commit bool, missing []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active == nil {
+		t.active = map[string]*decisionDelivery{}
+	}
+	t.active[txnID] = &decisionDelivery{commit: commit, started: time.Now(), missing: missing}
+}
+
+func (t *deliveryTracker) update(txnID string, attempts int, missing []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if d := t.active[txnID]; d != nil {
+		d.attempts, d.missing = attempts, missing
+	}
+}
+
+func (t *deliveryTracker) end(txnID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.active, txnID)
+}
+
+// snapshot returns the deliveries in progress, oldest first.
+func (t *deliveryTracker) snapshot() []DeliveryStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]DeliveryStatus, 0, len(t.active))
+	for id, d := range t.active {
+		out = append(out, DeliveryStatus{
+			TxnID: id, Commit: d.commit, AgeMs: time.Since(d.started).Milliseconds(),
+			Attempts: d.attempts, Missing: append([]string(nil), d.missing...),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AgeMs > out[j].AgeMs })
+	return out
+}
+
+// sendDecision broadcasts decision and, if some peer does not acknowledge
+// it, keeps delivering it in the background.
+func (n *Node) sendDecision(txnID string, decision DecisionArgs) {
+	acks, allAcked, missing := n.broadcastDecisionAndCollectAcks(txnID, decision)
+	if allAcked {
+		n.terminateDecision(txnID)
+		n.logTxnEvent(txnID, "TXN_TERMINATED", fmt.Sprintf("all %d participants ACKed", acks))
+		return
+	}
+	n.logTxnEvent(txnID, "TXN_TERMINATION_PENDING", fmt.Sprintf("ACKs=%d/%d missing=%s", acks, acks+len(missing), strings.Join(missing, ",")))
+	go n.deliverDecision(txnID, decision, missing)
+}
+
+// deliverDecision retries decision to the peers in missing until all of
+// them acknowledge it or decisionRetryWindow passes.
+func (n *Node) deliverDecision(txnID string, decision DecisionArgs, missing []string) {
+	remaining := append([]string(nil), missing...)
+	sort.Strings(remaining)
+	n.deliveries.begin(txnID, decision.Commit, remaining)
+	defer n.deliveries.end(txnID)
+
+	deadline := time.Now().Add(decisionRetryWindow)
+	wait := decisionRetryBase
+	attempt := 0
+	for len(remaining) > 0 && time.Now().Add(wait).Before(deadline) {
+		time.Sleep(wait)
+		wait = min(2*wait, decisionRetryMax)
+		attempt++
+		acked := make([]bool, len(remaining))
+		var wg sync.WaitGroup
+		for i, peer := range remaining {
+			wg.Add(1)
+			go func(i int, p string) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), decisionAckWaitTimeout)
+				defer cancel()
+				var ack bool
+				err := n.callPeerContext(ctx, p, "NodeRPC.DecideBid", decision, &ack)
+				acked[i] = err == nil && ack
+			}(i, peer)
+		}
+		wg.Wait()
+		next := remaining[:0:0]
+		for i, peer := range remaining {
+			if acked[i] {
+				n.logTxnEvent(txnID, "TXN_DECIDE_ACK_RETRY", fmt.Sprintf("peer=%s attempt=%d", peer, attempt))
+				continue
+			}
+			next = append(next, peer)
+		}
+		remaining = next
+		n.deliveries.update(txnID, attempt, remaining)
+		if len(remaining) > 0 {
+			n.logTxnEvent(txnID, "TXN_TERMINATION_RETRY", fmt.Sprintf("attempt=%d remaining=%s", attempt, strings.Join(remaining, ",")))
+		}
+	}
+
+	if len(remaining) == 0 {
+		n.terminateDecision(txnID)
+		n.logTxnEvent(txnID, "TXN_TERMINATED", "all participants ACKed after retry")
+		return
+	}
+	n.Metrics.Inc("decision_deliveries_incomplete_total")
+	log.Printf("[%s] ⚠️  Gave up delivering the decision on %s after %d attempts; no ACK from %s\n",
+		n.ID, txnID, attempt, strings.Join(remaining, ", "))
+	n.logTxnEvent(txnID, "TXN_TERMINATION_INCOMPLETE", fmt.Sprintf("unacked participants=%s", strings.Join(remaining, ",")))
+}
@@ -1,5 +1,7 @@
 package node
 
+import "context"
+
 func (n *Node) markDependency(address string) {
 	if address == "" || address == n.Address {
 		return
@@ -27,8 +29,23 @@ func (n *Node) clearDependenciesForParticipants(participants map[string]bool) {
 	}
 }
 
+// peerCaller makes RPC calls to peers. Nodes use their *RPCClient; tests
+// stand in for peers by setting Node.caller.
+type peerCaller interface {
+	CallContext(ctx context.Context, address, method string, args interface{}, reply interface{}) error
+}
+
 func (n *Node) callPeer(address, method string, args interface{}, reply interface{}) error {
-	err := n.Client.Call(address, method, args, reply)
+	return n.callPeerContext(context.Background(), address, method, args, reply)
+}
+
+// callPeerContext is callPeer that gives up when ctx is done.
+func (n *Node) callPeerContext(ctx context.Context, address, method string, args interface{}, reply interface{}) error {
+	var caller peerCaller = n.Client
+	if n.caller != nil {
+		caller = n.caller
+	}
+	err := caller.CallContext(ctx, address, method, args, reply)
 	if err == nil {
 		n.markDependency(address)
 	}
@@ -0,0 +1,238 @@
+package node
+
+// dispatch.go — Bounded dispatcher for fire-and-forget RPCs, and GET /rpcstats.
+//
+// Broadcasts that nobody waits on (heartbeats, snapshot pushes, abort
+// decisions, config and membership fan-outs, deferred RA replies) go through
+// one lane per destination: a small queue drained by a fixed number of
+// workers. A dead or hung peer therefore ties up at most its own lane's
+// workers instead of piling up goroutines, and every send is counted, so a
+// peer that silently drops everything shows up in /rpcstats and /metrics.
+//
+// When a lane is full, ordinary sends are dropped and counted; the next
+// heartbeat or snapshot supersedes them anyway. Sends that must not be lost
+// (sendReliable) run on their own goroutine instead, counted as overflow.
+// Heartbeats and snapshot pushes use sendLatest: while one is still queued
+// for a peer, a newer one replaces it instead of queueing behind it.
+//
+// Fan-outs that wait for every reply (2PC rounds, checkpoint finalize) use
+// fanOut, which caps how many of their calls run at once.
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	asyncLaneWorkers = 2  // concurrent sends per destination
+	asyncLaneQueue   = 64 // queued sends per destination before dropping
+	maxFanOut        = 8  // concurrent calls per fanOut
+)
+
+// DestinationStats is one row of GET /rpcstats.
+type DestinationStats struct {
+	Address         string `json:"address"`
+	Sent            uint64 `json:"sent"`      // completed without error
+	Failed          uint64 `json:"failed"`    // completed with an error
+	Dropped         uint64 `json:"dropped"`   // discarded because the lane was full
+	Overflow        uint64 `json:"overflow"`  // reliable sends run outside the lane because it was full
+	Coalesced       uint64 `json:"coalesced"` // replaced by a newer send before they ran
+	InFlight        int    `json:"inFlight"`
+	Queued          int    `json:"queued"`
+	LastMethod      string `json:"lastMethod,omitempty"` // of the last failure
+	LastError       string `json:"lastError,omitempty"`
+	LastErrorUnix   int64  `json:"lastErrorUnix,omitempty"`
+	LastSuccessUnix int64  `json:"lastSuccessUnix,omitempty"`
+}
+
+type asyncJob struct {
+	method string
+	call   func() error // guarded by asyncDispatcher.mu while queued
+	latest bool         // queued by sendLatest
+}
+
+type asyncLane struct {
+	jobs   chan *asyncJob
+	latest map[string]*asyncJob // queued sendLatest job per method; guarded by asyncDispatcher.mu
+	stats  DestinationStats     // guarded by asyncDispatcher.mu
+}
+
+type asyncDispatcher struct {
+	metrics *Metrics
+	mu      sync.Mutex
+	lanes   map[string]*asyncLane
+}
+
+func newAsyncDispatcher(metrics *Metrics) *asyncDispatcher {
+	return &asyncDispatcher{metrics: metrics, lanes: map[string]*asyncLane{}}
+}
+
+// lane returns addr's lane, starting its workers on first use.
+func (d *asyncDispatcher) lane(addr string) *asyncLane {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	l, ok := d.lanes[addr]
+	if !ok {
+		l = &asyncLane{jobs: make(chan *asyncJob, asyncLaneQueue), latest: map[string]*asyncJob{}, stats: DestinationStats{Address: addr}}
+		d.lanes[addr] = l
+		for i := 0; i < asyncLaneWorkers; i++ {
+			go d.work(addr, l)
+		}
+	}
+	return l
+}
+
+func (d *asyncDispatcher) work(addr string, l *asyncLane) {
+	for job := range l.jobs {
+		d.mu.Lock()
+		if job.latest && l.latest[job.method] == job {
+			delete(l.latest, job.method) // from now on a newer send queues afresh
+		}
+		d.mu.Unlock()
+		d.run(addr, l, job)
+	}
+}
+
+// send queues call for addr and returns at once. The call is dropped if
+// addr's lane is full.
+func (d *asyncDispatcher) send(addr, method string, call func() error) {
+	l := d.lane(addr)
+	select {
+	case l.jobs <- &asyncJob{method: method, call: call}:
+		d.refreshGauges(addr, l)
+	default:
+		d.drop(addr, l, method)
+	}
+}
+
+// sendLatest is send for messages where only the newest matters, such as
+// heartbeats and snapshots: if a send of the same method to addr is still
+// queued, call replaces it.
+func (d *asyncDispatcher) sendLatest(addr, method string, call func() error) {
+	l := d.lane(addr)
+	d.mu.Lock()
+	if queued := l.latest[method]; queued != nil {
+		queued.call = call
+		l.stats.Coalesced++
+		d.mu.Unlock()
+		d.metrics.Inc(metricName("rpc_async_total", "peer", addr, "method", method, "result", "coalesced"))
+		return
+	}
+	job := &asyncJob{method: method, call: call, latest: true}
+	select {
+	case l.jobs <- job:
+		l.latest[method] = job
+		d.mu.Unlock()
+		d.refreshGauges(addr, l)
+	default:
+		d.mu.Unlock()
+		d.drop(addr, l, method)
+	}
+}
+
+func (d *asyncDispatcher) drop(addr string, l *asyncLane, method string) {
+	d.mu.Lock()
+	l.stats.Dropped++
+	d.mu.Unlock()
+	d.metrics.Inc(metricName("rpc_async_total", "peer", addr, "method", method, "result", "dropped"))
+}
+
+// sendReliable is send for messages whose loss would stall a protocol, such
+// as deferred RA replies. A full lane does not drop the call.
+func (d *asyncDispatcher) sendReliable(addr, method string, call func() error) {
+	l := d.lane(addr)
+	job := &asyncJob{method: method, call: call}
+	select {
+	case l.jobs <- job:
+		d.refreshGauges(addr, l)
+	default:
+		d.mu.Lock()
+		l.stats.Overflow++
+		d.mu.Unlock()
+		d.metrics.Inc(metricName("rpc_async_total", "peer", addr, "method", method, "result", "overflow"))
+		go d.run(addr, l, job)
+	}
+}
+
+func (d *asyncDispatcher) run(addr string, l *asyncLane, job *asyncJob) {
+	d.mu.Lock()
+	l.stats.InFlight++
+	call := job.call
+	d.mu.Unlock()
+	d.refreshGauges(addr, l)
+
+	err := call()
+
+	now := time.Now().Unix()
+	result := "ok"
+	d.mu.Lock()
+	l.stats.InFlight--
+	if err != nil {
+		result = "error"
+		l.stats.Failed++
+		l.stats.LastMethod, l.stats.LastError, l.stats.LastErrorUnix = job.method, err.Error(), now
+	} else {
+		l.stats.Sent++
+		l.stats.LastSuccessUnix = now
+	}
+	d.mu.Unlock()
+	d.metrics.Inc(metricName("rpc_async_total", "peer", addr, "method", job.method, "result", result))
+	d.refreshGauges(addr, l)
+}
+
+func (d *asyncDispatcher) refreshGauges(addr string, l *asyncLane) {
+	d.mu.Lock()
+	inFlight := l.stats.InFlight
+	d.mu.Unlock()
+	d.metrics.Set(metricName("rpc_async_inflight", "peer", addr), float64(inFlight))
+	d.metrics.Set(metricName("rpc_async_queued", "peer", addr), float64(len(l.jobs)))
+}
+
+// fanOut runs call once for every peer, at most maxFanOut at a time, and
+// returns at once. Callers collect results through their own channel,
+// which must have room for every peer.
+func fanOut(peers []string, call func(peer string)) {
+	next := make(chan string, len(peers))
+	for _, p := range peers {
+		next <- p
+	}
+	close(next)
+	for i := 0; i < min(len(peers), maxFanOut); i++ {
+		go func() {
+			for p := range next {
+				call(p)
+			}
+		}()
+	}
+}
+
+// snapshot returns per-destination stats sorted by address.
+func (d *asyncDispatcher) snapshot() []DestinationStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]DestinationStats, 0, len(d.lanes))
+	for _, l := range d.lanes {
+		s := l.stats
+		s.Queued = len(l.jobs)
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out
+}
+
+// handleRPCStatsRequest serves GET /rpcstats.
+func (n *Node) handleRPCStatsRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"nodeId":       n.ID,
+		"laneWorkers":  asyncLaneWorkers,
+		"laneQueue":    asyncLaneQueue,
+		"maxFanOut":    maxFanOut,
+		"destinations": n.async.snapshot(),
+	})
+}
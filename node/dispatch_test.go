@@ -0,0 +1,202 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// laneStats returns d's row for addr.
+func laneStats(d *asyncDispatcher, addr string) DestinationStats {
+	for _, s := range d.snapshot() {
+		if s.Address == addr {
+			return s
+		}
+	}
+	return DestinationStats{Address: addr}
+}
+
+func TestDownPeerShowsFailures(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	c.kill()
+
+	a.broadcastQueueState()
+	waitFor(t, "both snapshot pushes to finish", func() bool {
+		return laneStats(a.async, b.Address).Sent == 1 && laneStats(a.async, c.Address).Failed == 1
+	})
+
+	rec := httptest.NewRecorder()
+	a.handleRPCStatsRequest(rec, httptest.NewRequest(http.MethodGet, "/rpcstats", nil))
+	var stats struct {
+		NodeID       string
+		Destinations []DestinationStats
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("/rpcstats: %d %s: %v", rec.Code, rec.Body, err)
+	}
+	rows := map[string]DestinationStats{}
+	for _, s := range stats.Destinations {
+		rows[s.Address] = s
+	}
+	if up := rows[b.Address]; up.Sent != 1 || up.Failed != 0 || up.LastError != "" || up.LastSuccessUnix == 0 {
+		t.Errorf("live B = %+v", up)
+	}
+	if down := rows[c.Address]; down.Failed != 1 || down.Sent != 0 || down.LastMethod != "SyncQueueState" || down.LastError == "" || down.LastErrorUnix == 0 {
+		t.Errorf("dead C = %+v, want one SyncQueueState failure with its error", down)
+	}
+	if got := a.Metrics.Counter(metricName("rpc_async_total", "peer", c.Address, "method", "SyncQueueState", "result", "error")); got != 1 {
+		t.Errorf("rpc_async_total{peer=C,result=error} = %v, want 1", got)
+	}
+	if got := gauge(a.Metrics, metricName("rpc_async_inflight", "peer", c.Address)); got != 0 {
+		t.Errorf("rpc_async_inflight{peer=C} = %v, want 0", got)
+	}
+}
+
+func TestHungPeerLaneIsBounded(t *testing.T) {
+	d := newAsyncDispatcher(NewMetrics())
+	release := make(chan struct{})
+	hang := func() error { <-release; return nil }
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < asyncLaneWorkers; i++ {
+		d.send("hung:1", "HandleHeartbeat", hang)
+	}
+	waitFor(t, "the lane's workers to take a send each", func() bool { return laneStats(d, "hung:1").InFlight == asyncLaneWorkers })
+	for i := 0; i < asyncLaneQueue+5; i++ {
+		d.send("hung:1", "DecideBid", hang)
+	}
+	s := laneStats(d, "hung:1")
+	if s.Queued != asyncLaneQueue || s.Dropped != 5 {
+		t.Errorf("queued %d, dropped %d; want %d and 5", s.Queued, s.Dropped, asyncLaneQueue)
+	}
+	if got := d.metrics.Counter(metricName("rpc_async_total", "peer", "hung:1", "method", "DecideBid", "result", "dropped")); got != 5 {
+		t.Errorf("rpc_async_total{result=dropped} = %v, want 5", got)
+	}
+	// A send that must not be lost runs beside the full lane.
+	d.sendReliable("hung:1", "HandleRADeferredReply", hang)
+	waitFor(t, "the reliable send to start", func() bool { return laneStats(d, "hung:1").InFlight == asyncLaneWorkers+1 })
+	if s := laneStats(d, "hung:1"); s.Overflow != 1 {
+		t.Errorf("overflow = %d, want 1", s.Overflow)
+	}
+	if extra := runtime.NumGoroutine() - before; extra > asyncLaneWorkers+1 {
+		t.Errorf("%d goroutines for one hung peer, want at most %d", extra, asyncLaneWorkers+1)
+	}
+
+	close(release)
+	want := uint64(asyncLaneWorkers + asyncLaneQueue + 1)
+	waitFor(t, "the lane to drain", func() bool { return laneStats(d, "hung:1").Sent == want })
+	if s := laneStats(d, "hung:1"); s.InFlight != 0 || s.Queued != 0 || s.Failed != 0 {
+		t.Errorf("drained lane = %+v", s)
+	}
+}
+
+func TestSendLatestCoalesces(t *testing.T) {
+	d := newAsyncDispatcher(NewMetrics())
+	release := make(chan struct{})
+	for i := 0; i < asyncLaneWorkers; i++ {
+		d.send("p1:1", "DecideBid", func() error { <-release; return nil })
+	}
+	waitFor(t, "the lane's workers to be busy", func() bool { return laneStats(d, "p1:1").InFlight == asyncLaneWorkers })
+
+	var ran []int
+	done := make(chan struct{})
+	for i := 1; i <= 3; i++ {
+		d.sendLatest("p1:1", "SyncQueueState", func() error {
+			ran = append(ran, i)
+			close(done)
+			return nil
+		})
+	}
+	if s := laneStats(d, "p1:1"); s.Queued != 1 || s.Coalesced != 2 {
+		t.Errorf("queued %d, coalesced %d; want 1 and 2", s.Queued, s.Coalesced)
+	}
+	close(release)
+	<-done
+	if len(ran) != 1 || ran[0] != 3 {
+		t.Errorf("ran snapshot(s) %v, want only the newest", ran)
+	}
+}
+
+func TestFanOutCapsConcurrency(t *testing.T) {
+	peers := make([]string, 3*maxFanOut)
+	for i := range peers {
+		peers[i] = fmt.Sprintf("p%d:1", i)
+	}
+	var running, peak atomic.Int32
+	results := make(chan string, len(peers))
+	fanOut(peers, func(p string) {
+		now := running.Add(1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+		results <- p
+	})
+	seen := map[string]bool{}
+	for range peers {
+		seen[<-results] = true
+	}
+	if len(seen) != len(peers) {
+		t.Errorf("called %d of %d peers", len(seen), len(peers))
+	}
+	if got := peak.Load(); got != maxFanOut {
+		t.Errorf("%d calls ran at once, want %d", got, maxFanOut)
+	}
+}
+
+// BenchmarkBroadcastGoroutines sends a heartbeat and a snapshot to 20 peers
+// per op, each call taking 5ms as a slow peer would, faster than the calls
+// complete. "per-call" starts a goroutine per send as the broadcasts used
+// to; "lanes" queues them on the dispatcher. peak-goroutines is the most
+// running above the baseline, calls/op how many sends actually ran.
+func BenchmarkBroadcastGoroutines(b *testing.B) {
+	peers := make([]string, 20)
+	for i := range peers {
+		peers[i] = fmt.Sprintf("p%d:1", i)
+	}
+	methods := []string{"NodeRPC.HandleHeartbeat", "NodeRPC.SyncQueueState"}
+	bench := func(b *testing.B, send func(peer, method string, call func() error)) {
+		var calls atomic.Int64
+		call := func() error {
+			calls.Add(1)
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}
+		base := runtime.NumGoroutine()
+		peak := 0
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, p := range peers {
+				for _, m := range methods {
+					send(p, m, call)
+				}
+			}
+			peak = max(peak, runtime.NumGoroutine()-base)
+			time.Sleep(time.Millisecond)
+		}
+		b.StopTimer()
+		b.ReportMetric(float64(peak), "peak-goroutines")
+		b.ReportMetric(float64(calls.Load())/float64(b.N), "calls/op")
+	}
+	b.Run("per-call", func(b *testing.B) {
+		bench(b, func(_, _ string, call func() error) { go call() })
+	})
+	b.Run("lanes", func(b *testing.B) {
+		d := newAsyncDispatcher(NewMetrics())
+		bench(b, d.sendLatest)
+	})
+}
@@ -0,0 +1,157 @@
+package node
+
+// electionlog.go — Recent leadership events, GET /election-log.
+//
+// Reconstructing a failover used to mean interleaving the log lines of every
+// node by hand. Each node now keeps its last electionLogSize leadership
+// events in memory: elections it started, Election messages it received and
+// how it answered them, OKs it got back, wins, coordinator announcements it
+// accepted or refused, terms learned from heartbeats, pre-vote deferrals,
+// planned handovers and step-downs. Every event carries the node's Lamport
+// time and wall-clock time, the election term it happened in and, where
+// there is one, the peer involved.
+//
+// GET /election-log asks every peer for its events over
+// NodeRPC.GetElectionLog and merges them by wall-clock time, each entry
+// naming its node; ?scope=local skips the fan-out. The log is not saved:
+// a restarted node starts with an empty one, and the incident timeline
+// (incidents.go) keeps the longer view.
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	electionLogSize        = 100
+	electionLogPeerTimeout = 1500 * time.Millisecond
+)
+
+// Election log event kinds.
+const (
+	ElectionStarted      = "election_started"       // this node campaigns
+	ElectionReceived     = "election_received"      // a candidate's Election message; Detail says how it was answered
+	ElectionOKReceived   = "ok_received"            // a higher-ranked peer will take over
+	ElectionWon          = "election_won"           // this node became coordinator
+	ElectionWithheld     = "election_withheld"      // no OK, but too few nodes reachable to claim
+	CoordinatorAccepted  = "coordinator_accepted"   // a leader announcement was accepted
+	CoordinatorRejected  = "coordinator_rejected"   // a stale leader announcement was refused
+	LeaderLearned        = "leader_learned"         // a newer term or leader learned from a heartbeat or pre-vote
+	LeadershipHandedOver = "leadership_handed_over" // planned transfer, old leader's side
+	LeadershipTakenOver  = "leadership_taken_over"  // planned transfer, new leader's side
+	LeaderSteppedDown    = "step_down"              // this node stopped being coordinator
+)
+
+// ElectionEvent is one entry of the election log.
+type ElectionEvent struct {
+	Seq      int    `json:"seq"` // per node, from 1 since start
+	NodeID   string `json:"nodeId"`
+	Kind     string `json:"kind"`
+	Term     int    `json:"term"`
+	Peer     string `json:"peer,omitempty"` // the other node, by ID; by address for an OK from a peer not yet heard from
+	Detail   string `json:"detail,omitempty"`
+	Lamport  int    `json:"lamport"`
+	AtUnixMs int64  `json:"atUnixMs"`
+}
+
+// electionLog is a fixed-size ring of the latest events.
+type electionLog struct {
+	mu     sync.Mutex
+	seq    int
+	events [electionLogSize]ElectionEvent
+}
+
+func (l *electionLog) add(e ElectionEvent) {
+	l.mu.Lock()
+	l.seq++
+	e.Seq = l.seq
+	l.events[(l.seq-1)%electionLogSize] = e
+	l.mu.Unlock()
+}
+
+// list returns the events held, oldest first.
+func (l *electionLog) list() []ElectionEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	held := min(l.seq, electionLogSize)
+	out := make([]ElectionEvent, 0, held)
+	for seq := l.seq - held + 1; seq <= l.seq; seq++ {
+		out = append(out, l.events[(seq-1)%electionLogSize])
+	}
+	return out
+}
+
+// noteElectionEvent records a leadership event, as a local event of the
+// Lamport clock. It may be called with ElectionMutex held, so term is passed
+// in rather than read.
+func (n *Node) noteElectionEvent(kind string, term int, peer, detail string) {
+	n.electionLog.add(ElectionEvent{
+		NodeID: n.ID, Kind: kind, Term: term, Peer: peer, Detail: detail,
+		Lamport: n.Clock.Tick(), AtUnixMs: time.Now().UnixMilli(),
+	})
+}
+
+// GetElectionLog returns this node's election log, for GET /election-log.
+func (rp *NodeRPC) GetElectionLog(_ EmptyArgs, reply *[]ElectionEvent) error {
+	*reply = rp.node.electionLog.list()
+	return nil
+}
+
+// handleElectionLogRequest serves GET /election-log[?scope=local].
+func (n *Node) handleElectionLogRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	events := n.electionLog.list()
+	unreachable := []IncidentSource{}
+	if r.URL.Query().Get("scope") != "local" {
+		peers := n.peerList()
+		type result struct {
+			peer   string
+			events []ElectionEvent
+			err    error
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), electionLogPeerTimeout)
+		defer cancel()
+		results := make(chan result, len(peers))
+		// Monitoring traffic bypasses callPeer so it creates no checkpoint dependencies.
+		fanOut(peers, func(p string) {
+			var reply []ElectionEvent
+			err := n.Client.CallContext(ctx, p, "NodeRPC.GetElectionLog", EmptyArgs{}, &reply)
+			results <- result{p, reply, err}
+		})
+		for range peers {
+			res := <-results
+			if res.err != nil {
+				unreachable = append(unreachable, IncidentSource{res.peer, res.err.Error()})
+				continue
+			}
+			events = append(events, res.events...)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.AtUnixMs != b.AtUnixMs {
+			return a.AtUnixMs < b.AtUnixMs
+		}
+		if a.NodeID != b.NodeID {
+			return a.NodeID < b.NodeID
+		}
+		return a.Seq < b.Seq
+	})
+	n.ElectionMutex.Lock()
+	term, leader := n.Term, n.Coordinator
+	n.ElectionMutex.Unlock()
+	writeJSON(w, struct {
+		GeneratedAtUnix int64            `json:"generatedAtUnix"`
+		ServedBy        string           `json:"servedBy"`
+		Term            int              `json:"term"`
+		Coordinator     string           `json:"coordinator"`
+		Events          []ElectionEvent  `json:"events"`
+		Unreachable     []IncidentSource `json:"unreachable"`
+	}{time.Now().Unix(), n.ID, term, leader, events, unreachable})
+}
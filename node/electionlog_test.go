@@ -0,0 +1,130 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+// electionLogOf reads GET /election-log from n.
+func electionLogOf(t *testing.T, n *Node, query string) (events []ElectionEvent, term int, coordinator string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	n.handleElectionLogRequest(rec, httptest.NewRequest(http.MethodGet, "/election-log"+query, nil))
+	var body struct {
+		Term        int
+		Coordinator string
+		Events      []ElectionEvent
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /election-log%s: %d %s", query, rec.Code, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Events, body.Term, body.Coordinator
+}
+
+// expectEvents checks that want appears in n's log in order, other events
+// in between allowed. A want entry matches on its non-zero fields.
+func expectEvents(t *testing.T, n *Node, want ...ElectionEvent) {
+	t.Helper()
+	events := n.electionLog.list()
+	i := 0
+	for _, e := range events {
+		if i == len(want) {
+			break
+		}
+		w := want[i]
+		if e.Kind == w.Kind && (w.Peer == "" || e.Peer == w.Peer) && (w.Term == 0 || e.Term == w.Term) && (w.Detail == "" || e.Detail == w.Detail) {
+			i++
+		}
+	}
+	if i < len(want) {
+		t.Errorf("%s's log lacks %+v in order; it holds:", n.ID, want[i])
+		for _, e := range events {
+			t.Logf("  %d %s term %d peer %q %q", e.Seq, e.Kind, e.Term, e.Peer, e.Detail)
+		}
+	}
+	for j, e := range events {
+		if e.NodeID != n.ID || e.Seq != j+1 || (j > 0 && (e.Lamport <= events[j-1].Lamport || e.AtUnixMs < events[j-1].AtUnixMs)) {
+			t.Errorf("%s's event %d out of sequence: %+v", n.ID, j, e)
+		}
+	}
+}
+
+func TestElectionLogRecordsOneElection(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+
+	// B campaigns; C outranks it and takes over.
+	b.runElection()
+	waitFor(t, "A and B to follow C", func() bool {
+		return coordinatorOf(a.Node) == c.ID && coordinatorOf(b.Node) == c.ID
+	})
+	leading(t, c.Node)
+	expectEvents(t, b.Node, ElectionEvent{Kind: ElectionStarted}, ElectionEvent{Kind: ElectionOKReceived})
+	for _, from := range []string{b.ID, c.ID} {
+		expectEvents(t, a.Node, ElectionEvent{Kind: ElectionReceived, Peer: from, Detail: "no OK: candidate outranks us or we are out of service"})
+	}
+	// C's announcement and its first heartbeat race; whichever lands first
+	// is where A and B learn of term 1.
+	for _, tn := range []*testNode{a, b} {
+		learned := false
+		for _, e := range tn.electionLog.list() {
+			if (e.Kind == CoordinatorAccepted || e.Kind == LeaderLearned) && e.Peer == c.ID && e.Term == 1 {
+				learned = true
+			}
+		}
+		if !learned {
+			t.Errorf("%s's log does not show it learning that C leads term 1: %+v", tn.ID, tn.electionLog.list())
+		}
+	}
+
+	// A claim for a later term deposes C.
+	var ok bool
+	if err := (&NodeRPC{node: c.Node}).HandleCoordinator(BullyMessage{NodeID: b.ID, Rank: b.Rank, Term: 2, Address: b.Address}, &ok); err != nil || !ok {
+		t.Fatalf("HandleCoordinator: %v %v", ok, err)
+	}
+	expectEvents(t, c.Node,
+		ElectionEvent{Kind: ElectionReceived, Peer: b.ID, Detail: "answered OK; taking over"},
+		ElectionEvent{Kind: ElectionStarted},
+		ElectionEvent{Kind: ElectionWon, Term: 1},
+		ElectionEvent{Kind: LeaderSteppedDown, Term: 2, Detail: b.ID + " leads"},
+		ElectionEvent{Kind: CoordinatorAccepted, Peer: b.ID, Term: 2},
+	)
+
+	// GET /election-log merges every node's events by time.
+	events, term, coordinator := electionLogOf(t, a.Node, "")
+	if term != 1 || coordinator != c.ID {
+		t.Errorf("A's header: term %d, coordinator %q", term, coordinator)
+	}
+	perNode := map[string]int{}
+	for _, e := range events {
+		perNode[e.NodeID]++
+	}
+	for _, tn := range nodes {
+		if perNode[tn.ID] != len(tn.electionLog.list()) {
+			t.Errorf("merged log has %d of %s's %d events", perNode[tn.ID], tn.ID, len(tn.electionLog.list()))
+		}
+	}
+	if !sort.SliceIsSorted(events, func(i, j int) bool { return events[i].AtUnixMs < events[j].AtUnixMs }) {
+		t.Error("merged log is not in time order")
+	}
+	if local, _, _ := electionLogOf(t, a.Node, "?scope=local"); len(local) != perNode[a.ID] {
+		t.Errorf("?scope=local gave %d events, want A's %d", len(local), perNode[a.ID])
+	}
+}
+
+func TestElectionLogKeepsTheLatest(t *testing.T) {
+	var l electionLog
+	for i := 0; i < electionLogSize+50; i++ {
+		l.add(ElectionEvent{Kind: ElectionStarted})
+	}
+	events := l.list()
+	if len(events) != electionLogSize || events[0].Seq != 51 || events[len(events)-1].Seq != electionLogSize+50 {
+		t.Errorf("ring holds %d events, seq %d..%d", len(events), events[0].Seq, events[len(events)-1].Seq)
+	}
+}
@@ -0,0 +1,91 @@
+package node
+
+// emoji.go — Keyword-based emoji/category inference for items added without one.
+//
+// Inference runs once, on the coordinator, before the item is queued and
+// broadcast; followers and checkpoints only ever see the stored result. The
+// built-in rules can be extended at startup with --emoji-map <file.json>:
+//
+//	[{"keywords": ["vinyl", "record"], "emoji": "💿", "category": "Music"}]
+//
+// File rules are tried first, in file order, then the built-in ones; the
+// first keyword match wins.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// EmojiRule maps any of its keywords to an emoji and category.
+type EmojiRule struct {
+	Keywords []string `json:"keywords"`
+	Emoji    string   `json:"emoji"`
+	Category string   `json:"category"`
+}
+
+const fallbackEmoji = "📦"
+const fallbackCategory = "General"
+
+var defaultEmojiRules = []EmojiRule{
+	{Keywords: []string{"watch", "rolex", "clock"}, Emoji: "⌚", Category: "Watches"},
+	{Keywords: []string{"guitar", "bass", "violin", "piano"}, Emoji: "🎸", Category: "Instruments"},
+	{Keywords: []string{"laptop", "computer", "notebook", "pc"}, Emoji: "💻", Category: "Electronics"},
+	{Keywords: []string{"painting", "art", "canvas", "portrait"}, Emoji: "🖼️", Category: "Art"},
+	{Keywords: []string{"coin", "gold", "silver"}, Emoji: "🪙", Category: "Collectibles"},
+	{Keywords: []string{"shoe", "shoes", "sneaker", "sneakers", "jordan"}, Emoji: "👟", Category: "Fashion"},
+}
+
+var (
+	emojiRulesMu sync.RWMutex
+	emojiRules   = defaultEmojiRules
+)
+
+// LoadEmojiRules puts the JSON rule array in path ahead of the built-in rules.
+func LoadEmojiRules(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var rules []EmojiRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, r := range rules {
+		if r.Emoji == "" || len(r.Keywords) == 0 {
+			return fmt.Errorf("%s: rule %d needs an emoji and at least one keyword", path, i)
+		}
+	}
+	emojiRulesMu.Lock()
+	emojiRules = append(rules, defaultEmojiRules...)
+	emojiRulesMu.Unlock()
+	return nil
+}
+
+// inferEmoji classifies an item by whole-word keyword match on its name, then
+// its description. It is a pure function of the text and the rule table.
+func inferEmoji(name, description string) (emoji, category string) {
+	emojiRulesMu.RLock()
+	rules := emojiRules
+	emojiRulesMu.RUnlock()
+
+	for _, text := range []string{name, description} {
+		words := map[string]bool{}
+		for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			words[w] = true
+		}
+		for _, rule := range rules {
+			for _, kw := range rule.Keywords {
+				if words[strings.ToLower(kw)] {
+					return rule.Emoji, rule.Category
+				}
+			}
+		}
+	}
+	return fallbackEmoji, fallbackCategory
+}
@@ -0,0 +1,82 @@
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInferEmoji(t *testing.T) {
+	cases := []struct {
+		name, description string
+		emoji, category   string
+	}{
+		{"Vintage Rolex", "", "⌚", "Watches"},
+		{"Fender guitar", "", "🎸", "Instruments"},
+		{"Gaming laptop", "", "💻", "Electronics"},
+		{"Oil painting", "", "🖼️", "Art"},
+		{"Roman coin", "", "🪙", "Collectibles"},
+		{"Air Jordan sneakers", "", "👟", "Fashion"},
+		{"Mystery lot", "A gold pocket watch", "⌚", "Watches"},               // description; first rule wins
+		{"Mystery lot", "Contents unknown", fallbackEmoji, fallbackCategory}, // nothing matches
+		{"Watchtower print", "", fallbackEmoji, fallbackCategory},            // whole words only
+		{"LAPTOP!", "", "💻", "Electronics"},
+	}
+	for _, c := range cases {
+		emoji, category := inferEmoji(c.name, c.description)
+		if emoji != c.emoji || category != c.category {
+			t.Errorf("inferEmoji(%q, %q) = %s %s, want %s %s", c.name, c.description, emoji, category, c.emoji, c.category)
+		}
+	}
+}
+
+func TestNewAuctionItemKeepsGivenEmoji(t *testing.T) {
+	args := AddItemArgs{Name: "Rolex", Description: "Watch", StartingPrice: 10, DurationSec: 60}
+	if item := newAuctionItem("item-9", args); item.Emoji != "⌚" || item.Category != "Watches" {
+		t.Errorf("inferred %s %s, want ⌚ Watches", item.Emoji, item.Category)
+	}
+	args.Emoji = "🎁"
+	if item := newAuctionItem("item-9", args); item.Emoji != "🎁" || item.Category != "" {
+		t.Errorf("given emoji: got %s %q, want 🎁 with no category", item.Emoji, item.Category)
+	}
+	args.Emoji, args.Category = "", "Luxury"
+	if item := newAuctionItem("item-9", args); item.Emoji != "⌚" || item.Category != "Luxury" {
+		t.Errorf("given category: got %s %s, want ⌚ Luxury", item.Emoji, item.Category)
+	}
+}
+
+func TestLoadEmojiRules(t *testing.T) {
+	t.Cleanup(func() {
+		emojiRulesMu.Lock()
+		emojiRules = defaultEmojiRules
+		emojiRulesMu.Unlock()
+	})
+	path := filepath.Join(t.TempDir(), "emoji.json")
+	write := func(s string) {
+		if err := os.WriteFile(path, []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`[{"keywords": ["vinyl"], "emoji": "💿", "category": "Music"}, {"keywords": ["watch"], "emoji": "🕰️", "category": "Clocks"}]`)
+	if err := LoadEmojiRules(path); err != nil {
+		t.Fatal(err)
+	}
+	if emoji, category := inferEmoji("Vinyl box set", ""); emoji != "💿" || category != "Music" {
+		t.Errorf("file rule: got %s %s", emoji, category)
+	}
+	if emoji, _ := inferEmoji("Pocket watch", ""); emoji != "🕰️" {
+		t.Errorf("file rules should come before the built-in ones, got %s", emoji)
+	}
+	if emoji, _ := inferEmoji("Guitar", ""); emoji != "🎸" {
+		t.Errorf("built-in rules dropped, got %s", emoji)
+	}
+
+	write(`[{"keywords": [], "emoji": "💿"}]`)
+	if err := LoadEmojiRules(path); err == nil {
+		t.Error("rule without keywords accepted")
+	}
+	if emoji, _ := inferEmoji("Vinyl", ""); emoji != "💿" {
+		t.Error("a rejected file replaced the loaded rules")
+	}
+}
@@ -0,0 +1,399 @@
+package node
+
+// events.go — Historical events: finished auctions kept under a name.
+//
+// When the last lot of an auction closes, or when an admin restarts the
+// auction with archive=true, the coordinator files the results since the
+// previous event as a historical event: the lots with their winners, the
+// totals, and a settlement listing what each winner owes. Events are named
+// after the day they closed and can be renamed later.
+//
+// Events live in the archive store (archive/events_<node ID>/), never in the
+// replicated queue state, so they do not grow snapshots or checkpoints. The
+// coordinator writes each event and pushes it to every peer; a node that
+// was down asks the coordinator for the ones it missed once it is ready.
+// Reads (GET /events/history and the /history page) are served locally on
+// any node.
+//
+// Results already trimmed by --retain-results are in the results archive,
+// not in the event.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxEventNameLen = 100
+
+var eventIDPattern = regexp.MustCompile(`^evt-[0-9]{8}-[0-9]{6}(-[0-9]+)?$`)
+
+// HistoricalEvent is one finished auction, as stored and as served by
+// GET /events/history/{id}.
+type HistoricalEvent struct {
+	ID            string        `json:"id"`
+	Name          string        `json:"name"`
+	Reason        string        `json:"reason"` // "completed" or "restarted"
+	StartedAtUnix int64         `json:"startedAtUnix"`
+	ClosedAtUnix  int64         `json:"closedAtUnix"`
+	CreatedBy     string        `json:"createdBy"` // coordinator node ID
+	UpdatedAtUnix int64         `json:"updatedAtUnix"`
+	Totals        EventTotals   `json:"totals"`
+	Settlement    []EventWinner `json:"settlement"`
+	Results       []ItemResult  `json:"results,omitempty"` // left out of listings
+}
+
+// EventTotals sums up an event.
+type EventTotals struct {
+	Lots    int `json:"lots"`
+	Sold    int `json:"sold"`
+	Unsold  int `json:"unsold"`
+	Revenue int `json:"revenue"`
+	Winners int `json:"winners"`
+}
+
+// EventWinner is one row of an event's settlement.
+type EventWinner struct {
+	Winner   string `json:"winner"`
+	WinnerID string `json:"winnerId"`
+	Lots     int    `json:"lots"`
+	Total    int    `json:"total"`
+}
+
+func eventsDir(nodeID string) string {
+	return filepath.Join(archiveDir, "events_"+nodeID)
+}
+
+func eventPath(nodeID, id string) string {
+	return filepath.Join(eventsDir(nodeID), id+".json")
+}
+
+func loadEvent(nodeID, id string) (*HistoricalEvent, error) {
+	if !eventIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("event %q not found", id)
+	}
+	b, err := os.ReadFile(eventPath(nodeID, id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("event %q not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ev HistoricalEvent
+	if err := json.Unmarshal(b, &ev); err != nil {
+		return nil, fmt.Errorf("parse event %q: %w", id, err)
+	}
+	if ev.Settlement == nil {
+		ev.Settlement = []EventWinner{} // gob turns an empty list into nil on followers
+	}
+	return &ev, nil
+}
+
+// listEvents returns every stored event, newest first. Unreadable files are
+// logged and skipped.
+func (n *Node) listEvents() []HistoricalEvent {
+	files, _ := filepath.Glob(filepath.Join(eventsDir(n.ID), "evt-*.json"))
+	out := make([]HistoricalEvent, 0, len(files))
+	for _, f := range files {
+		ev, err := loadEvent(n.ID, strings.TrimSuffix(filepath.Base(f), ".json"))
+		if err != nil {
+			log.Printf("[%s] Warning: skipping event file %s: %v\n", n.ID, f, err)
+			continue
+		}
+		out = append(out, *ev)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ClosedAtUnix > out[j].ClosedAtUnix })
+	return out
+}
+
+// storeEvent writes ev unless a copy at least as new is already stored. It
+// reports whether the file changed.
+func (n *Node) storeEvent(ev HistoricalEvent) (bool, error) {
+	if !eventIDPattern.MatchString(ev.ID) {
+		return false, fmt.Errorf("invalid event id %q", ev.ID)
+	}
+	n.eventsMu.Lock()
+	defer n.eventsMu.Unlock()
+	if old, err := loadEvent(n.ID, ev.ID); err == nil && old.UpdatedAtUnix >= ev.UpdatedAtUnix {
+		return false, nil
+	}
+	b, err := json.MarshalIndent(ev, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(eventsDir(n.ID), 0o755); err != nil {
+		return false, err
+	}
+	tmp := eventPath(n.ID, ev.ID) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp, eventPath(n.ID, ev.ID))
+}
+
+// newHistoricalEvent builds an event from the results that closed after the
+// newest existing event. It returns nil if there are none.
+func newHistoricalEvent(results []ItemResult, reason, createdBy string, existing []HistoricalEvent) *HistoricalEvent {
+	var after int64
+	names := map[string]bool{}
+	ids := map[string]bool{}
+	for _, ev := range existing {
+		after = max(after, ev.ClosedAtUnix)
+		names[ev.Name] = true
+		ids[ev.ID] = true
+	}
+	var lots []ItemResult
+	for _, r := range results {
+		if r.ClosedAtUnix > after {
+			lots = append(lots, r)
+		}
+	}
+	if len(lots) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	ev := &HistoricalEvent{
+		Reason:        reason,
+		StartedAtUnix: lots[0].OpenedAtUnix,
+		ClosedAtUnix:  lots[len(lots)-1].ClosedAtUnix,
+		CreatedBy:     createdBy,
+		UpdatedAtUnix: now.Unix(),
+		Results:       lots,
+	}
+	ev.ID = "evt-" + now.UTC().Format("20060102-150405")
+	for i := 2; ids[ev.ID]; i++ {
+		ev.ID = fmt.Sprintf("evt-%s-%d", now.UTC().Format("20060102-150405"), i)
+	}
+	base := "Auction of " + time.Unix(ev.ClosedAtUnix, 0).Format("Jan 2, 2006")
+	ev.Name = base
+	for i := 2; names[ev.Name]; i++ {
+		ev.Name = fmt.Sprintf("%s (%d)", base, i)
+	}
+
+	byWinner := map[string]*EventWinner{}
+	for _, r := range lots {
+		ev.Totals.Lots++
+		if r.WinningBid <= 0 {
+			ev.Totals.Unsold++
+			continue
+		}
+		ev.Totals.Sold++
+		ev.Totals.Revenue += r.WinningBid
+		key := r.WinnerID
+		if key == "" {
+			key = "name:" + r.Winner
+		}
+		w, ok := byWinner[key]
+		if !ok {
+			w = &EventWinner{Winner: r.Winner, WinnerID: r.WinnerID}
+			byWinner[key] = w
+		}
+		w.Lots++
+		w.Total += r.WinningBid
+	}
+	ev.Totals.Winners = len(byWinner)
+	ev.Settlement = []EventWinner{}
+	for _, w := range byWinner {
+		ev.Settlement = append(ev.Settlement, *w)
+	}
+	sort.Slice(ev.Settlement, func(i, j int) bool {
+		if ev.Settlement[i].Total != ev.Settlement[j].Total {
+			return ev.Settlement[i].Total > ev.Settlement[j].Total
+		}
+		return ev.Settlement[i].Winner < ev.Settlement[j].Winner
+	})
+	return ev
+}
+
+// archiveEvent files results as a new event and sends it to every peer.
+// Coordinator only.
+func (n *Node) archiveEvent(reason string, results []ItemResult) {
+	ev := newHistoricalEvent(results, reason, n.ID, n.listEvents())
+	if ev == nil {
+		return
+	}
+	if _, err := n.storeEvent(*ev); err != nil {
+		log.Printf("[%s] Warning: could not archive event %s: %v\n", n.ID, ev.ID, err)
+		return
+	}
+	log.Printf("[%s] 📚 Archived %q (%s): %d lots, $%d\n", n.ID, ev.Name, ev.ID, ev.Totals.Lots, ev.Totals.Revenue)
+	n.Metrics.Inc(metricName("events_archived_total", "reason", reason))
+	n.pushEvent(*ev)
+}
+
+func (n *Node) pushEvent(ev HistoricalEvent) {
+	for _, peer := range n.peerList() {
+		p := peer
+		n.async.sendReliable(p, "StoreEvent", func() error {
+			var ok bool
+			return n.callPeer(p, "NodeRPC.StoreEvent", ev, &ok)
+		})
+	}
+}
+
+// StoreEvent saves an event created or renamed by the coordinator.
+func (rp *NodeRPC) StoreEvent(args HistoricalEvent, reply *bool) error {
+	changed, err := rp.node.storeEvent(args)
+	if err != nil {
+		return err
+	}
+	*reply = changed
+	return nil
+}
+
+// ListEvents returns every event stored here, for a node catching up.
+func (rp *NodeRPC) ListEvents(args EmptyArgs, reply *[]HistoricalEvent) error {
+	*reply = rp.node.listEvents()
+	return nil
+}
+
+// catchUpEvents copies the events this node missed while it was down from
+// the coordinator.
+func (n *Node) catchUpEvents() {
+	coordinatorAddress, isLocal := n.getCoordinatorAddress()
+	if isLocal || coordinatorAddress == "" {
+		return
+	}
+	var events []HistoricalEvent
+	if err := n.callPeer(coordinatorAddress, "NodeRPC.ListEvents", EmptyArgs{}, &events); err != nil {
+		return // older coordinator, or gone again; reads just show what is here
+	}
+	copied := 0
+	for _, ev := range events {
+		if changed, err := n.storeEvent(ev); err == nil && changed {
+			copied++
+		}
+	}
+	if copied > 0 {
+		log.Printf("[%s] 📚 Copied %d historical event(s) from the coordinator\n", n.ID, copied)
+	}
+}
+
+// RenameEventArgs renames a historical event.
+type RenameEventArgs struct {
+	ID   string
+	Name string
+}
+
+// renameEventAndBroadcast renames an event. Coordinator only; followers
+// forward via SubmitEventRenameToCoordinator.
+func (n *Node) renameEventAndBroadcast(args RenameEventArgs) (int, string) {
+	name := strings.TrimSpace(args.Name)
+	if name == "" || len(name) > maxEventNameLen {
+		return http.StatusBadRequest, fmt.Sprintf("name must be 1-%d characters", maxEventNameLen)
+	}
+	ev, err := loadEvent(n.ID, args.ID)
+	if err != nil {
+		return http.StatusNotFound, err.Error()
+	}
+	old := ev.Name
+	ev.Name = name
+	ev.UpdatedAtUnix = max(time.Now().Unix(), ev.UpdatedAtUnix+1)
+	if _, err := n.storeEvent(*ev); err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	log.Printf("[%s] 📚 Renamed event %s: %q → %q\n", n.ID, ev.ID, old, name)
+	n.pushEvent(*ev)
+	return http.StatusOK, fmt.Sprintf("Event %s renamed to %q", ev.ID, name)
+}
+
+// SubmitEventRenameToCoordinator forwards POST /admin/events/rename.
+func (rp *NodeRPC) SubmitEventRenameToCoordinator(args RenameEventArgs, reply *CoordinatorActionReply) error {
+	if _, isLocal := rp.node.getCoordinatorAddress(); !isLocal {
+		reply.Accepted = false
+		reply.Message = "This node is not the coordinator"
+		return nil
+	}
+	status, message := rp.node.renameEventAndBroadcast(args)
+	reply.Accepted, reply.Message = status == http.StatusOK, message
+	return nil
+}
+
+// submitEventRename renames an event on the coordinator, forwarding if needed.
+func (n *Node) submitEventRename(args RenameEventArgs) (int, string) {
+	coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
+	if isLocalCoordinator {
+		return n.renameEventAndBroadcast(args)
+	}
+	if coordinatorAddress == "" {
+		return http.StatusServiceUnavailable, "Election in progress, please wait"
+	}
+	var reply CoordinatorActionReply
+	if err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitEventRenameToCoordinator", args, &reply); err != nil {
+		return http.StatusServiceUnavailable, "Leader unavailable; retry shortly"
+	}
+	if !reply.Accepted {
+		return http.StatusBadRequest, reply.Message
+	}
+	return http.StatusOK, reply.Message
+}
+
+// handleEventsHistoryRequest serves GET /events/history (summaries, newest
+// first) and GET /events/history/{id} (one event; ?download=1 to save it).
+func (n *Node) handleEventsHistoryRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/events/history"), "/")
+	if id == "" {
+		events := n.listEvents()
+		for i := range events {
+			events[i].Results = nil
+		}
+		writeJSON(w, events)
+		return
+	}
+	ev, err := loadEvent(n.ID, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("download") != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", ev.ID+".json"))
+	}
+	writeJSON(w, ev)
+}
+
+// handleEventRenameRequest serves POST /admin/events/rename with a JSON body
+// {"id": "evt-...", "name": "..."} or the same form fields.
+func (n *Node) handleEventRenameRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var args RenameEventArgs
+	if strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "application/json") {
+		var req struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+		args = RenameEventArgs{ID: req.ID, Name: req.Name}
+	} else {
+		args = RenameEventArgs{ID: r.FormValue("id"), Name: r.FormValue("name")}
+	}
+
+	var status int
+	var message string
+	if !n.holdForClient(r, "admin_event_rename", func() { status, message = n.submitEventRename(args) }) {
+		return
+	}
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(message))
+}
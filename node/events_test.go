@@ -0,0 +1,172 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func eventsOn(t *testing.T, n *Node) []HistoricalEvent {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	n.handleEventsHistoryRequest(rec, httptest.NewRequest(http.MethodGet, "/events/history", nil))
+	var events []HistoricalEvent
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+		t.Fatalf("/events/history on %s: %d %s", n.ID, rec.Code, rec.Body)
+	}
+	return events
+}
+
+// lotsOf renders an event's results as "item:winner:amount".
+func lotsOf(ev HistoricalEvent) []string {
+	out := make([]string, len(ev.Results))
+	for i, r := range ev.Results {
+		out[i] = fmt.Sprintf("%s:%s:%d", r.Item.ID, r.WinnerID, r.WinningBid)
+	}
+	return out
+}
+
+func TestTwoAuctionsBecomeSeparateEvents(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	bid := func(bidder string, amount int) {
+		t.Helper()
+		a.Queue.mu.Lock()
+		itemID := a.Queue.CurrentItem.ID
+		a.Queue.mu.Unlock()
+		if got := a.submitBid(context.Background(), BidArgs{BidderID: bidder, DisplayName: bidder, Amount: amount, ItemID: itemID}).Code; got != BidCommitted {
+			t.Fatalf("%s at $%d on %s = %s", bidder, amount, itemID, got)
+		}
+	}
+	expectEvents := func(when string, count int) {
+		t.Helper()
+		for _, tn := range nodes {
+			waitFor(t, tn.ID+" to hold the events "+when, func() bool { return len(eventsOn(t, tn.Node)) == count })
+		}
+	}
+
+	// Yesterday's auction: lot1 was the only lot, and went to b1.
+	for _, tn := range nodes {
+		tn.Queue.mu.Lock()
+		tn.Queue.Queue = nil
+		tn.Queue.mu.Unlock()
+	}
+	bid("b1", 15)
+	a.Queue.mu.Lock()
+	a.Queue.DeadlineUnix = time.Now().Add(-24 * time.Hour).Unix()
+	a.finalizeCurrentItemLocked()
+	a.Queue.mu.Unlock()
+	a.startNextItem()
+	expectEvents("once the first auction completes", 1)
+
+	// Restarting with archive files nothing new: lot1 is already in an event.
+	if ok, msg := a.restartAuctionAndBroadcast(true); !ok {
+		t.Fatalf("restart: %s", msg)
+	}
+	waitNoGoroutines(t, []string{"node.(*Node).archiveEvent"})
+	first := defaultItems()[0]
+	bid("b2", first.StartingPrice+5)
+	a.Queue.mu.Lock()
+	a.finalizeCurrentItemLocked()
+	a.Queue.mu.Unlock()
+	if ok, msg := a.restartAuctionAndBroadcast(true); !ok {
+		t.Fatalf("second restart: %s", msg)
+	}
+	expectEvents("after the second auction", 2)
+
+	// Each event holds only its own auction, newest first, on every node.
+	events := eventsOn(t, b.Node)
+	latest, earlier := events[0], events[1]
+	if latest.Reason != "restarted" || earlier.Reason != "completed" || latest.Name == earlier.Name || !strings.HasPrefix(earlier.Name, "Auction of ") {
+		t.Errorf("events = %q (%s), %q (%s)", latest.Name, latest.Reason, earlier.Name, earlier.Reason)
+	}
+	if latest.Results != nil || earlier.Results != nil {
+		t.Error("the listing includes results")
+	}
+	for _, tc := range []struct {
+		ev         HistoricalEvent
+		lots       []string
+		totals     EventTotals
+		settlement []EventWinner
+	}{
+		{earlier, []string{"lot1:b1:15"}, EventTotals{Lots: 1, Sold: 1, Revenue: 15, Winners: 1},
+			[]EventWinner{{Winner: "b1", WinnerID: "b1", Lots: 1, Total: 15}}},
+		{latest, []string{fmt.Sprintf("%s:b2:%d", first.ID, first.StartingPrice+5)}, EventTotals{Lots: 1, Sold: 1, Revenue: first.StartingPrice + 5, Winners: 1},
+			[]EventWinner{{Winner: "b2", WinnerID: "b2", Lots: 1, Total: first.StartingPrice + 5}}},
+	} {
+		for _, tn := range nodes {
+			ev, err := loadEvent(tn.ID, tc.ev.ID)
+			if err != nil {
+				t.Fatalf("%s: %v", tn.ID, err)
+			}
+			if !reflect.DeepEqual(lotsOf(*ev), tc.lots) || ev.Totals != tc.totals || !reflect.DeepEqual(ev.Settlement, tc.settlement) {
+				t.Errorf("%s on %s: lots %v totals %+v settlement %+v, want %v %+v %+v",
+					tc.ev.ID, tn.ID, lotsOf(*ev), ev.Totals, ev.Settlement, tc.lots, tc.totals, tc.settlement)
+			}
+		}
+	}
+
+	// One event can be downloaded on its own; none of them is live state.
+	rec := httptest.NewRecorder()
+	b.handleEventsHistoryRequest(rec, httptest.NewRequest(http.MethodGet, "/events/history/"+earlier.ID+"?download=1", nil))
+	var one HistoricalEvent
+	if err := json.Unmarshal(rec.Body.Bytes(), &one); err != nil || one.ID != earlier.ID || len(one.Results) != 1 ||
+		!strings.Contains(rec.Header().Get("Content-Disposition"), earlier.ID+".json") {
+		t.Errorf("download: %d %s %q", rec.Code, rec.Body, rec.Header().Get("Content-Disposition"))
+	}
+	rec = httptest.NewRecorder()
+	b.handleEventsHistoryRequest(rec, httptest.NewRequest(http.MethodGet, "/events/history/evt-nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown event: %d, want 404", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	a.handleStateRequest(rec, httptest.NewRequest(http.MethodGet, "/state", nil))
+	if strings.Contains(rec.Body.String(), "evt-") {
+		t.Errorf("/state carries events: %s", rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	c.handleHistoryUI(rec, httptest.NewRequest(http.MethodGet, "/history", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Past events") {
+		t.Errorf("/history: %d", rec.Code)
+	}
+
+	// A rename through a follower reaches every node.
+	rename := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/events/rename", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		b.handleEventRenameRequest(rec, req)
+		return rec
+	}
+	if rec := rename(`{"id": "` + earlier.ID + `", "name": "  "}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("blank name: %d %s, want 400", rec.Code, rec.Body)
+	}
+	if rec := rename(`{"id": "` + earlier.ID + `", "name": "Spring gala"}`); rec.Code != http.StatusOK {
+		t.Fatalf("rename via B: %d %s", rec.Code, rec.Body)
+	}
+	for _, tn := range nodes {
+		waitFor(t, tn.ID+" to see the new name", func() bool {
+			ev, err := loadEvent(tn.ID, earlier.ID)
+			return err == nil && ev.Name == "Spring gala" && len(ev.Results) == 1
+		})
+	}
+
+	// A node that missed both auctions copies them from the coordinator.
+	d := startTestNode(t, "D", 4)
+	setLeader(d.Node, a.ID, a.Address)
+	d.catchUpEvents()
+	if got := eventsOn(t, d.Node); len(got) != 2 || got[0].ID != latest.ID || got[1].Name != "Spring gala" {
+		t.Errorf("D after catching up = %+v", got)
+	}
+}
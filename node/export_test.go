@@ -0,0 +1,111 @@
+package node
+
+// export_test.go — A running cluster for tests outside the package, such as
+// the client package's in node_test.
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+// APICluster is a cluster laid out as Start lays it out with --tls-cert,
+// --require-client-cert and --admin-port: RPC and the public routes on one
+// TLS port per node, the admin routes on a plain one behind the token. The
+// last node is the coordinator and lot1 is up at 10 everywhere.
+type APICluster struct {
+	CAFile   string // the cluster CA
+	CertFile string // a client certificate from the CA, and its key
+	KeyFile  string
+
+	t      *testing.T
+	nodes  []*testNode
+	admins []*http.Server
+}
+
+// StartAPICluster starts one node per ID with token as --admin-token.
+func StartAPICluster(t *testing.T, token string, ids ...string) *APICluster {
+	t.Helper()
+	ca := newTestCA(t, "cluster")
+	configs := make([]*ClusterTLS, len(ids))
+	for i, id := range ids {
+		configs[i] = ca.clusterTLS(t, id, true)
+	}
+	c := &APICluster{CAFile: ca.caFile, t: t}
+	c.CertFile, c.KeyFile = ca.issue(t, "integration")
+	c.nodes = tlsCluster(t, ids, configs)
+	c.admins = make([]*http.Server, len(ids))
+	leader := c.nodes[len(ids)-1]
+	for i, tn := range c.nodes {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tn.AdminAddress, tn.AdminToken = l.Addr().String(), token
+		tn.registerHTTPRoutes(tn.mux)
+		c.serveAdmin(i, l)
+		withLotUp(tn.Node)
+		setLeader(tn.Node, leader.ID, leader.Address)
+	}
+	leading(t, leader.Node)
+	return c
+}
+
+func (c *APICluster) serveAdmin(i int, l net.Listener) {
+	srv := &http.Server{Handler: c.nodes[i].adminHandler()}
+	c.admins[i] = srv
+	go srv.Serve(l)
+	c.t.Cleanup(func() { srv.Close() })
+}
+
+// Node returns the i'th node.
+func (c *APICluster) Node(i int) *Node { return c.nodes[i].Node }
+
+// URLs are the nodes' public base URLs.
+func (c *APICluster) URLs() []string {
+	urls := make([]string, len(c.nodes))
+	for i, tn := range c.nodes {
+		urls[i] = "https://" + tn.Address
+	}
+	return urls
+}
+
+// AdminURLs are the nodes' admin base URLs.
+func (c *APICluster) AdminURLs() []string {
+	urls := make([]string, len(c.nodes))
+	for i, tn := range c.nodes {
+		urls[i] = "http://" + tn.AdminAddress
+	}
+	return urls
+}
+
+// RotateToken restarts every node's admin listener on its address with a
+// new --admin-token, as a rolling restart of the cluster would.
+func (c *APICluster) RotateToken(token string) {
+	c.t.Helper()
+	for i, tn := range c.nodes {
+		c.admins[i].Close()
+		l, err := net.Listen("tcp", tn.AdminAddress)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		tn.AdminToken = token
+		c.serveAdmin(i, l)
+	}
+}
+
+// HighestBid is node i's highest bid on the lot up.
+func (c *APICluster) HighestBid(i int) int { return highestBid(c.nodes[i].Node) }
+
+// CommittedBids counts node i's committed bids by bidder ID.
+func (c *APICluster) CommittedBids(i int) map[string]int {
+	counts := map[string]int{}
+	for bidder, recs := range c.nodes[i].bids.snapshot() {
+		for _, r := range recs {
+			if r.TxnID != "" {
+				counts[bidder]++
+			}
+		}
+	}
+	return counts
+}
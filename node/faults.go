@@ -0,0 +1,228 @@
+package node
+
+// faults.go — Runtime fault injection: added latency on outbound RPC.
+//
+// For demos of what a WAN does to the cluster, POST /admin/latency holds
+// every RPC this node sends to a peer for delayMs, plus or minus up to
+// jitterMs, before it is sent. Peer "*" applies to every peer without a
+// rule of its own. The endpoint only exists with --enable-fault-injection.
+//
+// The delay sits in RPCClient, next to the circuit breaker, so it covers
+// every caller: 2PC, heartbeats, snapshot pushes, checkpoints. It waits on a
+// timer together with the call's context, so a caller that gives up is
+// released at once instead of sleeping out the delay. Injected time counts
+// towards the round trip RPCClient measures per peer, so /peers and
+// rpc_call_seconds{peer} show the delay as the rest of the node sees it.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxInjectedDelay = 60 * time.Second
+	allPeers         = "*"
+	rttSmoothing     = 0.2 // weight of the newest sample in RTTStats.AvgMs
+)
+
+// LatencyRule is the latency injected on RPC to one peer.
+type LatencyRule struct {
+	Peer      string `json:"peer"`
+	DelayMs   int    `json:"delayMs"`
+	JitterMs  int    `json:"jitterMs"`
+	SetAtUnix int64  `json:"setAtUnix"`
+}
+
+// RTTStats summarizes the round trips of successful calls to one peer.
+type RTTStats struct {
+	Samples int64   `json:"samples"`
+	LastMs  float64 `json:"lastMs"`
+	AvgMs   float64 `json:"avgMs"` // exponentially weighted
+	MaxMs   float64 `json:"maxMs"`
+}
+
+// SetLatency installs rule, replacing any rule for the same peer. A rule
+// with no delay and no jitter removes it.
+func (c *RPCClient) SetLatency(rule LatencyRule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rule.DelayMs == 0 && rule.JitterMs == 0 {
+		delete(c.latency, rule.Peer)
+		return
+	}
+	if c.latency == nil {
+		c.latency = map[string]LatencyRule{}
+	}
+	c.latency[rule.Peer] = rule
+}
+
+// ClearLatency removes the rule for peer, or every rule when peer is empty.
+func (c *RPCClient) ClearLatency(peer string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if peer == "" {
+		c.latency = nil
+		return
+	}
+	delete(c.latency, peer)
+}
+
+// Latency returns the rule that applies to address, if any.
+func (c *RPCClient) Latency(address string) (LatencyRule, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.latencyLocked(address)
+}
+
+func (c *RPCClient) latencyLocked(address string) (LatencyRule, bool) {
+	if rule, ok := c.latency[address]; ok {
+		return rule, true
+	}
+	rule, ok := c.latency[allPeers]
+	return rule, ok
+}
+
+// LatencyRules returns every installed rule, sorted by peer.
+func (c *RPCClient) LatencyRules() []LatencyRule {
+	c.mu.Lock()
+	rules := make([]LatencyRule, 0, len(c.latency))
+	for _, rule := range c.latency {
+		rules = append(rules, rule)
+	}
+	c.mu.Unlock()
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Peer < rules[j].Peer })
+	return rules
+}
+
+// injectedDelay draws the delay for one call to address.
+func (c *RPCClient) injectedDelay(address string) time.Duration {
+	c.mu.Lock()
+	rule, ok := c.latencyLocked(address)
+	c.mu.Unlock()
+	if !ok {
+		return 0
+	}
+	ms := rule.DelayMs
+	if rule.JitterMs > 0 {
+		ms += rand.Intn(2*rule.JitterMs+1) - rule.JitterMs
+	}
+	return time.Duration(max(ms, 0)) * time.Millisecond
+}
+
+// observeRTT records one successful call to address.
+func (c *RPCClient) observeRTT(address string, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	c.mu.Lock()
+	s := c.rtt[address]
+	if s == nil {
+		if c.rtt == nil {
+			c.rtt = map[string]*RTTStats{}
+		}
+		s = &RTTStats{AvgMs: ms}
+		c.rtt[address] = s
+	}
+	s.Samples++
+	s.LastMs = ms
+	s.AvgMs += rttSmoothing * (ms - s.AvgMs)
+	s.MaxMs = max(s.MaxMs, ms)
+	c.mu.Unlock()
+	if c.OnRoundTrip != nil {
+		c.OnRoundTrip(address, d)
+	}
+}
+
+// RTT returns the round-trip stats for address, if it has been reached.
+func (c *RPCClient) RTT(address string) (RTTStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s := c.rtt[address]; s != nil {
+		return *s, true
+	}
+	return RTTStats{}, false
+}
+
+// handleLatencyRequest serves /admin/latency:
+//
+//	GET                                   — installed rules
+//	POST {"peer","delayMs","jitterMs"}    — set (delayMs=jitterMs=0 clears)
+//	DELETE ?peer=<address>                — clear one peer, or all without peer
+func (n *Node) handleLatencyRequest(w http.ResponseWriter, r *http.Request) {
+	if !n.FaultInjection {
+		http.Error(w, "Fault injection is disabled; start the node with --enable-fault-injection", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		rule, err := parseLatencyRule(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule.SetAtUnix = time.Now().Unix()
+		n.Client.SetLatency(rule)
+		n.Metrics.Set(metricName("injected_latency_ms", "peer", rule.Peer), float64(rule.DelayMs))
+		if rule.DelayMs == 0 && rule.JitterMs == 0 {
+			log.Printf("[%s] 🐢 Cleared injected latency to %s\n", n.ID, rule.Peer)
+		} else {
+			log.Printf("[%s] 🐢 Injecting %dms ±%dms latency on RPC to %s\n", n.ID, rule.DelayMs, rule.JitterMs, rule.Peer)
+		}
+	case http.MethodDelete:
+		peer := r.URL.Query().Get("peer")
+		for _, rule := range n.Client.LatencyRules() {
+			if peer == "" || rule.Peer == peer {
+				n.Metrics.Set(metricName("injected_latency_ms", "peer", rule.Peer), 0)
+			}
+		}
+		n.Client.ClearLatency(peer)
+		if peer == "" {
+			peer = "all peers"
+		}
+		log.Printf("[%s] 🐢 Cleared injected latency to %s\n", n.ID, peer)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"rules": n.Client.LatencyRules()})
+}
+
+// parseLatencyRule reads a rule from a JSON or form body.
+func parseLatencyRule(r *http.Request) (LatencyRule, error) {
+	var rule LatencyRule
+	if strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			return rule, fmt.Errorf("invalid JSON request: %v", err)
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return rule, fmt.Errorf("invalid form request")
+		}
+		rule.Peer = r.FormValue("peer")
+		for field, dst := range map[string]*int{"delayMs": &rule.DelayMs, "jitterMs": &rule.JitterMs} {
+			if v := r.FormValue(field); v != "" {
+				ms, err := strconv.Atoi(v)
+				if err != nil {
+					return rule, fmt.Errorf("%s must be a whole number of milliseconds", field)
+				}
+				*dst = ms
+			}
+		}
+	}
+	rule.Peer = strings.TrimSpace(rule.Peer)
+	switch {
+	case rule.Peer == "":
+		return rule, fmt.Errorf("peer is required (an RPC address, or %q for every peer)", allPeers)
+	case rule.DelayMs < 0 || rule.JitterMs < 0:
+		return rule, fmt.Errorf("delayMs and jitterMs must not be negative")
+	case time.Duration(rule.DelayMs+rule.JitterMs)*time.Millisecond > maxInjectedDelay:
+		return rule, fmt.Errorf("delayMs + jitterMs must not exceed %s", maxInjectedDelay)
+	}
+	return rule, nil
+}
@@ -0,0 +1,150 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func latencyRequest(n *Node, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	n.handleLatencyRequest(rec, req)
+	return rec
+}
+
+// peersOf returns n's GET /peers entries by address.
+func peersOf(t *testing.T, n *Node) map[string]PeerVersion {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	n.handlePeersRequest(rec, httptest.NewRequest(http.MethodGet, "/peers", nil))
+	var body struct{ Peers []PeerVersion }
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]PeerVersion{}
+	for _, p := range body.Peers {
+		out[p.Address] = p
+	}
+	return out
+}
+
+func TestInjectedLatencyShowsInRTT(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	ping := func(addr string) {
+		t.Helper()
+		var snap QueueSnapshot
+		if err := a.callPeer(addr, "NodeRPC.GetQueueState", EmptyArgs{}, &snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rpcSeconds := func(addr string) (uint64, float64) {
+		h := a.Metrics.Histogram(metricName("rpc_call_seconds", "peer", addr))
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.count, h.sum
+	}
+
+	if rec := latencyRequest(a.Node, http.MethodPost, "/admin/latency", `{"peer": "*", "delayMs": 100}`); rec.Code != http.StatusNotFound {
+		t.Errorf("without --enable-fault-injection: %d, want 404", rec.Code)
+	}
+	a.FaultInjection = true
+	if rec := latencyRequest(a.Node, http.MethodPost, "/admin/latency", `{"peer": "`+b.Address+`", "delayMs": 150, "jitterMs": 20}`); rec.Code != http.StatusOK {
+		t.Fatalf("set latency: %d %s", rec.Code, rec.Body)
+	}
+	for i := 0; i < 5; i++ {
+		ping(b.Address)
+		ping(c.Address)
+	}
+
+	// B's round trips carry the delay; C's do not.
+	peers := peersOf(t, a.Node)
+	rttB, rttC := peers[b.Address].RTT, peers[c.Address].RTT
+	if rttB == nil || rttB.Samples != 5 || rttB.LastMs < 130 || rttB.AvgMs < 130 || rttB.MaxMs > 1000 {
+		t.Errorf("RTT to B = %+v, want five samples of 130-170ms", rttB)
+	}
+	if rttC == nil || rttC.MaxMs >= 100 {
+		t.Errorf("RTT to C = %+v, want no delay", rttC)
+	}
+	if rule := peers[b.Address].Latency; rule == nil || rule.DelayMs != 150 || rule.JitterMs != 20 || rule.SetAtUnix == 0 {
+		t.Errorf("/peers rule for B = %+v", rule)
+	}
+	if peers[c.Address].Latency != nil {
+		t.Errorf("/peers shows a rule for C: %+v", peers[c.Address].Latency)
+	}
+	if count, sum := rpcSeconds(b.Address); count != 5 || sum < 5*0.13 {
+		t.Errorf("rpc_call_seconds{peer=B}: %d calls, %.3fs; want 5 delayed calls", count, sum)
+	}
+	if got := gauge(a.Metrics, metricName("injected_latency_ms", "peer", b.Address)); got != 150 {
+		t.Errorf("injected_latency_ms{peer=B} = %v, want 150", got)
+	}
+
+	// A caller that gives up is released at once, not after the delay.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var snap QueueSnapshot
+	if err := a.callPeerContext(ctx, b.Address, "NodeRPC.GetQueueState", EmptyArgs{}, &snap); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("call with a short deadline = %v", err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("cancelled call took %s", d)
+	}
+
+	// "*" covers C too, until every rule is cleared.
+	if rec := latencyRequest(a.Node, http.MethodPost, "/admin/latency", `{"peer": "*", "delayMs": 80}`); rec.Code != http.StatusOK {
+		t.Fatalf("set latency for all peers: %d %s", rec.Code, rec.Body)
+	}
+	ping(c.Address)
+	if rtt, _ := a.Client.RTT(c.Address); rtt.LastMs < 70 {
+		t.Errorf("RTT to C under \"*\" = %+v", rtt)
+	}
+	if rule, _ := a.Client.Latency(b.Address); rule.DelayMs != 150 {
+		t.Errorf("B's own rule = %+v, want it to win over \"*\"", rule)
+	}
+	if rec := latencyRequest(a.Node, http.MethodDelete, "/admin/latency", ""); rec.Code != http.StatusOK || len(a.Client.LatencyRules()) != 0 {
+		t.Fatalf("clear: %d %s", rec.Code, rec.Body)
+	}
+	ping(b.Address)
+	if rtt, _ := a.Client.RTT(b.Address); rtt.LastMs >= 100 {
+		t.Errorf("RTT to B after clearing = %+v", rtt)
+	}
+	if got := gauge(a.Metrics, metricName("injected_latency_ms", "peer", b.Address)); got != 0 {
+		t.Errorf("injected_latency_ms{peer=B} after clearing = %v", got)
+	}
+}
+
+func TestLatencyRuleValidation(t *testing.T) {
+	n := biddingNode(t)
+	n.FaultInjection = true
+	for _, body := range []string{
+		`{"delayMs": 100}`,
+		`{"peer": "*", "delayMs": -1}`,
+		`{"peer": "*", "delayMs": 50000, "jitterMs": 20000}`,
+		`{"peer": `,
+	} {
+		if rec := latencyRequest(n, http.MethodPost, "/admin/latency", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d, want 400", body, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/admin/latency", strings.NewReader("peer=p1:1&delayMs=40&jitterMs=5"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	n.handleLatencyRequest(rec, req)
+	if rule, ok := n.Client.Latency("p1:1"); rec.Code != http.StatusOK || !ok || rule.DelayMs != 40 || rule.JitterMs != 5 {
+		t.Errorf("form rule: %d %+v", rec.Code, rule)
+	}
+	if d := n.Client.injectedDelay("p1:1"); d < 35*time.Millisecond || d > 45*time.Millisecond {
+		t.Errorf("injected delay = %s, want 40ms ±5ms", d)
+	}
+	if latencyRequest(n, http.MethodPost, "/admin/latency", `{"peer": "p1:1", "delayMs": 0}`); len(n.Client.LatencyRules()) != 0 {
+		t.Errorf("a zero rule left %+v", n.Client.LatencyRules())
+	}
+}
@@ -0,0 +1,232 @@
+package node
+
+// followersync.go — Follower pulls: how often they run, skipping pulls that
+// would change nothing, sync lag, and POST /admin/sync-now.
+//
+// Besides the coordinator's pushes, every follower pulls a snapshot every
+// syncIntervalMs (runtime config, 2000 by default). A display board can run
+// with --sync-interval 500ms, an archival observer with 10s;
+// POST /admin/config sets it for the whole cluster.
+//
+// Snapshots carry StateVersion, the coordinator's queue mutation counter
+// when the snapshot was built, and heartbeats carry the leader's counter at
+// the time. The counter belongs to the leader's process, so a version only
+// means something together with its term. A ready follower skips a
+// scheduled pull when the last heartbeat of the current term reports a
+// version it already holds, so short intervals cost a full snapshot only
+// when something changed. How far behind it is shows as sync_lag_versions.
+//
+// A heartbeat that reports a version the follower does not hold yet starts
+// a pull at once instead of waiting for the schedule; that covers a lost
+// push within one heartbeat. Pushes and pulls can overtake each other, so a
+// coordinator snapshot older than the one held, in the same term, is
+// ignored rather than applied over newer state.
+//
+// POST /admin/sync-now pulls at once, whatever the last heartbeat said, and
+// reports the version applied and whether it differs from the one held.
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultSyncInterval = 2 * time.Second
+	minSyncIntervalMs   = 100
+	maxSyncIntervalMs   = 60_000
+)
+
+// syncPosition is a coordinator state version and the term it was seen in.
+type syncPosition struct {
+	Term    int
+	Version uint64
+}
+
+type followerSync struct {
+	mu      sync.Mutex
+	held    syncPosition // last coordinator snapshot applied here
+	leader  syncPosition // from the latest leader heartbeat
+	pulling atomic.Bool  // a heartbeat-triggered pull is running
+}
+
+// lagLocked is how many versions the held state is behind the leader's last
+// heartbeat. After a leader change every version of the new leader counts.
+func (s *followerSync) lagLocked() uint64 {
+	switch {
+	case s.leader.Version == 0:
+		return 0 // leader from an older build
+	case s.held.Term != s.leader.Term:
+		return s.leader.Version
+	case s.leader.Version > s.held.Version:
+		return s.leader.Version - s.held.Version
+	}
+	return 0
+}
+
+// noteLeaderVersion records the state version from a leader heartbeat and
+// returns how far behind it the held state is.
+func (n *Node) noteLeaderVersion(term int, version uint64) uint64 {
+	if version == 0 {
+		return 0
+	}
+	s := &n.followerSync
+	s.mu.Lock()
+	s.leader = syncPosition{Term: term, Version: version}
+	lag := s.lagLocked()
+	s.mu.Unlock()
+	n.Metrics.Set("sync_lag_versions", float64(lag))
+	return lag
+}
+
+// olderThanHeld reports whether snap is a coordinator snapshot older than
+// the one last applied in the same term. Called under Queue.mu, which also
+// serializes noteHeldVersion, so the check and the apply are atomic.
+func (n *Node) olderThanHeld(snap QueueSnapshot) (syncPosition, bool) {
+	if !snap.IsCoordinator || snap.StateVersion == 0 {
+		return syncPosition{}, false
+	}
+	s := &n.followerSync
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.held, s.held.Term == snap.Term && snap.StateVersion < s.held.Version
+}
+
+// fastSync pulls from the coordinator at once, after a heartbeat reported
+// a version this node does not hold. One such pull runs at a time.
+func (n *Node) fastSync() {
+	s := &n.followerSync
+	if !s.pulling.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer s.pulling.Store(false)
+		coordinatorAddress, isLocal := n.getCoordinatorAddress()
+		if isLocal || coordinatorAddress == "" || n.rebuildRunning() {
+			return
+		}
+		if _, _, err := n.pullFromCoordinator(coordinatorAddress); err != nil {
+			log.Printf("[%s] Heartbeat-triggered sync from %s failed: %v\n", n.ID, coordinatorAddress, err)
+			n.Metrics.Inc(metricName("state_sync_pulls_total", "result", "failed"))
+			return
+		}
+		n.Metrics.Inc(metricName("state_sync_pulls_total", "result", "heartbeat"))
+	}()
+}
+
+// noteHeldVersion records that a coordinator snapshot was applied.
+func (n *Node) noteHeldVersion(snap QueueSnapshot) {
+	s := &n.followerSync
+	s.mu.Lock()
+	s.held = syncPosition{Term: snap.Term, Version: snap.StateVersion}
+	lag := s.lagLocked()
+	s.mu.Unlock()
+	n.Metrics.Set("sync_lag_versions", float64(lag))
+}
+
+// syncUpToDate reports whether the last heartbeat of the current term
+// reports a version this node already holds.
+func (n *Node) syncUpToDate() bool {
+	term := n.currentTerm()
+	s := &n.followerSync
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader.Version != 0 && s.leader.Term == term &&
+		s.held.Term == term && s.held.Version >= s.leader.Version
+}
+
+func (n *Node) syncInterval() time.Duration {
+	return time.Duration(n.runtimeConfig().SyncIntervalMs) * time.Millisecond
+}
+
+// pullFromCoordinator fetches, checks and applies the coordinator's
+// snapshot. It returns the snapshot and the position held before it.
+func (n *Node) pullFromCoordinator(coordinatorAddress string) (QueueSnapshot, syncPosition, error) {
+	snap, err := n.fetchQueueSnapshot(coordinatorAddress)
+	if err != nil {
+		return snap, syncPosition{}, err
+	}
+	if err := n.admitSnapshot(snap, coordinatorAddress); err != nil {
+		return snap, syncPosition{}, err
+	}
+	n.followerSync.mu.Lock()
+	prev := n.followerSync.held
+	n.followerSync.mu.Unlock()
+	if !n.applyQueueSnapshot(snap, coordinatorAddress) {
+		return snap, prev, errors.New("snapshot rejected: fails state invariants")
+	}
+	n.markSynced("synced from coordinator " + coordinatorAddress)
+	return snap, prev, nil
+}
+
+// periodicStateSync pulls state from the coordinator every syncIntervalMs
+// (follower only) until the node is stopped. A changed interval applies
+// from the next wait.
+func (n *Node) periodicStateSync() {
+	for {
+		time.Sleep(n.syncInterval())
+		if n.Phase() == PhaseStopped {
+			return
+		}
+		coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
+		if isLocalCoordinator || coordinatorAddress == "" || n.rebuildRunning() {
+			continue
+		}
+		if n.IsReady() && n.syncUpToDate() {
+			n.Metrics.Inc(metricName("state_sync_pulls_total", "result", "skipped"))
+			continue
+		}
+		if _, _, err := n.pullFromCoordinator(coordinatorAddress); err != nil {
+			n.Metrics.Inc(metricName("state_sync_pulls_total", "result", "failed"))
+			continue
+		}
+		n.Metrics.Inc(metricName("state_sync_pulls_total", "result", "pulled"))
+	}
+}
+
+// SyncNowReply is the body of POST /admin/sync-now.
+type SyncNowReply struct {
+	Coordinator     string `json:"coordinator"`
+	Term            int    `json:"term"`
+	StateVersion    uint64 `json:"stateVersion"`
+	PreviousVersion uint64 `json:"previousVersion"`
+	Changed         bool   `json:"changed"`
+}
+
+// handleSyncNowRequest serves POST /admin/sync-now: an immediate pull from
+// the coordinator on this node.
+func (n *Node) handleSyncNowRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	coordinatorAddress, isLocal := n.getCoordinatorAddress()
+	switch {
+	case isLocal:
+		http.Error(w, "This node is the coordinator; its state is the source", http.StatusConflict)
+		return
+	case coordinatorAddress == "":
+		http.Error(w, "Election in progress, please wait", http.StatusServiceUnavailable)
+		return
+	case n.rebuildRunning():
+		http.Error(w, "A rebuild is running on this node", http.StatusConflict)
+		return
+	}
+	snap, prev, err := n.pullFromCoordinator(coordinatorAddress)
+	if err != nil {
+		n.Metrics.Inc(metricName("state_sync_pulls_total", "result", "failed"))
+		http.Error(w, "Sync failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	n.Metrics.Inc(metricName("state_sync_pulls_total", "result", "on_demand"))
+	writeJSON(w, SyncNowReply{
+		Coordinator:     coordinatorAddress,
+		Term:            snap.Term,
+		StateVersion:    snap.StateVersion,
+		PreviousVersion: prev.Version,
+		Changed:         prev != syncPosition{Term: snap.Term, Version: snap.StateVersion},
+	})
+}
@@ -0,0 +1,196 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func syncNow(n *Node, method string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	n.handleSyncNowRequest(rec, httptest.NewRequest(method, "/admin/sync-now", nil))
+	return rec
+}
+
+// bumpVersion changes the coordinator's state without pushing it.
+func bumpVersion(n *Node) uint64 {
+	n.Queue.mu.Lock()
+	n.Queue.CurrentHighestBid++
+	n.Queue.touchLocked()
+	n.Queue.mu.Unlock()
+	return n.Queue.Version()
+}
+
+func TestSyncNow(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	version := bumpVersion(a.Node)
+
+	// B is one version behind, as the last heartbeat says.
+	if lag := b.noteLeaderVersion(a.currentTerm(), version); lag != version || gauge(b.Metrics, "sync_lag_versions") != float64(version) {
+		t.Errorf("lag before syncing = %d, gauge %v; want %d", lag, gauge(b.Metrics, "sync_lag_versions"), version)
+	}
+	rec := syncNow(b.Node, http.MethodPost)
+	var reply SyncNowReply
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("POST /admin/sync-now: %d %s", rec.Code, rec.Body)
+	}
+	want := SyncNowReply{Coordinator: a.Address, Term: a.currentTerm(), StateVersion: version, Changed: true}
+	if reply != want {
+		t.Errorf("sync-now = %+v, want %+v", reply, want)
+	}
+	if highestBid(b.Node) != 11 || !b.syncUpToDate() || gauge(b.Metrics, "sync_lag_versions") != 0 {
+		t.Errorf("after sync-now: highest bid %d, up to date %v, lag %v", highestBid(b.Node), b.syncUpToDate(), gauge(b.Metrics, "sync_lag_versions"))
+	}
+
+	// Again with nothing new: the same version, unchanged.
+	json.Unmarshal(syncNow(b.Node, http.MethodPost).Body.Bytes(), &reply)
+	if reply.Changed || reply.PreviousVersion != version || reply.StateVersion != version {
+		t.Errorf("second sync-now = %+v, want version %d unchanged", reply, version)
+	}
+	if got := b.Metrics.Counter(metricName("state_sync_pulls_total", "result", "on_demand")); got != 2 {
+		t.Errorf("state_sync_pulls_total{result=on_demand} = %v, want 2", got)
+	}
+
+	if rec := syncNow(a.Node, http.MethodPost); rec.Code != http.StatusConflict {
+		t.Errorf("sync-now on the coordinator: %d, want 409", rec.Code)
+	}
+	if rec := syncNow(b.Node, http.MethodGet); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /admin/sync-now: %d, want 405", rec.Code)
+	}
+	if rec := syncNow(biddingNode(t), http.MethodPost); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("sync-now with no leader: %d, want 503", rec.Code)
+	}
+	setLeader(b.Node, "X", closedAddr(t))
+	if rec := syncNow(b.Node, http.MethodPost); rec.Code != http.StatusBadGateway {
+		t.Errorf("sync-now with the leader down: %d, want 502", rec.Code)
+	}
+	if got := b.Metrics.Counter(metricName("state_sync_pulls_total", "result", "failed")); got != 1 {
+		t.Errorf("state_sync_pulls_total{result=failed} = %v, want 1", got)
+	}
+}
+
+func TestSyncIntervalOverride(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	if got := b.syncInterval(); got != DefaultSyncInterval {
+		t.Errorf("default interval %s, want %s", got, DefaultSyncInterval)
+	}
+	for _, bad := range []string{`{"syncIntervalMs": 50}`, `{"syncIntervalMs": 120000}`} {
+		if rec := postConfig(a.Node, bad); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d, want 400", bad, rec.Code)
+		}
+	}
+	if rec := postConfig(a.Node, `{"syncIntervalMs": 100}`); rec.Code != http.StatusOK {
+		t.Fatalf("set syncIntervalMs: %d %s", rec.Code, rec.Body)
+	}
+	waitFor(t, "B to take the new interval", func() bool { return b.syncInterval() == 100*time.Millisecond })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.periodicStateSync()
+	}()
+	t.Cleanup(func() {
+		b.setPhase(PhaseStopped, "test over")
+		<-done
+	})
+
+	// B pulls once, then skips every 100ms while heartbeats report
+	// nothing new.
+	skipped := func() float64 { return b.Metrics.Counter(metricName("state_sync_pulls_total", "result", "skipped")) }
+	version := a.Queue.Version()
+	b.noteLeaderVersion(a.currentTerm(), version)
+	waitFor(t, "B to skip three pulls", func() bool { return skipped() >= 3 })
+	if got := b.Metrics.Counter(metricName("state_sync_pulls_total", "result", "pulled")); got != 1 {
+		t.Errorf("state_sync_pulls_total{result=pulled} = %v, want 1", got)
+	}
+
+	// A change the push missed is picked up by the next scheduled pull.
+	version = bumpVersion(a.Node)
+	b.noteLeaderVersion(a.currentTerm(), version)
+	waitFor(t, "B to pull the change", func() bool { return highestBid(b.Node) == 11 })
+	if got := b.Metrics.Counter(metricName("state_sync_pulls_total", "result", "pulled")); got != 2 {
+		t.Errorf("state_sync_pulls_total{result=pulled} = %v, want 2", got)
+	}
+}
+
+func TestOlderCoordinatorSnapshotIgnored(t *testing.T) {
+	n := biddingNode(t)
+	push := func(term int, version uint64, highest int, fromCoordinator bool) {
+		t.Helper()
+		snap := n.buildQueueSnapshot()
+		snap.Term, snap.StateVersion, snap.CurrentHighestBid, snap.IsCoordinator = term, version, highest, fromCoordinator
+		if !n.applyQueueSnapshot(snap, "test") {
+			t.Fatalf("snapshot term %d version %d refused", term, version)
+		}
+	}
+	ignored := func() float64 { return n.Metrics.Counter("snapshots_ignored_older_total") }
+
+	push(2, 5, 50, true)
+	push(2, 4, 40, true) // overtaken by version 5
+	if highestBid(n) != 50 || ignored() != 1 {
+		t.Errorf("after an older push: highest bid %d, %v ignored; want 50 and 1", highestBid(n), ignored())
+	}
+	push(2, 5, 55, true) // the same version again is not older
+	push(3, 2, 30, true) // a new leader counts from its own start
+	if highestBid(n) != 30 || ignored() != 1 {
+		t.Errorf("after a newer term: highest bid %d, %v ignored; want 30 and 1", highestBid(n), ignored())
+	}
+	push(3, 1, 20, false) // not from a coordinator: no version to compare
+	if highestBid(n) != 20 {
+		t.Errorf("non-coordinator snapshot not applied: highest bid %d", highestBid(n))
+	}
+
+	// Every mutation moves the version on.
+	before := n.Queue.Version()
+	commitBid(t, &NodeRPC{node: n}, "T1-1", BidArgs{BidderID: "b1", DisplayName: "b1", Amount: 60, ItemID: "lot1"})
+	if n.Queue.Version() <= before {
+		t.Errorf("version %d after a commit, was %d", n.Queue.Version(), before)
+	}
+}
+
+func TestHeartbeatTriggersFastSync(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, b.ID, b.Address)
+	}
+	leading(t, b.Node)
+	heartbeat := func() {
+		t.Helper()
+		var ok bool
+		msg := BullyMessage{NodeID: b.ID, Rank: b.Rank, Term: b.currentTerm(), Address: b.Address, StateVersion: b.Queue.Version()}
+		if err := (&NodeRPC{node: a.Node}).HandleHeartbeat(msg, &ok); err != nil || !ok {
+			t.Fatalf("heartbeat: %v %v", ok, err)
+		}
+	}
+	pulls := func() float64 { return a.Metrics.Counter(metricName("state_sync_pulls_total", "result", "heartbeat")) }
+
+	// B changes state and its push is lost; the next heartbeat gives it away.
+	bumpVersion(b.Node)
+	heartbeat()
+	waitFor(t, "A to pull the change", func() bool { return highestBid(a.Node) == 11 && !a.followerSync.pulling.Load() })
+	if pulls() != 1 || !a.syncUpToDate() {
+		t.Errorf("%v heartbeat pulls, up to date %v; want one pull", pulls(), a.syncUpToDate())
+	}
+
+	// A heartbeat reporting what A holds starts no pull.
+	heartbeat()
+	if pulls() != 1 || a.followerSync.pulling.Load() {
+		t.Errorf("%v heartbeat pulls after an up-to-date heartbeat, want 1", pulls())
+	}
+}
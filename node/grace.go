@@ -0,0 +1,57 @@
+package node
+
+// grace.go — What the new coordinator does with a lot whose deadline passed
+// while the cluster had no leader.
+//
+// Bids only commit through the coordinator, so between a leader's crash and
+// the next election win nobody can bid, and followers turn bidders away
+// with "election in progress". If the deadline falls inside that window,
+// the lot would otherwise close the moment the new leader looks at it, and
+// the bidders who were cut off never get their turn.
+//
+// By default (--failover-grace 10s) the new coordinator re-opens such a lot
+// for the grace period instead: it moves DeadlineUnix to now+grace, records
+// the original deadline in GraceFromUnix, and pushes the change with the
+// next snapshot, so every node and the UI show the re-opened lot. Bidding
+// then works as usual, anti-snipe included, and the timer closes the lot at
+// the new deadline. A lot gets at most one grace: a second failover that
+// misses the grace deadline closes it right away.
+//
+// With --no-grace the lot closes on takeover with the bids committed before
+// its original deadline (the coordinator refuses later ones), and the result
+// records that deadline as its close time, not the takeover time.
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const DefaultFailoverGrace = 10 * time.Second
+
+// applyFailoverGraceLocked is called on takeover for a lot whose deadline
+// has passed. It re-opens the lot when the policy allows and returns the
+// deadline the item timer should run to. Must hold Queue.mu.
+func (n *Node) applyFailoverGraceLocked(now int64) (deadline int64, notice string) {
+	q := n.Queue
+	deadline = q.DeadlineUnix
+	name := q.CurrentItem.Name
+	switch {
+	case n.FailoverGrace <= 0:
+		n.Metrics.Inc(metricName("failover_grace_total", "result", "disabled"))
+		log.Printf("[%s] ⏳ %s expired during the failover; closing it at its original deadline (--no-grace)\n", n.ID, name)
+		return deadline, ""
+	case q.GraceFromUnix > 0:
+		n.Metrics.Inc(metricName("failover_grace_total", "result", "already_granted"))
+		log.Printf("[%s] ⏳ %s expired again during a failover; it already had its grace period, closing it\n", n.ID, name)
+		return deadline, ""
+	}
+	secs := int64((n.FailoverGrace + time.Second - 1) / time.Second)
+	q.GraceFromUnix = deadline
+	q.DeadlineUnix = now + secs
+	q.touchLocked()
+	n.Metrics.Inc(metricName("failover_grace_total", "result", "granted"))
+	log.Printf("[%s] ⏳ %s expired %ds ago during the failover; re-opening it for %ds\n", n.ID, name, now-deadline, secs)
+	return q.DeadlineUnix, fmt.Sprintf("%s closed during a leader failover; bidding re-opened for %ds (until %s)",
+		name, secs, time.Unix(q.DeadlineUnix, 0).Format(time.RFC3339))
+}
@@ -0,0 +1,146 @@
+package node
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+// failoverPastDeadline commits b1's $15 on lot1 through A, lets the lot's
+// deadline pass, kills A and has C take over with the given grace. It
+// returns B and C and the original deadline.
+func failoverPastDeadline(t *testing.T, grace time.Duration) (b, c *testNode, deadline int64) {
+	t.Helper()
+	nodes := testCluster(t, "A", "B", "C")
+	a := nodes[0]
+	b, c = nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		tn.Queue.mu.Lock()
+		tn.Queue.Queue = nil // lot1 is the last lot
+		tn.Queue.CurrentItem.Name, tn.Queue.CurrentItem.DurationSec = "Vase", 60
+		tn.Queue.mu.Unlock()
+		tn.FailoverGrace = grace
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	if r := a.submitBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 15, ItemID: "lot1"}); r.Code != BidCommitted {
+		t.Fatalf("b1's bid = %s", r.Code)
+	}
+	// The deadline passes, then A dies before its timer closes the lot.
+	deadline = time.Now().Add(-3 * time.Second).Unix()
+	a.Queue.mu.Lock()
+	a.Queue.DeadlineUnix = deadline
+	a.Queue.touchLocked()
+	a.Queue.mu.Unlock()
+	a.broadcastQueueState()
+	for _, tn := range []*testNode{b, c} {
+		waitFor(t, tn.ID+" to have A's state", func() bool {
+			tn.Queue.mu.Lock()
+			defer tn.Queue.mu.Unlock()
+			return tn.Queue.DeadlineUnix == deadline && tn.Queue.CurrentHighestBid == 15
+		})
+	}
+	a.kill()
+	c.runElection()
+	if !c.leaderKnown() {
+		t.Fatal("C did not take over")
+	}
+	leading(t, c.Node)
+
+	// A comes back empty as a follower, so C's decisions reach everyone.
+	a = a.restart(t)
+	setLeader(a.Node, c.ID, c.Address)
+	expireCooldown(c.Client, a.Address)
+	return b, c, deadline
+}
+
+func resultsOf(n *Node) []ItemResult {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	return append([]ItemResult(nil), n.Queue.Results...)
+}
+
+func TestFailoverGraceReopensLot(t *testing.T) {
+	b, c, deadline := failoverPastDeadline(t, time.Second)
+
+	// The lot is re-opened for the grace, on every node.
+	var graceDeadline int64
+	waitFor(t, "C to re-open lot1", func() bool {
+		c.Queue.mu.Lock()
+		defer c.Queue.mu.Unlock()
+		graceDeadline = c.Queue.DeadlineUnix
+		return c.Queue.GraceFromUnix == deadline
+	})
+	if now := time.Now().Unix(); graceDeadline < now || graceDeadline > now+1 {
+		t.Errorf("grace deadline %d, want now+1s (%d)", graceDeadline, now+1)
+	}
+	waitFor(t, "B to see the grace", func() bool {
+		b.Queue.mu.Lock()
+		defer b.Queue.mu.Unlock()
+		return b.Queue.GraceFromUnix == deadline && b.Queue.DeadlineUnix == graceDeadline
+	})
+	if !slices.Contains(delivered(c.Alerts), "failover_grace") {
+		t.Errorf("alerts %v lack failover_grace", delivered(c.Alerts))
+	}
+	if got := c.Metrics.Counter(metricName("failover_grace_total", "result", "granted")); got != 1 {
+		t.Errorf("failover_grace_total{result=granted} = %v, want 1", got)
+	}
+
+	// A bidder cut off by the failover gets their turn and wins. (Anti-snipe
+	// would extend the grace; it is off to keep the test short.)
+	if rec := postConfig(c.Node, `{"antiSnipeSec": 0}`); rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/config: %d %s", rec.Code, rec.Body)
+	}
+	if r := c.submitBid(context.Background(), BidArgs{BidderID: "b2", DisplayName: "Bob", Amount: 20, ItemID: "lot1"}); r.Code != BidCommitted {
+		t.Fatalf("bid in the grace = %s: %s", r.Code, r.Message)
+	}
+	time.Sleep(time.Until(time.Unix(graceDeadline, 0)))
+	for _, n := range []*Node{b.Node, c.Node} {
+		waitFor(t, n.ID+" to close lot1", func() bool { return len(resultsOf(n)) == 1 })
+		r := resultsOf(n)[0]
+		if r.WinnerID != "b2" || r.WinningBid != 20 || r.ClosedAtUnix != graceDeadline {
+			t.Errorf("%s: %s won at $%d, closed %d; want b2 at $20, closed at the grace deadline %d", n.ID, r.WinnerID, r.WinningBid, r.ClosedAtUnix, graceDeadline)
+		}
+	}
+}
+
+func TestNoGraceClosesAtOriginalDeadline(t *testing.T) {
+	b, c, deadline := failoverPastDeadline(t, 0)
+
+	for _, n := range []*Node{b.Node, c.Node} {
+		waitFor(t, n.ID+" to close lot1", func() bool { return len(resultsOf(n)) == 1 })
+		r := resultsOf(n)[0]
+		if r.WinnerID != "b1" || r.WinningBid != 15 || r.ClosedAtUnix != deadline {
+			t.Errorf("%s: %s won at $%d, closed %d; want b1 at $15, closed at %d", n.ID, r.WinnerID, r.WinningBid, r.ClosedAtUnix, deadline)
+		}
+	}
+	if r := c.submitBid(context.Background(), BidArgs{BidderID: "b2", DisplayName: "Bob", Amount: 20, ItemID: "lot1"}); r.Code == BidCommitted {
+		t.Error("a bid after the deadline committed with --no-grace")
+	}
+	if got := c.Metrics.Counter(metricName("failover_grace_total", "result", "disabled")); got != 1 {
+		t.Errorf("failover_grace_total{result=disabled} = %v, want 1", got)
+	}
+}
+
+func TestGraceGrantedOnce(t *testing.T) {
+	n := biddingNode(t)
+	n.FailoverGrace = 5 * time.Second
+	now := time.Now().Unix()
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	n.Queue.DeadlineUnix = now - 1
+	if d, notice := n.applyFailoverGraceLocked(now); d != now+5 || notice == "" || n.Queue.GraceFromUnix != now-1 {
+		t.Fatalf("first failover: deadline %d, notice %q", d, notice)
+	}
+	// A second failover misses the grace deadline too: no second grace.
+	n.Queue.DeadlineUnix = now + 5
+	if d, notice := n.applyFailoverGraceLocked(now + 7); d != now+5 || notice != "" {
+		t.Errorf("second failover: deadline %d, notice %q; want it closed", d, notice)
+	}
+	if got := n.Metrics.Counter(metricName("failover_grace_total", "result", "already_granted")); got != 1 {
+		t.Errorf("failover_grace_total{result=already_granted} = %v, want 1", got)
+	}
+}
@@ -3,73 +3,161 @@ package node
 // handlers.go — HTTP request handlers for /bid, /state, and /checkpoint endpoints.
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
+)
+
+// A forwarded bid is retried while there is no reachable leader, which is
+// usually an election that ends within a couple of seconds. Waits double
+// from forwardBackoff: 300ms, 600ms, 1.2s.
+const (
+	forwardAttempts = 4
+	forwardBackoff  = 300 * time.Millisecond
 )
 
 func (n *Node) handleBidRequest(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if phase := n.Phase(); phase != PhaseReady {
+		writeBidReply(w, rejectBid(BidNotReady, "Node is "+string(phase)+"; bids are accepted once it is ready"))
+		return
+	}
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Invalid form request", http.StatusBadRequest)
+		writeBidReply(w, rejectBid(BidInvalid, "Invalid form request"))
 		return
 	}
 
 	amountStr := r.FormValue("amount")
-	bidder := r.FormValue("bidder")
-	if bidder == "" {
-		bidder = n.ID
+	bid := BidArgs{
+		DisplayName:    strings.TrimSpace(r.FormValue("bidder")),
+		BidderID:       sessionBidderID(w, r),
+		Origin:         n.ID,
+		ItemID:         strings.TrimSpace(r.FormValue("itemId")),
+		IdempotencyKey: r.Header.Get("Idempotency-Key"),
+	}
+	if bid.DisplayName == "" {
+		bid.DisplayName = guestName(bid.BidderID)
 	}
 
 	var amount int
 	if _, err := fmt.Sscanf(amountStr, "%d", &amount); err != nil || amount <= 0 {
-		http.Error(w, "Invalid bid amount", http.StatusBadRequest)
+		writeBidReply(w, rejectBid(BidInvalid, "Invalid bid amount"))
 		return
 	}
+	bid.Amount = amount
+	bid = bid.withIdentity()
+	n.stampBidTiming(&bid, r.FormValue("clientTime"), received)
 
-	coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
-	if !isLocalCoordinator {
+	var reply CoordinatorBidReply
+	if !n.holdForClient(r, "bid", func() {
+		reply = n.submitBid(r.Context(), bid).withCode()
+		n.observeBidProcessing(bid)
+		if reply.Code != BidCommitted && reply.Code != BidUnknown {
+			// Commits are recorded by applyDecision, which is also where an
+			// unknown outcome shows up if the coordinator did commit it.
+			n.recordBid("", bid, reply.Code, reply.Message)
+		}
+	}) {
+		return // client gone; see holdForClient
+	}
+	setReceiptHeaders(w, bid.Origin, reply)
+	writeBidReply(w, reply)
+}
+
+// submitBid runs bid through 2PC, forwarding it to the coordinator when this
+// node is not the coordinator, and returns the coordinator's reply.
+//
+// While there is no leader to forward to (an election, a coordinator that
+// could not be dialled or whose breaker is open, or a peer that answers it
+// is no longer coordinator), the coordinator is looked up again and the
+// forward retried, up to forwardAttempts in all. Each of those retries is
+// safe because no coordinator received the bid. A forward that failed after
+// it was sent is not retried: the coordinator may have committed it.
+func (n *Node) submitBid(ctx context.Context, bid BidArgs) CoordinatorBidReply {
+	var reply CoordinatorBidReply
+	backoff := forwardBackoff
+	for attempt := 1; ; attempt++ {
+		coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
+		if isLocalCoordinator {
+			// This node is the coordinator — run 2PC directly
+			return n.ProposeBid(ctx, bid)
+		}
 		if coordinatorAddress == "" {
-			http.Error(w, "Election in progress, please wait", http.StatusServiceUnavailable)
-			return
+			reply = rejectBid(BidNoLeader, "Election in progress, please wait")
+		} else {
+			reply = n.forwardBid(ctx, coordinatorAddress, bid)
 		}
-		// Forward to coordinator
-		var reply CoordinatorBidReply
-		err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitBidToCoordinator",
-			BidArgs{Amount: amount, Bidder: bidder}, &reply)
-		if err != nil {
-			http.Error(w, "Leader unavailable; retry shortly", http.StatusServiceUnavailable)
-			return
+		if reply.Code != BidNoLeader {
+			return reply
 		}
-		if !reply.Accepted {
-			http.Error(w, reply.Message, http.StatusBadRequest)
-			return
+		if attempt == forwardAttempts {
+			reply.Message = fmt.Sprintf("%s (tried %d times)", reply.Message, attempt)
+			return reply
 		}
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(reply.Message))
-		return
+		select {
+		case <-ctx.Done():
+			return rejectBid(BidCancelled, cancelledMessage)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		n.Metrics.Inc("bid_forward_retries_total")
 	}
+}
 
-	// This node is the coordinator — run 2PC directly
-	accepted, message := n.ProposeBid(amount, bidder)
-	if !accepted {
-		http.Error(w, message, http.StatusBadRequest)
-		return
+// forwardBid sends bid to the coordinator once.
+func (n *Node) forwardBid(ctx context.Context, coordinatorAddress string, bid BidArgs) CoordinatorBidReply {
+	var reply CoordinatorBidReply
+	timer := n.startBidTimer()
+	n.bidForwards.Add(1)
+	err := n.callPeerContext(ctx, coordinatorAddress, "NodeRPC.SubmitBidToCoordinator",
+		bid, &reply)
+	n.bidForwards.Add(-1)
+	timer.lap(stageForward)
+	switch {
+	case ctx.Err() != nil:
+		return rejectBid(BidCancelled, cancelledMessage)
+	case err != nil && forwardUndelivered(err):
+		return rejectBid(BidNoLeader, "Leader unavailable; retry shortly")
+	case err != nil:
+		log.Printf("[%s] ⚠️ Forwarded bid from %s for $%d got no answer from %s: %v\n",
+			n.ID, bid.Bidder, bid.Amount, coordinatorAddress, err)
+		n.Metrics.Inc("bid_forward_unknown_total")
+		return rejectBid(BidUnknown, "Leader did not answer; the bid may have been placed, check your bids before retrying")
 	}
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte(message))
+	return reply.withCode()
+}
+
+// forwardUndelivered reports whether a forward failed before the
+// coordinator could have seen it: the dial failed or the breaker is open.
+func forwardUndelivered(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var open *BreakerOpenError
+	return errors.As(err, &open)
 }
 
 func (n *Node) handleStateRequest(w http.ResponseWriter, r *http.Request) {
-	snap := n.buildQueueSnapshot()
+	body, err := n.cachedStateJSON()
+	if err != nil {
+		http.Error(w, "Could not encode state", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(snap)
+	_, _ = w.Write(body)
 }
 
 func (n *Node) handleAddItemRequest(w http.ResponseWriter, r *http.Request) {
@@ -82,6 +170,11 @@ func (n *Node) handleAddItemRequest(w http.ResponseWriter, r *http.Request) {
 	description := ""
 	startingPrice := 0
 	durationSec := 0
+	emoji := ""
+	category := ""
+	var increments []IncrementBand
+	var allowedBidders []string
+	var custom map[string]string
 
 	if strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "application/json") {
 		body, err := io.ReadAll(r.Body)
@@ -90,10 +183,15 @@ func (n *Node) handleAddItemRequest(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		var req struct {
-			Name          string `json:"name"`
-			Description   string `json:"description"`
-			StartingPrice int    `json:"startingPrice"`
-			DurationSec   int    `json:"durationSec"`
+			Name           string            `json:"name"`
+			Description    string            `json:"description"`
+			StartingPrice  int               `json:"startingPrice"`
+			DurationSec    int               `json:"durationSec"`
+			Emoji          string            `json:"emoji"`
+			Category       string            `json:"category"`
+			Increments     []IncrementBand   `json:"increments"`
+			AllowedBidders []string          `json:"allowedBidders"`
+			Custom         map[string]string `json:"custom"`
 		}
 		if err := json.Unmarshal(body, &req); err != nil {
 			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
@@ -103,6 +201,11 @@ func (n *Node) handleAddItemRequest(w http.ResponseWriter, r *http.Request) {
 		description = req.Description
 		startingPrice = req.StartingPrice
 		durationSec = req.DurationSec
+		emoji = req.Emoji
+		category = req.Category
+		increments = req.Increments
+		allowedBidders = req.AllowedBidders
+		custom = req.Custom
 	} else {
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "Invalid form request", http.StatusBadRequest)
@@ -110,6 +213,10 @@ func (n *Node) handleAddItemRequest(w http.ResponseWriter, r *http.Request) {
 		}
 		name = r.FormValue("name")
 		description = r.FormValue("description")
+		emoji = r.FormValue("emoji")
+		category = r.FormValue("category")
+		allowedBidders = parseBidderList(r.FormValue("allowedBidders"))
+		custom = customFromForm(r.PostForm)
 		if _, err := fmt.Sscanf(r.FormValue("startingPrice"), "%d", &startingPrice); err != nil {
 			http.Error(w, "Invalid starting price", http.StatusBadRequest)
 			return
@@ -120,35 +227,54 @@ func (n *Node) handleAddItemRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	args := AddItemArgs{
+		Name:           name,
+		Description:    description,
+		StartingPrice:  startingPrice,
+		DurationSec:    durationSec,
+		Emoji:          emoji,
+		Category:       category,
+		Increments:     increments,
+		AllowedBidders: allowedBidders,
+		Custom:         custom,
+	}
+
+	var status int
+	var message string
+	if !n.holdForClient(r, "admin_item", func() { status, message = n.submitAddItem(args) }) {
+		return
+	}
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(message))
+}
+
+// submitAddItem adds an item on the coordinator, forwarding if needed.
+func (n *Node) submitAddItem(args AddItemArgs) (int, string) {
 	coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
 	if !isLocalCoordinator {
 		if coordinatorAddress == "" {
-			http.Error(w, "Election in progress, please wait", http.StatusServiceUnavailable)
-			return
+			return http.StatusServiceUnavailable, "Election in progress, please wait"
 		}
 		var reply CoordinatorActionReply
-		err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitAddItemToCoordinator",
-			AddItemArgs{Name: name, Description: description, StartingPrice: startingPrice, DurationSec: durationSec}, &reply)
+		err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitAddItemToCoordinator", args, &reply)
 		if err != nil {
-			http.Error(w, "Leader unavailable; retry shortly", http.StatusServiceUnavailable)
-			return
+			return http.StatusServiceUnavailable, "Leader unavailable; retry shortly"
 		}
 		if !reply.Accepted {
-			http.Error(w, reply.Message, http.StatusBadRequest)
-			return
+			return http.StatusBadRequest, reply.Message
 		}
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(reply.Message))
-		return
+		return http.StatusOK, reply.Message
 	}
 
-	accepted, message := n.addItemAndBroadcast(name, description, startingPrice, durationSec)
+	accepted, message := n.addItemAndBroadcast(args)
 	if !accepted {
-		http.Error(w, message, http.StatusBadRequest)
-		return
+		return http.StatusBadRequest, message
 	}
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte(message))
+	return http.StatusOK, message
 }
 
 func (n *Node) handleAuctionControlRequest(w http.ResponseWriter, r *http.Request) {
@@ -158,6 +284,8 @@ func (n *Node) handleAuctionControlRequest(w http.ResponseWriter, r *http.Reques
 	}
 
 	action := ""
+	dryRun := false
+	archive := false
 	if strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "application/json") {
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
@@ -165,19 +293,25 @@ func (n *Node) handleAuctionControlRequest(w http.ResponseWriter, r *http.Reques
 			return
 		}
 		var req struct {
-			Action string `json:"action"`
+			Action  string `json:"action"`
+			DryRun  bool   `json:"dryRun"`
+			Archive bool   `json:"archive"`
 		}
 		if err := json.Unmarshal(body, &req); err != nil {
 			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
 			return
 		}
 		action = req.Action
+		dryRun = req.DryRun
+		archive = req.Archive
 	} else {
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "Invalid form request", http.StatusBadRequest)
 			return
 		}
 		action = r.FormValue("action")
+		dryRun = r.FormValue("dryRun") == "true" || r.FormValue("dryRun") == "1"
+		archive = r.FormValue("archive") == "true" || r.FormValue("archive") == "1"
 	}
 
 	if action != "start" && action != "restart" && action != "stop" {
@@ -193,11 +327,15 @@ func (n *Node) handleAuctionControlRequest(w http.ResponseWriter, r *http.Reques
 		}
 		var reply CoordinatorActionReply
 		err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitAuctionControlToCoordinator",
-			AuctionControlArgs{Action: action}, &reply)
+			AuctionControlArgs{Action: action, DryRun: dryRun, Archive: archive}, &reply)
 		if err != nil {
 			http.Error(w, "Leader unavailable; retry shortly", http.StatusServiceUnavailable)
 			return
 		}
+		if dryRun && reply.Plan != nil {
+			writeJSON(w, reply.Plan)
+			return
+		}
 		if !reply.Accepted {
 			http.Error(w, reply.Message, http.StatusBadRequest)
 			return
@@ -207,6 +345,11 @@ func (n *Node) handleAuctionControlRequest(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if dryRun {
+		writeJSON(w, n.previewAuctionControl(action))
+		return
+	}
+
 	var accepted bool
 	var message string
 	if action == "start" {
@@ -214,7 +357,7 @@ func (n *Node) handleAuctionControlRequest(w http.ResponseWriter, r *http.Reques
 	} else if action == "stop" {
 		accepted, message = n.stopAuctionAndBroadcast()
 	} else {
-		accepted, message = n.restartAuctionAndBroadcast()
+		accepted, message = n.restartAuctionAndBroadcast(archive)
 	}
 
 	if !accepted {
@@ -236,6 +379,16 @@ func (n *Node) handleCheckpointRequest(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Could not read checkpoint", http.StatusInternalServerError)
 		return
 	}
+	cp, _, err := MigrateCheckpoint(b)
+	if err != nil {
+		http.Error(w, "Could not read checkpoint", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, publicCheckpoint(*cp)) // allow-lists are not public
+}
+
+// writeJSON encodes v as a JSON response body.
+func writeJSON(w http.ResponseWriter, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
-	_, _ = w.Write(b)
+	_ = json.NewEncoder(w).Encode(v)
 }
@@ -0,0 +1,179 @@
+package node
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeCoordinator commits every bid forwarded to it.
+type fakeCoordinator struct{ calls atomic.Int32 }
+
+func (f *fakeCoordinator) SubmitBidToCoordinator(args BidArgs, reply *CoordinatorBidReply) error {
+	f.calls.Add(1)
+	*reply = CoordinatorBidReply{Accepted: true, Code: BidCommitted, Message: "Bid placed"}
+	return nil
+}
+
+func serveFakeCoordinator(t *testing.T) (string, *fakeCoordinator) {
+	t.Helper()
+	f := &fakeCoordinator{}
+	return serveAsNodeRPC(t, f), f
+}
+
+// serveAsNodeRPC serves rcvr's methods as NodeRPC on a loopback address.
+func serveAsNodeRPC(t *testing.T, rcvr interface{}) string {
+	t.Helper()
+	s := rpc.NewServer()
+	if err := s.RegisterName("NodeRPC", rcvr); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, s)
+	go http.Serve(l, mux)
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().String()
+}
+
+// closedAddr returns an address nothing listens on.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+// followerOf returns a node that takes id at addr for its leader.
+func followerOf(t *testing.T, id, addr string) *Node {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	n := NewNode("F1", "127.0.0.1:9", nil, 1)
+	t.Cleanup(n.Client.Close)
+	setLeader(n, id, addr)
+	return n
+}
+
+func setLeader(n *Node, id, addr string) {
+	n.ElectionMutex.Lock()
+	n.setCoordinatorLocked(id)
+	if n.bullyAddrs == nil {
+		n.bullyAddrs = map[string]string{}
+	}
+	n.bullyAddrs[id] = addr
+	n.ElectionMutex.Unlock()
+	n.lastLeaderContact.Store(time.Now().UnixNano())
+}
+
+func TestSubmitBidRetriesAfterLeaderChange(t *testing.T) {
+	dead := closedAddr(t)
+	n := followerOf(t, "C1", dead)
+	live, coord := serveFakeCoordinator(t)
+
+	// Once the forward to the dead leader has failed, a new one is elected.
+	go func() {
+		for {
+			n.Client.mu.Lock()
+			b := n.Client.breakers[dead]
+			failed := b != nil && b.failures > 0
+			n.Client.mu.Unlock()
+			if failed {
+				setLeader(n, "C2", live)
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	reply := n.submitBid(context.Background(), BidArgs{BidderID: "b1", Amount: 50, ItemID: "lot1"})
+	if reply.Code != BidCommitted {
+		t.Fatalf("reply = %s %q, want committed", reply.Code, reply.Message)
+	}
+	if got := coord.calls.Load(); got != 1 {
+		t.Errorf("new leader got %d forwards, want 1", got)
+	}
+	if got := n.Metrics.Counter("bid_forward_retries_total"); got != 1 {
+		t.Errorf("bid_forward_retries_total = %v, want 1", got)
+	}
+}
+
+// serveDroppingRPC accepts the RPC handshake, reads the first call and
+// closes the connection without answering it.
+func serveDroppingRPC(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	var calls atomic.Int32
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			if _, err := http.ReadRequest(r); err == nil {
+				_, _ = io.WriteString(conn, "HTTP/1.0 200 Connected to Go RPC\n\n")
+				if _, err := r.ReadByte(); err == nil {
+					calls.Add(1)
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return l.Addr().String(), &calls
+}
+
+func TestSubmitBidDoesNotRetrySentForward(t *testing.T) {
+	addr, calls := serveDroppingRPC(t)
+	n := followerOf(t, "C1", addr)
+
+	reply := n.submitBid(context.Background(), BidArgs{BidderID: "b1", Amount: 50, ItemID: "lot1"})
+	if reply.Code != BidUnknown {
+		t.Fatalf("reply = %s %q, want unknown", reply.Code, reply.Message)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("coordinator got %d forwards, want 1", got)
+	}
+	if got := n.Metrics.Counter("bid_forward_retries_total"); got != 0 {
+		t.Errorf("bid_forward_retries_total = %v, want 0", got)
+	}
+	if got := n.Metrics.Counter("bid_forward_unknown_total"); got != 1 {
+		t.Errorf("bid_forward_unknown_total = %v, want 1", got)
+	}
+}
+
+func TestForwardUndelivered(t *testing.T) {
+	_, dialErr := net.Dial("tcp", closedAddr(t))
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"dial", dialErr, true},
+		{"breaker", &BreakerOpenError{Peer: "x"}, true},
+		{"timeout", context.DeadlineExceeded, false},
+		{"eof", io.ErrUnexpectedEOF, false},
+		{"shutdown", rpc.ErrShutdown, false},
+	}
+	for _, c := range cases {
+		if got := forwardUndelivered(c.err); got != c.want {
+			t.Errorf("%s: forwardUndelivered(%v) = %v, want %v", c.name, c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package node
+
+// historyui.go — Serves /history, a read-only page for browsing past events.
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+)
+
+func (n *Node) handleHistoryUI(w http.ResponseWriter, r *http.Request) {
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <title>Past events — %s</title>
+  <style>
+    :root { --bg: #000; --surface: rgba(28, 28, 30, 0.6); --border: rgba(255, 255, 255, 0.1); --text: #fff; --muted: #8e8e93; --green: #34c759; }
+    * { margin: 0; padding: 0; box-sizing: border-box; }
+    body { font-family: -apple-system, BlinkMacSystemFont, 'Inter', sans-serif; background: var(--bg); color: var(--text); padding: 48px 24px; line-height: 1.5; }
+    main { max-width: 1000px; margin: 0 auto; display: grid; grid-template-columns: 280px 1fr; gap: 24px; }
+    header { max-width: 1000px; margin: 0 auto 32px; display: flex; justify-content: space-between; align-items: baseline; border-bottom: 0.5px solid var(--border); padding-bottom: 16px; }
+    a { color: var(--muted); }
+    .panel { background: var(--surface); border: 0.5px solid var(--border); border-radius: 16px; padding: 20px; }
+    .event { display: block; width: 100%%; text-align: left; background: none; border: 0; color: var(--text); padding: 10px 8px; border-radius: 8px; cursor: pointer; font: inherit; }
+    .event:hover, .event.selected { background: rgba(255, 255, 255, 0.08); }
+    .event small, .muted { color: var(--muted); font-size: 0.85rem; }
+    .totals { display: flex; gap: 24px; margin: 12px 0 20px; }
+    .totals b { display: block; font-size: 1.4rem; }
+    table { width: 100%%; border-collapse: collapse; margin-bottom: 20px; font-size: 0.9rem; }
+    th, td { text-align: left; padding: 6px 4px; border-bottom: 0.5px solid var(--border); }
+    th { color: var(--muted); font-weight: 500; }
+    td.num { text-align: right; }
+    h2 { font-size: 1.2rem; }
+    h3 { font-size: 0.95rem; color: var(--muted); font-weight: 500; margin-bottom: 6px; }
+  </style>
+</head>
+<body>
+  <header><h1>Past events</h1><a href="/">← Live auction (%s)</a></header>
+  <main>
+    <div class="panel" id="eventList"><span class="muted">Loading…</span></div>
+    <div class="panel" id="eventDetail"><span class="muted">Pick an event.</span></div>
+  </main>
+<script>
+  function el(tag, text, cls) {
+    const e = document.createElement(tag);
+    if (text !== undefined) e.textContent = text;
+    if (cls) e.className = cls;
+    return e;
+  }
+  function when(unix) { return new Date(unix * 1000).toLocaleString(); }
+  function table(head, rows) {
+    const t = el('table'), tr = el('tr');
+    head.forEach(function(h) { tr.append(el('th', h)); });
+    t.append(tr);
+    rows.forEach(function(r) {
+      const row = el('tr');
+      r.forEach(function(c) { row.append(el('td', c, typeof c === 'number' || /^\$/.test(c) ? 'num' : '')); });
+      t.append(row);
+    });
+    return t;
+  }
+  async function showEvent(id, button) {
+    document.querySelectorAll('.event').forEach(function(b) { b.classList.toggle('selected', b === button); });
+    const box = document.getElementById('eventDetail');
+    try {
+      const res = await fetch('/events/history/' + encodeURIComponent(id));
+      if (!res.ok) throw new Error(await res.text());
+      const ev = await res.json();
+      const totals = el('div', undefined, 'totals');
+      [['Lots', ev.totals.lots], ['Sold', ev.totals.sold], ['Revenue', '$' + ev.totals.revenue], ['Winners', ev.totals.winners]].forEach(function(t) {
+        const d = el('div', t[0], 'muted');
+        d.prepend(el('b', String(t[1])));
+        totals.append(d);
+      });
+      const download = el('a', 'Export JSON');
+      download.href = '/events/history/' + encodeURIComponent(ev.id) + '?download=1';
+      box.replaceChildren(el('h2', ev.name),
+        el('div', when(ev.startedAtUnix) + ' – ' + when(ev.closedAtUnix) + ' · ' + ev.reason + ' · ' + ev.id, 'muted'),
+        totals,
+        el('h3', 'Winners'),
+        table(['Winner', 'Lots', 'Total'], (ev.settlement || []).map(function(w) { return [w.winner, w.lots, '$' + w.total]; })),
+        el('h3', 'Lots'),
+        table(['Item', 'Winner', 'Price'], (ev.results || []).map(function(r) {
+          return [r.Item.Name, r.Winner, r.WinningBid > 0 ? '$' + r.WinningBid : '—'];
+        })),
+        download);
+    } catch (e) { box.replaceChildren(el('span', 'Could not load event: ' + e.message, 'muted')); }
+  }
+  async function loadEvents() {
+    const list = document.getElementById('eventList');
+    try {
+      const events = await (await fetch('/events/history')).json();
+      list.replaceChildren();
+      if (!events.length) list.append(el('span', 'No past events yet. One is saved when an auction runs out of lots.', 'muted'));
+      events.forEach(function(ev, i) {
+        const b = el('button', ev.name, 'event');
+        b.append(el('br'), el('small', when(ev.closedAtUnix) + ' · ' + ev.totals.sold + '/' + ev.totals.lots + ' sold · $' + ev.totals.revenue));
+        b.onclick = function() { showEvent(ev.id, b); };
+        list.append(b);
+        if (i === 0) showEvent(ev.id, b);
+      });
+    } catch (e) { list.replaceChildren(el('span', 'Unavailable', 'muted')); }
+  }
+  loadEvents();
+</script>
+</body>
+</html>`, html.EscapeString(n.ID), html.EscapeString(n.ID))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(page))
+}
@@ -0,0 +1,183 @@
+package node
+
+// httpgate.go — Admission control that keeps inter-node RPC responsive while
+// the public HTTP surface is overloaded.
+//
+// RPC (net/rpc over HTTP CONNECT) shares the listener with the UI and API.
+// Browser traffic is capped at maxConcurrentHTTP handlers with a bounded
+// wait queue; anything beyond that is shed with 503. RPC requests bypass the
+// gate entirely so heartbeats and 2PC messages are never queued behind polls.
+//
+// Handlers that wait on the coordinator (bids, queue edits) hold their slot
+// through holdForClient, which gives the slot back as soon as the browser
+// disconnects instead of when the coordinator finally answers.
+
+import (
+	"net/http"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	maxConcurrentHTTP = 64
+	maxQueuedHTTP     = 256
+	httpQueueWait     = 2 * time.Second
+)
+
+type httpGate struct {
+	slots    chan struct{}
+	inflight atomic.Int64
+	queued   atomic.Int64
+	rpcConns atomic.Int64
+
+	heldMu sync.Mutex
+	held   map[string]*atomic.Int64 // per endpoint; see holdForClient
+	holds  map[*heldRequest]struct{}
+}
+
+type heldRequest struct {
+	endpoint string
+	since    time.Time
+}
+
+func newHTTPGate() *httpGate {
+	return &httpGate{
+		slots: make(chan struct{}, maxConcurrentHTTP),
+		held:  map[string]*atomic.Int64{},
+		holds: map[*heldRequest]struct{}{},
+	}
+}
+
+func (g *httpGate) beginHold(endpoint string) *heldRequest {
+	h := &heldRequest{endpoint: endpoint, since: time.Now()}
+	g.heldMu.Lock()
+	g.holds[h] = struct{}{}
+	g.heldMu.Unlock()
+	return h
+}
+
+func (g *httpGate) endHold(h *heldRequest) {
+	g.heldMu.Lock()
+	delete(g.holds, h)
+	g.heldMu.Unlock()
+}
+
+// status reports the gate's queues for /admin/inflight.
+func (g *httpGate) status() IntakeStatus {
+	s := IntakeStatus{HTTPInFlight: g.inflight.Load(), HTTPQueued: g.queued.Load(), Held: map[string]int64{}}
+	g.heldMu.Lock()
+	defer g.heldMu.Unlock()
+	for endpoint, c := range g.held {
+		if v := c.Load(); v > 0 {
+			s.Held[endpoint] = v
+		}
+	}
+	var oldest *heldRequest
+	for h := range g.holds {
+		if oldest == nil || h.since.Before(oldest.since) {
+			oldest = h
+		}
+	}
+	if oldest != nil {
+		s.OldestHeldEndpoint, s.OldestHeldMs = oldest.endpoint, time.Since(oldest.since).Milliseconds()
+	}
+	return s
+}
+
+func (g *httpGate) heldCounter(endpoint string) *atomic.Int64 {
+	g.heldMu.Lock()
+	defer g.heldMu.Unlock()
+	c, ok := g.held[endpoint]
+	if !ok {
+		c = &atomic.Int64{}
+		g.held[endpoint] = c
+	}
+	return c
+}
+
+// gatedHandler routes RPC straight through and admits HTTP via the gate.
+func (n *Node) gatedHandler(next http.Handler) http.Handler {
+	g := n.httpGate
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == rpc.DefaultRPCPath || r.URL.Path == authRPCPath {
+			n.Metrics.Set("rpc_connections_active", float64(g.rpcConns.Add(1)))
+			defer func() { n.Metrics.Set("rpc_connections_active", float64(g.rpcConns.Add(-1))) }()
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case g.slots <- struct{}{}:
+		default:
+			if g.queued.Load() >= maxQueuedHTTP {
+				n.shedHTTP(w)
+				return
+			}
+			n.Metrics.Set("http_queued_requests", float64(g.queued.Add(1)))
+			timer := time.NewTimer(httpQueueWait)
+			admitted := false
+			select {
+			case g.slots <- struct{}{}:
+				admitted = true
+			case <-timer.C:
+			case <-r.Context().Done():
+			}
+			timer.Stop()
+			n.Metrics.Set("http_queued_requests", float64(g.queued.Add(-1)))
+			if !admitted {
+				n.shedHTTP(w)
+				return
+			}
+		}
+
+		n.Metrics.Set("http_inflight_requests", float64(g.inflight.Add(1)))
+		defer func() {
+			n.Metrics.Set("http_inflight_requests", float64(g.inflight.Add(-1)))
+			<-g.slots
+		}()
+		n.Metrics.Inc("http_requests_total")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// holdForClient runs work, which may block for seconds on the coordinator,
+// and returns when it finishes or when the client disconnects, whichever is
+// first. It reports whether the client is still there for the result. Work
+// that watches r.Context(), like a bid still collecting votes, stops early;
+// anything else completes in the background and only the HTTP slot is
+// released early.
+func (n *Node) holdForClient(r *http.Request, endpoint string, work func()) bool {
+	held := n.httpGate.heldCounter(endpoint)
+	gauge := metricName("http_held_requests", "endpoint", endpoint)
+	n.Metrics.Set(gauge, float64(held.Add(1)))
+	hold := n.httpGate.beginHold(endpoint)
+	var release sync.Once
+	releaseHold := func() {
+		release.Do(func() {
+			n.Metrics.Set(gauge, float64(held.Add(-1)))
+			n.httpGate.endHold(hold)
+		})
+	}
+	defer releaseHold()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		work()
+	}()
+	select {
+	case <-done:
+		return true
+	case <-r.Context().Done():
+		n.Metrics.Inc(metricName("http_client_disconnects_total", "endpoint", endpoint))
+		return false
+	}
+}
+
+func (n *Node) shedHTTP(w http.ResponseWriter) {
+	n.Metrics.Inc("http_shed_total")
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "Node is overloaded; retry shortly", http.StatusServiceUnavailable)
+}
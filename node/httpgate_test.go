@@ -0,0 +1,173 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStateFloodDoesNotStarveHeartbeats(t *testing.T) {
+	t.Chdir(t.TempDir())
+	follower := NewNode("F1", "127.0.0.1:9", nil, 1)
+	leader := NewNode("L1", "127.0.0.1:10", nil, 5)
+	t.Cleanup(leader.Client.Close)
+
+	// /state is stuck, as it is when hundreds of browsers poll a busy node.
+	stuck := make(chan struct{})
+	server := rpc.NewServer()
+	if err := server.Register(&NodeRPC{node: follower}); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	for path, h := range follower.rpcHandlers(server) {
+		mux.Handle(path, h)
+	}
+	mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) { <-stuck })
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: follower.gatedHandler(mux)}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	addr := l.Addr().String()
+
+	const extra = 32
+	flood := maxConcurrentHTTP + maxQueuedHTTP + extra
+	statuses := make(chan int, flood)
+	var wg sync.WaitGroup
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: flood}}
+	for range flood {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get("http://" + addr + "/state")
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	g := follower.httpGate
+	waitFor(t, "the gate to fill", func() bool {
+		return g.inflight.Load() == maxConcurrentHTTP && g.queued.Load() == maxQueuedHTTP &&
+			follower.Metrics.Counter("http_shed_total") == extra
+	})
+
+	// The leader's heartbeat still gets through at once.
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var ok bool
+	hb := BullyMessage{NodeID: leader.ID, Rank: leader.Rank, Address: leader.Address}
+	if err := leader.Client.CallContext(ctx, addr, "NodeRPC.HandleHeartbeat", hb, &ok); err != nil || !ok {
+		t.Fatalf("heartbeat during the flood: ok=%v err=%v", ok, err)
+	}
+	if took := time.Since(start); took > 500*time.Millisecond {
+		t.Errorf("heartbeat took %s during the flood", took)
+	}
+	if got, _ := follower.getCoordinatorAddress(); got != leader.Address {
+		t.Errorf("follower's leader = %q after the heartbeat, want %s", got, leader.Address)
+	}
+
+	close(stuck)
+	wg.Wait()
+	close(statuses)
+	shed := 0
+	for code := range statuses {
+		if code == http.StatusServiceUnavailable {
+			shed++
+		}
+	}
+	if shed < extra {
+		t.Errorf("%d requests shed with 503, want at least %d", shed, extra)
+	}
+	if got := g.inflight.Load(); got != 0 {
+		t.Errorf("%d requests still in flight", got)
+	}
+}
+
+// hangingCoordinator takes forwarded bids and never answers them.
+type hangingCoordinator struct{ release chan struct{} }
+
+func (h *hangingCoordinator) SubmitBidToCoordinator(args BidArgs, reply *CoordinatorBidReply) error {
+	<-h.release
+	return nil
+}
+
+func TestDroppedClientsReleaseHolds(t *testing.T) {
+	hang := &hangingCoordinator{release: make(chan struct{})}
+	s := rpc.NewServer()
+	if err := s.RegisterName("NodeRPC", hang); err != nil {
+		t.Fatal(err)
+	}
+	cl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpcMux := http.NewServeMux()
+	rpcMux.Handle(rpc.DefaultRPCPath, s)
+	go http.Serve(cl, rpcMux)
+	t.Cleanup(func() {
+		close(hang.release)
+		cl.Close()
+	})
+
+	n := followerOf(t, "L1", cl.Addr().String())
+	withLotUp(n)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bid", n.handleBidRequest)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: n.gatedHandler(mux)}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	// 200 bidders send a bid that the coordinator sits on, then vanish.
+	const clients = 200
+	conns := make([]net.Conn, 0, clients)
+	for i := range clients {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+		fmt.Fprintf(c, "POST /bid?itemId=lot1&amount=%d HTTP/1.1\r\nHost: x\r\nContent-Length: 0\r\n\r\n", 50+i)
+	}
+	g := n.httpGate
+	held := metricName("http_held_requests", "endpoint", "bid")
+	waitFor(t, "the bids to be held", func() bool {
+		return gauge(n.Metrics, held) == maxConcurrentHTTP && g.queued.Load() == clients-maxConcurrentHTTP
+	})
+	if s := g.status(); s.Held["bid"] != maxConcurrentHTTP || s.OldestHeldEndpoint != "bid" {
+		t.Errorf("intake = %+v, want %d bids held", s, maxConcurrentHTTP)
+	}
+	for _, c := range conns {
+		c.Close()
+	}
+
+	waitNoGoroutines(t, []string{"handleBidRequest", "holdForClient", "forwardBid", "gatedHandler"})
+	if got := gauge(n.Metrics, held); got != 0 {
+		t.Errorf("%s = %v after the clients left, want 0", held, got)
+	}
+	if s := g.status(); len(s.Held) != 0 || s.HTTPInFlight != 0 || s.HTTPQueued != 0 {
+		t.Errorf("intake after the clients left = %+v", s)
+	}
+	if got := n.bidForwards.Load(); got != 0 {
+		t.Errorf("%d forwards still open", got)
+	}
+	// Queued bids may be admitted as the held ones leave, and are then
+	// dropped in turn.
+	if got := n.Metrics.Counter(metricName("http_client_disconnects_total", "endpoint", "bid")); got < maxConcurrentHTTP || got > clients {
+		t.Errorf("http_client_disconnects_total{endpoint=bid} = %v, want %d to %d", got, maxConcurrentHTTP, clients)
+	}
+}
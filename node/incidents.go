@@ -0,0 +1,291 @@
+package node
+
+// incidents.go — Per-node incident log and the cluster-wide GET /incidents
+// timeline.
+//
+// Every node turns what it already detects into incidents with a start and,
+// once over, an end: alerts while raised (quorum loss, missing peers,
+// checkpoint failures, ...), one-off alerts such as a repaired divergence,
+// elections until a leader is known, leader changes, time spent in a
+// lifecycle phase other than ready, and restarts. A restart records the
+// time the node was down, measured from its last checkpoint, and closes
+// whatever was still open when it stopped.
+//
+// The log is saved with the local checkpoint and trimmed to
+// --incident-retention (and at most maxIncidents entries). GET /incidents
+// asks every peer for its log and merges them by start time, each entry
+// naming its node; ?scope=local skips the fan-out.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultIncidentRetention = 7 * 24 * time.Hour
+	maxIncidents             = 500
+	incidentsPeerTimeout     = 1500 * time.Millisecond
+)
+
+// Incident is one entry of the timeline. Point events (a leader change, a
+// restart) have EndUnix == StartUnix.
+type Incident struct {
+	ID          string `json:"id"`
+	NodeID      string `json:"nodeId"`
+	Kind        string `json:"kind"`          // alert key without its ":<subject>" suffix, or election, leader_change, phase, restart, downtime
+	Key         string `json:"key,omitempty"` // full alert key, for alerts
+	Severity    string `json:"severity"`
+	Message     string `json:"message"`
+	Resolution  string `json:"resolution,omitempty"`
+	StartUnix   int64  `json:"startUnix"`
+	EndUnix     int64  `json:"endUnix,omitempty"` // 0 while ongoing
+	DurationSec int64  `json:"durationSec"`       // so far, for ongoing ones
+	Ongoing     bool   `json:"ongoing"`
+}
+
+type incidentLog struct {
+	mu        sync.Mutex
+	nodeID    string
+	retention time.Duration
+	seq       int
+	entries   []*Incident
+	open      map[string]*Incident // by key
+}
+
+func newIncidentLog(nodeID string) *incidentLog {
+	return &incidentLog{nodeID: nodeID, retention: DefaultIncidentRetention, open: map[string]*Incident{}}
+}
+
+func incidentKind(key string) string {
+	kind, _, _ := strings.Cut(key, ":")
+	return kind
+}
+
+func (l *incidentLog) addLocked(inc *Incident) {
+	l.seq++
+	inc.ID = fmt.Sprintf("%s-%d-%d", l.nodeID, inc.StartUnix, l.seq)
+	inc.NodeID = l.nodeID
+	l.entries = append(l.entries, inc)
+	l.trimLocked()
+}
+
+// trimLocked drops closed incidents past the retention, then the oldest
+// closed ones beyond maxIncidents. Ongoing incidents are always kept.
+func (l *incidentLog) trimLocked() {
+	cutoff := time.Now().Add(-l.retention).Unix()
+	excess := len(l.entries) - maxIncidents
+	kept := l.entries[:0]
+	for _, inc := range l.entries {
+		closed := inc.EndUnix > 0
+		if closed && (inc.EndUnix < cutoff || excess > 0) {
+			excess--
+			continue
+		}
+		kept = append(kept, inc)
+	}
+	for i := len(kept); i < len(l.entries); i++ {
+		l.entries[i] = nil
+	}
+	l.entries = kept
+}
+
+// begin opens an incident under key unless one is already open.
+func (l *incidentLog) begin(key, kind, severity, message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.open[key]; ok {
+		return
+	}
+	inc := &Incident{Kind: kind, Severity: severity, Message: message, StartUnix: time.Now().Unix()}
+	if kind != key {
+		inc.Key = key
+	}
+	l.open[key] = inc
+	l.addLocked(inc)
+}
+
+// end closes the incident open under key, if any.
+func (l *incidentLog) end(key, resolution string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inc, ok := l.open[key]
+	if !ok {
+		return
+	}
+	delete(l.open, key)
+	inc.EndUnix = max(time.Now().Unix(), inc.StartUnix)
+	inc.Resolution = resolution
+}
+
+// point records an event without duration.
+func (l *incidentLog) point(kind, severity, message string) {
+	now := time.Now().Unix()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.addLocked(&Incident{Kind: kind, Severity: severity, Message: message, StartUnix: now, EndUnix: now})
+}
+
+// fromAlert is the AlertBus hook: raised alerts open an incident under
+// their key, resolutions close it, one-off notifications are points.
+func (l *incidentLog) fromAlert(a Alert, oneOff bool) {
+	switch {
+	case oneOff:
+		l.mu.Lock()
+		now := time.Now().Unix()
+		inc := &Incident{Kind: incidentKind(a.Key), Key: a.Key, Severity: a.Severity, Message: a.Message, StartUnix: now, EndUnix: now}
+		if inc.Kind == a.Key {
+			inc.Key = ""
+		}
+		l.addLocked(inc)
+		l.mu.Unlock()
+	case a.Resolved:
+		l.end(a.Key, a.Message)
+	default:
+		l.begin(a.Key, incidentKind(a.Key), a.Severity, a.Message)
+	}
+}
+
+// restore loads a saved log after a restart. Incidents still open when the
+// node stopped are closed at stoppedAt, and the gap until now is recorded
+// as downtime.
+func (l *incidentLog) restore(saved []Incident, stoppedAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stop := stoppedAt.Unix()
+	for i := range saved {
+		inc := saved[i]
+		if inc.EndUnix == 0 {
+			inc.EndUnix = max(stop, inc.StartUnix)
+			inc.Resolution = "node stopped"
+		}
+		inc.Ongoing = false
+		l.entries = append(l.entries, &inc)
+	}
+	l.seq = len(l.entries)
+	now := time.Now().Unix()
+	l.addLocked(&Incident{
+		Kind: "downtime", Severity: SeverityWarning, StartUnix: stop, EndUnix: max(now, stop),
+		Message:    fmt.Sprintf("%s was down; restarted from its checkpoint of %s", l.nodeID, stoppedAt.Format(time.RFC3339)),
+		Resolution: "restarted",
+	})
+}
+
+// list returns copies, oldest first, with durations filled in.
+func (l *incidentLog) list() []Incident {
+	now := time.Now().Unix()
+	l.mu.Lock()
+	out := make([]Incident, len(l.entries))
+	for i, inc := range l.entries {
+		out[i] = *inc
+	}
+	l.mu.Unlock()
+	for i := range out {
+		end := out[i].EndUnix
+		out[i].Ongoing = end == 0
+		if end == 0 {
+			end = now
+		}
+		out[i].DurationSec = max(end-out[i].StartUnix, 0)
+	}
+	return out
+}
+
+func (l *incidentLog) setRetention(d time.Duration) {
+	l.mu.Lock()
+	l.retention = d
+	l.trimLocked()
+	l.mu.Unlock()
+}
+
+// SetIncidentRetention sets how long closed incidents are kept.
+func (n *Node) SetIncidentRetention(d time.Duration) {
+	n.incidents.setRetention(d)
+}
+
+// noteLeaderChange records a change of the known coordinator. Called from
+// the health watcher, which samples the coordinator every tick.
+func (n *Node) noteLeaderChange() {
+	n.ElectionMutex.Lock()
+	leader, term := n.Coordinator, n.Term
+	n.ElectionMutex.Unlock()
+
+	n.healthMu.Lock()
+	prev := n.lastLeaderSeen
+	n.lastLeaderSeen = leader
+	n.healthMu.Unlock()
+
+	if leader != "" && !n.electionRunning.Load() {
+		n.incidents.end("election", "leader is "+leader)
+	}
+	if leader == prev || leader == "" {
+		return
+	}
+	if prev == "" {
+		prev = "none"
+	}
+	n.incidents.point("leader_change", SeverityInfo, fmt.Sprintf("leader %s → %s (term %d)", prev, leader, term))
+}
+
+// GetIncidents returns this node's incident log, for GET /incidents.
+func (rp *NodeRPC) GetIncidents(_ EmptyArgs, reply *[]Incident) error {
+	*reply = rp.node.incidents.list()
+	return nil
+}
+
+// IncidentSource is a peer that did not answer GET /incidents.
+type IncidentSource struct {
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
+
+// handleIncidentsRequest serves GET /incidents[?scope=local].
+func (n *Node) handleIncidentsRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	incidents := n.incidents.list()
+	unreachable := []IncidentSource{}
+	if r.URL.Query().Get("scope") != "local" {
+		peers := n.peerList()
+		type result struct {
+			peer      string
+			incidents []Incident
+			err       error
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), incidentsPeerTimeout)
+		defer cancel()
+		results := make(chan result, len(peers))
+		// Monitoring traffic bypasses callPeer so it creates no checkpoint dependencies.
+		fanOut(peers, func(p string) {
+			var reply []Incident
+			err := n.Client.CallContext(ctx, p, "NodeRPC.GetIncidents", EmptyArgs{}, &reply)
+			results <- result{p, reply, err}
+		})
+		for range peers {
+			res := <-results
+			if res.err != nil {
+				unreachable = append(unreachable, IncidentSource{res.peer, res.err.Error()})
+				continue
+			}
+			incidents = append(incidents, res.incidents...)
+		}
+	}
+	sort.SliceStable(incidents, func(i, j int) bool {
+		if incidents[i].StartUnix != incidents[j].StartUnix {
+			return incidents[i].StartUnix < incidents[j].StartUnix
+		}
+		return incidents[i].NodeID < incidents[j].NodeID
+	})
+	writeJSON(w, struct {
+		GeneratedAtUnix int64            `json:"generatedAtUnix"`
+		ServedBy        string           `json:"servedBy"`
+		Incidents       []Incident       `json:"incidents"`
+		Unreachable     []IncidentSource `json:"unreachable"`
+	}{time.Now().Unix(), n.ID, incidents, unreachable})
+}
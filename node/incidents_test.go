@@ -0,0 +1,154 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// incidentsOn serves GET /incidents on n.
+func incidentsOn(t *testing.T, n *Node, query string) (incidents []Incident, unreachable []IncidentSource) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	n.handleIncidentsRequest(rec, httptest.NewRequest(http.MethodGet, "/incidents"+query, nil))
+	var body struct {
+		Incidents   []Incident
+		Unreachable []IncidentSource
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("GET /incidents: %d %s", rec.Code, rec.Body)
+	}
+	return body.Incidents, body.Unreachable
+}
+
+func findIncident(incidents []Incident, nodeID, kind, message string) *Incident {
+	for i := range incidents {
+		if inc := &incidents[i]; inc.NodeID == nodeID && inc.Kind == kind && strings.Contains(inc.Message, message) {
+			return inc
+		}
+	}
+	return nil
+}
+
+func TestIncidentTimelineAfterFailoverAndPartition(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		tn.HeartbeatInterval = time.Hour // one round as B takes over, then quiet
+		tn.MajorityLossWindow = 0
+		setLeader(tn.Node, c.ID, c.Address)
+		tn.checkClusterHealth()
+	}
+
+	// Failover: C dies and B takes over.
+	if err := c.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	c.kill()
+	b.runElection()
+	if !b.leaderKnown() || b.currentTerm() != 1 {
+		t.Fatalf("B did not take over: term %d", b.currentTerm())
+	}
+	leading(t, b.Node)
+	waitFor(t, "A to follow B", func() bool {
+		a.ElectionMutex.Lock()
+		defer a.ElectionMutex.Unlock()
+		return a.Coordinator == b.ID
+	})
+	a.checkClusterHealth()
+
+	// Partition: B stops hearing from anyone.
+	if err := a.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	a.kill()
+	b.notePeerContact(a.Address, true)
+	b.notePeerContact(c.Address, true)
+	stale := time.Now().Add(-peerMissingAfter - time.Second)
+	b.healthMu.Lock()
+	b.leaderSince = stale
+	b.peerLastSeen[a.Address], b.peerLastSeen[c.Address] = stale, stale
+	b.healthMu.Unlock()
+	b.checkClusterHealth()
+	if !b.Alerts.IsActive("quorum_lost") {
+		t.Fatal("quorum_lost not raised on B")
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	// It heals: both come back from their checkpoints.
+	a, c = a.restart(t), c.restart(t)
+	expireCooldown(b.Client, c.Address) // B's heartbeats to the dead C opened its breaker
+	b.notePeerContact(a.Address, true)
+	b.notePeerContact(c.Address, true)
+	b.checkClusterHealth()
+
+	incidents, unreachable := incidentsOn(t, b.Node, "")
+	if len(unreachable) != 0 {
+		t.Errorf("unreachable peers: %+v", unreachable)
+	}
+	for i := 1; i < len(incidents); i++ {
+		if incidents[i].StartUnix < incidents[i-1].StartUnix {
+			t.Fatalf("timeline out of order at %d: %+v", i, incidents)
+		}
+	}
+	sane := func(what string, inc *Incident, minSec int64) {
+		t.Helper()
+		switch {
+		case inc == nil:
+			t.Errorf("timeline lacks %s: %+v", what, incidents)
+		case inc.Ongoing || inc.EndUnix < inc.StartUnix || inc.DurationSec < minSec || inc.DurationSec > 10:
+			t.Errorf("%s = %+v, want closed after %d-10s", what, inc, minSec)
+		}
+	}
+	sane("B's election", findIncident(incidents, b.ID, "election", "started an election"), 0)
+	sane("B's leader change", findIncident(incidents, b.ID, "leader_change", "C → B (term 1)"), 0)
+	sane("A's leader change", findIncident(incidents, a.ID, "leader_change", "C → B"), 0)
+	quorum := findIncident(incidents, b.ID, "quorum_lost", "")
+	sane("the partition", quorum, 1)
+	if quorum != nil && !strings.Contains(quorum.Resolution, "quorum regained") {
+		t.Errorf("partition resolution = %q", quorum.Resolution)
+	}
+	sane("the missing peer", findIncident(incidents, b.ID, "peer_missing", c.Address), 1)
+	sane("C's downtime", findIncident(incidents, c.ID, "downtime", "was down"), 1)
+	sane("A's downtime", findIncident(incidents, a.ID, "downtime", "was down"), 1)
+	// Logs survive a restart in the checkpoint.
+	sane("C's leader change before it died", findIncident(incidents, c.ID, "leader_change", "none → C"), 0)
+
+	if local, _ := incidentsOn(t, b.Node, "?scope=local"); len(local) == 0 || len(local) >= len(incidents) {
+		t.Errorf("?scope=local returned %d of %d incidents", len(local), len(incidents))
+	} else {
+		for _, inc := range local {
+			if inc.NodeID != b.ID {
+				t.Errorf("?scope=local includes %s's %s", inc.NodeID, inc.Kind)
+			}
+		}
+	}
+}
+
+func TestIncidentRetention(t *testing.T) {
+	l := newIncidentLog("N")
+	l.begin("quorum_lost", "quorum_lost", SeverityCritical, "still going")
+	l.point("restart", SeverityInfo, "old")
+	l.mu.Lock()
+	l.entries[0].StartUnix = time.Now().Add(-3 * time.Hour).Unix()
+	l.entries[1].StartUnix = time.Now().Add(-2 * time.Hour).Unix()
+	l.entries[1].EndUnix = l.entries[1].StartUnix
+	l.mu.Unlock()
+	l.point("restart", SeverityInfo, "new")
+
+	l.setRetention(time.Hour)
+	got := l.list()
+	if len(got) != 2 || got[0].Message != "still going" || !got[0].Ongoing || got[0].DurationSec < 3*3600 || got[1].Message != "new" {
+		t.Fatalf("after trimming to 1h: %+v", got)
+	}
+
+	for i := 0; i < maxIncidents+10; i++ {
+		l.point("restart", SeverityInfo, "flap")
+	}
+	if got := l.list(); len(got) != maxIncidents || got[0].Message != "still going" {
+		t.Errorf("%d incidents kept, first %+v; want %d with the ongoing one", len(got), got[0], maxIncidents)
+	}
+}
@@ -0,0 +1,75 @@
+package node
+
+// increments.go — Graduated minimum bid increments by price band.
+//
+// An increments table is a list of bands, each giving the step that applies
+// while the current price is under UpTo; the last band may be open-ended
+// (UpTo 0). The cluster-wide table is the "bidIncrements" runtime config
+// value, so it reaches every node through the config broadcast and hash
+// check; an item can carry its own table, replicated with the item. Without
+// a table any higher bid is accepted, as before.
+
+import "fmt"
+
+// IncrementBand is one row of an increments table.
+type IncrementBand struct {
+	UpTo      int `json:"upTo"`      // exclusive price ceiling; 0 = no ceiling (last band only)
+	Increment int `json:"increment"` // minimum raise while the price is in this band
+}
+
+// validateIncrements checks that bands ascend, steps are positive and only
+// the last band is open-ended.
+func validateIncrements(bands []IncrementBand) error {
+	prev := 0
+	for i, b := range bands {
+		if b.Increment <= 0 {
+			return fmt.Errorf("increment band %d: increment must be positive", i+1)
+		}
+		if b.UpTo == 0 {
+			if i != len(bands)-1 {
+				return fmt.Errorf("increment band %d: only the last band may be open-ended", i+1)
+			}
+			continue
+		}
+		if b.UpTo <= prev {
+			return fmt.Errorf("increment band %d: upTo must be greater than %d", i+1, prev)
+		}
+		prev = b.UpTo
+	}
+	return nil
+}
+
+// incrementFor returns the step for a current price of price: the first band
+// whose ceiling is above it. A price exactly on a ceiling belongs to the next
+// band. Past the last closed band, the last band's step applies.
+func incrementFor(bands []IncrementBand, price int) int {
+	if len(bands) == 0 {
+		return 1
+	}
+	for _, b := range bands {
+		if b.UpTo == 0 || price < b.UpTo {
+			return b.Increment
+		}
+	}
+	return bands[len(bands)-1].Increment
+}
+
+// bidIncrementsLocked returns the table for the current item: its own, or
+// the cluster-wide one. Must hold Queue.mu.
+func (n *Node) bidIncrementsLocked() []IncrementBand {
+	if item := n.Queue.CurrentItem; item != nil && len(item.Increments) > 0 {
+		return item.Increments
+	}
+	return n.runtimeConfig().BidIncrements
+}
+
+// minNextBidLocked returns the lowest acceptable bid and the step in force.
+// The opening bid is the starting price; after that it is the current price
+// plus its band's increment. Must hold Queue.mu.
+func (n *Node) minNextBidLocked() (minBid, step int) {
+	step = incrementFor(n.bidIncrementsLocked(), n.Queue.CurrentHighestBid)
+	if n.Queue.CurrentWinnerID == "" && n.Queue.CurrentWinner == "" {
+		return n.Queue.CurrentHighestBid + 1, step
+	}
+	return n.Queue.CurrentHighestBid + step, step
+}
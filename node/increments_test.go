@@ -0,0 +1,145 @@
+package node
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// houseBands is $5 steps under $100, $25 under $1000 and $100 above.
+var houseBands = []IncrementBand{{UpTo: 100, Increment: 5}, {UpTo: 1000, Increment: 25}, {Increment: 100}}
+
+func TestValidateIncrements(t *testing.T) {
+	cases := []struct {
+		name  string
+		bands []IncrementBand
+		want  string // "" for valid
+	}{
+		{"none", nil, ""},
+		{"house table", houseBands, ""},
+		{"all closed", []IncrementBand{{UpTo: 100, Increment: 5}, {UpTo: 500, Increment: 10}}, ""},
+		{"zero step", []IncrementBand{{UpTo: 100, Increment: 0}}, "band 1: increment must be positive"},
+		{"open band first", []IncrementBand{{Increment: 5}, {UpTo: 100, Increment: 10}}, "band 1: only the last band"},
+		{"descending", []IncrementBand{{UpTo: 500, Increment: 5}, {UpTo: 100, Increment: 10}}, "band 2: upTo must be greater than 500"},
+		{"repeated ceiling", []IncrementBand{{UpTo: 100, Increment: 5}, {UpTo: 100, Increment: 10}}, "band 2: upTo must be greater than 100"},
+	}
+	for _, c := range cases {
+		err := validateIncrements(c.bands)
+		if (c.want == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), c.want)) {
+			t.Errorf("%s: err = %v, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestIncrementFor(t *testing.T) {
+	closed := []IncrementBand{{UpTo: 100, Increment: 5}, {UpTo: 500, Increment: 10}}
+	cases := []struct {
+		name  string
+		bands []IncrementBand
+		price int
+		want  int
+	}{
+		{"no table", nil, 250, 1},
+		{"first band", houseBands, 0, 5},
+		{"just under a ceiling", houseBands, 99, 5},
+		{"on a ceiling", houseBands, 100, 25},
+		{"middle band", houseBands, 999, 25},
+		{"on the last ceiling", houseBands, 1000, 100},
+		{"open-ended band", houseBands, 1_000_000, 100},
+		{"past the last closed band", closed, 500, 10},
+	}
+	for _, c := range cases {
+		if got := incrementFor(c.bands, c.price); got != c.want {
+			t.Errorf("%s: increment at $%d = %d, want %d", c.name, c.price, got, c.want)
+		}
+	}
+}
+
+func TestMinNextBid(t *testing.T) {
+	n := biddingNode(t)
+	setLeader(n, n.ID, n.Address)
+	leading(t, n)
+	if rec := postConfig(n, `{"bidIncrements": [{"upTo": 100, "increment": 5}, {"upTo": 1000, "increment": 25}, {"upTo": 0, "increment": 100}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/config: %d %s", rec.Code, rec.Body)
+	}
+	cases := []struct {
+		name          string
+		price         int
+		winner        string
+		own           []IncrementBand
+		minBid, step  int
+		rejected, bid int // a bid one under the minimum, and one at it
+		reason        PrepareRejection
+	}{
+		{"opening bid", 10, "", nil, 11, 5, 10, 11, RejectBidTooLow},
+		{"first band", 60, "b1", nil, 65, 5, 64, 65, RejectBelowIncrement},
+		{"on a ceiling", 100, "b1", nil, 125, 25, 124, 125, RejectBelowIncrement},
+		{"top band", 1000, "b1", nil, 1100, 100, 1099, 1100, RejectBelowIncrement},
+		{"item's own table", 60, "b1", []IncrementBand{{Increment: 20}}, 80, 20, 79, 80, RejectBelowIncrement},
+	}
+	for _, c := range cases {
+		n.Queue.mu.Lock()
+		n.Queue.CurrentItem.Increments = c.own
+		n.Queue.CurrentHighestBid, n.Queue.CurrentWinnerID, n.Queue.CurrentWinner = c.price, c.winner, c.winner
+		minBid, step := n.minNextBidLocked()
+		n.Queue.mu.Unlock()
+		if minBid != c.minBid || step != c.step {
+			t.Errorf("%s: min next bid $%d step $%d, want $%d step $%d", c.name, minBid, step, c.minBid, c.step)
+		}
+		if ok, reason := n.canPrepareBid(BidArgs{BidderID: "b2", Amount: c.rejected, ItemID: "lot1"}); ok || reason != c.reason {
+			t.Errorf("%s: $%d = %v %s, want %s", c.name, c.rejected, ok, reason, c.reason)
+		}
+		if ok, reason := n.canPrepareBid(BidArgs{BidderID: "b2", Amount: c.bid, ItemID: "lot1"}); !ok {
+			t.Errorf("%s: $%d refused: %s", c.name, c.bid, reason)
+		}
+	}
+}
+
+func TestIncrementsAgreeAcrossNodes(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a := nodes[0]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	if rec := postConfig(nodes[2].Node, `{"bidIncrements": [{"upTo": 100, "increment": 5}, {"upTo": 0, "increment": 25}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/config: %d %s", rec.Code, rec.Body)
+	}
+	for _, tn := range nodes {
+		waitFor(t, tn.ID+" to apply the table", func() bool { return len(tn.runtimeConfig().BidIncrements) == 2 })
+	}
+
+	ladder := []struct {
+		amount int
+		code   BidCode
+	}{
+		{50, BidCommitted},
+		{54, BidBelowIncrement},
+		{55, BidCommitted},
+		{100, BidCommitted},
+		{120, BidBelowIncrement}, // $100 is in the $25 band
+		{125, BidCommitted},
+	}
+	for _, step := range ladder {
+		reply := a.ProposeBid(t.Context(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: step.amount, ItemID: "lot1"})
+		if reply.Code != step.code {
+			t.Fatalf("$%d = %s %q, want %s", step.amount, reply.Code, reply.Message, step.code)
+		}
+		if step.code == BidCommitted {
+			for _, tn := range nodes {
+				waitFor(t, tn.ID+" to apply the bid", func() bool { return highestBid(tn.Node) == step.amount })
+			}
+		}
+		// Every participant agrees with the coordinator on the next bid.
+		for _, tn := range nodes[1:] {
+			for _, amount := range []int{step.amount + 4, step.amount + 5, step.amount + 24, step.amount + 25} {
+				okA, _ := a.canPrepareBid(BidArgs{BidderID: "b2", Amount: amount, ItemID: "lot1"})
+				okP, _ := tn.canPrepareBid(BidArgs{BidderID: "b2", Amount: amount, ItemID: "lot1"})
+				if okA != okP {
+					t.Errorf("after $%d: $%d is %v on A but %v on %s", step.amount, amount, okA, okP, tn.ID)
+				}
+			}
+		}
+	}
+}
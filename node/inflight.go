@@ -0,0 +1,211 @@
+package node
+
+// inflight.go — GET /admin/inflight: what the node is waiting on right now.
+//
+// Meant for a bid that hangs. The response shows five things:
+//   - the Ricart–Agrawala state: requesting, in the critical section,
+//     replies still outstanding, and peers deferred until release
+//   - every bid proposal this node is running as coordinator, with its
+//     stage, age, vote tally and the peers that have not voted yet
+//   - decisions still being retried to peers that have not acknowledged
+//   - the intake queues: HTTP slots, held requests and forwarded bids
+//   - the lifecycle phase, so a drained node is obvious
+//
+// Everything is read through accessors that take the owning mutex; the
+// endpoint never blocks on the RA lock or on a 2PC round.
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// txnRounds tracks the bid proposals this node is coordinating, from the
+// moment they ask for the critical section until proposeBid returns.
+type txnRounds struct {
+	mu     sync.Mutex
+	seq    uint64
+	rounds map[uint64]*txnRound
+}
+
+type txnRound struct {
+	txnID      string // empty until the critical section is acquired
+	bid        BidArgs
+	stage      bidStage // stageRAAcquire, stagePrepare or stageDecide
+	started    time.Time
+	stageSince time.Time
+	quorum     int
+	yes        int
+	no         map[PrepareRejection]int
+	awaiting   map[string]bool // peers asked to prepare that have not answered
+}
+
+// InFlightRound is one row of "rounds" in GET /admin/inflight.
+type InFlightRound struct {
+	TxnID      string         `json:"txnId,omitempty"`
+	Stage      string         `json:"stage"`
+	AgeMs      int64          `json:"ageMs"`
+	StageAgeMs int64          `json:"stageAgeMs"`
+	Amount     int            `json:"amount"`
+	Bidder     string         `json:"bidder"`
+	BidderID   string         `json:"bidderId"`
+	ItemID     string         `json:"itemId,omitempty"`
+	Quorum     int            `json:"quorum,omitempty"`
+	Yes        int            `json:"yes,omitempty"` // includes the coordinator's own vote
+	No         map[string]int `json:"no,omitempty"`
+	Awaiting   []string       `json:"awaiting,omitempty"`
+}
+
+// begin registers a proposal that is about to request the critical section.
+func (t *txnRounds) begin(bid BidArgs) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rounds == nil {
+		t.rounds = map[uint64]*txnRound{}
+	}
+	t.seq++
+	now := time.Now()
+	t.rounds[t.seq] = &txnRound{bid: bid, stage: stageRAAcquire, started: now, stageSince: now}
+	return t.seq
+}
+
+func (t *txnRounds) end(id uint64) {
+	t.mu.Lock()
+	delete(t.rounds, id)
+	t.mu.Unlock()
+}
+
+// prepare records that the round has the critical section and is asking peers.
+func (t *txnRounds) prepare(id uint64, txnID string, peers []string, quorum int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rounds[id]
+	if !ok {
+		return
+	}
+	r.txnID, r.quorum, r.yes = txnID, quorum, 1
+	r.stage, r.stageSince = stagePrepare, time.Now()
+	r.no = map[PrepareRejection]int{}
+	r.awaiting = make(map[string]bool, len(peers))
+	for _, p := range peers {
+		r.awaiting[p] = true
+	}
+}
+
+func (t *txnRounds) vote(id uint64, peer string, yes bool, reason PrepareRejection) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rounds[id]
+	if !ok {
+		return
+	}
+	delete(r.awaiting, peer)
+	if yes {
+		r.yes++
+	} else {
+		r.no[reason]++
+	}
+}
+
+func (t *txnRounds) advance(id uint64, s bidStage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, ok := t.rounds[id]; ok {
+		r.stage, r.stageSince = s, time.Now()
+	}
+}
+
+// get returns one round as it stands, if it is still running.
+func (t *txnRounds) get(id uint64) (InFlightRound, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rounds[id]
+	if !ok {
+		return InFlightRound{}, false
+	}
+	return r.row(time.Now()), true
+}
+
+// snapshot returns the rounds oldest first.
+func (t *txnRounds) snapshot() []InFlightRound {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	out := make([]InFlightRound, 0, len(t.rounds))
+	for _, r := range t.rounds {
+		out = append(out, r.row(now))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AgeMs > out[j].AgeMs })
+	return out
+}
+
+func (r *txnRound) row(now time.Time) InFlightRound {
+	row := InFlightRound{
+		TxnID:      r.txnID,
+		Stage:      bidStageNames[r.stage],
+		AgeMs:      now.Sub(r.started).Milliseconds(),
+		StageAgeMs: now.Sub(r.stageSince).Milliseconds(),
+		Amount:     r.bid.Amount,
+		Bidder:     r.bid.DisplayName,
+		BidderID:   r.bid.BidderID,
+		ItemID:     r.bid.ItemID,
+		Quorum:     r.quorum,
+		Yes:        r.yes,
+	}
+	if len(r.no) > 0 {
+		row.No = make(map[string]int, len(r.no))
+		for reason, count := range r.no {
+			row.No[string(reason)] = count
+		}
+	}
+	for p := range r.awaiting {
+		row.Awaiting = append(row.Awaiting, p)
+	}
+	sort.Strings(row.Awaiting)
+	return row
+}
+
+// IntakeStatus is the "intake" section of GET /admin/inflight.
+type IntakeStatus struct {
+	HTTPInFlight       int64            `json:"httpInFlight"`
+	HTTPQueued         int64            `json:"httpQueued"` // waiting for an HTTP slot
+	Held               map[string]int64 `json:"held"`       // per endpoint, waiting on the coordinator
+	OldestHeldEndpoint string           `json:"oldestHeldEndpoint,omitempty"`
+	OldestHeldMs       int64            `json:"oldestHeldMs"`
+	BidForwards        int64            `json:"bidForwards"` // bids this node has forwarded and not heard back on
+	AsyncQueued        int              `json:"asyncQueued"` // fire-and-forget sends queued, all peers
+}
+
+// handleInFlightRequest serves GET /admin/inflight.
+func (n *Node) handleInFlightRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	coordinator, isCoordinator := n.getCoordinatorAddress()
+	intake := n.httpGate.status()
+	intake.BidForwards = n.bidForwards.Load()
+	for _, d := range n.async.snapshot() {
+		intake.AsyncQueued += d.Queued
+	}
+	status := map[string]interface{}{
+		"nodeId":        n.ID,
+		"phase":         n.Phase(),
+		"isCoordinator": isCoordinator,
+		"coordinator":   coordinator,
+		"mutex":         n.mutexKind,
+		"serialize":     n.serialize,
+		"ra":            n.RA.Status(),
+		"rounds":        n.rounds.snapshot(),
+		"deliveries":    n.deliveries.snapshot(),
+		"intake":        intake,
+	}
+	if q, ok := n.Mutex.(*QuorumMutex); ok {
+		status["quorum"] = q.Status()
+	}
+	if t, ok := n.Mutex.(*TokenRingManager); ok {
+		status["token"] = t.Status()
+	}
+	writeJSON(w, status)
+}
@@ -0,0 +1,116 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type inFlightStatus struct {
+	Phase         NodePhase       `json:"phase"`
+	IsCoordinator bool            `json:"isCoordinator"`
+	RA            RAStatus        `json:"ra"`
+	Rounds        []InFlightRound `json:"rounds"`
+	Intake        IntakeStatus    `json:"intake"`
+}
+
+func inFlightOf(t *testing.T, n *Node) inFlightStatus {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	n.handleInFlightRequest(rec, httptest.NewRequest(http.MethodGet, "/admin/inflight", nil))
+	var s inFlightStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatalf("/admin/inflight: %d %s", rec.Code, rec.Body)
+	}
+	return s
+}
+
+func TestInFlightShowsStuckRound(t *testing.T) {
+	// Four nodes need three votes; with p2 voting no, the round waits on frozen p3.
+	release := make(chan struct{})
+	frozen := func(ctx context.Context, method string, reply interface{}) error {
+		if _, ok := reply.(*PrepareReply); ok {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return votingPeer("yes")(ctx, method, reply)
+	}
+	caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{
+		"p1:1": votingPeer("yes"),
+		"p2:1": votingPeer("bid_too_low"),
+		"p3:1": frozen,
+	}}
+	n := withLotUp(electionNode(t, caller, "p1:1", "p2:1", "p3:1"))
+	setLeader(n, n.ID, n.Address)
+
+	done := make(chan CoordinatorBidReply, 1)
+	go func() {
+		done <- n.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+	}()
+	waitFor(t, "the round to wait on p3 alone", func() bool {
+		rounds := n.rounds.snapshot()
+		return len(rounds) == 1 && len(rounds[0].Awaiting) == 1
+	})
+	time.Sleep(20 * time.Millisecond)
+
+	s := inFlightOf(t, n)
+	if !s.IsCoordinator || s.Phase != PhaseReady {
+		t.Errorf("coordinator = %v, phase = %s", s.IsCoordinator, s.Phase)
+	}
+	if !s.RA.InCriticalSection || s.RA.HeldMs <= 0 {
+		t.Errorf("ra = %+v, want the round holding the critical section", s.RA)
+	}
+	if len(s.Rounds) != 1 {
+		t.Fatalf("rounds = %+v, want the stuck one", s.Rounds)
+	}
+	r := s.Rounds[0]
+	if r.TxnID == "" || r.Stage != "prepare" || r.BidderID != "b1" || r.Amount != 50 || r.ItemID != "lot1" {
+		t.Errorf("round = %+v, want b1's $50 on lot1 in prepare", r)
+	}
+	if r.Quorum != 3 || r.Yes != 2 || !reflect.DeepEqual(r.No, map[string]int{"bid_too_low": 1}) || !reflect.DeepEqual(r.Awaiting, []string{"p3:1"}) {
+		t.Errorf("tally = quorum %d, yes %d, no %v, awaiting %v; want 3, 2, one bid_too_low, [p3:1]", r.Quorum, r.Yes, r.No, r.Awaiting)
+	}
+	if r.AgeMs < 20 || r.StageAgeMs < 20 || r.StageAgeMs > r.AgeMs {
+		t.Errorf("ages = %dms in %dms, want both at least 20ms", r.StageAgeMs, r.AgeMs)
+	}
+
+	close(release)
+	<-done
+	waitNoGoroutines(t, bidRoundGoroutines)
+	if s := inFlightOf(t, n); len(s.Rounds) != 0 || s.RA.InCriticalSection {
+		t.Errorf("after the round: rounds %+v, ra %+v", s.Rounds, s.RA)
+	}
+}
+
+func TestInFlightShowsRAWait(t *testing.T) {
+	nodes := testCluster(t, "H", "R")
+	h, r := nodes[0], nodes[1]
+	if err := h.RA.RequestCS(); err != nil {
+		t.Fatal(err)
+	}
+	rDone := requestCS(r.RA)
+	waitFor(t, "H to defer R", func() bool { return len(h.RA.Status().DeferredPeers) == 1 })
+	time.Sleep(10 * time.Millisecond)
+
+	if ra := inFlightOf(t, h.Node).RA; !ra.InCriticalSection || !reflect.DeepEqual(ra.DeferredPeers, []string{r.Address}) {
+		t.Errorf("H: %+v, want in the CS deferring R", ra)
+	}
+	ra := inFlightOf(t, r.Node).RA
+	if !ra.Requesting || ra.InCriticalSection || ra.RepliesOutstanding != 1 || ra.WaitingMs <= 0 ||
+		!reflect.DeepEqual(ra.Awaiting, []string{h.Address}) || !reflect.DeepEqual(ra.DeferredBy, []string{h.Address}) {
+		t.Errorf("R: %+v, want waiting on H", ra)
+	}
+
+	h.RA.ReleaseCS()
+	if err := <-rDone; err != nil {
+		t.Fatal(err)
+	}
+	r.RA.ReleaseCS()
+}
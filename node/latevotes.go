@@ -0,0 +1,98 @@
+package node
+
+// latevotes.go — Prepare votes that arrive after the coordinator decided.
+//
+// ProposeBid stops collecting votes as soon as the outcome is known: a
+// quorum of YES, too many NO for one, or the vote timeout. Prepares still
+// out at that point used to be cancelled on the spot, and a peer that had
+// already prepared was left to whichever came first, the decision broadcast
+// or the prepared-txn TTL. When the prepare reached a slow peer after the
+// decision did, the pending entry sat there until the TTL aborted it.
+//
+// Now the remaining votes are drained in the background for
+// --late-vote-grace (2s by default) before the prepares are cancelled. Each
+// late vote is logged as TXN_LATE_VOTE and counted in
+// late_prepare_votes_total{vote}, and a peer that voted YES late is sent the
+// decision again right away, so its pending entry resolves at once. Applying
+// a decision twice is harmless. A drain ends when every peer has answered
+// or the grace runs out, whichever is first; late_vote_drains is the number
+// running. With --late-vote-grace 0 prepares are cancelled as before.
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultLateVoteGrace = 2 * time.Second
+
+// CheckLateVoteGrace validates --late-vote-grace. A grace past the
+// prepared-txn TTL would resend decisions to peers that already gave up.
+func CheckLateVoteGrace(d time.Duration) error {
+	if d < 0 || d > preparedTxnTTL {
+		return fmt.Errorf("--late-vote-grace must be between 0 and %s, got %s", preparedTxnTTL, d)
+	}
+	return nil
+}
+
+// lateVoteDrains counts the drains running, for the late_vote_drains gauge.
+type lateVoteDrains struct {
+	active atomic.Int64
+}
+
+// drainLateVotes reads the votes of the outstanding peers after the
+// decision, then calls stop to cancel whatever prepares are left.
+func (n *Node) drainLateVotes(txnID string, decision DecisionArgs, votes <-chan voteResult, outstanding int, stop context.CancelFunc) {
+	grace := n.LateVoteGrace
+	if outstanding == 0 || grace <= 0 {
+		stop()
+		return
+	}
+	n.Metrics.Set("late_vote_drains", float64(n.lateVotes.active.Add(1)))
+	go func() {
+		defer func() {
+			n.Metrics.Set("late_vote_drains", float64(n.lateVotes.active.Add(-1)))
+		}()
+		defer stop()
+		decided := time.Now()
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		for ; outstanding > 0; outstanding-- {
+			select {
+			case v := <-votes:
+				n.noteLateVote(txnID, decision, v, time.Since(decided))
+			case <-timer.C:
+				n.logTxnEvent(txnID, "TXN_LATE_VOTES_CLOSED", fmt.Sprintf("%d peer(s) still silent %s after the decision", outstanding, grace))
+				return
+			}
+		}
+	}()
+}
+
+// noteLateVote records one vote that missed the decision. Failed calls are
+// not votes and are ignored.
+func (n *Node) noteLateVote(txnID string, decision DecisionArgs, v voteResult, after time.Duration) {
+	if v.reason == RejectUnreachable || v.reason == RejectIncompatible {
+		return
+	}
+	after = after.Round(time.Millisecond)
+	if !v.yes {
+		n.Metrics.Inc(metricName("late_prepare_votes_total", "vote", "no"))
+		n.logTxnEvent(txnID, "TXN_LATE_VOTE", fmt.Sprintf("peer=%s vote=no reason=%s after=%s", n.peerName(v.peer), v.reason, after))
+		return
+	}
+	outcome := "abort"
+	if decision.Commit {
+		outcome = "commit"
+	}
+	n.Metrics.Inc(metricName("late_prepare_votes_total", "vote", "yes"))
+	n.logTxnEvent(txnID, "TXN_LATE_VOTE", fmt.Sprintf("peer=%s vote=yes after=%s; resending %s", n.peerName(v.peer), after, outcome))
+	log.Printf("[%s] Late YES from %s on txn %s; resending %s\n", n.ID, n.peerName(v.peer), txnID, outcome)
+	p := v.peer
+	n.async.send(p, "DecideBid", func() error {
+		var ack bool
+		return n.callPeer(p, "NodeRPC.DecideBid", decision, &ack)
+	})
+}
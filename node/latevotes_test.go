@@ -0,0 +1,193 @@
+package node
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func gauge(m *Metrics, name string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gauges[name]
+}
+
+// txnEvents returns the events n logged for txnID, in order.
+func txnEvents(t *testing.T, n *Node, txnID string) []string {
+	t.Helper()
+	f, err := os.Open(txnLogPath(n.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e TxnLogEntry
+		if json.Unmarshal(s.Bytes(), &e) == nil && e.TxnID == txnID {
+			events = append(events, e.Event)
+		}
+	}
+	return events
+}
+
+func hasEvent(events []string, event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// bidRoundGoroutines are the functions a 2PC round runs in, directly or
+// in the goroutines it starts.
+var bidRoundGoroutines = []string{"node.(*Node).proposeBid", "node.(*Node).drainLateVotes", "node.fanOut"}
+
+// goroutinesIn returns the stacks of the goroutines running, or started
+// by, any of fns.
+func goroutinesIn(fns []string) []string {
+	buf := make([]byte, 1<<20)
+	var found []string
+	for _, g := range strings.Split(string(buf[:runtime.Stack(buf, true)]), "\n\n") {
+		for _, fn := range fns {
+			if strings.Contains(g, fn) {
+				found = append(found, g)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// waitNoGoroutines waits for every goroutine in fns to end.
+func waitNoGoroutines(t *testing.T, fns []string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		left := goroutinesIn(fns)
+		if len(left) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutine(s) left behind:\n%s", len(left), strings.Join(left, "\n\n"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLateVoteDrainEndsAtGrace(t *testing.T) {
+	caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{
+		"p1:1": func(context.Context, string, interface{}) error { return nil },
+	}}
+	n := electionNode(t, caller, "p1:1", "p2:1", "p3:1")
+	n.LateVoteGrace = 100 * time.Millisecond
+
+	votes := make(chan voteResult, 3)
+	var stopped atomic.Int32
+	decision := DecisionArgs{TxnID: "N2-1", Commit: true}
+	n.drainLateVotes("N2-1", decision, votes, 3, func() { stopped.Add(1) })
+	if got := gauge(n.Metrics, "late_vote_drains"); got != 1 {
+		t.Errorf("late_vote_drains while draining = %v, want 1", got)
+	}
+
+	// One peer answers late; the other two never do.
+	votes <- voteResult{peer: "p1:1", yes: true}
+	waitFor(t, "the decision to be resent", func() bool { return len(caller.sent("p1:1")) == 1 })
+	if got := caller.sent("p1:1"); got[0] != "NodeRPC.DecideBid" {
+		t.Errorf("late voter was sent %v, want the decision", got)
+	}
+
+	waitFor(t, "the drain to stop", func() bool { return stopped.Load() == 1 })
+	waitNoGoroutines(t, bidRoundGoroutines)
+	if got := gauge(n.Metrics, "late_vote_drains"); got != 0 {
+		t.Errorf("late_vote_drains after the grace = %v, want 0", got)
+	}
+	if got := n.Metrics.Counter(metricName("late_prepare_votes_total", "vote", "yes")); got != 1 {
+		t.Errorf("late_prepare_votes_total{vote=yes} = %v, want 1", got)
+	}
+	events := txnEvents(t, n, "N2-1")
+	if !hasEvent(events, "TXN_LATE_VOTE") || !hasEvent(events, "TXN_LATE_VOTES_CLOSED") {
+		t.Errorf("events = %v, want TXN_LATE_VOTE and TXN_LATE_VOTES_CLOSED", events)
+	}
+}
+
+func TestLateVoteDrainDisabled(t *testing.T) {
+	n := electionNode(t, &fakeCaller{}, "p1:1")
+	n.LateVoteGrace = 0
+	var stopped atomic.Int32
+	n.drainLateVotes("N2-1", DecisionArgs{TxnID: "N2-1"}, make(chan voteResult, 1), 1, func() { stopped.Add(1) })
+	if stopped.Load() != 1 {
+		t.Fatal("prepares not cancelled at once with --late-vote-grace 0")
+	}
+	if got := gauge(n.Metrics, "late_vote_drains"); got != 0 {
+		t.Errorf("late_vote_drains = %v, want 0", got)
+	}
+}
+
+// lateCaller holds back slow's prepare votes until after the decision and
+// loses the first decision sent to it.
+type lateCaller struct {
+	peerCaller
+	slow    string
+	delay   time.Duration
+	dropped atomic.Bool
+}
+
+func (c *lateCaller) CallContext(ctx context.Context, address, method string, args, reply interface{}) error {
+	if address == c.slow && method == "NodeRPC.DecideBid" && c.dropped.CompareAndSwap(false, true) {
+		return errors.New("connection reset")
+	}
+	err := c.peerCaller.CallContext(ctx, address, method, args, reply)
+	if address == c.slow && method == "NodeRPC.PrepareBid" {
+		time.Sleep(c.delay)
+	}
+	return err
+}
+
+func pendingTxns(n *Node) int {
+	n.TxnMutex.Lock()
+	defer n.TxnMutex.Unlock()
+	return len(n.PendingTxns)
+}
+
+func TestLateYesVoterResolved(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, c := nodes[0], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	a.LateVoteGrace = time.Second
+
+	a.caller = &lateCaller{peerCaller: a.Client, slow: c.Address, delay: 200 * time.Millisecond}
+	reply := a.ProposeBid(context.Background(), BidArgs{BidderID: "b2", DisplayName: "Bob", Amount: 50, ItemID: "lot1"})
+	if reply.Code != BidCommitted {
+		t.Fatalf("reply = %s %q, want committed", reply.Code, reply.Message)
+	}
+
+	// C prepared, missed the decision and voted YES after it was made; the
+	// drain resends the decision, which clears C's pending entry.
+	resent := metricName("rpc_async_total", "peer", c.Address, "method", "DecideBid", "result", "ok")
+	waitFor(t, "the decision to be resent to C", func() bool { return a.Metrics.Counter(resent) == 1 })
+	waitFor(t, "C to apply the bid", func() bool { return highestBid(c.Node) == 50 })
+	if got := pendingTxns(c.Node); got != 0 {
+		t.Errorf("C holds %d pending txns, want 0", got)
+	}
+	if got := a.Metrics.Counter(metricName("late_prepare_votes_total", "vote", "yes")); got != 1 {
+		t.Errorf("late_prepare_votes_total{vote=yes} = %v, want 1", got)
+	}
+	if events := txnEvents(t, a.Node, reply.TxnID); !hasEvent(events, "TXN_LATE_VOTE") {
+		t.Errorf("events = %v, want TXN_LATE_VOTE", events)
+	}
+
+	waitFor(t, "the drain to end", func() bool { return gauge(a.Metrics, "late_vote_drains") == 0 })
+	waitNoGoroutines(t, bidRoundGoroutines)
+}
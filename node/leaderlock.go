@@ -0,0 +1,101 @@
+package node
+
+// leaderlock.go — Serializing coordinator-only changes without a
+// cluster-wide round (--serialize=leader-lock).
+//
+// Every bid, queue change and admin action is already funnelled to the
+// coordinator, and only the coordinator enters the critical section. With one
+// proposer, the Ricart–Agrawala round before each 2PC is N RPCs of pure
+// latency. In leader-lock mode the coordinator takes a local mutex instead.
+//
+// The local lock only excludes this node, so it is used only while nothing
+// suggests a second coordinator:
+//
+//   - this node is coordinator, in the term it was elected or handed
+//     leadership in; a term learned since then without a change of leader
+//     (a pre-vote that named us again, say) counts as a mismatch
+//   - no other node has sent a heartbeat or a leader claim within the last
+//     two leader timeouts; a deposed leader that has not noticed, or a tie
+//     in the same term, sends exactly those
+//
+// Otherwise the coordinator takes the cluster mutex (--mutex) as well, as in
+// ra mode, and counts cs_fallbacks_total{reason}. The local lock is taken
+// first in both cases, so the two paths never run side by side on one node.
+// A leader demoted while it holds the lock finishes like a demoted leader in
+// ra mode: the term fence refuses its prepares and decisions.
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Serialization modes, for --serialize.
+const (
+	SerializeRA         = "ra"
+	SerializeLeaderLock = "leader-lock"
+)
+
+// ParseSerializeMode checks a --serialize value.
+func ParseSerializeMode(mode string) (string, error) {
+	switch mode {
+	case SerializeRA, SerializeLeaderLock:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown serialization mode %q (want %s or %s)", mode, SerializeRA, SerializeLeaderLock)
+}
+
+// SetSerializeMode selects how coordinator-only changes are serialized;
+// call it before Start.
+func (n *Node) SetSerializeMode(mode string) {
+	n.serialize = mode
+}
+
+// enterCS enters the critical section of a coordinator-only change and
+// returns the function that leaves it. On error nothing is held.
+func (n *Node) enterCS() (func(), error) {
+	if n.serialize != SerializeLeaderLock {
+		if err := n.Mutex.RequestCS(); err != nil {
+			return nil, err
+		}
+		return n.Mutex.ReleaseCS, nil
+	}
+	n.leaderLock.Lock()
+	reason := n.leaderLockUnsafe()
+	if reason == "" {
+		return n.leaderLock.Unlock, nil
+	}
+	n.Metrics.Inc(metricName("cs_fallbacks_total", "reason", reason))
+	log.Printf("[%s] 🔒 Leader lock not safe (%s); taking the cluster mutex\n", n.ID, reason)
+	if err := n.Mutex.RequestCS(); err != nil {
+		n.leaderLock.Unlock()
+		return nil, err
+	}
+	return func() {
+		n.Mutex.ReleaseCS()
+		n.leaderLock.Unlock()
+	}, nil
+}
+
+// leaderLockUnsafe says why a second coordinator could exist, or "".
+func (n *Node) leaderLockUnsafe() string {
+	n.ElectionMutex.Lock()
+	defer n.ElectionMutex.Unlock()
+	switch {
+	case n.Coordinator != n.ID:
+		return "not_coordinator"
+	case n.Term != n.leaderTerm:
+		return "term_mismatch"
+	case time.Since(n.rivalLeaderAt) < 2*n.LeaderTimeout:
+		return "rival_leader"
+	}
+	return ""
+}
+
+// noteRivalLeaderLocked records leader traffic from another node while this
+// node is coordinator. Must hold ElectionMutex.
+func (n *Node) noteRivalLeaderLocked() {
+	if n.Coordinator == n.ID {
+		n.rivalLeaderAt = time.Now()
+	}
+}
@@ -0,0 +1,74 @@
+package node
+
+// leaderrole.go — The coordinator role's lifetime.
+//
+// A node that lost leadership used to find out only when its goroutines
+// next looked: the heartbeat loop after its next interval, the item timer
+// after sleeping to the lot's deadline, the periodic checkpointer at its
+// next tick. Until then a goroutine per lot sat idle, and a timer that woke
+// just as the node was re-elected could act on a lot it no longer owned.
+//
+// Each spell as coordinator now has a context. It is created when
+// Coordinator becomes this node and cancelled, under ElectionMutex, when
+// Coordinator becomes anything else, so a demotion inside HandleCoordinator
+// or a step-down stops the heartbeat loop, the checkpointer and any item or
+// announcement timer before the RPC returns. Coordinator must therefore only
+// be changed through setCoordinatorLocked.
+
+import (
+	"context"
+	"time"
+)
+
+// setCoordinatorLocked records the leader and starts or ends this node's
+// spell as coordinator. Must hold ElectionMutex.
+func (n *Node) setCoordinatorLocked(id string) {
+	was := n.Coordinator == n.ID
+	n.Coordinator = id
+	switch {
+	case id == n.ID && !was:
+		n.leaderCtx, n.leaderCancel = context.WithCancel(context.Background())
+		n.leaderTerm = n.Term
+	case id != n.ID && was && n.leaderCancel != nil:
+		n.leaderCancel()
+		n.leaderCancel = nil
+	}
+}
+
+// leaderContext returns the context of the current spell as coordinator,
+// or a cancelled one if this node is not coordinator.
+func (n *Node) leaderContext() context.Context {
+	n.ElectionMutex.Lock()
+	defer n.ElectionMutex.Unlock()
+	if n.Coordinator != n.ID || n.leaderCtx == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+	return n.leaderCtx
+}
+
+// startLeading starts the coordinator's goroutines; all but
+// OnBecomeCoordinator run until the spell ends.
+func (n *Node) startLeading() {
+	ctx := n.leaderContext()
+	go n.BroadcastHeartbeats(ctx)
+	go n.runPeriodicCheckpointing(ctx)
+	go n.OnBecomeCoordinator()
+}
+
+// sleepWhileLeading sleeps for d and reports whether this node's spell as
+// coordinator outlasted it.
+func sleepWhileLeading(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
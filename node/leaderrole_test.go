@@ -0,0 +1,67 @@
+package node
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// leaderLoops are the goroutines that run for one spell as coordinator.
+var leaderLoops = []string{
+	"node.(*Node).BroadcastHeartbeats",
+	"node.(*Node).runPeriodicCheckpointing",
+	"node.(*Node).runItemTimer",
+}
+
+func TestDemotionStopsLeaderLoops(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, b.ID, b.Address)
+	}
+	before := len(goroutinesIn(leaderLoops))
+	b.ElectionMutex.Lock()
+	b.Term = 1
+	b.ElectionMutex.Unlock()
+	leading(t, b.Node)
+	b.startLeading()
+	for _, fn := range leaderLoops {
+		waitFor(t, fn+" to start", func() bool { return len(goroutinesIn([]string{fn})) > 0 })
+	}
+	spell := b.leaderContext()
+
+	// A claims term 2; B is demoted inside the RPC.
+	var ok bool
+	if err := (&NodeRPC{node: b.Node}).HandleCoordinator(BullyMessage{NodeID: a.ID, Rank: a.Rank, Term: 2, Address: a.Address}, &ok); err != nil || !ok {
+		t.Fatalf("HandleCoordinator: %v %v", ok, err)
+	}
+	if spell.Err() == nil {
+		t.Fatal("spell as coordinator still live after HandleCoordinator returned")
+	}
+	demoted := time.Now()
+	for len(goroutinesIn(leaderLoops)) > before {
+		if time.Since(demoted) > 100*time.Millisecond {
+			t.Fatalf("leader loops still running 100ms after demotion:\n%s", strings.Join(goroutinesIn(leaderLoops), "\n\n"))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if b.leaderContext().Err() == nil {
+		t.Error("leaderContext is live on a follower")
+	}
+
+	// Leading again starts a new spell; the old one stays cancelled.
+	b.ElectionMutex.Lock()
+	b.Term = 3
+	b.setCoordinatorLocked(b.ID)
+	b.ElectionMutex.Unlock()
+	if again := b.leaderContext(); again.Err() != nil || again == spell {
+		t.Errorf("second spell's context: %v, same as the first: %v", again.Err(), again == spell)
+	}
+	b.ElectionMutex.Lock()
+	b.setCoordinatorLocked("")
+	b.ElectionMutex.Unlock()
+	if b.leaderContext().Err() == nil {
+		t.Error("step-down left the spell running")
+	}
+}
@@ -0,0 +1,53 @@
+package node
+
+// lease.go — Leader lease: forget a coordinator whose heartbeats stopped.
+//
+// A follower used to forward to the last known coordinator until an
+// election replaced it, which takes --leader-timeout plus the election. A
+// crashed leader meant bidders waited for a dial or call to fail and then got
+// "leader unavailable"; a hung one held them for the full RPC timeout.
+//
+// Now every heartbeat from the leader, and every leader announcement, renews
+// a lease of twice --heartbeat-interval. Once it lapses getCoordinatorAddress
+// reports no coordinator, so every handler answers "election in progress" at
+// once (/bid after its forward retries, see submitBid, and with Retry-After)
+// and nothing is forwarded until the next heartbeat or a new leader's
+// announcement. CheckElectionTiming keeps --leader-timeout above two
+// heartbeat intervals, so the lease always lapses before the election starts. A lapse is logged once and counted in
+// leader_lease_expiries_total. The coordinator does not hold a lease on
+// itself.
+
+import (
+	"log"
+	"time"
+)
+
+// leaderLease is how long a heartbeat vouches for the leader.
+func (n *Node) leaderLease() time.Duration {
+	return 2 * n.HeartbeatInterval
+}
+
+// renewLeaderLease records contact with the current leader.
+func (n *Node) renewLeaderLease() {
+	n.lastLeaderContact.Store(time.Now().UnixNano())
+	n.leaseLapsed.Store(false)
+}
+
+// leaderLeaseHeld reports whether leader has been heard from within the
+// lease. The first check after a lapse logs it.
+func (n *Node) leaderLeaseHeld(leader string) bool {
+	last := n.lastLeaderContact.Load()
+	silent := time.Since(time.Unix(0, last))
+	if last > 0 && silent <= n.leaderLease() {
+		return true
+	}
+	if n.leaseLapsed.CompareAndSwap(false, true) {
+		heard := "never heard from"
+		if last > 0 {
+			heard = "silent for " + silent.Round(time.Millisecond).String()
+		}
+		log.Printf("[%s] ⏳ Leader lease on %s lapsed (%s); not forwarding until a leader is heard\n", n.ID, leader, heard)
+		n.Metrics.Inc("leader_lease_expiries_total")
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package node
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLapsedLeaseStopsForwarding(t *testing.T) {
+	addr, coord := serveFakeCoordinator(t)
+	n := withLotUp(followerOf(t, "C1", addr))
+	n.HeartbeatInterval = 50 * time.Millisecond
+	amount := 20
+	bid := func() *http.Response {
+		amount++
+		return postBid(n, url.Values{"bidder": {"b1"}, "amount": {strconv.Itoa(amount)}, "itemId": {"lot1"}}).Result()
+	}
+	expectForwards := func(want int32) {
+		t.Helper()
+		if got := coord.calls.Load(); got != want {
+			t.Errorf("coordinator got %d forwards, want %d", got, want)
+		}
+	}
+	lapse := func() {
+		time.Sleep(n.leaderLease() + 20*time.Millisecond)
+	}
+	leader := BullyMessage{NodeID: "C1", Rank: 2, Address: addr}
+	rp := &NodeRPC{node: n}
+
+	if resp := bid(); resp.StatusCode != http.StatusOK {
+		t.Fatalf("bid within the lease: %d", resp.StatusCode)
+	}
+	expectForwards(1)
+
+	// The heartbeats stop. Once the lease lapses the node has no leader to
+	// forward to, and after its retries tells the bidder to come back.
+	lapse()
+	if got, local := n.getCoordinatorAddress(); got != "" || local {
+		t.Errorf("getCoordinatorAddress = %q, %v after the lease lapsed", got, local)
+	}
+	resp := bid()
+	if resp.StatusCode != http.StatusServiceUnavailable || BidCode(resp.Header.Get("X-Bid-Outcome")) != BidNoLeader || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("bid after the lease lapsed: %d %s, Retry-After %q", resp.StatusCode, resp.Header.Get("X-Bid-Outcome"), resp.Header.Get("Retry-After"))
+	}
+	expectForwards(1)
+	if got := n.Metrics.Counter("leader_lease_expiries_total"); got != 1 {
+		t.Errorf("leader_lease_expiries_total = %v, want 1 per lapse", got)
+	}
+
+	// A heartbeat renews the lease, and so does a leader announcement. A
+	// bid waiting out the lapse goes through as soon as either arrives.
+	var ok bool
+	if err := rp.HandleHeartbeat(leader, &ok); err != nil || !ok {
+		t.Fatalf("heartbeat: %v %v", ok, err)
+	}
+	if resp := bid(); resp.StatusCode != http.StatusOK {
+		t.Errorf("bid after a heartbeat: %d", resp.StatusCode)
+	}
+	lapse()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		var ok bool
+		rp.HandleCoordinator(leader, &ok)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(n.HeartbeatInterval):
+				rp.HandleHeartbeat(leader, &ok)
+			}
+		}
+	}()
+	if resp := bid(); resp.StatusCode != http.StatusOK {
+		t.Errorf("bid across the announcement: %d", resp.StatusCode)
+	}
+	expectForwards(3)
+	if got := n.Metrics.Counter("leader_lease_expiries_total"); got != 2 {
+		t.Errorf("leader_lease_expiries_total = %v, want 2", got)
+	}
+}
@@ -0,0 +1,251 @@
+package node
+
+// lifecycle.go — Node lifecycle phases (Starting → Restoring → Syncing →
+// Ready → Draining → Stopped), /healthz, and the drain/stop path.
+//
+// Only a Ready node accepts bids or votes YES in 2PC. A node that has just
+// started or restored a checkpoint serves its (possibly stale) state read-only
+// until it has synced with the coordinator, or reconciled with its peers after
+// winning an election.
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NodePhase is where a node is in its lifecycle. It is unrelated to the
+// auction phase (idle, bidding, ...) reported in QueueSnapshot.Phase.
+type NodePhase string
+
+const (
+	PhaseStarting  NodePhase = "starting"
+	PhaseRestoring NodePhase = "restoring" // loading the local checkpoint
+	PhaseSyncing   NodePhase = "syncing"   // serving read-only until caught up
+	PhaseReady     NodePhase = "ready"
+	PhaseDraining  NodePhase = "draining" // finishing in-flight work; no new bids
+	PhaseStopped   NodePhase = "stopped"
+
+	PhaseQuarantined NodePhase = "quarantined" // refused a regression in strict mode; see strict.go
+)
+
+var allPhases = []NodePhase{PhaseStarting, PhaseRestoring, PhaseSyncing, PhaseReady, PhaseDraining, PhaseStopped, PhaseQuarantined}
+
+// drainTimeout bounds how long Stop waits for prepared transactions to resolve.
+const drainTimeout = 10 * time.Second
+
+// PhaseTransition is one entry of the lifecycle history in /healthz.
+type PhaseTransition struct {
+	Phase  NodePhase
+	AtUnix int64
+	Reason string
+}
+
+type lifecycle struct {
+	mu      sync.Mutex
+	phase   NodePhase
+	since   time.Time
+	spent   map[NodePhase]time.Duration // completed time per phase
+	history []PhaseTransition
+}
+
+func newLifecycle() *lifecycle {
+	now := time.Now()
+	return &lifecycle{
+		phase:   PhaseStarting,
+		since:   now,
+		spent:   map[NodePhase]time.Duration{},
+		history: []PhaseTransition{{Phase: PhaseStarting, AtUnix: now.Unix(), Reason: "process started"}},
+	}
+}
+
+// advance moves to next unless the lifecycle is already there or stopped.
+// It returns the phase that was left, and false if nothing changed.
+func (l *lifecycle) advance(next NodePhase, reason string) (NodePhase, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev := l.phase
+	if prev == next || prev == PhaseStopped {
+		return prev, false
+	}
+	now := time.Now()
+	l.spent[prev] += now.Sub(l.since)
+	l.phase, l.since = next, now
+	l.history = append(l.history, PhaseTransition{Phase: next, AtUnix: now.Unix(), Reason: reason})
+	return prev, true
+}
+
+// Phase returns the node's current lifecycle phase.
+func (n *Node) Phase() NodePhase {
+	n.lifecycle.mu.Lock()
+	defer n.lifecycle.mu.Unlock()
+	return n.lifecycle.phase
+}
+
+// IsReady reports whether the node may accept bids and take part in 2PC.
+func (n *Node) IsReady() bool {
+	return n.Phase() == PhaseReady
+}
+
+func (n *Node) setPhase(next NodePhase, reason string) {
+	prev, changed := n.lifecycle.advance(next, reason)
+	if !changed {
+		return
+	}
+	log.Printf("[%s] 🚦 Phase %s → %s (%s)\n", n.ID, prev, next, reason)
+	if prev == PhaseQuarantined {
+		n.Alerts.Resolve(regressionAlertKey, n.ID+" released from quarantine: "+reason)
+	}
+	if next == PhaseReady {
+		n.incidents.end("phase", "ready: "+reason)
+	} else if next != PhaseStarting {
+		n.incidents.begin("phase", "phase", SeverityInfo, n.ID+" not ready ("+string(next)+": "+reason+")")
+	}
+	n.Metrics.Inc(metricName("node_phase_transitions_total", "phase", string(next)))
+	n.refreshPhaseMetrics()
+}
+
+// markSynced promotes a syncing node to Ready. Draining nodes stay draining,
+// and a rebuilding node waits for its rebuild to finish. Historical events
+// are not part of the synced state, so missed ones are fetched separately.
+func (n *Node) markSynced(reason string) {
+	if n.Phase() == PhaseSyncing && !n.rebuildRunning() {
+		n.setPhase(PhaseReady, reason)
+		go n.catchUpEvents()
+	}
+}
+
+// refreshPhaseMetrics publishes the current phase and the time spent in each.
+func (n *Node) refreshPhaseMetrics() {
+	l := n.lifecycle
+	l.mu.Lock()
+	current := l.phase
+	spent := make(map[NodePhase]time.Duration, len(l.spent))
+	for p, d := range l.spent {
+		spent[p] = d
+	}
+	spent[current] += time.Since(l.since)
+	l.mu.Unlock()
+
+	for _, p := range allPhases {
+		active := 0.0
+		if p == current {
+			active = 1
+		}
+		n.Metrics.Set(metricName("node_phase", "phase", string(p)), active)
+		n.Metrics.Set(metricName("node_phase_seconds", "phase", string(p)), spent[p].Seconds())
+	}
+}
+
+// Drain stops the node taking new bids and votes while it keeps serving reads.
+func (n *Node) Drain(reason string) {
+	n.setPhase(PhaseDraining, reason)
+}
+
+// Resume undoes Drain, or releases a quarantined node. A follower re-syncs
+// before it is Ready again; the coordinator is the source of truth and
+// becomes Ready at once.
+func (n *Node) Resume() {
+	if p := n.Phase(); p != PhaseDraining && p != PhaseQuarantined {
+		return
+	}
+	if _, isLocal := n.getCoordinatorAddress(); isLocal {
+		n.setPhase(PhaseReady, "resumed as coordinator")
+		return
+	}
+	n.setPhase(PhaseSyncing, "resumed; re-syncing with coordinator")
+}
+
+// Stop drains the node, waits (bounded) for prepared transactions to be
+// decided, and saves a final checkpoint so a restart resumes cleanly.
+func (n *Node) Stop() {
+	n.Drain("shutdown requested")
+	deadline := time.Now().Add(drainTimeout)
+	for time.Now().Before(deadline) {
+		n.TxnMutex.Lock()
+		pending := len(n.PendingTxns)
+		n.TxnMutex.Unlock()
+		if pending == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := n.takeLocalCheckpoint(); err != nil {
+		log.Printf("[%s] Warning: final checkpoint failed: %v\n", n.ID, err)
+	}
+	n.Client.Close()
+	n.closeListeners()
+	n.setPhase(PhaseStopped, "shutdown complete")
+}
+
+// HealthStatus is the body of GET /healthz.
+type HealthStatus struct {
+	NodeID       string
+	Phase        NodePhase
+	Ready        bool
+	SinceUnix    int64
+	Coordinator  string
+	Term         int // election term
+	Transitions  []PhaseTransition
+	PhaseSeconds map[NodePhase]float64
+	Degraded     bool           // ready, but canary bid rounds keep failing
+	Canary       *CanaryStatus  `json:",omitempty"` // see canary.go
+	Rebuild      *RebuildStatus `json:",omitempty"` // latest /admin/rebuild; see rebuild.go
+}
+
+// handleHealthRequest serves GET /healthz: 200 when Ready, 503 otherwise, so
+// load balancers only route bidders to nodes that will take their bids. A
+// coordinator whose canary rounds keep failing is Degraded and also gets 503.
+func (n *Node) handleHealthRequest(w http.ResponseWriter, r *http.Request) {
+	l := n.lifecycle
+	l.mu.Lock()
+	status := HealthStatus{
+		NodeID:       n.ID,
+		Phase:        l.phase,
+		Ready:        l.phase == PhaseReady,
+		SinceUnix:    l.since.Unix(),
+		Transitions:  append([]PhaseTransition(nil), l.history...),
+		PhaseSeconds: map[NodePhase]float64{},
+	}
+	for p, d := range l.spent {
+		status.PhaseSeconds[p] = d.Seconds()
+	}
+	status.PhaseSeconds[l.phase] += time.Since(l.since).Seconds()
+	l.mu.Unlock()
+
+	n.ElectionMutex.Lock()
+	status.Coordinator = n.Coordinator
+	status.Term = n.Term
+	n.ElectionMutex.Unlock()
+	status.Rebuild = n.rebuildStatus()
+	if status.Canary = n.canaryStatus(); status.Canary != nil {
+		status.Degraded = status.Canary.Degraded
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Ready || status.Degraded {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// handleDrainRequest serves POST /admin/drain (action=drain|resume) for
+// maintenance: a drained node keeps serving reads but takes no bids.
+func (n *Node) handleDrainRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.FormValue("action") {
+	case "", "drain":
+		n.Drain("drained by operator")
+	case "resume":
+		n.Resume()
+	default:
+		http.Error(w, "action must be drain or resume", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"phase": n.Phase()})
+}
@@ -0,0 +1,149 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// phaseHistory lists the phases n has been through, oldest first.
+func phaseHistory(n *Node) []NodePhase {
+	n.lifecycle.mu.Lock()
+	defer n.lifecycle.mu.Unlock()
+	var out []NodePhase
+	for _, tr := range n.lifecycle.history {
+		out = append(out, tr.Phase)
+	}
+	return out
+}
+
+func expectPhases(t *testing.T, n *Node, want ...NodePhase) {
+	t.Helper()
+	if got := phaseHistory(n); !reflect.DeepEqual(got, want) {
+		t.Errorf("%s phases = %v, want %v", n.ID, got, want)
+	}
+}
+
+func healthz(t *testing.T, n *Node) (int, HealthStatus) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	n.handleHealthRequest(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var status HealthStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("/healthz: %d %s: %v", rec.Code, rec.Body, err)
+	}
+	return rec.Code, status
+}
+
+func TestLifecyclePhasesCleanStart(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	withLotUp(a.Node)
+	for _, tn := range nodes {
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	expectPhases(t, b.Node, PhaseStarting)
+
+	// Start listens before the node has synced; until then it is read-only.
+	b.setPhase(PhaseSyncing, "listening; waiting for first sync")
+	if ok, reason := b.canPrepareBid(BidArgs{BidderID: "b1", Amount: 50, ItemID: "lot1"}); ok || reason != RejectNodeNotReady {
+		t.Errorf("syncing node prepare = %v %s, want %s", ok, reason, RejectNodeNotReady)
+	}
+	if code, status := healthz(t, b.Node); code != http.StatusServiceUnavailable || status.Ready {
+		t.Errorf("/healthz while syncing = %d ready=%v, want 503", code, status.Ready)
+	}
+
+	if _, _, err := b.pullFromCoordinator(a.Address); err != nil {
+		t.Fatal(err)
+	}
+	expectPhases(t, b.Node, PhaseStarting, PhaseSyncing, PhaseReady)
+	code, status := healthz(t, b.Node)
+	if code != http.StatusOK || !status.Ready || len(status.Transitions) != 3 {
+		t.Errorf("/healthz when ready = %d ready=%v with %d transitions, want 200 with 3", code, status.Ready, len(status.Transitions))
+	}
+	if got := gauge(b.Metrics, metricName("node_phase", "phase", "ready")); got != 1 {
+		t.Errorf("node_phase{phase=ready} = %v, want 1", got)
+	}
+	if got := gauge(b.Metrics, metricName("node_phase", "phase", "syncing")); got != 0 {
+		t.Errorf("node_phase{phase=syncing} = %v, want 0", got)
+	}
+	if got := highestBid(b.Node); got != 10 {
+		t.Errorf("synced highest bid = %d, want the coordinator's 10", got)
+	}
+}
+
+func TestLifecyclePhasesCheckpointRestore(t *testing.T) {
+	n := biddingNode(t)
+	if err := n.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewNode(n.ID, n.Address, nil, 1)
+	expectPhases(t, restored, PhaseStarting, PhaseRestoring)
+	if restored.IsReady() {
+		t.Error("restored node is ready before it has synced")
+	}
+
+	restored.setPhase(PhaseSyncing, "listening; waiting for first sync")
+	restored.markSynced("test")
+	expectPhases(t, restored, PhaseStarting, PhaseRestoring, PhaseSyncing, PhaseReady)
+}
+
+func TestLifecyclePhasesDrain(t *testing.T) {
+	n := biddingNode(t)
+	setLeader(n, "L1", "127.0.0.1:1")
+	post := func(action string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/drain", strings.NewReader(url.Values{"action": {action}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		n.handleDrainRequest(rec, req)
+		return rec
+	}
+
+	if rec := post("drain"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"draining"`) {
+		t.Fatalf("drain: %d %s", rec.Code, rec.Body)
+	}
+	if ok, reason := n.canPrepareBid(BidArgs{BidderID: "b1", Amount: 50, ItemID: "lot1"}); ok || reason != RejectNodeNotReady {
+		t.Errorf("draining node prepare = %v %s, want %s", ok, reason, RejectNodeNotReady)
+	}
+	if code, _ := healthz(t, n); code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz while draining = %d, want 503", code)
+	}
+	// A sync does not end a drain.
+	n.markSynced("test")
+	if p := n.Phase(); p != PhaseDraining {
+		t.Errorf("phase after a sync while draining = %s", p)
+	}
+
+	// A follower re-syncs before it is ready again; a coordinator is ready at once.
+	post("resume")
+	if p := n.Phase(); p != PhaseSyncing {
+		t.Errorf("follower resumed into %s, want syncing", p)
+	}
+	n.markSynced("test")
+	post("drain")
+	setLeader(n, n.ID, n.Address)
+	post("resume")
+	if p := n.Phase(); p != PhaseReady {
+		t.Errorf("coordinator resumed into %s, want ready", p)
+	}
+	if rec := post("pause"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown action answered %d, want 400", rec.Code)
+	}
+
+	n.Stop()
+	expectPhases(t, n, PhaseStarting, PhaseReady, PhaseDraining, PhaseSyncing, PhaseReady,
+		PhaseDraining, PhaseReady, PhaseDraining, PhaseStopped)
+	if cp, err := loadCheckpoint(n.ID); err != nil || cp == nil {
+		t.Errorf("final checkpoint = %v, %v", cp, err)
+	}
+	// Nothing moves a stopped node.
+	n.Resume()
+	n.setPhase(PhaseReady, "test")
+	if p := n.Phase(); p != PhaseStopped {
+		t.Errorf("phase after stop = %s", p)
+	}
+}
@@ -0,0 +1,258 @@
+package node
+
+// membership.go — Runtime peer list and peer-assisted bootstrap (--join).
+//
+// A node started with --join <member> asks that member for the full peer
+// list, the current coordinator, the cluster's critical-settings hash, and
+// a state snapshot. The responder adds the joiner to its own membership and
+// announces it to everyone else. Learned membership is persisted in the
+// checkpoint so later restarts can bootstrap from any known peer.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+)
+
+type JoinArgs struct {
+	NodeID     string
+	Address    string // address peers should dial to reach the joiner
+	Rank       int
+	ConfigHash string
+	Version    VersionInfo
+}
+
+type JoinReply struct {
+	Accepted    bool
+	Message     string
+	Members     []string // every known member address, including the responder
+	Coordinator string
+	ConfigHash  string
+	Snapshot    QueueSnapshot
+	Version     VersionInfo // responder's build/protocol version
+
+	// CoordinatorAddress is where the coordinator can be reached, so a node
+	// that joined through a follower can forward bids before the first
+	// heartbeat. Empty from older builds.
+	CoordinatorAddress string
+
+	ConfigOverrides map[string]json.RawMessage // runtime config set via /admin/config
+	ConfigVersion   int
+}
+
+type MemberArgs struct {
+	Address string
+}
+
+// clusterConfigHash fingerprints settings every member must agree on.
+func clusterConfigHash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "quorum=majority;antiSnipe=%d;preparedTTL=%s;voteWait=%s",
+		antiSnipeWindow, preparedTxnTTL, voteWaitTimeout)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// peerList returns a copy of the current peer addresses.
+func (n *Node) peerList() []string {
+	n.peersMu.RLock()
+	defer n.peersMu.RUnlock()
+	return append([]string(nil), n.Peers...)
+}
+
+// HasPeers reports whether this node knows any other member.
+func (n *Node) HasPeers() bool {
+	n.peersMu.RLock()
+	defer n.peersMu.RUnlock()
+	return len(n.Peers) > 0
+}
+
+// isPeer reports whether address is a current member other than this node.
+func (n *Node) isPeer(address string) bool {
+	n.peersMu.RLock()
+	defer n.peersMu.RUnlock()
+	for _, p := range n.Peers {
+		if p == address {
+			return true
+		}
+	}
+	return false
+}
+
+// SingleNode reports whether this node currently has no peers. In that mode
+// it is its own coordinator, elections and RA are skipped, and quorum is 1;
+// it leaves the mode as soon as another node joins.
+func (n *Node) SingleNode() bool {
+	return !n.HasPeers()
+}
+
+// quorumFor is the 2PC majority for a cluster of peerCount peers plus self.
+func quorumFor(peerCount int) int {
+	return (peerCount+1)/2 + 1
+}
+
+// isSelfAddress reports whether address refers to this node.
+func (n *Node) isSelfAddress(address string) bool {
+	return address == n.Address || address == n.advertiseAddress()
+}
+
+// addPeer adds address to the membership. Returns false if already known.
+func (n *Node) addPeer(address string) bool {
+	if address == "" || n.isSelfAddress(address) {
+		return false
+	}
+	n.peersMu.Lock()
+	for _, p := range n.Peers {
+		if p == address {
+			n.peersMu.Unlock()
+			return false
+		}
+	}
+	n.Peers = append(n.Peers, address)
+	peers := append([]string(nil), n.Peers...)
+	n.peersMu.Unlock()
+
+	n.Mutex.UpdatePeers(peers)
+	log.Printf("[%s] ➕ Added cluster member %s (peers=%d)\n", n.ID, address, len(peers))
+	return true
+}
+
+// advertiseAddress is the address other members should use to reach us.
+// Wildcard binds (0.0.0.0, ::) fall back to localhost.
+func (n *Node) advertiseAddress() string {
+	if n.AdvertiseAddress != "" {
+		return n.AdvertiseAddress
+	}
+	host, port, err := net.SplitHostPort(n.Address)
+	if err != nil {
+		return n.Address
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// JoinCluster bootstraps membership and state from the first reachable
+// candidate: the given seeds, then any member remembered from a checkpoint.
+func (n *Node) JoinCluster(seeds []string) error {
+	candidates := append([]string(nil), seeds...)
+	candidates = append(candidates, n.peerList()...)
+	args := JoinArgs{NodeID: n.ID, Address: n.advertiseAddress(), Rank: n.Rank, ConfigHash: clusterConfigHash(), Version: n.versionInfo()}
+
+	var lastErr error
+	for _, candidate := range candidates {
+		if candidate == "" || n.isSelfAddress(candidate) {
+			continue
+		}
+		var reply JoinReply
+		if err := n.callPeer(candidate, "NodeRPC.JoinCluster", args, &reply); err != nil {
+			lastErr = err
+			log.Printf("[%s] Join via %s failed: %v\n", n.ID, candidate, err)
+			continue
+		}
+		if !reply.Accepted {
+			return fmt.Errorf("join rejected by %s: %s", candidate, reply.Message)
+		}
+		n.noteHandshakeVersion(candidate, reply.Version)
+
+		for _, member := range reply.Members {
+			n.addPeer(member)
+		}
+		if reply.Coordinator != "" {
+			n.ElectionMutex.Lock()
+			n.noteBullyAddressLocked(BullyMessage{NodeID: reply.Coordinator, Address: reply.CoordinatorAddress})
+			n.setCoordinatorLocked(reply.Coordinator)
+			n.renewLeaderLease()
+			n.ElectionMutex.Unlock()
+		}
+		n.applyQueueSnapshot(reply.Snapshot, candidate) // on failure the periodic sync retries
+		if reply.ConfigVersion > 0 {
+			if _, _, err := n.setConfigOverrides(reply.ConfigOverrides, reply.ConfigVersion, true); err != nil {
+				log.Printf("[%s] Warning: cluster config not applied: %v\n", n.ID, err)
+			}
+		}
+		log.Printf("[%s] 🤝 Joined cluster via %s (members=%d, coordinator=%s)\n",
+			n.ID, candidate, len(n.peerList()), reply.Coordinator)
+
+		// Persist the learned membership right away.
+		if err := n.takeLocalCheckpoint(); err != nil {
+			log.Printf("[%s] Warning: could not persist membership: %v\n", n.ID, err)
+		}
+		return nil
+	}
+	if lastErr == nil {
+		return fmt.Errorf("no seed or known peer to join through")
+	}
+	return fmt.Errorf("no member reachable: %w", lastErr)
+}
+
+// JoinCluster admits a new member and hands it the cluster view.
+func (rp *NodeRPC) JoinCluster(args JoinArgs, reply *JoinReply) error {
+	n := rp.node
+	reply.ConfigHash = clusterConfigHash()
+	reply.Version = n.versionInfo()
+	if args.ConfigHash != reply.ConfigHash {
+		reply.Accepted = false
+		reply.Message = fmt.Sprintf("config hash mismatch (member %s, joiner %s); check quorum/anti-snipe/TTL settings",
+			reply.ConfigHash, args.ConfigHash)
+		log.Printf("[%s] ⚠️ Rejected join from %s: %s\n", n.ID, args.NodeID, reply.Message)
+		n.captureDeadLetter("join", args.NodeID+" at "+args.Address, "config_mismatch", args, errors.New(reply.Message))
+		return nil
+	}
+	if msg := n.rejectDuplicateJoin(args); msg != "" {
+		reply.Accepted = false
+		reply.Message = msg
+		log.Printf("[%s] ⚠️ Rejected join from %s at %s: %s\n", n.ID, args.NodeID, args.Address, msg)
+		n.captureDeadLetter("join", args.NodeID+" at "+args.Address, "duplicate_node_id", args, errors.New(msg))
+		n.Alerts.Notify("duplicate_join_refused", SeverityCritical,
+			fmt.Sprintf("refused a second %s joining from %s: %s", args.NodeID, args.Address, msg))
+		return nil
+	}
+
+	n.noteHandshakeVersion(args.Address, args.Version)
+	n.Client.ResetBreaker(args.Address) // it just called us, so stop failing fast
+	others := n.peerList()
+	if n.addPeer(args.Address) {
+		for _, peer := range others {
+			p := peer
+			n.async.sendReliable(p, "AddMember", func() error {
+				var ok bool
+				return n.callPeer(p, "NodeRPC.AddMember", MemberArgs{Address: args.Address}, &ok)
+			})
+		}
+	}
+
+	members := []string{n.advertiseAddress()}
+	for _, p := range n.peerList() {
+		if p != args.Address {
+			members = append(members, p)
+		}
+	}
+
+	n.ElectionMutex.Lock()
+	reply.Coordinator = n.Coordinator
+	n.ElectionMutex.Unlock()
+	switch reply.Coordinator {
+	case "":
+	case n.ID:
+		reply.CoordinatorAddress = n.advertiseAddress()
+	default:
+		reply.CoordinatorAddress = n.peerAddressByID(reply.Coordinator)
+	}
+	reply.Accepted = true
+	reply.Members = members
+	reply.Snapshot = n.buildQueueSnapshot()
+	reply.ConfigOverrides, reply.ConfigVersion = n.configOverrides()
+	return nil
+}
+
+// AddMember is broadcast by the member that admitted a new node.
+func (rp *NodeRPC) AddMember(args MemberArgs, reply *bool) error {
+	rp.node.addPeer(args.Address)
+	*reply = true
+	return nil
+}
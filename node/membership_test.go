@@ -0,0 +1,59 @@
+package node
+
+import (
+	"context"
+	"testing"
+)
+
+func TestJoinRunningClusterAndBid(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+
+	// D knows only B, a follower.
+	d := startTestNode(t, "D", 4)
+	if err := d.JoinCluster([]string{b.Address}); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.peerList(); len(got) != 3 {
+		t.Fatalf("D learned peers %v, want the other three", got)
+	}
+	if addr, _ := d.getCoordinatorAddress(); addr != a.Address {
+		t.Fatalf("D resolves the coordinator to %q, want %s", addr, a.Address)
+	}
+	if got := highestBid(d.Node); got != 10 {
+		t.Errorf("D's highest bid after the join = %d, want 10 from the snapshot", got)
+	}
+	for _, tn := range nodes {
+		waitFor(t, tn.ID+" to hear of D", func() bool { return tn.isPeer(d.Address) })
+	}
+
+	reply := d.submitBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+	if reply.Code != BidCommitted {
+		t.Fatalf("bid through D = %s %q, want committed", reply.Code, reply.Message)
+	}
+	waitFor(t, "D to apply the bid", func() bool { return highestBid(d.Node) == 50 })
+
+	// A restart without --join comes back with the learned membership.
+	if got := NewNode("D", d.Address, nil, 4).peerList(); len(got) != 3 {
+		t.Errorf("restarted D has peers %v, want the three it learned", got)
+	}
+}
+
+func TestJoinRejectsConfigMismatch(t *testing.T) {
+	nodes := testCluster(t, "A")
+	var reply JoinReply
+	args := JoinArgs{NodeID: "D", Address: "127.0.0.1:9", Rank: 4, ConfigHash: "different"}
+	if err := (&NodeRPC{node: nodes[0].Node}).JoinCluster(args, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Accepted {
+		t.Fatal("join with a different config hash accepted")
+	}
+	if nodes[0].isPeer(args.Address) {
+		t.Error("refused joiner added to the membership")
+	}
+}
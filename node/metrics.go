@@ -0,0 +1,199 @@
+package node
+
+// metrics.go — Minimal in-process counters, gauges and histograms exposed in
+// Prometheus text format.
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metrics is a tiny registry of named counters, gauges and histograms.
+// Series names may carry Prometheus-style labels, built with metricName.
+type Metrics struct {
+	mu         sync.Mutex
+	counters   map[string]float64
+	gauges     map[string]float64
+	histograms map[string]*Histogram
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		counters:   map[string]float64{},
+		gauges:     map[string]float64{},
+		histograms: map[string]*Histogram{},
+	}
+}
+
+// latencyBuckets are the upper bounds, in seconds, of latency histograms.
+var latencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram counts observations into fixed buckets. Observe does not
+// allocate, so hot paths can record every event.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64 // shared, ascending
+	counts []uint64  // per bucket, not cumulative; last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// Observe records one value.
+func (h *Histogram) Observe(v float64) {
+	i := 0
+	for i < len(h.bounds) && v > h.bounds[i] {
+		i++
+	}
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+// Histogram returns the latency histogram called name, creating it on first
+// use. Callers on hot paths should keep the pointer rather than look it up
+// per event.
+func (m *Metrics) Histogram(name string) *Histogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.histograms[name]
+	if !ok {
+		h = &Histogram{bounds: latencyBuckets, counts: make([]uint64, len(latencyBuckets)+1)}
+		m.histograms[name] = h
+	}
+	return h
+}
+
+// Inc increments a counter by one.
+func (m *Metrics) Inc(name string) {
+	m.Add(name, 1)
+}
+
+// Add increments a counter by v.
+func (m *Metrics) Add(name string, v float64) {
+	m.mu.Lock()
+	m.counters[name] += v
+	m.mu.Unlock()
+}
+
+// Set overwrites a gauge.
+func (m *Metrics) Set(name string, v float64) {
+	m.mu.Lock()
+	m.gauges[name] = v
+	m.mu.Unlock()
+}
+
+// Counter returns the current value of a counter.
+func (m *Metrics) Counter(name string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+// metricName builds a labelled series name: metricName("x", "peer", "a") → x{peer="a"}.
+func metricName(base string, labels ...string) string {
+	if len(labels) < 2 {
+		return base
+	}
+	parts := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	return base + "{" + strings.Join(parts, ",") + "}"
+}
+
+// seriesBase strips the label set from a series name.
+func seriesBase(name string) string {
+	if i := strings.IndexByte(name, '{'); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// WritePrometheus renders all series in Prometheus text exposition format.
+func (m *Metrics) WritePrometheus(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeFamily(w, m.counters, "counter")
+	writeFamily(w, m.gauges, "gauge")
+	writeHistograms(w, m.histograms)
+}
+
+// withLabel appends label k="v" to a series name's label set.
+func withLabel(name, suffix, k, v string) string {
+	base, labels := seriesBase(name), ""
+	if i := strings.IndexByte(name, '{'); i >= 0 {
+		labels = name[i+1:len(name)-1] + ","
+	}
+	return fmt.Sprintf("%s%s{%s%s=%q}", base, suffix, labels, k, v)
+}
+
+func writeHistograms(w io.Writer, histograms map[string]*Histogram) {
+	names := make([]string, 0, len(histograms))
+	for name := range histograms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lastBase := ""
+	for _, name := range names {
+		base := seriesBase(name)
+		if base != lastBase {
+			fmt.Fprintf(w, "# TYPE %s histogram\n", base)
+			lastBase = base
+		}
+		h := histograms[name]
+		h.mu.Lock()
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%s %d\n", withLabel(name, "_bucket", "le", fmt.Sprintf("%g", bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s %d\n", withLabel(name, "_bucket", "le", "+Inf"), h.count)
+		suffix := name[len(base):]
+		fmt.Fprintf(w, "%s_sum%s %g\n", base, suffix, h.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", base, suffix, h.count)
+		h.mu.Unlock()
+	}
+}
+
+func writeFamily(w io.Writer, series map[string]float64, kind string) {
+	names := make([]string, 0, len(series))
+	for name := range series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lastBase := ""
+	for _, name := range names {
+		if base := seriesBase(name); base != lastBase {
+			fmt.Fprintf(w, "# TYPE %s %s\n", base, kind)
+			lastBase = base
+		}
+		fmt.Fprintf(w, "%s %g\n", name, series[name])
+	}
+}
+
+// handleMetricsRequest serves GET /metrics.
+func (n *Node) handleMetricsRequest(w http.ResponseWriter, r *http.Request) {
+	n.refreshDerivedMetrics()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	n.Metrics.WritePrometheus(w)
+}
+
+// refreshDerivedMetrics recomputes gauges that are derived from other series.
+func (n *Node) refreshDerivedMetrics() {
+	n.refreshMemoryMetrics()
+	n.refreshPhaseMetrics()
+	peers := len(n.peerList())
+	n.Metrics.Set("cluster_size", float64(peers+1))
+	n.Metrics.Set("quorum_size", float64(quorumFor(peers)))
+	hits := n.Metrics.Counter("state_cache_hits_total")
+	misses := n.Metrics.Counter("state_cache_misses_total")
+	if total := hits + misses; total > 0 {
+		n.Metrics.Set("state_cache_hit_ratio", hits/total)
+	}
+}
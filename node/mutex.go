@@ -0,0 +1,81 @@
+package node
+
+// mutex.go — The cluster-wide critical section and its two algorithms.
+//
+// Queue changes, bids and leadership transfers run inside one cluster-wide
+// critical section. Ricart–Agrawala (ricart_agrawala.go) needs a reply from
+// every peer, so one crashed node sends every entry through the
+// deferral-lease and timeout paths. The quorum algorithm (quorummutex.go)
+// needs votes from a majority only, and the token ring (tokenring.go) only
+// the token. --mutex picks one. Every node must run the same one: they do
+// not exclude each other, and a message of another kind raises a
+// mutex_mismatch alert.
+
+import (
+	"fmt"
+	"log"
+)
+
+// Mutual exclusion algorithms, for --mutex.
+const (
+	MutexRA     = "ra"
+	MutexQuorum = "quorum"
+	MutexToken  = "token"
+)
+
+// MutexManager is a cluster-wide critical section. RequestCS returns an
+// error, and then the caller must not call ReleaseCS, when the CS could not
+// be had in time. ReceiveRequest answers a peer's request: true grants it
+// now, false defers it until a later message.
+type MutexManager interface {
+	RequestCS() error
+	ReleaseCS()
+	ReceiveRequest(req RAMessage) bool
+	UpdatePeers(peers []string)
+}
+
+// ParseMutexKind checks a --mutex value.
+func ParseMutexKind(kind string) (string, error) {
+	switch kind {
+	case MutexRA, MutexQuorum, MutexToken:
+		return kind, nil
+	}
+	return "", fmt.Errorf("unknown mutual exclusion algorithm %q (want %s, %s or %s)", kind, MutexRA, MutexQuorum, MutexToken)
+}
+
+// SetMutex selects the mutual exclusion algorithm; call it before Start.
+// The others take the RA request timeout.
+func (n *Node) SetMutex(kind string) {
+	switch kind {
+	case MutexQuorum:
+		n.setQuorumMutex()
+	case MutexToken:
+		t := NewTokenRingManager(n.ID, n.Address, n.Rank, n.peerList(), n.Client)
+		t.RequestTimeout = n.RA.RequestTimeout
+		t.TokenTimeout = n.TokenTimeout
+		t.PeerRank = n.peerRankOf
+		t.OnTimeout = n.noteTokenTimeout
+		t.OnRegenerate = n.noteTokenRegenerated
+		n.Mutex, n.mutexKind = t, MutexToken
+	default:
+		n.Mutex, n.mutexKind = n.RA, MutexRA
+	}
+}
+
+func (n *Node) setQuorumMutex() {
+	q := NewQuorumMutex(n.ID, n.Address, n.peerList(), n.Clock, n.Client)
+	q.async = n.async
+	q.RequestTimeout = n.RA.RequestTimeout
+	q.ResolvePeer = n.peerAddressByID
+	q.OnTimeout = n.noteRATimeout
+	n.Mutex, n.mutexKind = q, MutexQuorum
+}
+
+// noteMutexMismatch reports a request from a peer running the other
+// algorithm.
+func (n *Node) noteMutexMismatch(from, kind string) {
+	msg := fmt.Sprintf("%s sent a %s mutual exclusion request, but %s runs --mutex=%s; they do not exclude each other",
+		from, kind, n.ID, n.mutexKind)
+	log.Printf("[%s] ⚠️  %s\n", n.ID, msg)
+	n.Alerts.Notify("mutex_mismatch", SeverityCritical, msg)
+}
@@ -0,0 +1,152 @@
+package node
+
+// mutexstate.go — GET /mutex: who is waiting on whom in the critical section.
+//
+// When the cluster wedges, typically with every node stuck in RequestCS, one
+// node's /admin/inflight shows only its own side. GET /mutex reports this
+// node's mutual exclusion state; GET /mutex?cluster=true asks every peer for
+// theirs in parallel, as /topology does, and adds the waits-for edges: each
+// requesting node points at the peers whose reply it still lacks. A cycle in
+// those edges is a deadlock; an edge to an unreachable node is a crash the
+// lease and timeout paths have not caught up with yet. Nothing is cached, so
+// every call shows the state as it is.
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MutexState is one node's mutual exclusion state.
+type MutexState struct {
+	NodeID  string           `json:"nodeId"`
+	Address string           `json:"address"`
+	Mutex   string           `json:"mutex"` // --mutex in use
+	Lamport int              `json:"lamport"`
+	RA      RAStatus         `json:"ra"`
+	Quorum  *QuorumStatus    `json:"quorum,omitempty"`
+	Token   *TokenRingStatus `json:"token,omitempty"`
+}
+
+// MutexMember is one row of GET /mutex?cluster=true.
+type MutexMember struct {
+	Address   string      `json:"address"`
+	Reachable bool        `json:"reachable"`
+	Error     string      `json:"error,omitempty"`
+	State     *MutexState `json:"state,omitempty"`
+}
+
+// ClusterMutexState is the body of GET /mutex?cluster=true.
+type ClusterMutexState struct {
+	GeneratedAtUnix int64               `json:"generatedAtUnix"`
+	ServedBy        string              `json:"servedBy"`
+	Members         []MutexMember       `json:"members"`
+	WaitsFor        map[string][]string `json:"waitsFor"` // requester address -> peers it lacks a reply from
+}
+
+func (n *Node) mutexState() MutexState {
+	s := MutexState{
+		NodeID:  n.ID,
+		Address: n.Address,
+		Mutex:   n.mutexKind,
+		Lamport: n.Clock.Get(),
+		RA:      n.RA.Status(),
+	}
+	if q, ok := n.Mutex.(*QuorumMutex); ok {
+		qs := q.Status()
+		s.Quorum = &qs
+	}
+	if t, ok := n.Mutex.(*TokenRingManager); ok {
+		ts := t.Status()
+		s.Token = &ts
+	}
+	return s
+}
+
+// GetMutexState returns this node's mutual exclusion state for a peer's
+// GET /mutex?cluster=true.
+func (rp *NodeRPC) GetMutexState(_ EmptyArgs, reply *MutexState) error {
+	*reply = rp.node.mutexState()
+	return nil
+}
+
+// fetchMutexState asks one peer for its state, giving up after
+// topologyPeerTimeout. Like fetchNodeStatus it bypasses callPeer.
+func (n *Node) fetchMutexState(address string) MutexMember {
+	member := MutexMember{Address: address}
+	type result struct {
+		state MutexState
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var state MutexState
+		err := n.Client.Call(address, "NodeRPC.GetMutexState", EmptyArgs{}, &state)
+		done <- result{state, err}
+	}()
+	select {
+	case res := <-done:
+		if res.err != nil {
+			member.Error = res.err.Error()
+			return member
+		}
+		member.Reachable = true
+		member.State = &res.state
+		// gob drops empty slices; keep the JSON shape of a local answer.
+		if member.State.RA.DeferredPeers == nil {
+			member.State.RA.DeferredPeers = []string{}
+		}
+		if member.State.RA.DeferredBy == nil {
+			member.State.RA.DeferredBy = []string{}
+		}
+	case <-time.After(topologyPeerTimeout):
+		member.Error = "timed out after " + topologyPeerTimeout.String()
+	}
+	return member
+}
+
+// clusterMutexState gathers every member's state.
+func (n *Node) clusterMutexState() ClusterMutexState {
+	peers := n.peerList()
+	members := make([]MutexMember, len(peers)+1)
+	self := n.mutexState()
+	members[0] = MutexMember{Address: n.Address, Reachable: true, State: &self}
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			members[i+1] = n.fetchMutexState(addr)
+		}(i, peer)
+	}
+	wg.Wait()
+
+	c := ClusterMutexState{
+		GeneratedAtUnix: time.Now().Unix(),
+		ServedBy:        n.ID,
+		Members:         members,
+		WaitsFor:        map[string][]string{},
+	}
+	for _, m := range members {
+		if !m.Reachable || !m.State.RA.Requesting || m.State.RA.InCriticalSection {
+			continue
+		}
+		if awaiting := m.State.RA.Awaiting; len(awaiting) > 0 {
+			c.WaitsFor[m.Address] = awaiting
+		}
+	}
+	return c
+}
+
+// handleMutexRequest serves GET /mutex and GET /mutex?cluster=true.
+func (n *Node) handleMutexRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Query().Get("cluster") != "true" {
+		writeJSON(w, n.mutexState())
+		return
+	}
+	writeJSON(w, n.clusterMutexState())
+}
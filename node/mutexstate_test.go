@@ -0,0 +1,82 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// getMutex serves GET /mutex+query from n into body.
+func getMutex(t *testing.T, n *Node, query string, body any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	n.handleMutexRequest(rec, httptest.NewRequest(http.MethodGet, "/mutex"+query, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /mutex%s: %d %s", query, rec.Code, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMutexStateShowsPendingRequest(t *testing.T) {
+	nodes := testCluster(t, "H", "A", "S")
+	h, a, s := nodes[0], nodes[1], nodes[2]
+	// A holds the CS, and H's request never reaches S.
+	if err := a.RA.RequestCS(); err != nil {
+		t.Fatal(err)
+	}
+	h.Client.SetLatency(LatencyRule{Peer: s.Address, DelayMs: 60_000})
+	hDone := requestCS(h.RA)
+	waitFor(t, "A to defer H", func() bool { return len(h.RA.Status().DeferredBy) == 1 })
+
+	var local MutexState
+	getMutex(t, h.Node, "", &local)
+	ra := local.RA
+	if local.NodeID != "H" || local.Mutex != MutexRA || !ra.Requesting || ra.InCriticalSection || ra.RequestTime == 0 {
+		t.Errorf("H's state = %+v, want a pending RA request", local)
+	}
+	if ra.RepliesOutstanding != 2 || len(ra.Awaiting) != 2 || len(ra.DeferredBy) != 1 || ra.DeferredBy[0] != a.Address {
+		t.Errorf("H's RA state = %+v, want replies from A (deferred) and S outstanding", ra)
+	}
+
+	// From A, the cluster view has H waiting on A and S.
+	var cluster ClusterMutexState
+	getMutex(t, a.Node, "?cluster=true", &cluster)
+	if cluster.ServedBy != "A" || len(cluster.Members) != 3 {
+		t.Fatalf("cluster view = %+v", cluster)
+	}
+	for _, m := range cluster.Members {
+		if !m.Reachable || m.State == nil {
+			t.Errorf("member %s unreachable: %s", m.Address, m.Error)
+		}
+	}
+	if got := cluster.WaitsFor[h.Address]; len(got) != 2 || len(cluster.WaitsFor) != 1 {
+		t.Errorf("waitsFor = %v, want only H, waiting on A and S", cluster.WaitsFor)
+	}
+	if self := cluster.Members[0].State; !self.RA.InCriticalSection || len(self.RA.DeferredPeers) != 1 || self.RA.DeferredPeers[0] != h.Address {
+		t.Errorf("A's own row = %+v, want it inside and owing H", self.RA)
+	}
+
+	// A dead member is listed with its error.
+	s.kill()
+	getMutex(t, a.Node, "?cluster=true", &cluster)
+	for _, m := range cluster.Members {
+		if m.Address == s.Address && (m.Reachable || m.Error == "") {
+			t.Errorf("dead S listed as %+v", m)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.handleMutexRequest(rec, httptest.NewRequest(http.MethodPost, "/mutex", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /mutex: %d", rec.Code)
+	}
+
+	a.RA.ReleaseCS()
+	h.RA.UpdatePeers([]string{a.Address})
+	awaitCS(t, "H", hDone)
+	h.RA.ReleaseCS()
+	h.Client.SetLatency(LatencyRule{Peer: s.Address})
+}
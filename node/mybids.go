@@ -0,0 +1,226 @@
+package node
+
+// mybids.go — GET /me/bids: a bidder's own bids and what became of them.
+//
+// Every node keeps a bid book indexed by bidder ID. A committed bid is
+// entered when the node applies the commit decision, which every
+// participant does, so any synced node can answer and a leader failover
+// loses nothing already committed. Rejected bids are entered as receipts
+// by the node that took the HTTP request, so a bidder sees their own
+// rejections on the node they bid through. The book is saved with the
+// local checkpoint and kept out of /checkpoint. A node that was down while
+// bids committed does not see them.
+//
+// Whether a committed bid is still leading, won or was outbid is worked out
+// at read time from the current item and the results, so the book itself
+// only ever grows.
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxBidsPerBidder   = 200 // oldest entries are dropped first
+	defaultMyBidsLimit = 50
+	maxMyBidsLimit     = 200
+)
+
+// BidRecord is one entry of the bid book. Status is filled in per request.
+type BidRecord struct {
+	TxnID    string  `json:"txnId,omitempty"` // empty for rejections
+	ItemID   string  `json:"itemId"`
+	ItemName string  `json:"itemName,omitempty"`
+	Amount   int     `json:"amount"`
+	Name     string  `json:"name"` // display name used for the bid
+	AtUnix   int64   `json:"atUnix"`
+	Outcome  BidCode `json:"outcome"` // committed, or why it was rejected
+	Message  string  `json:"message,omitempty"`
+	Status   string  `json:"status,omitempty"` // leading, won, outbid, closed or rejected
+
+	// Advisory; see bidtiming.go.
+	ClientAtMs   int64 `json:"clientAtMs,omitempty"`   // client-claimed submission time
+	ReceivedAtMs int64 `json:"receivedAtMs,omitempty"` // receive time on the node the bid went through
+	DelayMs      int64 `json:"delayMs,omitempty"`      // receivedAtMs - clientAtMs
+	SkewFlagged  bool  `json:"skewFlagged,omitempty"`  // claim more than 30s off, or unusable
+
+	// Attribution; see origin.go. Empty for bids from older builds.
+	Origin      string `json:"origin,omitempty"`      // node that took the HTTP request
+	Coordinator string `json:"coordinator,omitempty"` // node that ran 2PC; commits only
+	Term        int    `json:"term,omitempty"`        // its election term
+}
+
+type bidBook struct {
+	mu       sync.Mutex
+	byBidder map[string][]BidRecord
+	txns     map[string]bool // committed txn IDs already entered
+}
+
+// add enters rec for bidderID unless its txn is already there.
+func (b *bidBook) add(bidderID string, rec BidRecord) {
+	if bidderID == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.byBidder == nil {
+		b.byBidder = map[string][]BidRecord{}
+		b.txns = map[string]bool{}
+	}
+	if rec.TxnID != "" {
+		if b.txns[rec.TxnID] {
+			return
+		}
+		b.txns[rec.TxnID] = true
+	}
+	recs := append(b.byBidder[bidderID], rec)
+	if len(recs) > maxBidsPerBidder {
+		for _, old := range recs[:len(recs)-maxBidsPerBidder] {
+			delete(b.txns, old.TxnID)
+		}
+		recs = append([]BidRecord(nil), recs[len(recs)-maxBidsPerBidder:]...)
+	}
+	b.byBidder[bidderID] = recs
+}
+
+// has reports whether the book holds the bid committed by txnID.
+func (b *bidBook) has(txnID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.txns[txnID]
+}
+
+// page returns the total and up to limit records, newest first, skipping
+// the newest offset.
+func (b *bidBook) page(bidderID string, offset, limit int) (int, []BidRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	recs := b.byBidder[bidderID]
+	out := []BidRecord{}
+	for i := len(recs) - 1 - offset; i >= 0 && len(out) < limit; i-- {
+		out = append(out, recs[i])
+	}
+	return len(recs), out
+}
+
+// snapshot copies the book for a checkpoint.
+func (b *bidBook) snapshot() map[string][]BidRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.byBidder) == 0 {
+		return nil
+	}
+	out := make(map[string][]BidRecord, len(b.byBidder))
+	for id, recs := range b.byBidder {
+		out[id] = append([]BidRecord(nil), recs...)
+	}
+	return out
+}
+
+// restore loads a book saved by snapshot.
+func (b *bidBook) restore(saved map[string][]BidRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.byBidder = map[string][]BidRecord{}
+	b.txns = map[string]bool{}
+	for id, recs := range saved {
+		b.byBidder[id] = recs
+		for _, rec := range recs {
+			if rec.TxnID != "" {
+				b.txns[rec.TxnID] = true
+			}
+		}
+	}
+}
+
+// recordBid enters a bid that reached a decision or was turned away.
+func (n *Node) recordBid(txnID string, bid BidArgs, outcome BidCode, message string) {
+	rec := BidRecord{
+		TxnID:   txnID,
+		ItemID:  bid.ItemID,
+		Amount:  bid.Amount,
+		Name:    bid.DisplayName,
+		AtUnix:  time.Now().Unix(),
+		Outcome: outcome,
+
+		ClientAtMs:   bid.ClientAtMs,
+		ReceivedAtMs: bid.ReceivedAtMs,
+		SkewFlagged:  bid.ClientSkewFlagged,
+
+		Origin:      bid.Origin,
+		Coordinator: bid.Coordinator,
+		Term:        bid.Term,
+	}
+	if bid.ClientAtMs > 0 {
+		rec.DelayMs = bid.ReceivedAtMs - bid.ClientAtMs
+	}
+	if outcome != BidCommitted {
+		rec.Message = message
+	}
+	n.Queue.mu.Lock()
+	if item := n.Queue.CurrentItem; item != nil && (rec.ItemID == "" || item.ID == rec.ItemID) {
+		rec.ItemID, rec.ItemName = item.ID, item.Name
+	}
+	n.Queue.mu.Unlock()
+	n.bids.add(bid.BidderID, rec)
+}
+
+// bidStatusLocked says what became of a committed bid. Must hold Queue.mu.
+func (n *Node) bidStatusLocked(bidderID string, rec BidRecord) string {
+	if rec.Outcome != BidCommitted {
+		return "rejected"
+	}
+	q := n.Queue
+	if q.CurrentItem != nil && q.CurrentItem.ID == rec.ItemID {
+		if q.CurrentWinnerID == bidderID && q.CurrentHighestBid == rec.Amount {
+			return "leading"
+		}
+		return "outbid"
+	}
+	for i := len(q.Results) - 1; i >= 0; i-- {
+		if res := q.Results[i]; res.Item.ID == rec.ItemID {
+			if res.WinnerID == bidderID && res.WinningBid == rec.Amount {
+				return "won"
+			}
+			return "outbid"
+		}
+	}
+	return "closed" // result archived or not yet synced here
+}
+
+// handleMyBidsRequest serves GET /me/bids?offset=0&limit=50 for the caller's
+// bidder_id cookie, newest first.
+func (n *Node) handleMyBidsRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = defaultMyBidsLimit
+	}
+	limit = min(limit, maxMyBidsLimit)
+
+	var bidderID string
+	if c, err := r.Cookie(bidderIDCookie); err == nil {
+		bidderID = c.Value
+	}
+	total, bids := n.bids.page(bidderID, offset, limit)
+	n.Queue.mu.Lock()
+	for i := range bids {
+		bids[i].Status = n.bidStatusLocked(bidderID, bids[i])
+	}
+	n.Queue.mu.Unlock()
+	writeJSON(w, struct {
+		BidderID string      `json:"bidderId"`
+		Total    int         `json:"total"`
+		Offset   int         `json:"offset"`
+		Bids     []BidRecord `json:"bids"`
+	}{bidderID, total, offset, bids})
+}
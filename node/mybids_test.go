@@ -0,0 +1,114 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type myBidsPage struct {
+	BidderID string      `json:"bidderId"`
+	Total    int         `json:"total"`
+	Offset   int         `json:"offset"`
+	Bids     []BidRecord `json:"bids"`
+}
+
+func myBidsOn(t *testing.T, n *Node, bidderID, query string) myBidsPage {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me/bids"+query, nil)
+	req.AddCookie(&http.Cookie{Name: bidderIDCookie, Value: bidderID})
+	n.handleMyBidsRequest(rec, req)
+	var page myBidsPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("/me/bids on %s: %d %s", n.ID, rec.Code, rec.Body)
+	}
+	return page
+}
+
+// summary renders bids as "amount:outcome:status", newest first.
+func summary(bids []BidRecord) []string {
+	out := make([]string, len(bids))
+	for i, b := range bids {
+		out[i] = fmt.Sprintf("%d:%s:%s", b.Amount, b.Outcome, b.Status)
+	}
+	return out
+}
+
+func TestMyBidsAcrossFailover(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	// Every bid goes through /bid on C.
+	bid := func(bidder string, amount int) BidCode {
+		t.Helper()
+		form := url.Values{"bidder": {bidder}, "amount": {fmt.Sprint(amount)}, "itemId": {"lot1"}}
+		req := httptest.NewRequest(http.MethodPost, "/bid", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: bidderIDCookie, Value: bidder})
+		rec := httptest.NewRecorder()
+		c.handleBidRequest(rec, req)
+		return BidCode(rec.Header().Get("X-Bid-Outcome"))
+	}
+
+	for _, step := range []struct {
+		bidder string
+		amount int
+		code   BidCode
+	}{{"b1", 20, BidCommitted}, {"b1", 15, BidOutbid}, {"b1", 30, BidCommitted}, {"b2", 40, BidCommitted}} {
+		if got := bid(step.bidder, step.amount); got != step.code {
+			t.Fatalf("%s at $%d = %s, want %s", step.bidder, step.amount, got, step.code)
+		}
+	}
+
+	// A crashes and comes back empty; B takes over and b1 bids again.
+	a.kill()
+	for _, tn := range nodes[1:] {
+		setLeader(tn.Node, b.ID, b.Address)
+	}
+	leading(t, b.Node)
+	a = a.restart(t)
+	if got := bid("b1", 50); got != BidCommitted {
+		t.Fatalf("b1 after failover = %s", got)
+	}
+	waitNoGoroutines(t, bidRoundGoroutines)
+
+	// C took every request, so it also holds b1's rejection.
+	want := []string{"50:committed:leading", "30:committed:outbid", "15:outbid:rejected", "20:committed:outbid"}
+	if got := myBidsOn(t, c.Node, "b1", ""); got.Total != 4 || !reflect.DeepEqual(summary(got.Bids), want) {
+		t.Errorf("b1 on C = %d %v, want %v", got.Total, summary(got.Bids), want)
+	}
+	first, rest := myBidsOn(t, c.Node, "b1", "?limit=2"), myBidsOn(t, c.Node, "b1", "?offset=2&limit=2")
+	if first.Total != 4 || !reflect.DeepEqual(summary(append(first.Bids, rest.Bids...)), want) {
+		t.Errorf("b1 on C in pages of 2 = %v then %v", summary(first.Bids), summary(rest.Bids))
+	}
+
+	// The new leader saw every commit, whichever node coordinated it.
+	got := myBidsOn(t, b.Node, "b1", "")
+	if !reflect.DeepEqual(summary(got.Bids), []string{"50:committed:leading", "30:committed:outbid", "20:committed:outbid"}) {
+		t.Errorf("b1 on B = %v", summary(got.Bids))
+	}
+	if coords := []string{got.Bids[0].Coordinator, got.Bids[1].Coordinator}; !reflect.DeepEqual(coords, []string{b.ID, a.ID}) {
+		t.Errorf("coordinators = %v, want B after the failover and A before", coords)
+	}
+
+	// Once lot1 closes, b1's last bid won it.
+	b.Queue.mu.Lock()
+	b.finalizeCurrentItemLocked()
+	b.Queue.mu.Unlock()
+	if got := myBidsOn(t, b.Node, "b1", "?limit=1"); !reflect.DeepEqual(summary(got.Bids), []string{"50:committed:won"}) {
+		t.Errorf("b1 on B after the close = %v", summary(got.Bids))
+	}
+	if got := myBidsOn(t, b.Node, "nobody", ""); got.Total != 0 || got.Bids == nil {
+		t.Errorf("unknown bidder = %+v, want an empty list", got)
+	}
+}
@@ -3,47 +3,146 @@ package node
 // node.go — Node struct definition, constructor, and HTTP server startup.
 
 import (
-	"fmt"
+	"context"
+	"crypto/tls"
+	"errors"
 	"log"
 	"net"
 	"net/http"
 	"net/rpc"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	voteWaitTimeout          = 2500 * time.Millisecond
-	decisionAckWaitTimeout   = 2500 * time.Millisecond
-	decisionAckRetryInterval = 2 * time.Second
-	decisionAckMaxRetries    = 5
-	preparedTxnTTL           = 8 * time.Second
+	voteWaitTimeout        = 2500 * time.Millisecond
+	decisionAckWaitTimeout = 2500 * time.Millisecond
+	preparedTxnTTL         = 8 * time.Second
 )
 
 // Node is the main distributed auction node.
 type Node struct {
-	ID            string
-	Address       string
-	Peers         []string
-	Queue         *ItemQueueState
-	Clock         *LamportClock
-	RA            *RAManager
-	Client        *RPCClient
-	Rank          int
-	Coordinator   string
-	ElectionMutex sync.Mutex
-	LeaderChan    chan bool
-	TxnMutex      sync.Mutex
-	PendingTxns   map[string]PendingTxn
-	TxnLogMutex   sync.Mutex
-	DepMutex      sync.Mutex
-	Dependencies  map[string]bool
-	KTMutex       sync.Mutex
-	KTRounds      map[string]*KTRoundState
-	CkptMutex     sync.Mutex
-	CkptInFlight  bool
+	ID               string
+	Address          string
+	AdvertiseAddress string      // address peers dial; derived from Address if empty
+	HTTPAddress      string      // public UI/API listener; empty serves it on Address alongside RPC
+	AdminAddress     string      // operator endpoints listener (--admin-port); empty serves them on the public one
+	TLS              *ClusterTLS // set by UseTLS; nil serves and dials plain TCP
+	Peers            []string    // guarded by peersMu; read via peerList()
+	Queue            *ItemQueueState
+	Clock            *LamportClock
+	RA               *RAManager
+	Mutex            MutexManager // RA unless --mutex=quorum; see mutex.go
+	Client           *RPCClient
+	caller           peerCaller // makes callPeer's calls; nil uses Client, see dependency.go
+	Rank             int
+	Coordinator      string
+	Term             int               // highest election term seen; guarded by ElectionMutex
+	bullyAddrs       map[string]string // node ID → RPC address from Bully messages; guarded by ElectionMutex
+	ElectionMutex    sync.Mutex
+	leaderCtx        context.Context    // current spell as coordinator; guarded by ElectionMutex, see leaderrole.go
+	leaderCancel     context.CancelFunc // guarded by ElectionMutex
+	leaderTerm       int                // term this spell as coordinator began in; guarded by ElectionMutex
+	rivalLeaderAt    time.Time          // last leader traffic from another node while coordinator; see leaderlock.go
+	LeaderChan       chan bool
+	TxnMutex         sync.Mutex
+	PendingTxns      map[string]PendingTxn
+	decisions        *decisionLog    // decisions applied here, for QueryDecision; see decisionlog.go
+	deliveries       deliveryTracker // decisions some peers have not acknowledged; see delivery.go
+	TxnLogMutex      sync.Mutex
+	DepMutex         sync.Mutex
+	Dependencies     map[string]bool
+	KTMutex          sync.Mutex
+	KTRounds         map[string]*KTRoundState
+	CkptMutex        sync.Mutex
+	CkptInFlight     bool
+	Metrics          *Metrics
+	Alerts           *AlertBus
+	// CheckpointHistory is how many committed checkpoints to keep under
+	// checkpoints/history for /admin/state-at (0 = none).
+	CheckpointHistory int
+	CanaryInterval    time.Duration // 0 disables canary rounds; see canary.go
+	// AutoProfileThreshold profiles the node when a bid runs longer than
+	// this (0 = off); see profiles.go.
+	AutoProfileThreshold time.Duration
+	AdminToken           string        // required by /admin/profiles; empty disables it
+	ItemsURL             string        // --items-url: catalogue the first coordinator seeds from
+	ItemsSHA256          string        // --items-sha256: expected digest of ItemsURL
+	FaultInjection       bool          // --enable-fault-injection: serves /admin/latency
+	StrictConsistency    bool          // --strict-consistency: quarantine on state regressions; see strict.go
+	HeartbeatInterval    time.Duration // --heartbeat-interval: how often the leader sends heartbeats
+	LeaderTimeout        time.Duration // --leader-timeout: silence after which a follower starts an election
+	ElectionWait         time.Duration // --election-wait: how long a candidate waits for an OK
+	MajorityLossWindow   time.Duration // --majority-loss-window: leader steps down after this long without a majority (0 = never)
+	FailoverGrace        time.Duration // --failover-grace: re-open a lot that expired during a failover for this long (0 = --no-grace)
+	WinnerDisplay        WinnerDisplay // --winner-display: how public views name bidders; see paddles.go
+	LateVoteGrace        time.Duration // --late-vote-grace: how long votes are drained after a decision; see latevotes.go
+	TokenTimeout         time.Duration // --token-timeout: how long the --mutex=token token may go unseen; see tokenring.go
+
+	peersMu       sync.RWMutex
+	stateCache    stateCache
+	stateCacheV1  stateCache
+	topology      topologyCache             // see topology.go
+	bidStages     bidStageHistograms        // see bidlatency.go
+	canaryStages  bidStageHistograms        // the same stages for canary rounds
+	async         *asyncDispatcher          // fire-and-forget RPCs; see dispatch.go
+	mutexKind     string                    // --mutex
+	serialize     string                    // --serialize; see leaderlock.go
+	leaderLock    sync.Mutex                // leader-lock mode's critical section
+	view          atomic.Pointer[queueView] // copy-on-write snapshot; see queueView()
+	bidDedup      bidDeduper
+	rounds        txnRounds // coordinated bids in progress; see inflight.go
+	bids          bidBook   // per-bidder bid history for /me/bids
+	canary        canaryState
+	profiler      autoProfiler
+	rebuild       rebuildState    // see rebuild.go
+	bootstrap     bootstrapServer // rebuild bundles being served, as coordinator
+	clusterSecret []byte          // signs and checks RPC; see rpcauth.go
+	eventsMu      sync.Mutex      // serializes writes to the events archive
+	queueOps      sync.Mutex      // admin queue mutations and item transitions; see queueops.go
+	followerSync  followerSync    // state versions held and heard; see followersync.go
+	bidForwards   atomic.Int64    // bids forwarded to the coordinator, awaiting a reply
+	versionsMu    sync.Mutex
+	peerVersions  map[string]PeerVersion
+	txnLogLines   int // cached line count of the txn log; -1 until counted
+
+	configMu      sync.RWMutex
+	config        runtimeConfigState // see config.go
+	configSyncing atomic.Bool
+
+	takeoverRunning atomic.Bool  // see maybeTakeOver
+	electionRunning atomic.Bool  // see StartElection
+	electionQueued  atomic.Bool  // a trigger arrived while electionRunning
+	transferring    atomic.Bool  // a leadership transfer is running; see transfer.go
+	coldStart       atomic.Bool  // booted without a checkpoint; see catalogue.go
+	withheldRounds  atomic.Int32 // election rounds in a row that lacked a quorum; see runElection
+
+	lastLeaderContact atomic.Int64 // UnixNano of the last leader heartbeat or announcement; see lease.go
+	leaseLapsed       atomic.Bool
+	offeringResults   atomic.Bool // see splitbrain.go
+	splitBrainMu      sync.Mutex
+	splitBrainSeen    map[string]bool       // losing results already reported
+	heldResults       map[string]heldResult // foreign results for the open lot; guarded by Queue.mu
+	lateVotes         lateVoteDrains
+
+	listenersMu sync.Mutex
+	listeners   []net.Listener // HTTP listeners Stop closes; see adminport.go
+	stopping    atomic.Bool
+
+	healthMu           sync.Mutex // guards the alert detectors' state below
+	peerLastSeen       map[string]time.Time
+	electionTimes      []time.Time
+	checkpointFailures int
+	leaderSince        time.Time
+	lastLeaderSeen     string        // coordinator at the last health tick; see noteLeaderChange
+	incidents          *incidentLog  // see incidents.go
+	electionLog        electionLog   // see electionlog.go
+	deadLetters        deadLetterBox // see deadletters.go
+	readLimiter        *ipRateLimiter
+	httpGate           *httpGate
+	lifecycle          *lifecycle // see lifecycle.go; read via Phase()
 }
 
 type KTRoundState struct {
@@ -66,18 +165,36 @@ func NewNode(id, address string, peers []string, rank int) *Node {
 	peers = sanitizePeers(peers, address)
 	clock := &LamportClock{}
 	client := &RPCClient{}
-	ra := NewRAManager(id, address, peers, clock, client)
 	restoredPending := map[string]PendingTxn{}
+	lc := newLifecycle()
 
 	// Try to restore from a previously saved checkpoint.
 	var queue *ItemQueueState
-	if cp, err := loadCheckpoint(id); err != nil {
+	var cfg runtimeConfigState
+	var term int
+	var savedBids map[string][]BidRecord
+	incidents := newIncidentLog(id)
+	coldStart := false
+	if cp, err := loadCheckpoint(id); errors.As(err, new(ErrCheckpointTooNew)) {
+		// Starting fresh would overwrite the newer checkpoint at the next round.
+		log.Fatalf("[%s] Refusing to start: %v\n", id, err)
+	} else if err != nil {
 		log.Printf("[%s] Warning: could not read checkpoint: %v\n", id, err)
 		queue = freshQueue()
 	} else if cp != nil {
+		lc.advance(PhaseRestoring, "loading checkpoint")
 		log.Printf("[%s] 🔄 Restoring from checkpoint (lamport=%d, item=%v, results=%d)\n",
 			id, cp.LamportTime, itemName(cp.CurrentItem), len(cp.Results))
 		clock.Update(cp.LamportTime)
+		if len(peers) == 0 && len(cp.Peers) > 0 {
+			peers = sanitizePeers(cp.Peers, address)
+			log.Printf("[%s] Using %d peer(s) remembered in checkpoint\n", id, len(peers))
+		}
+		cfg.overrides = cp.ConfigOverrides
+		cfg.version = cp.ConfigVersion
+		term = cp.ElectionTerm
+		savedBids = cp.BidBook
+		incidents.restore(cp.Incidents, time.Unix(cp.CheckpointTime, 0))
 		for txnID, pending := range cp.PendingTxns {
 			restoredPending[txnID] = PendingTxn{
 				Bid:        pending.Bid,
@@ -88,29 +205,90 @@ func NewNode(id, address string, peers []string, rank int) *Node {
 			CurrentItem:       cp.CurrentItem,
 			Queue:             cp.RemainingQueue,
 			Results:           cp.Results,
+			ResultsTrimmed:    cp.ResultsTrimmed,
+			Announcement:      cp.Announcement,
+			LastShuffle:       cp.Shuffle,
+			Budgets:           cp.Budgets,
+			Paddles:           cp.Paddles,
 			CurrentHighestBid: cp.CurrentHighestBid,
 			CurrentWinner:     cp.CurrentWinner,
+			CurrentWinnerID:   cp.CurrentWinnerID,
 			DeadlineUnix:      cp.DeadlineUnix,
+			GraceFromUnix:     cp.GraceFromUnix,
+			OpenedAtUnix:      cp.OpenedAtUnix,
 			Active:            false, // Force inactive on startup
 		}
 	} else {
 		queue = freshQueue()
+		coldStart = true
+		incidents.point("restart", SeverityInfo, id+" started without a checkpoint")
+	}
+	metrics := NewMetrics()
+	async := newAsyncDispatcher(metrics)
+	ra := NewRAManager(id, address, peers, clock, client)
+	ra.async = async
+	// SetBaseConfig replaces the base with the flag values at startup.
+	cfg.base = DefaultRuntimeConfig()
+	cfg.effective = cfg.base
+	if len(cfg.overrides) > 0 {
+		if effective, err := overlayConfig(cfg.base, cfg.overrides); err == nil {
+			cfg.effective = effective
+		}
 	}
 
-	return &Node{
+	n := &Node{
 		ID:           id,
 		Address:      address,
 		Peers:        peers,
 		Queue:        queue,
 		Clock:        clock,
 		RA:           ra,
+		Mutex:        ra,
+		mutexKind:    MutexRA,
+		serialize:    SerializeRA,
 		Client:       client,
 		Rank:         rank,
+		Term:         term,
 		LeaderChan:   make(chan bool),
 		PendingTxns:  restoredPending,
+		decisions:    loadDecisionLog(id),
 		Dependencies: map[string]bool{},
 		KTRounds:     map[string]*KTRoundState{},
+		Metrics:      metrics,
+		bidStages:    newBidStageHistograms(metrics, "bid_stage_seconds"),
+		canaryStages: newBidStageHistograms(metrics, "canary_stage_seconds"),
+		async:        async,
+		Alerts:       NewAlertBus(id),
+		config:       cfg,
+		readLimiter:  newIPRateLimiter(cfg.effective.ReadRatePerSec, cfg.effective.ReadRateBurst),
+		httpGate:     newHTTPGate(),
+		lifecycle:    lc,
+		incidents:    incidents,
+		deadLetters:  deadLetterBox{capacity: DefaultDeadLetters},
+
+		HeartbeatInterval:  DefaultHeartbeatInterval,
+		LeaderTimeout:      DefaultLeaderTimeout,
+		ElectionWait:       DefaultElectionWait,
+		MajorityLossWindow: DefaultMajorityLossWindow,
+		FailoverGrace:      DefaultFailoverGrace,
+		LateVoteGrace:      DefaultLateVoteGrace,
+		TokenTimeout:       DefaultTokenTimeout,
+		WinnerDisplay:      WinnerDisplayFull,
+		txnLogLines:        -1,
 	}
+	n.bids.restore(savedBids)
+	n.Alerts.observe = incidents.fromAlert
+	n.RA.OnStaleDeferral = n.noteStaleDeferral
+	n.RA.IsMember = n.isPeer
+	n.RA.OnTimeout = n.noteRATimeout
+	n.RA.ResolvePeer = n.peerAddressByID
+	n.coldStart.Store(coldStart)
+	client.OnProtocolError = n.noteProtocolError
+	client.OnBreakerChange = n.noteBreakerChange
+	client.OnRoundTrip = func(address string, d time.Duration) {
+		n.Metrics.Histogram(metricName("rpc_call_seconds", "peer", address)).Observe(d.Seconds())
+	}
+	return n
 }
 
 func sanitizePeers(peers []string, selfAddress string) []string {
@@ -144,6 +322,20 @@ func freshQueue() *ItemQueueState {
 }
 
 func (n *Node) Start() {
+	n.RA.mu.Lock()
+	n.RA.Address = n.advertiseAddress() // --advertise is set after NewNode
+	n.RA.mu.Unlock()
+	if q, ok := n.Mutex.(*QuorumMutex); ok {
+		q.mu.Lock()
+		q.Address = n.advertiseAddress()
+		q.mu.Unlock()
+	}
+	if t, ok := n.Mutex.(*TokenRingManager); ok {
+		t.mu.Lock()
+		t.Address = n.advertiseAddress()
+		t.mu.Unlock()
+		go t.watch()
+	}
 	rpcServer := &NodeRPC{node: n}
 	server := rpc.NewServer()
 	_ = server.Register(rpcServer)
@@ -152,33 +344,75 @@ func (n *Node) Start() {
 	if err != nil {
 		log.Fatalf("Listen error: %v", err)
 	}
+	if n.TLS != nil {
+		listener = tls.NewListener(listener, n.TLS.Server)
+	}
+
+	// With --rpc-port the cluster RPC gets a listener of its own, so the
+	// public port serves nothing but the UI and the HTTP API.
+	httpListener, httpAddress := listener, n.Address
+	if n.HTTPAddress != "" && n.HTTPAddress != n.Address {
+		httpAddress = n.HTTPAddress
+		if httpListener, err = net.Listen("tcp", httpAddress); err != nil {
+			log.Fatalf("Listen error: %v", err)
+		}
+		rpcMux := http.NewServeMux()
+		for path, h := range n.rpcHandlers(server) {
+			rpcMux.Handle(path, h)
+		}
+		go func() {
+			if err := http.Serve(listener, n.gatedHandler(rpcMux)); err != nil {
+				log.Printf("RPC server error on %s: %v", n.Address, err)
+			}
+		}()
+	}
 
 	mux := http.NewServeMux()
-	mux.Handle(rpc.DefaultRPCPath, server)
-	mux.HandleFunc("/", n.handleUI)
-	mux.HandleFunc("/bid", n.handleBidRequest)
-	mux.HandleFunc("/state", n.handleStateRequest)
-	mux.HandleFunc("/admin/item", n.handleAddItemRequest)
-	mux.HandleFunc("/admin/auction", n.handleAuctionControlRequest)
-	mux.HandleFunc("/checkpoint", n.handleCheckpointRequest)
+	if httpListener == listener {
+		for path, h := range n.rpcHandlers(server) {
+			mux.Handle(path, h)
+		}
+	}
+	n.registerHTTPRoutes(mux)
+	if n.AdminAddress != "" {
+		n.listenAdmin()
+	}
+	n.trackListener(httpListener)
 
 	go func() {
-		if err := http.Serve(listener, mux); err != nil {
-			log.Printf("HTTP server error on %s: %v", n.Address, err)
+		if err := http.Serve(httpListener, n.gatedHandler(mux)); err != nil && !n.stopping.Load() {
+			log.Printf("HTTP server error on %s: %v", httpAddress, err)
 		}
 	}()
+	n.setPhase(PhaseSyncing, "listening; waiting for first sync")
 	go n.abortStalePreparedTxns()
 	go n.periodicStateSync()
-	go n.runPeriodicCheckpointing()
+	go n.monitorPeerVersions()
+	go n.watchClusterHealth()
+	if n.CanaryInterval > 0 {
+		go n.runCanary()
+	}
 	go n.StartCLI()
-	log.Printf("Node %s listening on %s (UI at http://%s)\n", n.ID, n.Address, n.Address)
+	if n.SingleNode() {
+		log.Printf("[%s] No peers configured: running in single-node mode\n", n.ID)
+	}
+	scheme := "http"
+	if n.TLS != nil && httpListener == listener {
+		scheme = "https"
+	}
+	log.Printf("Node %s listening on %s (UI at %s://%s) version=%s commit=%s protocol=%d\n",
+		n.ID, n.Address, scheme, httpAddress, Version, Commit, ProtocolVersion)
 }
 
-// getCoordinatorAddress resolves the coordinator's TCP address.
+// getCoordinatorAddress resolves the coordinator's TCP address: the one it
+// sent in its Bully messages, else the peer whose /version names it. Node IDs
+// carry no address, so an unknown one resolves to "" until either arrives,
+// as does a leader whose lease has lapsed (see lease.go).
 // Returns (address, isLocal): isLocal=true means this node IS the coordinator.
 func (n *Node) getCoordinatorAddress() (string, bool) {
 	n.ElectionMutex.Lock()
 	coordinatorID := n.Coordinator
+	learned := n.bullyAddrs[coordinatorID]
 	n.ElectionMutex.Unlock()
 
 	if coordinatorID == "" {
@@ -187,18 +421,11 @@ func (n *Node) getCoordinatorAddress() (string, bool) {
 	if coordinatorID == n.ID {
 		return n.Address, true
 	}
-
-	rankStr := strings.TrimPrefix(coordinatorID, "Node")
-	rank, err := strconv.Atoi(rankStr)
-	if err != nil || rank <= 0 {
+	if !n.leaderLeaseHeld(coordinatorID) {
 		return "", false
 	}
-	coordinatorPort := 8000 + rank
-	portSuffix := fmt.Sprintf(":%d", coordinatorPort)
-	for _, peer := range n.Peers {
-		if strings.HasSuffix(peer, portSuffix) {
-			return peer, false
-		}
+	if learned != "" {
+		return learned, false
 	}
-	return fmt.Sprintf("localhost:%d", coordinatorPort), false
+	return n.peerAddressOf(coordinatorID), false
 }
@@ -0,0 +1,117 @@
+package node
+
+// nodelock.go — One running process per node ID and checkpoint directory.
+//
+// At startup a node takes an exclusive OS lock on checkpoints/node_<ID>.lock
+// and holds it until it exits. A second process started with the same ID in
+// the same directory fails fast instead of fighting over the checkpoint file
+// and joining elections with a duplicate rank. The OS drops the lock when the
+// process dies, so a crash never leaves a stale lock behind. The file itself
+// only records who holds it, for the error message.
+//
+// Duplicates on different machines or directories are caught by the cluster
+// instead: see rejectDuplicateJoin and checkDuplicateIDs.
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errLockHeld is returned by lockFile when another process holds the lock.
+var errLockHeld = errors.New("lock held by another process")
+
+func nodeLockPath(nodeID string) string {
+	return filepath.Join(checkpointDir, fmt.Sprintf("node_%s.lock", nodeID))
+}
+
+// AcquireNodeLock locks nodeID's checkpoint directory for this process. The
+// returned function releases it.
+func AcquireNodeLock(nodeID, address string) (func(), error) {
+	if err := os.MkdirAll(checkpointDir, 0o755); err != nil {
+		return nil, err
+	}
+	path := nodeLockPath(nodeID)
+	f, err := lockFile(path)
+	if errors.Is(err, errLockHeld) {
+		holder, _ := os.ReadFile(path)
+		return nil, fmt.Errorf("%s is already running from %s (%s holds %s); stop it first or start this one with a different --id or working directory",
+			nodeID, mustAbs(checkpointDir), strings.TrimSpace(string(holder)), path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	_ = f.Truncate(0)
+	_, _ = fmt.Fprintf(f, "pid %d, address %s, since %s\n", os.Getpid(), address, time.Now().Format(time.RFC3339))
+	_ = f.Sync()
+	return func() { _ = f.Close() }, nil
+}
+
+func mustAbs(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// rejectDuplicateJoin returns why a join must be refused because another
+// live member already uses the joiner's node ID, or "" to admit it. Any
+// member handling JoinCluster runs it, so the coordinator need not be up. A
+// member at the joiner's own address is a restart and is never a conflict;
+// a recorded holder that no longer answers is assumed to have moved.
+func (n *Node) rejectDuplicateJoin(args JoinArgs) string {
+	if args.NodeID == n.ID && !n.isSelfAddress(args.Address) {
+		return fmt.Sprintf("node ID %s is already in use by live member %s", args.NodeID, n.advertiseAddress())
+	}
+	for _, pv := range n.peerVersionTable() {
+		if pv.Address == args.Address || pv.Info == nil || pv.Info.NodeID != args.NodeID {
+			continue
+		}
+		var info VersionInfo
+		if err := n.callPeer(pv.Address, "NodeRPC.GetVersion", EmptyArgs{}, &info); err != nil {
+			n.recordPeerVersion(pv.Address, nil)
+			continue
+		}
+		n.recordPeerVersion(pv.Address, &info)
+		if info.NodeID == args.NodeID {
+			return fmt.Sprintf("node ID %s is already in use by live member %s", args.NodeID, pv.Address)
+		}
+	}
+	return ""
+}
+
+// checkDuplicateIDs raises duplicate_node_id:<ID> for every node ID that more
+// than one reachable member claims, this node included, and resolves the
+// ones in prev that have cleared. It returns the IDs currently duplicated.
+func (n *Node) checkDuplicateIDs(prev map[string]bool) map[string]bool {
+	holders := map[string][]string{n.ID: {n.advertiseAddress()}}
+	for _, pv := range n.peerVersionTable() {
+		if pv.Reachable && pv.Info != nil {
+			holders[pv.Info.NodeID] = append(holders[pv.Info.NodeID], pv.Address)
+		}
+	}
+	dups := map[string]bool{}
+	for id, addrs := range holders {
+		if len(addrs) < 2 {
+			continue
+		}
+		dups[id] = true
+		if !prev[id] {
+			log.Printf("[%s] ⚠️  DUPLICATE NODE ID: %s claimed by %s\n", n.ID, id, strings.Join(addrs, ", "))
+		}
+		n.Alerts.Raise("duplicate_node_id:"+id, SeverityCritical,
+			fmt.Sprintf("node ID %s is claimed by %d live members (%s); elections and checkpoints will conflict",
+				id, len(addrs), strings.Join(addrs, ", ")))
+	}
+	for id := range prev {
+		if !dups[id] {
+			n.Alerts.Resolve("duplicate_node_id:"+id, fmt.Sprintf("node ID %s is unique again", id))
+		}
+	}
+	n.Metrics.Set("cluster_duplicate_node_ids", float64(len(dups)))
+	return dups
+}
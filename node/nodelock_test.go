@@ -0,0 +1,106 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNodeLockRefusesSecondInstance(t *testing.T) {
+	t.Chdir(t.TempDir())
+	release, err := AcquireNodeLock("Node2", "127.0.0.1:8002")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = AcquireNodeLock("Node2", "127.0.0.1:9002")
+	if err == nil {
+		t.Fatal("second Node2 got the lock")
+	}
+	for _, want := range []string{"Node2 is already running", fmt.Sprintf("pid %d", os.Getpid()), "127.0.0.1:8002", "--id"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	other, err := AcquireNodeLock("Node3", "127.0.0.1:8003")
+	if err != nil {
+		t.Fatalf("Node3 in the same directory: %v", err)
+	}
+	other()
+
+	// Once the first instance exits, the ID is free again.
+	release()
+	again, err := AcquireNodeLock("Node2", "127.0.0.1:9002")
+	if err != nil {
+		t.Fatalf("relock after release: %v", err)
+	}
+	again()
+}
+
+func TestJoinRefusesDuplicateID(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	b, c := nodes[1], nodes[2]
+	c.recordPeerVersion(b.Address, &VersionInfo{NodeID: b.ID})
+	join := func(id, addr string) JoinReply {
+		t.Helper()
+		var reply JoinReply
+		args := JoinArgs{NodeID: id, Address: addr, Rank: 9, ConfigHash: clusterConfigHash(), Version: c.versionInfo()}
+		if err := (&NodeRPC{node: c.Node}).JoinCluster(args, &reply); err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	elsewhere := closedAddr(t)
+	for _, id := range []string{"B", "C"} {
+		reply := join(id, elsewhere)
+		if reply.Accepted || !strings.Contains(reply.Message, "already in use by live member") {
+			t.Errorf("second %s: accepted=%v %q, want refused", id, reply.Accepted, reply.Message)
+		}
+	}
+	if c.isPeer(elsewhere) {
+		t.Error("refused joiner added to the membership")
+	}
+	if !slices.Contains(delivered(c.Alerts), "duplicate_join_refused") {
+		t.Errorf("alerts %v lack duplicate_join_refused", delivered(c.Alerts))
+	}
+
+	// B rejoining from its own address is a restart, not a duplicate.
+	if reply := join("B", b.Address); !reply.Accepted {
+		t.Errorf("B restarting in place refused: %q", reply.Message)
+	}
+	// Once B is gone, its ID may come back from a new address.
+	b.kill()
+	if reply := join("B", elsewhere); !reply.Accepted {
+		t.Errorf("B moving after its old instance died refused: %q", reply.Message)
+	}
+}
+
+func TestDuplicateIDAlert(t *testing.T) {
+	t.Chdir(t.TempDir())
+	n := NewNode("A", "127.0.0.1:9", []string{"p1:1", "p2:1", "p3:1"}, 1)
+	n.recordPeerVersion("p1:1", &VersionInfo{NodeID: "B"})
+	n.recordPeerVersion("p2:1", &VersionInfo{NodeID: "B"})
+	n.recordPeerVersion("p3:1", &VersionInfo{NodeID: "A"})
+
+	dups := n.checkDuplicateIDs(nil)
+	if len(dups) != 2 || !dups["A"] || !dups["B"] {
+		t.Errorf("duplicates = %v, want A and B", dups)
+	}
+	if !n.Alerts.IsActive("duplicate_node_id:A") || !n.Alerts.IsActive("duplicate_node_id:B") {
+		t.Errorf("active alerts = %v", n.Alerts.ActiveKeys())
+	}
+
+	// p3 goes quiet and p2 turns out to be someone else.
+	n.recordPeerVersion("p3:1", nil)
+	n.recordPeerVersion("p2:1", &VersionInfo{NodeID: "C"})
+	dups = n.checkDuplicateIDs(dups)
+	if len(dups) != 0 || len(n.Alerts.ActiveKeys()) != 0 {
+		t.Errorf("duplicates = %v, active alerts = %v; want none", dups, n.Alerts.ActiveKeys())
+	}
+	if got := gauge(n.Metrics, "cluster_duplicate_node_ids"); got != 0 {
+		t.Errorf("cluster_duplicate_node_ids = %v, want 0", got)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package node
+
+import (
+	"os"
+	"syscall"
+)
+
+const errSharingViolation syscall.Errno = 32 // ERROR_SHARING_VIOLATION
+
+// lockFile opens path with no sharing allowed, which Windows enforces until
+// the handle is closed or the process exits.
+func lockFile(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil,
+		syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err == errSharingViolation {
+		return nil, errLockHeld
+	}
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
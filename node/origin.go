@@ -0,0 +1,113 @@
+package node
+
+// origin.go — Which node took each committed bid, and who committed it.
+//
+// The node a client sends /bid to stamps itself as the bid's Origin, and the
+// coordinator stamps its own ID and election term on the bid when its 2PC
+// round begins. Both travel in the prepare and decision messages, so every
+// participant enters them in its bid book, the txn log and, for the winning
+// bid, the lot's result (WinnerOrigin). The bidder gets them back as the
+// X-Bid-Origin, X-Bid-Coordinator, X-Bid-Term and X-Bid-Txn headers of a
+// committed /bid.
+//
+// bids_committed_by_origin_total{origin} counts the commits a node applied,
+// which on a synced node is every commit in the cluster, and GET /bidstats
+// breaks the bid book down by origin and coordinator, to show whether one
+// node's UI takes all the traffic. Bids from older builds carry none of the
+// fields and are counted as "unknown".
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+const unknownOrigin = "unknown"
+
+func originLabel(id string) string {
+	if id == "" {
+		return unknownOrigin
+	}
+	return id
+}
+
+// committed finds the committed record of bidderID's amount on itemID.
+func (b *bidBook) committed(bidderID, itemID string, amount int) (BidRecord, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	recs := b.byBidder[bidderID]
+	for i := len(recs) - 1; i >= 0; i-- {
+		if rec := recs[i]; rec.Outcome == BidCommitted && rec.ItemID == itemID && rec.Amount == amount {
+			return rec, true
+		}
+	}
+	return BidRecord{}, false
+}
+
+// OriginCount is one row of GET /bidstats.
+type OriginCount struct {
+	Node  string  `json:"node"`
+	Bids  int     `json:"bids"`
+	Share float64 `json:"share"` // of all committed bids in the book
+}
+
+// attribution tallies the committed bids in the book by origin and by
+// coordinator.
+func (b *bidBook) attribution() (total int, byOrigin, byCoordinator map[string]int) {
+	byOrigin, byCoordinator = map[string]int{}, map[string]int{}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, recs := range b.byBidder {
+		for _, rec := range recs {
+			if rec.Outcome != BidCommitted {
+				continue
+			}
+			total++
+			byOrigin[originLabel(rec.Origin)]++
+			byCoordinator[originLabel(rec.Coordinator)]++
+		}
+	}
+	return total, byOrigin, byCoordinator
+}
+
+func originCounts(counts map[string]int, total int) []OriginCount {
+	out := make([]OriginCount, 0, len(counts))
+	for node, bids := range counts {
+		out = append(out, OriginCount{Node: node, Bids: bids, Share: float64(bids) / float64(max(total, 1))})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Bids != out[j].Bids {
+			return out[i].Bids > out[j].Bids
+		}
+		return out[i].Node < out[j].Node
+	})
+	return out
+}
+
+// handleBidStatsRequest serves GET /bidstats: committed bids in this node's
+// bid book by origin node and by coordinator.
+func (n *Node) handleBidStatsRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	total, byOrigin, byCoordinator := n.bids.attribution()
+	writeJSON(w, map[string]interface{}{
+		"nodeId":        n.ID,
+		"committedBids": total,
+		"byOrigin":      originCounts(byOrigin, total),
+		"byCoordinator": originCounts(byCoordinator, total),
+	})
+}
+
+// setReceiptHeaders adds the attribution of a committed bid to the /bid
+// response.
+func setReceiptHeaders(w http.ResponseWriter, origin string, reply CoordinatorBidReply) {
+	w.Header().Set("X-Bid-Origin", origin)
+	if reply.TxnID == "" {
+		return // rejected, or an older coordinator
+	}
+	w.Header().Set("X-Bid-Txn", reply.TxnID)
+	w.Header().Set("X-Bid-Coordinator", reply.Coordinator)
+	w.Header().Set("X-Bid-Term", strconv.Itoa(reply.Term))
+}
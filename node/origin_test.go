@@ -0,0 +1,79 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCommittedBidsNameOriginAndCoordinator(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		tn.ElectionMutex.Lock()
+		tn.Term = 4
+		tn.ElectionMutex.Unlock()
+		setLeader(tn.Node, c.ID, c.Address)
+	}
+	leading(t, c.Node)
+
+	// Ann bids through A, Bob through C itself.
+	rec := postBid(a.Node, url.Values{"bidder": {"Ann"}, "amount": {"20"}, "itemId": {"lot1"}})
+	expectBidReply(t, "bid via A", rec, BidCommitted, http.StatusOK)
+	h := rec.Header()
+	if h.Get("X-Bid-Origin") != "A" || h.Get("X-Bid-Coordinator") != "C" || h.Get("X-Bid-Term") != "4" || h.Get("X-Bid-Txn") == "" {
+		t.Errorf("receipt headers = %v", h)
+	}
+	expectBidReply(t, "bid via C", postBid(c.Node, url.Values{"bidder": {"Bob"}, "amount": {"30"}, "itemId": {"lot1"}}), BidCommitted, http.StatusOK)
+
+	// Every participant records the attribution.
+	for _, tn := range nodes {
+		waitFor(t, tn.ID+" to apply both commits", func() bool {
+			total, _, _ := tn.bids.attribution()
+			return total == 2
+		})
+		for _, origin := range []string{"A", "C"} {
+			if got := tn.Metrics.Counter(metricName("bids_committed_by_origin_total", "origin", origin)); got != 1 {
+				t.Errorf("%s: bids_committed_by_origin_total{origin=%s} = %v, want 1", tn.ID, origin, got)
+			}
+		}
+	}
+	for _, rec := range b.bids.snapshot() {
+		for _, r := range rec {
+			if r.Coordinator != "C" || r.Term != 4 || (r.Amount == 20) != (r.Origin == "A") {
+				t.Errorf("B's record %+v", r)
+			}
+		}
+	}
+
+	// An older coordinator names only itself, in the decision.
+	var ok bool
+	legacy := DecisionArgs{TxnID: "old-1", Commit: true, Leader: "C", Term: 4,
+		Bid: BidArgs{BidderID: "b9", DisplayName: "Cy", Amount: 40, ItemID: "lot1"}}
+	if err := (&NodeRPC{node: b.Node}).DecideBid(legacy, &ok); err != nil || !ok {
+		t.Fatalf("legacy decision: %v, applied %v", err, ok)
+	}
+	if r, found := b.bids.committed("b9", "lot1", 40); !found || r.Origin != "" || r.Coordinator != "C" || r.Term != 4 {
+		t.Errorf("legacy commit recorded as %+v (found %v)", r, found)
+	}
+
+	rec = httptest.NewRecorder()
+	b.handleBidStatsRequest(rec, httptest.NewRequest(http.MethodGet, "/bidstats", nil))
+	var stats struct {
+		CommittedBids int
+		ByOrigin      []OriginCount
+		ByCoordinator []OriginCount
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.CommittedBids != 3 || len(stats.ByOrigin) != 3 || stats.ByOrigin[0].Node != "A" || stats.ByOrigin[2].Node != unknownOrigin {
+		t.Errorf("/bidstats by origin = %+v of %d", stats.ByOrigin, stats.CommittedBids)
+	}
+	if len(stats.ByCoordinator) != 1 || stats.ByCoordinator[0].Bids != 3 || stats.ByCoordinator[0].Share != 1 || stats.ByOrigin[0].Share > 0.34 {
+		t.Errorf("/bidstats by coordinator = %+v", stats.ByCoordinator)
+	}
+}
@@ -0,0 +1,330 @@
+package node
+
+// paddles.go — Winner display policy for public screens, and paddle numbers.
+//
+// Some events do not want real names on the projector. --winner-display
+// picks how public views show a bidder:
+//
+//	full           the display name, as before
+//	initials       "Ada Lovelace" → "A.L."
+//	paddle-number  "Paddle 12" for a registered bidder, initials otherwise
+//
+// The policy covers /state, /api/v1/state and /api/v1/checkpoint, and so the
+// embedded UI, which draws the leading bidder, the sold banner and the
+// results from /api/v1/state. Outside the full policy those views also drop
+// bidder IDs, since a name-derived ID can be matched back to the name. Guest
+// names are derived from the session, not the person, and are shown as they
+// are. Operator views (/admin/*, /checkpoint, /bidstats, the transaction log
+// and the event history with its settlement) keep full identities.
+//
+// An admin registers a bidder ID with POST /admin/paddle. The coordinator
+// hands out the next number from a counter kept in the replicated queue
+// state next to the registry, so numbers travel with snapshots and
+// checkpoints, survive failover and are never reused. Registering a bidder
+// again returns the number it already has.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WinnerDisplay is the --winner-display policy.
+type WinnerDisplay string
+
+const (
+	WinnerDisplayFull     WinnerDisplay = "full"
+	WinnerDisplayInitials WinnerDisplay = "initials"
+	WinnerDisplayPaddle   WinnerDisplay = "paddle-number"
+
+	maxInitials = 3
+)
+
+// ParseWinnerDisplay checks a --winner-display value.
+func ParseWinnerDisplay(s string) (WinnerDisplay, error) {
+	switch d := WinnerDisplay(strings.TrimSpace(s)); d {
+	case WinnerDisplayFull, WinnerDisplayInitials, WinnerDisplayPaddle:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown winner display %q (want full, initials or paddle-number)", s)
+}
+
+// PaddleBook is the replicated paddle registry. It is replaced, never
+// modified in place, so snapshots can share it.
+type PaddleBook struct {
+	Numbers map[string]int `json:"numbers"` // by bidder ID
+	Last    int            `json:"last"`    // highest number handed out; numbers are never reused
+}
+
+// number returns bidderID's paddle number, or 0 if it has none.
+func (b *PaddleBook) number(bidderID string) int {
+	if b == nil {
+		return 0
+	}
+	return b.Numbers[bidderID]
+}
+
+// withBidder returns the book with bidderID registered and its number. The
+// book is copied only when a new number is handed out.
+func (b *PaddleBook) withBidder(bidderID string) (*PaddleBook, int, bool) {
+	if num := b.number(bidderID); num > 0 {
+		return b, num, false
+	}
+	out := &PaddleBook{Numbers: map[string]int{}}
+	if b != nil {
+		out.Last = b.Last
+		for id, num := range b.Numbers {
+			out.Numbers[id] = num
+		}
+	}
+	out.Last++
+	out.Numbers[bidderID] = out.Last
+	return out, out.Last, true
+}
+
+// initials shortens a name to the first letters of its first few words.
+func initials(name string) string {
+	var b strings.Builder
+	for i, word := range strings.Fields(name) {
+		if i == maxInitials {
+			break
+		}
+		r, _ := utf8.DecodeRuneInString(word)
+		b.WriteRune(unicode.ToUpper(r))
+		b.WriteByte('.')
+	}
+	if b.Len() == 0 {
+		return "?"
+	}
+	return b.String()
+}
+
+func paddleLabel(num int) string {
+	return fmt.Sprintf("Paddle %d", num)
+}
+
+// show returns what the policy displays for a bidder.
+func (d WinnerDisplay) show(name, bidderID string, paddles *PaddleBook) string {
+	if d == WinnerDisplayFull || d == "" || name == "" || name == "No bids" {
+		return name
+	}
+	if bidderID == "" {
+		bidderID = legacyBidderID(name)
+	}
+	if d == WinnerDisplayPaddle {
+		if num := paddles.number(bidderID); num > 0 {
+			return paddleLabel(num)
+		}
+	}
+	if name == guestName(bidderID) {
+		return name
+	}
+	return initials(name)
+}
+
+// winnerMask rewrites the bidders of one public response. Styles are
+// re-keyed by the shown names; when two bidders show alike, the first keeps
+// its style.
+type winnerMask struct {
+	policy  WinnerDisplay
+	paddles *PaddleBook
+	source  map[string]BidderStyle // by display name
+	styles  map[string]BidderStyle // by shown name
+}
+
+func (m *winnerMask) show(name, bidderID string) string {
+	shown := m.policy.show(name, bidderID, m.paddles)
+	if st, ok := m.source[name]; ok {
+		if _, taken := m.styles[shown]; !taken {
+			m.styles[shown] = st
+		}
+	}
+	return shown
+}
+
+func (m *winnerMask) results(results []ItemResult) []ItemResult {
+	out := make([]ItemResult, len(results))
+	for i, r := range results {
+		r.Winner, r.WinnerID = m.show(r.Winner, r.WinnerID), ""
+		out[i] = r
+	}
+	return out
+}
+
+func (m *winnerMask) announcement(ann *SoldAnnouncement) *SoldAnnouncement {
+	if ann == nil {
+		return nil
+	}
+	copied := *ann
+	copied.Result.Winner, copied.Result.WinnerID = m.show(ann.Result.Winner, ann.Result.WinnerID), ""
+	return &copied
+}
+
+// displaySnapshot applies the winner display policy to a snapshot about to
+// be served publicly. The snapshot's slices are shared, so nothing is
+// modified in place.
+func (n *Node) displaySnapshot(snap QueueSnapshot) QueueSnapshot {
+	if n.WinnerDisplay == WinnerDisplayFull || n.WinnerDisplay == "" {
+		return snap
+	}
+	m := &winnerMask{policy: n.WinnerDisplay, paddles: snap.Paddles, source: snap.BidderStyles, styles: map[string]BidderStyle{}}
+	snap.CurrentWinner, snap.CurrentWinnerID = m.show(snap.CurrentWinner, snap.CurrentWinnerID), ""
+	snap.Results = m.results(snap.Results)
+	snap.Announcement = m.announcement(snap.Announcement)
+	snap.BidderStyles = m.styles
+	return snap
+}
+
+// displayCheckpoint is displaySnapshot for checkpoint files.
+func (n *Node) displayCheckpoint(cp CheckpointData) CheckpointData {
+	if n.WinnerDisplay == WinnerDisplayFull || n.WinnerDisplay == "" {
+		return cp
+	}
+	m := &winnerMask{policy: n.WinnerDisplay, paddles: cp.Paddles, styles: map[string]BidderStyle{}}
+	cp.CurrentWinner, cp.CurrentWinnerID = m.show(cp.CurrentWinner, cp.CurrentWinnerID), ""
+	cp.Results = m.results(cp.Results)
+	cp.Announcement = m.announcement(cp.Announcement)
+	return cp
+}
+
+// PaddleArgs registers a bidder for a paddle number.
+type PaddleArgs struct {
+	BidderID string
+}
+
+// PaddleReply is the outcome of a registration.
+type PaddleReply struct {
+	Accepted bool
+	Message  string
+	Paddle   int
+}
+
+// PaddleEntry is one row of GET /admin/paddles.
+type PaddleEntry struct {
+	BidderID string `json:"bidderId"`
+	Paddle   int    `json:"paddle"`
+}
+
+// registerPaddleAndBroadcast gives a bidder a paddle number. Coordinator
+// only; followers forward via SubmitPaddleToCoordinator.
+func (n *Node) registerPaddleAndBroadcast(args PaddleArgs) PaddleReply {
+	args.BidderID = strings.TrimSpace(args.BidderID)
+	if args.BidderID == "" {
+		return PaddleReply{Message: "bidderId is required"}
+	}
+	if msg := n.versionWriteBlock(); msg != "" {
+		return PaddleReply{Message: msg}
+	}
+
+	release, err := n.enterCS()
+	if err != nil {
+		return PaddleReply{Message: err.Error()}
+	}
+	defer release()
+
+	n.Queue.mu.Lock()
+	book, num, added := n.Queue.Paddles.withBidder(args.BidderID)
+	if added {
+		n.Queue.Paddles = book
+		n.Queue.touchLocked()
+	}
+	n.Queue.mu.Unlock()
+
+	if !added {
+		return PaddleReply{Accepted: true, Paddle: num, Message: fmt.Sprintf("%s already has paddle %d", args.BidderID, num)}
+	}
+	log.Printf("[%s] 🏷️  %s registered as paddle %d\n", n.ID, args.BidderID, num)
+	n.Metrics.Inc("paddles_assigned_total")
+	n.broadcastQueueState()
+	go n.initiateGlobalCheckpoint()
+	return PaddleReply{Accepted: true, Paddle: num, Message: fmt.Sprintf("%s registered as paddle %d", args.BidderID, num)}
+}
+
+// SubmitPaddleToCoordinator forwards POST /admin/paddle to the leader.
+func (rp *NodeRPC) SubmitPaddleToCoordinator(args PaddleArgs, reply *PaddleReply) error {
+	if _, isLocal := rp.node.getCoordinatorAddress(); !isLocal {
+		reply.Accepted = false
+		reply.Message = "This node is not the coordinator"
+		return nil
+	}
+	*reply = rp.node.registerPaddleAndBroadcast(args)
+	return nil
+}
+
+// submitPaddle registers a bidder on the coordinator, forwarding if needed.
+func (n *Node) submitPaddle(args PaddleArgs) (int, PaddleReply) {
+	var reply PaddleReply
+	coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
+	if isLocalCoordinator {
+		reply = n.registerPaddleAndBroadcast(args)
+	} else {
+		if coordinatorAddress == "" {
+			return http.StatusServiceUnavailable, PaddleReply{Message: "Election in progress, please wait"}
+		}
+		if err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitPaddleToCoordinator", args, &reply); err != nil {
+			return http.StatusServiceUnavailable, PaddleReply{Message: "Leader unavailable; retry shortly"}
+		}
+	}
+	if !reply.Accepted {
+		return http.StatusBadRequest, reply
+	}
+	return http.StatusOK, reply
+}
+
+// paddleTable lists every registered bidder, by paddle number.
+func (n *Node) paddleTable() []PaddleEntry {
+	n.Queue.mu.Lock()
+	book := n.Queue.Paddles
+	n.Queue.mu.Unlock()
+	rows := []PaddleEntry{}
+	if book != nil {
+		for id, num := range book.Numbers {
+			rows = append(rows, PaddleEntry{BidderID: id, Paddle: num})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Paddle < rows[j].Paddle })
+	return rows
+}
+
+// handlePaddleRequest serves GET /admin/paddles (list) and POST /admin/paddle
+// with a JSON body {"bidderId": "..."} or the same form field.
+func (n *Node) handlePaddleRequest(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/admin/paddles":
+		writeJSON(w, n.paddleTable())
+		return
+	case r.Method != http.MethodPost || r.URL.Path != "/admin/paddle":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var args PaddleArgs
+	if strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "application/json") {
+		var req struct {
+			BidderID string `json:"bidderId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON request", http.StatusBadRequest)
+			return
+		}
+		args.BidderID = req.BidderID
+	} else {
+		args.BidderID = r.FormValue("bidderId")
+	}
+
+	var status int
+	var reply PaddleReply
+	if !n.holdForClient(r, "admin_paddle", func() { status, reply = n.submitPaddle(args) }) {
+		return
+	}
+	if status != http.StatusOK {
+		http.Error(w, reply.Message, status)
+		return
+	}
+	writeJSON(w, PaddleEntry{BidderID: strings.TrimSpace(args.BidderID), Paddle: reply.Paddle})
+}
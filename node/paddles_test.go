@@ -0,0 +1,160 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// displayNode is a node under policy with a named leader, a paddle
+// holder, an unregistered winner and a guest among its results.
+func displayNode(t *testing.T, policy WinnerDisplay) *Node {
+	t.Helper()
+	n := biddingNode(t)
+	n.WinnerDisplay = policy
+	n.Queue.mu.Lock()
+	n.Queue.CurrentWinner, n.Queue.CurrentWinnerID = "Ada Lovelace", "ada"
+	n.Queue.Results = []ItemResult{
+		{Item: AuctionItem{ID: "lot0", StartingPrice: 5}, Winner: "Grace Brewster Murray Hopper", WinnerID: "grace", WinningBid: 9},
+		{Item: AuctionItem{ID: "lot00", StartingPrice: 5}, Winner: guestName("sess-1"), WinnerID: "sess-1", WinningBid: 7},
+	}
+	n.Queue.Paddles = &PaddleBook{Numbers: map[string]int{"ada": 7}, Last: 7}
+	n.Queue.touchLocked()
+	n.Queue.mu.Unlock()
+	return n
+}
+
+// shownBidders is what a public view names: the leader, its ID and each
+// result's winner and ID.
+func shownBidders(snap QueueSnapshot) []string {
+	out := []string{snap.CurrentWinner, snap.CurrentWinnerID}
+	for _, r := range snap.Results {
+		out = append(out, r.Winner, r.WinnerID)
+	}
+	return out
+}
+
+func TestWinnerDisplayPolicies(t *testing.T) {
+	guest := guestName("sess-1")
+	for _, tc := range []struct {
+		policy WinnerDisplay
+		want   []string
+	}{
+		{WinnerDisplayFull, []string{"Ada Lovelace", "ada", "Grace Brewster Murray Hopper", "grace", guest, "sess-1"}},
+		{WinnerDisplayInitials, []string{"A.L.", "", "G.B.M.", "", guest, ""}},
+		{WinnerDisplayPaddle, []string{"Paddle 7", "", "G.B.M.", "", guest, ""}},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			n := displayNode(t, tc.policy)
+			rec := httptest.NewRecorder()
+			n.handleStateRequest(rec, httptest.NewRequest(http.MethodGet, "/state", nil))
+			var snap QueueSnapshot
+			if err := json.Unmarshal(rec.Body.Bytes(), &snap); err != nil {
+				t.Fatal(err)
+			}
+			if got := shownBidders(snap); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("/state shows %q, want %q", got, tc.want)
+			}
+			rec = httptest.NewRecorder()
+			n.handleStateV1Request(rec, httptest.NewRequest(http.MethodGet, "/api/v1/state", nil))
+			var v1 struct {
+				CurrentWinner string `json:"currentWinner"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &v1); err != nil || v1.CurrentWinner != tc.want[0] {
+				t.Errorf("/api/v1/state currentWinner = %q, want %q", v1.CurrentWinner, tc.want[0])
+			}
+
+			// Operators and peers still see who is who.
+			if got := shownBidders(n.buildQueueSnapshot()); got[0] != "Ada Lovelace" || got[1] != "ada" {
+				t.Errorf("replicated snapshot shows %q", got)
+			}
+			if err := n.takeLocalCheckpoint(); err != nil {
+				t.Fatal(err)
+			}
+			rec = httptest.NewRecorder()
+			n.handleCheckpointRequest(rec, httptest.NewRequest(http.MethodGet, "/checkpoint", nil))
+			if !strings.Contains(rec.Body.String(), "Grace Brewster Murray Hopper") {
+				t.Errorf("/checkpoint lost the full names: %s", rec.Body)
+			}
+			rec = httptest.NewRecorder()
+			n.handleCheckpointV1Request(rec, httptest.NewRequest(http.MethodGet, "/api/v1/checkpoint", nil))
+			if leaked := strings.Contains(rec.Body.String(), "Grace Brewster"); leaked != (tc.policy == WinnerDisplayFull) {
+				t.Errorf("/api/v1/checkpoint under %s: %s", tc.policy, rec.Body)
+			}
+		})
+	}
+
+	if _, err := ParseWinnerDisplay("nicknames"); err == nil {
+		t.Error("ParseWinnerDisplay accepted an unknown policy")
+	}
+	if got := initials("  élan vital "); got != "É.V." {
+		t.Errorf("initials = %q", got)
+	}
+}
+
+func registerPaddle(t *testing.T, n *Node, bidderID string) int {
+	t.Helper()
+	rec := postForm(n.handlePaddleRequest, "/admin/paddle", url.Values{"bidderId": {bidderID}})
+	var entry PaddleEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entry); rec.Code != http.StatusOK || err != nil {
+		t.Fatalf("register %s on %s: %d %s", bidderID, n.ID, rec.Code, rec.Body)
+	}
+	return entry.Paddle
+}
+
+func paddlesOn(n *Node) map[string]int {
+	out := map[string]int{}
+	for _, e := range n.paddleTable() {
+		out[e.BidderID] = e.Paddle
+	}
+	return out
+}
+
+func TestPaddleNumbersSurviveFailover(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, c.ID, c.Address)
+	}
+	leading(t, c.Node)
+
+	// Registered through a follower, numbered by the leader.
+	if got := []int{registerPaddle(t, a.Node, "ann"), registerPaddle(t, b.Node, "bob"), registerPaddle(t, a.Node, "ann")}; !reflect.DeepEqual(got, []int{1, 2, 1}) {
+		t.Errorf("paddles %v, want ann 1, bob 2, ann again 1", got)
+	}
+	want := map[string]int{"ann": 1, "bob": 2}
+	waitFor(t, "the followers to hold the registry", func() bool {
+		return reflect.DeepEqual(paddlesOn(a.Node), want) && reflect.DeepEqual(paddlesOn(b.Node), want)
+	})
+
+	// C crashes and B takes over: the numbers stay, and the counter
+	// carries on rather than reusing one.
+	c.kill()
+	c.ElectionMutex.Lock()
+	c.setCoordinatorLocked("")
+	c.ElectionMutex.Unlock()
+	b.runElection()
+	leading(t, b.Node)
+	waitFor(t, "A to follow B", func() bool { return coordinatorOf(a.Node) == b.ID })
+	if got := []int{registerPaddle(t, a.Node, "bob"), registerPaddle(t, b.Node, "cy")}; !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("after failover paddles %v, want bob 2, cy 3", got)
+	}
+	want["cy"] = 3
+	waitFor(t, "A to hold the new registration", func() bool { return reflect.DeepEqual(paddlesOn(a.Node), want) })
+
+	rec := postForm(b.handlePaddleRequest, "/admin/paddle", url.Values{"bidderId": {"  "}})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("blank bidder: %d, want 400", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	b.handlePaddleRequest(rec, httptest.NewRequest(http.MethodGet, "/admin/paddles", nil))
+	var table []PaddleEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &table); err != nil || len(table) != 3 || table[2] != (PaddleEntry{BidderID: "cy", Paddle: 3}) {
+		t.Errorf("GET /admin/paddles = %s", rec.Body)
+	}
+}
@@ -0,0 +1,136 @@
+package node
+
+// prevote.go — Pre-vote: check that the leader is really gone before an
+// election.
+//
+// A node that restarts starts an election from MonitorLeader straight away.
+// If it has the highest rank it wins at once, even though the leader is
+// alive and the restarted node holds only its checkpoint. A follower whose
+// own link to the leader broke would likewise depose a leader the rest of
+// the cluster still hears. So elections started by the failure detector
+// (at startup and on a heartbeat timeout) first ask every peer whether it
+// has a live leader: the leader itself, or one whose lease it holds (see
+// lease.go). If a majority of the peers says yes, the node adopts that
+// leader, pulls a fresh snapshot from it and does not campaign. Otherwise
+// the election runs as before.
+//
+// Elections that answer a lower-ranked candidate, and the takeover that a
+// higher-ranked node runs once it is synced and ready, skip the pre-vote:
+// by then the node holds current state, so winning costs no data.
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+type PreVoteArgs struct {
+	NodeID string
+	Term   int
+}
+
+// PreVoteReply says whether the sender has a live leader.
+type PreVoteReply struct {
+	LeaderAlive   bool
+	Leader        string
+	LeaderAddress string
+	Term          int
+}
+
+// PreVote answers a node that wants to start an election.
+func (rp *NodeRPC) PreVote(args PreVoteArgs, reply *PreVoteReply) error {
+	n := rp.node
+	n.ElectionMutex.Lock()
+	leader, term := n.Coordinator, n.Term
+	n.ElectionMutex.Unlock()
+	reply.Term = term
+	if leader == "" || leader == args.NodeID {
+		return nil
+	}
+	if leader == n.ID {
+		reply.LeaderAlive, reply.Leader, reply.LeaderAddress = true, n.ID, n.advertiseAddress()
+		return nil
+	}
+	address, _ := n.getCoordinatorAddress()
+	if address == "" {
+		return nil // lease lapsed; see lease.go
+	}
+	reply.LeaderAlive, reply.Leader, reply.LeaderAddress = true, leader, address
+	return nil
+}
+
+// leaderAliveByMajority runs the pre-vote. When a majority of the peers
+// still has a live leader, it adopts that leader, syncs from it and returns
+// true.
+func (n *Node) leaderAliveByMajority() bool {
+	peers := n.peerList()
+	if len(peers) == 0 {
+		return false
+	}
+	args := PreVoteArgs{NodeID: n.ID, Term: n.currentTerm()}
+	replies := make(chan PreVoteReply, len(peers))
+	fanOut(peers, func(p string) {
+		var reply PreVoteReply
+		if err := n.Client.Call(p, "NodeRPC.PreVote", args, &reply); err != nil {
+			reply = PreVoteReply{}
+		}
+		replies <- reply
+	})
+	timeout := time.After(n.ElectionWait)
+	alive := 0
+	var best PreVoteReply
+collect:
+	for range peers {
+		select {
+		case r := <-replies:
+			if !r.LeaderAlive {
+				continue
+			}
+			alive++
+			if r.Term > best.Term || best.Leader == "" {
+				best = r
+			}
+		case <-timeout:
+			break collect
+		}
+	}
+	if alive*2 <= len(peers) || best.LeaderAddress == "" {
+		n.Metrics.Inc(metricName("prevotes_total", "result", "proceed"))
+		return false
+	}
+
+	n.ElectionMutex.Lock()
+	if best.Term < n.Term {
+		// The peers are behind us; let the election sort it out.
+		n.ElectionMutex.Unlock()
+		n.Metrics.Inc(metricName("prevotes_total", "result", "proceed"))
+		return false
+	}
+	if best.Term > n.Term {
+		n.Term = best.Term
+	}
+	n.setCoordinatorLocked(best.Leader)
+	n.noteBullyAddressLocked(BullyMessage{NodeID: best.Leader, Address: best.LeaderAddress})
+	n.renewLeaderLease()
+	n.ElectionMutex.Unlock()
+	n.Metrics.Set("election_term", float64(best.Term))
+	n.Metrics.Inc(metricName("prevotes_total", "result", "deferred"))
+	n.noteElectionEvent(LeaderLearned, best.Term, best.Leader, fmt.Sprintf("pre-vote: %d of %d peers still hear it; not campaigning", alive, len(peers)))
+	log.Printf("[%s] 🗳️  Pre-vote: %d of %d peers still hear leader %s (term %d); not campaigning\n",
+		n.ID, alive, len(peers), best.Leader, best.Term)
+
+	if _, _, err := n.pullFromCoordinator(best.LeaderAddress); err != nil {
+		// The periodic pull retries; until then the node stays out of service.
+		log.Printf("[%s] Could not sync from %s after the pre-vote: %v\n", n.ID, best.Leader, err)
+	}
+	return true
+}
+
+// campaign starts an election for the failure detector, unless the
+// pre-vote finds the leader alive.
+func (n *Node) campaign() {
+	if n.leaderAliveByMajority() {
+		return
+	}
+	n.StartElection()
+}
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRejoiningNodeDefersToLiveLeader(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		tn.Term = 1
+		setLeader(tn.Node, b.ID, b.Address)
+	}
+	leading(t, b.Node)
+	bid := func(bidder string, amount int) {
+		t.Helper()
+		if r := b.submitBid(context.Background(), BidArgs{BidderID: bidder, DisplayName: bidder, Amount: amount, ItemID: "lot1"}); r.Code != BidCommitted {
+			t.Fatalf("%s at $%d = %s", bidder, amount, r.Code)
+		}
+	}
+	bid("b1", 15)
+	waitFor(t, "C to apply the first bid", func() bool { return highestBid(c.Node) == 15 })
+
+	// C, the highest rank, goes down with a checkpoint that misses the
+	// next bid, and comes back mid-auction.
+	if err := c.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	bid("b2", 25)
+	want := deadline(b.Node)
+	c.kill()
+	c = c.restart(t)
+	c.setPhase(PhaseSyncing, "listening; waiting for first sync") // as Start does
+	if highestBid(c.Node) != 15 {
+		t.Fatalf("C restored highest bid %d, want the checkpoint's 15", highestBid(c.Node))
+	}
+	c.campaign() // as MonitorLeader does at startup
+
+	for _, n := range []*Node{a.Node, b.Node, c.Node} {
+		if got := coordinatorOf(n); got != b.ID || n.currentTerm() != 1 {
+			t.Errorf("%s follows %q in term %d, want B in term 1", n.ID, got, n.currentTerm())
+		}
+		if highestBid(n) != 25 || deadline(n) != want {
+			t.Errorf("%s: highest bid %d, deadline %d; want 25 and %d", n.ID, highestBid(n), deadline(n), want)
+		}
+	}
+	if !c.IsReady() {
+		t.Errorf("C is %s after syncing from B", c.Phase())
+	}
+	if got := c.Metrics.Counter(metricName("prevotes_total", "result", "deferred")); got != 1 {
+		t.Errorf("prevotes_total{result=deferred} = %v, want 1", got)
+	}
+
+	// Once B is really gone, the pre-vote lets C's election go ahead.
+	b.kill()
+	b.ElectionMutex.Lock()
+	b.setCoordinatorLocked("")
+	b.ElectionMutex.Unlock()
+	c.campaign()
+	leading(t, c.Node)
+	if got := coordinatorOf(c.Node); got != c.ID || c.currentTerm() != 2 {
+		t.Errorf("after B died C follows %q in term %d, want itself in term 2", got, c.currentTerm())
+	}
+	if got := c.Metrics.Counter(metricName("prevotes_total", "result", "proceed")); got != 1 {
+		t.Errorf("prevotes_total{result=proceed} = %v, want 1", got)
+	}
+}
@@ -0,0 +1,267 @@
+package node
+
+// profiles.go — Automatic profiling of slow bids, and GET /admin/profiles.
+//
+// With --auto-profile-threshold set, proposeBid arms a timer for every bid.
+// If the bid is still running when it fires, the node writes a goroutine
+// dump straight away (showing what the bid is blocked on) and then records
+// a CPU profile for autoProfileCPUTime. The files are tagged with the bid's
+// txn ID, which matches the TXN_* lines of the transaction log, and kept
+// under checkpoints/profiles/<node ID>/. Only the newest maxProfileCaptures
+// captures are kept.
+//
+// A capture makes the node slower, which could make the next bid slow too,
+// so captures are at least autoProfileMinGap apart and never overlap. A bid
+// that finishes under the threshold only costs starting and stopping a
+// timer; with the threshold at 0 nothing is armed at all.
+//
+// Dumps show internal state, so /admin/profiles needs --admin-token.
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	autoProfileCPUTime = 3 * time.Second
+	autoProfileMinGap  = time.Minute
+	maxProfileCaptures = 20
+)
+
+var profileFilePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+// ProfileCapture describes one capture, saved next to its files as
+// <id>.json and listed by GET /admin/profiles.
+type ProfileCapture struct {
+	ID             string        `json:"id"`
+	TxnID          string        `json:"txnId,omitempty"` // empty if the bid was still waiting for the critical section
+	Stage          string        `json:"stage"`           // bid stage when the threshold passed
+	ThresholdMs    int64         `json:"thresholdMs"`
+	CapturedAtUnix int64         `json:"capturedAtUnix"`
+	Files          []ProfileFile `json:"files"`
+	Error          string        `json:"error,omitempty"`
+}
+
+// ProfileFile is one file of a capture.
+type ProfileFile struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+	URL   string `json:"url,omitempty"` // filled in per request
+}
+
+type autoProfiler struct {
+	mu      sync.Mutex
+	last    time.Time
+	running bool
+}
+
+func (n *Node) profileDir() string {
+	return filepath.Join(checkpointDir, "profiles", n.ID)
+}
+
+// watchSlowBid arms the slow-bid timer for round. It returns nil when
+// auto-profiling is off.
+func (n *Node) watchSlowBid(round uint64) *time.Timer {
+	if n.AutoProfileThreshold <= 0 {
+		return nil
+	}
+	return time.AfterFunc(n.AutoProfileThreshold, func() { n.captureSlowBid(round) })
+}
+
+// captureSlowBid profiles the node while round is still running, unless a
+// capture ran recently.
+func (n *Node) captureSlowBid(round uint64) {
+	row, ok := n.rounds.get(round)
+	if !ok {
+		return // finished just as the timer fired
+	}
+	p := &n.profiler
+	p.mu.Lock()
+	if p.running || time.Since(p.last) < autoProfileMinGap {
+		p.mu.Unlock()
+		n.Metrics.Inc(metricName("auto_profiles_total", "result", "skipped"))
+		return
+	}
+	p.running, p.last = true, time.Now()
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.running = false
+		p.mu.Unlock()
+	}()
+
+	now := time.Now()
+	c := ProfileCapture{
+		ID:             fmt.Sprintf("%s-%s", now.UTC().Format("20060102T150405Z"), profileTag(row.TxnID)),
+		TxnID:          row.TxnID,
+		Stage:          row.Stage,
+		ThresholdMs:    n.AutoProfileThreshold.Milliseconds(),
+		CapturedAtUnix: now.Unix(),
+	}
+	log.Printf("[%s] 🩺 Bid %s still in %s after %s; capturing profiles as %s\n",
+		n.ID, c.TxnID, c.Stage, n.AutoProfileThreshold, c.ID)
+	if err := n.writeCapture(&c); err != nil {
+		c.Error = err.Error()
+		log.Printf("[%s] ⚠️  Profile capture %s incomplete: %v\n", n.ID, c.ID, err)
+		n.Metrics.Inc(metricName("auto_profiles_total", "result", "failed"))
+	} else {
+		n.Metrics.Inc(metricName("auto_profiles_total", "result", "ok"))
+	}
+	if b, err := json.MarshalIndent(c, "", "  "); err == nil {
+		_ = os.WriteFile(filepath.Join(n.profileDir(), c.ID+".json"), b, 0o644)
+	}
+	n.pruneCaptures()
+}
+
+// profileTag makes a txn ID safe for a file name.
+func profileTag(txnID string) string {
+	if txnID == "" {
+		return "ra-wait"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' {
+			return r
+		}
+		return '_'
+	}, txnID)
+}
+
+// writeCapture writes the goroutine dump and then the CPU profile of c.
+func (n *Node) writeCapture(c *ProfileCapture) error {
+	if err := os.MkdirAll(n.profileDir(), 0o755); err != nil {
+		return err
+	}
+	write := func(suffix string, fill func(*os.File) error) error {
+		name := c.ID + suffix
+		f, err := os.Create(filepath.Join(n.profileDir(), name))
+		if err != nil {
+			return err
+		}
+		err = fill(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if info, statErr := os.Stat(f.Name()); statErr == nil {
+			c.Files = append(c.Files, ProfileFile{Name: name, Bytes: info.Size()})
+		}
+		return err
+	}
+	if err := write(".goroutines.txt", func(f *os.File) error {
+		return pprof.Lookup("goroutine").WriteTo(f, 2)
+	}); err != nil {
+		return err
+	}
+	return write(".cpu.pprof", func(f *os.File) error {
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err // another CPU profile is already running
+		}
+		time.Sleep(autoProfileCPUTime)
+		pprof.StopCPUProfile()
+		return nil
+	})
+}
+
+// listCaptures returns the saved captures, newest first.
+func (n *Node) listCaptures() []ProfileCapture {
+	files, _ := filepath.Glob(filepath.Join(n.profileDir(), "*.json"))
+	out := make([]ProfileCapture, 0, len(files))
+	for _, f := range files {
+		var c ProfileCapture
+		b, err := os.ReadFile(f)
+		if err == nil {
+			err = json.Unmarshal(b, &c)
+		}
+		if err != nil {
+			c = ProfileCapture{ID: strings.TrimSuffix(filepath.Base(f), ".json"), Error: err.Error()}
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out
+}
+
+// pruneCaptures deletes all but the newest maxProfileCaptures captures.
+func (n *Node) pruneCaptures() {
+	captures := n.listCaptures()
+	if len(captures) <= maxProfileCaptures {
+		return
+	}
+	for _, c := range captures[maxProfileCaptures:] {
+		old, _ := filepath.Glob(filepath.Join(n.profileDir(), c.ID+".*"))
+		for _, f := range old {
+			_ = os.Remove(f)
+		}
+	}
+}
+
+// adminAuthorized reports whether r carries --admin-token, as an
+// "Authorization: Bearer" header or a token query parameter, and answers the
+// request itself if not.
+func (n *Node) adminAuthorized(w http.ResponseWriter, r *http.Request) bool {
+	if n.AdminToken == "" {
+		http.Error(w, "Disabled: start the node with --admin-token to use this endpoint", http.StatusForbidden)
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(n.AdminToken)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// handleProfilesRequest serves GET /admin/profiles (capture history) and
+// GET /admin/profiles/{file} (download).
+func (n *Node) handleProfilesRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !n.adminAuthorized(w, r) {
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/profiles"), "/")
+	if name == "" {
+		suffix := ""
+		if token := r.URL.Query().Get("token"); token != "" {
+			suffix = "?token=" + token // the caller already has it; keeps the links clickable
+		}
+		captures := n.listCaptures()
+		for i := range captures {
+			for j := range captures[i].Files {
+				captures[i].Files[j].URL = "/admin/profiles/" + captures[i].Files[j].Name + suffix
+			}
+		}
+		writeJSON(w, map[string]interface{}{
+			"nodeId":      n.ID,
+			"thresholdMs": n.AutoProfileThreshold.Milliseconds(),
+			"captures":    captures,
+		})
+		return
+	}
+	if !profileFilePattern.MatchString(name) || strings.HasSuffix(name, ".json") {
+		http.NotFound(w, r)
+		return
+	}
+	path := filepath.Join(n.profileDir(), name)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", n.ID+"-"+name))
+	http.ServeFile(w, r, path)
+}
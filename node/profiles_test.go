@@ -0,0 +1,139 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowPeer votes yes on a prepare after d.
+func slowPeer(d time.Duration) func(context.Context, string, interface{}) error {
+	return func(ctx context.Context, method string, reply interface{}) error {
+		if _, ok := reply.(*PrepareReply); ok {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return votingPeer("yes")(ctx, method, reply)
+	}
+}
+
+func getProfiles(n *Node, path, auth string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if auth != "" {
+		req.Header.Set("Authorization", "Bearer "+auth)
+	}
+	rec := httptest.NewRecorder()
+	n.handleProfilesRequest(rec, req)
+	return rec
+}
+
+func TestSlowBidIsProfiled(t *testing.T) {
+	caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{
+		"p1:1": slowPeer(200 * time.Millisecond),
+		"p2:1": slowPeer(200 * time.Millisecond),
+	}}
+	n := withLotUp(electionNode(t, caller, "p1:1", "p2:1"))
+	n.AutoProfileThreshold = 50 * time.Millisecond
+	// The second YES would otherwise be drained, and logged, after the test.
+	n.LateVoteGrace = 0
+	setLeader(n, n.ID, n.Address)
+
+	bid := func(amount int) CoordinatorBidReply {
+		t.Helper()
+		reply := n.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: amount, ItemID: "lot1"})
+		if reply.Code != BidCommitted {
+			t.Fatalf("bid = %s %q", reply.Code, reply.Message)
+		}
+		return reply
+	}
+	reply := bid(50)
+	ok := metricName("auto_profiles_total", "result", "ok")
+	deadline := time.Now().Add(autoProfileCPUTime + 2*time.Second)
+	for n.Metrics.Counter(ok) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("no capture of the slow bid")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	captures := n.listCaptures()
+	if len(captures) != 1 {
+		t.Fatalf("captures = %+v", captures)
+	}
+	c := captures[0]
+	if c.TxnID != reply.TxnID || c.Stage != bidStageNames[stagePrepare] || c.ThresholdMs != 50 || c.Error != "" || len(c.Files) != 2 {
+		t.Errorf("capture = %+v, want bid %s in prepare", c, reply.TxnID)
+	}
+	for _, f := range c.Files {
+		if f.Bytes == 0 || !strings.HasPrefix(f.Name, c.ID) {
+			t.Errorf("file %+v", f)
+		}
+	}
+	dump, err := os.ReadFile(filepath.Join(n.profileDir(), c.ID+".goroutines.txt"))
+	if err != nil || !strings.Contains(string(dump), "proposeBid") {
+		t.Errorf("goroutine dump does not show the bid: %v", err)
+	}
+
+	// A second slow bid within the minute is not captured.
+	bid(60)
+	waitFor(t, "the second bid's capture to be skipped", func() bool {
+		return n.Metrics.Counter(metricName("auto_profiles_total", "result", "skipped")) == 1
+	})
+
+	// The history and the files need the admin token.
+	if rec := getProfiles(n, "/admin/profiles", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("without --admin-token: %d", rec.Code)
+	}
+	n.AdminToken = "s3cret"
+	if rec := getProfiles(n, "/admin/profiles", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d", rec.Code)
+	}
+	rec := getProfiles(n, "/admin/profiles?token=s3cret", "")
+	var body struct{ Captures []ProfileCapture }
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || len(body.Captures) != 1 {
+		t.Fatalf("history: %v %s", err, rec.Body)
+	}
+	link := body.Captures[0].Files[0].URL
+	if !strings.HasPrefix(link, "/admin/profiles/"+c.ID) || !strings.HasSuffix(link, "?token=s3cret") {
+		t.Errorf("download link %q", link)
+	}
+	rec = getProfiles(n, link, "")
+	if rec.Code != http.StatusOK || rec.Body.Len() == 0 || !strings.Contains(rec.Header().Get("Content-Disposition"), n.ID+"-"+c.ID) {
+		t.Errorf("download: %d, %d bytes, %q", rec.Code, rec.Body.Len(), rec.Header().Get("Content-Disposition"))
+	}
+	for _, bad := range []string{c.ID + ".json", "..%2Fx", "missing.txt"} {
+		if rec := getProfiles(n, "/admin/profiles/"+bad, "s3cret"); rec.Code != http.StatusNotFound {
+			t.Errorf("GET /admin/profiles/%s: %d, want 404", bad, rec.Code)
+		}
+	}
+}
+
+// BenchmarkWatchSlowBid is the watchdog's cost to a bid that finishes under
+// the threshold, against none when auto-profiling is off.
+func BenchmarkWatchSlowBid(b *testing.B) {
+	n := biddingNode(b)
+	for _, threshold := range []time.Duration{0, time.Hour} {
+		name := "off"
+		if threshold > 0 {
+			name = "under-threshold"
+		}
+		b.Run(name, func(b *testing.B) {
+			n.AutoProfileThreshold = threshold
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if timer := n.watchSlowBid(uint64(i)); timer != nil {
+					timer.Stop()
+				}
+			}
+		})
+	}
+}
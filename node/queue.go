@@ -21,26 +21,29 @@ func defaultItems() []AuctionItem {
 	}
 }
 
-const antiSnipeWindow = int64(15) // seconds — reset timer if bid placed this close to deadline
+const antiSnipeWindow = int64(15) // default seconds — reset timer if bid placed this close to deadline
 
-// maybeExtendDeadline resets the current item's deadline to antiSnipeWindow seconds
-// from now if a bid was placed within the anti-snipe window. Called by coordinator only.
+// maybeExtendDeadline resets the current item's deadline to the anti-snipe window
+// (runtime config antiSnipeSec) from now if a bid was placed within it. Called by
+// coordinator only.
 func (n *Node) maybeExtendDeadline() {
+	window := int64(n.runtimeConfig().AntiSnipeSec)
 	n.Queue.mu.Lock()
 	if n.Queue.CurrentItem == nil || !n.Queue.Active {
 		n.Queue.mu.Unlock()
 		return
 	}
 	remaining := n.Queue.DeadlineUnix - time.Now().Unix()
-	if remaining >= antiSnipeWindow {
+	if remaining >= window {
 		n.Queue.mu.Unlock()
 		return
 	}
-	newDeadline := time.Now().Unix() + antiSnipeWindow
+	newDeadline := time.Now().Unix() + window
 	n.Queue.DeadlineUnix = newDeadline
+	n.Queue.touchLocked()
 	itemID := n.Queue.CurrentItem.ID
 	log.Printf("[%s] ⏱  Anti-snipe: extended deadline by %ds (was %ds left)\n",
-		n.ID, antiSnipeWindow, remaining)
+		n.ID, window, remaining)
 	n.Queue.mu.Unlock()
 
 	n.broadcastQueueState()
@@ -52,54 +55,77 @@ func (n *Node) maybeExtendDeadline() {
 
 // startNextItem is called only by the coordinator to advance the queue.
 func (n *Node) startNextItem() {
+	n.queueOps.Lock()
+	defer n.queueOps.Unlock()
 	n.Queue.mu.Lock()
 
 	if len(n.Queue.Queue) == 0 {
 		n.Queue.CurrentItem = nil
 		n.Queue.Active = false
 		n.Queue.DeadlineUnix = 0
+		n.Queue.OpenedAtUnix = 0
+		n.Queue.touchLocked()
+		results := n.Queue.Results // replaced, never modified, so safe to keep
 		n.Queue.mu.Unlock()
 		log.Printf("[%s] All auction items completed\n", n.ID)
 		n.broadcastQueueState()
+		go n.archiveEvent("completed", results)
 		return
 	}
 
+	before := n.Queue.censusLocked()
 	next := n.Queue.Queue[0]
 	n.Queue.Queue = n.Queue.Queue[1:]
 	n.Queue.CurrentItem = &next
 	n.Queue.CurrentHighestBid = next.StartingPrice - 1
 	n.Queue.CurrentWinner = ""
-	n.Queue.DeadlineUnix = time.Now().Unix() + int64(next.DurationSec)
+	n.Queue.CurrentWinnerID = ""
+	n.Queue.OpenedAtUnix = time.Now().Unix()
+	n.Queue.DeadlineUnix = n.Queue.OpenedAtUnix + int64(next.DurationSec)
+	n.Queue.GraceFromUnix = 0
+	n.Queue.touchLocked()
+	violation := n.checkItemsLocked("start_next_item", before, nil, false)
+	deadline := n.Queue.DeadlineUnix
 	n.Queue.mu.Unlock()
+	n.alertItemInvariant(violation)
 
 	log.Printf("[%s] Started auction for: %s (deadline in %ds)\n", n.ID, next.Name, next.DurationSec)
 	n.broadcastQueueState()
 	go n.initiateGlobalCheckpoint()
-	go n.runItemTimer(next.ID, n.Queue.DeadlineUnix)
+	go n.runItemTimer(next.ID, deadline)
 }
 
-// runItemTimer sleeps until the deadline, then finalizes the item and advances the queue.
+// runItemTimer sleeps until the deadline, then finalizes the item and starts
+// the sold announcement that precedes the next lot.
 func (n *Node) runItemTimer(itemID string, deadlineUnix int64) {
-	if dur := time.Until(time.Unix(deadlineUnix, 0)); dur > 0 {
-		time.Sleep(dur)
+	if !sleepWhileLeading(n.leaderContext(), time.Until(time.Unix(deadlineUnix, 0))) {
+		return // demoted; the next leader restarts the timer
 	}
 
 	n.ElectionMutex.Lock()
-	isCoordinator := n.Coordinator == "" || n.Coordinator == n.ID
+	isCoordinator := n.Coordinator == n.ID // cleared by a step-down; see stepdown.go
 	n.ElectionMutex.Unlock()
 	if !isCoordinator {
 		return
 	}
 
+	n.queueOps.Lock()
 	n.Queue.mu.Lock()
 	if !n.Queue.Active || n.Queue.CurrentItem == nil || n.Queue.CurrentItem.ID != itemID || n.Queue.DeadlineUnix != deadlineUnix {
 		n.Queue.mu.Unlock()
+		n.queueOps.Unlock()
 		return
 	}
+	before := n.Queue.censusLocked()
 	n.finalizeCurrentItemLocked()
+	until := n.beginSoldAnnouncementLocked()
+	violation := n.checkItemsLocked("finalize_item", before, nil, false)
 	n.Queue.mu.Unlock()
+	n.queueOps.Unlock()
+	n.alertItemInvariant(violation)
 
-	n.startNextItem()
+	n.broadcastQueueState()
+	go n.runAnnouncementTimer(until)
 }
 
 // finalizeCurrentItemLocked records the result of the current item. Must hold Queue.mu.
@@ -107,18 +133,54 @@ func (n *Node) finalizeCurrentItemLocked() {
 	if n.Queue.CurrentItem == nil {
 		return
 	}
+	closedAt := time.Now().Unix()
+	if d := n.Queue.DeadlineUnix; d > 0 && d < closedAt {
+		// Closed late (after a failover): the lot ended at its deadline.
+		closedAt = d
+	}
+	openedAt := n.Queue.OpenedAtUnix
+	if openedAt == 0 || openedAt > closedAt {
+		// Pre-upgrade state never recorded an open time; assume it ran as scheduled.
+		openedAt = closedAt - int64(n.Queue.CurrentItem.DurationSec)
+	}
 	result := ItemResult{
-		Item:       *n.Queue.CurrentItem,
-		Winner:     n.Queue.CurrentWinner,
-		WinningBid: n.Queue.CurrentHighestBid,
+		Item:                 *n.Queue.CurrentItem,
+		Winner:               n.Queue.CurrentWinner,
+		WinnerID:             n.Queue.CurrentWinnerID,
+		WinningBid:           n.Queue.CurrentHighestBid,
+		OpenedAtUnix:         openedAt,
+		ClosedAtUnix:         closedAt,
+		ScheduledDurationSec: n.Queue.CurrentItem.DurationSec,
+		ActualDurationSec:    int(closedAt - openedAt),
+		FinalizedLamport:     n.Clock.Tick(),
 	}
 	if result.WinningBid <= result.Item.StartingPrice-1 {
 		result.Winner = "No bids"
+		result.WinnerID = ""
 		result.WinningBid = 0
+	} else if rec, ok := n.bids.committed(result.WinnerID, result.Item.ID, result.WinningBid); ok {
+		result.WinnerOrigin = rec.Origin
+	}
+	if _, sold := n.Queue.resultLocked(result.Item.ID); sold {
+		// The other side of a split brain already sold this lot.
+		merged, conflicts := mergeResults(n.Queue.Results, []ItemResult{result})
+		n.Queue.Results = merged
+		for _, c := range conflicts {
+			go n.noteResultConflict(c, "this node's close")
+		}
+	} else {
+		n.Queue.Results = append(n.Queue.Results, result)
 	}
-	n.Queue.Results = append(n.Queue.Results, result)
+	n.mergeHeldResultLocked(result.Item.ID)
+	if kept, _ := n.Queue.resultLocked(result.Item.ID); sameOutcome(kept, result) {
+		n.Queue.chargeWinnerLocked(result)
+	}
+	n.trimResultsLocked()
 	log.Printf("[%s] Finalized: %s → winner=%s bid=%d\n", n.ID, result.Item.Name, result.Winner, result.WinningBid)
 	n.Queue.CurrentItem = nil
+	n.Queue.OpenedAtUnix = 0
+	n.Queue.GraceFromUnix = 0
+	n.Queue.touchLocked()
 	// Checkpoint after every item closes so we never lose a result.
 	go n.initiateGlobalCheckpoint()
 }
@@ -126,68 +188,114 @@ func (n *Node) finalizeCurrentItemLocked() {
 // broadcastQueueState pushes a snapshot to all peer nodes.
 func (n *Node) broadcastQueueState() {
 	snap := n.buildQueueSnapshot()
-	for _, peer := range n.Peers {
-		go func(p string) {
-			var ok bool
-			_ = n.callPeer(p, "NodeRPC.SyncQueueState", snap, &ok)
-		}(peer)
+	var compressed *CompressedSnapshot
+	if c, err := n.compressSnapshot(snap); err == nil {
+		compressed = &c
+	}
+	for _, peer := range n.peerList() {
+		p := peer
+		n.async.sendLatest(p, "SyncQueueState", func() error {
+			return n.sendQueueSnapshot(p, snap, compressed)
+		})
 	}
 }
 
-// buildQueueSnapshot returns a serialisable copy of the current queue state.
+// buildQueueSnapshot returns a serialisable view of the current queue state.
+// Its slices and maps are shared with other readers and must not be modified.
 func (n *Node) buildQueueSnapshot() QueueSnapshot {
-	n.ElectionMutex.Lock()
-	isCoordinator := n.Coordinator == "" || n.Coordinator == n.ID
-	n.ElectionMutex.Unlock()
-
-	n.Queue.mu.Lock()
-	defer n.Queue.mu.Unlock()
+	snap := n.queueView()
+	snap.Seq = n.Clock.Get()
+	snap.Term = n.currentTerm()
+	snap.IsCoordinator = n.isCoordinatorOrUnknown()
+	snap.NodePhase = n.Phase()
+	return snap
+}
 
+// copyQueueSnapshotLocked deep-copies the queue state into a new snapshot,
+// leaving Seq, IsCoordinator and NodePhase unset. Must hold Queue.mu.
+func (n *Node) copyQueueSnapshotLocked() QueueSnapshot {
 	snap := QueueSnapshot{
 		CurrentHighestBid: n.Queue.CurrentHighestBid,
 		CurrentWinner:     n.Queue.CurrentWinner,
+		CurrentWinnerID:   n.Queue.CurrentWinnerID,
 		DeadlineUnix:      n.Queue.DeadlineUnix,
+		GraceFromUnix:     n.Queue.GraceFromUnix,
+		OpenedAtUnix:      n.Queue.OpenedAtUnix,
 		Active:            n.Queue.Active,
 		QueueLen:          len(n.Queue.Queue),
 		Results:           append([]ItemResult(nil), n.Queue.Results...),
+		ResultsTrimmed:    n.Queue.ResultsTrimmed,
 		RemainingItems:    append([]AuctionItem(nil), n.Queue.Queue...),
-		IsCoordinator:     isCoordinator,
+		BidderStyles:      n.bidderStylesLocked(),
+		Phase:             n.Queue.phaseLocked(),
+		Budgets:           copyBudgets(n.Queue.Budgets),
+		Paddles:           n.Queue.Paddles,
+		StateVersion:      n.Queue.Version(),
 	}
 	if n.Queue.CurrentItem != nil {
 		item := *n.Queue.CurrentItem
 		snap.CurrentItem = &item
+		snap.MinNextBid, snap.BidIncrement = n.minNextBidLocked()
+	}
+	if n.Queue.Announcement != nil {
+		ann := *n.Queue.Announcement
+		snap.Announcement = &ann
+	}
+	if n.Queue.LastShuffle != nil {
+		shuffle := *n.Queue.LastShuffle
+		shuffle.Order = append([]string(nil), shuffle.Order...)
+		snap.Shuffle = &shuffle
 	}
 	return snap
 }
 
 // applyQueueSnapshot overwrites local state with the coordinator's snapshot.
-func (n *Node) applyQueueSnapshot(snap QueueSnapshot) {
+// A snapshot that fails validateQueueSnapshot is dropped and false returned.
+// One older than the coordinator snapshot already held is skipped; see
+// followersync.go.
+func (n *Node) applyQueueSnapshot(snap QueueSnapshot, source string) bool {
+	if err := validateQueueSnapshot(snap); err != nil {
+		n.rejectInvalid("snapshot", source, snap, err)
+		return false
+	}
 	n.Queue.mu.Lock()
 	defer n.Queue.mu.Unlock()
+	if held, older := n.olderThanHeld(snap); older {
+		// Overtaken by a newer push or pull; what we hold is more recent.
+		log.Printf("[%s] Ignoring %s: version %d is older than the %d held (term %d)\n",
+			n.ID, source, snap.StateVersion, held.Version, held.Term)
+		n.Metrics.Inc("snapshots_ignored_older_total")
+		return true
+	}
 	n.Queue.CurrentItem = snap.CurrentItem
 	n.Queue.CurrentHighestBid = snap.CurrentHighestBid
 	n.Queue.CurrentWinner = snap.CurrentWinner
+	n.Queue.CurrentWinnerID = snap.CurrentWinnerID
 	n.Queue.DeadlineUnix = snap.DeadlineUnix
+	n.Queue.GraceFromUnix = snap.GraceFromUnix
+	n.Queue.OpenedAtUnix = snap.OpenedAtUnix
 	n.Queue.Active = snap.Active
 	n.Queue.Queue = snap.RemainingItems
-	n.Queue.Results = append([]ItemResult(nil), snap.Results...)
-}
-
-// periodicStateSync pulls state from the coordinator every 2 seconds (follower only).
-func (n *Node) periodicStateSync() {
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-	for range ticker.C {
-		coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
-		if isLocalCoordinator || coordinatorAddress == "" {
-			continue
+	if snap.IsCoordinator {
+		if divergent := n.divergentResultsLocked(snap); len(divergent) > 0 {
+			go n.offerResults(divergent)
 		}
-		var snap QueueSnapshot
-		if err := n.callPeer(coordinatorAddress, "NodeRPC.GetQueueState", EmptyArgs{}, &snap); err != nil {
-			continue
-		}
-		n.applyQueueSnapshot(snap)
 	}
+	n.archiveResultsDroppedBySnapshotLocked(snap)
+	n.Queue.Results = append([]ItemResult(nil), snap.Results...)
+	n.Queue.ResultsTrimmed = snap.ResultsTrimmed
+	n.Queue.Announcement = snap.Announcement
+	n.Queue.LastShuffle = snap.Shuffle
+	n.Queue.Budgets = snap.Budgets
+	n.Queue.Paddles = snap.Paddles
+	if snap.Seq > n.Queue.AuthSeq {
+		n.Queue.AuthSeq = snap.Seq
+	}
+	n.Queue.touchLocked()
+	if snap.IsCoordinator {
+		n.noteHeldVersion(snap)
+	}
+	return true
 }
 
 // OnBecomeCoordinator is called after a Bully election win to (re)start the item timer.
@@ -196,13 +304,31 @@ func (n *Node) periodicStateSync() {
 func (n *Node) OnBecomeCoordinator() {
 	// ── State reconciliation: adopt the most up-to-date peer state ──────────
 	n.reconcileStateFromPeers()
+	if _, isLocal := n.getCoordinatorAddress(); !isLocal {
+		// Stepped down or deposed while reconciling; the next leader syncs us.
+		return
+	}
+	n.markSynced("reconciled state as coordinator")
+	n.replayDecisions()
+	n.seedCatalogueFromURL()
 
 	n.Queue.mu.Lock()
 	isActive := n.Queue.Active
 	hasItem := n.Queue.CurrentItem != nil
 	deadlineSet := n.Queue.DeadlineUnix > 0
+	var announcementUntil int64
+	if n.Queue.Announcement != nil {
+		announcementUntil = n.Queue.Announcement.UntilUnix
+	}
 	n.Queue.mu.Unlock()
 
+	if announcementUntil > 0 {
+		// Failover mid-announcement: finish it on the original schedule.
+		n.broadcastQueueState()
+		go n.runAnnouncementTimer(announcementUntil)
+		return
+	}
+
 	if !isActive {
 		// Explicit user action is required to start/restart the auction.
 		return
@@ -210,11 +336,19 @@ func (n *Node) OnBecomeCoordinator() {
 
 	switch {
 	case hasItem && deadlineSet:
-		// Resume existing timer
+		// Resume existing timer; a lot that expired while we had no leader
+		// may get a grace period first (see grace.go).
 		n.Queue.mu.Lock()
 		itemID := n.Queue.CurrentItem.ID
 		deadline := n.Queue.DeadlineUnix
+		var notice string
+		if now := time.Now().Unix(); deadline <= now {
+			deadline, notice = n.applyFailoverGraceLocked(now)
+		}
 		n.Queue.mu.Unlock()
+		if notice != "" {
+			n.Alerts.Notify("failover_grace", SeverityInfo, notice)
+		}
 		n.broadcastQueueState()
 		go n.runItemTimer(itemID, deadline)
 
@@ -223,6 +357,10 @@ func (n *Node) OnBecomeCoordinator() {
 		n.Queue.mu.Lock()
 		dur := n.Queue.CurrentItem.DurationSec
 		n.Queue.DeadlineUnix = time.Now().Unix() + int64(dur)
+		if n.Queue.OpenedAtUnix == 0 {
+			n.Queue.OpenedAtUnix = time.Now().Unix()
+		}
+		n.Queue.touchLocked()
 		itemID := n.Queue.CurrentItem.ID
 		deadline := n.Queue.DeadlineUnix
 		n.Queue.mu.Unlock()
@@ -244,11 +382,14 @@ func (n *Node) reconcileStateFromPeers() {
 		snap QueueSnapshot
 	}
 
-	ch := make(chan *peerSnap, len(n.Peers))
-	for _, peer := range n.Peers {
+	peers := n.peerList()
+	if len(peers) == 0 {
+		return
+	}
+	ch := make(chan *peerSnap, len(peers))
+	for _, peer := range peers {
 		go func(p string) {
-			var snap QueueSnapshot
-			err := n.callPeer(p, "NodeRPC.GetQueueState", EmptyArgs{}, &snap)
+			snap, err := n.fetchQueueSnapshot(p)
 			if err != nil {
 				ch <- nil
 				return
@@ -261,19 +402,21 @@ func (n *Node) reconcileStateFromPeers() {
 	timer := time.NewTimer(2 * time.Second)
 	defer timer.Stop()
 	var best *QueueSnapshot
+	var answered []*peerSnap
 	received := 0
-	for received < len(n.Peers) {
+	for received < len(peers) {
 		select {
 		case ps := <-ch:
 			received++
 			if ps == nil {
 				continue
 			}
+			answered = append(answered, ps)
 			if best == nil || snapshotIsBetter(&ps.snap, best) {
 				best = &ps.snap
 			}
 		case <-timer.C:
-			received = len(n.Peers)
+			received = len(peers)
 		}
 	}
 
@@ -287,19 +430,30 @@ func (n *Node) reconcileStateFromPeers() {
 	if snapshotIsBetter(best, &localSnap) {
 		log.Printf("[%s] 🔄 Adopting newer state from peer (results=%d, highBid=%d)\n",
 			n.ID, len(best.Results), best.CurrentHighestBid)
-		n.applyQueueSnapshot(*best)
+		n.noteDivergence(fmt.Sprintf("local results=%d highBid=%d, peer results=%d highBid=%d",
+			localSnap.ResultsTrimmed+len(localSnap.Results), localSnap.CurrentHighestBid,
+			best.ResultsTrimmed+len(best.Results), best.CurrentHighestBid))
+		n.applyQueueSnapshot(*best, "peer reconciliation")
+		// What the local state had and the adopted one lacks is merged back
+		// below with every other side; see splitbrain.go.
+		answered = append(answered, &peerSnap{peer: "state held before reconciliation", snap: localSnap})
 	} else {
 		log.Printf("[%s] reconcileStateFromPeers: local state is up-to-date\n", n.ID)
 	}
+	for _, ps := range answered {
+		n.mergeForeignResults(ps.snap.Results, ps.peer)
+	}
 }
 
 // snapshotIsBetter returns true if candidate is more up-to-date than current.
 func snapshotIsBetter(candidate, current *QueueSnapshot) bool {
-	// More completed results is always better
-	if len(candidate.Results) > len(current.Results) {
+	// More completed results is always better (counting archived ones)
+	candidateTotal := candidate.ResultsTrimmed + len(candidate.Results)
+	currentTotal := current.ResultsTrimmed + len(current.Results)
+	if candidateTotal > currentTotal {
 		return true
 	}
-	if len(candidate.Results) < len(current.Results) {
+	if candidateTotal < currentTotal {
 		return false
 	}
 	// Same number of results: higher bid is better
@@ -316,109 +470,106 @@ func snapshotIsBetter(candidate, current *QueueSnapshot) bool {
 	return false
 }
 
-func (n *Node) addItemAndBroadcast(name, description string, startingPrice, durationSec int) (bool, string) {
-	if name == "" || description == "" || startingPrice <= 0 || durationSec <= 0 {
-		return false, "name, description, starting price, and duration are required"
+// reseedItemsLocked returns the demo items for another run of the auction,
+// numbered after every lot the queue still accounts for so that they do not
+// reuse the IDs of the last run's results. Must hold Queue.mu.
+func (n *Node) reseedItemsLocked() []AuctionItem {
+	items := defaultItems()
+	var next int
+	_, _ = fmt.Sscanf(n.nextItemIDLocked(), "item-%d", &next)
+	for i := range items {
+		items[i].ID = fmt.Sprintf("item-%d", next+i)
 	}
+	return items
+}
 
-	n.RA.RequestCS()
-	defer n.RA.ReleaseCS()
-
-	n.Queue.mu.Lock()
-	newID := fmt.Sprintf("item-%d", len(n.Queue.Queue)+len(n.Queue.Results)+2)
-	if n.Queue.CurrentItem == nil {
-		newID = "item-1"
+// nextItemIDLocked returns the next free "item-N" ID: one past both the
+// highest N in use and the number of items ever queued, so IDs stay unique
+// even after results have been trimmed. Must hold Queue.mu.
+func (n *Node) nextItemIDLocked() string {
+	highest := n.Queue.ResultsTrimmed + len(n.Queue.Results) + len(n.Queue.Queue)
+	consider := func(id string) {
+		var num int
+		if _, err := fmt.Sscanf(id, "item-%d", &num); err == nil && num > highest {
+			highest = num
+		}
 	}
-	item := AuctionItem{
-		ID:            newID,
-		Name:          name,
-		Description:   description,
-		Emoji:         "",
-		StartingPrice: startingPrice,
-		DurationSec:   durationSec,
+	if n.Queue.CurrentItem != nil {
+		highest++
+		consider(n.Queue.CurrentItem.ID)
 	}
-	n.Queue.Queue = append(n.Queue.Queue, item)
-	n.Queue.mu.Unlock()
-
-	n.broadcastQueueState()
-	go n.initiateGlobalCheckpoint()
-	return true, "Item added to queue"
+	for _, item := range n.Queue.Queue {
+		consider(item.ID)
+	}
+	for _, r := range n.Queue.Results {
+		consider(r.Item.ID)
+	}
+	return fmt.Sprintf("item-%d", highest+1)
 }
 
-func (n *Node) startAuctionAndBroadcast() (bool, string) {
-	n.RA.RequestCS()
-	defer n.RA.ReleaseCS()
-
-	n.Queue.mu.Lock()
-	if n.Queue.Active && n.Queue.CurrentItem != nil && n.Queue.DeadlineUnix > time.Now().Unix() {
-		n.Queue.mu.Unlock()
-		return true, "Auction already running"
+// validateAddItem checks an item before it is queued.
+func validateAddItem(args AddItemArgs) error {
+	if args.Name == "" || args.Description == "" || args.StartingPrice <= 0 || args.DurationSec <= 0 {
+		return fmt.Errorf("name, description, starting price, and duration are required")
+	}
+	if err := validateCustomFields(args.Custom); err != nil {
+		return err
 	}
+	return validateIncrements(args.Increments)
+}
 
-	if n.Queue.CurrentItem == nil {
-		if len(n.Queue.Queue) == 0 {
-			items := defaultItems()
-			n.Queue.Queue = items
+// newAuctionItem builds a validated item, inferring the emoji and category
+// when none were given.
+func newAuctionItem(id string, args AddItemArgs) AuctionItem {
+	emoji, category := args.Emoji, args.Category
+	if emoji == "" {
+		var inferred string
+		emoji, inferred = inferEmoji(args.Name, args.Description)
+		if category == "" {
+			category = inferred
 		}
-		next := n.Queue.Queue[0]
-		n.Queue.Queue = n.Queue.Queue[1:]
-		n.Queue.CurrentItem = &next
-		n.Queue.CurrentHighestBid = next.StartingPrice - 1
-		n.Queue.CurrentWinner = ""
 	}
-
-	n.Queue.Active = true
-	dur := n.Queue.CurrentItem.DurationSec
-	n.Queue.DeadlineUnix = time.Now().Unix() + int64(dur)
-	itemID := n.Queue.CurrentItem.ID
-	deadline := n.Queue.DeadlineUnix
-	n.Queue.mu.Unlock()
-
-	n.broadcastQueueState()
-	go n.initiateGlobalCheckpoint()
-	go n.runItemTimer(itemID, deadline)
-	return true, "Auction started"
+	item := AuctionItem{
+		ID:            id,
+		Name:          args.Name,
+		Description:   args.Description,
+		Emoji:         emoji,
+		Category:      category,
+		StartingPrice: args.StartingPrice,
+		DurationSec:   args.DurationSec,
+		Increments:    args.Increments,
+		Custom:        cloneCustom(args.Custom),
+	}
+	item.setAllowedBidders(args.AllowedBidders)
+	return item
 }
 
-func (n *Node) restartAuctionAndBroadcast() (bool, string) {
-	n.RA.RequestCS()
-	defer n.RA.ReleaseCS()
-
-	items := defaultItems()
-	first := items[0]
-
-	n.Queue.mu.Lock()
-	n.Queue.Queue = items[1:]
-	n.Queue.CurrentItem = &first
-	n.Queue.CurrentHighestBid = first.StartingPrice - 1
-	n.Queue.CurrentWinner = ""
-	n.Queue.Results = nil
-	n.Queue.Active = true
-	n.Queue.DeadlineUnix = time.Now().Unix() + int64(first.DurationSec)
-	itemID := first.ID
-	deadline := n.Queue.DeadlineUnix
-	n.Queue.mu.Unlock()
-
-	n.broadcastQueueState()
-	go n.initiateGlobalCheckpoint()
-	go n.runItemTimer(itemID, deadline)
-	return true, "Auction restarted"
-}
+func (n *Node) addItemAndBroadcast(args AddItemArgs) (bool, string) {
+	if err := validateAddItem(args); err != nil {
+		return false, err.Error()
+	}
+	if msg := n.versionWriteBlock(); msg != "" {
+		return false, msg
+	}
 
-func (n *Node) stopAuctionAndBroadcast() (bool, string) {
-	n.RA.RequestCS()
-	defer n.RA.ReleaseCS()
+	release, err := n.enterCS()
+	if err != nil {
+		return false, err.Error()
+	}
+	defer release()
+	n.queueOps.Lock()
+	defer n.queueOps.Unlock()
 
 	n.Queue.mu.Lock()
-	if !n.Queue.Active {
-		n.Queue.mu.Unlock()
-		return false, "Auction already stopped"
-	}
-	n.Queue.Active = false
-	// Keep current item and queue intact for potential resume
+	before := n.Queue.censusLocked()
+	item := newAuctionItem(n.nextItemIDLocked(), args)
+	n.Queue.Queue = append(n.Queue.Queue, item)
+	n.Queue.touchLocked()
+	violation := n.checkItemsLocked("add_item", before, []string{item.ID}, false)
 	n.Queue.mu.Unlock()
+	n.alertItemInvariant(violation)
 
 	n.broadcastQueueState()
 	go n.initiateGlobalCheckpoint()
-	return true, "Auction stopped"
+	return true, "Item added to queue"
 }
@@ -0,0 +1,58 @@
+package node
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResultTimingSurvivesFailover(t *testing.T) {
+	leader := biddingNode(t)
+	follower := NewNode("T2", "127.0.0.1:10", nil, 2)
+
+	// The lot was scheduled for 60s and anti-snipe stretched it by 15s; its
+	// leader fails before closing it.
+	openedAt := time.Now().Unix() - 80
+	leader.Queue.mu.Lock()
+	leader.Queue.CurrentItem.DurationSec = 60
+	leader.Queue.OpenedAtUnix = openedAt
+	leader.Queue.DeadlineUnix = openedAt + 75
+	leader.Queue.mu.Unlock()
+
+	snap := leader.buildQueueSnapshot()
+	if snap.OpenedAtUnix != openedAt {
+		t.Fatalf("snapshot OpenedAtUnix = %d, want %d", snap.OpenedAtUnix, openedAt)
+	}
+	if cp := leader.buildCheckpointData(); cp.OpenedAtUnix != openedAt {
+		t.Fatalf("checkpoint OpenedAtUnix = %d, want %d", cp.OpenedAtUnix, openedAt)
+	}
+	if !follower.applyQueueSnapshot(snap, "test") {
+		t.Fatal("snapshot rejected")
+	}
+
+	// The new coordinator closes the lot late, after its deadline.
+	follower.Queue.mu.Lock()
+	follower.finalizeCurrentItemLocked()
+	res := follower.Queue.Results[len(follower.Queue.Results)-1]
+	follower.Queue.mu.Unlock()
+	if res.OpenedAtUnix != openedAt || res.ClosedAtUnix != openedAt+75 {
+		t.Errorf("result ran %d–%d, want %d–%d", res.OpenedAtUnix, res.ClosedAtUnix, openedAt, openedAt+75)
+	}
+	if res.ScheduledDurationSec != 60 || res.ActualDurationSec != 75 {
+		t.Errorf("durations = %ds scheduled, %ds actual; want 60 and 75", res.ScheduledDurationSec, res.ActualDurationSec)
+	}
+}
+
+func TestResultTimingWithoutOpenTime(t *testing.T) {
+	// State from before open times were recorded: assume the lot ran as
+	// scheduled.
+	n := biddingNode(t)
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	n.Queue.CurrentItem.DurationSec = 30
+	n.Queue.OpenedAtUnix = 0
+	n.finalizeCurrentItemLocked()
+	res := n.Queue.Results[len(n.Queue.Results)-1]
+	if res.ActualDurationSec != 30 || res.ClosedAtUnix-res.OpenedAtUnix != 30 {
+		t.Errorf("result ran %d–%d (%ds), want 30s", res.OpenedAtUnix, res.ClosedAtUnix, res.ActualDurationSec)
+	}
+}
@@ -0,0 +1,110 @@
+package node
+
+// queueops.go — One line for everything that changes which lots the queue
+// holds, and the accounting check run after each change.
+//
+// Admin mutations (add, batch add, shuffle, start/stop/restart) take the RA
+// lock, but the coordinator's timers, which open the next lot and close the
+// current one, did not, so an admin change could land between a timer's
+// decision and its write. n.queueOps now serializes both sides on the
+// coordinator. Lock order is the RA lock, then queueOps, then Queue.mu: an
+// admin change waits for the critical section before it takes queueOps, so
+// a slow peer delays the change but never a timer. Bids take the RA lock
+// and Queue.mu but never queueOps: they change the live lot's price, not
+// which lots exist.
+//
+// After each operation the node counts the lot IDs it accounts for: queued,
+// live and retained results. It compares them with the count from before.
+// An ID may not appear twice, and none may vanish except results that
+// retention archived. None may appear that the operation did not add. A
+// restart replaces the catalogue by design, so only the duplicate check
+// applies to it. A violation is not rolled back. It is logged, counted in
+// queue_invariant_violations_total{op} and sent as a critical alert, so the
+// bug shows up where it happened rather than as a missing lot later.
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// itemCensus counts the lot IDs the queue accounts for.
+type itemCensus struct {
+	ids     map[string]int
+	trimmed int // results archived by retention, no longer listed
+}
+
+// censusLocked takes the census of q. Must hold q.mu.
+func (q *ItemQueueState) censusLocked() itemCensus {
+	c := itemCensus{ids: make(map[string]int, len(q.Queue)+len(q.Results)+1), trimmed: q.ResultsTrimmed}
+	for _, item := range q.Queue {
+		c.ids[item.ID]++
+	}
+	if q.CurrentItem != nil {
+		c.ids[q.CurrentItem.ID]++
+	}
+	for _, r := range q.Results {
+		c.ids[r.Item.ID]++
+	}
+	return c
+}
+
+// itemInvariantErrors compares the census after an operation with the one
+// before it. added lists the IDs the operation created. With rebased set the
+// operation may replace the whole set, and only duplicates are reported.
+func itemInvariantErrors(before, after itemCensus, added []string, rebased bool) []string {
+	var errs []string
+	for id, k := range after.ids {
+		if k > 1 {
+			errs = append(errs, fmt.Sprintf("%s is held %d times", id, k))
+		}
+	}
+	if !rebased {
+		isAdded := make(map[string]bool, len(added))
+		for _, id := range added {
+			isAdded[id] = true
+		}
+		for id := range after.ids {
+			if before.ids[id] == 0 && !isAdded[id] {
+				errs = append(errs, id+" appeared without being added")
+			}
+		}
+		var lost []string
+		for id := range before.ids {
+			if after.ids[id] == 0 {
+				lost = append(lost, id)
+			}
+		}
+		if len(lost) > after.trimmed-before.trimmed {
+			for _, id := range lost {
+				errs = append(errs, id+" was lost")
+			}
+		}
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+// checkItemsLocked verifies the census after op against before, logs and
+// counts any violation, and returns its message, or "" if there is none.
+// The caller passes it to alertItemInvariant once Queue.mu is released.
+// Must hold Queue.mu.
+func (n *Node) checkItemsLocked(op string, before itemCensus, added []string, rebased bool) string {
+	errs := itemInvariantErrors(before, n.Queue.censusLocked(), added, rebased)
+	if len(errs) == 0 {
+		return ""
+	}
+	n.Metrics.Inc(metricName("queue_invariant_violations_total", "op", op))
+	msg := fmt.Sprintf("Queue accounting broken by %s: %s", op, strings.Join(errs, "; "))
+	log.Printf("[%s] 🛑 %s\n", n.ID, msg)
+	return msg
+}
+
+// alertItemInvariant sends the alert for a violation checkItemsLocked
+// found. Alert hooks may be slow, so it must not run under Queue.mu.
+func (n *Node) alertItemInvariant(violation string) {
+	if violation != "" {
+		n.Alerts.Notify("queue_invariant", SeverityCritical, violation)
+	}
+}
@@ -0,0 +1,585 @@
+package node
+
+// quorummutex.go — Majority-quorum (Maekawa-style) mutual exclusion, --mutex=quorum.
+//
+// Every node is a voter with one vote. A node that wants the critical
+// section stamps a request with its Lamport clock and asks every node,
+// itself included, for its vote; it enters once a majority has voted for
+// it. Any two majorities share a voter, and a voter gives its vote to one
+// request at a time, so no two nodes are inside at once. A node that is
+// down casts no vote, but up to a minority of them costs nothing.
+//
+// A voter whose vote is taken queues the request, oldest (lowest Lamport
+// stamp, then node ID) first. On RELEASE it votes for the head of the queue.
+// Requests that each hold part of a majority could wait on each other
+// forever, so when an older request queues behind a younger vote holder the
+// voter sends the holder an INQUIRE naming the older request. The holder
+// yields the vote back unless it is already inside; the voter then votes
+// for the older request and keeps the holder queued. The oldest request
+// anywhere is never asked to yield, so it always gets its majority.
+//
+// Messages get lost and nodes crash while holding votes. A requester
+// resends its request to the voters it lacks every quorumResendInterval;
+// the voter answers a resend of the request it voted for with its vote
+// again. A voter whose vote has been held for a lease (5s) while others
+// wait asks the holder too. A holder that cannot be reached, or no longer
+// has the request (it restarted, or its RELEASE was lost), loses the vote.
+// A holder that is alive keeps it, however long it takes.
+//
+// After --ra-timeout without a majority the request is withdrawn like an RA
+// one (ricart_agrawala.go): RELEASE goes to every voter and RequestCS
+// returns ErrCSTimeout.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/rpc"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const quorumResendInterval = time.Second
+
+// quorumSelf keys this node's own vote among a request's votes.
+const quorumSelf = "(self)"
+
+// Answers to an INQUIRE.
+const (
+	inquireKept    = "kept"     // inside, or older than the request waiting
+	inquireYielded = "yielded"  // vote given back; still wants it
+	inquireNotHeld = "not_held" // no such request here
+)
+
+// QuorumInquireArgs asks the holder of Voter's vote for request Timestamp
+// to yield it to the candidate request.
+type QuorumInquireArgs struct {
+	Voter              string // node ID of the voter
+	Timestamp          int    // the holder's request
+	TargetAddress      string // the voter's address as the holder knows it
+	CandidateNodeID    string
+	CandidateTimestamp int
+}
+
+// quorumRequest is a request as a voter keeps it.
+type quorumRequest struct {
+	NodeID    string
+	Address   string // where votes go
+	Target    string // the voter's address as the requester knows it
+	Timestamp int
+}
+
+// before reports whether r is older than o, and so goes first.
+func (r quorumRequest) before(o quorumRequest) bool {
+	if r.Timestamp != o.Timestamp {
+		return r.Timestamp < o.Timestamp
+	}
+	return r.NodeID < o.NodeID
+}
+
+func (r quorumRequest) String() string {
+	return fmt.Sprintf("%s@%d", r.NodeID, r.Timestamp)
+}
+
+// QuorumStatus is a read-only view of the quorum mutex, for /admin/inflight.
+type QuorumStatus struct {
+	Requesting        bool     `json:"requesting"`
+	InCriticalSection bool     `json:"inCriticalSection"`
+	RequestTime       int      `json:"requestTime,omitempty"`
+	WaitingMs         int64    `json:"waitingMs,omitempty"`
+	HeldMs            int64    `json:"heldMs,omitempty"`
+	Votes             []string `json:"votes"` // voters whose vote we hold
+	VotesNeeded       int      `json:"votesNeeded,omitempty"`
+	VotedFor          string   `json:"votedFor,omitempty"` // request holding our vote, node@stamp
+	Queued            []string `json:"queued"`             // requests waiting for our vote, oldest first
+}
+
+type QuorumMutex struct {
+	local   sync.Mutex // one local request at a time; held from RequestCS to ReleaseCS
+	mu      sync.Mutex
+	NodeID  string
+	Address string // advertised RPC address; set by Node.Start
+	Peers   []string
+	Clock   *LamportClock
+	Client  *RPCClient
+	async   *asyncDispatcher
+
+	RequestTimeout time.Duration // 0 waits forever
+	VoteLease      time.Duration // how long a vote is held before the holder is asked about it
+	ResolvePeer    func(nodeID string) string
+	OnTimeout      func(missing []string, waited time.Duration)
+
+	// Requester side.
+	requesting  bool
+	requestTime int
+	entered     bool
+	requestedAt time.Time
+	enteredAt   time.Time
+	need        int
+	votes       map[string]bool // by voter address, quorumSelf for ours
+	voteCh      chan struct{}   // closed on entry
+
+	// Voter side.
+	vote       *quorumRequest // the request holding our vote
+	votedAt    time.Time
+	inquiredAt time.Time
+	inquiring  bool
+	queue      []quorumRequest
+}
+
+func NewQuorumMutex(nodeID, address string, peers []string, clock *LamportClock, client *RPCClient) *QuorumMutex {
+	return &QuorumMutex{
+		NodeID:         nodeID,
+		Address:        address,
+		Peers:          append([]string(nil), peers...),
+		Clock:          clock,
+		Client:         client,
+		RequestTimeout: DefaultRARequestTimeout,
+		VoteLease:      DefaultRADeferralLease,
+	}
+}
+
+// UpdatePeers replaces the voters asked by later requests.
+func (q *QuorumMutex) UpdatePeers(peers []string) {
+	q.mu.Lock()
+	q.Peers = append([]string(nil), peers...)
+	q.mu.Unlock()
+}
+
+// RequestCS blocks until a majority has voted for this node; see
+// MutexManager.
+func (q *QuorumMutex) RequestCS() error {
+	q.local.Lock()
+	q.mu.Lock()
+	q.requesting, q.entered = true, false
+	q.requestTime = q.Clock.Tick()
+	q.requestedAt, q.enteredAt = time.Now(), time.Time{}
+	peers := append([]string(nil), q.Peers...)
+	q.need = quorumFor(len(peers))
+	q.votes = map[string]bool{}
+	q.voteCh = make(chan struct{})
+	requestTime, self, timeout, entered := q.requestTime, q.Address, q.RequestTimeout, q.voteCh
+	if q.receiveLocked(quorumRequest{NodeID: q.NodeID, Timestamp: requestTime}) {
+		q.countVoteLocked(requestTime, quorumSelf)
+	}
+	q.mu.Unlock()
+
+	if len(peers) > 0 {
+		log.Printf("[%s] Requesting Critical Section at Time %d (quorum %d of %d)\n", q.NodeID, requestTime, q.need, len(peers)+1)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ask := func(p string, first bool) {
+		req := RAMessage{Timestamp: requestTime, NodeID: q.NodeID, SenderAddress: self, TargetAddress: p}
+		var granted bool
+		err := q.Client.CallContext(ctx, p, "NodeRPC.HandleQuorumRequest", req, &granted)
+		switch {
+		case ctx.Err() != nil:
+		case err != nil:
+			if first {
+				log.Printf("[%s] No vote from %s: %v\n", q.NodeID, p, err)
+			}
+		case granted:
+			q.mu.Lock()
+			counted := q.countVoteLocked(requestTime, p)
+			q.mu.Unlock()
+			if !counted {
+				q.sendRelease(p, requestTime) // granted after we gave up
+			}
+		}
+	}
+	for _, p := range peers {
+		go ask(p, true)
+	}
+
+	resend := time.NewTicker(quorumResendInterval)
+	defer resend.Stop()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	for {
+		select {
+		case <-entered:
+			if len(peers) > 0 {
+				log.Printf("[%s] Entered Critical Section\n", q.NodeID)
+			}
+			return nil
+		case <-resend.C:
+			q.mu.Lock()
+			var lacking []string
+			for _, p := range peers {
+				if !q.votes[p] {
+					lacking = append(lacking, p)
+				}
+			}
+			q.mu.Unlock()
+			for _, p := range lacking {
+				go ask(p, false)
+			}
+		case <-expired:
+			return q.withdraw(peers, requestTime, timeout)
+		}
+	}
+}
+
+// countVoteLocked records voter's vote for the request made at
+// requestTime, and enters the CS on the vote that makes a majority. It
+// returns false for a vote for a request that is gone. Must hold q.mu.
+func (q *QuorumMutex) countVoteLocked(requestTime int, voter string) bool {
+	if !q.requesting || q.requestTime != requestTime {
+		return false
+	}
+	if q.votes[voter] {
+		return true
+	}
+	q.votes[voter] = true
+	if !q.entered && len(q.votes) >= q.need {
+		q.entered, q.enteredAt = true, time.Now()
+		close(q.voteCh)
+	}
+	return true
+}
+
+// withdraw gives up the request made at requestTime after its timeout.
+func (q *QuorumMutex) withdraw(peers []string, requestTime int, waited time.Duration) error {
+	q.mu.Lock()
+	if q.entered {
+		// The last vote came in as the timer fired.
+		q.mu.Unlock()
+		return nil
+	}
+	var missing []string
+	for _, p := range peers {
+		if !q.votes[p] {
+			missing = append(missing, p)
+		}
+	}
+	got, need := len(q.votes), q.need
+	q.mu.Unlock()
+	log.Printf("[%s] ⚠️  Gave up on the critical section (request %d) after %s: %d of %d votes, none from %s\n",
+		q.NodeID, requestTime, waited, got, need, strings.Join(missing, ", "))
+	if q.OnTimeout != nil {
+		q.OnTimeout(missing, waited)
+	}
+	q.ReleaseCS()
+	return fmt.Errorf("%w: %d of %d votes, none from %s within %s", ErrCSTimeout, got, need, strings.Join(missing, ", "), waited)
+}
+
+// ReleaseCS leaves the CS, or abandons the request, and gives every vote back.
+func (q *QuorumMutex) ReleaseCS() {
+	q.mu.Lock()
+	requestTime := q.requestTime
+	q.requesting, q.entered = false, false
+	q.votes = nil
+	q.enteredAt = time.Time{}
+	q.releaseLocked(q.NodeID, requestTime)
+	peers := append([]string(nil), q.Peers...)
+	q.mu.Unlock()
+	q.local.Unlock()
+
+	for _, p := range peers {
+		q.sendRelease(p, requestTime)
+	}
+}
+
+func (q *QuorumMutex) sendRelease(p string, requestTime int) {
+	msg := RAMessage{Timestamp: requestTime, NodeID: q.NodeID, TargetAddress: p}
+	q.async.sendReliable(p, "HandleQuorumRelease", func() error {
+		var ok bool
+		return q.Client.Call(p, "NodeRPC.HandleQuorumRelease", msg, &ok)
+	})
+}
+
+// ReceiveRequest is the voter's side of a request: true is a vote, false
+// queues the request until a RELEASE or a yield frees the vote.
+func (q *QuorumMutex) ReceiveRequest(req RAMessage) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.Clock.Update(req.Timestamp)
+	return q.receiveLocked(quorumRequest{NodeID: req.NodeID, Address: req.SenderAddress, Target: req.TargetAddress, Timestamp: req.Timestamp})
+}
+
+func (q *QuorumMutex) receiveLocked(r quorumRequest) bool {
+	if q.vote != nil && q.vote.NodeID == r.NodeID {
+		if q.vote.Timestamp == r.Timestamp {
+			return true // a resend; the vote may have been lost
+		}
+		// A node asks one request at a time, so its older one was abandoned.
+		q.vote = nil
+	}
+	kept := q.queue[:0]
+	for _, w := range q.queue {
+		if w.NodeID != r.NodeID {
+			kept = append(kept, w)
+		}
+	}
+	q.queue = append(kept, r)
+	if q.vote == nil {
+		q.grantNextLocked()
+		if *q.vote == r {
+			return true
+		}
+		go q.sendVote(*q.vote)
+		return false
+	}
+	q.maybeInquireLocked()
+	return false
+}
+
+// grantNextLocked votes for the oldest queued request, if any. The caller
+// delivers the vote.
+func (q *QuorumMutex) grantNextLocked() {
+	q.vote = nil
+	if len(q.queue) == 0 {
+		return
+	}
+	sort.Slice(q.queue, func(i, j int) bool { return q.queue[i].before(q.queue[j]) })
+	next := q.queue[0]
+	q.queue = q.queue[1:]
+	q.vote, q.votedAt, q.inquiredAt = &next, time.Now(), time.Time{}
+	q.maybeInquireLocked()
+}
+
+// sendVote delivers a vote granted after the request was answered.
+func (q *QuorumMutex) sendVote(r quorumRequest) {
+	if r.NodeID == q.NodeID {
+		q.mu.Lock()
+		ok := q.countVoteLocked(r.Timestamp, quorumSelf)
+		if !ok {
+			q.releaseLocked(q.NodeID, r.Timestamp)
+		}
+		q.mu.Unlock()
+		return
+	}
+	addr := q.addressOf(r)
+	if addr == "" {
+		// The holder lease takes the vote back.
+		log.Printf("[%s] No address for %s; cannot send its vote\n", q.NodeID, r.NodeID)
+		return
+	}
+	msg := RAMessage{Timestamp: r.Timestamp, NodeID: q.NodeID, TargetAddress: r.Target}
+	q.async.sendReliable(addr, "HandleQuorumVote", func() error {
+		var ok bool
+		return q.Client.Call(addr, "NodeRPC.HandleQuorumVote", msg, &ok)
+	})
+}
+
+func (q *QuorumMutex) addressOf(r quorumRequest) string {
+	if r.Address != "" || q.ResolvePeer == nil {
+		return r.Address
+	}
+	return q.ResolvePeer(r.NodeID)
+}
+
+// HandleVote counts a vote sent by message. A vote for a request that is
+// gone is given straight back, so the voter is not left holding it.
+func (q *QuorumMutex) HandleVote(msg RAMessage) {
+	q.mu.Lock()
+	ok := q.countVoteLocked(msg.Timestamp, msg.TargetAddress)
+	q.mu.Unlock()
+	if !ok && msg.TargetAddress != "" {
+		q.sendRelease(msg.TargetAddress, msg.Timestamp)
+	}
+}
+
+// HandleRelease frees this voter's vote, or drops the queued request, of
+// nodeID's request made at requestTime.
+func (q *QuorumMutex) HandleRelease(msg RAMessage) {
+	q.mu.Lock()
+	q.releaseLocked(msg.NodeID, msg.Timestamp)
+	q.mu.Unlock()
+}
+
+func (q *QuorumMutex) releaseLocked(nodeID string, requestTime int) {
+	if q.vote != nil && q.vote.NodeID == nodeID && q.vote.Timestamp == requestTime {
+		q.grantAndNotifyLocked()
+		return
+	}
+	kept := q.queue[:0]
+	for _, w := range q.queue {
+		if w.NodeID != nodeID || w.Timestamp != requestTime {
+			kept = append(kept, w)
+		}
+	}
+	q.queue = kept
+}
+
+// grantAndNotifyLocked moves the vote to the next queued request and sends
+// it there.
+func (q *QuorumMutex) grantAndNotifyLocked() {
+	q.grantNextLocked()
+	if q.vote != nil {
+		go q.sendVote(*q.vote)
+	}
+}
+
+// maybeInquireLocked asks the vote holder to yield when an older request
+// waits, or when the holder has kept the vote for a lease while any request
+// waits. One inquiry runs at a time. Must hold q.mu.
+func (q *QuorumMutex) maybeInquireLocked() {
+	if q.vote == nil || len(q.queue) == 0 || q.inquiring {
+		return
+	}
+	best := q.queue[0]
+	for _, w := range q.queue[1:] {
+		if w.before(best) {
+			best = w
+		}
+	}
+	now := time.Now()
+	older := best.before(*q.vote) && now.Sub(q.inquiredAt) >= quorumResendInterval
+	leased := now.Sub(q.votedAt) >= q.VoteLease && now.Sub(q.inquiredAt) >= q.VoteLease
+	if !older && !leased {
+		return
+	}
+	q.inquiring, q.inquiredAt = true, now
+	go q.inquire(*q.vote, best)
+}
+
+// inquire asks holder about our vote on behalf of candidate, and moves the
+// vote if the holder gave it up, lost it or is gone.
+func (q *QuorumMutex) inquire(holder, candidate quorumRequest) {
+	args := QuorumInquireArgs{
+		Voter: q.NodeID, Timestamp: holder.Timestamp, TargetAddress: holder.Target,
+		CandidateNodeID: candidate.NodeID, CandidateTimestamp: candidate.Timestamp,
+	}
+	var answer string
+	var err error
+	if holder.NodeID == q.NodeID {
+		args.TargetAddress = quorumSelf
+		answer = q.AnswerInquire(args)
+	} else if addr := q.addressOf(holder); addr == "" {
+		err = errors.New("no address")
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), raProbeTimeout)
+		err = q.Client.CallContext(ctx, addr, "NodeRPC.HandleQuorumInquire", args, &answer)
+		cancel()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inquiring = false
+	if q.vote == nil || *q.vote != holder {
+		return // released or superseded meanwhile
+	}
+	var se rpc.ServerError
+	switch {
+	case errors.As(err, &se):
+		return // alive, but could not answer; ask again later
+	case err != nil:
+		log.Printf("[%s] Vote holder %s unreachable (%v); taking the vote back\n", q.NodeID, holder, err)
+		q.grantAndNotifyLocked()
+	case answer == inquireNotHeld:
+		log.Printf("[%s] %s no longer holds our vote; taking it back\n", q.NodeID, holder)
+		q.grantAndNotifyLocked()
+	case answer == inquireYielded:
+		q.queue = append(q.queue, holder)
+		q.grantAndNotifyLocked()
+	}
+}
+
+// AnswerInquire is the holder's side of an INQUIRE.
+func (q *QuorumMutex) AnswerInquire(args QuorumInquireArgs) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.requesting || q.requestTime != args.Timestamp {
+		return inquireNotHeld
+	}
+	if q.entered {
+		return inquireKept
+	}
+	candidate := quorumRequest{NodeID: args.CandidateNodeID, Timestamp: args.CandidateTimestamp}
+	if !candidate.before(quorumRequest{NodeID: q.NodeID, Timestamp: q.requestTime}) {
+		return inquireKept
+	}
+	delete(q.votes, args.TargetAddress)
+	return inquireYielded
+}
+
+// Status returns a copy of the current state.
+func (q *QuorumMutex) Status() QuorumStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := QuorumStatus{Votes: []string{}, Queued: []string{}}
+	if q.vote != nil {
+		s.VotedFor = q.vote.String()
+	}
+	queued := append([]quorumRequest(nil), q.queue...)
+	sort.Slice(queued, func(i, j int) bool { return queued[i].before(queued[j]) })
+	for _, w := range queued {
+		s.Queued = append(s.Queued, w.String())
+	}
+	if !q.requesting {
+		return s
+	}
+	s.Requesting, s.RequestTime, s.VotesNeeded = true, q.requestTime, q.need
+	for v := range q.votes {
+		s.Votes = append(s.Votes, v)
+	}
+	sort.Strings(s.Votes)
+	if q.entered {
+		s.InCriticalSection = true
+		s.HeldMs = time.Since(q.enteredAt).Milliseconds()
+	} else {
+		s.WaitingMs = time.Since(q.requestedAt).Milliseconds()
+	}
+	return s
+}
+
+// quorumMutex returns the quorum mutex, or reports a request from a peer
+// that runs it to a node that does not.
+func (n *Node) quorumMutex(from string) (*QuorumMutex, error) {
+	if q, ok := n.Mutex.(*QuorumMutex); ok {
+		return q, nil
+	}
+	n.noteMutexMismatch(from, MutexQuorum)
+	return nil, fmt.Errorf("%s runs --mutex=%s", n.ID, n.mutexKind)
+}
+
+// HandleQuorumRequest asks this node for its vote.
+func (rp *NodeRPC) HandleQuorumRequest(args RAMessage, reply *bool) error {
+	q, err := rp.node.quorumMutex(args.NodeID)
+	if err != nil {
+		return err
+	}
+	*reply = q.ReceiveRequest(args)
+	return nil
+}
+
+// HandleQuorumVote delivers a vote granted after the request was answered.
+func (rp *NodeRPC) HandleQuorumVote(args RAMessage, reply *bool) error {
+	q, err := rp.node.quorumMutex(args.NodeID)
+	if err != nil {
+		return err
+	}
+	q.HandleVote(args)
+	*reply = true
+	return nil
+}
+
+// HandleQuorumRelease gives this node's vote back.
+func (rp *NodeRPC) HandleQuorumRelease(args RAMessage, reply *bool) error {
+	q, err := rp.node.quorumMutex(args.NodeID)
+	if err != nil {
+		return err
+	}
+	q.HandleRelease(args)
+	*reply = true
+	return nil
+}
+
+// HandleQuorumInquire asks whether this node yields a vote it holds.
+func (rp *NodeRPC) HandleQuorumInquire(args QuorumInquireArgs, reply *string) error {
+	q, err := rp.node.quorumMutex(args.Voter)
+	if err != nil {
+		return err
+	}
+	*reply = q.AnswerInquire(args)
+	return nil
+}
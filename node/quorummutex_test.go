@@ -0,0 +1,87 @@
+package node
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// quorumCluster is a test cluster running --mutex=quorum.
+func quorumCluster(t *testing.T, ids ...string) []*testNode {
+	t.Helper()
+	nodes := testCluster(t, ids...)
+	for _, tn := range nodes {
+		tn.SetMutex(MutexQuorum)
+		tn.Mutex.(*QuorumMutex).RequestTimeout = 5 * time.Second
+	}
+	return nodes
+}
+
+func TestQuorumMutexExcludesWithOneOfFiveDown(t *testing.T) {
+	nodes := quorumCluster(t, "A", "B", "C", "D", "E")
+	nodes[4].kill()
+	live := nodes[:4]
+
+	// The dead voter costs nothing: three of five votes let A in at once.
+	a := live[0].Mutex.(*QuorumMutex)
+	start := time.Now()
+	if err := a.RequestCS(); err != nil {
+		t.Fatal(err)
+	}
+	if s := a.Status(); !s.InCriticalSection || s.VotesNeeded != 3 || len(s.Votes) < 3 || time.Since(start) > time.Second {
+		t.Errorf("A after %s: %+v, want inside on 3 votes", time.Since(start), s)
+	}
+	a.ReleaseCS()
+
+	// The four live nodes contend; never two inside at once.
+	var inside, overlaps, entries atomic.Int32
+	var wg sync.WaitGroup
+	errs := make(chan error, len(live))
+	for _, tn := range live {
+		wg.Add(1)
+		go func(m MutexManager) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				if err := m.RequestCS(); err != nil {
+					errs <- err
+					return
+				}
+				if inside.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				entries.Add(1)
+				time.Sleep(2 * time.Millisecond)
+				inside.Add(-1)
+				m.ReleaseCS()
+			}
+		}(tn.Mutex)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if overlaps.Load() != 0 || entries.Load() != 20 {
+		t.Errorf("%d entries, %d of them with another node inside; want 20, one at a time", entries.Load(), overlaps.Load())
+	}
+	// RELEASE goes out asynchronously.
+	for _, tn := range live {
+		q := tn.Mutex.(*QuorumMutex)
+		waitFor(t, tn.ID+"'s vote to come back", func() bool {
+			s := q.Status()
+			return !s.Requesting && s.VotedFor == "" && len(s.Queued) == 0
+		})
+	}
+
+	// Without a majority there is no entry, only a timeout.
+	nodes[3].kill()
+	nodes[2].kill()
+	a.RequestTimeout = 300 * time.Millisecond
+	if err := a.RequestCS(); !errors.Is(err, ErrCSTimeout) {
+		t.Errorf("RequestCS with 2 of 5 up = %v, want ErrCSTimeout", err)
+	}
+	b := live[1].Mutex.(*QuorumMutex)
+	waitFor(t, "B's vote to come back after A withdrew", func() bool { return b.Status().VotedFor == "" })
+}
@@ -0,0 +1,84 @@
+package node
+
+// rafairness.go — RA queueing statistics and priority aging.
+//
+// Requests are ordered by Lamport timestamp, ties going to the lower node
+// ID, so under heavy contention low-ID nodes win more than their share and
+// nothing showed how long anyone queued. Each RAManager now counts its CS
+// entries, timeouts, total and longest wait, and the deferrals it issued and
+// received; Stats returns them.
+//
+// With aging on (AgingThreshold, --ra-aging), a node whose requests keep
+// waiting longer than the threshold carries that accumulated wait into its
+// next request, with an age credit of one Lamport tick per threshold waited,
+// at most raMaxAgeCredit. Requests are then ordered by timestamp minus
+// credit. The credit is fixed when a request goes out and travels in the
+// message, so every node compares the same numbers and the order stays
+// total; raising the priority of a request already sent could let a peer
+// that replied to it earlier and the node now yielding to it both enter. The
+// accumulated wait resets after a request that waited less than the
+// threshold.
+//
+// Receivers honour credits whatever their own setting, so nodes may turn
+// aging on one by one; a build that predates aging ignores credits, and
+// requests between it and an aged request can deadlock until --ra-timeout.
+
+import "time"
+
+// raMaxAgeCredit bounds the Lamport ticks a request can gain by aging, so a
+// long-starved node overtakes recent requests without jumping the queue
+// indefinitely.
+const raMaxAgeCredit = 8
+
+// RAStats counts this node's use of the critical section since start.
+type RAStats struct {
+	Entries           int64 `json:"entries"`
+	Timeouts          int64 `json:"timeouts"`    // requests withdrawn after RequestTimeout
+	TotalWaitMs       int64 `json:"totalWaitMs"` // request to entry, over all entries
+	MaxWaitMs         int64 `json:"maxWaitMs"`
+	DeferralsIssued   int64 `json:"deferralsIssued"`   // peer requests we held back
+	DeferralsReceived int64 `json:"deferralsReceived"` // our requests a peer held back
+	AgedRequests      int64 `json:"agedRequests"`      // requests sent with an age credit
+	CarriedWaitMs     int64 `json:"carriedWaitMs"`     // accumulated wait the next request carries
+}
+
+// Stats returns a copy of the statistics.
+func (ra *RAManager) Stats() RAStats {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	s := ra.stats
+	s.CarriedWaitMs = ra.carriedWait.Milliseconds()
+	return s
+}
+
+// ageCreditLocked is the credit the next request earns. Must hold ra.mu.
+func (ra *RAManager) ageCreditLocked() int {
+	if ra.AgingThreshold <= 0 || ra.carriedWait < ra.AgingThreshold {
+		return 0
+	}
+	return int(min(ra.carriedWait/ra.AgingThreshold, raMaxAgeCredit))
+}
+
+// noteWaitLocked records how long the current request waited, entered or
+// withdrawn, and carries it forward for aging. Must hold ra.mu.
+func (ra *RAManager) noteWaitLocked(wait time.Duration, entered bool) {
+	if entered {
+		ra.stats.Entries++
+		ra.stats.TotalWaitMs += wait.Milliseconds()
+		ra.stats.MaxWaitMs = max(ra.stats.MaxWaitMs, wait.Milliseconds())
+	} else {
+		ra.stats.Timeouts++
+	}
+	if ra.AgingThreshold > 0 && wait >= ra.AgingThreshold {
+		ra.carriedWait += wait
+	} else {
+		ra.carriedWait = 0
+	}
+}
+
+// raPrecedes reports whether request a goes before request b: the lower
+// timestamp after age credits first, ties to the lower node ID.
+func raPrecedes(aTime, aCredit int, aID string, bTime, bCredit int, bID string) bool {
+	a, b := aTime-aCredit, bTime-bCredit
+	return a < b || (a == b && aID < bID)
+}
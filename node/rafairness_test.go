@@ -0,0 +1,103 @@
+package node
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRAStressBoundsWait(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	const rounds = 30
+	var inside, overlaps atomic.Int32
+	var wg sync.WaitGroup
+	for _, tn := range nodes {
+		tn.RA.AgingThreshold = 10 * time.Millisecond
+		wg.Add(1)
+		go func(ra *RAManager) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if err := ra.RequestCS(); err != nil {
+					t.Error(err)
+					return
+				}
+				if inside.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				time.Sleep(time.Millisecond)
+				inside.Add(-1)
+				ra.ReleaseCS()
+			}
+		}(tn.RA)
+	}
+	wg.Wait()
+	if overlaps.Load() != 0 {
+		t.Errorf("%d entries overlapped another node's", overlaps.Load())
+	}
+	issued, received := int64(0), int64(0)
+	for _, tn := range nodes {
+		s := tn.RA.Stats()
+		if s.Entries != rounds || s.Timeouts != 0 {
+			t.Errorf("%s: %d entries, %d timeouts; want %d and none", tn.ID, s.Entries, s.Timeouts, rounds)
+		}
+		if s.MaxWaitMs > 2000 || s.TotalWaitMs < s.MaxWaitMs {
+			t.Errorf("%s waited up to %dms, %dms in all", tn.ID, s.MaxWaitMs, s.TotalWaitMs)
+		}
+		issued += s.DeferralsIssued
+		received += s.DeferralsReceived
+	}
+	if issued == 0 || received == 0 {
+		t.Errorf("deferrals issued %d, received %d; contention should produce both", issued, received)
+	}
+}
+
+func TestRAAgeCredit(t *testing.T) {
+	ra := NewRAManager("A", "", nil, &LamportClock{}, nil)
+	for _, c := range []struct {
+		threshold, carried time.Duration
+		want               int
+	}{
+		{0, time.Second, 0},
+		{100 * time.Millisecond, 50 * time.Millisecond, 0},
+		{100 * time.Millisecond, 350 * time.Millisecond, 3},
+		{100 * time.Millisecond, time.Minute, raMaxAgeCredit},
+	} {
+		ra.AgingThreshold, ra.carriedWait = c.threshold, c.carried
+		if got := ra.ageCreditLocked(); got != c.want {
+			t.Errorf("threshold %s, carried %s: credit %d, want %d", c.threshold, c.carried, got, c.want)
+		}
+	}
+
+	// Long waits add up; a short one clears the account.
+	ra.AgingThreshold, ra.carriedWait = 100*time.Millisecond, 0
+	ra.noteWaitLocked(150*time.Millisecond, true)
+	ra.noteWaitLocked(200*time.Millisecond, false)
+	if s := ra.Stats(); s.CarriedWaitMs != 350 || s.Entries != 1 || s.Timeouts != 1 || s.MaxWaitMs != 150 {
+		t.Errorf("stats after two long waits = %+v", s)
+	}
+	ra.noteWaitLocked(10*time.Millisecond, true)
+	if s := ra.Stats(); s.CarriedWaitMs != 0 || s.TotalWaitMs != 160 {
+		t.Errorf("stats after a short wait = %+v", s)
+	}
+}
+
+func TestRAAgedRequestGoesFirst(t *testing.T) {
+	ra := NewRAManager("A", "", nil, &LamportClock{}, nil)
+	ra.RequestingCS, ra.RequestTime, ra.RepliesNeeded = true, 10, 1
+
+	// Z's later request waits for ours...
+	if ra.ReceiveRequest(RAMessage{NodeID: "Z", Timestamp: 12}) {
+		t.Error("later unaged request answered at once")
+	}
+	// ...unless its credit takes it ahead, even of a lower node ID.
+	if !ra.ReceiveRequest(RAMessage{NodeID: "Z", Timestamp: 12, AgeCredit: 3}) {
+		t.Error("aged request not answered at once")
+	}
+	if !raPrecedes(12, 2, "A", 10, 0, "B") || raPrecedes(12, 2, "B", 10, 0, "A") {
+		t.Error("equal aged timestamps not broken by node ID")
+	}
+	if got := ra.Stats().DeferralsIssued; got != 1 {
+		t.Errorf("DeferralsIssued = %d, want 1", got)
+	}
+}
@@ -0,0 +1,177 @@
+package node
+
+// ralease.go — Breaking RA deferrals held by dead or forgetful peers.
+//
+// A peer inside the critical section, or one with an older request, holds
+// back its reply to ours until it leaves. If it crashes first the reply
+// never comes, and RequestCS used to block forever, and every bid with it.
+// Each deferral now runs on a lease: once a peer has held our reply for
+// DeferralLease, or as soon as its circuit breaker opens, we ask the peer
+// whether it still owes it. The deferral is broken, and the reply counted
+// as given, only when
+//
+//   - the peer answers that it owes us nothing (it restarted and forgot the
+//     request, or its reply was lost), or
+//   - the peer cannot be reached and is no longer a member of the cluster.
+//
+// A peer that answers that it still holds the deferral is alive and inside
+// the critical section, however slowly, and we keep waiting; it is asked
+// again every lease. So is a member that cannot be reached: a partition
+// looks the same as a crash from here, and the peer may still be inside
+// the critical section. Breaking its deferral could put two nodes in the
+// critical section at once. Time alone never breaks a deferral; a request
+// that cannot be answered is withdrawn at RequestTimeout instead.
+//
+// Replies name the request they answer, so a deferred reply that arrives
+// after its deferral was broken is dropped instead of being counted twice.
+//
+// A peer that never answers the request at all, because the call to it
+// hangs, has no deferral to probe. RequestCS gives up on it after
+// RequestTimeout (--ra-timeout): the request is withdrawn and the caller
+// gets ErrCSTimeout, so a bid fails with a retryable error instead of
+// hanging.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/rpc"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultRADeferralLease = 5 * time.Second
+	raDeferralCheck        = 500 * time.Millisecond
+	raProbeTimeout         = 2 * time.Second
+)
+
+// awaitReplies waits until every peer has replied, checking deferrals
+// meanwhile. It returns false if timeout (0 = none) passes first.
+func (ra *RAManager) awaitReplies(replyCh chan struct{}, requestTime int, timeout time.Duration) bool {
+	ticker := time.NewTicker(raDeferralCheck)
+	defer ticker.Stop()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	for {
+		select {
+		case <-replyCh:
+			return true
+		case <-ticker.C:
+			ra.checkDeferrals(requestTime)
+		case <-expired:
+			return false
+		}
+	}
+}
+
+// checkDeferrals probes the peers whose deferral is due for a liveness
+// check and breaks the ones that are stale.
+func (ra *RAManager) checkDeferrals(requestTime int) {
+	type due struct {
+		peer   string
+		waited time.Duration
+	}
+	var probes []due
+	ra.mu.Lock()
+	if !ra.RequestingCS || ra.RequestTime != requestTime {
+		ra.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	for p, st := range ra.replies {
+		if st.answered || st.deferredAt.IsZero() {
+			continue
+		}
+		leaseDue := now.Sub(st.deferredAt) >= ra.DeferralLease && now.Sub(st.probedAt) >= ra.DeferralLease
+		if leaseDue || ra.Client.Breaker(p).State == BreakerOpen && now.Sub(st.probedAt) >= raDeferralCheck {
+			st.probedAt = now
+			probes = append(probes, due{p, now.Sub(st.deferredAt)})
+		}
+	}
+	ra.mu.Unlock()
+
+	for _, d := range probes {
+		reason, stale := ra.probeDeferral(d.peer, requestTime)
+		if !stale {
+			why := "peer is alive and still holds it"
+			if reason == "unreachable" {
+				why = "cannot reach the peer, but it is still a member; waiting"
+			}
+			log.Printf("[%s] RA reply from %s held for %s; %s\n",
+				ra.NodeID, d.peer, d.waited.Round(time.Millisecond), why)
+			continue
+		}
+		if ra.answer(requestTime, d.peer) && ra.OnStaleDeferral != nil {
+			ra.OnStaleDeferral(d.peer, reason, d.waited)
+		}
+	}
+}
+
+// probeDeferral asks peer whether it still owes a reply to the request made
+// at requestTime. It reports a reason when the deferral is stale, and
+// "unreachable" for a member it cannot reach, whose deferral stands.
+func (ra *RAManager) probeDeferral(peer string, requestTime int) (string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), raProbeTimeout)
+	defer cancel()
+	var owed bool
+	err := ra.Client.CallContext(ctx, peer, "NodeRPC.RADeferralStatus",
+		RAMessage{Timestamp: requestTime, NodeID: ra.NodeID, TargetAddress: peer}, &owed)
+	var se rpc.ServerError
+	switch {
+	case errors.As(err, &se):
+		// An older build without the probe: alive, so keep waiting.
+		return "", false
+	case err != nil:
+		if ra.IsMember != nil && !ra.IsMember(peer) {
+			return "left_cluster", true
+		}
+		return "unreachable", false
+	case !owed:
+		return "not_owed", true
+	}
+	return "", false
+}
+
+// Owes reports whether this node holds back a reply to req.
+func (ra *RAManager) Owes(req RAMessage) bool {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	for _, d := range ra.DeferredReply {
+		if d.NodeID == req.NodeID && d.Timestamp == req.Timestamp {
+			return true
+		}
+	}
+	return false
+}
+
+// RADeferralStatus answers a requester checking whether its deferral is
+// still held.
+func (rp *NodeRPC) RADeferralStatus(args RAMessage, reply *bool) error {
+	*reply = rp.node.RA.Owes(args)
+	return nil
+}
+
+// noteRATimeout is the RAManager hook for a withdrawn request.
+func (n *Node) noteRATimeout(missing []string, waited time.Duration) {
+	n.Metrics.Inc("ra_request_timeouts_total")
+	names := make([]string, len(missing))
+	for i, p := range missing {
+		names[i] = n.peerName(p)
+	}
+	n.Alerts.Notify("ra_request_timeout", SeverityWarning,
+		fmt.Sprintf("Gave up on the critical section after %s: no reply from %s", waited, strings.Join(names, ", ")))
+}
+
+// noteStaleDeferral is the RAManager hook for a broken deferral.
+func (n *Node) noteStaleDeferral(peer, reason string, waited time.Duration) {
+	n.Metrics.Inc(metricName("ra_deferrals_broken_total", "reason", reason))
+	log.Printf("[%s] 🔓 Broke stale RA deferral from %s after %s (%s)\n", n.ID, peer, waited.Round(time.Millisecond), reason)
+	n.Alerts.Notify("ra_stale_deferral", SeverityWarning,
+		fmt.Sprintf("Stopped waiting for %s's critical-section reply after %s (%s)", peer, waited.Round(time.Second), reason))
+}
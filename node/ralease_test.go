@@ -0,0 +1,54 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBidProceedsAfterCSHolderRestarts(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	a.RA.DeferralLease = 300 * time.Millisecond
+
+	if err := b.RA.RequestCS(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan CoordinatorBidReply, 1)
+	go func() {
+		done <- a.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+	}()
+	waitFor(t, "B to defer A", func() bool { return len(a.RA.Status().DeferredBy) == 1 })
+
+	// B crashes inside the critical section. While it cannot be reached it
+	// may still be in there, so A keeps waiting past the lease.
+	b.kill()
+	select {
+	case reply := <-done:
+		t.Fatalf("bid finished while the CS holder was unreachable: %s %q", reply.Code, reply.Message)
+	case <-time.After(a.RA.DeferralLease + 2*raDeferralCheck):
+	}
+
+	// B comes back with no memory of A's request; the next probe finds the
+	// deferral stale and the bid goes ahead within one lease.
+	b.restart(t)
+	bound := a.RA.DeferralLease + raDeferralCheck + time.Second
+	select {
+	case reply := <-done:
+		if reply.Code != BidCommitted {
+			t.Fatalf("reply = %s %q, want committed", reply.Code, reply.Message)
+		}
+	case <-time.After(bound):
+		t.Fatalf("bid still blocked %s after the CS holder restarted", bound)
+	}
+	if got := a.Metrics.Counter(metricName("ra_deferrals_broken_total", "reason", "not_owed")); got != 1 {
+		t.Errorf("ra_deferrals_broken_total{reason=not_owed} = %v, want 1", got)
+	}
+	if got := highestBid(c.Node); got != 50 {
+		t.Errorf("C's highest bid = %d, want 50", got)
+	}
+}
@@ -0,0 +1,107 @@
+package node
+
+// ratelimit.go — Coarse per-client-IP token bucket for anonymous read endpoints.
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	readRateLimitPerSec = 20.0 // sustained requests/second per IP
+	readRateLimitBurst  = 60.0 // bucket size; lets a fresh tab load everything at once
+	rateLimitIdleTTL    = 5 * time.Minute
+)
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// ipRateLimiter hands out tokens per remote IP. Idle buckets are swept lazily.
+type ipRateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newIPRateLimiter(rate, burst float64) *ipRateLimiter {
+	return &ipRateLimiter{
+		rate:      rate,
+		burst:     burst,
+		buckets:   map[string]*tokenBucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// SetRate changes the refill rate and bucket size; existing buckets keep
+// their tokens, capped at the new burst.
+func (l *ipRateLimiter) SetRate(rate, burst float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = burst
+	for _, b := range l.buckets {
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+}
+
+// Allow reports whether the client may proceed, consuming one token if so.
+func (l *ipRateLimiter) Allow(ip string) bool {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > rateLimitIdleTTL {
+		for key, b := range l.buckets {
+			if now.Sub(b.lastSeen) > rateLimitIdleTTL {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[ip] = b
+	}
+	b.tokens += now.Sub(b.lastSeen).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.lastSeen = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// clientIP extracts the remote IP (without port) from a request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitReads wraps an anonymous read handler with the per-IP limiter.
+func (n *Node) limitReads(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !n.readLimiter.Allow(clientIP(r)) {
+			n.Metrics.Inc(metricName("http_rate_limited_total", "path", r.URL.Path))
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,498 @@
+package node
+
+// rebuild.go — POST /admin/rebuild: rebuild a broken follower from the
+// coordinator.
+//
+// The follower moves its checkpoint, transaction log, archives and events
+// aside into archive/rebuild_<ID>_<time>/, empties its in-memory state and
+// drops back to Syncing. It then pulls a bootstrap bundle from the
+// coordinator through NodeRPC.BootstrapState: the full queue snapshot plus
+// the archived results, the bid book and the past events. The bundle is
+// gob-encoded and gzipped once per rebuild and fetched in
+// bootstrapChunkSize pieces, so a long history never has to fit in one RPC.
+// The follower checks the bundle's SHA-256 and the state digest of the
+// snapshot inside it before installing anything, saves a checkpoint, and
+// only then becomes Ready. While it runs, the rebuild keeps periodic sync
+// from declaring the node Ready early.
+//
+// The coordinator builds at most maxConcurrentRebuilds bundles at a time.
+// Other followers are told to wait and retry. A session that stops
+// fetching for bootstrapSessionIdle frees its slot.
+//
+// The transaction log is this node's own audit trail and is not copied from
+// the coordinator; the old one stays in the rebuild archive.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	bootstrapChunkSize    = 256 * 1024
+	bootstrapSessionIdle  = 30 * time.Second
+	maxConcurrentRebuilds = 1
+	rebuildWaitTimeout    = 2 * time.Minute // how long a follower waits for a free slot
+	rebuildRetryDelay     = 2 * time.Second
+)
+
+// Rebuild stages, in order. A rebuild ends in RebuildDone or RebuildFailed.
+const (
+	RebuildArchiving   = "archiving"
+	RebuildWaiting     = "waiting" // coordinator busy with other rebuilds
+	RebuildDownloading = "downloading"
+	RebuildVerifying   = "verifying"
+	RebuildInstalling  = "installing"
+	RebuildDone        = "done"
+	RebuildFailed      = "failed"
+)
+
+// RebuildStatus is the progress of the latest rebuild, shown by
+// GET /admin/rebuild, /healthz and /topology.
+type RebuildStatus struct {
+	Stage          string `json:"stage"`
+	Coordinator    string `json:"coordinator,omitempty"`
+	StartedAtUnix  int64  `json:"startedAtUnix"`
+	FinishedAtUnix int64  `json:"finishedAtUnix,omitempty"`
+	ArchivedTo     string `json:"archivedTo,omitempty"` // where the old files went
+	BytesReceived  int    `json:"bytesReceived"`
+	BytesTotal     int    `json:"bytesTotal"`
+	StateDigest    string `json:"stateDigest,omitempty"` // verified digest of the installed state
+	Error          string `json:"error,omitempty"`
+}
+
+type rebuildState struct {
+	mu      sync.Mutex
+	running bool
+	status  *RebuildStatus
+}
+
+// BootstrapArgs asks the coordinator for the next chunk of a bootstrap
+// bundle. An empty Session starts a new one.
+type BootstrapArgs struct {
+	NodeID  string
+	Session string
+	Offset  int
+}
+
+// BootstrapReply carries one chunk. Busy means every rebuild slot is taken.
+type BootstrapReply struct {
+	Busy    bool
+	Message string
+	Session string
+	Total   int    // bundle size in bytes
+	Digest  string // hex SHA-256 of the whole bundle
+	Data    []byte
+}
+
+// bootstrapBundle is everything a rebuilt follower installs.
+type bootstrapBundle struct {
+	Snapshot        QueueSnapshot
+	StateDigest     string // stateDigest(Snapshot) on the coordinator
+	ArchivedResults []archivedResult
+	BidBook         map[string][]BidRecord
+	Events          []HistoricalEvent
+}
+
+type bootstrapSession struct {
+	nodeID   string
+	data     []byte
+	digest   string
+	lastUsed time.Time
+}
+
+type bootstrapServer struct {
+	mu       sync.Mutex
+	sessions map[string]*bootstrapSession
+}
+
+// stateDigest hashes the replicated auction state in snap, leaving out
+// fields that describe the sender rather than the auction. Two nodes with
+// the same digest hold the same auction.
+func stateDigest(snap QueueSnapshot) string {
+	b, _ := json.Marshal(struct {
+		CurrentItem       *AuctionItem
+		CurrentHighestBid int
+		CurrentWinner     string
+		CurrentWinnerID   string
+		DeadlineUnix      int64
+		OpenedAtUnix      int64
+		Active            bool
+		RemainingItems    []AuctionItem
+		Results           []ItemResult
+		ResultsTrimmed    int
+		Announcement      *SoldAnnouncement
+		Shuffle           *ShuffleRecord
+		Budgets           map[string]BidderBudget
+		Paddles           *PaddleBook
+	}{snap.CurrentItem, snap.CurrentHighestBid, snap.CurrentWinner, snap.CurrentWinnerID,
+		snap.DeadlineUnix, snap.OpenedAtUnix, snap.Active, snap.RemainingItems, snap.Results,
+		snap.ResultsTrimmed, snap.Announcement, snap.Shuffle, snap.Budgets, snap.Paddles})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// localStateDigest is stateDigest of this node's current state.
+func (n *Node) localStateDigest() string {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	return stateDigest(n.copyQueueSnapshotLocked())
+}
+
+// ── Coordinator side ──────────────────────────────────────────────────────────
+
+// readArchivedResults returns this node's results archive.
+func (n *Node) readArchivedResults() ([]archivedResult, error) {
+	b, err := os.ReadFile(resultsArchivePath(n.ID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []archivedResult
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var r archivedResult
+		if err := json.Unmarshal(line, &r); err != nil {
+			return nil, fmt.Errorf("results archive: %w", err)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// buildBootstrapBundle encodes the bundle and returns it with its digest.
+func (n *Node) buildBootstrapBundle() ([]byte, string, error) {
+	archived, err := n.readArchivedResults()
+	if err != nil {
+		return nil, "", err
+	}
+	bundle := bootstrapBundle{
+		Snapshot:        n.buildQueueSnapshot(),
+		ArchivedResults: archived,
+		BidBook:         n.bids.snapshot(),
+		Events:          n.listEvents(),
+	}
+	bundle.StateDigest = stateDigest(bundle.Snapshot)
+
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	if err := gob.NewEncoder(zw).Encode(bundle); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(out.Bytes())
+	return out.Bytes(), hex.EncodeToString(sum[:]), nil
+}
+
+// BootstrapState serves a rebuilding follower its bundle, one chunk per call.
+func (rp *NodeRPC) BootstrapState(args BootstrapArgs, reply *BootstrapReply) error {
+	n := rp.node
+	if _, isLocal := n.getCoordinatorAddress(); !isLocal {
+		return errors.New("not the coordinator")
+	}
+	b := &n.bootstrap
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	for id, s := range b.sessions {
+		if now.Sub(s.lastUsed) > bootstrapSessionIdle {
+			log.Printf("[%s] 🧱 Dropping idle bootstrap session of %s\n", n.ID, s.nodeID)
+			delete(b.sessions, id)
+		}
+	}
+
+	s := b.sessions[args.Session]
+	if args.Session == "" {
+		if len(b.sessions) >= maxConcurrentRebuilds {
+			reply.Busy = true
+			reply.Message = fmt.Sprintf("%d rebuild(s) already running; retry shortly", len(b.sessions))
+			n.Metrics.Inc(metricName("bootstrap_sessions_total", "result", "busy"))
+			return nil
+		}
+		data, digest, err := n.buildBootstrapBundle()
+		if err != nil {
+			return fmt.Errorf("build bootstrap bundle: %w", err)
+		}
+		args.Session = fmt.Sprintf("%s-%d", args.NodeID, now.UnixNano())
+		s = &bootstrapSession{nodeID: args.NodeID, data: data, digest: digest}
+		if b.sessions == nil {
+			b.sessions = map[string]*bootstrapSession{}
+		}
+		b.sessions[args.Session] = s
+		n.Metrics.Inc(metricName("bootstrap_sessions_total", "result", "started"))
+		log.Printf("[%s] 🧱 Serving bootstrap bundle to %s (%d bytes)\n", n.ID, args.NodeID, len(data))
+	} else if s == nil {
+		return fmt.Errorf("unknown or expired bootstrap session %s", args.Session)
+	}
+	if args.Offset < 0 || args.Offset > len(s.data) {
+		return fmt.Errorf("offset %d outside bundle of %d bytes", args.Offset, len(s.data))
+	}
+	end := min(args.Offset+bootstrapChunkSize, len(s.data))
+	s.lastUsed = now
+	reply.Session, reply.Total, reply.Digest = args.Session, len(s.data), s.digest
+	reply.Data = s.data[args.Offset:end]
+	if end == len(s.data) {
+		delete(b.sessions, args.Session)
+	}
+	return nil
+}
+
+// ── Follower side ─────────────────────────────────────────────────────────────
+
+// rebuildRunning reports whether a rebuild is in progress.
+func (n *Node) rebuildRunning() bool {
+	n.rebuild.mu.Lock()
+	defer n.rebuild.mu.Unlock()
+	return n.rebuild.running
+}
+
+// rebuildStatus returns a copy of the latest rebuild's progress, or nil.
+func (n *Node) rebuildStatus() *RebuildStatus {
+	n.rebuild.mu.Lock()
+	defer n.rebuild.mu.Unlock()
+	if n.rebuild.status == nil {
+		return nil
+	}
+	s := *n.rebuild.status
+	return &s
+}
+
+func (n *Node) updateRebuild(update func(*RebuildStatus)) {
+	n.rebuild.mu.Lock()
+	update(n.rebuild.status)
+	n.rebuild.mu.Unlock()
+}
+
+// startRebuild begins a rebuild from the coordinator in the background.
+func (n *Node) startRebuild() (int, string) {
+	coordinator, isLocal := n.getCoordinatorAddress()
+	if isLocal {
+		return http.StatusConflict, "This node is the coordinator; rebuild a follower instead"
+	}
+	if coordinator == "" {
+		return http.StatusServiceUnavailable, "Election in progress, please wait"
+	}
+	switch n.Phase() {
+	case PhaseDraining, PhaseStopped:
+		return http.StatusConflict, fmt.Sprintf("Node is %s", n.Phase())
+	}
+	n.rebuild.mu.Lock()
+	if n.rebuild.running {
+		n.rebuild.mu.Unlock()
+		return http.StatusConflict, "A rebuild is already running"
+	}
+	n.rebuild.running = true
+	n.rebuild.status = &RebuildStatus{Stage: RebuildArchiving, Coordinator: coordinator, StartedAtUnix: time.Now().Unix()}
+	n.rebuild.mu.Unlock()
+
+	n.setPhase(PhaseSyncing, "rebuild requested by operator")
+	go n.runRebuild(coordinator)
+	return http.StatusAccepted, "Rebuild started from " + coordinator
+}
+
+func (n *Node) runRebuild(coordinator string) {
+	digest, err := n.rebuildFrom(coordinator)
+	now := time.Now().Unix()
+	n.rebuild.mu.Lock()
+	n.rebuild.running = false
+	if err != nil {
+		n.rebuild.status.Stage, n.rebuild.status.Error = RebuildFailed, err.Error()
+	} else {
+		n.rebuild.status.Stage, n.rebuild.status.StateDigest = RebuildDone, digest
+	}
+	n.rebuild.status.FinishedAtUnix = now
+	n.rebuild.mu.Unlock()
+
+	if err != nil {
+		n.Metrics.Inc(metricName("rebuilds_total", "result", "failed"))
+		log.Printf("[%s] 🧱 Rebuild failed: %v; falling back to periodic sync\n", n.ID, err)
+		if _, isLocal := n.getCoordinatorAddress(); isLocal {
+			n.markSynced("rebuild abandoned; now coordinator")
+		}
+		return
+	}
+	n.Metrics.Inc(metricName("rebuilds_total", "result", "ok"))
+	log.Printf("[%s] 🧱 Rebuilt from %s (state digest %s)\n", n.ID, coordinator, digest[:12])
+	n.markSynced("rebuilt from coordinator " + coordinator)
+}
+
+// rebuildFrom runs every stage and returns the verified state digest.
+func (n *Node) rebuildFrom(coordinator string) (string, error) {
+	dir, err := n.archiveLocalState()
+	if err != nil {
+		return "", fmt.Errorf("archive local state: %w", err)
+	}
+	n.updateRebuild(func(s *RebuildStatus) { s.ArchivedTo, s.Stage = dir, RebuildDownloading })
+	n.resetLocalState()
+
+	data, digest, err := n.downloadBundle(coordinator)
+	if err != nil {
+		return "", err
+	}
+
+	n.updateRebuild(func(s *RebuildStatus) { s.Stage = RebuildVerifying })
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != digest {
+		return "", errors.New("bundle digest mismatch")
+	}
+	var bundle bootstrapBundle
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("gunzip bundle: %w", err)
+	}
+	if err := gob.NewDecoder(zr).Decode(&bundle); err != nil {
+		return "", fmt.Errorf("decode bundle: %w", err)
+	}
+	if got := stateDigest(bundle.Snapshot); got != bundle.StateDigest {
+		return "", fmt.Errorf("state digest mismatch: bundle says %s, snapshot hashes to %s", bundle.StateDigest, got)
+	}
+	if err := validateQueueSnapshot(bundle.Snapshot); err != nil {
+		return "", fmt.Errorf("bundle snapshot: %w", err)
+	}
+
+	n.updateRebuild(func(s *RebuildStatus) { s.Stage = RebuildInstalling })
+	if len(bundle.ArchivedResults) > 0 {
+		lines := make([][]byte, 0, len(bundle.ArchivedResults))
+		for _, r := range bundle.ArchivedResults {
+			if b, err := json.Marshal(r); err == nil {
+				lines = append(lines, b)
+			}
+		}
+		if err := appendLines(resultsArchivePath(n.ID), lines); err != nil {
+			return "", fmt.Errorf("write results archive: %w", err)
+		}
+	}
+	n.bids.restore(bundle.BidBook)
+	for _, ev := range bundle.Events {
+		if _, err := n.storeEvent(ev); err != nil {
+			return "", fmt.Errorf("store event %s: %w", ev.ID, err)
+		}
+	}
+	if !n.applyQueueSnapshot(bundle.Snapshot, "rebuild from "+coordinator) {
+		return "", errors.New("bundle snapshot rejected")
+	}
+	if err := n.takeLocalCheckpoint(); err != nil {
+		return "", fmt.Errorf("checkpoint: %w", err)
+	}
+	return bundle.StateDigest, nil
+}
+
+// downloadBundle fetches the bundle chunk by chunk, waiting for a free slot
+// while the coordinator is busy with other rebuilds.
+func (n *Node) downloadBundle(coordinator string) ([]byte, string, error) {
+	args := BootstrapArgs{NodeID: n.ID}
+	var data []byte
+	waitUntil := time.Now().Add(rebuildWaitTimeout)
+	for {
+		var reply BootstrapReply
+		if err := n.callPeer(coordinator, "NodeRPC.BootstrapState", args, &reply); err != nil {
+			return nil, "", fmt.Errorf("fetch bundle at offset %d: %w", args.Offset, err)
+		}
+		if reply.Busy {
+			if time.Now().After(waitUntil) {
+				return nil, "", fmt.Errorf("coordinator still busy after %s: %s", rebuildWaitTimeout, reply.Message)
+			}
+			n.updateRebuild(func(s *RebuildStatus) { s.Stage = RebuildWaiting })
+			time.Sleep(rebuildRetryDelay)
+			continue
+		}
+		if args.Session == "" {
+			data = make([]byte, 0, reply.Total)
+		}
+		args.Session = reply.Session
+		data = append(data, reply.Data...)
+		args.Offset = len(data)
+		n.updateRebuild(func(s *RebuildStatus) {
+			s.Stage, s.BytesReceived, s.BytesTotal = RebuildDownloading, len(data), reply.Total
+		})
+		if len(data) >= reply.Total {
+			return data, reply.Digest, nil
+		}
+		if len(reply.Data) == 0 {
+			return nil, "", errors.New("coordinator sent an empty chunk")
+		}
+	}
+}
+
+// archiveLocalState moves this node's files into a fresh rebuild archive
+// directory and returns its path. Missing files are skipped.
+func (n *Node) archiveLocalState() (string, error) {
+	dir := filepath.Join(archiveDir, fmt.Sprintf("rebuild_%s_%s", n.ID, time.Now().UTC().Format("20060102T150405Z")))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	move := func(path string) error {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+		return os.Rename(path, filepath.Join(dir, filepath.Base(path)))
+	}
+
+	n.TxnLogMutex.Lock()
+	err := errors.Join(move(txnLogPath(n.ID)), move(txnArchivePath(n.ID)))
+	n.txnLogLines = -1
+	n.TxnLogMutex.Unlock()
+	n.eventsMu.Lock()
+	err = errors.Join(err, move(eventsDir(n.ID)))
+	n.eventsMu.Unlock()
+	n.Queue.mu.Lock()
+	err = errors.Join(err, move(checkpointPath(n.ID)), move(resultsArchivePath(n.ID)), move(checkpointHistoryDir(n.ID)))
+	n.Queue.mu.Unlock()
+	if err != nil {
+		return dir, err
+	}
+	log.Printf("[%s] 🧱 Moved local state to %s\n", n.ID, dir)
+	return dir, nil
+}
+
+// resetLocalState empties the queue, the bid book and prepared txns.
+func (n *Node) resetLocalState() {
+	n.Queue.mu.Lock()
+	q := n.Queue
+	q.Queue, q.CurrentItem, q.Results, q.ResultsTrimmed = nil, nil, nil, 0
+	q.CurrentHighestBid, q.CurrentWinner, q.CurrentWinnerID = 0, "", ""
+	q.DeadlineUnix, q.GraceFromUnix, q.OpenedAtUnix, q.Active = 0, 0, 0, false
+	q.Announcement, q.LastShuffle, q.Budgets, q.Paddles = nil, nil, nil, nil
+	q.touchLocked()
+	n.Queue.mu.Unlock()
+	n.bids.restore(nil)
+	n.TxnMutex.Lock()
+	n.PendingTxns = map[string]PendingTxn{}
+	n.TxnMutex.Unlock()
+}
+
+// handleRebuildRequest serves POST /admin/rebuild (start) and
+// GET /admin/rebuild (progress).
+func (n *Node) handleRebuildRequest(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]interface{}{"phase": n.Phase(), "rebuild": n.rebuildStatus()})
+	case http.MethodPost:
+		code, msg := n.startRebuild()
+		if code != http.StatusAccepted {
+			http.Error(w, msg, code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		writeJSON(w, map[string]interface{}{"message": msg, "rebuild": n.rebuildStatus()})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
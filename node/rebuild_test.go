@@ -0,0 +1,216 @@
+package node
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitRebuild waits for n's rebuild to finish and returns its status.
+func waitRebuild(t *testing.T, n *Node) RebuildStatus {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for n.rebuildRunning() {
+		if time.Now().After(deadline) {
+			t.Fatalf("rebuild of %s still running: %+v", n.ID, n.rebuildStatus())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return *n.rebuildStatus()
+}
+
+// archiveNoise gives n a results archive too big to send in one chunk.
+func archiveNoise(t *testing.T, n *Node, count int) {
+	t.Helper()
+	lines := make([][]byte, count)
+	for i := range lines {
+		noise := make([]byte, 512)
+		rand.Read(noise)
+		b, err := json.Marshal(archivedResult{Index: i, Result: ItemResult{Item: AuctionItem{ID: "old", Description: hex.EncodeToString(noise)}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines[i] = b
+	}
+	if err := appendLines(resultsArchivePath(n.ID), lines); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRebuildCorruptFollower(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	for _, bid := range []BidArgs{{BidderID: "b1", Amount: 15}, {BidderID: "b2", Amount: 20}} {
+		bid.DisplayName, bid.ItemID = bid.BidderID, "lot1"
+		if r := a.submitBid(context.Background(), bid); r.Code != BidCommitted {
+			t.Fatalf("%s at $%d = %s", bid.BidderID, bid.Amount, r.Code)
+		}
+	}
+	a.Queue.mu.Lock()
+	a.finalizeCurrentItemLocked()
+	a.Queue.mu.Unlock()
+	a.startNextItem()
+	archiveNoise(t, a.Node, 600)
+	ev := newHistoricalEvent([]ItemResult{{Item: AuctionItem{ID: "lot0"}, WinningBid: 5, ClosedAtUnix: 1}}, "completed", a.ID, nil)
+	if _, err := a.storeEvent(*ev); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "B to follow A", func() bool { return b.localStateDigest() == a.localStateDigest() })
+
+	// B's disk and memory go bad.
+	if err := b.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(checkpointPath(b.ID), []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b.Queue.mu.Lock()
+	b.Queue.CurrentHighestBid, b.Queue.CurrentWinner, b.Queue.Results = 999, "nobody", nil
+	b.Queue.mu.Unlock()
+	b.bids.restore(nil)
+	if b.localStateDigest() == a.localStateDigest() {
+		t.Fatal("corruption left B's digest unchanged")
+	}
+
+	rec := httptest.NewRecorder()
+	b.handleRebuildRequest(rec, httptest.NewRequest(http.MethodPost, "/admin/rebuild", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /admin/rebuild: %d %s", rec.Code, rec.Body)
+	}
+	status := waitRebuild(t, b.Node)
+	if status.Stage != RebuildDone || status.Error != "" {
+		t.Fatalf("rebuild = %+v", status)
+	}
+	if status.BytesTotal <= bootstrapChunkSize || status.BytesReceived != status.BytesTotal {
+		t.Errorf("received %d of %d bytes; want a bundle of several chunks", status.BytesReceived, status.BytesTotal)
+	}
+
+	// B now matches A, and its old files are kept aside.
+	want := a.localStateDigest()
+	if got := b.localStateDigest(); got != want || status.StateDigest != want {
+		t.Errorf("digests: B %s, rebuild %s, A %s", got, status.StateDigest, want)
+	}
+	if code, h := healthz(t, b.Node); code != http.StatusOK || h.Phase != PhaseReady || h.Rebuild == nil || h.Rebuild.Stage != RebuildDone {
+		t.Errorf("/healthz after rebuild: %d %+v", code, h)
+	}
+	if got, want := summary(myBidsOn(t, b.Node, "b1", "").Bids), summary(myBidsOn(t, a.Node, "b1", "").Bids); !reflect.DeepEqual(got, want) || len(got) != 1 {
+		t.Errorf("b1's bids on B = %v, want %v", got, want)
+	}
+	if events := b.listEvents(); len(events) != 1 || events[0].ID != ev.ID {
+		t.Errorf("B's events = %+v", events)
+	}
+	archived, err := b.readArchivedResults()
+	if err != nil || len(archived) != 600 {
+		t.Errorf("B's results archive: %d entries, %v", len(archived), err)
+	}
+	if old, err := os.ReadFile(filepath.Join(status.ArchivedTo, filepath.Base(checkpointPath(b.ID)))); err != nil || string(old) != "{not json" {
+		t.Errorf("old checkpoint in %s: %q %v", status.ArchivedTo, old, err)
+	}
+	if _, err := loadCheckpoint(b.ID); err != nil {
+		t.Errorf("new checkpoint: %v", err)
+	}
+	if got := b.Metrics.Counter(metricName("rebuilds_total", "result", "ok")); got != 1 {
+		t.Errorf("rebuilds_total{result=ok} = %v, want 1", got)
+	}
+
+	// The coordinator itself cannot be rebuilt.
+	rec = httptest.NewRecorder()
+	a.handleRebuildRequest(rec, httptest.NewRequest(http.MethodPost, "/admin/rebuild", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("rebuild of the coordinator: %d, want 409", rec.Code)
+	}
+}
+
+func TestBootstrapThrottle(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a := nodes[0]
+	for _, tn := range nodes {
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	archiveNoise(t, a.Node, 600)
+	rp := &NodeRPC{node: a.Node}
+	fetch := func(args BootstrapArgs) BootstrapReply {
+		t.Helper()
+		var reply BootstrapReply
+		if err := rp.BootstrapState(args, &reply); err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	first := fetch(BootstrapArgs{NodeID: "B"})
+	if first.Busy || len(first.Data) != bootstrapChunkSize || first.Total <= bootstrapChunkSize {
+		t.Fatalf("first chunk: busy=%v %d of %d bytes", first.Busy, len(first.Data), first.Total)
+	}
+	if second := fetch(BootstrapArgs{NodeID: "C"}); !second.Busy || second.Data != nil {
+		t.Errorf("second rebuild while B's runs: busy=%v", second.Busy)
+	}
+	if got := a.Metrics.Counter(metricName("bootstrap_sessions_total", "result", "busy")); got != 1 {
+		t.Errorf("bootstrap_sessions_total{result=busy} = %v, want 1", got)
+	}
+
+	// B fetches the rest; its last chunk frees the slot.
+	data := first.Data
+	for len(data) < first.Total {
+		data = append(data, fetch(BootstrapArgs{NodeID: "B", Session: first.Session, Offset: len(data)}).Data...)
+	}
+	var reply BootstrapReply
+	if err := rp.BootstrapState(BootstrapArgs{NodeID: "B", Session: first.Session, Offset: len(data)}, &reply); err == nil {
+		t.Error("finished session still open")
+	}
+	third := fetch(BootstrapArgs{NodeID: "C"})
+	if third.Busy {
+		t.Fatal("slot not freed after B finished")
+	}
+
+	// A session that stops fetching gives its slot up.
+	a.bootstrap.mu.Lock()
+	a.bootstrap.sessions[third.Session].lastUsed = time.Now().Add(-bootstrapSessionIdle - time.Second)
+	a.bootstrap.mu.Unlock()
+	if fourth := fetch(BootstrapArgs{NodeID: "D"}); fourth.Busy {
+		t.Error("idle session still holds the slot")
+	}
+
+	// Followers do not serve bundles.
+	if err := (&NodeRPC{node: nodes[1].Node}).BootstrapState(BootstrapArgs{NodeID: "C"}, &reply); err == nil || !strings.Contains(err.Error(), "not the coordinator") {
+		t.Errorf("follower BootstrapState: %v", err)
+	}
+}
+
+// tamperedBootstrap serves a bundle that does not match its digest.
+type tamperedBootstrap struct{}
+
+func (tamperedBootstrap) BootstrapState(_ BootstrapArgs, reply *BootstrapReply) error {
+	reply.Session, reply.Total, reply.Digest, reply.Data = "s", 3, strings.Repeat("0", 64), []byte("abc")
+	return nil
+}
+
+func TestRebuildRejectsTamperedBundle(t *testing.T) {
+	addr := serveAsNodeRPC(t, tamperedBootstrap{})
+	n := followerOf(t, "X", addr)
+	if code, msg := n.startRebuild(); code != http.StatusAccepted {
+		t.Fatalf("startRebuild: %d %s", code, msg)
+	}
+	status := waitRebuild(t, n)
+	if status.Stage != RebuildFailed || !strings.Contains(status.Error, "digest mismatch") {
+		t.Errorf("rebuild = %+v, want failed on the digest", status)
+	}
+	if n.Phase() == PhaseReady {
+		t.Error("node went Ready on a bundle that failed verification")
+	}
+}
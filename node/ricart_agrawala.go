@@ -1,28 +1,107 @@
 package node
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
+// DefaultRARequestTimeout bounds how long RequestCS waits for the peers'
+// replies once its request is out (--ra-timeout). It is well above a
+// deferral lease plus a probe, so a dead holder is found by its lease first.
+const DefaultRARequestTimeout = 15 * time.Second
+
+// ErrCSTimeout is returned by RequestCS when some peer neither replied nor
+// could be shown dead before RequestTimeout. The request is withdrawn.
+var ErrCSTimeout = errors.New("timed out waiting for the critical section")
+
 type RAMessage struct {
 	Timestamp     int
 	NodeID        string
-	SenderAddress string // TCP address for deferred replies
+	SenderAddress string // advertised RPC address for deferred replies
+	TargetAddress string // the receiver's address as the requester knows it; echoed in the deferred reply
+	WaitedMs      int64  // the requester's accumulated wait, when aging; see rafairness.go
+	AgeCredit     int    // Lamport ticks the request is moved ahead by
+}
+
+// raDeferral is a request this node answers when it leaves the CS.
+type raDeferral struct {
+	Address   string // where to send the reply
+	NodeID    string
+	Timestamp int
+	Target    string // RAMessage.TargetAddress of the request
+}
+
+// raPeer tracks one peer's answer to the current request.
+type raPeer struct {
+	answered   bool
+	deferredAt time.Time // set while the peer holds our reply back
+	probedAt   time.Time // last liveness probe of a deferral; see ralease.go
 }
 
 type RAManager struct {
+	local         sync.Mutex // one local request at a time; held from RequestCS to ReleaseCS
 	mu            sync.Mutex
 	NodeID        string
-	Address       string
+	Address       string // advertised RPC address, sent as SenderAddress; set by Node.Start
 	Peers         []string
 	Clock         *LamportClock
 	RequestTime   int
 	RequestingCS  bool
 	RepliesNeeded int
-	DeferredReply []string
+	DeferredReply []raDeferral
 	Client        *RPCClient
-	ReplyChan     chan struct{}
+	ReplyChan     chan struct{}    // closed when the current request has every reply
+	requestCtx    context.Context  // cancelled when the current request ends
+	async         *asyncDispatcher // deferred replies; set by NewNode
+	requestedAt   time.Time        // wall clock of the current request, for Status
+	enteredAt     time.Time        // zero until the current request holds the CS
+	ageCredit     int              // the current request's age credit
+	replies       map[string]*raPeer
+	stats         RAStats
+	carriedWait   time.Duration // accumulated long waits, for aging
+
+	// DeferralLease is how long a peer may hold back its reply before its
+	// liveness is checked; see ralease.go.
+	DeferralLease time.Duration
+	// OnStaleDeferral is called when a deferral is broken; set by NewNode.
+	OnStaleDeferral func(peer, reason string, waited time.Duration)
+	// IsMember reports whether address is still in the cluster; set by
+	// NewNode. An unreachable peer's deferral is broken only once it is not.
+	IsMember func(address string) bool
+	// RequestTimeout is how long RequestCS waits for replies; 0 waits
+	// forever. OnTimeout, set by NewNode, is told which peers were missing.
+	RequestTimeout time.Duration
+	OnTimeout      func(missing []string, waited time.Duration)
+	// AgingThreshold turns priority aging on: requests that waited longer
+	// than this earn the next one an age credit; see rafairness.go. 0 is off.
+	AgingThreshold time.Duration
+	// ResolvePeer maps a node ID to its RPC address, or "" if unknown; set
+	// by NewNode. It serves peers whose requests carry no SenderAddress.
+	ResolvePeer func(nodeID string) string
+	// addrs is each requester's address as of its latest request, so a
+	// deferred reply follows a peer that restarted on a new address.
+	addrs map[string]string
+}
+
+// RAStatus is a read-only view of the RA state, for /admin/inflight and
+// /mutex.
+type RAStatus struct {
+	Requesting         bool     `json:"requesting"`
+	InCriticalSection  bool     `json:"inCriticalSection"`
+	RequestTime        int      `json:"requestTime,omitempty"` // Lamport timestamp of the request
+	WaitingMs          int64    `json:"waitingMs,omitempty"`   // requested but not yet entered
+	HeldMs             int64    `json:"heldMs,omitempty"`      // time inside the CS so far
+	RepliesOutstanding int      `json:"repliesOutstanding"`
+	Awaiting           []string `json:"awaiting,omitempty"` // peers that have not replied yet
+	DeferredPeers      []string `json:"deferredPeers"`      // answered when the CS is released
+	DeferredBy         []string `json:"deferredBy"`         // peers holding back their reply to our request
+	Peers              int      `json:"peers"`
 }
 
 func NewRAManager(nodeID, address string, peers []string, clock *LamportClock, client *RPCClient) *RAManager {
@@ -32,45 +111,256 @@ func NewRAManager(nodeID, address string, peers []string, clock *LamportClock, c
 		Peers:     peers,
 		Clock:     clock,
 		Client:    client,
-		ReplyChan: make(chan struct{}, len(peers)),
+		ReplyChan: make(chan struct{}),
+
+		DeferralLease:  DefaultRADeferralLease,
+		RequestTimeout: DefaultRARequestTimeout,
+	}
+}
+
+// UpdatePeers replaces the peer set. A request in flight follows it: a
+// peer removed is counted as having replied, and a peer added is asked too,
+// since it could otherwise enter alongside us.
+func (ra *RAManager) UpdatePeers(peers []string) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	ra.Peers = append([]string(nil), peers...)
+	if !ra.RequestingCS || ra.replies == nil {
+		return
+	}
+	keep := make(map[string]bool, len(peers))
+	for _, p := range peers {
+		keep[p] = true
+		if _, asked := ra.replies[p]; !asked && ra.RepliesNeeded > 0 {
+			ra.replies[p] = &raPeer{}
+			ra.RepliesNeeded++
+			go ra.ask(ra.requestCtx, p, ra.requestMessage(p))
+		}
+	}
+	for p, st := range ra.replies {
+		if !keep[p] && !st.answered {
+			log.Printf("[%s] %s left the cluster; counting its RA reply as given\n", ra.NodeID, p)
+			ra.answerLocked(ra.RequestTime, p)
+		}
 	}
 }
 
-func (ra *RAManager) RequestCS() {
+// RequestCS blocks until this node holds the critical section, and then
+// the caller must call ReleaseCS. The request state is per node, so
+// concurrent local callers (bids, admin mutations, a leadership transfer)
+// queue on ra.local first instead of overwriting each other's request.
+//
+// If some peer has neither replied nor been shown dead RequestTimeout after
+// the request went out, typically because the call to it hangs, the
+// request is withdrawn and an error wrapping ErrCSTimeout is returned. The
+// caller must not call ReleaseCS then. Time alone never grants the CS.
+func (ra *RAManager) RequestCS() error {
+	ra.local.Lock()
 	ra.mu.Lock()
 	ra.RequestingCS = true
 	ra.RequestTime = ra.Clock.Tick()
-	ra.RepliesNeeded = len(ra.Peers)
+	peers := append([]string(nil), ra.Peers...)
+	ra.RepliesNeeded = len(peers)
+	// A fresh channel per request, so a reply counted just as an earlier
+	// request timed out cannot be read as one for this request.
+	ra.ReplyChan = make(chan struct{})
+	replyCh := ra.ReplyChan
+	requestTime := ra.RequestTime
+	timeout := ra.RequestTimeout
+	// Cancelled on return, so calls still hanging after a timeout give up.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ra.requestCtx = ctx
+	ra.requestedAt, ra.enteredAt = time.Now(), time.Time{}
+	ra.ageCredit = ra.ageCreditLocked()
+	credit, waited := ra.ageCredit, ra.carriedWait
+	requests := make(map[string]RAMessage, len(peers))
+	for _, p := range peers {
+		requests[p] = ra.requestMessage(p)
+	}
+	if credit > 0 {
+		ra.stats.AgedRequests++
+	}
+	ra.replies = make(map[string]*raPeer, len(peers))
+	for _, p := range peers {
+		ra.replies[p] = &raPeer{}
+	}
 	ra.mu.Unlock()
 
-	log.Printf("[%s] Requesting Critical Section at Time %d\n", ra.NodeID, ra.RequestTime)
+	if len(peers) == 0 {
+		// Single node: nobody to ask.
+		ra.markEntered()
+		return nil
+	}
 
-	for _, peer := range ra.Peers {
-		go func(p string) {
-			req := RAMessage{Timestamp: ra.RequestTime, NodeID: ra.NodeID, SenderAddress: ra.Address}
-			var reply bool
-			err := ra.Client.Call(p, "NodeRPC.HandleRARequest", req, &reply)
-			if err != nil {
-				log.Printf("[%s] Failed to contact %s: %v", ra.NodeID, p, err)
-				ra.HandleRAReply() // Proceed even if node is down
-			} else if reply {
-				ra.HandleRAReply()
-			}
-		}(peer)
+	if credit > 0 {
+		log.Printf("[%s] Requesting Critical Section at Time %d, aged by %d after waiting %s\n",
+			ra.NodeID, requestTime, credit, waited.Round(time.Millisecond))
+	} else {
+		log.Printf("[%s] Requesting Critical Section at Time %d\n", ra.NodeID, requestTime)
+	}
+
+	for p, req := range requests {
+		go ra.ask(ctx, p, req)
 	}
 
-	for i := 0; i < len(ra.Peers); i++ {
-		<-ra.ReplyChan
+	if !ra.awaitReplies(replyCh, requestTime, timeout) {
+		return ra.withdraw(requestTime, timeout)
 	}
+	ra.markEntered()
 	log.Printf("[%s] Entered Critical Section\n", ra.NodeID)
+	return nil
+}
+
+// requestMessage is the current request as sent to peer. Must hold ra.mu.
+func (ra *RAManager) requestMessage(peer string) RAMessage {
+	return RAMessage{
+		Timestamp: ra.RequestTime, NodeID: ra.NodeID, SenderAddress: ra.Address, TargetAddress: peer,
+		WaitedMs: ra.carriedWait.Milliseconds(), AgeCredit: ra.ageCredit,
+	}
+}
+
+// ask sends req to peer and counts its answer.
+func (ra *RAManager) ask(ctx context.Context, peer string, req RAMessage) {
+	var reply bool
+	err := ra.Client.CallContext(ctx, peer, "NodeRPC.HandleRARequest", req, &reply)
+	switch {
+	case ctx.Err() != nil:
+		// RequestCS returned; the answer no longer matters.
+	case err != nil:
+		log.Printf("[%s] Failed to contact %s: %v", ra.NodeID, peer, err)
+		ra.answer(req.Timestamp, peer) // Proceed even if node is down
+	case reply:
+		ra.answer(req.Timestamp, peer)
+	default:
+		ra.noteDeferred(req.Timestamp, peer)
+	}
+}
+
+// withdraw gives up the request made at requestTime after its timeout and
+// answers the requests deferred meanwhile. Replies that arrive later are
+// dropped by answerLocked.
+func (ra *RAManager) withdraw(requestTime int, waited time.Duration) error {
+	ra.mu.Lock()
+	missing := ra.awaitingLocked()
+	ra.noteWaitLocked(time.Since(ra.requestedAt), false)
+	ra.mu.Unlock()
+	log.Printf("[%s] ⚠️  Gave up on the critical section (request %d) after %s: no reply from %s\n",
+		ra.NodeID, requestTime, waited, strings.Join(missing, ", "))
+	if ra.OnTimeout != nil {
+		ra.OnTimeout(missing, waited)
+	}
+	ra.ReleaseCS()
+	return fmt.Errorf("%w: no reply from %s within %s", ErrCSTimeout, strings.Join(missing, ", "), waited)
+}
+
+// awaitingLocked lists the peers that have not answered the current
+// request. Must hold ra.mu.
+func (ra *RAManager) awaitingLocked() []string {
+	awaiting := []string{}
+	for p, st := range ra.replies {
+		if !st.answered {
+			awaiting = append(awaiting, p)
+		}
+	}
+	sort.Strings(awaiting)
+	return awaiting
+}
+
+func (ra *RAManager) markEntered() {
+	ra.mu.Lock()
+	ra.enteredAt = time.Now()
+	ra.noteWaitLocked(ra.enteredAt.Sub(ra.requestedAt), true)
+	ra.mu.Unlock()
+}
+
+// Status returns a copy of the current RA state.
+func (ra *RAManager) Status() RAStatus {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	s := RAStatus{
+		Requesting:    ra.RequestingCS,
+		DeferredPeers: []string{},
+		DeferredBy:    []string{},
+		Peers:         len(ra.Peers),
+	}
+	for _, d := range ra.DeferredReply {
+		if d.Address != "" {
+			s.DeferredPeers = append(s.DeferredPeers, d.Address)
+		} else {
+			s.DeferredPeers = append(s.DeferredPeers, d.NodeID)
+		}
+	}
+	if !ra.RequestingCS {
+		return s
+	}
+	for p, st := range ra.replies {
+		if !st.answered && !st.deferredAt.IsZero() {
+			s.DeferredBy = append(s.DeferredBy, p)
+		}
+	}
+	sort.Strings(s.DeferredBy)
+	s.RequestTime = ra.RequestTime
+	if ra.enteredAt.IsZero() {
+		s.WaitingMs = time.Since(ra.requestedAt).Milliseconds()
+		s.RepliesOutstanding = max(ra.RepliesNeeded, 0)
+		s.Awaiting = ra.awaitingLocked()
+	} else {
+		s.InCriticalSection = true
+		s.HeldMs = time.Since(ra.enteredAt).Milliseconds()
+	}
+	return s
 }
 
-func (ra *RAManager) HandleRAReply() {
+// answer counts peer's reply to the request made at requestTime. Each peer
+// counts once per request; replies to an earlier request are dropped.
+func (ra *RAManager) answer(requestTime int, peer string) bool {
 	ra.mu.Lock()
 	defer ra.mu.Unlock()
+	return ra.answerLocked(requestTime, peer)
+}
+
+func (ra *RAManager) answerLocked(requestTime int, peer string) bool {
+	st := ra.replies[peer]
+	if !ra.RequestingCS || ra.RequestTime != requestTime || st == nil || st.answered {
+		return false
+	}
+	st.answered = true
 	ra.RepliesNeeded--
-	if ra.RepliesNeeded >= 0 {
-		ra.ReplyChan <- struct{}{}
+	if ra.RepliesNeeded == 0 {
+		close(ra.ReplyChan)
+	}
+	return true
+}
+
+// noteDeferred records that peer holds back its reply to the request made
+// at requestTime, unless the deferred reply has already overtaken the call.
+func (ra *RAManager) noteDeferred(requestTime int, peer string) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if st := ra.replies[peer]; ra.RequestingCS && ra.RequestTime == requestTime && st != nil && !st.answered {
+		st.deferredAt = time.Now()
+		ra.stats.DeferralsReceived++
+	}
+}
+
+// HandleRAReply counts a deferred reply.
+func (ra *RAManager) HandleRAReply(msg RAMessage) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if msg.Timestamp != 0 && msg.TargetAddress != "" {
+		if !ra.answerLocked(msg.Timestamp, msg.TargetAddress) {
+			log.Printf("[%s] Ignoring stale RA reply from %s (request %d)\n", ra.NodeID, msg.NodeID, msg.Timestamp)
+		}
+		return
+	}
+	// Older peers do not say which request they answer; credit any peer
+	// that deferred the current one.
+	for p, st := range ra.replies {
+		if !st.answered && !st.deferredAt.IsZero() {
+			ra.answerLocked(ra.RequestTime, p)
+			return
+		}
 	}
 }
 
@@ -80,15 +370,33 @@ func (ra *RAManager) ReceiveRequest(req RAMessage) bool {
 
 	ra.Clock.Update(req.Timestamp)
 
-	deferReply := ra.RequestingCS && ((ra.RequestTime < req.Timestamp) || (ra.RequestTime == req.Timestamp && ra.NodeID < req.NodeID))
+	// Once every reply is in, every request waits, whatever its timestamp:
+	// a peer added during our request may ask with an older one.
+	deferReply := ra.RequestingCS && (ra.RepliesNeeded == 0 ||
+		raPrecedes(ra.RequestTime, ra.ageCredit, ra.NodeID, req.Timestamp, req.AgeCredit, req.NodeID))
+
+	if req.SenderAddress != "" {
+		if ra.addrs == nil {
+			ra.addrs = map[string]string{}
+		}
+		ra.addrs[req.NodeID] = req.SenderAddress
+	}
+	// A node asks for one CS at a time, so a new request from it means any
+	// other one we hold was abandoned: it restarted, or broke our deferral.
+	// A restarted node's clock may even be behind, so timestamps are not
+	// compared; a retried request replaces itself.
+	kept := ra.DeferredReply[:0]
+	for _, d := range ra.DeferredReply {
+		if d.NodeID != req.NodeID {
+			kept = append(kept, d)
+		}
+	}
+	ra.DeferredReply = kept
 
 	if deferReply {
 		log.Printf("[%s] Deferring reply to %s\n", ra.NodeID, req.NodeID)
-		addr := req.SenderAddress
-		if addr == "" {
-			addr = req.NodeID // fallback for backwards compatibility
-		}
-		ra.DeferredReply = append(ra.DeferredReply, addr)
+		ra.stats.DeferralsIssued++
+		ra.DeferredReply = append(ra.DeferredReply, raDeferral{Address: req.SenderAddress, NodeID: req.NodeID, Timestamp: req.Timestamp, Target: req.TargetAddress})
 		return false
 	}
 	log.Printf("[%s] Replying to %s immediately\n", ra.NodeID, req.NodeID)
@@ -98,15 +406,45 @@ func (ra *RAManager) ReceiveRequest(req RAMessage) bool {
 func (ra *RAManager) ReleaseCS() {
 	ra.mu.Lock()
 	ra.RequestingCS = false
+	ra.enteredAt = time.Time{}
+	ra.replies = nil
 	deferred := ra.DeferredReply
 	ra.DeferredReply = nil
+	alone := len(ra.Peers) == 0
 	ra.mu.Unlock()
+	ra.local.Unlock()
 
+	if alone && len(deferred) == 0 {
+		return
+	}
 	log.Printf("[%s] Releasing Critical Section, replying to %d deferred requests\n", ra.NodeID, len(deferred))
-	for _, peer := range deferred {
-		go func(p string) {
+	for _, d := range deferred {
+		p := ra.replyAddress(d)
+		if p == "" {
+			// Its deferral lease (ralease.go) will notice the reply is not owed.
+			log.Printf("[%s] No address for %s; cannot send its deferred RA reply\n", ra.NodeID, d.NodeID)
+			continue
+		}
+		msg := RAMessage{NodeID: ra.NodeID, Timestamp: d.Timestamp, TargetAddress: d.Target}
+		ra.async.sendReliable(p, "HandleRADeferredReply", func() error {
 			var reply bool
-			ra.Client.Call(p, "NodeRPC.HandleRADeferredReply", RAMessage{NodeID: ra.NodeID}, &reply)
-		}(peer)
+			return ra.Client.Call(p, "NodeRPC.HandleRADeferredReply", msg, &reply)
+		})
+	}
+}
+
+// replyAddress is where the deferred reply d goes: the address the peer
+// sent with its latest request, else the one resolved from its node ID.
+// Node IDs are never dialled.
+func (ra *RAManager) replyAddress(d raDeferral) string {
+	ra.mu.Lock()
+	addr := ra.addrs[d.NodeID]
+	ra.mu.Unlock()
+	if addr == "" {
+		addr = d.Address
+	}
+	if addr == "" && ra.ResolvePeer != nil {
+		addr = ra.ResolvePeer(d.NodeID)
 	}
+	return addr
 }
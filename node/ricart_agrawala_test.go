@@ -0,0 +1,344 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func repliesNeeded(ra *RAManager) int {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	return ra.RepliesNeeded
+}
+
+// waitFor polls cond until it holds or a couple of seconds pass.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func requestCS(ra *RAManager) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- ra.RequestCS() }()
+	return done
+}
+
+func TestRARetriedRequestGetsOneReply(t *testing.T) {
+	nodes := testCluster(t, "H", "R")
+	h, r := nodes[0], nodes[1]
+
+	if err := h.RA.RequestCS(); err != nil {
+		t.Fatal(err)
+	}
+	hFirst := h.RA.Status().RequestTime
+	rDone := requestCS(r.RA)
+	waitFor(t, "H to defer R", func() bool {
+		s := r.RA.Status()
+		return len(s.DeferredBy) == 1 && len(h.RA.Status().DeferredPeers) == 1
+	})
+	rt := r.RA.Status().RequestTime
+	req := RAMessage{Timestamp: rt, NodeID: r.ID, SenderAddress: r.Address, TargetAddress: h.Address}
+
+	// R retries its request while H still holds the CS.
+	if h.RA.ReceiveRequest(req) {
+		t.Fatal("retried request answered while H holds the CS")
+	}
+	if got := h.RA.Status().DeferredPeers; len(got) != 1 {
+		t.Fatalf("H holds %d deferrals after the retry, want 1: %v", len(got), got)
+	}
+
+	// A reply to an earlier request of R's is not counted.
+	r.RA.HandleRAReply(RAMessage{NodeID: h.ID, Timestamp: rt - 1, TargetAddress: h.Address})
+	if got := repliesNeeded(r.RA); got != 1 {
+		t.Fatalf("RepliesNeeded after a stale reply = %d, want 1", got)
+	}
+
+	h.RA.ReleaseCS()
+	select {
+	case err := <-rDone:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("R never entered the CS")
+	}
+	sent := metricName("rpc_async_total", "peer", r.Address, "method", "HandleRADeferredReply", "result", "ok")
+	waitFor(t, "the deferred reply to be sent", func() bool { return h.Metrics.Counter(sent) >= 1 })
+
+	// The same reply delivered twice counts once.
+	r.RA.HandleRAReply(RAMessage{NodeID: h.ID, Timestamp: rt, TargetAddress: h.Address})
+	if got := repliesNeeded(r.RA); got != 0 {
+		t.Fatalf("RepliesNeeded after a duplicate reply = %d, want 0", got)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := h.Metrics.Counter(sent); got != 1 {
+		t.Errorf("H sent %v deferred replies to R, want 1", got)
+	}
+
+	// H asks again while R holds the CS. Replies left over from H's first
+	// request must not let it in.
+	hDone := requestCS(h.RA)
+	waitFor(t, "R to defer H", func() bool { return len(h.RA.Status().DeferredBy) == 1 })
+	h.RA.HandleRAReply(RAMessage{NodeID: r.ID, Timestamp: hFirst, TargetAddress: r.Address})
+	h.RA.HandleRAReply(RAMessage{NodeID: r.ID, Timestamp: hFirst, TargetAddress: r.Address})
+	select {
+	case <-hDone:
+		t.Fatal("H entered the CS while R holds it")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if s := h.RA.Status(); s.InCriticalSection || len(s.Awaiting) != 1 || s.Awaiting[0] != r.Address {
+		t.Fatalf("H status = %+v, want it still awaiting %s", s, r.Address)
+	}
+
+	r.RA.ReleaseCS()
+	select {
+	case err := <-hDone:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("H never entered the CS after R released it")
+	}
+	h.RA.ReleaseCS()
+}
+
+// awaitCS waits for a RequestCS started with requestCS to return.
+func awaitCS(t *testing.T, who string, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%s never entered the CS", who)
+	}
+}
+
+func TestRADeferredReplyFollowsRestartedPeer(t *testing.T) {
+	nodes := testCluster(t, "H", "R")
+	h, r := nodes[0], nodes[1]
+	if err := h.RA.RequestCS(); err != nil {
+		t.Fatal(err)
+	}
+
+	// R asks from the address it had before restarting...
+	old := closedAddr(t)
+	h.RA.ReceiveRequest(RAMessage{Timestamp: 1, NodeID: r.ID, SenderAddress: old, TargetAddress: h.Address})
+	if got := h.RA.Status().DeferredPeers; len(got) != 1 || got[0] != old {
+		t.Fatalf("H's deferrals = %v, want R at %s", got, old)
+	}
+	// ...and again from its new one before H releases.
+	rDone := requestCS(r.RA)
+	waitFor(t, "H to defer R's new request", func() bool {
+		got := h.RA.Status().DeferredPeers
+		return len(got) == 1 && got[0] == r.Address
+	})
+
+	h.RA.ReleaseCS()
+	awaitCS(t, "R", rDone)
+	sent := metricName("rpc_async_total", "peer", r.Address, "method", "HandleRADeferredReply", "result", "ok")
+	waitFor(t, "the deferred reply to reach R", func() bool { return h.Metrics.Counter(sent) == 1 })
+	for _, peer := range []string{old, r.ID} {
+		for _, result := range []string{"ok", "error"} {
+			if got := h.Metrics.Counter(metricName("rpc_async_total", "peer", peer, "method", "HandleRADeferredReply", "result", result)); got != 0 {
+				t.Errorf("H sent %v deferred replies to %s (%s)", got, peer, result)
+			}
+		}
+	}
+	r.RA.ReleaseCS()
+}
+
+func TestRADeferredReplyResolvesNodeID(t *testing.T) {
+	nodes := testCluster(t, "H", "R")
+	h, r := nodes[0], nodes[1]
+	var resolved []string
+	h.RA.ResolvePeer = func(id string) string {
+		resolved = append(resolved, id)
+		if id == r.ID {
+			return r.Address
+		}
+		return ""
+	}
+	if err := h.RA.RequestCS(); err != nil {
+		t.Fatal(err)
+	}
+
+	// R's build sends no SenderAddress; a peer H cannot place asks too.
+	r.RA.Address = ""
+	rDone := requestCS(r.RA)
+	waitFor(t, "H to defer R", func() bool { return len(h.RA.Status().DeferredPeers) == 1 })
+	h.RA.ReceiveRequest(RAMessage{Timestamp: 1, NodeID: "Ghost", TargetAddress: h.Address})
+	if got := h.RA.Status().DeferredPeers; len(got) != 2 || got[0] != r.ID || got[1] != "Ghost" {
+		t.Fatalf("H's deferrals = %v, want R and Ghost by ID", got)
+	}
+
+	h.RA.ReleaseCS()
+	awaitCS(t, "R", rDone)
+	if len(resolved) != 2 || resolved[0] != r.ID || resolved[1] != "Ghost" {
+		t.Errorf("ResolvePeer asked for %v, want R then Ghost", resolved)
+	}
+	sent := metricName("rpc_async_total", "peer", r.Address, "method", "HandleRADeferredReply", "result", "ok")
+	waitFor(t, "the deferred reply to reach R", func() bool { return h.Metrics.Counter(sent) == 1 })
+	time.Sleep(50 * time.Millisecond)
+	for _, peer := range []string{r.ID, "Ghost"} {
+		for _, result := range []string{"ok", "error"} {
+			if got := h.Metrics.Counter(metricName("rpc_async_total", "peer", peer, "method", "HandleRADeferredReply", "result", result)); got != 0 {
+				t.Errorf("H dialled node ID %s: %v (%s)", peer, got, result)
+			}
+		}
+	}
+	r.RA.ReleaseCS()
+}
+
+func TestRARequestTimesOutOnSilentPeer(t *testing.T) {
+	nodes := testCluster(t, "H", "A", "S")
+	h, a, s := nodes[0], nodes[1], nodes[2]
+	// H's calls to S never get through; S neither replies nor fails.
+	h.Client.SetLatency(LatencyRule{Peer: s.Address, DelayMs: 60_000})
+	h.RA.RequestTimeout = 300 * time.Millisecond
+
+	start := time.Now()
+	hDone := requestCS(h.RA)
+	waitFor(t, "A to answer H", func() bool { return len(h.RA.Status().Awaiting) == 1 })
+	requestTime := h.RA.Status().RequestTime
+	// A asks meanwhile; H's earlier request defers it.
+	aDone := requestCS(a.RA)
+	waitFor(t, "H to defer A", func() bool { return len(h.RA.Status().DeferredPeers) == 1 })
+
+	select {
+	case err := <-hDone:
+		if !errors.Is(err, ErrCSTimeout) || !strings.Contains(err.Error(), s.Address) {
+			t.Fatalf("RequestCS = %v, want ErrCSTimeout naming S", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("RequestCS still blocked on S")
+	}
+	if waited := time.Since(start); waited < h.RA.RequestTimeout || waited > time.Second {
+		t.Errorf("RequestCS returned after %s, want about %s", waited, h.RA.RequestTimeout)
+	}
+	if st := h.RA.Status(); st.Requesting || st.InCriticalSection {
+		t.Errorf("H after the timeout = %+v, want the request withdrawn", st)
+	}
+	if got := h.Metrics.Counter("ra_request_timeouts_total"); got != 1 {
+		t.Errorf("ra_request_timeouts_total = %v, want 1", got)
+	}
+	if _, recent := h.Alerts.snapshot(); len(recent) != 1 || recent[0].Key != "ra_request_timeout" || !strings.Contains(recent[0].Message, s.Address) {
+		t.Errorf("alerts sent = %+v, want one ra_request_timeout naming S", recent)
+	}
+	// Withdrawing answered the request H deferred.
+	awaitCS(t, "A", aDone)
+	a.RA.ReleaseCS()
+
+	// S's answer to the withdrawn request turns up during the next one and
+	// is not counted for it.
+	h.Client.SetLatency(LatencyRule{Peer: s.Address, DelayMs: 200})
+	h.RA.RequestTimeout = 2 * time.Second
+	hDone = requestCS(h.RA)
+	waitFor(t, "H's next request", func() bool { return h.RA.Status().RequestTime > requestTime })
+	h.RA.HandleRAReply(RAMessage{NodeID: s.ID, Timestamp: requestTime, TargetAddress: s.Address})
+	if got := h.RA.Status().Awaiting; !slices.Contains(got, s.Address) {
+		t.Errorf("H awaits %v after S's late reply, want S still among them", got)
+	}
+	awaitCS(t, "H", hDone)
+	h.RA.ReleaseCS()
+
+	// A bid whose round cannot get the CS is turned away as retryable.
+	h.Client.SetLatency(LatencyRule{Peer: s.Address, DelayMs: 60_000})
+	h.RA.RequestTimeout = 300 * time.Millisecond
+	withLotUp(h.Node)
+	setLeader(h.Node, h.ID, h.Address)
+	reply := h.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+	if reply.Code != BidNoQuorum {
+		t.Errorf("bid without the CS = %s %q, want %s", reply.Code, reply.Message, BidNoQuorum)
+	}
+	h.Client.SetLatency(LatencyRule{Peer: s.Address})
+}
+
+func TestRAPeerRemovedDuringRequest(t *testing.T) {
+	nodes := testCluster(t, "H", "A", "S")
+	h, a, s := nodes[0], nodes[1], nodes[2]
+	h.Client.SetLatency(LatencyRule{Peer: s.Address, DelayMs: 60_000})
+	h.RA.RequestTimeout = 5 * time.Second
+
+	hDone := requestCS(h.RA)
+	waitFor(t, "A to answer H", func() bool {
+		got := h.RA.Status().Awaiting
+		return len(got) == 1 && got[0] == s.Address
+	})
+	// S leaves; its reply counts as given.
+	h.RA.UpdatePeers([]string{a.Address})
+	awaitCS(t, "H", hDone)
+	if got := repliesNeeded(h.RA); got != 0 {
+		t.Errorf("RepliesNeeded = %d, want 0", got)
+	}
+	h.RA.ReleaseCS()
+
+	// The next request asks A alone.
+	if err := h.RA.RequestCS(); err != nil {
+		t.Fatal(err)
+	}
+	if s := h.RA.Status(); s.Peers != 1 {
+		t.Errorf("H asks %d peers, want 1", s.Peers)
+	}
+	h.RA.ReleaseCS()
+	h.Client.SetLatency(LatencyRule{Peer: s.Address})
+}
+
+func TestRAPeerAddedDuringRequest(t *testing.T) {
+	nodes := testCluster(t, "H", "A", "N")
+	h, a, n := nodes[0], nodes[1], nodes[2]
+	h.peersMu.Lock()
+	h.Peers = []string{a.Address}
+	h.peersMu.Unlock()
+	h.RA.UpdatePeers([]string{a.Address})
+
+	// N holds the CS, and A waits for it.
+	if err := n.RA.RequestCS(); err != nil {
+		t.Fatal(err)
+	}
+	aDone := requestCS(a.RA)
+	waitFor(t, "N to defer A", func() bool { return len(n.RA.Status().DeferredPeers) == 1 })
+	// H, not knowing N, waits for A's older request.
+	hDone := requestCS(h.RA)
+	waitFor(t, "A to defer H", func() bool { return len(a.RA.Status().DeferredPeers) == 1 })
+
+	// N joins H's peers mid-request; H must wait for it too.
+	if !h.addPeer(n.Address) {
+		t.Fatal("N not added")
+	}
+	waitFor(t, "N to defer H", func() bool { return len(n.RA.Status().DeferredPeers) == 2 })
+	if got := repliesNeeded(h.RA); got != 2 {
+		t.Errorf("RepliesNeeded after N joined = %d, want 2", got)
+	}
+
+	n.RA.ReleaseCS()
+	awaitCS(t, "A", aDone)
+	waitFor(t, "N's deferred reply to H", func() bool {
+		got := h.RA.Status().Awaiting
+		return len(got) == 1 && got[0] == a.Address
+	})
+	if h.RA.Status().InCriticalSection {
+		t.Error("H entered while A is inside")
+	}
+	a.RA.ReleaseCS()
+	awaitCS(t, "H", hDone)
+
+	// Joining while H is inside asks nobody.
+	h.RA.UpdatePeers([]string{a.Address, n.Address, closedAddr(t)})
+	if got := repliesNeeded(h.RA); got != 0 {
+		t.Errorf("RepliesNeeded after a join inside the CS = %d, want 0", got)
+	}
+	h.RA.UpdatePeers([]string{a.Address, n.Address})
+	h.RA.ReleaseCS()
+}
@@ -2,22 +2,49 @@ package node
 
 // rpc.go — All RPC message types and NodeRPC handler methods.
 
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
 // ── Types ─────────────────────────────────────────────────────────────────────
 
 type BidArgs struct {
-	Amount int
-	Bidder string
+	Amount      int
+	Bidder      string // legacy display name; kept in sync with DisplayName
+	BidderID    string // stable identity used for all enforcement
+	DisplayName string
+	Origin      string // ID of the node the client sent the bid to; audit only, never displayed as the bidder
+	ItemID      string // lot the bidder saw; filled with the current item for older clients
+
+	IdempotencyKey string // optional client-supplied key; overrides content-based dedup
+	Canary         bool   // synthetic health-check bid; see canary.go
+
+	// Advisory timing for fairness analysis; never affects ordering or
+	// validity. See bidtiming.go.
+	ClientAtMs        int64 // client-claimed submission time, Unix ms; 0 if absent or discarded
+	ReceivedAtMs      int64 // when the Origin node received the request, Unix ms
+	ClientSkewFlagged bool  // the claim was more than 30s off, or unusable
+
+	// Set by the coordinator when its 2PC round begins; empty from older
+	// builds. See origin.go.
+	Coordinator string
+	Term        int
 }
 
 type PrepareArgs struct {
 	TxnID     string
 	Bid       BidArgs
 	Timestamp int
+	State     *AuthoritativeState // coordinator's current item state; nil from older coordinators
 }
 
 type PrepareReply struct {
-	Vote   bool
-	Reason string
+	Vote       bool
+	Reason     string           // human-readable
+	Rejection  PrepareRejection // set on a NO vote
+	SelfHealed bool             // participant fast-forwarded to State before voting
 }
 
 type DecisionArgs struct {
@@ -25,27 +52,42 @@ type DecisionArgs struct {
 	Commit bool
 	Bid    BidArgs
 	Leader string
+	Term   int // leader's election term when the round began; 0 from older builds
 }
 
 type CoordinatorBidReply struct {
 	Accepted bool
 	Message  string
+	Code     BidCode // outcome class; empty from older coordinators (see withCode)
+
+	// Commits only; empty from older coordinators. See origin.go.
+	TxnID       string
+	Coordinator string
+	Term        int
 }
 
 type AddItemArgs struct {
-	Name          string
-	Description   string
-	StartingPrice int
-	DurationSec   int
+	Name           string
+	Description    string
+	StartingPrice  int
+	DurationSec    int
+	Emoji          string // optional; inferred from the name when blank
+	Category       string // optional; inferred along with the emoji
+	Increments     []IncrementBand
+	AllowedBidders []string          // optional bidder IDs; makes the item invite-only
+	Custom         map[string]string // optional operator metadata
 }
 
 type AuctionControlArgs struct {
-	Action string
+	Action  string
+	DryRun  bool // plan only; nothing is mutated and no RA lock is taken
+	Archive bool // restart only: file the cleared results as a historical event
 }
 
 type CoordinatorActionReply struct {
 	Accepted bool
 	Message  string
+	Plan     *ControlPlan // set for dry runs
 }
 
 type EmptyArgs struct{}
@@ -53,6 +95,7 @@ type EmptyArgs struct{}
 type TakeCheckpointArgs struct {
 	InitiatorID string
 	LamportTime int
+	Term        int // initiator's election term; 0 from older builds
 }
 
 type TakeCheckpointReply struct {
@@ -67,6 +110,7 @@ type KTTentativeArgs struct {
 	LamportTime int
 	From        string
 	Visited     []string
+	Term        int // initiator's election term, passed on unchanged; 0 from older builds
 }
 
 type KTTentativeReply struct {
@@ -84,12 +128,28 @@ type QueueSnapshot struct {
 	CurrentItem       *AuctionItem
 	CurrentHighestBid int
 	CurrentWinner     string
+	CurrentWinnerID   string
 	DeadlineUnix      int64
+	GraceFromUnix     int64 // original deadline when DeadlineUnix is a failover grace; see grace.go
+	OpenedAtUnix      int64
 	Active            bool
 	QueueLen          int
+	Seq               int    // sender's Lamport time when built; see AuthoritativeState
+	Term              int    // sender's election term; 0 from older builds
+	StateVersion      uint64 // sender's queue mutation counter when built; see followersync.go
 	RemainingItems    []AuctionItem
 	Results           []ItemResult
+	ResultsTrimmed    int // older results moved to the archive
 	IsCoordinator     bool
+	NodePhase         NodePhase              // lifecycle phase of the node serving /state
+	BidderStyles      map[string]BidderStyle // display color/avatar per bidder named above
+	Phase             string                 // idle, bidding, paused, sold-announcement, ended
+	Announcement      *SoldAnnouncement
+	Shuffle           *ShuffleRecord          // seed and derivation of the last queue shuffle
+	MinNextBid        int                     // lowest bid the current item accepts
+	BidIncrement      int                     // increment band in force at the current price
+	Budgets           map[string]BidderBudget // by bidder ID; stripped from /state
+	Paddles           *PaddleBook             // paddle registry; stripped from /state
 }
 
 // ── Handlers ──────────────────────────────────────────────────────────────────
@@ -102,25 +162,38 @@ func (rp *NodeRPC) SubmitBidToCoordinator(args BidArgs, reply *CoordinatorBidRep
 
 	if !isCoordinator {
 		reply.Accepted = false
+		reply.Code = BidNoLeader
 		reply.Message = "This node is not the coordinator"
 		return nil
 	}
-	accepted, message := rp.node.ProposeBid(args.Amount, args.Bidder)
-	reply.Accepted = accepted
-	reply.Message = message
+	// net/rpc carries no deadline; the forwarding node stops waiting on its own.
+	*reply = rp.node.ProposeBid(context.Background(), args.withIdentity())
 	return nil
 }
 
 // PrepareBid is Phase-1 of 2PC: a peer votes yes/no on a proposed bid.
 func (rp *NodeRPC) PrepareBid(args PrepareArgs, reply *PrepareReply) error {
 	rp.node.Clock.Update(args.Timestamp)
-	if !rp.node.canPrepareBid(args.Bid) {
+	args.Bid = args.Bid.withIdentity()
+	reply.SelfHealed = rp.node.fastForwardTo(args.State)
+	if ok, reason := rp.node.canPrepareBid(args.Bid); !ok {
+		reply.Vote = false
+		reply.Rejection = reason
+		reply.Reason = reason.Message()
+		rp.node.logTxnEvent(args.TxnID, "TXN_PREPARE_VOTE_NO", string(reason)+": "+reply.Reason)
+		return nil
+	}
+	if reason, holder := rp.node.rememberPendingTxn(args.TxnID, args.Bid); reason != "" {
 		reply.Vote = false
-		reply.Reason = "bid not higher, auction inactive, or time expired"
-		rp.node.logTxnEvent(args.TxnID, "TXN_PREPARE_VOTE_NO", reply.Reason)
+		reply.Rejection = reason
+		reply.Reason = reason.Message()
+		detail := "held by " + holder
+		if reason == RejectDecided {
+			detail = "the decision arrived first"
+		}
+		rp.node.logTxnEvent(args.TxnID, "TXN_PREPARE_VOTE_NO", fmt.Sprintf("%s: %s", reason, detail))
 		return nil
 	}
-	rp.node.rememberPendingTxn(args.TxnID, args.Bid)
 	reply.Vote = true
 	reply.Reason = "prepared"
 	rp.node.logTxnEvent(args.TxnID, "TXN_PREPARE_VOTE_YES", "prepared")
@@ -129,7 +202,17 @@ func (rp *NodeRPC) PrepareBid(args PrepareArgs, reply *PrepareReply) error {
 
 // DecideBid is Phase-2 of 2PC: apply commit or abort.
 func (rp *NodeRPC) DecideBid(args DecisionArgs, reply *bool) error {
-	rp.node.applyDecision(args.TxnID, args.Commit, args.Bid)
+	if err := rp.node.admitDecision(args); err != nil {
+		rp.node.logTxnEvent(args.TxnID, "TXN_DECIDE_REJECTED", fmt.Sprintf("term %d from %s: %v", args.Term, args.Leader, err))
+		*reply = false
+		return nil
+	}
+	bid := args.Bid
+	if bid.Coordinator == "" {
+		// Older coordinators only name themselves in the decision.
+		bid.Coordinator, bid.Term = args.Leader, args.Term
+	}
+	rp.node.applyDecision(args.TxnID, args.Commit, bid)
 	rp.node.logTxnEvent(args.TxnID, "TXN_DECIDE_ACK_SENT", "decision applied and ACK sent")
 	*reply = true
 	return nil
@@ -143,7 +226,12 @@ func (rp *NodeRPC) GetQueueState(_ EmptyArgs, reply *QueueSnapshot) error {
 
 // SyncQueueState lets the coordinator push a state snapshot to followers.
 func (rp *NodeRPC) SyncQueueState(snap QueueSnapshot, reply *bool) error {
-	rp.node.applyQueueSnapshot(snap)
+	if err := rp.node.admitSnapshot(snap, "coordinator push"); err != nil {
+		return err
+	}
+	if !rp.node.applyQueueSnapshot(snap, "pushed snapshot") {
+		return errors.New("snapshot rejected: fails state invariants")
+	}
 	*reply = true
 	return nil
 }
@@ -159,7 +247,7 @@ func (rp *NodeRPC) SubmitAddItemToCoordinator(args AddItemArgs, reply *Coordinat
 		return nil
 	}
 
-	accepted, message := rp.node.addItemAndBroadcast(args.Name, args.Description, args.StartingPrice, args.DurationSec)
+	accepted, message := rp.node.addItemAndBroadcast(args)
 	reply.Accepted = accepted
 	reply.Message = message
 	return nil
@@ -176,13 +264,21 @@ func (rp *NodeRPC) SubmitAuctionControlToCoordinator(args AuctionControlArgs, re
 		return nil
 	}
 
+	if args.DryRun {
+		plan := rp.node.previewAuctionControl(args.Action)
+		reply.Accepted = plan.Accepted
+		reply.Message = plan.Message
+		reply.Plan = &plan
+		return nil
+	}
+
 	var accepted bool
 	var message string
 	switch args.Action {
 	case "start":
 		accepted, message = rp.node.startAuctionAndBroadcast()
 	case "restart":
-		accepted, message = rp.node.restartAuctionAndBroadcast()
+		accepted, message = rp.node.restartAuctionAndBroadcast(args.Archive)
 	case "stop":
 		accepted, message = rp.node.stopAuctionAndBroadcast()
 	default:
@@ -198,13 +294,16 @@ func (rp *NodeRPC) SubmitAuctionControlToCoordinator(args AuctionControlArgs, re
 
 // HandleRARequest handles a Ricart-Agrawala mutual exclusion request.
 func (rp *NodeRPC) HandleRARequest(args RAMessage, reply *bool) error {
+	if rp.node.mutexKind != MutexRA {
+		rp.node.noteMutexMismatch(args.NodeID, MutexRA)
+	}
 	*reply = rp.node.RA.ReceiveRequest(args)
 	return nil
 }
 
 // HandleRADeferredReply sends a deferred RA reply after releasing the CS.
 func (rp *NodeRPC) HandleRADeferredReply(args RAMessage, reply *bool) error {
-	rp.node.RA.HandleRAReply()
+	rp.node.RA.HandleRAReply(args)
 	*reply = true
 	return nil
 }
@@ -212,6 +311,10 @@ func (rp *NodeRPC) HandleRADeferredReply(args RAMessage, reply *bool) error {
 // TakeCheckpoint is called by the coordinator to ask this follower to save its state.
 func (rp *NodeRPC) TakeCheckpoint(args TakeCheckpointArgs, reply *TakeCheckpointReply) error {
 	rp.node.Clock.Update(args.LamportTime)
+	if !rp.node.admitTerm(args.Term, args.InitiatorID, "checkpoint") {
+		reply.Error = errStaleTerm.Error()
+		return nil
+	}
 	if err := rp.node.takeLocalCheckpoint(); err != nil {
 		reply.OK = false
 		reply.Error = err.Error()
@@ -223,6 +326,10 @@ func (rp *NodeRPC) TakeCheckpoint(args TakeCheckpointArgs, reply *TakeCheckpoint
 }
 
 func (rp *NodeRPC) HandleKTTentativeCheckpoint(args KTTentativeArgs, reply *KTTentativeReply) error {
+	if !rp.node.admitTerm(args.Term, args.Initiator, "checkpoint") {
+		reply.Error = errStaleTerm.Error()
+		return nil
+	}
 	ok, participants, errMsg := rp.node.handleKTTentativeRequest(args)
 	reply.OK = ok
 	reply.Error = errMsg
@@ -238,10 +345,13 @@ func (rp *NodeRPC) HandleKTFinalizeCheckpoint(args KTFinalizeArgs, reply *bool)
 
 // HandleBid is a legacy direct-propagation handler, kept for compatibility.
 func (rp *NodeRPC) HandleBid(args BidArgs, reply *bool) error {
+	args = args.withIdentity()
 	rp.node.Queue.mu.Lock()
 	if rp.node.Queue.Active && rp.node.Queue.CurrentItem != nil && args.Amount > rp.node.Queue.CurrentHighestBid {
 		rp.node.Queue.CurrentHighestBid = args.Amount
-		rp.node.Queue.CurrentWinner = args.Bidder
+		rp.node.Queue.CurrentWinner = args.DisplayName
+		rp.node.Queue.CurrentWinnerID = args.BidderID
+		rp.node.Queue.touchLocked()
 	}
 	rp.node.Queue.mu.Unlock()
 	*reply = true
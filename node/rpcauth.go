@@ -0,0 +1,234 @@
+package node
+
+// rpcauth.go — Optional shared-secret authentication of cluster RPC.
+//
+// With --cluster-secret set, RPC moves from rpc.DefaultRPCPath to
+// authRPCPath and every request is signed. The client sends each call's
+// arguments gob-encoded inside an authEnvelope together with the time and
+// an HMAC-SHA256 over the method, sequence number, time and encoded
+// arguments. The server checks the envelope before decoding the arguments
+// and answers a missing, stale (more than maxAuthSkew off) or wrong
+// signature with an error instead of running the method. Replies are not
+// signed.
+//
+// The separate path makes a mixed cluster obvious. A node with a secret
+// answers the plain path with 403 and logs who tried. A node without one
+// has no authRPCPath, so a signing peer gets 404. Either way the dialing
+// node reports a ProtocolError for the peer, which is logged and raised as
+// an alert.
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+const (
+	authRPCPath     = "/_goRPC_auth_"
+	maxAuthSkew     = 30 * time.Second
+	authLogInterval = 10 * time.Second // per caller and reason
+)
+
+var authRejectLog struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+var (
+	errSecretRequired = errors.New("peer requires a cluster secret; start this node with the same --cluster-secret")
+	errSecretUnknown  = errors.New("peer does not use --cluster-secret; give every member the same secret")
+)
+
+// authEnvelope wraps the encoded arguments of one signed request.
+type authEnvelope struct {
+	TimestampUnixMilli int64
+	MAC                []byte
+	Body               []byte // gob-encoded arguments, self-contained
+}
+
+func rpcMAC(secret []byte, method string, seq uint64, ts int64, body []byte) []byte {
+	m := hmac.New(sha256.New, secret)
+	var nums [16]byte
+	binary.BigEndian.PutUint64(nums[:8], seq)
+	binary.BigEndian.PutUint64(nums[8:], uint64(ts))
+	m.Write([]byte(method))
+	m.Write([]byte{0})
+	m.Write(nums[:])
+	m.Write(body)
+	return m.Sum(nil)
+}
+
+// authClientCodec signs requests; replies are read as plain gob.
+type authClientCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	secret []byte
+}
+
+func newAuthClientCodec(conn io.ReadWriteCloser, secret []byte) *authClientCodec {
+	buf := bufio.NewWriter(conn)
+	return &authClientCodec{rwc: conn, dec: gob.NewDecoder(conn), enc: gob.NewEncoder(buf), encBuf: buf, secret: secret}
+}
+
+func (c *authClientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(body); err != nil {
+		return err
+	}
+	ts := time.Now().UnixMilli()
+	env := authEnvelope{TimestampUnixMilli: ts, MAC: rpcMAC(c.secret, r.ServiceMethod, r.Seq, ts, b.Bytes()), Body: b.Bytes()}
+	if err := c.enc.Encode(r); err != nil {
+		return err
+	}
+	if err := c.enc.Encode(env); err != nil {
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *authClientCodec) ReadResponseHeader(r *rpc.Response) error { return c.dec.Decode(r) }
+func (c *authClientCodec) ReadResponseBody(body interface{}) error  { return c.dec.Decode(body) }
+func (c *authClientCodec) Close() error                             { return c.rwc.Close() }
+
+// authServerCodec verifies each request before its arguments are decoded.
+type authServerCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	secret []byte
+	reject func(method, reason string)
+	req    rpc.Request
+	env    authEnvelope
+	closed bool
+}
+
+func (c *authServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.dec.Decode(r); err != nil {
+		return err
+	}
+	c.req = *r
+	c.env = authEnvelope{}
+	return c.dec.Decode(&c.env)
+}
+
+// ReadRequestBody returns an error for a bad signature, which net/rpc sends
+// back to the caller as the call's error without running the method.
+func (c *authServerCodec) ReadRequestBody(body interface{}) error {
+	if body == nil {
+		return nil
+	}
+	skew := time.Since(time.UnixMilli(c.env.TimestampUnixMilli))
+	switch {
+	case len(c.env.MAC) == 0:
+		c.reject(c.req.ServiceMethod, "unsigned")
+		return errors.New("cluster auth: request is not signed")
+	case skew > maxAuthSkew || skew < -maxAuthSkew:
+		c.reject(c.req.ServiceMethod, "stale")
+		return fmt.Errorf("cluster auth: timestamp is %s off (limit %s); check the clocks", skew.Round(time.Second), maxAuthSkew)
+	case !hmac.Equal(c.env.MAC, rpcMAC(c.secret, c.req.ServiceMethod, c.req.Seq, c.env.TimestampUnixMilli, c.env.Body)):
+		c.reject(c.req.ServiceMethod, "bad_signature")
+		return errors.New("cluster auth: bad signature; the cluster secrets differ")
+	}
+	return gob.NewDecoder(bytes.NewReader(c.env.Body)).Decode(body)
+}
+
+func (c *authServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	if err := c.enc.Encode(r); err != nil {
+		c.Close()
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		c.Close()
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *authServerCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
+
+// UseClusterSecret turns on signed RPC, in both directions. Call it before
+// Start.
+func (n *Node) UseClusterSecret(secret string) {
+	n.clusterSecret = []byte(secret)
+	n.Client.SetSecret(n.clusterSecret)
+}
+
+// rpcHandlers returns the handlers to mount for server: the plain endpoint,
+// or, with a cluster secret, the signed one plus a plain endpoint that turns
+// unsigned peers away.
+func (n *Node) rpcHandlers(server *rpc.Server) map[string]http.Handler {
+	if len(n.clusterSecret) == 0 {
+		return map[string]http.Handler{rpc.DefaultRPCPath: server}
+	}
+	signed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "405 must CONNECT", http.StatusMethodNotAllowed)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			log.Printf("[%s] rpc hijacking %s: %v\n", n.ID, r.RemoteAddr, err)
+			return
+		}
+		_, _ = io.WriteString(conn, "HTTP/1.0 200 Connected to Go RPC\n\n")
+		buf := bufio.NewWriter(conn)
+		remote := r.RemoteAddr
+		server.ServeCodec(&authServerCodec{
+			rwc: conn, dec: gob.NewDecoder(conn), enc: gob.NewEncoder(buf), encBuf: buf, secret: n.clusterSecret,
+			reject: func(method, reason string) { n.noteAuthRejection(remote, method, reason) },
+		})
+	})
+	unsigned := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.noteAuthRejection(r.RemoteAddr, "", "no_secret")
+		http.Error(w, errSecretRequired.Error(), http.StatusForbidden)
+	})
+	return map[string]http.Handler{authRPCPath: signed, rpc.DefaultRPCPath: unsigned}
+}
+
+// noteAuthRejection counts a request refused by cluster auth and logs it,
+// at most once per authLogInterval for each caller and reason.
+func (n *Node) noteAuthRejection(remote, method, reason string) {
+	n.Metrics.Inc(metricName("rpc_auth_rejected_total", "reason", reason))
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host // the port changes with every connection
+	}
+	key := remote + "|" + reason
+	authRejectLog.mu.Lock()
+	if time.Since(authRejectLog.last[key]) < authLogInterval {
+		authRejectLog.mu.Unlock()
+		return
+	}
+	if authRejectLog.last == nil {
+		authRejectLog.last = map[string]time.Time{}
+	}
+	authRejectLog.last[key] = time.Now()
+	authRejectLog.mu.Unlock()
+	if reason == "no_secret" {
+		log.Printf("[%s] 🔐 Refused unsigned RPC connection from %s: it runs without --cluster-secret\n", n.ID, remote)
+	} else {
+		log.Printf("[%s] 🔐 Refused %s from %s (%s)\n", n.ID, method, remote, reason)
+	}
+	n.Alerts.Notify("rpc_auth_rejected", SeverityWarning,
+		fmt.Sprintf("RPC from %s refused (%s); check that every member has the same --cluster-secret", remote, reason))
+}
@@ -0,0 +1,574 @@
+package node
+
+// scenario.go — Scripted, repeatable cluster runs for demos (--scenario file.json).
+//
+// The runner starts a fresh local cluster from this same binary in a scratch
+// directory, plays timed steps against it over the public HTTP API, kills and
+// restarts nodes on cue, and finishes with a pass/fail summary of the
+// scenario's assertions. Nodes run on localhost:8001.. so the coordinator
+// port heuristic holds. Node timers are real, so scenarios run in wall time.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scenario is the on-disk description of a scripted run.
+type Scenario struct {
+	Name  string         `json:"name"`
+	Nodes int            `json:"nodes"` // cluster size; nodes are Node1..NodeN
+	Flags []string       `json:"flags"` // extra flags passed to every node
+	Steps []ScenarioStep `json:"steps"`
+
+	// SettleSec is how long to wait after the last step before checking
+	// Expect (default 5).
+	SettleSec int            `json:"settleSec"`
+	Expect    ScenarioExpect `json:"expect"`
+}
+
+// ScenarioStep is one timed action. At is an offset from the moment the
+// cluster has settled on its first leader, e.g. "45s".
+//
+// Actions:
+//
+//	add_item  Item                      POST /admin/item
+//	control   Value: start|stop|restart POST /admin/auction
+//	bid       Bidder, Amount            POST /bid
+//	bots      Bidders, Increment,       bidders take turns outbidding the
+//	          Every, Until              current price until Until
+//	kill      Node                      stop NodeN's process
+//	restart   Node                      start NodeN again (keeps its checkpoint)
+//	wait_sold ItemName, TimeoutSec      block until that item has a result
+//
+// Node selects the node the request is sent to (default 1).
+type ScenarioStep struct {
+	At     string `json:"at"`
+	Action string `json:"action"`
+	Node   int    `json:"node"`
+
+	Value      string       `json:"value"`
+	Bidder     string       `json:"bidder"`
+	Amount     int          `json:"amount"`
+	Item       *AddItemArgs `json:"item"`
+	ItemName   string       `json:"itemName"`
+	TimeoutSec int          `json:"timeoutSec"`
+	Bidders    []string     `json:"bidders"`
+	Increment  int          `json:"increment"`
+	Every      string       `json:"every"`
+	Until      string       `json:"until"`
+	Expect     *StepExpect  `json:"expect"`
+
+	offset time.Duration
+}
+
+// StepExpect asserts on the outcome of a single request step.
+type StepExpect struct {
+	Accepted *bool `json:"accepted"`
+}
+
+// ScenarioExpect holds the end-of-run assertions. Zero values are unchecked.
+type ScenarioExpect struct {
+	Winners       map[string]string `json:"winners"` // item name → winner display name
+	CurrentWinner string            `json:"currentWinner"`
+	HighestBid    int               `json:"highestBid"`
+	Phase         string            `json:"phase"`
+	Leader        string            `json:"leader"`
+	// Elections is the number of leadership takeovers observed, counting
+	// the settled startup leader as the first.
+	Elections int `json:"elections"`
+}
+
+const leaderSettleTime = 5 * time.Second
+
+type scenarioCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+type scenarioRun struct {
+	sc      Scenario
+	dir     string
+	exe     string
+	start   time.Time
+	mu      sync.Mutex
+	procs   map[int]*exec.Cmd
+	leader  string
+	leaders []string // leadership takeovers in order
+	checks  []scenarioCheck
+	client  *http.Client
+}
+
+// LoadScenario reads and validates a scenario file.
+func LoadScenario(path string) (Scenario, error) {
+	var sc Scenario
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return sc, err
+	}
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return sc, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if sc.Nodes <= 0 {
+		sc.Nodes = 1
+	}
+	if sc.SettleSec <= 0 {
+		sc.SettleSec = 5
+	}
+	for i := range sc.Steps {
+		st := &sc.Steps[i]
+		if st.offset, err = time.ParseDuration(st.At); err != nil {
+			return sc, fmt.Errorf("step %d: bad at %q: %w", i, st.At, err)
+		}
+		if st.Node == 0 {
+			st.Node = 1
+		}
+		if st.Node < 1 || st.Node > sc.Nodes {
+			return sc, fmt.Errorf("step %d: node %d outside 1..%d", i, st.Node, sc.Nodes)
+		}
+		switch st.Action {
+		case "add_item":
+			if st.Item == nil {
+				return sc, fmt.Errorf("step %d: add_item needs item", i)
+			}
+		case "control", "bid", "kill", "restart", "wait_sold":
+		case "bots":
+			if len(st.Bidders) == 0 || st.Increment <= 0 {
+				return sc, fmt.Errorf("step %d: bots needs bidders and a positive increment", i)
+			}
+			if _, err := time.ParseDuration(st.Every); err != nil {
+				return sc, fmt.Errorf("step %d: bad every %q: %w", i, st.Every, err)
+			}
+			if _, err := time.ParseDuration(st.Until); err != nil {
+				return sc, fmt.Errorf("step %d: bad until %q: %w", i, st.Until, err)
+			}
+		default:
+			return sc, fmt.Errorf("step %d: unknown action %q", i, st.Action)
+		}
+	}
+	sort.SliceStable(sc.Steps, func(i, j int) bool { return sc.Steps[i].offset < sc.Steps[j].offset })
+	return sc, nil
+}
+
+// RunScenario plays the scenario in path and prints a summary. It returns
+// false if any assertion failed or the cluster could not be started.
+func RunScenario(path string) bool {
+	sc, err := LoadScenario(path)
+	if err != nil {
+		fmt.Printf("Scenario error: %v\n", err)
+		return false
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Printf("Scenario error: %v\n", err)
+		return false
+	}
+	dir, err := os.MkdirTemp("", "auction-scenario-")
+	if err != nil {
+		fmt.Printf("Scenario error: %v\n", err)
+		return false
+	}
+	r := &scenarioRun{
+		sc:     sc,
+		dir:    dir,
+		exe:    exe,
+		procs:  map[int]*exec.Cmd{},
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	fmt.Printf("▶ Scenario %q: %d node(s), %d step(s); node logs in %s\n", sc.Name, sc.Nodes, len(sc.Steps), dir)
+	defer r.stopAll()
+
+	for i := 1; i <= sc.Nodes; i++ {
+		if err := r.startNode(i); err != nil {
+			fmt.Printf("Scenario error: %v\n", err)
+			return false
+		}
+	}
+	stopWatch := make(chan struct{})
+	go r.watchLeader(stopWatch)
+	if !r.waitForStableLeader(30 * time.Second) {
+		close(stopWatch)
+		fmt.Println("Scenario error: no stable leader within 30s")
+		return false
+	}
+	r.mu.Lock()
+	r.start = time.Now()
+	r.mu.Unlock()
+
+	var bots sync.WaitGroup
+	for i := range sc.Steps {
+		st := sc.Steps[i]
+		if wait := time.Until(r.start.Add(st.offset)); wait > 0 {
+			time.Sleep(wait)
+		}
+		if st.Action == "bots" {
+			bots.Add(1)
+			go func() {
+				defer bots.Done()
+				r.runBots(st)
+			}()
+			continue
+		}
+		r.runStep(st)
+	}
+	bots.Wait()
+	time.Sleep(time.Duration(sc.SettleSec) * time.Second)
+	close(stopWatch)
+
+	r.checkExpectations()
+	return r.summary()
+}
+
+func (r *scenarioRun) logf(format string, args ...interface{}) {
+	fmt.Printf("  [%6.1fs] %s\n", time.Since(r.start).Seconds(), fmt.Sprintf(format, args...))
+}
+
+func (r *scenarioRun) addr(node int) string {
+	return fmt.Sprintf("localhost:%d", 8000+node)
+}
+
+func (r *scenarioRun) startNode(node int) error {
+	peers := make([]string, 0, r.sc.Nodes-1)
+	for i := 1; i <= r.sc.Nodes; i++ {
+		if i != node {
+			peers = append(peers, r.addr(i))
+		}
+	}
+	args := []string{
+		fmt.Sprintf("--id=Node%d", node),
+		fmt.Sprintf("--port=%d", 8000+node),
+		"--host=localhost",
+		"--peers=" + strings.Join(peers, ","),
+	}
+	args = append(args, r.sc.Flags...)
+	logFile, err := os.OpenFile(filepath.Join(r.dir, fmt.Sprintf("node%d.log", node)), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(r.exe, args...)
+	cmd.Dir = r.dir
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return fmt.Errorf("start Node%d: %w", node, err)
+	}
+	go func() {
+		_ = cmd.Wait()
+		logFile.Close()
+	}()
+	r.mu.Lock()
+	r.procs[node] = cmd
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *scenarioRun) stopNode(node int) bool {
+	r.mu.Lock()
+	cmd := r.procs[node]
+	delete(r.procs, node)
+	r.mu.Unlock()
+	if cmd == nil || cmd.Process == nil {
+		return false
+	}
+	_ = cmd.Process.Kill()
+	return true
+}
+
+func (r *scenarioRun) stopAll() {
+	for i := 1; i <= r.sc.Nodes; i++ {
+		r.stopNode(i)
+	}
+}
+
+func (r *scenarioRun) liveNodes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nodes := make([]int, 0, len(r.procs))
+	for i := range r.procs {
+		nodes = append(nodes, i)
+	}
+	sort.Ints(nodes)
+	return nodes
+}
+
+func (r *scenarioRun) fetchState(node int) (*QueueSnapshot, error) {
+	resp, err := r.client.Get("http://" + r.addr(node) + "/state")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var snap QueueSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// watchLeader polls live nodes and records each change of coordinator.
+func (r *scenarioRun) watchLeader(stop <-chan struct{}) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		for _, node := range r.liveNodes() {
+			snap, err := r.fetchState(node)
+			if err != nil || !snap.IsCoordinator {
+				continue
+			}
+			id := fmt.Sprintf("Node%d", node)
+			r.mu.Lock()
+			changed := id != r.leader
+			if changed {
+				r.leader = id
+				r.leaders = append(r.leaders, id)
+			}
+			started := !r.start.IsZero()
+			r.mu.Unlock()
+			if changed && started {
+				r.logf("👑 %s is now the leader", id)
+			}
+			break
+		}
+	}
+}
+
+// waitForStableLeader waits until one node has held leadership for
+// leaderSettleTime, so startup elections are not counted against the
+// scenario, and restarts the takeover history from that leader.
+func (r *scenarioRun) waitForStableLeader(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	last, since := "", time.Now()
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		leader := r.leader
+		if leader != "" && leader == last && time.Since(since) >= leaderSettleTime {
+			r.leaders = []string{leader}
+			r.mu.Unlock()
+			return true
+		}
+		r.mu.Unlock()
+		if leader != last {
+			last, since = leader, time.Now()
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	return false
+}
+
+func (r *scenarioRun) post(node int, path string, form url.Values) (bool, string) {
+	resp, err := r.client.PostForm("http://"+r.addr(node)+path, form)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode == http.StatusOK, strings.TrimSpace(string(body))
+}
+
+func (r *scenarioRun) runStep(st ScenarioStep) {
+	switch st.Action {
+	case "add_item":
+		ok, msg := r.post(st.Node, "/admin/item", url.Values{
+			"name":          {st.Item.Name},
+			"description":   {st.Item.Description},
+			"startingPrice": {fmt.Sprint(st.Item.StartingPrice)},
+			"durationSec":   {fmt.Sprint(st.Item.DurationSec)},
+			"emoji":         {st.Item.Emoji},
+			"category":      {st.Item.Category},
+		})
+		r.logf("add item %q via Node%d → %s", st.Item.Name, st.Node, msg)
+		r.checkStep(st, ok, msg)
+	case "control":
+		ok, msg := r.post(st.Node, "/admin/auction", url.Values{"action": {st.Value}})
+		r.logf("%s auction via Node%d → %s", st.Value, st.Node, msg)
+		r.checkStep(st, ok, msg)
+	case "bid":
+		ok, msg := r.post(st.Node, "/bid", url.Values{"bidder": {st.Bidder}, "amount": {fmt.Sprint(st.Amount)}})
+		r.logf("%s bids $%d via Node%d → %s", st.Bidder, st.Amount, st.Node, msg)
+		r.checkStep(st, ok, msg)
+	case "kill":
+		if r.stopNode(st.Node) {
+			r.logf("💥 killed Node%d", st.Node)
+		} else {
+			r.logf("kill Node%d: not running", st.Node)
+		}
+	case "restart":
+		if err := r.startNode(st.Node); err != nil {
+			r.logf("restart Node%d failed: %v", st.Node, err)
+			r.checks = append(r.checks, scenarioCheck{Name: fmt.Sprintf("restart Node%d", st.Node), Detail: err.Error()})
+			return
+		}
+		r.logf("♻️  restarted Node%d", st.Node)
+	case "wait_sold":
+		r.waitSold(st)
+	}
+}
+
+// checkStep records a per-step assertion, if the step has one.
+func (r *scenarioRun) checkStep(st ScenarioStep, accepted bool, msg string) {
+	if st.Expect == nil || st.Expect.Accepted == nil {
+		return
+	}
+	want := *st.Expect.Accepted
+	r.checks = append(r.checks, scenarioCheck{
+		Name:   fmt.Sprintf("%s at %s accepted=%v", st.Action, st.At, want),
+		Passed: accepted == want,
+		Detail: msg,
+	})
+}
+
+// runBots has the bidders take turns raising the current price by Increment
+// every Every until Until. The turn order is fixed, so a replay bids the
+// same sequence as long as the cluster accepts the same bids.
+func (r *scenarioRun) runBots(st ScenarioStep) {
+	every, _ := time.ParseDuration(st.Every)
+	until, _ := time.ParseDuration(st.Until)
+	r.logf("🤖 %d bot(s) bidding every %s until %s", len(st.Bidders), st.Every, st.Until)
+	for turn := 0; time.Since(r.start) < until; turn++ {
+		bidder := st.Bidders[turn%len(st.Bidders)]
+		snap, err := r.fetchState(st.Node)
+		if err == nil && snap.CurrentItem != nil && snap.Phase == PhaseBidding {
+			amount := snap.CurrentHighestBid + st.Increment
+			if snap.CurrentHighestBid == 0 {
+				amount = snap.CurrentItem.StartingPrice + st.Increment
+			}
+			ok, msg := r.post(st.Node, "/bid", url.Values{"bidder": {bidder}, "amount": {fmt.Sprint(amount)}})
+			if !ok {
+				r.logf("🤖 %s $%d rejected: %s", bidder, amount, msg)
+			}
+		}
+		time.Sleep(every)
+	}
+}
+
+func (r *scenarioRun) waitSold(st ScenarioStep) {
+	timeout := time.Duration(st.TimeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		for _, node := range r.liveNodes() {
+			snap, err := r.fetchState(node)
+			if err != nil {
+				continue
+			}
+			for _, res := range snap.Results {
+				if res.Item.Name == st.ItemName {
+					r.logf("🔨 %q sold to %s for $%d", res.Item.Name, res.Winner, res.WinningBid)
+					return
+				}
+			}
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	r.logf("timed out waiting for %q to sell", st.ItemName)
+	r.checks = append(r.checks, scenarioCheck{
+		Name:   fmt.Sprintf("wait_sold %q", st.ItemName),
+		Detail: fmt.Sprintf("not sold within %s", timeout),
+	})
+}
+
+func (r *scenarioRun) checkExpectations() {
+	var snap *QueueSnapshot
+	for _, node := range r.liveNodes() {
+		if s, err := r.fetchState(node); err == nil && s.IsCoordinator {
+			snap = s
+			break
+		}
+	}
+	if snap == nil {
+		r.checks = append(r.checks, scenarioCheck{Name: "leader reachable at end", Detail: "no live node reports being coordinator"})
+		return
+	}
+	r.checkFinalState(snap)
+}
+
+// checkFinalState records the end-of-run assertions against the leader's
+// final state and the takeovers watched during the run.
+func (r *scenarioRun) checkFinalState(snap *QueueSnapshot) {
+	exp := r.sc.Expect
+	names := make([]string, 0, len(exp.Winners))
+	for name := range exp.Winners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		want := exp.Winners[name]
+		got := "(not sold)"
+		for _, res := range snap.Results {
+			if res.Item.Name == name {
+				got = res.Winner
+			}
+		}
+		r.expectEqual(fmt.Sprintf("winner of %q", name), want, got)
+	}
+	if exp.CurrentWinner != "" {
+		r.expectEqual("current winner", exp.CurrentWinner, snap.CurrentWinner)
+	}
+	if exp.HighestBid != 0 {
+		r.expectEqual("highest bid", fmt.Sprint(exp.HighestBid), fmt.Sprint(snap.CurrentHighestBid))
+	}
+	if exp.Phase != "" {
+		r.expectEqual("phase", exp.Phase, snap.Phase)
+	}
+
+	r.mu.Lock()
+	leader := r.leader
+	leaders := append([]string(nil), r.leaders...)
+	r.mu.Unlock()
+	if exp.Leader != "" {
+		r.expectEqual("final leader", exp.Leader, leader)
+	}
+	if exp.Elections != 0 {
+		r.checks = append(r.checks, scenarioCheck{
+			Name:   "elections",
+			Passed: len(leaders) == exp.Elections,
+			Detail: fmt.Sprintf("want %d, got %d %v", exp.Elections, len(leaders), leaders),
+		})
+	}
+}
+
+func (r *scenarioRun) expectEqual(name, want, got string) {
+	r.checks = append(r.checks, scenarioCheck{
+		Name:   name,
+		Passed: got == want,
+		Detail: fmt.Sprintf("want %s, got %s", want, got),
+	})
+}
+
+func (r *scenarioRun) summary() bool {
+	fmt.Println("────────────────────────────────────────────────────────")
+	passed := 0
+	for _, c := range r.checks {
+		mark := "✗"
+		if c.Passed {
+			mark = "✓"
+			passed++
+		}
+		fmt.Printf("  %s %-40s %s\n", mark, c.Name, c.Detail)
+	}
+	ok := passed == len(r.checks)
+	result := "PASS"
+	if !ok {
+		result = "FAIL"
+	}
+	fmt.Printf("%s: %d/%d checks passed (%s)\n", result, passed, len(r.checks), r.sc.Name)
+	return ok
+}
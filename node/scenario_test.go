@@ -0,0 +1,146 @@
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeScenario(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadExampleScenarios(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "scenarios", "*.json"))
+	if err != nil || len(paths) < 2 {
+		t.Fatalf("example scenarios = %v, %v; want at least 2", paths, err)
+	}
+	for _, path := range paths {
+		sc, err := LoadScenario(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if sc.Name == "" || len(sc.Steps) == 0 {
+			t.Errorf("%s: %q with %d steps", path, sc.Name, len(sc.Steps))
+		}
+	}
+}
+
+func TestLoadScenarioSchedule(t *testing.T) {
+	sc, err := LoadScenario(writeScenario(t, `{
+		"name": "schedule",
+		"nodes": 3,
+		"steps": [
+			{"at": "45s", "action": "kill", "node": 3},
+			{"at": "1m10s", "action": "restart", "node": 3},
+			{"at": "0s", "action": "control", "value": "start"},
+			{"at": "45s", "action": "bid", "bidder": "Ann", "amount": 10}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Steps run in time order; ties keep the order written.
+	var order []string
+	for _, st := range sc.Steps {
+		order = append(order, st.At+" "+st.Action)
+	}
+	if got, want := strings.Join(order, ", "), "0s control, 45s kill, 45s bid, 1m10s restart"; got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+	if sc.Steps[3].offset != 70*time.Second {
+		t.Errorf("1m10s offset = %s", sc.Steps[3].offset)
+	}
+	if sc.Steps[0].Node != 1 {
+		t.Errorf("default node = %d, want 1", sc.Steps[0].Node)
+	}
+	if sc.SettleSec != 5 {
+		t.Errorf("default settle = %ds, want 5", sc.SettleSec)
+	}
+}
+
+func TestLoadScenarioErrors(t *testing.T) {
+	cases := []struct{ name, steps, want string }{
+		{"bad offset", `{"at": "soon", "action": "kill"}`, "bad at"},
+		{"node out of range", `{"at": "1s", "action": "kill", "node": 3}`, "outside 1..2"},
+		{"unknown action", `{"at": "1s", "action": "dance"}`, "unknown action"},
+		{"add_item without item", `{"at": "1s", "action": "add_item"}`, "needs item"},
+		{"bots without bidders", `{"at": "1s", "action": "bots", "increment": 5, "every": "1s", "until": "5s"}`, "needs bidders"},
+		{"bots bad every", `{"at": "1s", "action": "bots", "bidders": ["a"], "increment": 5, "every": "often", "until": "5s"}`, "bad every"},
+	}
+	for _, c := range cases {
+		_, err := LoadScenario(writeScenario(t, `{"nodes": 2, "steps": [`+c.steps+`]}`))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: err = %v, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestScenarioAssertions(t *testing.T) {
+	accepted, rejected := true, false
+	sc := Scenario{
+		Name: "assertions",
+		Expect: ScenarioExpect{
+			Winners:       map[string]string{"Lamp": "Ann", "Clock": "Bob"},
+			CurrentWinner: "Cy",
+			HighestBid:    70,
+			Phase:         PhaseBidding,
+			Leader:        "Node2",
+			Elections:     2,
+		},
+	}
+	r := &scenarioRun{sc: sc, leader: "Node2", leaders: []string{"Node1", "Node2"}}
+	r.checkStep(ScenarioStep{At: "1s", Action: "bid", Expect: &StepExpect{Accepted: &accepted}}, true, "ok")
+	r.checkStep(ScenarioStep{At: "2s", Action: "bid", Expect: &StepExpect{Accepted: &rejected}}, true, "ok")
+	r.checkStep(ScenarioStep{At: "3s", Action: "bid"}, false, "no expectation")
+	r.checkFinalState(&QueueSnapshot{
+		Phase:             PhaseBidding,
+		CurrentWinner:     "Cy",
+		CurrentHighestBid: 70,
+		Results: []ItemResult{
+			{Item: AuctionItem{Name: "Lamp"}, Winner: "Ann"},
+			{Item: AuctionItem{Name: "Clock"}, Winner: "Dee"},
+		},
+	})
+
+	got := map[string]bool{}
+	for _, c := range r.checks {
+		got[c.Name] = c.Passed
+	}
+	want := map[string]bool{
+		"bid at 1s accepted=true":  true,
+		"bid at 2s accepted=false": false,
+		`winner of "Clock"`:        false,
+		`winner of "Lamp"`:         true,
+		"current winner":           true,
+		"highest bid":              true,
+		"phase":                    true,
+		"final leader":             true,
+		"elections":                true,
+	}
+	if len(r.checks) != len(want) {
+		t.Errorf("%d checks recorded, want %d: %+v", len(r.checks), len(want), r.checks)
+	}
+	for name, passed := range want {
+		if p, ok := got[name]; !ok || p != passed {
+			t.Errorf("check %q: recorded=%v passed=%v, want passed=%v", name, ok, p, passed)
+		}
+	}
+	if r.summary() {
+		t.Error("summary passed with two failed checks")
+	}
+
+	clean := &scenarioRun{sc: Scenario{Name: "clean"}}
+	clean.checkStep(ScenarioStep{At: "1s", Action: "bid", Expect: &StepExpect{Accepted: &accepted}}, true, "ok")
+	if !clean.summary() {
+		t.Error("summary failed with every check passing")
+	}
+}
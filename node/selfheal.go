@@ -0,0 +1,96 @@
+package node
+
+// selfheal.go — Prepare-phase piggybacking of the coordinator's current item
+// state, so a participant that missed snapshots can catch up before voting
+// instead of voting "no" on a valid bid.
+
+import (
+	"log"
+	"slices"
+)
+
+// AuthoritativeState is the coordinator's view of the current item, attached
+// to every PrepareArgs. Seq is the coordinator's Lamport time when it was
+// captured; participants only move forward to a newer Seq.
+type AuthoritativeState struct {
+	Seq               int
+	Item              *AuctionItem
+	Active            bool
+	CurrentHighestBid int
+	CurrentWinner     string
+	CurrentWinnerID   string
+	DeadlineUnix      int64
+	OpenedAtUnix      int64
+}
+
+// authoritativeState captures the coordinator's current item state.
+func (n *Node) authoritativeState() *AuthoritativeState {
+	seq := n.Clock.Tick()
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	st := &AuthoritativeState{
+		Seq:               seq,
+		Active:            n.Queue.Active,
+		CurrentHighestBid: n.Queue.CurrentHighestBid,
+		CurrentWinner:     n.Queue.CurrentWinner,
+		CurrentWinnerID:   n.Queue.CurrentWinnerID,
+		DeadlineUnix:      n.Queue.DeadlineUnix,
+		OpenedAtUnix:      n.Queue.OpenedAtUnix,
+	}
+	if n.Queue.CurrentItem != nil {
+		item := *n.Queue.CurrentItem
+		st.Item = &item
+	}
+	return st
+}
+
+// fastForwardTo adopts st if it is newer than anything this node has applied
+// and differs from local state. Returns true if local state changed.
+func (n *Node) fastForwardTo(st *AuthoritativeState) bool {
+	if st == nil {
+		return false
+	}
+	q := n.Queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if st.Seq <= q.AuthSeq {
+		return false
+	}
+	q.AuthSeq = st.Seq
+
+	sameItem := (q.CurrentItem == nil && st.Item == nil) ||
+		(q.CurrentItem != nil && st.Item != nil && q.CurrentItem.ID == st.Item.ID)
+	accessChanged := sameItem && st.Item != nil && !slices.Equal(q.CurrentItem.AllowedBidders, st.Item.AllowedBidders)
+	if sameItem && !accessChanged && q.Active == st.Active && q.CurrentHighestBid == st.CurrentHighestBid &&
+		q.DeadlineUnix == st.DeadlineUnix {
+		return false
+	}
+
+	if !sameItem && st.Item != nil {
+		// The coordinator moved on to an item we still have queued.
+		remaining := q.Queue[:0:0]
+		for _, it := range q.Queue {
+			if it.ID != st.Item.ID {
+				remaining = append(remaining, it)
+			}
+		}
+		q.Queue = remaining
+		item := *st.Item
+		q.CurrentItem = &item
+	} else if st.Item == nil {
+		q.CurrentItem = nil
+	} else if accessChanged {
+		item := *st.Item
+		q.CurrentItem = &item
+	}
+	q.Active = st.Active
+	q.CurrentHighestBid = st.CurrentHighestBid
+	q.CurrentWinner = st.CurrentWinner
+	q.CurrentWinnerID = st.CurrentWinnerID
+	q.DeadlineUnix = st.DeadlineUnix
+	q.OpenedAtUnix = st.OpenedAtUnix
+	q.touchLocked()
+	log.Printf("[%s] 🩹 Self-healed from prepare (seq=%d, item=%s, highBid=%d)\n",
+		n.ID, st.Seq, itemName(q.CurrentItem), q.CurrentHighestBid)
+	return true
+}
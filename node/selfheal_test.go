@@ -0,0 +1,77 @@
+package node
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStaleFollowerSelfHealsOnPrepare(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	// C missed the snapshots that closed lot0 and opened lot1.
+	c.Queue.mu.Lock()
+	c.Queue.CurrentItem = &AuctionItem{ID: "lot0", StartingPrice: 5}
+	c.Queue.CurrentHighestBid = 30
+	c.Queue.Queue = []AuctionItem{{ID: "lot1", StartingPrice: 10}, {ID: "lot2", StartingPrice: 20}}
+	c.Queue.mu.Unlock()
+	// With B down, the bid needs C's vote to reach quorum.
+	b.kill()
+
+	reply := a.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+	if reply.Code != BidCommitted {
+		t.Fatalf("reply = %s %q, want committed", reply.Code, reply.Message)
+	}
+	if got := a.Metrics.Counter(metricName("prepare_self_heals_total", "peer", c.Address)); got != 1 {
+		t.Errorf("prepare_self_heals_total{peer=C} = %v, want 1", got)
+	}
+	waitFor(t, "C to apply the bid", func() bool { return highestBid(c.Node) == 50 })
+	c.Queue.mu.Lock()
+	item, queued := c.Queue.CurrentItem.ID, len(c.Queue.Queue)
+	c.Queue.mu.Unlock()
+	if item != "lot1" || queued != 1 {
+		t.Errorf("C is on %s with %d lot(s) queued, want lot1 with 1", item, queued)
+	}
+
+	// A follower already in step is left alone.
+	reply = a.ProposeBid(context.Background(), BidArgs{BidderID: "b2", DisplayName: "Bob", Amount: 60, ItemID: "lot1"})
+	if reply.Code != BidCommitted {
+		t.Fatalf("second reply = %s %q, want committed", reply.Code, reply.Message)
+	}
+	if got := a.Metrics.Counter(metricName("prepare_self_heals_total", "peer", c.Address)); got != 1 {
+		t.Errorf("prepare_self_heals_total{peer=C} after an in-step prepare = %v, want 1", got)
+	}
+
+	// A keeps retrying both commits to B; let them land before cleanup.
+	b.restart(t)
+	a.Client.ResetBreaker(b.Address)
+	waitFor(t, "A to deliver to B", func() bool { return len(a.deliveries.snapshot()) == 0 })
+}
+
+func TestFastForwardIgnoresOlderState(t *testing.T) {
+	n := biddingNode(t)
+	newer := &AuthoritativeState{Seq: 10, Item: &AuctionItem{ID: "lot1", StartingPrice: 10}, Active: true, CurrentHighestBid: 40, DeadlineUnix: 1000}
+	older := &AuthoritativeState{Seq: 9, Item: &AuctionItem{ID: "lot1", StartingPrice: 10}, Active: true, CurrentHighestBid: 90, DeadlineUnix: 2000}
+	cases := []struct {
+		name    string
+		st      *AuthoritativeState
+		changed bool
+		highest int
+	}{
+		{"no state", nil, false, 10},
+		{"newer", newer, true, 40},
+		{"same seq again", newer, false, 40},
+		{"older", older, false, 40},
+	}
+	for _, c := range cases {
+		if got := n.fastForwardTo(c.st); got != c.changed {
+			t.Errorf("%s: fastForwardTo = %v, want %v", c.name, got, c.changed)
+		}
+		if got := highestBid(n); got != c.highest {
+			t.Errorf("%s: highest bid = %d, want %d", c.name, got, c.highest)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package node
+
+// shuffle.go — Verifiable reshuffling of the not-yet-started queue.
+//
+// The seed is derived only from public data: a digest of the results so far,
+// the coordinator's election term and a shuffle round counter. Together with
+// the published algorithm (sort the queued items by ID, then math/rand's
+// Shuffle from a source seeded with the seed's first 8 bytes) anyone can
+// recompute the order from /state and check that nobody hand-picked it.
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const shuffleAlgorithm = "auction-shuffle-v1"
+
+// ShuffleRecord publishes how the current queue order was produced.
+type ShuffleRecord struct {
+	Seed          string   // hex SHA-256 of SeedInput
+	SeedInput     string   // the exact string hashed to get Seed
+	ResultsDigest string   // hex SHA-256 over "ID|WinnerID|WinningBid\n" per result
+	Term          int      // coordinator's election term
+	Round         int      // 1 for the first shuffle, then +1 per shuffle
+	Order         []string // queued item IDs in the shuffled order
+	AtUnix        int64
+}
+
+// resultsDigest hashes the public outcome of every lot sold so far.
+func resultsDigest(results []ItemResult) string {
+	h := sha256.New()
+	for _, r := range results {
+		fmt.Fprintf(h, "%s|%s|%d\n", r.Item.ID, r.WinnerID, r.WinningBid)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// shuffleSeed derives the seed for a shuffle round from public data.
+func shuffleSeed(results []ItemResult, term, round int) (seed, input, digest string) {
+	digest = resultsDigest(results)
+	input = fmt.Sprintf("%s|results=%s|term=%d|round=%d", shuffleAlgorithm, digest, term, round)
+	sum := sha256.Sum256([]byte(input))
+	return hex.EncodeToString(sum[:]), input, digest
+}
+
+// ShuffleOrder returns the order of ids produced by seed. It is the
+// reference implementation for verifying a published ShuffleRecord: pass the
+// record's Order (in any arrangement) and Seed, and compare with Order.
+func ShuffleOrder(seed string, ids []string) ([]string, error) {
+	raw, err := hex.DecodeString(seed)
+	if err != nil || len(raw) < 8 {
+		return nil, fmt.Errorf("seed must be at least 8 hex-encoded bytes")
+	}
+	order := append([]string(nil), ids...)
+	sort.Strings(order)
+	r := rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(raw[:8]))))
+	r.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	return order, nil
+}
+
+// shuffleQueueAndBroadcast reorders the queued items with a fresh published
+// seed. Coordinator only; followers forward via SubmitShuffleToCoordinator.
+func (n *Node) shuffleQueueAndBroadcast() (bool, string) {
+	if msg := n.versionWriteBlock(); msg != "" {
+		return false, msg
+	}
+	term := n.currentTerm()
+
+	release, err := n.enterCS()
+	if err != nil {
+		return false, err.Error()
+	}
+	defer release()
+	n.queueOps.Lock()
+	defer n.queueOps.Unlock()
+
+	n.Queue.mu.Lock()
+	if len(n.Queue.Queue) < 2 {
+		n.Queue.mu.Unlock()
+		return false, "Need at least two queued items to shuffle"
+	}
+	round := 1
+	if n.Queue.LastShuffle != nil {
+		round = n.Queue.LastShuffle.Round + 1
+	}
+	seed, input, digest := shuffleSeed(n.Queue.Results, term, round)
+
+	byID := make(map[string]AuctionItem, len(n.Queue.Queue))
+	ids := make([]string, 0, len(n.Queue.Queue))
+	for _, item := range n.Queue.Queue {
+		if _, dup := byID[item.ID]; dup {
+			n.Queue.mu.Unlock()
+			return false, "Cannot shuffle: duplicate item ID " + item.ID
+		}
+		byID[item.ID] = item
+		ids = append(ids, item.ID)
+	}
+	order, err := ShuffleOrder(seed, ids)
+	if err != nil {
+		n.Queue.mu.Unlock()
+		return false, err.Error()
+	}
+	before := n.Queue.censusLocked()
+	queue := make([]AuctionItem, 0, len(order))
+	for _, id := range order {
+		queue = append(queue, byID[id])
+	}
+	n.Queue.Queue = queue
+	n.Queue.LastShuffle = &ShuffleRecord{
+		Seed:          seed,
+		SeedInput:     input,
+		ResultsDigest: digest,
+		Term:          term,
+		Round:         round,
+		Order:         order,
+		AtUnix:        time.Now().Unix(),
+	}
+	n.Queue.touchLocked()
+	violation := n.checkItemsLocked("shuffle", before, nil, false)
+	n.Queue.mu.Unlock()
+	n.alertItemInvariant(violation)
+
+	log.Printf("[%s] 🔀 Queue shuffled (round %d, seed %s…)\n", n.ID, round, seed[:16])
+	n.Metrics.Inc("queue_shuffles_total")
+	n.broadcastQueueState()
+	go n.initiateGlobalCheckpoint()
+	return true, fmt.Sprintf("Queue shuffled (round %d, seed %s)", round, seed)
+}
+
+// SubmitShuffleToCoordinator forwards POST /admin/shuffle to the leader.
+func (rp *NodeRPC) SubmitShuffleToCoordinator(_ EmptyArgs, reply *CoordinatorActionReply) error {
+	if _, isLocal := rp.node.getCoordinatorAddress(); !isLocal {
+		reply.Accepted = false
+		reply.Message = "This node is not the coordinator"
+		return nil
+	}
+	reply.Accepted, reply.Message = rp.node.shuffleQueueAndBroadcast()
+	return nil
+}
+
+// handleShuffleRequest serves POST /admin/shuffle.
+func (n *Node) handleShuffleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var status int
+	var message string
+	if !n.holdForClient(r, "admin_shuffle", func() { status, message = n.submitShuffle() }) {
+		return
+	}
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(message))
+}
+
+// submitShuffle shuffles on the coordinator, forwarding if needed.
+func (n *Node) submitShuffle() (int, string) {
+	var reply CoordinatorActionReply
+	coordinatorAddress, isLocalCoordinator := n.getCoordinatorAddress()
+	if isLocalCoordinator {
+		reply.Accepted, reply.Message = n.shuffleQueueAndBroadcast()
+	} else {
+		if coordinatorAddress == "" {
+			return http.StatusServiceUnavailable, "Election in progress, please wait"
+		}
+		if err := n.callPeer(coordinatorAddress, "NodeRPC.SubmitShuffleToCoordinator", EmptyArgs{}, &reply); err != nil {
+			return http.StatusServiceUnavailable, "Leader unavailable; retry shortly"
+		}
+	}
+	if !reply.Accepted {
+		return http.StatusBadRequest, reply.Message
+	}
+	return http.StatusOK, reply.Message
+}
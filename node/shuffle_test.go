@@ -0,0 +1,114 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestShuffleOrderReproducible(t *testing.T) {
+	seed, input, _ := shuffleSeed([]ItemResult{{Item: AuctionItem{ID: "lot0"}, WinnerID: "b1", WinningBid: 70}}, 3, 1)
+	if sum := sha256.Sum256([]byte(input)); hex.EncodeToString(sum[:]) != seed {
+		t.Fatalf("seed %s is not the SHA-256 of %q", seed, input)
+	}
+	ids := []string{"lot1", "lot2", "lot3", "lot4", "lot5", "lot6"}
+	order, err := ShuffleOrder(seed, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The order depends only on the seed, not on how the IDs are listed.
+	reversed := []string{"lot6", "lot5", "lot4", "lot3", "lot2", "lot1"}
+	if again, _ := ShuffleOrder(seed, reversed); !reflect.DeepEqual(again, order) {
+		t.Errorf("order from reversed IDs = %v, want %v", again, order)
+	}
+	if reflect.DeepEqual(order, ids) {
+		t.Errorf("seed %s left the order unchanged", seed)
+	}
+	// Every input to the seed matters.
+	for _, other := range [][3]int{{4, 1, 70}, {3, 2, 70}, {3, 1, 71}} {
+		s, _, _ := shuffleSeed([]ItemResult{{Item: AuctionItem{ID: "lot0"}, WinnerID: "b1", WinningBid: other[2]}}, other[0], other[1])
+		if s == seed {
+			t.Errorf("term %d, round %d, bid %d gave the same seed", other[0], other[1], other[2])
+		}
+	}
+	if _, err := ShuffleOrder("abc", ids); err == nil {
+		t.Error("short seed accepted")
+	}
+}
+
+func TestAdminShuffle(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a := nodes[0]
+	queued := []AuctionItem{{ID: "lot2"}, {ID: "lot3"}, {ID: "lot4"}, {ID: "lot5"}, {ID: "lot6"}}
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		tn.Queue.mu.Lock()
+		tn.Queue.Queue = append([]AuctionItem(nil), queued...)
+		tn.Queue.mu.Unlock()
+		tn.ElectionMutex.Lock()
+		tn.Term = 2
+		tn.ElectionMutex.Unlock()
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	shuffle := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		nodes[1].handleShuffleRequest(rec, httptest.NewRequest(http.MethodPost, "/admin/shuffle", nil))
+		return rec
+	}
+
+	// Sent to a follower, the shuffle runs on the coordinator.
+	for round := 1; round <= 2; round++ {
+		if rec := shuffle(); rec.Code != http.StatusOK {
+			t.Fatalf("round %d: %d %s", round, rec.Code, rec.Body)
+		}
+		snap := a.buildQueueSnapshot()
+		rec := snap.Shuffle
+		if rec == nil || rec.Round != round || rec.Term != 2 {
+			t.Fatalf("round %d published %+v", round, rec)
+		}
+		// Anyone can recompute the seed and the order from what /state shows.
+		seed, input, _ := shuffleSeed(snap.Results, rec.Term, rec.Round)
+		if seed != rec.Seed || input != rec.SeedInput {
+			t.Errorf("round %d: seed %s from %q, published %s from %q", round, seed, input, rec.Seed, rec.SeedInput)
+		}
+		order, err := ShuffleOrder(rec.Seed, []string{"lot2", "lot3", "lot4", "lot5", "lot6"})
+		if err != nil || !reflect.DeepEqual(order, rec.Order) {
+			t.Errorf("round %d: recomputed order %v, published %v", round, order, rec.Order)
+		}
+		var ids []string
+		for _, item := range snap.RemainingItems {
+			ids = append(ids, item.ID)
+		}
+		if !reflect.DeepEqual(ids, rec.Order) {
+			t.Errorf("round %d: queue %v, published order %v", round, ids, rec.Order)
+		}
+		for _, tn := range nodes[1:] {
+			waitFor(t, tn.ID+" to receive the shuffle", func() bool {
+				s := tn.buildQueueSnapshot().Shuffle
+				return s != nil && s.Seed == rec.Seed
+			})
+		}
+	}
+
+	// The record survives a restart from the checkpoint.
+	if err := a.takeLocalCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewNode(a.ID, a.Address, nil, 1)
+	if got, want := restored.buildQueueSnapshot().Shuffle, a.buildQueueSnapshot().Shuffle; got == nil || got.Seed != want.Seed || !reflect.DeepEqual(got.Order, want.Order) {
+		t.Errorf("restored shuffle = %+v, want %+v", got, want)
+	}
+
+	for _, tn := range nodes {
+		tn.Queue.mu.Lock()
+		tn.Queue.Queue = tn.Queue.Queue[:1]
+		tn.Queue.mu.Unlock()
+	}
+	if rec := shuffle(); rec.Code != http.StatusBadRequest {
+		t.Errorf("shuffling one lot answered %d, want 400", rec.Code)
+	}
+}
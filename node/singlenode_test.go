@@ -0,0 +1,160 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logCapture collects log output for the rest of the test.
+type logCapture struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *logCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+// lines returns the captured lines that contain prefix.
+func (c *logCapture) lines(prefix string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []string
+	for _, l := range strings.Split(c.buf.String(), "\n") {
+		if strings.Contains(l, prefix) {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+func captureLog(t *testing.T) *logCapture {
+	t.Helper()
+	c := &logCapture{}
+	log.SetOutput(c)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return c
+}
+
+// closeLot ends the lot up on n now and then its sold announcement, as the
+// timers would when they fire.
+func closeLot(t *testing.T, n *Node) {
+	t.Helper()
+	n.Queue.mu.Lock()
+	itemID := n.Queue.CurrentItem.ID
+	deadline := time.Now().Add(-time.Second).Unix()
+	n.Queue.DeadlineUnix = deadline
+	n.Queue.mu.Unlock()
+	n.runItemTimer(itemID, deadline)
+
+	n.Queue.mu.Lock()
+	if n.Queue.Announcement == nil {
+		n.Queue.mu.Unlock()
+		t.Fatalf("%s closed without a sold announcement", itemID)
+	}
+	until := time.Now().Add(-time.Second).Unix()
+	ann := *n.Queue.Announcement // a checkpoint may still be encoding the old one
+	ann.UntilUnix = until
+	n.Queue.Announcement = &ann
+	n.Queue.mu.Unlock()
+	n.runAnnouncementTimer(until)
+}
+
+func TestSingleNodeLifecycle(t *testing.T) {
+	t.Chdir(t.TempDir())
+	logs := captureLog(t)
+	n := NewNode("Solo", "127.0.0.1:9", nil, 1)
+	n.setPhase(PhaseReady, "test")
+	if !n.SingleNode() {
+		t.Fatal("node with no peers is not in single-node mode")
+	}
+
+	start := time.Now()
+	n.runElection()
+	if waited := time.Since(start); waited > 100*time.Millisecond {
+		t.Errorf("took leadership after %s, want no election delay", waited)
+	}
+	if coordinator, isLocal := n.getCoordinatorAddress(); !isLocal {
+		t.Fatalf("coordinator = %q, want itself", coordinator)
+	}
+	leading(t, n)
+
+	n.Queue.mu.Lock()
+	n.Queue.Queue = nil
+	n.Queue.mu.Unlock()
+	for _, name := range []string{"Lamp", "Clock"} {
+		if ok, msg := n.addItemAndBroadcast(AddItemArgs{Name: name, Description: "test lot", StartingPrice: 10, DurationSec: 60}); !ok {
+			t.Fatalf("add %s: %s", name, msg)
+		}
+	}
+	if ok, msg := n.startAuctionAndBroadcast(); !ok {
+		t.Fatalf("start: %s", msg)
+	}
+
+	reply := n.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50})
+	if reply.Code != BidCommitted {
+		t.Fatalf("bid = %s %q, want committed", reply.Code, reply.Message)
+	}
+	closeLot(t, n) // Lamp sells to Ann; Clock goes up
+	closeLot(t, n) // Clock goes unsold; the auction ends
+
+	n.Queue.mu.Lock()
+	phase, results := n.Queue.phaseLocked(), n.Queue.Results
+	n.Queue.mu.Unlock()
+	if phase != PhaseEnded {
+		t.Errorf("phase = %q, want %q", phase, PhaseEnded)
+	}
+	if len(results) != 2 || results[0].Winner != "Ann" || results[0].WinningBid != 50 || results[1].Winner != "No bids" {
+		t.Errorf("results = %+v, want Lamp to Ann at 50 and Clock unsold", results)
+	}
+	completed := metricName("events_archived_total", "reason", "completed")
+	waitFor(t, "the auction to be archived", func() bool { return n.Metrics.Counter(completed) == 1 })
+	if events := n.listEvents(); len(events) != 1 || events[0].Totals.Lots != 2 || events[0].Totals.Revenue != 50 {
+		t.Errorf("archived events = %+v, want one with 2 lots and $50", events)
+	}
+
+	rec := httptest.NewRecorder()
+	n.handlePeersRequest(rec, httptest.NewRequest(http.MethodGet, "/peers", nil))
+	var peers struct {
+		Mode        string
+		ClusterSize int
+		Quorum      int
+		Peers       []PeerVersion
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &peers); err != nil {
+		t.Fatal(err)
+	}
+	if peers.Mode != "single-node" || peers.ClusterSize != 1 || peers.Quorum != 1 || len(peers.Peers) != 0 {
+		t.Errorf("/peers = %+v, want single-node with size 1 and quorum 1", peers)
+	}
+	n.refreshDerivedMetrics()
+	if size, quorum := gauge(n.Metrics, "cluster_size"), gauge(n.Metrics, "quorum_size"); size != 1 || quorum != 1 {
+		t.Errorf("cluster_size = %v, quorum_size = %v, want 1 and 1", size, quorum)
+	}
+
+	// Nothing in the lifecycle should look like a problem on one node.
+	if len(logs.lines("Single-node mode")) != 1 {
+		t.Error("single-node leadership was not logged once")
+	}
+	for _, l := range logs.lines("[Solo]") {
+		for _, bad := range []string{"Warning", "Error", "Starting election", "Requesting Critical Section"} {
+			if strings.Contains(l, bad) {
+				t.Errorf("unexpected log line: %s", l)
+			}
+		}
+	}
+	if active, history := n.Alerts.snapshot(); len(active) != 0 || len(history) != 0 {
+		t.Errorf("alerts = %v, history = %v, want none", active, history)
+	}
+}
@@ -0,0 +1,177 @@
+package node
+
+// snapshotwire.go — Compressed QueueSnapshot transfer and the snapshot size
+// guard.
+//
+// Nodes advertise the "snapshot-gzip" capability in their VersionInfo. When a
+// peer is known to have it, snapshot pushes and pulls use the *Gzip RPC
+// variants, which carry a gzipped gob encoding of the same QueueSnapshot.
+// Peers that have not (yet) advertised it get the plain RPCs, so mixed
+// clusters keep working.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	capSnapshotGzip = "snapshot-gzip"
+
+	snapshotWarnBytes    = 256 * 1024 // gob size that triggers the size warning
+	snapshotWarnInterval = time.Minute
+)
+
+// localCapabilities lists the optional protocol features this build supports.
+func localCapabilities() []string {
+	return []string{capSnapshotGzip}
+}
+
+// CompressedSnapshot is a gzipped gob-encoded QueueSnapshot.
+type CompressedSnapshot struct {
+	Data []byte
+}
+
+// peerHasCapability reports whether the peer last advertised capability.
+func (n *Node) peerHasCapability(address, capability string) bool {
+	n.versionsMu.Lock()
+	defer n.versionsMu.Unlock()
+	pv, ok := n.peerVersions[address]
+	if !ok || pv.Info == nil {
+		return false
+	}
+	for _, c := range pv.Info.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+func gobSize(v interface{}) int {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return 0
+	}
+	return buf.Len()
+}
+
+// compressSnapshot encodes snap for the *Gzip RPCs and records the raw and
+// compressed sizes.
+func (n *Node) compressSnapshot(snap QueueSnapshot) (CompressedSnapshot, error) {
+	var raw bytes.Buffer
+	if err := gob.NewEncoder(&raw).Encode(snap); err != nil {
+		return CompressedSnapshot{}, err
+	}
+	n.checkSnapshotSize(snap, raw.Len())
+
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	if _, err := zw.Write(raw.Bytes()); err != nil {
+		return CompressedSnapshot{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return CompressedSnapshot{}, err
+	}
+	n.Metrics.Set(metricName("snapshot_wire_bytes", "encoding", "gob"), float64(raw.Len()))
+	n.Metrics.Set(metricName("snapshot_wire_bytes", "encoding", "gzip"), float64(out.Len()))
+	return CompressedSnapshot{Data: out.Bytes()}, nil
+}
+
+func decompressSnapshot(c CompressedSnapshot) (QueueSnapshot, error) {
+	var snap QueueSnapshot
+	zr, err := gzip.NewReader(bytes.NewReader(c.Data))
+	if err != nil {
+		return snap, fmt.Errorf("gunzip snapshot: %w", err)
+	}
+	defer zr.Close()
+	if err := gob.NewDecoder(zr).Decode(&snap); err != nil {
+		return snap, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+var snapshotWarn struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// checkSnapshotSize logs, at most once a minute, which parts of an oversized
+// snapshot take the space, so operators know which retention knob to turn.
+func (n *Node) checkSnapshotSize(snap QueueSnapshot, total int) {
+	if total < snapshotWarnBytes {
+		return
+	}
+	n.Metrics.Inc("snapshot_oversize_total")
+	snapshotWarn.mu.Lock()
+	if time.Since(snapshotWarn.last) < snapshotWarnInterval {
+		snapshotWarn.mu.Unlock()
+		return
+	}
+	snapshotWarn.last = time.Now()
+	snapshotWarn.mu.Unlock()
+
+	results := gobSize(snap.Results)
+	queue := gobSize(snap.RemainingItems)
+	styles := gobSize(snap.BidderStyles)
+	log.Printf("[%s] ⚠️  Snapshot is %d KiB (limit %d KiB): results=%d KiB (%d, see --retain-results), queue=%d KiB (%d items), bidder styles=%d KiB, other=%d KiB\n",
+		n.ID, total/1024, snapshotWarnBytes/1024,
+		results/1024, len(snap.Results), queue/1024, len(snap.RemainingItems),
+		styles/1024, (total-results-queue-styles)/1024)
+}
+
+// sendQueueSnapshot pushes snap to one peer, compressed when it can take it.
+func (n *Node) sendQueueSnapshot(peer string, snap QueueSnapshot, compressed *CompressedSnapshot) error {
+	var ok bool
+	if compressed != nil && n.peerHasCapability(peer, capSnapshotGzip) {
+		n.Metrics.Add(metricName("snapshot_sync_bytes_total", "encoding", "gzip"), float64(len(compressed.Data)))
+		return n.callPeer(peer, "NodeRPC.SyncQueueStateGzip", *compressed, &ok)
+	}
+	return n.callPeer(peer, "NodeRPC.SyncQueueState", snap, &ok)
+}
+
+// fetchQueueSnapshot pulls a peer's snapshot, compressed when it can send it.
+func (n *Node) fetchQueueSnapshot(peer string) (QueueSnapshot, error) {
+	if n.peerHasCapability(peer, capSnapshotGzip) {
+		var c CompressedSnapshot
+		if err := n.callPeer(peer, "NodeRPC.GetQueueStateGzip", EmptyArgs{}, &c); err != nil {
+			return QueueSnapshot{}, err
+		}
+		n.Metrics.Add(metricName("snapshot_sync_bytes_total", "encoding", "gzip"), float64(len(c.Data)))
+		return decompressSnapshot(c)
+	}
+	var snap QueueSnapshot
+	err := n.callPeer(peer, "NodeRPC.GetQueueState", EmptyArgs{}, &snap)
+	return snap, err
+}
+
+// GetQueueStateGzip is GetQueueState with a compressed reply.
+func (rp *NodeRPC) GetQueueStateGzip(_ EmptyArgs, reply *CompressedSnapshot) error {
+	c, err := rp.node.compressSnapshot(rp.node.buildQueueSnapshot())
+	if err != nil {
+		return err
+	}
+	*reply = c
+	return nil
+}
+
+// SyncQueueStateGzip is SyncQueueState with a compressed payload.
+func (rp *NodeRPC) SyncQueueStateGzip(c CompressedSnapshot, reply *bool) error {
+	snap, err := decompressSnapshot(c)
+	if err != nil {
+		return err
+	}
+	if err := rp.node.admitSnapshot(snap, "coordinator push"); err != nil {
+		return err
+	}
+	if !rp.node.applyQueueSnapshot(snap, "pushed snapshot") {
+		return errors.New("snapshot rejected: fails state invariants")
+	}
+	*reply = true
+	return nil
+}
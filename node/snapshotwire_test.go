@@ -0,0 +1,96 @@
+package node
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// withCatalogue queues items lots on n, each with a long description, as a
+// large charity catalogue would.
+func withCatalogue(n *Node, items int) *Node {
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	for i := 0; i < items; i++ {
+		n.Queue.Queue = append(n.Queue.Queue, AuctionItem{
+			ID:            fmt.Sprintf("lot%d", i+2),
+			Name:          fmt.Sprintf("Donated item %d", i),
+			Description:   strings.Repeat(fmt.Sprintf("Lot %d, kindly donated; see the photos at https://example.org/lots/%d. ", i, i), 10),
+			StartingPrice: 10 + i,
+			DurationSec:   60,
+		})
+	}
+	n.Queue.touchLocked()
+	return n
+}
+
+func TestSnapshotPullNegotiatesGzip(t *testing.T) {
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	withCatalogue(withLotUp(b.Node), 500)
+	gzipped := metricName("snapshot_sync_bytes_total", "encoding", "gzip")
+	lots := len(b.buildQueueSnapshot().RemainingItems)
+
+	// B has not advertised the capability: the plain RPC.
+	plain, err := a.fetchQueueSnapshot(b.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain.RemainingItems) != lots || a.Metrics.Counter(gzipped) != 0 {
+		t.Fatalf("plain pull: %d lots, %v gzip bytes", len(plain.RemainingItems), a.Metrics.Counter(gzipped))
+	}
+
+	a.recordPeerVersion(b.Address, &VersionInfo{NodeID: b.ID, Capabilities: localCapabilities()})
+	snap, err := a.fetchQueueSnapshot(b.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.RemainingItems) != lots || snap.RemainingItems[lots-1].Description != plain.RemainingItems[lots-1].Description {
+		t.Errorf("gzip pull differs from the plain one: %d lots", len(snap.RemainingItems))
+	}
+	raw := gauge(b.Metrics, metricName("snapshot_wire_bytes", "encoding", "gob"))
+	sent := a.Metrics.Counter(gzipped)
+	if sent == 0 || sent != gauge(b.Metrics, metricName("snapshot_wire_bytes", "encoding", "gzip")) || sent*10 > raw {
+		t.Errorf("gzip pull carried %v bytes for %v of gob", sent, raw)
+	}
+	// Some 380 KB of gob is over the 256 KiB guard.
+	if got := b.Metrics.Counter("snapshot_oversize_total"); got != 1 {
+		t.Errorf("snapshot_oversize_total = %v, want 1", got)
+	}
+
+	var ok bool
+	if err := (&NodeRPC{node: b.Node}).SyncQueueStateGzip(CompressedSnapshot{Data: []byte("not gzip")}, &ok); err == nil || !strings.Contains(err.Error(), "gunzip") {
+		t.Errorf("corrupt payload = %v, want a gunzip error", err)
+	}
+}
+
+// BenchmarkSnapshotSync compares the bytes one snapshot of a 500-lot
+// catalogue puts on the wire, as gob and gzipped gob.
+func BenchmarkSnapshotSync(b *testing.B) {
+	n := withCatalogue(biddingNode(b), 500)
+	snap := n.buildQueueSnapshot()
+	b.Run("gob", func(b *testing.B) {
+		var size int
+		for i := 0; i < b.N; i++ {
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+				b.Fatal(err)
+			}
+			size = buf.Len()
+		}
+		b.ReportMetric(float64(size), "wire-bytes/op")
+	})
+	b.Run("gzip", func(b *testing.B) {
+		var size int
+		for i := 0; i < b.N; i++ {
+			c, err := n.compressSnapshot(snap)
+			if err != nil {
+				b.Fatal(err)
+			}
+			size = len(c.Data)
+		}
+		b.ReportMetric(float64(size), "wire-bytes/op")
+	})
+}
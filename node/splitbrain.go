@@ -0,0 +1,367 @@
+package node
+
+// splitbrain.go — Reconciling results after a split brain.
+//
+// A coordinator cut off on the minority side keeps closing lots on its own
+// timer until it steps down, so after a partition the two sides can each
+// hold results the other lacks, or two different results for one lot.
+// Applying the leader's snapshot, or adopting the "best" peer snapshot on
+// takeover, used to keep one side's results and silently drop the other's.
+//
+// Results are now merged by union, keyed by item ID. When both sides
+// finalized the same lot differently, the higher WinningBid wins; on a tie,
+// the result finalized at the lower Lamport stamp (FinalizedLamport) wins,
+// then the lower bidder ID, so every node picks the same result whatever
+// order it merges in. Every conflict is logged with both outcomes, counted
+// in split_brain_conflicts_total and raised as a one-off split_brain alert,
+// once per losing result however many nodes held it.
+//
+// Two paths feed the merge. A new coordinator merges the results of every
+// peer that answered its reconciliation poll. A follower that is handed a
+// leader snapshot missing results it holds, or disagreeing with them,
+// offers those results to the leader with OfferResults before applying the
+// snapshot; the leader merges them and broadcasts the outcome. Only lots
+// the leader holds (queued or decided) are merged, so archived results and
+// those of an earlier run are not brought back. Lots that the other side
+// sold leave the remaining queue. A lot still open here keeps running and
+// its foreign result is held back, so the lot is not counted as both open
+// and decided; when the lot closes here, the held result is merged against
+// the close like any other. Budgets are not
+// reconciled: a winner charged on the losing side keeps the leader's
+// figure.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+const resultOfferAttempts = 3
+
+// resultConflict is one lot finalized differently on two sides.
+type resultConflict struct {
+	Kept    ItemResult
+	Dropped ItemResult
+}
+
+// sameOutcome reports whether two results for one lot agree on the sale.
+func sameOutcome(a, b ItemResult) bool {
+	return a.WinnerID == b.WinnerID && a.Winner == b.Winner && a.WinningBid == b.WinningBid
+}
+
+// resultOutranks reports whether a is kept over b for the same lot. It is a
+// total order, so merges do not depend on which side comes first.
+func resultOutranks(a, b ItemResult) bool {
+	if a.WinningBid != b.WinningBid {
+		return a.WinningBid > b.WinningBid
+	}
+	if a.FinalizedLamport != b.FinalizedLamport {
+		// Results from builds without the stamp (0) lose to stamped ones.
+		if a.FinalizedLamport == 0 || b.FinalizedLamport == 0 {
+			return b.FinalizedLamport == 0
+		}
+		return a.FinalizedLamport < b.FinalizedLamport
+	}
+	if a.WinnerID != b.WinnerID {
+		return a.WinnerID < b.WinnerID
+	}
+	if a.Winner != b.Winner {
+		return a.Winner < b.Winner
+	}
+	if a.ClosedAtUnix != b.ClosedAtUnix {
+		return a.ClosedAtUnix < b.ClosedAtUnix
+	}
+	if a.OpenedAtUnix != b.OpenedAtUnix {
+		return a.OpenedAtUnix < b.OpenedAtUnix
+	}
+	return a.WinnerOrigin < b.WinnerOrigin
+}
+
+// mergeResults returns the union of a and b by item ID, ordered by close
+// time, and the conflicts it resolved, ordered by item ID. Neither input
+// is modified.
+func mergeResults(a, b []ItemResult) ([]ItemResult, []resultConflict) {
+	byID := make(map[string]ItemResult, len(a)+len(b))
+	conflicts := map[string]resultConflict{}
+	for _, side := range [][]ItemResult{a, b} {
+		for _, res := range side {
+			held, ok := byID[res.Item.ID]
+			if !ok {
+				byID[res.Item.ID] = res
+				continue
+			}
+			kept, dropped := held, res
+			if resultOutranks(res, held) {
+				kept, dropped = res, held
+			}
+			byID[res.Item.ID] = kept
+			if !sameOutcome(kept, dropped) {
+				conflicts[res.Item.ID] = resultConflict{Kept: kept, Dropped: dropped}
+			}
+		}
+	}
+	merged := make([]ItemResult, 0, len(byID))
+	for _, res := range byID {
+		merged = append(merged, res)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		x, y := merged[i], merged[j]
+		if x.ClosedAtUnix != y.ClosedAtUnix {
+			return x.ClosedAtUnix < y.ClosedAtUnix
+		}
+		if x.FinalizedLamport != y.FinalizedLamport {
+			return x.FinalizedLamport < y.FinalizedLamport
+		}
+		return x.Item.ID < y.Item.ID
+	})
+	out := make([]resultConflict, 0, len(conflicts))
+	for _, c := range conflicts {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Kept.Item.ID < out[j].Kept.Item.ID })
+	return merged, out
+}
+
+// resultLocked returns the result recorded for itemID, if any. Must hold mu.
+func (q *ItemQueueState) resultLocked(itemID string) (ItemResult, bool) {
+	for i := len(q.Results) - 1; i >= 0; i-- {
+		if q.Results[i].Item.ID == itemID {
+			return q.Results[i], true
+		}
+	}
+	return ItemResult{}, false
+}
+
+// divergentResultsLocked returns the local results that a coordinator
+// snapshot would drop or overrule, leaving out the ones it trimmed away on
+// purpose. Must hold Queue.mu.
+func (n *Node) divergentResultsLocked(snap QueueSnapshot) []ItemResult {
+	local := n.Queue.Results
+	if trimmed := snap.ResultsTrimmed - n.Queue.ResultsTrimmed; trimmed > 0 {
+		local = local[min(trimmed, len(local)):]
+	}
+	theirs := make(map[string]ItemResult, len(snap.Results))
+	for _, res := range snap.Results {
+		theirs[res.Item.ID] = res
+	}
+	var out []ItemResult
+	for _, res := range local {
+		if other, ok := theirs[res.Item.ID]; !ok || !sameOutcome(res, other) {
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+// mergeForeignResults folds results from another node into the queue.
+// Coordinator only. Reports how many lots were added and how many
+// conflicts were resolved.
+func (n *Node) mergeForeignResults(results []ItemResult, source string) (added, conflicts int) {
+	if len(results) == 0 {
+		return 0, 0
+	}
+	n.queueOps.Lock()
+	n.Queue.mu.Lock()
+	before := make(map[string]ItemResult, len(n.Queue.Results))
+	for _, res := range n.Queue.Results {
+		before[res.Item.ID] = res
+	}
+	known := make(map[string]bool, len(n.Queue.Queue))
+	for _, item := range n.Queue.Queue {
+		known[item.ID] = true
+	}
+	live := ""
+	if item := n.Queue.CurrentItem; item != nil {
+		live = item.ID
+	}
+	var foreign []ItemResult
+	deferred := 0
+	for _, res := range results {
+		_, decided := before[res.Item.ID]
+		switch {
+		case res.Item.ID == live && !decided:
+			deferred++
+			n.holdResultLocked(res, source)
+		case decided || known[res.Item.ID]:
+			foreign = append(foreign, res)
+		}
+	}
+	if deferred > 0 {
+		log.Printf("[%s] Holding back the result from %s for open lot %s until it closes here\n", n.ID, source, live)
+	}
+	if ignored := len(results) - len(foreign) - deferred; ignored > 0 {
+		log.Printf("[%s] Ignoring %d result(s) from %s for lots not held here (archived, or from an earlier run)\n", n.ID, ignored, source)
+	}
+	merged, resolved := mergeResults(n.Queue.Results, foreign)
+	changed := len(merged) != len(n.Queue.Results)
+	for _, res := range merged {
+		if held, ok := before[res.Item.ID]; !ok {
+			added++
+		} else if !sameOutcome(held, res) {
+			changed = true
+		}
+	}
+	var sold []string
+	if changed {
+		decided := make(map[string]bool, len(merged))
+		for _, res := range merged {
+			decided[res.Item.ID] = true
+		}
+		remaining := make([]AuctionItem, 0, len(n.Queue.Queue))
+		for _, item := range n.Queue.Queue {
+			if decided[item.ID] {
+				sold = append(sold, item.ID)
+				continue
+			}
+			remaining = append(remaining, item)
+		}
+		n.Queue.Queue = remaining
+		n.Queue.Results = merged
+		n.trimResultsLocked()
+		n.Queue.touchLocked()
+	}
+	n.Queue.mu.Unlock()
+	n.queueOps.Unlock()
+
+	for _, c := range resolved {
+		n.noteResultConflict(c, source)
+	}
+	if !changed {
+		return added, len(resolved)
+	}
+	log.Printf("[%s] 🧩 Merged results from %s: %d lot(s) added, %d conflict(s) resolved, %d sold lot(s) dequeued\n",
+		n.ID, source, added, len(resolved), len(sold))
+	n.Metrics.Add("split_brain_results_merged_total", float64(added))
+	n.broadcastQueueState()
+	go n.initiateGlobalCheckpoint()
+	return added, len(resolved)
+}
+
+// heldResult is a foreign result for the lot that was open when it arrived.
+type heldResult struct {
+	result ItemResult
+	source string
+}
+
+// holdResultLocked keeps res until its lot closes here. Of two offers for
+// the lot, the one mergeResults would keep is held. Must hold Queue.mu.
+func (n *Node) holdResultLocked(res ItemResult, source string) {
+	if n.heldResults == nil {
+		n.heldResults = map[string]heldResult{}
+	}
+	if prev, ok := n.heldResults[res.Item.ID]; ok {
+		if kept, _ := mergeResults([]ItemResult{prev.result}, []ItemResult{res}); sameOutcome(kept[0], prev.result) {
+			return
+		}
+	}
+	n.heldResults[res.Item.ID] = heldResult{result: res, source: source}
+}
+
+// mergeHeldResultLocked merges the result held for itemID, if any, into
+// the results once the lot has closed here. Must hold Queue.mu.
+func (n *Node) mergeHeldResultLocked(itemID string) {
+	held, ok := n.heldResults[itemID]
+	if !ok {
+		return
+	}
+	delete(n.heldResults, itemID)
+	merged, conflicts := mergeResults(n.Queue.Results, []ItemResult{held.result})
+	n.Queue.Results = merged
+	for _, c := range conflicts {
+		go n.noteResultConflict(c, held.source)
+	}
+}
+
+// noteResultConflict reports one resolved conflict, loudly. Followers that
+// held the losing result offer it too; each losing result is reported once.
+func (n *Node) noteResultConflict(c resultConflict, source string) {
+	key := fmt.Sprintf("%s|%s|%d", c.Dropped.Item.ID, c.Dropped.WinnerID, c.Dropped.WinningBid)
+	n.splitBrainMu.Lock()
+	if n.splitBrainSeen == nil {
+		n.splitBrainSeen = map[string]bool{}
+	}
+	seen := n.splitBrainSeen[key]
+	n.splitBrainSeen[key] = true
+	n.splitBrainMu.Unlock()
+	if seen {
+		return
+	}
+	describe := func(r ItemResult) string {
+		return fmt.Sprintf("winner=%s id=%s bid=%d lamport=%d", r.Winner, r.WinnerID, r.WinningBid, r.FinalizedLamport)
+	}
+	log.Printf("[%s] ⚠️  ==============================================================\n", n.ID)
+	log.Printf("[%s] ⚠️  SPLIT BRAIN: %s (%s) was finalized twice (merging with %s)\n", n.ID, c.Kept.Item.Name, c.Kept.Item.ID, source)
+	log.Printf("[%s] ⚠️    kept    %s\n", n.ID, describe(c.Kept))
+	log.Printf("[%s] ⚠️    dropped %s\n", n.ID, describe(c.Dropped))
+	log.Printf("[%s] ⚠️  ==============================================================\n", n.ID)
+	n.Metrics.Inc("split_brain_conflicts_total")
+	n.Alerts.Notify("split_brain", SeverityCritical, fmt.Sprintf("%s was finalized twice; kept %s at %d, dropped %s at %d",
+		c.Kept.Item.Name, c.Kept.Winner, c.Kept.WinningBid, c.Dropped.Winner, c.Dropped.WinningBid))
+}
+
+// ResultOfferArgs carries results a follower holds that the leader lacks.
+type ResultOfferArgs struct {
+	From    string
+	Results []ItemResult
+}
+
+// ResultOfferReply says what the leader made of an offer.
+type ResultOfferReply struct {
+	Accepted  bool
+	Added     int
+	Conflicts int
+}
+
+// OfferResults merges a follower's divergent results on the coordinator.
+func (rp *NodeRPC) OfferResults(args ResultOfferArgs, reply *ResultOfferReply) error {
+	if _, isLocal := rp.node.getCoordinatorAddress(); !isLocal {
+		return nil
+	}
+	snap := QueueSnapshot{Results: args.Results}
+	if err := validateQueueSnapshot(snap); err != nil {
+		rp.node.rejectInvalid("result offer", args.From, args, err)
+		return nil
+	}
+	reply.Accepted = true
+	reply.Added, reply.Conflicts = rp.node.mergeForeignResults(args.Results, args.From)
+	return nil
+}
+
+// offerResults sends results a leader snapshot dropped or overruled to the
+// coordinator. One offer runs at a time; later snapshots carry the outcome.
+// If no attempt gets through, the results are logged in full so an
+// operator can re-enter them.
+func (n *Node) offerResults(results []ItemResult) {
+	if !n.offeringResults.CompareAndSwap(false, true) {
+		return
+	}
+	defer n.offeringResults.Store(false)
+	log.Printf("[%s] 🧩 Leader snapshot drops or overrules %d result(s) held here; offering them to the leader\n", n.ID, len(results))
+	args := ResultOfferArgs{From: n.ID, Results: results}
+	for attempt := 1; attempt <= resultOfferAttempts; attempt++ {
+		address, isLocal := n.getCoordinatorAddress()
+		if isLocal {
+			n.mergeForeignResults(results, "state held before the snapshot")
+			return
+		}
+		var reply ResultOfferReply
+		err := errors.New("no leader")
+		if address != "" {
+			err = n.callPeer(address, "NodeRPC.OfferResults", args, &reply)
+		}
+		if err == nil && reply.Accepted {
+			log.Printf("[%s] 🧩 Leader %s took the offer: %d lot(s) added, %d conflict(s) resolved\n",
+				n.ID, address, reply.Added, reply.Conflicts)
+			return
+		}
+		if attempt < resultOfferAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	b, _ := json.Marshal(results)
+	log.Printf("[%s] ⚠️  Could not offer %d divergent result(s) to the leader; they are no longer held here: %s\n", n.ID, len(results), b)
+	n.Metrics.Add("split_brain_results_lost_total", float64(len(results)))
+}
@@ -0,0 +1,44 @@
+package node
+
+import "testing"
+
+func TestMergeForeignResultsHoldsOpenLot(t *testing.T) {
+	n := biddingNode(t)
+	n.Queue.mu.Lock()
+	n.Queue.Queue = []AuctionItem{{ID: "lot2", StartingPrice: 10}}
+	n.Queue.mu.Unlock()
+	foreign := []ItemResult{
+		{Item: AuctionItem{ID: "lot1", StartingPrice: 10}, Winner: "Zed", WinnerID: "b9", WinningBid: 90, ClosedAtUnix: 100, FinalizedLamport: 5},
+		{Item: AuctionItem{ID: "lot2", StartingPrice: 10}, Winner: "Yan", WinnerID: "b8", WinningBid: 40, ClosedAtUnix: 200, FinalizedLamport: 6},
+	}
+
+	if added, _ := n.mergeForeignResults(foreign, "P2"); added != 1 {
+		t.Errorf("added = %d, want 1 (lot2 only)", added)
+	}
+	n.Queue.mu.Lock()
+	census := n.Queue.censusLocked()
+	current := n.Queue.CurrentItem
+	queued := len(n.Queue.Queue)
+	n.Queue.mu.Unlock()
+	if current == nil || current.ID != "lot1" {
+		t.Fatalf("current item = %v, want lot1 still open", current)
+	}
+	if census.ids["lot1"] != 1 || census.ids["lot2"] != 1 || queued != 0 {
+		t.Fatalf("census = %v with %d queued, want lot1 and lot2 once each and nothing queued", census.ids, queued)
+	}
+
+	// The lot closes here at 50; the held result outbids it.
+	commitBid(t, &NodeRPC{node: n}, "C-1", BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1", Coordinator: "C"})
+	n.Queue.mu.Lock()
+	n.finalizeCurrentItemLocked()
+	census = n.Queue.censusLocked()
+	res, ok := n.Queue.resultLocked("lot1")
+	n.Queue.mu.Unlock()
+	if census.ids["lot1"] != 1 {
+		t.Errorf("lot1 held %d times after closing, want 1", census.ids["lot1"])
+	}
+	if !ok || res.WinnerID != "b9" || res.WinningBid != 90 {
+		t.Errorf("lot1 result = %+v, want the held result (b9 at 90)", res)
+	}
+	waitFor(t, "the conflict to be reported", func() bool { return n.Metrics.Counter("split_brain_conflicts_total") == 1 })
+}
@@ -2,23 +2,45 @@ package node
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 // AuctionItem describes a single item being put up for auction.
 type AuctionItem struct {
-	ID           string
-	Name         string
-	Description  string
-	Emoji        string
+	ID            string
+	Name          string
+	Description   string
+	Emoji         string
+	Category      string
 	StartingPrice int
-	DurationSec  int
+	DurationSec   int
+	Increments    []IncrementBand `json:",omitempty"` // overrides the cluster bidIncrements table
+
+	AllowedBidders []string `json:",omitempty"` // bidder IDs; empty = anyone may bid. Never in public responses.
+	Restricted     bool     `json:",omitempty"` // len(AllowedBidders) > 0; safe to publish
+
+	Custom map[string]string `json:",omitempty"` // operator metadata; see customfields.go
 }
 
 // ItemResult records the outcome of a completed auction item.
 type ItemResult struct {
 	Item       AuctionItem
-	Winner     string
+	Winner     string // display name, or "No bids"
+	WinnerID   string // BidderID; empty when unsold
 	WinningBid int
+
+	WinnerOrigin string `json:",omitempty"` // node the winning bid came in through; see origin.go
+
+	// Lamport stamp of the close; breaks ties between two results for one
+	// lot after a split brain (see splitbrain.go). 0 from older builds.
+	FinalizedLamport int `json:",omitempty"`
+
+	// Wall-clock timing. ActualDurationSec exceeds ScheduledDurationSec when
+	// anti-snipe extensions stretched the lot.
+	OpenedAtUnix         int64
+	ClosedAtUnix         int64
+	ScheduledDurationSec int
+	ActualDurationSec    int
 }
 
 // ItemQueueState is the full shared state of the auction queue.
@@ -27,10 +49,31 @@ type ItemQueueState struct {
 	Queue             []AuctionItem // remaining items (not yet started)
 	CurrentItem       *AuctionItem  // nil when no active item
 	CurrentHighestBid int
-	CurrentWinner     string
-	DeadlineUnix      int64 // Unix timestamp (seconds) when current item closes
-	Active            bool  // false after all items are done
+	CurrentWinner     string // display name of the leading bidder
+	CurrentWinnerID   string // BidderID of the leading bidder
+	DeadlineUnix      int64  // Unix timestamp (seconds) when current item closes
+	GraceFromUnix     int64  // original deadline while a failover grace re-open runs; see grace.go
+	OpenedAtUnix      int64  // Unix timestamp (seconds) when current item opened
+	Active            bool   // false after all items are done
 	Results           []ItemResult
+	ResultsTrimmed    int                     // results moved to the archive by --retain-results
+	Announcement      *SoldAnnouncement       // non-nil between a sale and the next lot
+	AuthSeq           int                     // Seq of the newest coordinator state applied here
+	LastShuffle       *ShuffleRecord          // how Queue was last reordered; nil if never shuffled
+	Budgets           map[string]BidderBudget // per bidder ID; replaced, never modified in place (see budget.go)
+	Paddles           *PaddleBook             // paddle numbers by bidder ID; replaced, never modified in place (see paddles.go)
+
+	version atomic.Uint64 // bumped on every mutation; readable without mu
+}
+
+// touchLocked records that the queue state changed. Must hold mu.
+func (q *ItemQueueState) touchLocked() {
+	q.version.Add(1)
+}
+
+// Version returns the local mutation counter without taking mu.
+func (q *ItemQueueState) Version() uint64 {
+	return q.version.Load()
 }
 
 type LamportClock struct {
@@ -0,0 +1,121 @@
+package node
+
+// statecache.go — Copy-on-write queue view and serialized /state and
+// /api/v1/state JSON, all cached by queue state version.
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// queueView is an immutable copy of the queue state at one version. Once
+// published it is never modified, so readers share its slices without
+// copying or taking Queue.mu.
+type queueView struct {
+	version uint64
+	snap    QueueSnapshot
+}
+
+// queueView returns the current immutable snapshot, rebuilding it under
+// Queue.mu only when the queue has changed since the last one. Readers that
+// race a mutation in progress get the state from just before it.
+func (n *Node) queueView() QueueSnapshot {
+	if v := n.view.Load(); v != nil && v.version == n.Queue.Version() {
+		return v.snap
+	}
+	n.Queue.mu.Lock()
+	v := &queueView{version: n.Queue.Version(), snap: n.copyQueueSnapshotLocked()}
+	n.Queue.mu.Unlock()
+	// Publish unless a concurrent rebuild already stored a newer view.
+	for {
+		cur := n.view.Load()
+		if cur != nil && cur.version >= v.version {
+			break
+		}
+		if n.view.CompareAndSwap(cur, v) {
+			break
+		}
+	}
+	n.Metrics.Inc("queue_view_builds_total")
+	return v.snap
+}
+
+// stateCache holds the last marshalled public state. It is valid as long as
+// neither the queue version nor this node's election term, leadership or
+// lifecycle phase has changed.
+type stateCache struct {
+	mu    sync.RWMutex
+	valid bool
+	key   stateKey
+	body  []byte
+}
+
+// stateKey is everything a cached state body depends on besides the queue
+// contents. The Lamport time is left out of the body instead, since it moves
+// with every message and would make the cache miss on nearly every read.
+type stateKey struct {
+	version       uint64
+	term          int
+	isCoordinator bool
+	phase         NodePhase
+}
+
+// cachedStateJSON returns the /state body, re-marshalling only when the queue
+// has changed since the cached copy was built.
+func (n *Node) cachedStateJSON() ([]byte, error) {
+	return n.cachedStateBody(&n.stateCache, func(snap QueueSnapshot) interface{} { return snap })
+}
+
+// cachedStateV1JSON is cachedStateJSON for /api/v1/state.
+func (n *Node) cachedStateV1JSON() ([]byte, error) {
+	return n.cachedStateBody(&n.stateCacheV1, func(snap QueueSnapshot) interface{} { return stateV1(snap) })
+}
+
+// cachedStateBody returns c's body, rebuilding it from the public snapshot
+// in the form shape gives it when stale.
+func (n *Node) cachedStateBody(c *stateCache, shape func(QueueSnapshot) interface{}) ([]byte, error) {
+	// Read the version before building: a concurrent mutation can then only
+	// make the cached body newer than its key, never older.
+	key := stateKey{
+		version:       n.Queue.Version(),
+		term:          n.currentTerm(),
+		isCoordinator: n.isCoordinatorOrUnknown(),
+		phase:         n.Phase(),
+	}
+
+	c.mu.RLock()
+	if c.valid && c.key == key {
+		body := c.body
+		c.mu.RUnlock()
+		n.Metrics.Inc("state_cache_hits_total")
+		return body, nil
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Another request may have rebuilt it while we waited for the write lock.
+	if c.valid && c.key == key {
+		n.Metrics.Inc("state_cache_hits_total")
+		return c.body, nil
+	}
+	n.Metrics.Inc("state_cache_misses_total")
+	snap := n.buildQueueSnapshot()
+	snap.Seq = 0 // see stateKey
+	body, err := json.Marshal(shape(publicSnapshot(n.displaySnapshot(snap))))
+	if err != nil {
+		return nil, err
+	}
+	body = append(body, '\n')
+	c.valid = true
+	c.key = key
+	c.body = body
+	return body, nil
+}
+
+// isCoordinatorOrUnknown mirrors the IsCoordinator flag of buildQueueSnapshot.
+func (n *Node) isCoordinatorOrUnknown() bool {
+	n.ElectionMutex.Lock()
+	defer n.ElectionMutex.Unlock()
+	return n.Coordinator == "" || n.Coordinator == n.ID
+}
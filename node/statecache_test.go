@@ -0,0 +1,241 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStateCacheTracksTerm(t *testing.T) {
+	n := biddingNode(t)
+	first, err := n.cachedStateJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := n.cachedStateJSON(); !bytes.Equal(again, first) {
+		t.Fatal("unchanged state was re-marshalled differently")
+	}
+	if got := n.Metrics.Counter("state_cache_hits_total"); got != 1 {
+		t.Errorf("state_cache_hits_total = %v, want 1", got)
+	}
+
+	n.Clock.Tick()
+	n.ElectionMutex.Lock()
+	n.Term = 7
+	n.ElectionMutex.Unlock()
+	body, err := n.cachedStateJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap QueueSnapshot
+	if err := json.Unmarshal(body, &snap); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Term != 7 {
+		t.Errorf("Term = %d after the term changed, want 7", snap.Term)
+	}
+	if snap.Seq != 0 {
+		t.Errorf("Seq = %d, want it left out of the cached body", snap.Seq)
+	}
+}
+
+// pollerNode returns a bidding node with a realistic amount of state: a
+// few dozen results and lots still to come.
+func pollerNode(b testing.TB) *Node {
+	n := biddingNode(b)
+	n.Queue.mu.Lock()
+	for i := 0; i < 40; i++ {
+		n.Queue.Results = append(n.Queue.Results, ItemResult{
+			Item:       AuctionItem{ID: fmt.Sprintf("done%d", i), Name: "Vintage watch", StartingPrice: 100},
+			Winner:     "Ann",
+			WinnerID:   "b1",
+			WinningBid: 250 + i,
+		})
+	}
+	for i := 0; i < 20; i++ {
+		n.Queue.Queue = append(n.Queue.Queue, AuctionItem{ID: fmt.Sprintf("next%d", i), Name: "Guitar", StartingPrice: 50})
+	}
+	n.Queue.touchLocked()
+	n.Queue.mu.Unlock()
+	return n
+}
+
+// BenchmarkStatePollers serves /state to 1000 concurrent pollers while the
+// queue changes every millisecond. "uncached" is the path the cache replaced:
+// every poll copies and marshals the snapshot under Queue.mu. queue-locks/op
+// is how often a poll took Queue.mu and queue-lock-ns/op how long it held it.
+// A cached poll takes it only to rebuild the shared view, which holds it for
+// the copy alone; that time is measured separately and charged per rebuild.
+func BenchmarkStatePollers(b *testing.B) {
+	for _, cached := range []bool{false, true} {
+		name := "uncached"
+		if cached {
+			name = "cached"
+		}
+		b.Run(name, func(b *testing.B) {
+			n := pollerNode(b)
+			stop := make(chan struct{})
+			go func() {
+				tick := time.NewTicker(time.Millisecond)
+				defer tick.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-tick.C:
+						n.Queue.mu.Lock()
+						n.Queue.CurrentHighestBid++
+						n.Queue.touchLocked()
+						n.Queue.mu.Unlock()
+					}
+				}
+			}()
+			var held atomic.Int64
+			builds := n.Metrics.Counter("queue_view_builds_total")
+			b.SetParallelism(max(1, 1000/runtime.GOMAXPROCS(0)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					var err error
+					if cached {
+						_, err = n.cachedStateJSON()
+					} else {
+						n.Queue.mu.Lock()
+						start := time.Now()
+						snap := n.copyQueueSnapshotLocked()
+						_, err = json.Marshal(publicSnapshot(n.displaySnapshot(snap)))
+						held.Add(int64(time.Since(start)))
+						n.Queue.mu.Unlock()
+					}
+					if err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			b.StopTimer()
+			close(stop)
+			locks := float64(b.N)
+			if cached {
+				locks = n.Metrics.Counter("queue_view_builds_total") - builds
+				held.Store(int64(locks * float64(viewCopyTime(n))))
+			}
+			b.ReportMetric(locks/float64(b.N), "queue-locks/op")
+			b.ReportMetric(float64(held.Load())/float64(b.N), "queue-lock-ns/op")
+		})
+	}
+}
+
+// viewCopyTime is the mean time a view rebuild holds Queue.mu.
+func viewCopyTime(n *Node) time.Duration {
+	const rounds = 100
+	var total time.Duration
+	for i := 0; i < rounds; i++ {
+		n.Queue.mu.Lock()
+		start := time.Now()
+		_ = n.copyQueueSnapshotLocked()
+		total += time.Since(start)
+		n.Queue.mu.Unlock()
+	}
+	return total / rounds
+}
+
+func TestQueueViewSharedUntilChanged(t *testing.T) {
+	n := pollerNode(t)
+	first := n.buildQueueSnapshot()
+	second := n.buildQueueSnapshot()
+	if &first.Results[0] != &second.Results[0] || &first.RemainingItems[0] != &second.RemainingItems[0] {
+		t.Error("unchanged state was copied again")
+	}
+	if got := n.Metrics.Counter("queue_view_builds_total"); got != 1 {
+		t.Errorf("queue_view_builds_total = %v, want 1", got)
+	}
+
+	n.Queue.mu.Lock()
+	n.Queue.Results[0].WinningBid = 999
+	n.Queue.CurrentHighestBid = 77
+	n.Queue.touchLocked()
+	n.Queue.mu.Unlock()
+	third := n.buildQueueSnapshot()
+	if third.CurrentHighestBid != 77 || third.Results[0].WinningBid != 999 {
+		t.Errorf("view after a change = bid %d, result %d; want 77 and 999", third.CurrentHighestBid, third.Results[0].WinningBid)
+	}
+	if first.CurrentHighestBid == 77 || first.Results[0].WinningBid == 999 {
+		t.Error("a published view changed under its readers")
+	}
+
+	// Serving an unchanged state allocates nothing on the read path.
+	if allocs := testing.AllocsPerRun(100, func() { n.buildQueueSnapshot() }); allocs != 0 {
+		t.Errorf("buildQueueSnapshot allocates %v times per unchanged read, want 0", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { n.cachedStateJSON() }); allocs != 0 {
+		t.Errorf("cachedStateJSON allocates %v times per unchanged read, want 0", allocs)
+	}
+}
+
+// TestQueueViewConcurrentReaders is meant for -race: readers share views
+// while bids commit.
+func TestQueueViewConcurrentReaders(t *testing.T) {
+	n := pollerNode(t)
+	rp := &NodeRPC{node: n}
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			last := 0
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				snap := n.buildQueueSnapshot()
+				if snap.CurrentHighestBid < last {
+					t.Errorf("highest bid went back from %d to %d", last, snap.CurrentHighestBid)
+					return
+				}
+				last = snap.CurrentHighestBid
+				if _, err := n.cachedStateJSON(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 1; i <= 200; i++ {
+		commitBid(t, rp, fmt.Sprintf("C-%d", i), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 10 + i, ItemID: "lot1", Coordinator: "C"})
+	}
+	close(done)
+	readers.Wait()
+	if got := n.buildQueueSnapshot().CurrentHighestBid; got != 210 {
+		t.Errorf("final highest bid = %d, want 210", got)
+	}
+}
+
+// BenchmarkQueueSnapshot compares a deep copy per read, as before the
+// shared view, with reading the view of an unchanged queue.
+func BenchmarkQueueSnapshot(b *testing.B) {
+	n := pollerNode(b)
+	b.Run("copy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			n.Queue.mu.Lock()
+			_ = n.copyQueueSnapshotLocked()
+			n.Queue.mu.Unlock()
+		}
+	})
+	b.Run("view", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = n.buildQueueSnapshot()
+		}
+	})
+}
@@ -0,0 +1,331 @@
+package node
+
+// statehistory.go — Retained copies of committed checkpoints, and read-only
+// "time travel" over them: GET /admin/checkpoints, /admin/state-at and
+// /admin/state-diff.
+//
+// Every checkpoint this node commits is also copied to
+// checkpoints/history/<NodeID>/<UTC time>-L<lamport>.json, and the oldest
+// copies beyond --checkpoint-history are deleted. Historical checkpoints are
+// only ever decoded into fresh values; the live node state is never touched.
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCheckpointHistory is the --checkpoint-history default.
+const DefaultCheckpointHistory = 48
+
+func checkpointHistoryDir(nodeID string) string {
+	return filepath.Join(checkpointDir, "history", nodeID)
+}
+
+// CheckpointInfo names one retained checkpoint.
+type CheckpointInfo struct {
+	Name           string `json:"name"`
+	CheckpointTime int64  `json:"checkpointTime"`
+	LamportStamp   int    `json:"lamportStamp"`
+	Results        int    `json:"results"`
+}
+
+func checkpointHistoryName(data CheckpointData) string {
+	return fmt.Sprintf("%s-L%08d", time.Unix(data.CheckpointTime, 0).UTC().Format("20060102T150405Z"), data.LamportStamp)
+}
+
+// recordCheckpointHistory copies a just-committed checkpoint into the
+// history directory and prunes the oldest copies.
+func (n *Node) recordCheckpointHistory(b []byte) {
+	if n.CheckpointHistory <= 0 {
+		return
+	}
+	var data CheckpointData
+	if err := json.Unmarshal(b, &data); err != nil {
+		log.Printf("[%s] Warning: checkpoint history skipped: %v\n", n.ID, err)
+		return
+	}
+	dir := checkpointHistoryDir(n.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("[%s] Warning: checkpoint history skipped: %v\n", n.ID, err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, checkpointHistoryName(data)+".json"), b, 0o644); err != nil {
+		log.Printf("[%s] Warning: checkpoint history write failed: %v\n", n.ID, err)
+		return
+	}
+	names := n.checkpointHistoryNames()
+	for len(names) > n.CheckpointHistory {
+		_ = os.Remove(filepath.Join(dir, names[0]+".json"))
+		names = names[1:]
+	}
+}
+
+// checkpointHistoryNames lists retained checkpoints, oldest first.
+func (n *Node) checkpointHistoryNames() []string {
+	files, _ := filepath.Glob(filepath.Join(checkpointHistoryDir(n.ID), "*.json"))
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(f), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadHistoricalCheckpoint reads a retained checkpoint by name. "current"
+// names the live checkpoint file.
+func (n *Node) loadHistoricalCheckpoint(name string) (*CheckpointData, error) {
+	if name == "current" {
+		cp, err := loadCheckpoint(n.ID)
+		if err == nil && cp == nil {
+			err = fmt.Errorf("no checkpoint yet")
+		}
+		return cp, err
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return nil, fmt.Errorf("invalid checkpoint name %q", name)
+	}
+	b, err := os.ReadFile(filepath.Join(checkpointHistoryDir(n.ID), name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint %q not found", name)
+	}
+	data, _, err := MigrateCheckpoint(b)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint %q: %w", name, err)
+	}
+	return data, nil
+}
+
+// checkpointNameAt returns the newest retained checkpoint taken at or
+// before t.
+func (n *Node) checkpointNameAt(t time.Time) (string, error) {
+	prefix := t.UTC().Format("20060102T150405Z")
+	best := ""
+	for _, name := range n.checkpointHistoryNames() {
+		if len(name) >= len(prefix) && name[:len(prefix)] <= prefix {
+			best = name
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no retained checkpoint at or before %s", t.UTC().Format(time.RFC3339))
+	}
+	return best, nil
+}
+
+// snapshotFromCheckpoint reconstructs the QueueSnapshot a node would have
+// served from this checkpoint's state.
+func snapshotFromCheckpoint(cp *CheckpointData) QueueSnapshot {
+	q := &ItemQueueState{
+		CurrentItem:  cp.CurrentItem,
+		Queue:        cp.RemainingQueue,
+		Results:      cp.Results,
+		Active:       cp.Active,
+		Announcement: cp.Announcement,
+	}
+	return QueueSnapshot{
+		CurrentItem:       cp.CurrentItem,
+		CurrentHighestBid: cp.CurrentHighestBid,
+		CurrentWinner:     cp.CurrentWinner,
+		CurrentWinnerID:   cp.CurrentWinnerID,
+		DeadlineUnix:      cp.DeadlineUnix,
+		OpenedAtUnix:      cp.OpenedAtUnix,
+		Active:            cp.Active,
+		QueueLen:          len(cp.RemainingQueue),
+		Seq:               cp.LamportStamp,
+		RemainingItems:    cp.RemainingQueue,
+		Results:           cp.Results,
+		ResultsTrimmed:    cp.ResultsTrimmed,
+		Phase:             q.phaseLocked(),
+		Announcement:      cp.Announcement,
+		Shuffle:           cp.Shuffle,
+		Budgets:           cp.Budgets,
+		Paddles:           cp.Paddles,
+	}
+}
+
+// ValueChange is a before/after pair in a StateDiff.
+type ValueChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// StateDiff is the field-level difference between two snapshots. Only
+// fields that changed are set.
+type StateDiff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	ResultsAdded   []ItemResult  `json:"resultsAdded,omitempty"`
+	ResultsRemoved []ItemResult  `json:"resultsRemoved,omitempty"`
+	QueueAdded     []AuctionItem `json:"queueAdded,omitempty"`
+	QueueRemoved   []AuctionItem `json:"queueRemoved,omitempty"`
+
+	CurrentItem    *ValueChange `json:"currentItem,omitempty"`
+	HighestBid     *ValueChange `json:"highestBid,omitempty"`
+	CurrentWinner  *ValueChange `json:"currentWinner,omitempty"`
+	Deadline       *ValueChange `json:"deadlineUnix,omitempty"`
+	Active         *ValueChange `json:"active,omitempty"`
+	Phase          *ValueChange `json:"phase,omitempty"`
+	ResultsTrimmed *ValueChange `json:"resultsTrimmed,omitempty"`
+}
+
+// diffSnapshots compares two snapshots. Results are matched by item ID and
+// close time, queued items by item ID.
+func diffSnapshots(a, b QueueSnapshot) StateDiff {
+	var d StateDiff
+	resultKey := func(r ItemResult) string { return fmt.Sprintf("%s@%d", r.Item.ID, r.ClosedAtUnix) }
+	inA := map[string]bool{}
+	for _, r := range a.Results {
+		inA[resultKey(r)] = true
+	}
+	inB := map[string]bool{}
+	for _, r := range b.Results {
+		inB[resultKey(r)] = true
+		if !inA[resultKey(r)] {
+			d.ResultsAdded = append(d.ResultsAdded, r)
+		}
+	}
+	for _, r := range a.Results {
+		if !inB[resultKey(r)] {
+			d.ResultsRemoved = append(d.ResultsRemoved, r)
+		}
+	}
+
+	queuedA := map[string]bool{}
+	for _, it := range a.RemainingItems {
+		queuedA[it.ID] = true
+	}
+	queuedB := map[string]bool{}
+	for _, it := range b.RemainingItems {
+		queuedB[it.ID] = true
+		if !queuedA[it.ID] {
+			d.QueueAdded = append(d.QueueAdded, it)
+		}
+	}
+	for _, it := range a.RemainingItems {
+		if !queuedB[it.ID] {
+			d.QueueRemoved = append(d.QueueRemoved, it)
+		}
+	}
+
+	if itemName(a.CurrentItem) != itemName(b.CurrentItem) {
+		d.CurrentItem = &ValueChange{From: itemName(a.CurrentItem), To: itemName(b.CurrentItem)}
+	}
+	if a.CurrentHighestBid != b.CurrentHighestBid {
+		d.HighestBid = &ValueChange{From: a.CurrentHighestBid, To: b.CurrentHighestBid}
+	}
+	if a.CurrentWinner != b.CurrentWinner {
+		d.CurrentWinner = &ValueChange{From: a.CurrentWinner, To: b.CurrentWinner}
+	}
+	if a.DeadlineUnix != b.DeadlineUnix {
+		d.Deadline = &ValueChange{From: a.DeadlineUnix, To: b.DeadlineUnix}
+	}
+	if a.Active != b.Active {
+		d.Active = &ValueChange{From: a.Active, To: b.Active}
+	}
+	if a.Phase != b.Phase {
+		d.Phase = &ValueChange{From: a.Phase, To: b.Phase}
+	}
+	if a.ResultsTrimmed != b.ResultsTrimmed {
+		d.ResultsTrimmed = &ValueChange{From: a.ResultsTrimmed, To: b.ResultsTrimmed}
+	}
+	return d
+}
+
+// parseHistoryTime accepts RFC 3339 or Unix seconds.
+func parseHistoryTime(s string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// resolveCheckpointRef turns a ?checkpoint= name or ?at= time into a
+// retained checkpoint name.
+func (n *Node) resolveCheckpointRef(name, at string) (string, error) {
+	if name != "" {
+		return name, nil
+	}
+	if at == "" {
+		return "", fmt.Errorf("checkpoint or at is required")
+	}
+	t, err := parseHistoryTime(at)
+	if err != nil {
+		return "", fmt.Errorf("invalid time %q (use RFC 3339 or Unix seconds)", at)
+	}
+	return n.checkpointNameAt(t)
+}
+
+// handleCheckpointHistoryRequest serves GET /admin/checkpoints.
+func (n *Node) handleCheckpointHistoryRequest(w http.ResponseWriter, r *http.Request) {
+	infos := []CheckpointInfo{}
+	for _, name := range n.checkpointHistoryNames() {
+		cp, err := n.loadHistoricalCheckpoint(name)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, CheckpointInfo{
+			Name:           name,
+			CheckpointTime: cp.CheckpointTime,
+			LamportStamp:   cp.LamportStamp,
+			Results:        cp.ResultsTrimmed + len(cp.Results),
+		})
+	}
+	writeJSON(w, infos)
+}
+
+// handleStateAtRequest serves GET /admin/state-at?checkpoint=<name>|at=<time>.
+func (n *Node) handleStateAtRequest(w http.ResponseWriter, r *http.Request) {
+	name, err := n.resolveCheckpointRef(r.URL.Query().Get("checkpoint"), r.URL.Query().Get("at"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cp, err := n.loadHistoricalCheckpoint(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, struct {
+		Checkpoint     string        `json:"checkpoint"`
+		CheckpointTime int64         `json:"checkpointTime"`
+		State          QueueSnapshot `json:"state"`
+	}{name, cp.CheckpointTime, snapshotFromCheckpoint(cp)})
+}
+
+// handleStateDiffRequest serves GET /admin/state-diff?from=<a>&to=<b>. Each
+// side is a checkpoint name, "current", or a time via fromAt/toAt.
+func (n *Node) handleStateDiffRequest(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	load := func(name, at string) (string, *CheckpointData, int, error) {
+		name, err := n.resolveCheckpointRef(name, at)
+		if err != nil {
+			return "", nil, http.StatusBadRequest, err
+		}
+		cp, err := n.loadHistoricalCheckpoint(name)
+		if err != nil {
+			return "", nil, http.StatusNotFound, err
+		}
+		return name, cp, 0, nil
+	}
+	fromName, from, status, err := load(q.Get("from"), q.Get("fromAt"))
+	if err != nil {
+		http.Error(w, "from: "+err.Error(), status)
+		return
+	}
+	toName, to, status, err := load(q.Get("to"), q.Get("toAt"))
+	if err != nil {
+		http.Error(w, "to: "+err.Error(), status)
+		return
+	}
+	d := diffSnapshots(snapshotFromCheckpoint(from), snapshotFromCheckpoint(to))
+	d.From, d.To = fromName, toName
+	writeJSON(w, d)
+}
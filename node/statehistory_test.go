@@ -0,0 +1,209 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// checkpointPair is an auction at 8:15 and again at 8:20: lot1 sold to Ann,
+// lot2 went up and drew a bid, and lot4 was added to the queue.
+func checkpointPair() (before, after CheckpointData) {
+	at := time.Date(2026, 3, 1, 8, 15, 0, 0, time.UTC)
+	lot1 := AuctionItem{ID: "lot1", Name: "Lamp", StartingPrice: 10}
+	lot2 := AuctionItem{ID: "lot2", Name: "Clock", StartingPrice: 20}
+	lot3 := AuctionItem{ID: "lot3", Name: "Vase", StartingPrice: 30}
+	lot4 := AuctionItem{ID: "lot4", Name: "Rug", StartingPrice: 40}
+	before = CheckpointData{
+		SchemaVersion:     CheckpointSchemaVersion,
+		CheckpointTime:    at.Unix(),
+		LamportStamp:      40,
+		CurrentItem:       &lot1,
+		CurrentHighestBid: 50,
+		CurrentWinner:     "Ann",
+		CurrentWinnerID:   "b1",
+		DeadlineUnix:      at.Add(time.Minute).Unix(),
+		Active:            true,
+		RemainingQueue:    []AuctionItem{lot2, lot3},
+	}
+	after = before
+	after.CheckpointTime = at.Add(5 * time.Minute).Unix()
+	after.LamportStamp = 90
+	after.CurrentItem = &lot2
+	after.CurrentHighestBid, after.CurrentWinner, after.CurrentWinnerID = 25, "Bob", "b2"
+	after.DeadlineUnix = at.Add(6 * time.Minute).Unix()
+	after.RemainingQueue = []AuctionItem{lot3, lot4}
+	after.Results = []ItemResult{{Item: lot1, Winner: "Ann", WinnerID: "b1", WinningBid: 50, ClosedAtUnix: at.Add(time.Minute).Unix()}}
+	return before, after
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	before, after := checkpointPair()
+	d := diffSnapshots(snapshotFromCheckpoint(&before), snapshotFromCheckpoint(&after))
+	if len(d.ResultsAdded) != 1 || d.ResultsAdded[0].Item.ID != "lot1" || len(d.ResultsRemoved) != 0 {
+		t.Errorf("results added %v, removed %v; want lot1 added", d.ResultsAdded, d.ResultsRemoved)
+	}
+	if len(d.QueueAdded) != 1 || d.QueueAdded[0].ID != "lot4" || len(d.QueueRemoved) != 1 || d.QueueRemoved[0].ID != "lot2" {
+		t.Errorf("queue added %v, removed %v; want lot4 added and lot2 removed", d.QueueAdded, d.QueueRemoved)
+	}
+	changes := []struct {
+		name     string
+		got      *ValueChange
+		from, to interface{}
+	}{
+		{"current item", d.CurrentItem, "Lamp", "Clock"},
+		{"highest bid", d.HighestBid, 50, 25},
+		{"current winner", d.CurrentWinner, "Ann", "Bob"},
+		{"deadline", d.Deadline, before.DeadlineUnix, after.DeadlineUnix},
+	}
+	for _, c := range changes {
+		if c.got == nil || c.got.From != c.from || c.got.To != c.to {
+			t.Errorf("%s change = %+v, want %v → %v", c.name, c.got, c.from, c.to)
+		}
+	}
+	if d.Active != nil || d.Phase != nil || d.ResultsTrimmed != nil {
+		t.Errorf("unchanged fields reported: active %v, phase %v, trimmed %v", d.Active, d.Phase, d.ResultsTrimmed)
+	}
+
+	// The reverse diff undoes it, and a checkpoint equals itself.
+	back := diffSnapshots(snapshotFromCheckpoint(&after), snapshotFromCheckpoint(&before))
+	if len(back.ResultsRemoved) != 1 || len(back.ResultsAdded) != 0 || back.HighestBid.From != 25 {
+		t.Errorf("reverse diff = %+v", back)
+	}
+	if same := diffSnapshots(snapshotFromCheckpoint(&after), snapshotFromCheckpoint(&after)); !emptyDiff(same) {
+		t.Errorf("diff of a checkpoint with itself = %+v", same)
+	}
+}
+
+func emptyDiff(d StateDiff) bool {
+	b, _ := json.Marshal(d)
+	return string(b) == `{"from":"","to":""}`
+}
+
+func TestSnapshotFromCheckpointPhase(t *testing.T) {
+	before, after := checkpointPair()
+	after.Active = false
+	ended := after
+	ended.CurrentItem, ended.RemainingQueue = nil, nil
+	cases := []struct {
+		cp   CheckpointData
+		want string
+	}{
+		{before, PhaseBidding},
+		{after, PhasePaused},
+		{ended, PhaseEnded},
+	}
+	for _, c := range cases {
+		if got := snapshotFromCheckpoint(&c.cp).Phase; got != c.want {
+			t.Errorf("phase = %q, want %q", got, c.want)
+		}
+	}
+}
+
+// historyNode retains before and after, plus any later checkpoints, for a
+// node whose live state is something else again.
+func historyNode(t *testing.T, keep int, later ...CheckpointData) (*Node, []string) {
+	t.Helper()
+	n := biddingNode(t)
+	n.CheckpointHistory = keep
+	before, after := checkpointPair()
+	for _, cp := range append([]CheckpointData{before, after}, later...) {
+		b, err := json.Marshal(cp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.recordCheckpointHistory(b)
+	}
+	return n, n.checkpointHistoryNames()
+}
+
+func TestCheckpointHistoryRetention(t *testing.T) {
+	_, after := checkpointPair()
+	later := after
+	later.CheckpointTime += 300
+	later.LamportStamp = 120
+	_, names := historyNode(t, 2, later)
+	want := []string{checkpointHistoryName(after), checkpointHistoryName(later)}
+	if len(names) != 2 || names[0] != want[0] || names[1] != want[1] {
+		t.Errorf("retained %v, want %v", names, want)
+	}
+}
+
+func TestCheckpointNameAt(t *testing.T) {
+	n, names := historyNode(t, 10)
+	before, after := checkpointPair()
+	cases := []struct {
+		at   int64
+		want string
+	}{
+		{before.CheckpointTime, names[0]},
+		{before.CheckpointTime + 60, names[0]},
+		{after.CheckpointTime, names[1]},
+		{after.CheckpointTime + 3600, names[1]},
+		{before.CheckpointTime - 1, ""},
+	}
+	for _, c := range cases {
+		got, err := n.checkpointNameAt(time.Unix(c.at, 0))
+		if got != c.want || (err != nil) != (c.want == "") {
+			t.Errorf("checkpoint at %d = %q, %v; want %q", c.at, got, err, c.want)
+		}
+	}
+}
+
+func TestStateAtAndDiffEndpoints(t *testing.T) {
+	n, names := historyNode(t, 10)
+	version := n.Queue.Version()
+	get := func(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get(n.handleStateAtRequest, "/admin/state-at?at=2026-03-01T08:17:00Z")
+	var at struct {
+		Checkpoint string
+		State      QueueSnapshot
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &at); err != nil {
+		t.Fatalf("%d %s: %v", rec.Code, rec.Body, err)
+	}
+	if at.Checkpoint != names[0] || at.State.CurrentItem == nil || at.State.CurrentItem.ID != "lot1" || at.State.CurrentHighestBid != 50 {
+		t.Errorf("state at 8:17 = %s on %v at %d, want %s on lot1 at 50", at.Checkpoint, at.State.CurrentItem, at.State.CurrentHighestBid, names[0])
+	}
+
+	rec = get(n.handleStateDiffRequest, "/admin/state-diff?from="+names[0]+"&to="+names[1])
+	var d StateDiff
+	if err := json.Unmarshal(rec.Body.Bytes(), &d); err != nil {
+		t.Fatalf("%d %s: %v", rec.Code, rec.Body, err)
+	}
+	if d.From != names[0] || d.To != names[1] || len(d.ResultsAdded) != 1 || d.HighestBid == nil {
+		t.Errorf("diff = %+v", d)
+	}
+
+	bad := []struct {
+		handler http.HandlerFunc
+		target  string
+		code    int
+	}{
+		{n.handleStateAtRequest, "/admin/state-at", http.StatusBadRequest},
+		{n.handleStateAtRequest, "/admin/state-at?at=teatime", http.StatusBadRequest},
+		{n.handleStateAtRequest, "/admin/state-at?checkpoint=../../etc/passwd", http.StatusNotFound},
+		{n.handleStateAtRequest, "/admin/state-at?checkpoint=20990101T000000Z-L00000001", http.StatusNotFound},
+		{n.handleStateDiffRequest, "/admin/state-diff?from=" + names[0], http.StatusBadRequest},
+	}
+	for _, e := range bad {
+		if rec := get(e.handler, e.target); rec.Code != e.code {
+			t.Errorf("%s answered %d, want %d", e.target, rec.Code, e.code)
+		}
+	}
+
+	// Reading history never touches the live state.
+	if got := n.Queue.Version(); got != version {
+		t.Errorf("queue version moved from %d to %d", version, got)
+	}
+	if got := highestBid(n); got != 10 {
+		t.Errorf("live highest bid = %d, want 10", got)
+	}
+}
@@ -0,0 +1,72 @@
+package node
+
+// stepdown.go — A coordinator that loses contact with a majority steps down.
+//
+// Cut off from its peers, a leader cannot commit bids (2PC needs a quorum),
+// but it would still run the item timer and finalize lots on its own,
+// producing results the rest of the cluster never sees. So every heartbeat
+// round the leader counts the peers that acknowledged a heartbeat within
+// --majority-loss-window. If it and those peers have been short of a quorum
+// for the whole window, it demotes itself: Coordinator is cleared, which
+// ends its spell as coordinator (leaderrole.go) and with it its heartbeats
+// and item timers, and it goes back to syncing, so it
+// serves reads only and refuses bids until it has pulled state from
+// whoever leads next.
+//
+// It then takes part in elections as a follower. Like any candidate, it
+// claims leadership only in an election round that reaches a majority (see
+// runElection), so a partitioned top-ranked node cannot win every election
+// on its own side.
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const DefaultMajorityLossWindow = 5 * time.Second
+
+// heartbeatMajority reports how many nodes, counting this one, acknowledged
+// a heartbeat within window, and the quorum. Peers not heard from since
+// leadership began count as seen at that time.
+func (n *Node) heartbeatMajority(peers []string, leaderSince time.Time, window time.Duration) (int, int) {
+	now := time.Now()
+	reachable := 1
+	n.healthMu.Lock()
+	for _, p := range peers {
+		last, ok := n.peerLastSeen[p]
+		if !ok || last.Before(leaderSince) {
+			last = leaderSince
+		}
+		if now.Sub(last) <= window {
+			reachable++
+		}
+	}
+	n.healthMu.Unlock()
+	return reachable, quorumFor(len(peers))
+}
+
+// stepDownLostMajority demotes this coordinator. It returns false if the
+// node was no longer coordinator anyway.
+func (n *Node) stepDownLostMajority(reachable, total int) bool {
+	n.ElectionMutex.Lock()
+	if n.Coordinator != n.ID {
+		n.ElectionMutex.Unlock()
+		return false
+	}
+	n.setCoordinatorLocked("")
+	term := n.Term
+	n.ElectionMutex.Unlock()
+
+	msg := fmt.Sprintf("%s stepped down in term %d: only %d of %d nodes answered heartbeats for %s",
+		n.ID, term, reachable, total, n.MajorityLossWindow)
+	log.Printf("[%s] ⬇️  %s\n", n.ID, msg)
+	n.Metrics.Inc("leader_step_downs_total")
+	n.noteElectionEvent(LeaderSteppedDown, term, "", fmt.Sprintf("lost the majority: %d of %d nodes reachable", reachable, total))
+	n.Metrics.Inc("leader_majority_lost_total")
+	if n.Phase() == PhaseReady {
+		n.setPhase(PhaseSyncing, "stepped down after losing the majority; re-syncing from the next leader")
+	}
+	n.Alerts.Notify("leader_stepped_down", SeverityCritical, msg)
+	return true
+}
@@ -0,0 +1,100 @@
+package node
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// heartbeatAnswer acknowledges heartbeats and nothing else.
+func heartbeatAnswer(_ context.Context, method string, reply interface{}) error {
+	if method == "NodeRPC.HandleHeartbeat" {
+		*reply.(*bool) = true
+	}
+	return nil
+}
+
+// leadWithHeartbeats makes n coordinator and runs its heartbeats until
+// they stop; the returned channel closes then.
+func leadWithHeartbeats(t *testing.T, n *Node) <-chan struct{} {
+	t.Helper()
+	n.HeartbeatInterval = 20 * time.Millisecond
+	n.MajorityLossWindow = 300 * time.Millisecond
+	leading(t, n)
+	n.setPhase(PhaseReady, "test")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n.BroadcastHeartbeats(n.leaderContext())
+	}()
+	return done
+}
+
+func TestLeaderStepsDownWithoutMajority(t *testing.T) {
+	// Every heartbeat call fails.
+	n := electionNode(t, &fakeCaller{}, "p1:1", "p3:1")
+	spell := time.Now()
+	stopped := leadWithHeartbeats(t, n)
+
+	select {
+	case <-stopped:
+	case <-time.After(n.MajorityLossWindow + time.Second):
+		t.Fatal("still sending heartbeats a second after the window")
+	}
+	if took := time.Since(spell); took < n.MajorityLossWindow {
+		t.Errorf("stepped down after %s, before the %s window", took, n.MajorityLossWindow)
+	}
+	if n.leaderKnown() || n.leaderContext().Err() == nil {
+		t.Error("still coordinator after losing the majority")
+	}
+	if n.Phase() != PhaseSyncing || n.IsReady() {
+		t.Errorf("phase %s, want syncing until the next leader's state arrives", n.Phase())
+	}
+	if ok, reason := n.canPrepareBid(BidArgs{BidderID: "b1", DisplayName: "b1", Amount: 50, ItemID: "lot1"}); ok {
+		t.Error("a stepped-down node voted for a bid")
+	} else if reason == "" {
+		t.Error("no reason given for the NO vote")
+	}
+	for _, name := range []string{"leader_step_downs_total", "leader_majority_lost_total"} {
+		if got := n.Metrics.Counter(name); got != 1 {
+			t.Errorf("%s = %v, want 1", name, got)
+		}
+	}
+	if !slices.Contains(delivered(n.Alerts), "leader_stepped_down") {
+		t.Errorf("alerts %v lack leader_stepped_down", delivered(n.Alerts))
+	}
+
+	// Connectivity returns with a new leader: the node follows it, and is
+	// Ready again only once it has synced.
+	var ok bool
+	if err := (&NodeRPC{node: n}).HandleHeartbeat(BullyMessage{NodeID: "N3", Rank: 3, Term: 1, Address: "p3:1"}, &ok); err != nil || !ok {
+		t.Fatalf("heartbeat from the new leader: %v %v", ok, err)
+	}
+	if n.IsReady() {
+		t.Error("Ready before syncing with the new leader")
+	}
+	n.markSynced("test")
+	if !n.IsReady() {
+		t.Errorf("phase %s after syncing", n.Phase())
+	}
+}
+
+func TestLeaderKeepsMajorityWithOnePeer(t *testing.T) {
+	// One of two peers answers: with the leader that is two of three.
+	caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{"p1:1": heartbeatAnswer}}
+	n := electionNode(t, caller, "p1:1", "p3:1")
+	stopped := leadWithHeartbeats(t, n)
+
+	select {
+	case <-stopped:
+		t.Fatal("stepped down with a majority")
+	case <-time.After(3 * n.MajorityLossWindow):
+	}
+	if !n.leaderKnown() || n.Metrics.Counter("leader_majority_lost_total") != 0 {
+		t.Error("lost leadership with a majority")
+	}
+	if got := gauge(n.Metrics, "leader_reachable_nodes"); got != 2 {
+		t.Errorf("leader_reachable_nodes = %v, want 2", got)
+	}
+}
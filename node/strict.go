@@ -0,0 +1,110 @@
+package node
+
+// strict.go — --strict-consistency: quarantine rather than move backwards.
+//
+// By default a follower applies whatever snapshot the coordinator pushes, and
+// term fencing only drops traffic from deposed leaders. In strict mode a
+// snapshot or decision that would move the node's state backwards is not
+// applied at all, and the node stops taking part:
+//
+//   - a lower high bid on the item that is up locally
+//   - fewer results (live plus archived) than the node already has
+//   - an election term older than the node's own
+//
+// The node enters PhaseQuarantined, which votes NO in 2PC, refuses bids,
+// decisions and snapshots, and keeps it out of elections, and raises a
+// critical state_regression alert listing each regressed field next to the
+// local value. It stays there until an operator runs POST /admin/rebuild,
+// which replaces its state with the coordinator's, or POST /admin/drain
+// action=resume, which re-syncs with the checks still on.
+//
+// Decisions are only checked for their term: a retried decision can
+// legitimately arrive after a higher bid was committed.
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const regressionAlertKey = "state_regression"
+
+var errQuarantined = errors.New("node is quarantined after a state regression; see its state_regression alert")
+
+// snapshotRegressions lists how snap would move local state backwards.
+func (n *Node) snapshotRegressions(snap QueueSnapshot) []string {
+	var diffs []string
+	if term := n.currentTerm(); snap.Term != 0 && snap.Term < term {
+		diffs = append(diffs, fmt.Sprintf("term: local %d, incoming %d", term, snap.Term))
+	}
+	n.Queue.mu.Lock()
+	defer n.Queue.mu.Unlock()
+	if local, in := n.Queue.CurrentItem, snap.CurrentItem; local != nil && in != nil && local.ID == in.ID &&
+		snap.CurrentHighestBid < n.Queue.CurrentHighestBid {
+		diffs = append(diffs, fmt.Sprintf("high bid on %s: local $%d by %s, incoming $%d by %s",
+			local.ID, n.Queue.CurrentHighestBid, n.Queue.CurrentWinner, snap.CurrentHighestBid, snap.CurrentWinner))
+	}
+	localResults := len(n.Queue.Results) + n.Queue.ResultsTrimmed
+	if incoming := len(snap.Results) + snap.ResultsTrimmed; incoming < localResults {
+		diffs = append(diffs, fmt.Sprintf("results: local %d, incoming %d", localResults, incoming))
+	}
+	return diffs
+}
+
+// admitSnapshot decides whether a snapshot from the coordinator may be
+// applied: never while quarantined, and in strict mode not if it regresses
+// local state, which quarantines the node. Otherwise it is term-fenced.
+func (n *Node) admitSnapshot(snap QueueSnapshot, source string) error {
+	if n.Phase() == PhaseQuarantined {
+		return errQuarantined
+	}
+	if n.StrictConsistency {
+		if diffs := n.snapshotRegressions(snap); len(diffs) > 0 {
+			n.quarantine("snapshot", source, diffs)
+			n.captureDeadLetter("snapshot", source, "regression", snap, errors.New(strings.Join(diffs, "; ")))
+			return errQuarantined
+		}
+	}
+	if !n.admitTerm(snap.Term, "", "snapshot") {
+		n.captureDeadLetter("snapshot", source, "stale_term", snap, errStaleTerm)
+		return errStaleTerm
+	}
+	return nil
+}
+
+// admitDecision is admitSnapshot for 2PC decisions.
+func (n *Node) admitDecision(args DecisionArgs) error {
+	if n.Phase() == PhaseQuarantined {
+		return errQuarantined
+	}
+	if term := n.currentTerm(); n.StrictConsistency && args.Term != 0 && args.Term < term {
+		diff := fmt.Sprintf("term: local %d, incoming %d (txn %s)", term, args.Term, args.TxnID)
+		n.quarantine("decision", args.Leader, []string{diff})
+		n.captureDeadLetter("decision", args.Leader, "regression", args, errors.New(diff))
+		return errQuarantined
+	}
+	if !n.admitTerm(args.Term, args.Leader, "decision") {
+		n.captureDeadLetter("decision", args.Leader, "stale_term", args, errStaleTerm)
+		return errStaleTerm
+	}
+	return nil
+}
+
+// quarantine halts the node after a regression until an operator steps in.
+func (n *Node) quarantine(kind, source string, diffs []string) {
+	if source == "" {
+		source = "the coordinator"
+	}
+	n.setPhase(PhaseQuarantined, fmt.Sprintf("%s from %s regresses local state", kind, source))
+	n.Metrics.Inc(metricName("strict_quarantines_total", "kind", kind))
+	log.Printf("[%s] 🛑 ==============================================================\n", n.ID)
+	log.Printf("[%s] 🛑 Refused %s from %s: it would move state backwards\n", n.ID, kind, source)
+	for _, d := range diffs {
+		log.Printf("[%s] 🛑   %s\n", n.ID, d)
+	}
+	log.Printf("[%s] 🛑 Quarantined. Run POST /admin/rebuild or /admin/drain action=resume\n", n.ID)
+	log.Printf("[%s] 🛑 ==============================================================\n", n.ID)
+	n.Alerts.Raise(regressionAlertKey, SeverityCritical,
+		fmt.Sprintf("%s quarantined: %s from %s regresses local state (%s)", n.ID, kind, source, strings.Join(diffs, "; ")))
+}
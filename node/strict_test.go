@@ -0,0 +1,125 @@
+package node
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// regressionTarget is a follower in term 3 holding a $50 bid on lot1 and
+// one result.
+func regressionTarget(t *testing.T, strict bool) *Node {
+	t.Helper()
+	n := biddingNode(t)
+	n.StrictConsistency = strict
+	n.ElectionMutex.Lock()
+	n.Term = 3
+	n.ElectionMutex.Unlock()
+	n.Queue.mu.Lock()
+	n.Queue.CurrentHighestBid, n.Queue.CurrentWinner, n.Queue.CurrentWinnerID = 50, "Ann", "b1"
+	n.Queue.Results = []ItemResult{{Item: AuctionItem{ID: "lot0", Name: "Lamp", StartingPrice: 5, DurationSec: 30}, Winner: "Bob", WinnerID: "b2", WinningBid: 20}}
+	n.Queue.mu.Unlock()
+	return n
+}
+
+// regressiveSnapshot moves every checked field of n backwards.
+func regressiveSnapshot(n *Node) QueueSnapshot {
+	snap := n.buildQueueSnapshot()
+	snap.Term = 2
+	snap.CurrentHighestBid, snap.CurrentWinner, snap.CurrentWinnerID = 30, "Cat", "b3"
+	snap.Results = nil
+	snap.IsCoordinator = true
+	return snap
+}
+
+func TestRegressiveSnapshotByMode(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		n := regressionTarget(t, false)
+		var ok bool
+		if err := (&NodeRPC{node: n}).SyncQueueState(regressiveSnapshot(n), &ok); !errors.Is(err, errStaleTerm) {
+			t.Fatalf("SyncQueueState = %v, want it dropped as stale", err)
+		}
+		if highestBid(n) != 50 || n.Phase() != PhaseReady || n.Alerts.IsActive(regressionAlertKey) {
+			t.Errorf("default mode: $%d, phase %s, alert %v; want the snapshot dropped quietly", highestBid(n), n.Phase(), n.Alerts.IsActive(regressionAlertKey))
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		n := regressionTarget(t, true)
+		rp := &NodeRPC{node: n}
+		var ok bool
+		if err := rp.SyncQueueState(regressiveSnapshot(n), &ok); !errors.Is(err, errQuarantined) {
+			t.Fatalf("SyncQueueState = %v, want quarantine", err)
+		}
+		if highestBid(n) != 50 || n.Phase() != PhaseQuarantined {
+			t.Fatalf("strict mode: $%d, phase %s", highestBid(n), n.Phase())
+		}
+		active, _ := n.Alerts.snapshot()
+		if len(active) != 1 || active[0].Key != regressionAlertKey || active[0].Severity != SeverityCritical {
+			t.Fatalf("active alerts = %+v", active)
+		}
+		for _, diff := range []string{"term: local 3, incoming 2", "high bid on lot1: local $50 by Ann, incoming $30 by Cat", "results: local 1, incoming 0"} {
+			if !strings.Contains(active[0].Message, diff) {
+				t.Errorf("alert %q lacks %q", active[0].Message, diff)
+			}
+		}
+		if letters := n.deadLetters.list(); len(letters) != 1 || letters[0].Reason != "regression" {
+			t.Errorf("dead letters = %+v", letters)
+		}
+		if got := n.Metrics.Counter(metricName("strict_quarantines_total", "kind", "snapshot")); got != 1 {
+			t.Errorf("strict_quarantines_total{kind=snapshot} = %v, want 1", got)
+		}
+
+		// Quarantined, it takes no part in 2PC, even for good traffic.
+		var vote PrepareReply
+		if err := rp.PrepareBid(PrepareArgs{TxnID: "C-1", Bid: BidArgs{BidderID: "b4", DisplayName: "Dee", Amount: 60, ItemID: "lot1"}}, &vote); err != nil || vote.Vote {
+			t.Errorf("PrepareBid while quarantined: vote %v, %v", vote.Vote, err)
+		}
+		var applied bool
+		if err := rp.DecideBid(DecisionArgs{TxnID: "C-1", Commit: true, Bid: BidArgs{BidderID: "b4", DisplayName: "Dee", Amount: 60, ItemID: "lot1"}, Leader: "C", Term: 3}, &applied); err != nil || applied {
+			t.Errorf("DecideBid while quarantined: applied %v, %v", applied, err)
+		}
+		if err := rp.SyncQueueState(n.buildQueueSnapshot(), &ok); !errors.Is(err, errQuarantined) {
+			t.Errorf("snapshot while quarantined: %v", err)
+		}
+
+		// The operator resumes it: it re-syncs and the alert clears.
+		n.Resume()
+		if n.Phase() != PhaseSyncing || n.Alerts.IsActive(regressionAlertKey) {
+			t.Errorf("after resume: phase %s, alert %v", n.Phase(), n.Alerts.IsActive(regressionAlertKey))
+		}
+	})
+}
+
+func TestStrictModeChecks(t *testing.T) {
+	n := regressionTarget(t, true)
+	rp := &NodeRPC{node: n}
+
+	// Moving forward is fine.
+	snap := n.buildQueueSnapshot()
+	snap.CurrentHighestBid, snap.CurrentWinner, snap.CurrentWinnerID = 70, "Dee", "b4"
+	var ok bool
+	if err := rp.SyncQueueState(snap, &ok); err != nil || highestBid(n) != 70 {
+		t.Fatalf("forward snapshot: %v, $%d", err, highestBid(n))
+	}
+	// So is a lower bid on the next item.
+	snap.CurrentItem, snap.CurrentHighestBid = &AuctionItem{ID: "lot2", StartingPrice: 5}, 5
+	if diffs := n.snapshotRegressions(snap); len(diffs) != 0 {
+		t.Errorf("next item flagged: %v", diffs)
+	}
+	// Archived results count towards what the node has.
+	snap.Results, snap.ResultsTrimmed = nil, 1
+	if diffs := n.snapshotRegressions(snap); len(diffs) != 0 {
+		t.Errorf("results moved to the archive flagged: %v", diffs)
+	}
+
+	// A decision is only checked for its term.
+	var applied bool
+	stale := DecisionArgs{TxnID: "C-2", Commit: true, Bid: BidArgs{BidderID: "b5", DisplayName: "Eve", Amount: 90, ItemID: "lot1"}, Leader: "C", Term: 2}
+	if err := rp.DecideBid(stale, &applied); err != nil || applied || n.Phase() != PhaseQuarantined {
+		t.Errorf("stale decision: applied %v, phase %s", applied, n.Phase())
+	}
+	if got := n.Metrics.Counter(metricName("strict_quarantines_total", "kind", "decision")); got != 1 {
+		t.Errorf("strict_quarantines_total{kind=decision} = %v, want 1", got)
+	}
+}
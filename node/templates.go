@@ -0,0 +1,247 @@
+package node
+
+// templates.go — Auction templates: named item catalogues saved as plain JSON
+// under checkpoints/templates/ and re-used for later events.
+//
+// A template holds items only, never results or bids, and carries no item
+// IDs: applying one adds each item through the coordinator exactly like
+// POST /admin/item, so the coordinator numbers them. Templates are stored on
+// the node that captured them; copy the file to share it with other nodes.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// TemplateItem is one catalogue entry, in the same shape as the JSON body
+// of POST /admin/item.
+type TemplateItem struct {
+	Name           string            `json:"name"`
+	Description    string            `json:"description"`
+	StartingPrice  int               `json:"startingPrice"`
+	DurationSec    int               `json:"durationSec"`
+	Emoji          string            `json:"emoji,omitempty"`
+	Category       string            `json:"category,omitempty"`
+	Increments     []IncrementBand   `json:"increments,omitempty"`
+	AllowedBidders []string          `json:"allowedBidders,omitempty"`
+	Custom         map[string]string `json:"custom,omitempty"`
+}
+
+func (t TemplateItem) addItemArgs() AddItemArgs {
+	return AddItemArgs{
+		Name:           t.Name,
+		Description:    t.Description,
+		StartingPrice:  t.StartingPrice,
+		DurationSec:    t.DurationSec,
+		Emoji:          t.Emoji,
+		Category:       t.Category,
+		Increments:     t.Increments,
+		AllowedBidders: t.AllowedBidders,
+		Custom:         t.Custom,
+	}
+}
+
+// AuctionTemplate is the on-disk template file.
+type AuctionTemplate struct {
+	Name          string         `json:"name"`
+	CreatedAtUnix int64          `json:"createdAtUnix"`
+	CreatedBy     string         `json:"createdBy"` // node ID
+	Items         []TemplateItem `json:"items"`
+}
+
+// TemplateInfo is one row of GET /admin/templates.
+type TemplateInfo struct {
+	Name          string `json:"name"`
+	Items         int    `json:"items"`
+	CreatedAtUnix int64  `json:"createdAtUnix"`
+	Error         string `json:"error,omitempty"` // set when the file does not parse
+}
+
+func templateDir() string {
+	return filepath.Join(checkpointDir, "templates")
+}
+
+func templatePath(name string) string {
+	return filepath.Join(templateDir(), name+".json")
+}
+
+func loadTemplate(name string) (*AuctionTemplate, error) {
+	if !templateNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid template name %q", name)
+	}
+	b, err := os.ReadFile(templatePath(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t AuctionTemplate
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("parse template %q: %w", name, err)
+	}
+	t.Name = name
+	return &t, nil
+}
+
+// captureTemplate saves the current item and the queue behind it as name.
+func (n *Node) captureTemplate(name string, overwrite bool) (*AuctionTemplate, error) {
+	if !templateNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("template name must be 1-64 letters, digits, '-' or '_'")
+	}
+	if _, err := os.Stat(templatePath(name)); err == nil && !overwrite {
+		return nil, fmt.Errorf("template %q already exists; pass overwrite=true to replace it", name)
+	}
+
+	n.Queue.mu.Lock()
+	items := make([]AuctionItem, 0, len(n.Queue.Queue)+1)
+	if n.Queue.CurrentItem != nil {
+		items = append(items, *n.Queue.CurrentItem)
+	}
+	items = append(items, n.Queue.Queue...)
+	n.Queue.mu.Unlock()
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no items to capture")
+	}
+
+	t := &AuctionTemplate{Name: name, CreatedAtUnix: time.Now().Unix(), CreatedBy: n.ID}
+	for _, item := range items {
+		t.Items = append(t.Items, TemplateItem{
+			Name:           item.Name,
+			Description:    item.Description,
+			StartingPrice:  item.StartingPrice,
+			DurationSec:    item.DurationSec,
+			Emoji:          item.Emoji,
+			Category:       item.Category,
+			Increments:     item.Increments,
+			AllowedBidders: item.AllowedBidders,
+			Custom:         item.Custom,
+		})
+	}
+	b, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(templateDir(), 0o755); err != nil {
+		return nil, err
+	}
+	tmp := templatePath(name) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, templatePath(name)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// listTemplates returns the saved templates sorted by name.
+func listTemplates() []TemplateInfo {
+	files, _ := filepath.Glob(filepath.Join(templateDir(), "*.json"))
+	infos := make([]TemplateInfo, 0, len(files))
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".json")
+		info := TemplateInfo{Name: name}
+		if t, err := loadTemplate(name); err != nil {
+			info.Error = err.Error()
+		} else {
+			info.Items, info.CreatedAtUnix = len(t.Items), t.CreatedAtUnix
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// applyTemplate adds every item of the template through the coordinator as
+// one batch, so a template is applied whole or not at all. It only runs
+// while no auction is in progress.
+func (n *Node) applyTemplate(name string) (int, string) {
+	t, err := loadTemplate(name)
+	if err != nil {
+		return http.StatusNotFound, err.Error()
+	}
+	if len(t.Items) == 0 {
+		return http.StatusBadRequest, fmt.Sprintf("Template %q has no items", name)
+	}
+	n.Queue.mu.Lock()
+	active := n.Queue.Active
+	n.Queue.mu.Unlock()
+	if active {
+		return http.StatusConflict, "Stop the auction before applying a template"
+	}
+
+	items := make([]AddItemArgs, len(t.Items))
+	for i, item := range t.Items {
+		items[i] = item.addItemArgs()
+	}
+	status, reply := n.submitBatchAddItems(items)
+	if status != http.StatusOK {
+		if len(reply.Errors) > 0 {
+			e := reply.Errors[0]
+			return status, fmt.Sprintf("Template %q: %s (first: item %d, %s: %s)", name, reply.Message, e.Index+1, t.Items[e.Index].Name, e.Error)
+		}
+		return status, fmt.Sprintf("Template %q: %s", name, reply.Message)
+	}
+	n.Metrics.Inc("templates_applied_total")
+	return http.StatusOK, fmt.Sprintf("Template %q applied: %d items added", name, len(t.Items))
+}
+
+// handleTemplatesRequest serves GET /admin/templates (list) and
+// POST /admin/templates (name=..., overwrite=true to replace).
+func (n *Node) handleTemplatesRequest(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, listTemplates())
+	case http.MethodPost:
+		t, err := n.captureTemplate(strings.TrimSpace(r.FormValue("name")), r.FormValue("overwrite") == "true")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, t)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTemplateRequest serves GET /admin/templates/{name} and
+// POST /admin/templates/{name}/apply.
+func (n *Node) handleTemplateRequest(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/admin/templates/")
+	name, action, _ := strings.Cut(rest, "/")
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		t, err := loadTemplate(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, t)
+	case action == "apply" && r.Method == http.MethodPost:
+		var status int
+		var message string
+		if !n.holdForClient(r, "admin_template", func() { status, message = n.applyTemplate(name) }) {
+			return
+		}
+		if status != http.StatusOK {
+			http.Error(w, message, status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(message))
+	case action == "" || action == "apply":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
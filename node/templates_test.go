@@ -0,0 +1,167 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func postForm(h http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h(rec, req)
+	return rec
+}
+
+// springItems is a catalogue with every field a template carries.
+var springItems = []AuctionItem{
+	{ID: "lot1", Name: "Vase", Description: "Ming-style vase", StartingPrice: 40, DurationSec: 60, Emoji: "🏺", Category: "decor"},
+	{ID: "item-40", Name: "Bike", Description: "Road bike", StartingPrice: 200, DurationSec: 90, Emoji: "🚲", Category: "sport",
+		Increments: []IncrementBand{{UpTo: 500, Increment: 10}, {Increment: 50}}, AllowedBidders: []string{"b1", "b2"},
+		Custom: map[string]string{"size": "54cm"}},
+	{ID: "item-41", Name: "Lamp", Description: "Brass desk lamp", StartingPrice: 25, DurationSec: 30, Emoji: "💡", Category: "decor"},
+}
+
+func TestTemplateRoundTripOntoFreshCluster(t *testing.T) {
+	// Capture the catalogue on one node...
+	src := biddingNode(t)
+	src.Queue.mu.Lock()
+	src.Queue.CurrentItem = &springItems[0]
+	src.Queue.Queue = append([]AuctionItem(nil), springItems[1:]...)
+	src.Queue.mu.Unlock()
+	if rec := postForm(src.handleTemplatesRequest, "/admin/templates", url.Values{"name": {"spring"}}); rec.Code != http.StatusOK {
+		t.Fatalf("capture: %d %s", rec.Code, rec.Body)
+	}
+	file, err := os.ReadFile(templatePath("spring"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(file), "lot1") || strings.Contains(string(file), "item-40") {
+		t.Errorf("template keeps item IDs:\n%s", file)
+	}
+
+	// ...and apply it, as a copied file, on a cluster that has never seen it.
+	nodes := testCluster(t, "A", "B")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+	if err := os.MkdirAll(templateDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(templatePath("spring"), file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if rec := postForm(b.handleTemplateRequest, "/admin/templates/spring/apply", nil); rec.Code != http.StatusOK {
+		t.Fatalf("apply via B: %d %s", rec.Code, rec.Body)
+	}
+
+	// The six default lots hold item-1..6, so A numbers the template from 7.
+	wantIDs := []string{"item-7", "item-8", "item-9"}
+	for _, tn := range nodes {
+		waitFor(t, tn.ID+" to receive the template's lots", func() bool {
+			tn.Queue.mu.Lock()
+			defer tn.Queue.mu.Unlock()
+			return len(tn.Queue.Queue) == 9
+		})
+		tn.Queue.mu.Lock()
+		added := append([]AuctionItem(nil), tn.Queue.Queue[6:]...)
+		tn.Queue.mu.Unlock()
+		for i, item := range added {
+			want := springItems[i]
+			if item.ID != wantIDs[i] || item.Name != want.Name || item.Description != want.Description ||
+				item.StartingPrice != want.StartingPrice || item.DurationSec != want.DurationSec ||
+				item.Emoji != want.Emoji || item.Category != want.Category ||
+				fmt.Sprint(item.Increments, item.AllowedBidders, item.Custom) != fmt.Sprint(want.Increments, want.AllowedBidders, want.Custom) {
+				t.Errorf("%s: lot %d = %+v, want %s with the fields of %+v", tn.ID, i+1, item, wantIDs[i], want)
+			}
+		}
+	}
+	if got := b.Metrics.Counter("templates_applied_total"); got != 1 {
+		t.Errorf("templates_applied_total = %v, want 1", got)
+	}
+}
+
+func TestTemplateRequests(t *testing.T) {
+	n := biddingNode(t)
+	setLeader(n, n.ID, n.Address)
+	leading(t, n)
+	n.Queue.mu.Lock()
+	n.Queue.CurrentItem = &springItems[0]
+	n.Queue.mu.Unlock()
+	capture := func(form url.Values) *httptest.ResponseRecorder {
+		return postForm(n.handleTemplatesRequest, "/admin/templates", form)
+	}
+	get := func(h http.HandlerFunc, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	for _, name := range []string{"", "../escape", "has space", strings.Repeat("x", 65)} {
+		if rec := capture(url.Values{"name": {name}}); rec.Code != http.StatusBadRequest {
+			t.Errorf("name %q: %d, want 400", name, rec.Code)
+		}
+	}
+	if rec := capture(url.Values{"name": {"weekly"}}); rec.Code != http.StatusOK {
+		t.Fatalf("capture: %d %s", rec.Code, rec.Body)
+	}
+	if rec := capture(url.Values{"name": {"weekly"}}); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "overwrite") {
+		t.Errorf("capture over an existing template: %d %s", rec.Code, rec.Body)
+	}
+	if rec := capture(url.Values{"name": {"weekly"}, "overwrite": {"true"}}); rec.Code != http.StatusOK {
+		t.Errorf("overwrite: %d %s", rec.Code, rec.Body)
+	}
+
+	// A hand-edited file that no longer parses is listed with its error.
+	if err := os.WriteFile(filepath.Join(templateDir(), "broken.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var list []TemplateInfo
+	if err := json.Unmarshal(get(n.handleTemplatesRequest, "/admin/templates").Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "broken" || list[0].Error == "" || list[1].Name != "weekly" || list[1].Items != 7 {
+		t.Errorf("templates = %+v, want broken (with an error) and weekly (7 items)", list)
+	}
+
+	var tmpl AuctionTemplate
+	if rec := get(n.handleTemplateRequest, "/admin/templates/weekly"); json.Unmarshal(rec.Body.Bytes(), &tmpl) != nil || tmpl.CreatedBy != n.ID || len(tmpl.Items) != 7 {
+		t.Errorf("GET weekly: %d %s", rec.Code, rec.Body)
+	}
+	if rec := get(n.handleTemplateRequest, "/admin/templates/missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET missing: %d, want 404", rec.Code)
+	}
+
+	// Applying needs the auction stopped, and nothing is added otherwise.
+	apply := func(name string) *httptest.ResponseRecorder {
+		return postForm(n.handleTemplateRequest, "/admin/templates/"+name+"/apply", nil)
+	}
+	if rec := apply("weekly"); rec.Code != http.StatusConflict {
+		t.Errorf("apply during an auction: %d %s, want 409", rec.Code, rec.Body)
+	}
+	if rec := apply("missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("apply missing: %d, want 404", rec.Code)
+	}
+	n.Queue.mu.Lock()
+	n.Queue.Active = false
+	queued := len(n.Queue.Queue)
+	n.Queue.mu.Unlock()
+	if rec := apply("weekly"); rec.Code != http.StatusOK {
+		t.Fatalf("apply: %d %s", rec.Code, rec.Body)
+	}
+	n.Queue.mu.Lock()
+	added := len(n.Queue.Queue) - queued
+	n.Queue.mu.Unlock()
+	if added != 7 {
+		t.Errorf("apply added %d lots, want 7", added)
+	}
+}
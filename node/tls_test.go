@@ -0,0 +1,226 @@
+package node
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is a self-signed CA that issues certificates for 127.0.0.1.
+type testCA struct {
+	dir    string
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	caFile string
+	serial int64
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	ca := &testCA{dir: t.TempDir(), serial: 1}
+	ca.key = genKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(ca.serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &ca.key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca.cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	ca.caFile = writePEM(t, ca.dir, name+"-ca.pem", "CERTIFICATE", der)
+	return ca
+}
+
+// issue writes a certificate for a node, usable as both server and client,
+// and returns the certificate and key files.
+func (ca *testCA) issue(t *testing.T, name string) (certFile, keyFile string) {
+	t.Helper()
+	ca.serial++
+	key := genKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(ca.serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, ca.dir, name+".pem", "CERTIFICATE", der), writePEM(t, ca.dir, name+"-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+// clusterTLS loads name's certificate from ca the way main does.
+func (ca *testCA) clusterTLS(t *testing.T, name string, requireClientCert bool) *ClusterTLS {
+	t.Helper()
+	certFile, keyFile := ca.issue(t, name)
+	ct, err := LoadClusterTLS(certFile, keyFile, ca.caFile, requireClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ct
+}
+
+func genKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func writePEM(t *testing.T, dir, name, kind string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// tlsCluster is testCluster with each node serving and dialing over its
+// own TLS settings, as Start does with --tls-cert.
+func tlsCluster(t *testing.T, ids []string, configs []*ClusterTLS) []*testNode {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	listeners := make([]net.Listener, len(ids))
+	addrs := make([]string, len(ids))
+	for i := range ids {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners[i], addrs[i] = tls.NewListener(l, configs[i].Server), l.Addr().String()
+	}
+	nodes := make([]*testNode, len(ids))
+	for i, id := range ids {
+		n := NewNode(id, addrs[i], addrs, i+1)
+		n.UseTLS(configs[i])
+		nodes[i] = serveTestNode(t, n, listeners[i], addrs)
+	}
+	return nodes
+}
+
+func TestBidOverMutualTLS(t *testing.T) {
+	ca := newTestCA(t, "cluster")
+	nodes := tlsCluster(t, []string{"A", "B"}, []*ClusterTLS{ca.clusterTLS(t, "A", true), ca.clusterTLS(t, "B", true)})
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	leading(t, a.Node)
+
+	// A full 2PC round, PrepareBid included, runs over mTLS.
+	if r := a.submitBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "b1", Amount: 15, ItemID: "lot1"}); r.Code != BidCommitted {
+		t.Fatalf("bid over mTLS = %s: %s", r.Code, r.Message)
+	}
+	waitFor(t, "B to apply the commit", func() bool { return highestBid(b.Node) == 15 })
+
+	// Nobody without a certificate from the cluster CA gets a vote out of B.
+	trustsB := ca.clusterTLS(t, "C", false).Client
+	strangerCert, strangerKey := newTestCA(t, "stranger").issue(t, "M")
+	foreign, err := tls.LoadX509KeyPair(strangerCert, strangerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withForeignCert := trustsB.Clone()
+	withForeignCert.Certificates = []tls.Certificate{foreign}
+	cases := []struct {
+		name   string
+		config *tls.Config
+	}{
+		{"plain TCP", nil},
+		{"TLS without a client certificate", trustsB},
+		{"certificate from another CA", withForeignCert},
+	}
+	for i, tc := range cases {
+		c := &RPCClient{}
+		c.SetTLS(tc.config)
+		var vote PrepareReply
+		txnID := "X-" + tc.name
+		err := c.Call(b.Address, "NodeRPC.PrepareBid", PrepareArgs{TxnID: txnID, Bid: BidArgs{BidderID: "m", Amount: 20 + i, ItemID: "lot1"}}, &vote)
+		c.Close()
+		if err == nil || vote.Vote {
+			t.Errorf("%s: vote %v, err %v; want the connection refused", tc.name, vote.Vote, err)
+		}
+		b.TxnMutex.Lock()
+		_, pending := b.PendingTxns[txnID]
+		b.TxnMutex.Unlock()
+		if pending {
+			t.Errorf("%s: B prepared the bid", tc.name)
+		}
+	}
+}
+
+func TestTLSWithoutClientCerts(t *testing.T) {
+	ca := newTestCA(t, "cluster")
+	nodes := tlsCluster(t, []string{"A", "B"}, []*ClusterTLS{ca.clusterTLS(t, "A", false), ca.clusterTLS(t, "B", false)})
+	a, b := nodes[0], nodes[1]
+	withLotUp(b.Node)
+	if a.TLS.Client.Certificates != nil {
+		t.Error("client presents a certificate without --require-client-cert")
+	}
+	var vote PrepareReply
+	if err := a.callPeer(b.Address, "NodeRPC.PrepareBid", PrepareArgs{TxnID: "A-1", Bid: BidArgs{BidderID: "b1", DisplayName: "b1", Amount: 15, ItemID: "lot1"}}, &vote); err != nil || !vote.Vote {
+		t.Fatalf("PrepareBid over TLS: vote %v, err %v", vote.Vote, err)
+	}
+	// A plain client still cannot talk to a TLS node.
+	c := &RPCClient{}
+	defer c.Close()
+	if err := c.Call(b.Address, "NodeRPC.PrepareBid", PrepareArgs{TxnID: "A-2"}, &vote); err == nil {
+		t.Error("plain TCP call to a TLS node succeeded")
+	}
+}
+
+func TestLoadClusterTLSErrors(t *testing.T) {
+	ca := newTestCA(t, "cluster")
+	certFile, keyFile := ca.issue(t, "A")
+	cases := []struct {
+		name              string
+		cert, key, caFile string
+		requireClientCert bool
+		err               string
+	}{
+		{"key missing", certFile, "", ca.caFile, false, "must be given together"},
+		{"mTLS without a CA", certFile, keyFile, "", true, "needs --tls-ca"},
+		{"CA file with no certificates", certFile, keyFile, keyFile, false, "no certificates found"},
+		{"unreadable certificate", filepath.Join(ca.dir, "nope.pem"), keyFile, ca.caFile, false, "load certificate"},
+	}
+	for _, c := range cases {
+		if _, err := LoadClusterTLS(c.cert, c.key, c.caFile, c.requireClientCert); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: %v, want %q", c.name, err, c.err)
+		}
+	}
+	if ct, err := LoadClusterTLS(certFile, keyFile, "", false); err != nil || ct.Client.RootCAs != nil {
+		t.Errorf("no CA: %v, want the system roots", err)
+	}
+}
@@ -0,0 +1,612 @@
+package node
+
+// tokenring.go — Token-ring mutual exclusion, --mutex=token.
+//
+// One token circulates among the nodes in rank order, lowest first, the
+// highest passing it back to the lowest. A node enters the critical section
+// only while it holds the token and passes it on when it leaves; a node that
+// wants nothing holds it for tokenIdleHold and passes it on. Ranks come from
+// each peer's GetVersion answer; a peer whose rank is not known yet sits at
+// the start of the ring, by address, until it is.
+//
+// Every token carries a stamp: a generation, raised only when the token is
+// recreated, and a hop count, raised on every pass. A node accepts a token
+// only if its stamp is newer than any it has seen or been fenced at, so a
+// token that was given up for lost dies at the first node that knows better.
+//
+//   - A pass that fails is not taken to have failed: the peer may have got
+//     the token just as the call broke. The sender fences the peer at the
+//     stamp (FenceToken) and learns whether it arrived; if it did not, the
+//     peer will now refuse it, and the token goes to the next node with the
+//     next hop. A peer that cannot be reached for the fence is taken for
+//     down, as RA takes a peer that cannot be reached.
+//   - A token lost with a crashed holder is recreated. A node that has not
+//     seen the token for TokenTimeout (--token-timeout), plus one more for
+//     each known node that outranks it, so the highest-ranked live node goes
+//     first, claims the next generation from every peer (ClaimToken). A peer
+//     holding the token or ranking higher than the claimer refuses, and a
+//     peer grants each generation once; with a majority of grants, counting
+//     its own, the claimer creates the token. The granting nodes refuse any
+//     older token from then on. The first token is made the same way, a
+//     TokenTimeout after start.
+//
+// A node requesting the CS waits for the token, or until --ra-timeout.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/rpc"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTokenTimeout = 5 * time.Second
+	tokenIdleHold       = 50 * time.Millisecond
+	tokenPassTimeout    = 2 * time.Second
+	tokenRetryInterval  = time.Second // when no successor could be reached
+	tokenWatchInterval  = 250 * time.Millisecond
+)
+
+// TokenStamp orders tokens: a newer generation wins, then more hops.
+type TokenStamp struct {
+	Generation int
+	Hop        int
+}
+
+func (s TokenStamp) after(o TokenStamp) bool {
+	if s.Generation != o.Generation {
+		return s.Generation > o.Generation
+	}
+	return s.Hop > o.Hop
+}
+
+func (s TokenStamp) String() string {
+	return fmt.Sprintf("%d.%d", s.Generation, s.Hop)
+}
+
+// TokenArgs hands over the token (PassToken), or fences the receiver at
+// Stamp (FenceToken).
+type TokenArgs struct {
+	Stamp         TokenStamp
+	From          string // node ID of the sender
+	TargetAddress string // the receiver's address as the sender knows it
+}
+
+// TokenClaimArgs asks for a grant to recreate the token as Generation.
+type TokenClaimArgs struct {
+	Generation int
+	NodeID     string
+	Rank       int
+}
+
+type TokenClaimReply struct {
+	Granted  bool
+	Holding  bool // the token is here, or being passed from here
+	Outranks bool // this node ranks higher and recreates the token itself
+	Seen     TokenStamp
+}
+
+// tokenMember is one node of the ring.
+type tokenMember struct {
+	NodeID  string // the address while the rank is unknown
+	Rank    int
+	Address string
+}
+
+// TokenRingStatus is a read-only view of the token ring, for /admin/inflight.
+type TokenRingStatus struct {
+	HasToken          bool     `json:"hasToken"`
+	Stamp             string   `json:"stamp"` // generation.hop of the token last held here
+	Requesting        bool     `json:"requesting"`
+	InCriticalSection bool     `json:"inCriticalSection"`
+	WaitingMs         int64    `json:"waitingMs,omitempty"`
+	HeldMs            int64    `json:"heldMs,omitempty"`
+	UnseenMs          int64    `json:"unseenMs"` // since the token last passed through
+	Ring              []string `json:"ring"`     // passing order, node IDs where known
+	Passes            int64    `json:"passes"`
+	Regenerations     int64    `json:"regenerations"`
+}
+
+type TokenRingManager struct {
+	local   sync.Mutex // one local request at a time; held from RequestCS to ReleaseCS
+	mu      sync.Mutex
+	NodeID  string
+	Rank    int
+	Address string // advertised RPC address; set by Node.Start
+	Peers   []string
+	Client  *RPCClient
+
+	RequestTimeout time.Duration // 0 waits forever
+	TokenTimeout   time.Duration // unseen for this long, the token is recreated
+	// PeerRank returns a peer's node ID and rank, if known; set by NewNode.
+	PeerRank     func(address string) (nodeID string, rank int, ok bool)
+	OnTimeout    func(waited time.Duration)
+	OnRegenerate func(generation int, unseen time.Duration)
+
+	hasToken    bool
+	passing     int // passes under way; a token in flight counts as held for claims
+	stamp       TokenStamp
+	floor       TokenStamp // tokens at or below are refused
+	promised    int        // highest generation granted to a claimer
+	lastSeen    time.Time
+	requesting  bool
+	inCS        bool
+	requestedAt time.Time
+	enteredAt   time.Time
+	granted     chan struct{}   // closed on entry
+	skipped     map[string]bool // successors passed over, logged once until they take the token
+
+	passes        int64
+	regenerations int64
+}
+
+func NewTokenRingManager(nodeID, address string, rank int, peers []string, client *RPCClient) *TokenRingManager {
+	return &TokenRingManager{
+		NodeID:         nodeID,
+		Rank:           rank,
+		Address:        address,
+		Peers:          append([]string(nil), peers...),
+		Client:         client,
+		RequestTimeout: DefaultRARequestTimeout,
+		TokenTimeout:   DefaultTokenTimeout,
+		lastSeen:       time.Now(),
+	}
+}
+
+// UpdatePeers replaces the ring's other members. A token kept for want of
+// anyone to pass it to starts moving again.
+func (t *TokenRingManager) UpdatePeers(peers []string) {
+	t.mu.Lock()
+	t.Peers = append([]string(nil), peers...)
+	idle := t.hasToken && !t.inCS
+	t.mu.Unlock()
+	if idle {
+		go t.passAfter(tokenIdleHold)
+	}
+}
+
+// ReceiveRequest is never asked of a token ring: nothing is requested from
+// peers. It grants, so a stray request cannot hang its sender.
+func (t *TokenRingManager) ReceiveRequest(RAMessage) bool {
+	return true
+}
+
+// ring returns every node in passing order, this one included.
+func (t *TokenRingManager) ring() []tokenMember {
+	t.mu.Lock()
+	peers := append([]string(nil), t.Peers...)
+	self := tokenMember{NodeID: t.NodeID, Rank: t.Rank, Address: t.Address}
+	t.mu.Unlock()
+	members := []tokenMember{self}
+	for _, p := range peers {
+		m := tokenMember{NodeID: p, Rank: math.MinInt, Address: p}
+		if t.PeerRank != nil {
+			if id, rank, ok := t.PeerRank(p); ok {
+				m.NodeID, m.Rank = id, rank
+			}
+		}
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return tokenOutranks(members[j].Rank, members[j].NodeID, members[i].Rank, members[i].NodeID)
+	})
+	return members
+}
+
+// successors returns the other nodes in the order the token tries them.
+func (t *TokenRingManager) successors() []tokenMember {
+	members := t.ring()
+	for i, m := range members {
+		if m.NodeID == t.NodeID {
+			return append(append([]tokenMember(nil), members[i+1:]...), members[:i]...)
+		}
+	}
+	return nil
+}
+
+// tokenOutranks orders nodes as Bully does: higher rank, then greater ID.
+func tokenOutranks(aRank int, aID string, bRank int, bID string) bool {
+	if aRank != bRank {
+		return aRank > bRank
+	}
+	return aID > bID
+}
+
+// RequestCS blocks until this node holds the token; see MutexManager.
+func (t *TokenRingManager) RequestCS() error {
+	t.local.Lock()
+	t.mu.Lock()
+	t.requesting, t.inCS = true, false
+	t.requestedAt, t.enteredAt = time.Now(), time.Time{}
+	t.granted = make(chan struct{})
+	granted, timeout := t.granted, t.RequestTimeout
+	if t.hasToken {
+		t.enterLocked()
+	}
+	t.mu.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-granted:
+		return nil
+	case <-expired:
+		return t.withdraw(timeout)
+	}
+}
+
+// enterLocked lets the waiting request in. Must hold t.mu with the token.
+func (t *TokenRingManager) enterLocked() {
+	t.inCS, t.enteredAt = true, time.Now()
+	close(t.granted)
+}
+
+func (t *TokenRingManager) withdraw(waited time.Duration) error {
+	t.mu.Lock()
+	if t.inCS {
+		// The token came in as the timer fired.
+		t.mu.Unlock()
+		return nil
+	}
+	t.requesting = false
+	t.mu.Unlock()
+	t.local.Unlock()
+	log.Printf("[%s] ⚠️  Gave up on the critical section after %s: the token did not arrive\n", t.NodeID, waited)
+	if t.OnTimeout != nil {
+		t.OnTimeout(waited)
+	}
+	return fmt.Errorf("%w: the token did not arrive within %s", ErrCSTimeout, waited)
+}
+
+// ReleaseCS leaves the CS and passes the token on.
+func (t *TokenRingManager) ReleaseCS() {
+	t.mu.Lock()
+	t.requesting, t.inCS = false, false
+	t.enteredAt = time.Time{}
+	pass := t.hasToken
+	if pass {
+		t.hasToken = false
+		t.passing++
+	}
+	t.mu.Unlock()
+	t.local.Unlock()
+	if pass {
+		go t.passOn()
+	}
+}
+
+// ReceiveToken takes a token passed by a peer; false refuses a stale one.
+func (t *TokenRingManager) ReceiveToken(args TokenArgs) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hasToken {
+		log.Printf("[%s] 🎟️  Refusing token %s from %s: holding %s\n", t.NodeID, args.Stamp, args.From, t.stamp)
+		return false
+	}
+	if !args.Stamp.after(t.floor) {
+		log.Printf("[%s] 🎟️  Refusing stale token %s from %s\n", t.NodeID, args.Stamp, args.From)
+		return false
+	}
+	t.takeLocked(args.Stamp)
+	return true
+}
+
+// takeLocked makes this node the holder of the token stamped s. Must hold t.mu.
+func (t *TokenRingManager) takeLocked(s TokenStamp) {
+	t.hasToken, t.stamp, t.floor, t.lastSeen = true, s, s, time.Now()
+	if t.requesting && !t.inCS {
+		t.enterLocked()
+		return
+	}
+	go t.passAfter(tokenIdleHold)
+}
+
+// passAfter passes the token on after d, unless it is in use by then.
+func (t *TokenRingManager) passAfter(d time.Duration) {
+	time.Sleep(d)
+	t.mu.Lock()
+	if !t.hasToken || t.inCS {
+		t.mu.Unlock()
+		return
+	}
+	t.hasToken = false
+	t.passing++
+	t.mu.Unlock()
+	t.passOn()
+}
+
+// passOn hands the token to the first successor that takes it. The caller
+// has given the token up and counted the pass in passing.
+func (t *TokenRingManager) passOn() {
+	t.mu.Lock()
+	stamp := t.stamp
+	t.mu.Unlock()
+	for _, m := range t.successors() {
+		stamp.Hop++
+		args := TokenArgs{Stamp: stamp, From: t.NodeID, TargetAddress: m.Address}
+		var accepted bool
+		ctx, cancel := context.WithTimeout(context.Background(), tokenPassTimeout)
+		err := t.Client.CallContext(ctx, m.Address, "NodeRPC.PassToken", args, &accepted)
+		cancel()
+		landed := err == nil && accepted
+		if err != nil {
+			if !t.fence(m, args, err) {
+				continue
+			}
+			landed = true // it arrived before the call broke
+		}
+		t.mu.Lock()
+		t.passing--
+		t.lastSeen = time.Now()
+		if landed {
+			t.passes++
+			delete(t.skipped, m.Address)
+		}
+		t.mu.Unlock()
+		if !landed {
+			log.Printf("[%s] 🎟️  %s refused token %s: it has seen a newer one; dropping ours\n", t.NodeID, m.NodeID, stamp)
+		}
+		return
+	}
+
+	// Nobody could take it; keep it and try again later.
+	t.mu.Lock()
+	t.passing--
+	// Peers fenced on the way refuse the hops tried, so carry on from the last.
+	t.hasToken, t.stamp, t.lastSeen = true, stamp, time.Now()
+	if t.requesting && !t.inCS {
+		t.enterLocked()
+		t.mu.Unlock()
+		return
+	}
+	alone := len(t.Peers) == 0
+	t.mu.Unlock()
+	if !alone {
+		go t.passAfter(tokenRetryInterval)
+	}
+}
+
+// fence finds out, after a failed pass, whether m got the token anyway;
+// if not, m refuses it from now on. A peer that cannot be reached is taken
+// for down.
+func (t *TokenRingManager) fence(m tokenMember, args TokenArgs, passErr error) bool {
+	var arrived bool
+	ctx, cancel := context.WithTimeout(context.Background(), raProbeTimeout)
+	err := t.Client.CallContext(ctx, m.Address, "NodeRPC.FenceToken", args, &arrived)
+	cancel()
+	var se rpc.ServerError
+	switch {
+	case errors.As(err, &se):
+		log.Printf("[%s] 🎟️  %s cannot take the token (%v); trying the next node\n", t.NodeID, m.NodeID, err)
+		return false
+	case err != nil:
+		t.mu.Lock()
+		logged := t.skipped[m.Address]
+		if t.skipped == nil {
+			t.skipped = map[string]bool{}
+		}
+		t.skipped[m.Address] = true
+		t.mu.Unlock()
+		if !logged {
+			log.Printf("[%s] 🎟️  Cannot pass the token to %s (%v); skipping it until it answers\n", t.NodeID, m.NodeID, passErr)
+		}
+		return false
+	}
+	return arrived
+}
+
+// Fence answers a sender whose pass failed: whether the token stamped
+// args.Stamp arrived. From now on it is refused if it did not.
+func (t *TokenRingManager) Fence(args TokenArgs) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !args.Stamp.after(t.floor) {
+		return true
+	}
+	t.floor = args.Stamp
+	return false
+}
+
+// watch recreates the token when it has not been seen for long enough;
+// see the file comment. It runs for the life of the node.
+func (t *TokenRingManager) watch() {
+	ticker := time.NewTicker(tokenWatchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		t.mu.Lock()
+		holding := t.hasToken || t.passing > 0
+		unseen := time.Since(t.lastSeen)
+		alone := len(t.Peers) == 0
+		t.mu.Unlock()
+		if holding {
+			continue
+		}
+		if alone {
+			t.regenerate(unseen)
+			continue
+		}
+		wait := t.TokenTimeout
+		for _, m := range t.ring() {
+			if tokenOutranks(m.Rank, m.NodeID, t.Rank, t.NodeID) {
+				wait += t.TokenTimeout
+			}
+		}
+		if unseen >= wait {
+			t.regenerate(unseen)
+		}
+	}
+}
+
+// regenerate claims the next generation from every peer and creates the
+// token if a majority grants it.
+func (t *TokenRingManager) regenerate(unseen time.Duration) {
+	t.mu.Lock()
+	gen := max(t.floor.Generation, t.promised) + 1
+	t.promised = gen
+	peers := append([]string(nil), t.Peers...)
+	t.mu.Unlock()
+
+	claim := TokenClaimArgs{Generation: gen, NodeID: t.NodeID, Rank: t.Rank}
+	replies := make(chan TokenClaimReply, len(peers))
+	ctx, cancel := context.WithTimeout(context.Background(), raProbeTimeout)
+	defer cancel()
+	fanOut(peers, func(p string) {
+		var reply TokenClaimReply
+		if err := t.Client.CallContext(ctx, p, "NodeRPC.ClaimToken", claim, &reply); err != nil {
+			replies <- TokenClaimReply{} // reply may still be written by the abandoned call
+			return
+		}
+		replies <- reply
+	})
+	grants := 1
+	for range peers {
+		r := <-replies
+		switch {
+		case r.Holding, r.Outranks:
+			t.mu.Lock()
+			t.lastSeen = time.Now() // the token, or a better claimer, is alive
+			t.mu.Unlock()
+			return
+		case r.Granted:
+			grants++
+		}
+	}
+	if quorum := quorumFor(len(peers)); grants < quorum {
+		log.Printf("[%s] 🎟️  Not recreating the token: %d of %d nodes granted generation %d (quorum %d)\n",
+			t.NodeID, grants, len(peers)+1, gen, quorum)
+		t.mu.Lock()
+		t.lastSeen = time.Now()
+		t.mu.Unlock()
+		return
+	}
+
+	t.mu.Lock()
+	if t.hasToken || t.passing > 0 || t.promised != gen || t.floor.Generation >= gen {
+		t.mu.Unlock()
+		return // a token or a newer claim arrived meanwhile
+	}
+	// Generation 1 is the first token, not a recreated one.
+	if gen > 1 {
+		t.regenerations++
+		log.Printf("[%s] 🎟️  Recreating the token as generation %d; it was last seen %s ago\n",
+			t.NodeID, gen, unseen.Round(time.Millisecond))
+	} else {
+		log.Printf("[%s] 🎟️  Created the token\n", t.NodeID)
+	}
+	t.takeLocked(TokenStamp{Generation: gen})
+	t.mu.Unlock()
+	if t.OnRegenerate != nil && gen > 1 {
+		t.OnRegenerate(gen, unseen)
+	}
+}
+
+// AnswerClaim is a peer's side of a claim to recreate the token.
+func (t *TokenRingManager) AnswerClaim(c TokenClaimArgs) TokenClaimReply {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := TokenClaimReply{Seen: t.floor}
+	switch {
+	case t.hasToken || t.passing > 0:
+		r.Holding = true
+	case tokenOutranks(t.Rank, t.NodeID, c.Rank, c.NodeID):
+		r.Outranks = true
+	case c.Generation > t.promised && c.Generation > t.floor.Generation:
+		t.promised = c.Generation
+		if fence := (TokenStamp{Generation: c.Generation - 1, Hop: math.MaxInt}); fence.after(t.floor) {
+			t.floor = fence
+		}
+		t.lastSeen = time.Now() // give the claimer time to send it round
+		r.Granted = true
+	}
+	return r
+}
+
+// Status returns a copy of the current state.
+func (t *TokenRingManager) Status() TokenRingStatus {
+	ring := t.ring()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := TokenRingStatus{
+		HasToken: t.hasToken, Stamp: t.stamp.String(),
+		Requesting: t.requesting, InCriticalSection: t.inCS,
+		UnseenMs: time.Since(t.lastSeen).Milliseconds(),
+		Passes:   t.passes, Regenerations: t.regenerations,
+	}
+	if t.hasToken {
+		s.UnseenMs = 0
+	}
+	switch {
+	case t.inCS:
+		s.HeldMs = time.Since(t.enteredAt).Milliseconds()
+	case t.requesting:
+		s.WaitingMs = time.Since(t.requestedAt).Milliseconds()
+	}
+	for _, m := range ring {
+		s.Ring = append(s.Ring, m.NodeID)
+	}
+	return s
+}
+
+// tokenRing returns the token ring, or reports a token message from a peer
+// that runs it to a node that does not.
+func (n *Node) tokenRing(from string) (*TokenRingManager, error) {
+	if t, ok := n.Mutex.(*TokenRingManager); ok {
+		return t, nil
+	}
+	n.noteMutexMismatch(from, MutexToken)
+	return nil, fmt.Errorf("%s runs --mutex=%s", n.ID, n.mutexKind)
+}
+
+// noteTokenTimeout is the token ring's OnTimeout hook.
+func (n *Node) noteTokenTimeout(waited time.Duration) {
+	n.Metrics.Inc("ra_request_timeouts_total")
+	n.Alerts.Notify("ra_request_timeout", SeverityWarning,
+		fmt.Sprintf("Gave up on the critical section after %s: the token did not arrive", waited))
+}
+
+// noteTokenRegenerated is the token ring's OnRegenerate hook.
+func (n *Node) noteTokenRegenerated(generation int, unseen time.Duration) {
+	n.Metrics.Inc("token_regenerations_total")
+	n.Alerts.Notify("token_regenerated", SeverityWarning,
+		fmt.Sprintf("%s recreated the mutual exclusion token as generation %d; it was last seen %s ago",
+			n.ID, generation, unseen.Round(time.Millisecond)))
+}
+
+// PassToken hands this node the token.
+func (rp *NodeRPC) PassToken(args TokenArgs, reply *bool) error {
+	t, err := rp.node.tokenRing(args.From)
+	if err != nil {
+		return err
+	}
+	*reply = t.ReceiveToken(args)
+	return nil
+}
+
+// FenceToken asks whether a token whose pass failed arrived here.
+func (rp *NodeRPC) FenceToken(args TokenArgs, reply *bool) error {
+	t, err := rp.node.tokenRing(args.From)
+	if err != nil {
+		return err
+	}
+	*reply = t.Fence(args)
+	return nil
+}
+
+// ClaimToken asks for a grant to recreate a lost token.
+func (rp *NodeRPC) ClaimToken(args TokenClaimArgs, reply *TokenClaimReply) error {
+	t, err := rp.node.tokenRing(args.NodeID)
+	if err != nil {
+		return err
+	}
+	*reply = t.AnswerClaim(args)
+	return nil
+}
@@ -0,0 +1,124 @@
+package node
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenCluster is a test cluster running --mutex=token, each node knowing
+// the others' ranks. Nothing watches for a lost token; tests create it.
+func tokenCluster(t *testing.T, ids ...string) []*testNode {
+	t.Helper()
+	nodes := testCluster(t, ids...)
+	for _, tn := range nodes {
+		for _, o := range nodes {
+			if o != tn {
+				tn.recordPeerVersion(o.Address, &VersionInfo{NodeID: o.ID, Rank: o.Rank})
+			}
+		}
+		tn.SetMutex(MutexToken)
+		tn.Mutex.(*TokenRingManager).RequestTimeout = 3 * time.Second
+	}
+	// With nobody to pass to, the token stops where it is.
+	t.Cleanup(func() {
+		for _, tn := range nodes {
+			tn.Mutex.UpdatePeers(nil)
+		}
+	})
+	return nodes
+}
+
+func ringOf(tn *testNode) *TokenRingManager { return tn.Mutex.(*TokenRingManager) }
+
+func TestTokenRingCirculates(t *testing.T) {
+	nodes := tokenCluster(t, "A", "B", "C")
+	a, b, c := ringOf(nodes[0]), ringOf(nodes[1]), ringOf(nodes[2])
+	if got := a.Status().Ring; len(got) != 3 || got[0] != "A" || got[1] != "B" || got[2] != "C" {
+		t.Fatalf("ring = %v, want A B C", got)
+	}
+
+	c.regenerate(0)
+	if s := c.Status(); !s.HasToken && s.Passes == 0 {
+		t.Fatalf("C after creating the token: %+v", s)
+	}
+	// Idle, it goes round: C passes to A, A to B, B back to C.
+	for _, r := range []*TokenRingManager{c, a, b} {
+		waitFor(t, r.NodeID+" to pass the token on", func() bool { return r.Status().Passes >= 2 })
+	}
+	// A claim while it circulates is refused by whoever holds it.
+	a.regenerate(time.Minute)
+	for _, r := range []*TokenRingManager{a, b, c} {
+		if s := r.Status(); s.Regenerations != 0 || s.Stamp[0] != '1' {
+			t.Errorf("%s recreated a live token: %+v", r.NodeID, s)
+		}
+	}
+
+	// Nodes that do not hold the token wait for it, one at a time.
+	var inside, overlaps atomic.Int32
+	var wg sync.WaitGroup
+	for _, r := range []*TokenRingManager{a, b, c} {
+		wg.Add(1)
+		go func(r *TokenRingManager) {
+			defer wg.Done()
+			for i := 0; i < 3; i++ {
+				if err := r.RequestCS(); err != nil {
+					t.Error(err)
+					return
+				}
+				if s := r.Status(); !s.HasToken || !s.InCriticalSection {
+					t.Errorf("%s inside without the token: %+v", r.NodeID, s)
+				}
+				if inside.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				time.Sleep(2 * time.Millisecond)
+				inside.Add(-1)
+				r.ReleaseCS()
+			}
+		}(r)
+	}
+	wg.Wait()
+	if overlaps.Load() != 0 {
+		t.Errorf("%d entries overlapped another node's", overlaps.Load())
+	}
+}
+
+func TestTokenRingRecreatesTokenOfCrashedHolder(t *testing.T) {
+	nodes := tokenCluster(t, "A", "B", "C")
+	a, b, c := ringOf(nodes[0]), ringOf(nodes[1]), ringOf(nodes[2])
+	c.regenerate(0)
+
+	// C crashes inside the CS, holding the token.
+	if err := c.RequestCS(); err != nil {
+		t.Fatal(err)
+	}
+	held := c.Status().Stamp
+	nodes[2].kill()
+	a.RequestTimeout = 300 * time.Millisecond
+	if err := a.RequestCS(); err == nil {
+		t.Fatal("A entered while C holds the token")
+	}
+
+	// B, the highest live node, recreates it with A's grant.
+	b.regenerate(time.Minute)
+	if s := b.Status(); s.Regenerations != 1 || s.Stamp[0] != '2' {
+		t.Fatalf("B after the claim: %+v", s)
+	}
+	a.RequestTimeout = 3 * time.Second
+	if err := a.RequestCS(); err != nil {
+		t.Fatalf("A never got the new token: %v", err)
+	}
+	a.ReleaseCS()
+
+	// C's old token is refused if it ever turns up.
+	for _, r := range []*TokenRingManager{a, b} {
+		if r.ReceiveToken(TokenArgs{Stamp: TokenStamp{Generation: 1, Hop: 1 << 20}, From: "C"}) {
+			t.Errorf("%s took C's token %s after generation 2 was made", r.NodeID, held)
+		}
+	}
+	if got := nodes[1].Metrics.Counter("token_regenerations_total"); got != 1 {
+		t.Errorf("token_regenerations_total = %v, want 1", got)
+	}
+}
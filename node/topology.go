@@ -0,0 +1,199 @@
+package node
+
+// topology.go — GET /topology: a cluster-wide membership and health snapshot
+// that any node can serve, for dashboards that poll one URL per cluster.
+//
+// The serving node asks every peer for its NodeStatus in parallel with a short
+// timeout. Unreachable peers appear with an error instead of failing the whole
+// response. The result is cached briefly, so many monitors polling the same
+// node cost one fan-out rather than one each.
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	topologyCacheTTL    = 2 * time.Second
+	topologyPeerTimeout = 1500 * time.Millisecond
+)
+
+// NodeStatus is one node's own view of itself and the cluster.
+type NodeStatus struct {
+	NodeID       string         `json:"nodeId"`
+	Address      string         `json:"address"`
+	Rank         int            `json:"rank"`
+	Role         string         `json:"role"` // leader or follower
+	Phase        NodePhase      `json:"phase"`
+	Term         int            `json:"term"`
+	Coordinator  string         `json:"coordinator"` // who this node believes leads; "" during an election
+	StateVersion uint64         `json:"stateVersion"`
+	Lamport      int            `json:"lamport"`
+	Peers        int            `json:"peers"`
+	Version      VersionInfo    `json:"version"`
+	StateDigest  string         `json:"stateDigest"` // equal on nodes holding the same auction state
+	Rebuild      *RebuildStatus `json:"rebuild,omitempty"`
+}
+
+// TopologyMember is one row of GET /topology.
+type TopologyMember struct {
+	Address   string      `json:"address"`
+	Reachable bool        `json:"reachable"`
+	LatencyMs int64       `json:"latencyMs"`
+	Error     string      `json:"error,omitempty"`
+	Status    *NodeStatus `json:"status,omitempty"`
+}
+
+// Topology is the body of GET /topology.
+type Topology struct {
+	GeneratedAtUnix int64            `json:"generatedAtUnix"`
+	ServedBy        string           `json:"servedBy"`
+	Leader          string           `json:"leader"` // "" when no reachable node knows of one
+	Term            int              `json:"term"`
+	LeaderAgreed    bool             `json:"leaderAgreed"` // every reachable node names the same leader
+	ClusterSize     int              `json:"clusterSize"`
+	Reachable       int              `json:"reachable"`
+	Quorum          int              `json:"quorum"`
+	HasQuorum       bool             `json:"hasQuorum"`
+	Members         []TopologyMember `json:"members"`
+	Cached          bool             `json:"cached"`
+}
+
+type topologyCache struct {
+	mu    sync.Mutex // held across a fan-out so concurrent requests share it
+	at    time.Time
+	value Topology
+}
+
+func (n *Node) nodeStatus() NodeStatus {
+	n.ElectionMutex.Lock()
+	coordinator, term := n.Coordinator, n.Term
+	n.ElectionMutex.Unlock()
+	role := "follower"
+	if coordinator == n.ID {
+		role = "leader"
+	}
+	return NodeStatus{
+		NodeID:       n.ID,
+		Address:      n.Address,
+		Rank:         n.Rank,
+		Role:         role,
+		Phase:        n.Phase(),
+		Term:         term,
+		Coordinator:  coordinator,
+		StateVersion: n.Queue.Version(),
+		Lamport:      n.Clock.Get(),
+		Peers:        len(n.peerList()),
+		Version:      n.versionInfo(),
+		StateDigest:  n.localStateDigest(),
+		Rebuild:      n.rebuildStatus(),
+	}
+}
+
+// GetNodeStatus returns this node's status for a peer's /topology.
+func (rp *NodeRPC) GetNodeStatus(_ EmptyArgs, reply *NodeStatus) error {
+	*reply = rp.node.nodeStatus()
+	return nil
+}
+
+// fetchNodeStatus asks one peer for its status, giving up after
+// topologyPeerTimeout. It bypasses callPeer: monitoring traffic must not
+// create Koo–Toueg checkpoint dependencies.
+func (n *Node) fetchNodeStatus(address string) TopologyMember {
+	member := TopologyMember{Address: address}
+	type result struct {
+		status NodeStatus
+		err    error
+	}
+	done := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		var status NodeStatus
+		err := n.Client.Call(address, "NodeRPC.GetNodeStatus", EmptyArgs{}, &status)
+		done <- result{status, err}
+	}()
+	select {
+	case res := <-done:
+		member.LatencyMs = time.Since(start).Milliseconds()
+		if res.err != nil {
+			member.Error = res.err.Error()
+			return member
+		}
+		member.Reachable = true
+		member.Status = &res.status
+	case <-time.After(topologyPeerTimeout):
+		member.LatencyMs = topologyPeerTimeout.Milliseconds()
+		member.Error = "timed out after " + topologyPeerTimeout.String()
+	}
+	return member
+}
+
+// clusterTopology returns the cached topology, or gathers a fresh one.
+func (n *Node) clusterTopology() Topology {
+	c := &n.topology
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.at.IsZero() && time.Since(c.at) < topologyCacheTTL {
+		n.Metrics.Inc("topology_cache_hits_total")
+		cached := c.value
+		cached.Cached = true
+		return cached
+	}
+
+	peers := n.peerList()
+	members := make([]TopologyMember, len(peers)+1)
+	self := n.nodeStatus()
+	members[0] = TopologyMember{Address: n.Address, Reachable: true, Status: &self}
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			members[i+1] = n.fetchNodeStatus(addr)
+		}(i, peer)
+	}
+	wg.Wait()
+	n.Metrics.Inc("topology_fanouts_total")
+
+	t := Topology{
+		GeneratedAtUnix: time.Now().Unix(),
+		ServedBy:        n.ID,
+		ClusterSize:     len(members),
+		Quorum:          quorumFor(len(peers)),
+		Members:         members,
+		LeaderAgreed:    true,
+	}
+	// The leader is the one named in the newest term any reachable node knows.
+	for _, m := range members {
+		if !m.Reachable {
+			n.Metrics.Inc(metricName("topology_peer_errors_total", "peer", m.Address))
+			continue
+		}
+		t.Reachable++
+		if s := m.Status; s.Coordinator != "" && (t.Leader == "" || s.Term > t.Term) {
+			t.Leader, t.Term = s.Coordinator, s.Term
+		}
+	}
+	for _, m := range members {
+		if m.Reachable && m.Status.Coordinator != t.Leader {
+			t.LeaderAgreed = false
+		}
+	}
+	t.HasQuorum = t.Reachable >= t.Quorum
+
+	c.value, c.at = t, time.Now()
+	return t
+}
+
+// handleTopologyRequest serves GET /topology.
+func (n *Node) handleTopologyRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var t Topology
+	if n.holdForClient(r, "topology", func() { t = n.clusterTopology() }) {
+		writeJSON(w, t)
+	}
+}
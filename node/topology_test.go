@@ -0,0 +1,98 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// topologyOf serves GET /topology from n, past its cache.
+func topologyOf(t *testing.T, n *Node) Topology {
+	t.Helper()
+	n.topology.mu.Lock()
+	n.topology.at = time.Time{}
+	n.topology.mu.Unlock()
+	rec := httptest.NewRecorder()
+	n.handleTopologyRequest(rec, httptest.NewRequest(http.MethodGet, "/topology", nil))
+	var topo Topology
+	if err := json.Unmarshal(rec.Body.Bytes(), &topo); err != nil {
+		t.Fatalf("/topology: %d %s: %v", rec.Code, rec.Body, err)
+	}
+	return topo
+}
+
+func TestTopology(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b := nodes[0], nodes[1]
+	for _, tn := range nodes {
+		tn.ElectionMutex.Lock()
+		tn.Term = 4
+		tn.ElectionMutex.Unlock()
+		setLeader(tn.Node, a.ID, a.Address)
+	}
+	rolesOf := func(topo Topology) map[string]string {
+		roles := map[string]string{}
+		for _, m := range topo.Members {
+			if m.Status != nil {
+				roles[m.Status.NodeID] = m.Status.Role
+			}
+		}
+		return roles
+	}
+
+	// Served by a follower, every member is listed with its own view.
+	full := topologyOf(t, b.Node)
+	if full.ServedBy != "B" || full.Leader != "A" || full.Term != 4 || !full.LeaderAgreed {
+		t.Errorf("leader %q term %d agreed=%v served by %s, want A in term 4, agreed, by B", full.Leader, full.Term, full.LeaderAgreed, full.ServedBy)
+	}
+	if full.ClusterSize != 3 || full.Reachable != 3 || full.Quorum != 2 || !full.HasQuorum {
+		t.Errorf("size %d, reachable %d, quorum %d/%v; want 3, 3, 2/true", full.ClusterSize, full.Reachable, full.Quorum, full.HasQuorum)
+	}
+	if roles := rolesOf(full); roles["A"] != "leader" || roles["B"] != "follower" || roles["C"] != "follower" {
+		t.Errorf("roles = %v", roles)
+	}
+	for _, m := range full.Members {
+		if m.Status != nil && m.Status.Version.Protocol == 0 {
+			t.Errorf("%s reports no protocol version", m.Address)
+		}
+	}
+
+	// A second poll within the TTL is served from the cache.
+	rec := httptest.NewRecorder()
+	b.handleTopologyRequest(rec, httptest.NewRequest(http.MethodGet, "/topology", nil))
+	var cached Topology
+	if err := json.Unmarshal(rec.Body.Bytes(), &cached); err != nil || !cached.Cached {
+		t.Errorf("second poll cached=%v (%v), want true", cached.Cached, err)
+	}
+	if got := b.Metrics.Counter("topology_fanouts_total"); got != 1 {
+		t.Errorf("topology_fanouts_total = %v, want 1", got)
+	}
+
+	// A dead peer is reported with an error; the rest is still served.
+	nodes[2].kill()
+	partial := topologyOf(t, b.Node)
+	if partial.Reachable != 2 || !partial.HasQuorum || partial.Leader != "A" {
+		t.Errorf("with C down: reachable %d, quorum %v, leader %q; want 2, true, A", partial.Reachable, partial.HasQuorum, partial.Leader)
+	}
+	for _, m := range partial.Members {
+		if m.Address == nodes[2].Address && (m.Reachable || m.Error == "" || m.Status != nil) {
+			t.Errorf("dead C reported as %+v", m)
+		}
+	}
+	if got := b.Metrics.Counter(metricName("topology_peer_errors_total", "peer", nodes[2].Address)); got != 1 {
+		t.Errorf("topology_peer_errors_total{peer=C} = %v, want 1", got)
+	}
+
+	// Mid-election with the leader gone: no leader, and no quorum.
+	a.kill()
+	setLeader(b.Node, "", "")
+	leaderless := topologyOf(t, b.Node)
+	if leaderless.Leader != "" || leaderless.Reachable != 1 || leaderless.HasQuorum {
+		t.Errorf("leaderless: leader %q, reachable %d, quorum %v; want none, 1, false", leaderless.Leader, leaderless.Reachable, leaderless.HasQuorum)
+	}
+	if leaderless.ClusterSize != 3 || len(leaderless.Members) != 3 {
+		t.Errorf("leaderless: size %d with %d members, want 3", leaderless.ClusterSize, len(leaderless.Members))
+	}
+}
@@ -0,0 +1,267 @@
+package node
+
+// transfer.go — Planned leadership handover: POST /admin/transfer-leader.
+//
+// Stopping the coordinator for maintenance normally costs an outage: the
+// followers need a few seconds to miss its heartbeats and elect a
+// successor. A transfer hands over without that gap. The coordinator
+//
+//  1. picks the successor: the given node, or the highest-ranked ready
+//     peer. A successor that a ready peer outranks is refused, because that
+//     peer would take leadership straight back;
+//  2. takes the Ricart–Agrawala critical section, so no 2PC round is in
+//     flight and new bids queue behind the handover;
+//  3. drains, so that it neither campaigns nor takes leadership back when
+//     the successor's first heartbeat arrives;
+//  4. sends the successor its latest snapshot with the next term
+//     (AcceptLeadership). The successor applies it, claims leadership for
+//     that term, starts heartbeats and runs OnBecomeCoordinator;
+//  5. adopts the new term and leader itself, which stops its heartbeats and
+//     item timers, and announces the successor to the other peers.
+//
+// The old leader stays drained until an operator resumes it (POST
+// /admin/drain action=resume). After a resume
+// it is an ordinary node again and, being higher ranked, may win back
+// leadership the usual way. Bids that queued behind the handover find the
+// node no longer leads and get no_leader, which the node that took them
+// retries against the new coordinator. If the successor refuses or cannot be
+// reached, the old leader resumes and carries on.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const transferTimeout = 5 * time.Second
+
+type TransferLeadershipArgs struct {
+	To string // successor's node ID; empty picks the highest-ranked ready peer
+}
+
+type TransferLeadershipReply struct {
+	Accepted  bool
+	Message   string
+	Successor string
+	Term      int
+}
+
+type AcceptLeadershipArgs struct {
+	From     string
+	Term     int // the successor's first term
+	Snapshot QueueSnapshot
+}
+
+type AcceptLeadershipReply struct {
+	Accepted bool
+	Message  string
+	Rank     int
+	Address  string
+}
+
+// pickSuccessor resolves to (or the best candidate if empty) to a ready
+// peer's status, refusing one that another ready peer outranks.
+func (n *Node) pickSuccessor(to string) (NodeStatus, error) {
+	peers := n.peerList()
+	members := make(chan TopologyMember, len(peers))
+	fanOut(peers, func(p string) { members <- n.fetchNodeStatus(p) })
+	var ready []NodeStatus
+	found := false
+	for range peers {
+		m := <-members
+		if m.Status == nil {
+			continue
+		}
+		m.Status.Address = m.Address // the address we reach it on
+		if m.Status.NodeID == to {
+			found = true
+			if m.Status.Phase != PhaseReady {
+				return NodeStatus{}, fmt.Errorf("%s is %s, not ready", to, m.Status.Phase)
+			}
+		}
+		if m.Status.Phase == PhaseReady {
+			ready = append(ready, *m.Status)
+		}
+	}
+	if to != "" && !found {
+		return NodeStatus{}, fmt.Errorf("%s is not a reachable peer", to)
+	}
+	var best *NodeStatus
+	for i := range ready {
+		s := &ready[i]
+		if best == nil || s.Rank > best.Rank || (s.Rank == best.Rank && s.NodeID > best.NodeID) {
+			best = s
+		}
+	}
+	if best == nil {
+		return NodeStatus{}, errors.New("no ready peer to hand over to")
+	}
+	if to != "" && best.NodeID != to {
+		return NodeStatus{}, fmt.Errorf("%s outranks %s and would take leadership back; transfer to %s instead", best.NodeID, to, best.NodeID)
+	}
+	return *best, nil
+}
+
+// transferLeadership hands leadership to a peer; see the file comment.
+func (n *Node) transferLeadership(to string) (TransferLeadershipReply, error) {
+	if _, isLocal := n.getCoordinatorAddress(); !isLocal {
+		return TransferLeadershipReply{}, errors.New("this node is not the coordinator")
+	}
+	if !n.transferring.CompareAndSwap(false, true) {
+		return TransferLeadershipReply{}, errors.New("a transfer is already running")
+	}
+	defer n.transferring.Store(false)
+
+	successor, err := n.pickSuccessor(to)
+	if err != nil {
+		n.Metrics.Inc(metricName("leadership_transfers_total", "result", "refused"))
+		return TransferLeadershipReply{}, err
+	}
+
+	release, err := n.enterCS()
+	if err != nil {
+		n.Metrics.Inc(metricName("leadership_transfers_total", "result", "failed"))
+		return TransferLeadershipReply{}, err
+	}
+	defer release()
+
+	n.ElectionMutex.Lock()
+	if n.Coordinator != n.ID {
+		n.ElectionMutex.Unlock()
+		return TransferLeadershipReply{}, errors.New("lost leadership while preparing the transfer")
+	}
+	term := n.Term + 1
+	n.ElectionMutex.Unlock()
+
+	log.Printf("[%s] 🤝 Handing leadership to %s (term %d)\n", n.ID, successor.NodeID, term)
+	n.Drain("handing leadership to " + successor.NodeID)
+	ctx, cancel := context.WithTimeout(context.Background(), transferTimeout)
+	defer cancel()
+	var accept AcceptLeadershipReply
+	args := AcceptLeadershipArgs{From: n.ID, Term: term, Snapshot: n.buildQueueSnapshot()}
+	if err := n.callPeerContext(ctx, successor.Address, "NodeRPC.AcceptLeadership", args, &accept); err != nil || !accept.Accepted {
+		if err == nil {
+			err = errors.New(accept.Message)
+		}
+		n.Resume()
+		n.Metrics.Inc(metricName("leadership_transfers_total", "result", "failed"))
+		log.Printf("[%s] 🤝 Transfer to %s failed: %v; still leading\n", n.ID, successor.NodeID, err)
+		return TransferLeadershipReply{}, fmt.Errorf("%s did not take over: %v", successor.NodeID, err)
+	}
+
+	announce := BullyMessage{NodeID: successor.NodeID, Rank: accept.Rank, Term: term, Address: accept.Address}
+	n.ElectionMutex.Lock()
+	if term > n.Term {
+		n.Term = term
+	}
+	n.setCoordinatorLocked(successor.NodeID)
+	n.noteBullyAddressLocked(announce)
+	n.renewLeaderLease()
+	n.ElectionMutex.Unlock()
+	n.Metrics.Set("election_term", float64(term))
+
+	for _, peer := range n.peerList() {
+		if peer == successor.Address {
+			continue
+		}
+		p := peer
+		n.async.send(p, "HandleCoordinator", func() error {
+			var ok bool
+			return n.callPeer(p, "NodeRPC.HandleCoordinator", announce, &ok)
+		})
+	}
+	n.Metrics.Inc(metricName("leadership_transfers_total", "result", "ok"))
+	n.noteElectionEvent(LeadershipHandedOver, term, successor.NodeID, "planned transfer")
+	msg := fmt.Sprintf("Leadership transferred from %s to %s (term %d); %s is drained", n.ID, successor.NodeID, term, n.ID)
+	log.Printf("[%s] 🤝 %s\n", n.ID, msg)
+	n.Alerts.Notify("leadership_transferred", SeverityInfo, msg)
+	return TransferLeadershipReply{Accepted: true, Message: msg, Successor: successor.NodeID, Term: term}, nil
+}
+
+// TransferLeadership runs a transfer on the coordinator, for a node that
+// received POST /admin/transfer-leader.
+func (rp *NodeRPC) TransferLeadership(args TransferLeadershipArgs, reply *TransferLeadershipReply) error {
+	r, err := rp.node.transferLeadership(args.To)
+	if err != nil {
+		r.Message = err.Error()
+	}
+	*reply = r
+	return nil
+}
+
+// AcceptLeadership makes this node coordinator for args.Term with the old
+// leader's state.
+func (rp *NodeRPC) AcceptLeadership(args AcceptLeadershipArgs, reply *AcceptLeadershipReply) error {
+	n := rp.node
+	if p := n.Phase(); p != PhaseReady {
+		reply.Message = fmt.Sprintf("%s is %s", n.ID, p)
+		return nil
+	}
+	source := "leadership transfer from " + args.From
+	if err := n.admitSnapshot(args.Snapshot, source); err != nil {
+		reply.Message = err.Error()
+		return nil
+	}
+	if !n.applyQueueSnapshot(args.Snapshot, source) {
+		reply.Message = "snapshot failed validation"
+		return nil
+	}
+	n.ElectionMutex.Lock()
+	if args.Term <= n.Term {
+		n.ElectionMutex.Unlock()
+		reply.Message = fmt.Sprintf("term %d is not newer than ours (%d)", args.Term, n.Term)
+		return nil
+	}
+	n.Term = args.Term
+	n.setCoordinatorLocked(n.ID)
+	n.ElectionMutex.Unlock()
+	n.Metrics.Set("election_term", float64(args.Term))
+	log.Printf("[%s] 🤝 Took over leadership from %s (term %d)\n", n.ID, args.From, args.Term)
+	n.noteElectionEvent(LeadershipTakenOver, args.Term, args.From, "planned transfer")
+
+	n.startLeading()
+	reply.Accepted, reply.Rank, reply.Address = true, n.Rank, n.advertiseAddress()
+	return nil
+}
+
+// handleTransferLeaderRequest serves POST /admin/transfer-leader[?to=<node ID>],
+// forwarding to the coordinator when this node is not it.
+func (n *Node) handleTransferLeaderRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	args := TransferLeadershipArgs{To: strings.TrimSpace(r.FormValue("to"))}
+	if args.To == n.ID {
+		if _, isLocal := n.getCoordinatorAddress(); isLocal {
+			http.Error(w, n.ID+" already leads", http.StatusConflict)
+			return
+		}
+	}
+	var reply TransferLeadershipReply
+	coordinatorAddress, isLocal := n.getCoordinatorAddress()
+	switch {
+	case isLocal:
+		var err error
+		if reply, err = n.transferLeadership(args.To); err != nil {
+			reply.Message = err.Error()
+		}
+	case coordinatorAddress == "":
+		http.Error(w, "Election in progress, please wait", http.StatusServiceUnavailable)
+		return
+	default:
+		if err := n.callPeer(coordinatorAddress, "NodeRPC.TransferLeadership", args, &reply); err != nil {
+			http.Error(w, "Coordinator unreachable: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	if !reply.Accepted {
+		http.Error(w, reply.Message, http.StatusConflict)
+		return
+	}
+	writeJSON(w, reply)
+}
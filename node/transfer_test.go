@@ -0,0 +1,171 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func postTransfer(n *Node, to string) *httptest.ResponseRecorder {
+	return postForm(n.handleTransferLeaderRequest, "/admin/transfer-leader", url.Values{"to": {to}})
+}
+
+func TestTransferLosesNoResultsOrBids(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	earlier := ItemResult{Item: AuctionItem{ID: "lot0", Name: "Lamp", StartingPrice: 5, DurationSec: 30}, Winner: "Zed", WinnerID: "b0", WinningBid: 9, ClosedAtUnix: 1}
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		tn.Queue.mu.Lock()
+		tn.Queue.Queue = nil
+		tn.Queue.CurrentItem.Name, tn.Queue.CurrentItem.DurationSec = "Vase", 60
+		tn.Queue.Results = []ItemResult{earlier}
+		tn.Queue.mu.Unlock()
+		setLeader(tn.Node, c.ID, c.Address)
+	}
+	leading(t, c.Node)
+	term := c.currentTerm()
+
+	// Ten bidders bid through A while A asks C to hand over to B.
+	bidVia := func(n *Node, bidder string, amount int) BidCode {
+		form := url.Values{"bidder": {bidder}, "amount": {fmt.Sprint(amount)}, "itemId": {"lot1"}}
+		req := httptest.NewRequest(http.MethodPost, "/bid", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: bidderIDCookie, Value: bidder})
+		rec := httptest.NewRecorder()
+		n.handleBidRequest(rec, req)
+		return BidCode(rec.Header().Get("X-Bid-Outcome"))
+	}
+	var wg sync.WaitGroup
+	codes := make([]BidCode, 10)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = bidVia(a.Node, fmt.Sprintf("b%d", i+1), 20+10*i)
+		}()
+	}
+	rec := postTransfer(a.Node, "B")
+	wg.Wait()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/transfer-leader via A: %d %s", rec.Code, rec.Body)
+	}
+	leading(t, b.Node) // steps B down at cleanup
+
+	// Every bid got a real answer, and the highest one won.
+	committed := 0
+	for i, code := range codes {
+		switch code {
+		case BidCommitted:
+			committed++
+		case BidOutbid:
+		default:
+			t.Errorf("b%d's bid = %q during the transfer", i+1, code)
+		}
+	}
+	if codes[9] != BidCommitted || committed == 0 {
+		t.Errorf("outcomes %v; want the $110 bid committed", codes)
+	}
+
+	// B leads the next term everywhere; C is drained.
+	for _, tn := range nodes {
+		waitFor(t, tn.ID+" to follow B", func() bool {
+			tn.ElectionMutex.Lock()
+			defer tn.ElectionMutex.Unlock()
+			return tn.Coordinator == b.ID && tn.Term == term+1
+		})
+	}
+	if c.Phase() != PhaseDraining {
+		t.Errorf("C is %s after handing over, want draining", c.Phase())
+	}
+	if got := c.Metrics.Counter(metricName("leadership_transfers_total", "result", "ok")); got != 1 {
+		t.Errorf("leadership_transfers_total{result=ok} = %v, want 1", got)
+	}
+
+	// The lot closes under B: one result for it, the earlier one kept, and
+	// the same bid book on every node.
+	waitFor(t, "the nodes to agree", func() bool {
+		return a.localStateDigest() == b.localStateDigest() && c.localStateDigest() == b.localStateDigest()
+	})
+	b.Queue.mu.Lock()
+	b.finalizeCurrentItemLocked()
+	b.Queue.mu.Unlock()
+	b.startNextItem()
+	for _, tn := range nodes {
+		waitFor(t, tn.ID+" to record lot1", func() bool { return len(resultsOf(tn.Node)) == 2 })
+		results := resultsOf(tn.Node)
+		if results[0].Item.ID != "lot0" || results[1].Item.ID != "lot1" || results[1].WinnerID != "b10" || results[1].WinningBid != 110 {
+			t.Errorf("%s results = %+v", tn.ID, results)
+		}
+	}
+	// Each bid is in the book once: on A, which took it, and if committed
+	// on every node.
+	for i, code := range codes {
+		bidder := fmt.Sprintf("b%d", i+1)
+		onA := myBidsOn(t, a.Node, bidder, "").Bids
+		if len(onA) != 1 || onA[0].Outcome != code {
+			t.Errorf("%s's bids on A = %v, want one %s", bidder, summary(onA), code)
+			continue
+		}
+		for _, n := range []*Node{b.Node, c.Node} {
+			got := myBidsOn(t, n, bidder, "").Bids
+			if code == BidCommitted && !reflect.DeepEqual(summary(got), summary(onA)) {
+				t.Errorf("%s's bids on %s = %v, A has %v", bidder, n.ID, summary(got), summary(onA))
+			}
+			if code != BidCommitted && len(got) != 0 {
+				t.Errorf("%s's rejected bid is on %s: %v", bidder, n.ID, summary(got))
+			}
+		}
+	}
+}
+
+func TestTransferRefusals(t *testing.T) {
+	nodes := testCluster(t, "A", "B", "C")
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	for _, tn := range nodes {
+		withLotUp(tn.Node)
+		setLeader(tn.Node, c.ID, c.Address)
+	}
+	leading(t, c.Node)
+
+	for _, tc := range []struct{ to, want string }{
+		{"A", "B outranks A"},
+		{"D", "not a reachable peer"},
+		{"C", "already leads"},
+	} {
+		if rec := postTransfer(c.Node, tc.to); rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), tc.want) {
+			t.Errorf("transfer to %s: %d %q, want 409 %q", tc.to, rec.Code, rec.Body, tc.want)
+		}
+	}
+	b.Drain("maintenance")
+	if rec := postTransfer(c.Node, "B"); rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "not ready") {
+		t.Errorf("transfer to a draining B: %d %q", rec.Code, rec.Body)
+	}
+
+	// A successor that refuses leaves C leading and ready.
+	b.Resume()
+	b.markSynced("test")
+	b.ElectionMutex.Lock()
+	b.Term = 7 // C's next term would not be newer
+	b.ElectionMutex.Unlock()
+	if rec := postTransfer(a.Node, "B"); rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "not newer") {
+		t.Errorf("transfer B refuses: %d %q", rec.Code, rec.Body)
+	}
+	if !c.IsReady() || !c.leaderKnown() {
+		t.Errorf("C after a failed transfer: phase %s", c.Phase())
+	}
+	c.ElectionMutex.Lock()
+	leader := c.Coordinator
+	c.ElectionMutex.Unlock()
+	if leader != c.ID {
+		t.Errorf("C follows %s after a failed transfer", leader)
+	}
+	if got := c.Metrics.Counter(metricName("leadership_transfers_total", "result", "failed")); got != 1 {
+		t.Errorf("leadership_transfers_total{result=failed} = %v, want 1", got)
+	}
+}
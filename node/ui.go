@@ -87,6 +87,11 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
     .item-header { border-bottom: 0.5px solid var(--border); padding-bottom: 24px; }
     .item-name { font-size: 2.5rem; font-weight: 700; letter-spacing: -0.04em; color: white; }
     .item-desc { font-size: 1.125rem; color: var(--muted); margin-top: 8px; font-weight: 400; }
+    .item-details { display: none; margin-top: 10px; font-size: 0.85rem; color: var(--muted); }
+    .item-details summary { cursor: pointer; }
+    .item-details dl { display: grid; grid-template-columns: max-content 1fr; gap: 4px 14px; margin-top: 8px; }
+    .item-details dt { font-weight: 600; }
+    .item-details dd { margin: 0; overflow-wrap: anywhere; }
 
     .countdown-wrap { display: flex; flex-direction: column; gap: 12px; }
     .countdown-label { font-size: 0.75rem; font-weight: 600; color: var(--muted); letter-spacing: 0.08em; text-transform: uppercase; }
@@ -109,6 +114,8 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
 
     .bid-form { display: flex; flex-direction: column; gap: 20px; margin-top: 12px; }
     .input-row { display: flex; gap: 12px; }
+    .quick-bids { display: flex; gap: 8px; margin-top: 10px; }
+    .quick-bids .btn { flex: 1; }
     input[type=text], input[type=number] {
       flex: 1; padding: 14px 20px;
       background: rgba(255, 255, 255, 0.05); border: 0.5px solid var(--border);
@@ -116,6 +123,12 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
       font-size: 1rem; font-family: inherit; outline: none;
       transition: var(--transition);
     }
+    textarea {
+      padding: 14px 20px; min-height: 96px; resize: vertical;
+      background: rgba(255, 255, 255, 0.05); border: 0.5px solid var(--border);
+      border-radius: 12px; color: white;
+      font-size: 0.85rem; font-family: ui-monospace, monospace; outline: none;
+    }
     input[type=text]:focus, input[type=number]:focus {
       background: rgba(255, 255, 255, 0.08); border-color: rgba(255, 255, 255, 0.3);
     }
@@ -129,8 +142,20 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
     .btn:active { transform: scale(0.98); }
     .btn:disabled { opacity: 0.3; cursor: not-allowed; transform: none; }
     #feedback { font-size: 0.9rem; font-weight: 500; min-height: 20px; text-align: center; }
+    #inviteNote { display: none; font-size: 0.85rem; color: var(--muted); text-align: center; }
+    #graceNote { display: none; font-size: 0.85rem; color: var(--muted); text-align: center; }
+    #budgetNote { display: none; font-size: 0.85rem; color: var(--muted); text-align: center; }
+    .my-bids { margin-top: 12px; font-size: 0.85rem; color: var(--muted); }
+    .my-bids summary { cursor: pointer; }
+    .my-bids ul { list-style: none; margin: 8px 0 0; padding: 0; display: flex; flex-direction: column; gap: 4px; }
+    .my-bids .status { font-weight: 600; margin-right: 6px; }
     .err { color: var(--red); } .ok { color: var(--green); }
 
+    .phase-banner {
+      margin-bottom: 16px; padding: 12px 16px; border-radius: 12px; text-align: center;
+      background: rgba(255, 214, 10, 0.12); border: 0.5px solid rgba(255, 214, 10, 0.4);
+      color: #ffd60a; font-size: 0.9rem; font-weight: 500;
+    }
     .ended-banner {
       text-align: center; padding: 64px;
       background: linear-gradient(135deg, rgba(255, 255, 255, 0.05), transparent), var(--surface);
@@ -157,6 +182,8 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
     }
 
     .queue-list, .results-list { display: flex; flex-direction: column; gap: 16px; }
+    .history-link { display: block; margin-top: 16px; font-size: 0.85rem; color: var(--muted); text-decoration: none; }
+    .history-link:hover { color: var(--text); }
     .item-row { 
       display: flex; justify-content: space-between; align-items: center; 
       padding: 16px; border-radius: 12px; 
@@ -172,7 +199,7 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
     .cp-row { display: flex; justify-content: space-between; align-items: center; padding: 10px 0; }
     .cp-key { font-size: 0.8rem; color: var(--muted); }
     .cp-val { font-size: 0.85rem; font-weight: 500; color: white; }
-    .cp-dot { display: inline-block; width: 6px; height: 6px; border-radius: 50%; margin-right: 8px; background: var(--green); }
+    .cp-dot { display: inline-block; width: 6px; height: 6px; border-radius: 50%%; margin-right: 8px; background: var(--green); }
     .cp-dot.stale { background: var(--yellow); }
     .cp-dot.none { background: var(--border); }
 
@@ -181,6 +208,17 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
     .btn.secondary:hover { background: rgba(255, 255, 255, 0.15); }
     .btn.small { padding: 10px 20px; font-size: 0.9rem; }
     .empty-state { color: var(--muted); font-size: 0.9rem; text-align: center; padding: 24px 0; }
+    .sold-banner { text-align: center; padding: 48px 32px; }
+    .sold-banner .sold-hammer { font-size: 3rem; display: inline-block; transform-origin: 80%% 80%%; animation: hammer 0.6s ease-in 2; }
+    .sold-banner .sold-title { font-size: 2rem; font-weight: 700; color: white; margin-top: 12px; }
+    .sold-banner .sold-meta { font-size: 0.95rem; color: var(--muted); margin-top: 8px; }
+    @keyframes hammer { 0%% { transform: rotate(-35deg); } 70%% { transform: rotate(10deg); } 100%% { transform: rotate(0); } }
+    .alert-list { display: flex; flex-direction: column; gap: 8px; }
+    .inflight summary { cursor: pointer; font-size: 0.85rem; color: var(--muted); }
+    .inflight pre { font-size: 0.75rem; white-space: pre-wrap; margin-top: 8px; color: var(--muted); }
+    .alert-item { font-size: 0.85rem; padding: 10px 14px; border-radius: 10px; border: 0.5px solid var(--yellow); color: var(--yellow); background: rgba(255, 214, 10, 0.06); }
+    .alert-item.critical { border-color: var(--red); color: var(--red); background: rgba(255, 69, 58, 0.08); }
+    .bidder-dot { display: inline-block; width: 10px; height: 10px; border-radius: 50%%; margin-right: 8px; vertical-align: middle; }
 
   </style>
 </head>
@@ -200,6 +238,7 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
       <div class="item-header">
         <div class="item-name" id="itemName">Loading…</div>
         <div class="item-desc" id="itemDesc"></div>
+        <details class="item-details" id="itemDetails"><summary>Details</summary><dl id="itemCustom"></dl></details>
       </div>
       <div class="countdown-wrap">
         <div class="countdown-label">Time Remaining</div>
@@ -220,11 +259,20 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
       </div>
       <div class="bid-form">
         <div class="input-row">
-          <input type="text" id="bidderName" placeholder="Your Name" autocomplete="off">
+          <input type="text" id="bidderName" placeholder="Your Name (optional)" autocomplete="off">
           <input type="number" id="amount" placeholder="Bid Amount ($)" min="1" autocomplete="off">
           <button class="btn" id="bidBtn" onclick="submitBid()">Place Bid</button>
         </div>
+        <div class="quick-bids" id="quickBids"></div>
+        <div id="inviteNote">This lot is invite-only and your bidder ID is not on its list.</div>
+        <div id="graceNote">⏳ Re-opened briefly: this lot's deadline passed while the auction was switching servers.</div>
+        <div id="budgetNote"></div>
         <div id="feedback"></div>
+        <details class="my-bids" id="myBidsBox" ontoggle="fetchMyBids()">
+          <summary>My bids</summary>
+          <ul id="myBidsList"></ul>
+          <button class="btn secondary small" id="myBidsMore" style="display:none; margin-top:8px;" onclick="myBidsLimit += 50; fetchMyBids()">Show more</button>
+        </details>
       </div>
     </div>
 
@@ -238,15 +286,38 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
           <input type="number" id="newItemDuration" placeholder="Duration (sec)" min="10" autocomplete="off">
         </div>
         <button class="btn small" id="addItemBtn" onclick="addItem()">Add to Queue</button>
+        <textarea id="importJson" placeholder='Import a catalogue: paste a JSON array of items, e.g. [{"name": "...", "description": "...", "startingPrice": 100, "durationSec": 60}]'></textarea>
+        <div style="display:flex; gap:8px;">
+          <input type="file" id="importFile" accept=".json,application/json" onchange="loadImportFile(this)" style="display:none">
+          <button class="btn secondary small" onclick="document.getElementById('importFile').click()">Load File…</button>
+          <button class="btn small" id="importBtn" onclick="importItems()">Import All</button>
+        </div>
         <div style="display:flex; gap:8px;">
           <button class="btn secondary small" id="startAuctionBtn" onclick="auctionControl('start')">Start</button>
           <button class="btn secondary small" id="stopAuctionBtn" onclick="auctionControl('stop')">Stop</button>
           <button class="btn secondary small" id="restartAuctionBtn" onclick="auctionControl('restart')">Restart</button>
         </div>
         <div id="adminFeedback" class="admin-feedback"></div>
+        <div id="alertList" class="alert-list"></div>
+        <details class="inflight" id="inflightBox">
+          <summary>In-flight (debug stuck bids)</summary>
+          <pre id="inflightText">Loading…</pre>
+        </details>
+        <details class="inflight" id="incidentBox" ontoggle="fetchIncidents()">
+          <summary>Incident timeline</summary>
+          <pre id="incidentText">Loading…</pre>
+        </details>
       </div>
     </div>
 
+    <div id="phaseBanner" class="phase-banner" style="display:none"></div>
+
+    <div id="soldBanner" class="ended-banner sold-banner" style="display:none">
+      <div class="sold-hammer">🔨</div>
+      <div class="sold-title" id="soldTitle"></div>
+      <div class="sold-meta" id="soldMeta"></div>
+    </div>
+
     <div id="endedBanner" class="ended-banner" style="display:none">
       Auction Complete — All items sold
     </div>
@@ -260,6 +331,7 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
     <div class="panel">
       <div class="panel-title">Sold</div>
       <div class="results-list" id="resultsList"><div class="empty-state">No items sold yet</div></div>
+      <a class="history-link" href="/history">Past events →</a>
     </div>
     <div class="panel">
       <div class="panel-title">Checkpoint</div>
@@ -277,6 +349,8 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
   let totalDuration = 60;
   let deadlineUnix = 0;
   let localTimerInterval = null;
+  let currentItemId = '';
+  let bidAllowed = true;
 
   function fmt2(n){ return String(n).padStart(2,'0'); }
 
@@ -316,66 +390,196 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
     }
   }
 
+  function renderPhaseBanner(phase) {
+    const banner = document.getElementById('phaseBanner');
+    const notes = {
+      starting: 'This node is starting up — bidding is paused.',
+      restoring: 'This node is restoring its checkpoint — bidding is paused.',
+      syncing: 'This node is syncing with the cluster — figures may be stale and bidding is paused.',
+      draining: 'This node is draining for maintenance — bidding is paused; try another node.',
+      stopped: 'This node is shutting down — try another node.'
+    };
+    if (!phase || phase === 'ready') { banner.style.display = 'none'; return; }
+    banner.textContent = notes[phase] || ('Node phase: ' + phase);
+    banner.style.display = 'block';
+  }
+
   async function fetchState() {
     try {
-      const res = await fetch('/state');
+      const res = await fetch('/api/v1/state');
       const d = await res.json();
       // Admin panel always visible - actions proxy to coordinator
       document.getElementById('adminPanel').style.display = 'block';
+      renderPhaseBanner(d.nodePhase);
 
-      if (!d.Active || !d.CurrentItem) {
+      if (d.phase === 'sold-announcement' && d.announcement) {
+        showSoldBanner(d.announcement, d.bidderStyles);
+        renderQueue(d.remainingItems || []);
+        renderResults(d.results || [], d.bidderStyles);
+        return;
+      }
+      document.getElementById('soldBanner').style.display = 'none';
+
+      currentItemId = d.active && d.currentItem ? d.currentItem.id : '';
+      if (!d.active || !d.currentItem) {
         document.getElementById('currentCard').style.display = 'none';
         document.getElementById('endedBanner').style.display = 'block';
         if (localTimerInterval) { clearInterval(localTimerInterval); localTimerInterval = null; }
         renderQueue([]);
-        renderResults(d.Results || []);
+        renderResults(d.results || [], d.bidderStyles);
         return;
       }
 
       document.getElementById('currentCard').style.display = 'flex';
       document.getElementById('endedBanner').style.display = 'none';
 
-      const item = d.CurrentItem;
-      document.getElementById('itemName').textContent = item.Name;
-      document.getElementById('itemDesc').textContent = item.Description;
-      document.getElementById('highestBid').textContent = '$' + d.CurrentHighestBid;
-      document.getElementById('winner').textContent = d.CurrentWinner || '—';
+      const item = d.currentItem;
+      document.getElementById('itemName').textContent = (item.emoji ? item.emoji + ' ' : '') + item.name;
+      document.getElementById('itemDesc').textContent = item.description;
+      renderCustom(item);
+      document.getElementById('highestBid').textContent = '$' + d.currentHighestBid;
+      document.getElementById('winner').innerHTML = d.currentWinner ? bidderLabel(d.currentWinner, d.bidderStyles) : '—';
+      renderQuickBids(d.minNextBid, d.bidIncrement);
+      renderAccess(item);
+      renderBudget(item.id + ':' + d.currentHighestBid + ':' + (d.results || []).length);
 
       // Leader indicator
-      document.getElementById('leaderBadge').style.display = d.IsCoordinator ? 'inline-block' : 'none';
+      document.getElementById('leaderBadge').style.display = d.isCoordinator ? 'inline-block' : 'none';
 
-      if (d.DeadlineUnix && d.DeadlineUnix !== deadlineUnix) {
-        startLocalTimer(d.DeadlineUnix, item.DurationSec);
+      if (d.deadlineUnix && d.deadlineUnix !== deadlineUnix) {
+        startLocalTimer(d.deadlineUnix, item.durationSec);
       }
+      document.getElementById('graceNote').style.display = d.graceFromUnix ? 'block' : 'none';
 
-      renderQueue(d.RemainingItems || []);
-      renderResults(d.Results || []);
+      renderQueue(d.remainingItems || []);
+      renderResults(d.results || [], d.bidderStyles);
     } catch(e) { console.error('state fetch error', e); }
   }
 
+  // Invite-only lots: only /me knows whether this browser's bidder ID is listed.
+  async function renderAccess(item) {
+    let allowed = true;
+    if (item.restricted) {
+      try {
+        const me = await (await fetch('/me')).json();
+        allowed = me.currentItemId !== item.id || me.eligible;
+      } catch(e) { /* let the bid itself report the refusal */ }
+    }
+    bidAllowed = allowed;
+    document.getElementById('bidBtn').disabled = !allowed;
+    document.getElementById('inviteNote').style.display = allowed ? 'none' : 'block';
+  }
+
+  // Custom fields are operator-supplied text: build the list with
+  // textContent so values are never parsed as HTML.
+  let customKey = '';
+  function renderCustom(item) {
+    const custom = item.custom || {};
+    const keys = Object.keys(custom).sort();
+    const key = item.id + JSON.stringify(custom);
+    if (key === customKey) return;
+    customKey = key;
+    const list = document.getElementById('itemCustom');
+    list.replaceChildren();
+    keys.forEach(function(k) {
+      const dt = document.createElement('dt');
+      dt.textContent = k;
+      const dd = document.createElement('dd');
+      dd.textContent = custom[k];
+      list.append(dt, dd);
+    });
+    document.getElementById('itemDetails').style.display = keys.length ? 'block' : 'none';
+  }
+
+  // Budgets: /me reports this browser's budget and hold. Holds only move
+  // when the price or the results change, so refetch only then.
+  let budgetKey = '';
+  async function renderBudget(key) {
+    if (key === budgetKey) return;
+    budgetKey = key;
+    let b = null;
+    try { b = (await (await fetch('/me')).json()).budget; } catch(e) { budgetKey = ''; }
+    const el = document.getElementById('budgetNote');
+    if (!b) { el.style.display = 'none'; return; }
+    el.textContent = 'Your budget: $' + b.available + ' available ($' + b.held + ' held on your leading bid, $' + b.spent + ' spent, of $' + b.limit + ')';
+    el.style.display = 'block';
+  }
+
+  // Quick-bid buttons step by the increment of the current price band.
+  function renderQuickBids(minBid, step) {
+    const el = document.getElementById('quickBids');
+    if (!minBid) { el.innerHTML = ''; return; }
+    step = step || 1;
+    const amounts = [minBid, minBid + step, minBid + 4 * step];
+    const key = amounts.join(',');
+    if (el.dataset.key === key) return;
+    el.dataset.key = key;
+    el.innerHTML = amounts.map(function(a) {
+      return '<button class="btn secondary small" onclick="document.getElementById(\'amount\').value=' + a + '">$' + a + '</button>';
+    }).join('');
+  }
+
+  function showSoldBanner(ann, styles) {
+    const r = ann.result;
+    document.getElementById('currentCard').style.display = 'none';
+    document.getElementById('endedBanner').style.display = 'none';
+    document.getElementById('soldBanner').style.display = 'block';
+    if (localTimerInterval) { clearInterval(localTimerInterval); localTimerInterval = null; }
+    if (r.winner === 'No bids') {
+      document.getElementById('soldTitle').textContent = 'UNSOLD';
+      document.getElementById('soldMeta').textContent = r.item.name + ' received no bids';
+    } else {
+      document.getElementById('soldTitle').innerHTML = 'SOLD to ' + bidderLabel(r.winner, styles) + ' for $' + r.winningBid;
+      document.getElementById('soldMeta').textContent = r.item.name;
+    }
+  }
+
+  function itemTitle(it) {
+    return (it.emoji ? it.emoji + ' ' : '') + escapeHTML(it.name);
+  }
+
+  function escapeHTML(s) {
+    return String(s).replace(/[&<>"']/g, function(c) {
+      return {'&':'&amp;','<':'&lt;','>':'&gt;','"':'&quot;',"'":'&#39;'}[c];
+    });
+  }
+
+  function bidderLabel(name, styles) {
+    var st = styles && styles[name];
+    if (!st) return escapeHTML(name);
+    return '<span class="bidder-dot" style="background:' + st.color + '" title="' + st.avatar + '"></span>' + escapeHTML(name);
+  }
+
   function renderQueue(items) {
     const el = document.getElementById('queueList');
     if (!items.length) { el.innerHTML = '<div class="empty-state">No more items</div>'; return; }
     el.innerHTML = items.map(function(it) {
       return '<div class="item-row">' +
         '<div class="item-info">' +
-          '<div class="item-row-title">' + it.Name + '</div>' +
-          '<div class="item-row-meta">' + it.Description + '</div>' +
+          '<div class="item-row-title">' + itemTitle(it) + '</div>' +
+          '<div class="item-row-meta">' + it.description + '</div>' +
         '</div>' +
-        '<div class="item-row-side">$' + it.StartingPrice + '</div>' +
+        '<div class="item-row-side">$' + it.startingPrice + '</div>' +
         '</div>';
     }).join('');
   }
 
-  function renderResults(results) {
+  function renderResults(results, styles) {
     const el = document.getElementById('resultsList');
     if (!results.length) { el.innerHTML = '<div class="empty-state">No items sold yet</div>'; return; }
     el.innerHTML = [...results].reverse().map(function(r) {
-      var winnerText = r.Winner === 'No bids' ? 'Unsold' : ('Won by ' + r.Winner);
-      var bidText = r.WinningBid > 0 ? ('$' + r.WinningBid) : '\u2014';
+      var winnerText = r.winner === 'No bids' ? 'Unsold' : ('Won by ' + bidderLabel(r.winner, styles));
+      var bidText = r.winningBid > 0 ? ('$' + r.winningBid) : '\u2014';
+      if (r.closedAtUnix) {
+        var closedAt = new Date(r.closedAtUnix * 1000).toLocaleTimeString([], {hour: 'numeric', minute: '2-digit'});
+        winnerText += (r.winner === 'No bids' ? ' \u00b7 closed at ' : ' \u00b7 sold at ') + closedAt;
+        if (r.actualDurationSec > r.scheduledDurationSec) {
+          winnerText += ' (+' + (r.actualDurationSec - r.scheduledDurationSec) + 's)';
+        }
+      }
       return '<div class="item-row">' +
         '<div class="item-info">' +
-          '<div class="item-row-title">' + r.Item.Name + '</div>' +
+          '<div class="item-row-title">' + itemTitle(r.item) + '</div>' +
           '<div class="item-row-meta">' + winnerText + '</div>' +
         '</div>' +
         '<div class="item-row-side">' + bidText + '</div>' +
@@ -385,7 +589,7 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
 
   async function submitBid() {
     const amount = document.getElementById('amount').value;
-    const bidder = document.getElementById('bidderName').value.trim() || 'Anonymous';
+    const bidder = document.getElementById('bidderName').value.trim();
     const fb = document.getElementById('feedback');
     const btn = document.getElementById('bidBtn');
     if (!amount) { fb.textContent = 'Enter a bid amount'; fb.className = 'err'; return; }
@@ -396,27 +600,42 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
     const body = new URLSearchParams();
     body.append('amount', amount);
     body.append('bidder', bidder);
+    body.append('itemId', currentItemId);
+    body.append('clientTime', Date.now()); // advisory, for fairness analysis only
 
     try {
       const res = await fetch('/bid', { method:'POST', body, headers:{'Content-Type':'application/x-www-form-urlencoded'} });
-      if (!res.ok) {
-        fb.textContent = await res.text(); fb.className = 'err';
+      if (res.status === 409) {
+        // The lot changed under the bidder: show the new one and let them decide.
+        await fetchState();
+        const name = document.getElementById('itemName').textContent;
+        fb.textContent = currentItemId
+          ? 'The item changed to ' + name + ' before your bid went in. Check the new lot and bid again if you still want it.'
+          : 'Bidding on that item closed before your bid went in.';
+        fb.className = 'err';
+      } else if (!res.ok) {
+        let msg = await res.text();
+        if (res.status === 503) msg += ' (the cluster is busy; try again in a moment)';
+        fb.textContent = msg; fb.className = 'err';
         setTimeout(function() { fb.textContent = ''; fb.className = ''; }, 10000);
+        // Outbid, below the increment or not invited: show the current state.
+        if (res.status === 422 || res.status === 403) fetchState();
       } else {
         fb.textContent = await res.text(); fb.className = 'ok';
         document.getElementById('amount').value = '';
         setTimeout(function() { fb.textContent = ''; }, 3000);
         fetchState();
       }
+      fetchMyBids();
     } catch(e) {
       fb.textContent = 'Network error. Try again.'; fb.className = 'err';
     }
-    btn.disabled = false;
+    btn.disabled = !bidAllowed;
   }
 
   async function fetchCheckpoint() {
     try {
-      const res = await fetch('/checkpoint');
+      const res = await fetch('/api/v1/checkpoint');
       if (res.status === 404) {
         document.getElementById('cpStatus').innerHTML = '<span class="cp-dot none"></span>None yet';
         document.getElementById('cpTime').textContent = '—';
@@ -435,6 +654,17 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
     } catch(e) { console.error('checkpoint fetch error', e); }
   }
 
+  async function fetchAlerts() {
+    try {
+      const res = await fetch('/alerts');
+      if (!res.ok) return;
+      const d = await res.json();
+      document.getElementById('alertList').innerHTML = (d.active || []).map(function(a) {
+        return '<div class="alert-item ' + escapeHTML(a.severity) + '">' + escapeHTML(a.message) + '</div>';
+      }).join('');
+    } catch(e) { console.error('alerts fetch error', e); }
+  }
+
   async function addItem() {
     const name = document.getElementById('newItemName').value.trim();
     const description = document.getElementById('newItemDesc').value.trim();
@@ -485,8 +715,179 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
     btn.disabled = false;
   }
 
+  function loadImportFile(input) {
+    const file = input.files[0];
+    if (!file) return;
+    const reader = new FileReader();
+    reader.onload = function() { document.getElementById('importJson').value = reader.result; };
+    reader.readAsText(file);
+    input.value = '';
+  }
+
+  // The whole batch is applied or none of it, so a failed import can simply
+  // be fixed and retried.
+  async function importItems() {
+    const text = document.getElementById('importJson').value.trim();
+    const fb = document.getElementById('adminFeedback');
+    const btn = document.getElementById('importBtn');
+    if (!text) { fb.textContent = 'Paste or load a JSON item list first'; fb.className = 'admin-feedback err'; return; }
+    try { JSON.parse(text); } catch (e) {
+      fb.textContent = 'Not valid JSON: ' + e.message; fb.className = 'admin-feedback err'; return;
+    }
+
+    btn.disabled = true;
+    fb.textContent = 'Importing…';
+    fb.className = 'admin-feedback';
+    try {
+      const res = await fetch('/items/batch', { method: 'POST', body: text, headers: {'Content-Type': 'application/json'} });
+      let d;
+      try { d = await res.json(); } catch (e) { d = { message: 'HTTP ' + res.status }; }
+      if (!res.ok) {
+        const details = (d.errors || []).slice(0, 5).map(function(e) { return '#' + (e.index + 1) + ': ' + e.error; });
+        fb.textContent = d.message + (details.length ? ' (' + details.join('; ') + ')' : '');
+        fb.className = 'admin-feedback err';
+      } else {
+        fb.textContent = d.message + ' (' + d.ids[0] + ' to ' + d.ids[d.ids.length - 1] + ')';
+        fb.className = 'admin-feedback ok';
+        document.getElementById('importJson').value = '';
+        fetchState();
+      }
+    } catch (e) {
+      fb.textContent = 'Network error. Try again.';
+      fb.className = 'admin-feedback err';
+    }
+    btn.disabled = false;
+  }
+
+  // My bids: newest first, refreshed while the drawer is open since
+  // "leading" turns into "outbid" or "won" without any action here.
+  let myBidsLimit = 50;
+  async function fetchMyBids() {
+    if (!document.getElementById('myBidsBox').open) return;
+    const list = document.getElementById('myBidsList');
+    try {
+      const d = await (await fetch('/me/bids?limit=' + myBidsLimit)).json();
+      list.replaceChildren();
+      if (!d.bids.length) {
+        const li = document.createElement('li');
+        li.textContent = 'No bids from this browser yet.';
+        list.append(li);
+      }
+      d.bids.forEach(function(b) {
+        const li = document.createElement('li');
+        const status = document.createElement('span');
+        status.className = 'status';
+        status.textContent = b.status;
+        status.style.color = b.status === 'leading' || b.status === 'won' ? 'var(--green)' : b.status === 'rejected' ? 'var(--red)' : '';
+        li.append(status, '$' + b.amount + ' on ' + (b.itemName || b.itemId) + ' at ' + new Date(b.atUnix * 1000).toLocaleTimeString() +
+          (b.message ? ' \u2014 ' + b.message : ''));
+        list.append(li);
+      });
+      document.getElementById('myBidsMore').style.display = d.total > d.bids.length ? 'inline-block' : 'none';
+    } catch (e) { list.textContent = 'Unavailable'; }
+  }
+
+  // Only polled while the in-flight box is open.
+  async function fetchInFlight() {
+    if (!document.getElementById('inflightBox').open) return;
+    try {
+      const d = await (await fetch('/admin/inflight')).json();
+      const ra = d.ra, lines = [];
+      lines.push(d.nodeId + ' (' + d.phase + ')' + (d.isCoordinator ? ' coordinator' : ' follower of ' + (d.coordinator || '—')));
+      if (!ra.requesting) lines.push('RA: idle');
+      else if (ra.inCriticalSection) lines.push('RA: in critical section for ' + ra.heldMs + 'ms');
+      else lines.push('RA: waiting ' + ra.waitingMs + 'ms for ' + ra.repliesOutstanding + ' of ' + ra.peers + ' replies');
+      if (ra.deferredPeers.length) lines.push('RA deferred: ' + ra.deferredPeers.join(', '));
+      (d.rounds || []).forEach(function(r) {
+        const no = Object.keys(r.no || {}).map(function(k) { return k + '=' + r.no[k]; }).join(' ');
+        lines.push((r.txnId || '(no txn yet)') + ' $' + r.amount + ' ' + r.bidder + ': ' + r.stage + ' ' + r.stageAgeMs + 'ms' +
+          (r.quorum ? ', yes ' + r.yes + '/' + r.quorum : '') + (no ? ', no ' + no : '') +
+          (r.awaiting ? ', awaiting ' + r.awaiting.join(', ') : ''));
+      });
+      const i = d.intake;
+      lines.push('HTTP ' + i.httpInFlight + ' in flight, ' + i.httpQueued + ' queued; forwards ' + i.bidForwards + '; async queued ' + i.asyncQueued);
+      if (i.oldestHeldEndpoint) lines.push('Oldest held: ' + i.oldestHeldEndpoint + ' ' + i.oldestHeldMs + 'ms');
+      document.getElementById('inflightText').textContent = lines.join('\n');
+    } catch (e) { document.getElementById('inflightText').textContent = 'Unavailable'; }
+  }
+
+  function formatSpan(sec) {
+    if (sec < 60) return sec + 's';
+    if (sec < 3600) return Math.floor(sec / 60) + 'm' + (sec %% 60) + 's';
+    return Math.floor(sec / 3600) + 'h' + Math.floor((sec %% 3600) / 60) + 'm';
+  }
+
+  // Cluster-wide, newest first. Only polled while the box is open.
+  async function fetchIncidents() {
+    if (!document.getElementById('incidentBox').open) return;
+    try {
+      const d = await (await fetch('/incidents')).json();
+      const lines = d.incidents.slice().reverse().slice(0, 100).map(function(i) {
+        const span = i.ongoing ? 'ongoing ' + formatSpan(i.durationSec) : (i.durationSec ? formatSpan(i.durationSec) : '');
+        return new Date(i.startUnix * 1000).toLocaleString() + '  ' + i.nodeId + '  ' + i.kind + '  ' + i.message +
+          (span ? '  [' + span + ']' : '');
+      });
+      d.unreachable.forEach(function(u) { lines.unshift('(no answer from ' + u.address + ')'); });
+      document.getElementById('incidentText').textContent = lines.length ? lines.join('\n') : 'No incidents';
+    } catch (e) { document.getElementById('incidentText').textContent = 'Unavailable'; }
+  }
+
+  function describePlan(p) {
+    var lines = [];
+    if (!p.accepted) return 'This action would be rejected: ' + p.message;
+    if (p.noOp) return p.message + ' \u2014 nothing would change.';
+    if (p.currentItem) {
+      lines.push('Current item "' + p.currentItem.Name + '" would be ' + p.currentItemDisposition +
+        (p.currentWinner ? ' (leading: ' + p.currentWinner + ' at $' + p.currentHighestBid + ')' : '') + '.');
+    }
+    if (p.nextItem && (!p.currentItem || p.nextItem.ID !== p.currentItem.ID || p.currentItemDisposition === 'discarded')) {
+      lines.push('"' + p.nextItem.Name + '" would open next.');
+    }
+    if (p.itemsDropped && p.itemsDropped.length) {
+      lines.push(p.itemsDropped.length + ' queued item(s) would be removed: ' +
+        p.itemsDropped.map(function(it) { return it.Name; }).join(', ') + '.');
+    }
+    if (p.resultsCleared && p.resultsCleared.length) {
+      lines.push(p.resultsCleared.length + ' completed result(s) would be cleared.');
+    }
+    lines.push((p.queueAfter ? p.queueAfter.length : 0) + ' item(s) would remain queued.');
+    return lines.join('\n');
+  }
+
+  async function previewControl(action) {
+    const body = new URLSearchParams();
+    body.append('action', action);
+    body.append('dryRun', 'true');
+    const res = await fetch('/admin/auction', {
+      method: 'POST',
+      body,
+      headers: {'Content-Type': 'application/x-www-form-urlencoded'}
+    });
+    if (!res.ok) throw new Error(await res.text());
+    return res.json();
+  }
+
   async function auctionControl(action) {
     const fb = document.getElementById('adminFeedback');
+    let archive = false;
+    if (action === 'restart' || action === 'stop') {
+      try {
+        const plan = await previewControl(action);
+        if (!plan.accepted) {
+          fb.textContent = plan.message;
+          fb.className = 'admin-feedback err';
+          return;
+        }
+        if (!confirm('Confirm ' + action + '?\n\n' + describePlan(plan))) return;
+        if (action === 'restart' && plan.resultsCleared && plan.resultsCleared.length) {
+          archive = confirm('Save the ' + plan.resultsCleared.length + ' result(s) as a past event before they are cleared?');
+        }
+      } catch (e) {
+        fb.textContent = 'Could not preview action: ' + e.message;
+        fb.className = 'admin-feedback err';
+        return;
+      }
+    }
     const startBtn = document.getElementById('startAuctionBtn');
     const stopBtn = document.getElementById('stopAuctionBtn');
     const restartBtn = document.getElementById('restartAuctionBtn');
@@ -498,6 +899,7 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
 
     const body = new URLSearchParams();
     body.append('action', action);
+    if (archive) body.append('archive', 'true');
 
     try {
       const res = await fetch('/admin/auction', {
@@ -526,8 +928,13 @@ func (n *Node) handleUI(w http.ResponseWriter, r *http.Request) {
 
   setInterval(fetchState, 1000);
   setInterval(fetchCheckpoint, 15000);
+  setInterval(fetchAlerts, 5000);
+  setInterval(fetchInFlight, 2000);
+  setInterval(fetchIncidents, 5000);
+  setInterval(fetchMyBids, 3000);
   fetchState();
   fetchCheckpoint();
+  fetchAlerts();
 </script>
 </body>
 </html>`, n.ID)
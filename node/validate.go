@@ -0,0 +1,150 @@
+package node
+
+// validate.go — Structural checks on replicated state before it is applied.
+//
+// Snapshots and 2PC decisions arrive from peers and are installed wholesale,
+// so one buggy or hostile peer could otherwise push impossible state (an
+// active auction with no item, a negative winning bid) that every node then
+// replicates further. applyQueueSnapshot and applyDecision run these checks
+// first; a message that fails is logged with the broken rule, counted in
+// invalid_messages_total{kind,rule} and dropped, leaving the last good state
+// in place. The message is kept as a dead letter; see deadletters.go.
+//
+// Only invariants that hold on every correct node are checked. The deadline
+// is not compared with the local clock: clock skew and a coordinator closing
+// a lot slightly late both produce past deadlines legitimately.
+
+import (
+	"fmt"
+	"log"
+)
+
+// invariantError names the rule a message broke and how.
+type invariantError struct {
+	rule   string
+	detail string
+}
+
+func (e *invariantError) Error() string {
+	return e.rule + ": " + e.detail
+}
+
+func broken(rule, format string, args ...interface{}) error {
+	return &invariantError{rule: rule, detail: fmt.Sprintf(format, args...)}
+}
+
+// validateQueueSnapshot checks snap for impossible field combinations.
+func validateQueueSnapshot(snap QueueSnapshot) error {
+	if snap.Active && snap.CurrentItem == nil && snap.Announcement == nil {
+		// Between lots a running auction has no current item, only the sold
+		// announcement; see announcement.go.
+		return broken("active_without_item", "Active is set but there is no current item")
+	}
+	if snap.Active && snap.DeadlineUnix <= 0 {
+		return broken("active_without_deadline", "Active is set but DeadlineUnix=%d", snap.DeadlineUnix)
+	}
+	if snap.DeadlineUnix < 0 || snap.OpenedAtUnix < 0 {
+		return broken("negative_time", "DeadlineUnix=%d OpenedAtUnix=%d", snap.DeadlineUnix, snap.OpenedAtUnix)
+	}
+	if snap.CurrentItem != nil && snap.OpenedAtUnix > 0 && snap.DeadlineUnix > 0 && snap.DeadlineUnix < snap.OpenedAtUnix {
+		return broken("deadline_before_open", "DeadlineUnix=%d is before OpenedAtUnix=%d", snap.DeadlineUnix, snap.OpenedAtUnix)
+	}
+	if snap.CurrentHighestBid < 0 {
+		// A fresh lot starts at StartingPrice-1, which is -1 for a free one.
+		if snap.CurrentItem == nil || snap.CurrentHighestBid != snap.CurrentItem.StartingPrice-1 {
+			return broken("negative_amount", "CurrentHighestBid=%d", snap.CurrentHighestBid)
+		}
+	}
+	if snap.ResultsTrimmed < 0 {
+		return broken("negative_count", "ResultsTrimmed=%d", snap.ResultsTrimmed)
+	}
+
+	queued := make(map[string]bool, len(snap.RemainingItems))
+	for _, item := range snap.RemainingItems {
+		if err := validateItem(item); err != nil {
+			return err
+		}
+		if queued[item.ID] {
+			return broken("duplicate_item", "item %s is queued twice", item.ID)
+		}
+		queued[item.ID] = true
+	}
+	if item := snap.CurrentItem; item != nil {
+		if err := validateItem(*item); err != nil {
+			return err
+		}
+		if queued[item.ID] {
+			return broken("current_item_queued", "current item %s is also in the remaining queue", item.ID)
+		}
+	}
+
+	decided := make(map[string]bool, len(snap.Results))
+	for _, res := range snap.Results {
+		if err := validateItem(res.Item); err != nil {
+			return err
+		}
+		if decided[res.Item.ID] {
+			return broken("duplicate_result", "item %s has two results", res.Item.ID)
+		}
+		decided[res.Item.ID] = true
+		if res.WinningBid < 0 {
+			return broken("negative_amount", "result for %s has WinningBid=%d", res.Item.ID, res.WinningBid)
+		}
+		if res.ClosedAtUnix > 0 && res.OpenedAtUnix > res.ClosedAtUnix {
+			return broken("closed_before_open", "result for %s closed at %d before opening at %d", res.Item.ID, res.ClosedAtUnix, res.OpenedAtUnix)
+		}
+	}
+	for bidderID, b := range snap.Budgets {
+		if b.Limit < 0 || b.Spent < 0 {
+			return broken("negative_amount", "budget of %s has Limit=%d Spent=%d", bidderID, b.Limit, b.Spent)
+		}
+	}
+	if book := snap.Paddles; book != nil {
+		holder := make(map[int]string, len(book.Numbers))
+		for bidderID, num := range book.Numbers {
+			if num < 1 || num > book.Last {
+				return broken("paddle_out_of_range", "paddle of %s is %d; numbers run 1..%d", bidderID, num, book.Last)
+			}
+			if other, dup := holder[num]; dup {
+				return broken("duplicate_paddle", "paddle %d is held by %s and %s", num, other, bidderID)
+			}
+			holder[num] = bidderID
+		}
+	}
+	return nil
+}
+
+// validateItem checks one lot of a snapshot.
+func validateItem(item AuctionItem) error {
+	if item.ID == "" {
+		return broken("item_without_id", "item %q has no ID", item.Name)
+	}
+	if item.StartingPrice < 0 || item.DurationSec < 0 {
+		return broken("negative_amount", "item %s has StartingPrice=%d DurationSec=%d", item.ID, item.StartingPrice, item.DurationSec)
+	}
+	return nil
+}
+
+// validateDecision checks a 2PC decision for the bid it would apply, after
+// withIdentity has filled in the bidder ID.
+func validateDecision(txnID string, commit bool, bid BidArgs) error {
+	if txnID == "" {
+		return broken("decision_without_txn", "decision has no txn ID")
+	}
+	if bid.Amount < 0 || commit && !bid.Canary && bid.Amount == 0 {
+		return broken("bad_amount", "txn %s carries amount %d", txnID, bid.Amount)
+	}
+	if commit && !bid.Canary && bid.BidderID == "" {
+		return broken("decision_without_bidder", "txn %s commits a bid with no bidder", txnID)
+	}
+	return nil
+}
+
+// rejectInvalid logs and counts a message that failed validation, and
+// keeps msg as a dead letter.
+func (n *Node) rejectInvalid(kind, source string, msg any, err error) {
+	rule := invariantRule(err, "invalid")
+	n.Metrics.Inc(metricName("invalid_messages_total", "kind", kind, "rule", rule))
+	log.Printf("[%s] 🛑 Rejected %s (%s): %v; keeping current state\n", n.ID, kind, source, err)
+	n.captureDeadLetter(kind, source, rule, msg, err)
+}
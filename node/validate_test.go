@@ -0,0 +1,206 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// goodSnapshot is a running auction with one lot up, one queued and one sold.
+func goodSnapshot() QueueSnapshot {
+	now := time.Now().Unix()
+	return QueueSnapshot{
+		CurrentItem:       &AuctionItem{ID: "lot2", StartingPrice: 20, DurationSec: 60},
+		CurrentHighestBid: 25,
+		CurrentWinnerID:   "b1",
+		OpenedAtUnix:      now - 10,
+		DeadlineUnix:      now + 50,
+		Active:            true,
+		RemainingItems:    []AuctionItem{{ID: "lot3", StartingPrice: 30, DurationSec: 60}},
+		Results:           []ItemResult{{Item: AuctionItem{ID: "lot1", StartingPrice: 10}, WinningBid: 12, OpenedAtUnix: now - 100, ClosedAtUnix: now - 40}},
+		Budgets:           map[string]BidderBudget{"b1": {Limit: 100, Spent: 12}},
+		Paddles:           &PaddleBook{Numbers: map[string]int{"b1": 1, "b2": 2}, Last: 2},
+	}
+}
+
+func TestValidateQueueSnapshot(t *testing.T) {
+	cases := []struct {
+		name   string
+		mutate func(*QueueSnapshot)
+		rule   string
+	}{
+		{"good", func(*QueueSnapshot) {}, ""},
+		{"between lots", func(s *QueueSnapshot) {
+			s.Announcement = &SoldAnnouncement{Result: s.Results[0]}
+			s.CurrentItem = nil
+		}, ""},
+		{"free lot just opened", func(s *QueueSnapshot) { s.CurrentItem.StartingPrice, s.CurrentHighestBid = 0, -1 }, ""},
+		{"past deadline", func(s *QueueSnapshot) { s.DeadlineUnix = s.OpenedAtUnix + 1 }, ""},
+		{"active without item", func(s *QueueSnapshot) { s.CurrentItem = nil }, "active_without_item"},
+		{"active without deadline", func(s *QueueSnapshot) { s.DeadlineUnix = 0 }, "active_without_deadline"},
+		{"negative deadline", func(s *QueueSnapshot) { s.Active, s.DeadlineUnix = false, -1 }, "negative_time"},
+		{"deadline before open", func(s *QueueSnapshot) { s.DeadlineUnix = s.OpenedAtUnix - 1 }, "deadline_before_open"},
+		{"negative highest bid", func(s *QueueSnapshot) { s.CurrentHighestBid = -5 }, "negative_amount"},
+		{"negative trimmed count", func(s *QueueSnapshot) { s.ResultsTrimmed = -1 }, "negative_count"},
+		{"item without ID", func(s *QueueSnapshot) { s.RemainingItems[0].ID = "" }, "item_without_id"},
+		{"negative starting price", func(s *QueueSnapshot) { s.RemainingItems[0].StartingPrice = -1 }, "negative_amount"},
+		{"item queued twice", func(s *QueueSnapshot) { s.RemainingItems = append(s.RemainingItems, s.RemainingItems[0]) }, "duplicate_item"},
+		{"current item queued", func(s *QueueSnapshot) { s.RemainingItems = append(s.RemainingItems, *s.CurrentItem) }, "current_item_queued"},
+		{"two results for a lot", func(s *QueueSnapshot) { s.Results = append(s.Results, s.Results[0]) }, "duplicate_result"},
+		{"negative winning bid", func(s *QueueSnapshot) { s.Results[0].WinningBid = -5 }, "negative_amount"},
+		{"closed before open", func(s *QueueSnapshot) { s.Results[0].OpenedAtUnix = s.Results[0].ClosedAtUnix + 1 }, "closed_before_open"},
+		{"negative budget", func(s *QueueSnapshot) { s.Budgets["b1"] = BidderBudget{Limit: -1} }, "negative_amount"},
+		{"paddle past the last", func(s *QueueSnapshot) { s.Paddles.Numbers["b3"] = 3 }, "paddle_out_of_range"},
+		{"paddle held twice", func(s *QueueSnapshot) { s.Paddles.Numbers["b3"] = 1 }, "duplicate_paddle"},
+	}
+	for _, c := range cases {
+		snap := goodSnapshot()
+		c.mutate(&snap)
+		err := validateQueueSnapshot(snap)
+		if got := invariantRule(err, "invalid"); err == nil && c.rule != "" || err != nil && got != c.rule {
+			t.Errorf("%s: %v, want rule %q", c.name, err, c.rule)
+		}
+	}
+}
+
+func TestValidateDecision(t *testing.T) {
+	cases := []struct {
+		name   string
+		txnID  string
+		commit bool
+		bid    BidArgs
+		rule   string
+	}{
+		{"commit", "A-1", true, BidArgs{BidderID: "b1", Amount: 15}, ""},
+		{"abort with no bid", "A-1", false, BidArgs{}, ""},
+		{"canary", "A-1", true, BidArgs{Canary: true}, ""},
+		{"no txn", "", true, BidArgs{BidderID: "b1", Amount: 15}, "decision_without_txn"},
+		{"negative amount", "A-1", true, BidArgs{BidderID: "b1", Amount: -5}, "bad_amount"},
+		{"negative amount aborted", "A-1", false, BidArgs{BidderID: "b1", Amount: -5}, "bad_amount"},
+		{"zero commit", "A-1", true, BidArgs{BidderID: "b1"}, "bad_amount"},
+		{"no bidder", "A-1", true, BidArgs{Amount: 15}, "decision_without_bidder"},
+	}
+	for _, c := range cases {
+		err := validateDecision(c.txnID, c.commit, c.bid)
+		if got := invariantRule(err, "invalid"); err == nil && c.rule != "" || err != nil && got != c.rule {
+			t.Errorf("%s: %v, want rule %q", c.name, err, c.rule)
+		}
+	}
+}
+
+func TestInvalidMessagesKeepState(t *testing.T) {
+	n := withLotUp(biddingNode(t))
+	rp := &NodeRPC{node: n}
+	before := n.Queue.Version()
+
+	bad := goodSnapshot()
+	bad.CurrentItem = nil
+	bad.DeadlineUnix = time.Now().Add(-time.Hour).Unix()
+	var ok bool
+	if err := rp.SyncQueueState(bad, &ok); err == nil || ok {
+		t.Errorf("push of an active snapshot with no item: %v, %v; want refused", ok, err)
+	}
+	if err := rp.DecideBid(DecisionArgs{TxnID: "A-9", Commit: true, Bid: BidArgs{BidderID: "b1", DisplayName: "b1", Amount: -5, ItemID: "lot1"}}, &ok); err != nil {
+		t.Fatal(err)
+	}
+	if got := highestBid(n); got != 10 || n.Queue.Version() != before {
+		t.Errorf("highest bid %d, version %d → %d; want the state untouched", got, before, n.Queue.Version())
+	}
+	if n.decisions.wasApplied("A-9") {
+		t.Error("rejected decision marked applied")
+	}
+	for _, m := range []string{
+		metricName("invalid_messages_total", "kind", "snapshot", "rule", "active_without_item"),
+		metricName("invalid_messages_total", "kind", "decision", "rule", "bad_amount"),
+	} {
+		if got := n.Metrics.Counter(m); got != 1 {
+			t.Errorf("%s = %v, want 1", m, got)
+		}
+	}
+	if letters := n.deadLetters.list(); len(letters) != 2 || letters[0].Reason == letters[1].Reason {
+		t.Errorf("dead letters = %+v, want the snapshot and the decision", letters)
+	}
+
+	// A good snapshot still goes in afterwards.
+	good := goodSnapshot()
+	if err := rp.SyncQueueState(good, &ok); err != nil || !ok {
+		t.Fatalf("good push: %v", err)
+	}
+	if got := highestBid(n); got != 25 {
+		t.Errorf("highest bid after a good push = %d, want 25", got)
+	}
+}
+
+// fuzzSnapshot builds a snapshot from a handful of fuzzed fields, with
+// item IDs drawn from a small set so duplicates turn up.
+func fuzzSnapshot(active, hasItem bool, current, queued, sold uint8, highest int, opened, deadline int64, winning int) QueueSnapshot {
+	id := func(i uint8) string { return fmt.Sprintf("lot%d", i%4) }
+	snap := QueueSnapshot{Active: active, CurrentHighestBid: highest, OpenedAtUnix: opened, DeadlineUnix: deadline}
+	if hasItem {
+		snap.CurrentItem = &AuctionItem{ID: id(current), StartingPrice: int(current) - 8}
+	}
+	for i := uint8(0); i < queued%4; i++ {
+		snap.RemainingItems = append(snap.RemainingItems, AuctionItem{ID: id(queued + i*3)})
+	}
+	for i := uint8(0); i < sold%4; i++ {
+		snap.Results = append(snap.Results, ItemResult{Item: AuctionItem{ID: id(sold + i*5)}, WinningBid: winning, ClosedAtUnix: opened})
+	}
+	return snap
+}
+
+func FuzzValidateQueueSnapshot(f *testing.F) {
+	f.Add(true, true, uint8(1), uint8(2), uint8(1), 25, int64(100), int64(160), 12)
+	f.Add(true, false, uint8(0), uint8(0), uint8(0), 0, int64(0), int64(-1), 0)
+	f.Add(false, true, uint8(2), uint8(3), uint8(2), -5, int64(200), int64(100), -5)
+	f.Fuzz(func(t *testing.T, active, hasItem bool, current, queued, sold uint8, highest int, opened, deadline int64, winning int) {
+		snap := fuzzSnapshot(active, hasItem, current, queued, sold, highest, opened, deadline, winning)
+		if err := validateQueueSnapshot(snap); err != nil {
+			if _, ok := err.(*invariantError); !ok {
+				t.Fatalf("error %v does not name a rule", err)
+			}
+			return
+		}
+		// Whatever passes must hold every invariant.
+		if snap.Active && (snap.CurrentItem == nil || snap.DeadlineUnix <= 0) {
+			t.Fatalf("accepted an active auction with item %v and deadline %d", snap.CurrentItem, snap.DeadlineUnix)
+		}
+		if snap.DeadlineUnix < 0 || snap.OpenedAtUnix < 0 {
+			t.Fatalf("accepted negative times %d %d", snap.DeadlineUnix, snap.OpenedAtUnix)
+		}
+		if snap.CurrentHighestBid < 0 && (snap.CurrentItem == nil || snap.CurrentHighestBid != snap.CurrentItem.StartingPrice-1) {
+			t.Fatalf("accepted highest bid %d", snap.CurrentHighestBid)
+		}
+		seen := map[string]bool{}
+		for _, item := range snap.RemainingItems {
+			if seen[item.ID] {
+				t.Fatalf("accepted %s queued twice", item.ID)
+			}
+			seen[item.ID] = true
+		}
+		if snap.CurrentItem != nil && seen[snap.CurrentItem.ID] {
+			t.Fatalf("accepted current item %s also queued", snap.CurrentItem.ID)
+		}
+		seen = map[string]bool{}
+		for _, r := range snap.Results {
+			if seen[r.Item.ID] || r.WinningBid < 0 {
+				t.Fatalf("accepted result %+v", r)
+			}
+			seen[r.Item.ID] = true
+		}
+	})
+}
+
+func FuzzValidateDecision(f *testing.F) {
+	f.Add("A-1", true, "b1", 15, false)
+	f.Add("A-1", true, "b1", -5, false)
+	f.Add("", false, "", 0, true)
+	f.Fuzz(func(t *testing.T, txnID string, commit bool, bidderID string, amount int, canary bool) {
+		err := validateDecision(txnID, commit, BidArgs{BidderID: bidderID, Amount: amount, Canary: canary})
+		if err != nil {
+			return
+		}
+		if txnID == "" || amount < 0 || commit && !canary && (amount == 0 || bidderID == "") {
+			t.Fatalf("accepted txn %q commit=%v bidder %q amount %d canary=%v", txnID, commit, bidderID, amount, canary)
+		}
+	})
+}
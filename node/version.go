@@ -0,0 +1,281 @@
+package node
+
+// version.go — Build/version information and cluster version checks.
+//
+// Version, Commit and BuildDate are stamped at build time:
+//
+//	go build -ldflags "-X auction_node/node.Version=v1.2.0 \
+//	  -X auction_node/node.Commit=$(git rev-parse --short HEAD) \
+//	  -X auction_node/node.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" .
+//
+// ProtocolVersion is bumped whenever an RPC message changes incompatibly.
+// Members with a different protocol version are reported by the coordinator,
+// and with --strict-versioning it refuses writes until the cluster agrees.
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+const ProtocolVersion = 1
+
+const versionPollInterval = 10 * time.Second
+
+type VersionInfo struct {
+	NodeID    string `json:"nodeId"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	Protocol  int    `json:"protocol"`
+	Rank      int    `json:"rank,omitempty"` // election rank; 0 from older builds
+
+	// Capabilities lists optional protocol features, e.g. "snapshot-gzip".
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// PeerVersion is one row of GET /peers.
+type PeerVersion struct {
+	Address    string         `json:"address"`
+	Reachable  bool           `json:"reachable"`
+	Compatible bool           `json:"compatible"`
+	Info       *VersionInfo   `json:"info,omitempty"`
+	CheckedAt  int64          `json:"checkedAtUnix"`
+	Breaker    *BreakerStatus `json:"breaker,omitempty"` // set by GET /peers only
+	RTT        *RTTStats      `json:"rtt,omitempty"`     // set by GET /peers only
+	Latency    *LatencyRule   `json:"injectedLatency,omitempty"`
+}
+
+func (n *Node) versionInfo() VersionInfo {
+	return VersionInfo{
+		NodeID:       n.ID,
+		Version:      Version,
+		Commit:       Commit,
+		BuildDate:    BuildDate,
+		Protocol:     ProtocolVersion,
+		Rank:         n.Rank,
+		Capabilities: localCapabilities(),
+	}
+}
+
+// GetVersion returns this node's build and protocol version.
+func (rp *NodeRPC) GetVersion(_ EmptyArgs, reply *VersionInfo) error {
+	*reply = rp.node.versionInfo()
+	return nil
+}
+
+// recordPeerVersion stores the latest version observation for a peer.
+func (n *Node) recordPeerVersion(address string, info *VersionInfo) {
+	n.versionsMu.Lock()
+	defer n.versionsMu.Unlock()
+	if n.peerVersions == nil {
+		n.peerVersions = map[string]PeerVersion{}
+	}
+	pv := PeerVersion{Address: address, CheckedAt: time.Now().Unix()}
+	if info != nil {
+		pv.Reachable = true
+		pv.Info = info
+		pv.Compatible = info.Protocol == ProtocolVersion
+	} else if prev, ok := n.peerVersions[address]; ok {
+		// Keep the last known version of an unreachable peer.
+		pv.Info = prev.Info
+		pv.Compatible = prev.Compatible
+	} else {
+		pv.Compatible = true
+	}
+	n.peerVersions[address] = pv
+}
+
+// peerName returns a peer's node ID when its version is known, else its address.
+func (n *Node) peerName(address string) string {
+	n.versionsMu.Lock()
+	defer n.versionsMu.Unlock()
+	if pv, ok := n.peerVersions[address]; ok && pv.Info != nil && pv.Info.NodeID != "" {
+		return pv.Info.NodeID
+	}
+	return address
+}
+
+// peerAddressOf is the reverse of peerName: the address a peer last
+// answered GetVersion on as id, or "" if none has. Unlike the port-by-rank
+// guess it works whatever ports the peers listen on.
+func (n *Node) peerAddressOf(id string) string {
+	peers := n.peerList()
+	n.versionsMu.Lock()
+	defer n.versionsMu.Unlock()
+	for _, address := range peers {
+		if pv, ok := n.peerVersions[address]; ok && pv.Info != nil && pv.Info.NodeID == id {
+			return address
+		}
+	}
+	return ""
+}
+
+// peerRankOf returns the node ID and rank a peer last answered GetVersion
+// with; ok is false until it has, or if its build predates ranks there.
+func (n *Node) peerRankOf(address string) (id string, rank int, ok bool) {
+	n.versionsMu.Lock()
+	defer n.versionsMu.Unlock()
+	pv, found := n.peerVersions[address]
+	if !found || pv.Info == nil || pv.Info.Rank == 0 {
+		return "", 0, false
+	}
+	return pv.Info.NodeID, pv.Info.Rank, true
+}
+
+// peerAddressByID resolves a node ID from its Bully messages, else from
+// its /version answer; "" if neither has been seen.
+func (n *Node) peerAddressByID(id string) string {
+	n.ElectionMutex.Lock()
+	learned := n.bullyAddrs[id]
+	n.ElectionMutex.Unlock()
+	if learned != "" {
+		return learned
+	}
+	return n.peerAddressOf(id)
+}
+
+// noteProtocolError surfaces an RPC that failed to encode or decode: it is
+// counted, logged with a version hint and raised as an alert, instead of
+// passing for an unreachable peer. A peer refusing this node's cluster
+// secret (see rpcauth.go) is reported as such.
+func (n *Node) noteProtocolError(pe *ProtocolError) {
+	method := strings.TrimPrefix(pe.Method, "NodeRPC.")
+	n.Metrics.Inc(metricName("rpc_protocol_errors_total", "peer", pe.Peer, "method", method))
+	if errors.Is(pe.Err, errSecretRequired) || errors.Is(pe.Err, errSecretUnknown) || isAuthError(pe.Err) {
+		log.Printf("[%s] 🔐 %s to %s refused: %v\n", n.ID, method, pe.Peer, pe.Err)
+		n.Alerts.Notify("cluster_secret_mismatch:"+pe.Peer, SeverityWarning,
+			fmt.Sprintf("%s (%s) does not share this node's --cluster-secret: %v", n.peerName(pe.Peer), pe.Peer, pe.Err))
+		return
+	}
+	log.Printf("[%s] ⚠️  %s to %s could not be encoded/decoded: %v — the peer probably runs an incompatible build (compare /version on both; see /peers)\n",
+		n.ID, method, pe.Peer, pe.Err)
+	n.Alerts.Notify("incompatible_protocol:"+pe.Peer, SeverityWarning,
+		fmt.Sprintf("%s (%s) speaks an incompatible protocol: %s failed with %v", n.peerName(pe.Peer), pe.Peer, method, pe.Err))
+}
+
+// noteHandshakeVersion records the version a peer reported during the join
+// handshake. Peers that predate versioning report protocol 0.
+func (n *Node) noteHandshakeVersion(address string, info VersionInfo) {
+	if info.Version == "" {
+		info.Version = "pre-versioning"
+	}
+	if info.Protocol != ProtocolVersion {
+		log.Printf("[%s] ⚠️  %s speaks protocol %d (%s); this node speaks %d (%s)\n",
+			n.ID, address, info.Protocol, info.Version, ProtocolVersion, Version)
+	}
+	n.recordPeerVersion(address, &info)
+}
+
+// peerVersionTable returns the observed versions for all current peers.
+func (n *Node) peerVersionTable() []PeerVersion {
+	n.versionsMu.Lock()
+	defer n.versionsMu.Unlock()
+	rows := make([]PeerVersion, 0, len(n.peerVersions))
+	for _, addr := range n.peerList() {
+		if pv, ok := n.peerVersions[addr]; ok {
+			rows = append(rows, pv)
+		} else {
+			rows = append(rows, PeerVersion{Address: addr, Compatible: true})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Address < rows[j].Address })
+	return rows
+}
+
+// incompatiblePeers lists peers last seen speaking a different protocol.
+func (n *Node) incompatiblePeers() []string {
+	var out []string
+	for _, pv := range n.peerVersionTable() {
+		if !pv.Compatible && pv.Info != nil {
+			out = append(out, fmt.Sprintf("%s (%s, protocol %d)", pv.Address, pv.Info.Version, pv.Info.Protocol))
+		}
+	}
+	return out
+}
+
+// versionWriteBlock returns a rejection message when --strict-versioning is
+// on and the cluster has mixed protocol versions, or "" if writes may proceed.
+func (n *Node) versionWriteBlock() string {
+	if !n.runtimeConfig().StrictVersioning {
+		return ""
+	}
+	if bad := n.incompatiblePeers(); len(bad) > 0 {
+		return fmt.Sprintf("Writes disabled: mixed protocol versions in cluster (local protocol %d; %s)",
+			ProtocolVersion, strings.Join(bad, ", "))
+	}
+	return ""
+}
+
+// monitorPeerVersions polls every peer's version. The coordinator logs a
+// warning whenever the set of incompatible members changes.
+func (n *Node) monitorPeerVersions() {
+	lastReported := ""
+	var duplicates map[string]bool
+	for {
+		for _, peer := range n.peerList() {
+			var info VersionInfo
+			if err := n.callPeer(peer, "NodeRPC.GetVersion", EmptyArgs{}, &info); err != nil {
+				n.recordPeerVersion(peer, nil)
+				continue
+			}
+			n.recordPeerVersion(peer, &info)
+		}
+
+		bad := n.incompatiblePeers()
+		n.Metrics.Set("cluster_incompatible_peers", float64(len(bad)))
+		report := strings.Join(bad, ", ")
+		if report != lastReported && n.isCoordinatorOrUnknown() {
+			if report != "" {
+				log.Printf("[%s] ⚠️  VERSION MISMATCH: local %s (protocol %d) but %s\n",
+					n.ID, Version, ProtocolVersion, report)
+			} else if lastReported != "" {
+				log.Printf("[%s] Cluster protocol versions agree again (protocol %d)\n", n.ID, ProtocolVersion)
+			}
+			lastReported = report
+		}
+		duplicates = n.checkDuplicateIDs(duplicates)
+		time.Sleep(versionPollInterval)
+	}
+}
+
+// handleVersionRequest serves GET /version.
+func (n *Node) handleVersionRequest(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, n.versionInfo())
+}
+
+// handlePeersRequest serves GET /peers: membership with observed versions.
+func (n *Node) handlePeersRequest(w http.ResponseWriter, r *http.Request) {
+	peers := n.peerVersionTable()
+	for i := range peers {
+		b := n.Client.Breaker(peers[i].Address)
+		peers[i].Breaker = &b
+		if rtt, ok := n.Client.RTT(peers[i].Address); ok {
+			peers[i].RTT = &rtt
+		}
+		if rule, ok := n.Client.Latency(peers[i].Address); ok {
+			peers[i].Latency = &rule
+		}
+	}
+	mode := "cluster"
+	if len(peers) == 0 {
+		mode = "single-node"
+	}
+	writeJSON(w, struct {
+		Mode        string        `json:"mode"`
+		ClusterSize int           `json:"clusterSize"`
+		Quorum      int           `json:"quorum"`
+		Self        VersionInfo   `json:"self"`
+		Peers       []PeerVersion `json:"peers"`
+	}{mode, len(peers) + 1, quorumFor(len(peers)), n.versionInfo(), peers})
+}
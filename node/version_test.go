@@ -0,0 +1,86 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// joinAnswer answers a join handshake as a member running the given build.
+func joinAnswer(info VersionInfo) func(context.Context, string, interface{}) error {
+	return func(_ context.Context, method string, reply interface{}) error {
+		*reply.(*JoinReply) = JoinReply{Accepted: true, ConfigHash: clusterConfigHash(), Version: info}
+		return nil
+	}
+}
+
+func TestMixedVersionHandshake(t *testing.T) {
+	caller := &fakeCaller{peers: map[string]func(context.Context, string, interface{}) error{
+		"old:1": joinAnswer(VersionInfo{}), // predates versioning
+	}}
+	n := electionNode(t, caller)
+	withLotUp(n)
+	if err := n.JoinCluster([]string{"old:1"}); err != nil {
+		t.Fatal(err)
+	}
+	n.noteHandshakeVersion("same:1", n.versionInfo())
+	n.addPeer("same:1")
+	n.addPeer("old:1")
+
+	bad := n.incompatiblePeers()
+	if len(bad) != 1 || !strings.Contains(bad[0], "old:1 (pre-versioning, protocol 0)") {
+		t.Fatalf("incompatible peers = %v, want old:1 alone", bad)
+	}
+
+	w := httptest.NewRecorder()
+	n.handlePeersRequest(w, httptest.NewRequest(http.MethodGet, "/peers", nil))
+	var body struct {
+		Peers []PeerVersion `json:"peers"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	compatible := map[string]bool{}
+	for _, p := range body.Peers {
+		compatible[p.Address] = p.Compatible
+	}
+	if len(compatible) != 2 || compatible["old:1"] || !compatible["same:1"] {
+		t.Errorf("/peers compatibility = %v, want old:1 false and same:1 true", compatible)
+	}
+
+	// Writes go ahead unless --strict-versioning is on.
+	if msg := n.versionWriteBlock(); msg != "" {
+		t.Errorf("write blocked without strict versioning: %s", msg)
+	}
+	cfg := DefaultRuntimeConfig()
+	cfg.StrictVersioning = true
+	if err := n.SetBaseConfig(cfg, []string{"strictVersioning"}); err != nil {
+		t.Fatal(err)
+	}
+	reply := n.ProposeBid(context.Background(), BidArgs{BidderID: "b1", DisplayName: "Ann", Amount: 50, ItemID: "lot1"})
+	if reply.Code != BidUnavailable || !strings.Contains(reply.Message, "mixed protocol versions") {
+		t.Errorf("bid in a mixed cluster under strict versioning = %s %q, want unavailable", reply.Code, reply.Message)
+	}
+
+	// Once the old member is upgraded, writes resume.
+	n.recordPeerVersion("old:1", &VersionInfo{NodeID: "N9", Version: "v2", Protocol: ProtocolVersion})
+	if msg := n.versionWriteBlock(); msg != "" {
+		t.Errorf("write still blocked after the upgrade: %s", msg)
+	}
+}
+
+func TestUnreachablePeerKeepsLastVersion(t *testing.T) {
+	n := electionNode(t, &fakeCaller{}, "p:1")
+	n.recordPeerVersion("p:1", &VersionInfo{NodeID: "N3", Version: "v0", Protocol: ProtocolVersion + 1})
+	n.recordPeerVersion("p:1", nil)
+	rows := n.peerVersionTable()
+	if len(rows) != 1 || rows[0].Reachable || rows[0].Compatible || rows[0].Info == nil || rows[0].Info.NodeID != "N3" {
+		t.Errorf("row for an unreachable incompatible peer = %+v", rows)
+	}
+	if got := n.peerName("p:1"); got != "N3" {
+		t.Errorf("peerName = %q, want N3", got)
+	}
+}
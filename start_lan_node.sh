@@ -49,7 +49,8 @@ exePath="./auction_node"
 if [ -f "$exePath" ]; then
     rm "$exePath"
 fi
-go build -o auction_node .
+VERSION_LDFLAGS="-X auction_node/node.Version=$(git describe --tags --always --dirty 2>/dev/null || echo dev) -X auction_node/node.Commit=$(git rev-parse --short HEAD 2>/dev/null || echo unknown) -X auction_node/node.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+go build -ldflags "$VERSION_LDFLAGS" -o auction_node .
 
 # [4] Build peer list (all nodes except self)
 peers=()
@@ -42,7 +42,8 @@ fi
 echo "[3/5] Removing stale executables and rebuilding..."
 rm -f "$SCRIPT_DIR/auction_node" "$SCRIPT_DIR/auction_node.exe" "$SCRIPT_DIR/distributed-auction.exe"
 
-go build -o auction_node .
+VERSION_LDFLAGS="-X auction_node/node.Version=$(git describe --tags --always --dirty 2>/dev/null || echo dev) -X auction_node/node.Commit=$(git rev-parse --short HEAD 2>/dev/null || echo unknown) -X auction_node/node.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+go build -ldflags "$VERSION_LDFLAGS" -o auction_node .
 echo "    Build successful → $EXE"
 
 # ── [4/5] Start 4 nodes in the background ─────────────────────────────────────